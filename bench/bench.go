@@ -0,0 +1,92 @@
+// Package bench runs the dispatch benchmarks generated with 'socketgen gen --zero-alloc' and
+// parses their results.
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Result is one benchmark line of 'go test -bench -benchmem'
+type Result struct {
+	Name        string // Benchmark name without the "Benchmark" prefix and CPU suffix (e.g., "DispatchMoveReq")
+	Runs        int
+	NsPerOp     float64
+	BytesPerOp  int64
+	AllocsPerOp int64
+}
+
+// Options configures Run
+type Options struct {
+	Pattern    string // -bench regular expression
+	Benchtime  string // -benchtime, e.g. "1s" or "10000x"; empty for the default
+	MemProfile string // -memprofile output file; empty for none
+}
+
+// Run runs the benchmarks of the Go package in dir and returns their results. The output of
+// 'go test' is copied to log.
+func Run(dir string, opts Options, log io.Writer) ([]Result, error) {
+	args := []string{"test", "-run", "^$", "-bench", opts.Pattern, "-benchmem"}
+	if opts.Benchtime != "" {
+		args = append(args, "-benchtime", opts.Benchtime)
+	}
+	if opts.MemProfile != "" {
+		args = append(args, "-memprofile", opts.MemProfile)
+	}
+	args = append(args, ".")
+
+	var out bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = io.MultiWriter(&out, log)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go test failed: %w", err)
+	}
+	return Parse(&out)
+}
+
+// Parse extracts the benchmark results from 'go test -bench -benchmem' output
+func Parse(r io.Reader) ([]Result, error) {
+	var results []Result
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+
+		name := strings.TrimPrefix(fields[0], "Benchmark")
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+		runs, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+
+		res := Result{Name: name, Runs: runs, BytesPerOp: -1, AllocsPerOp: -1}
+		// The remaining fields are value/unit pairs
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, unit := fields[i], fields[i+1]
+			switch unit {
+			case "ns/op":
+				res.NsPerOp, _ = strconv.ParseFloat(value, 64)
+			case "B/op":
+				res.BytesPerOp, _ = strconv.ParseInt(value, 10, 64)
+			case "allocs/op":
+				res.AllocsPerOp, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+		results = append(results, res)
+	}
+	return results, scanner.Err()
+}
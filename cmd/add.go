@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/lock"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	addRange string
+	addField string
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <MessageName>",
+	Short: "Add a payload to packet.proto",
+	Long: `Declares an empty payload message in packet.proto and adds it to the payload oneof of
+GamePacket, numbered with the lowest free field number of its --range from socketgen.yaml, or
+after the highest payload number if no ranges are declared. Reserved numbers and the numbers of
+removed payloads recorded in socketgen.lock are never reused.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if !messageName.MatchString(name) {
+			fmt.Printf("Error: %q is not a message name in PascalCase (e.g., ChatReact)\n", name)
+			os.Exit(1)
+		}
+		field := addField
+		if field == "" {
+			field = snakeCase(name)
+		}
+
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+		packet := result.Schema.Packet
+		if packet.ParentFile().Messages().ByName(protoreflect.Name(name)) != nil {
+			fmt.Printf("Error: %s already defines %s\n", schemaFile, name)
+			os.Exit(1)
+		}
+		if packet.Fields().ByName(protoreflect.Name(field)) != nil {
+			fmt.Printf("Error: %s already has a field named %s; choose another with --field\n", result.Wrapper, field)
+			os.Exit(1)
+		}
+
+		taken, err := takenNumbers(packet)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		number, where, err := allocateNumber(result, cfg.Ranges, taken)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		src, err := os.ReadFile(schemaFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		updated, err := insertPayload(string(src), result.Wrapper, name, field, number)
+		if err != nil {
+			fmt.Printf("Error: %v; add it by hand as %s %s = %d\n", err, name, field, number)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(schemaFile, []byte(updated), 0644); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// The edit is textual, so make sure the schema still compiles
+		if _, err := parser.Parse(schemaFile); err != nil {
+			os.WriteFile(schemaFile, src, 0644)
+			fmt.Printf("Error: %s does not compile with %s added, so it was left unchanged: %v\n", schemaFile, name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %s to %s as %s = %d%s.\n", name, schemaFile, field, number, where)
+	},
+}
+
+var messageName = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// takenNumbers reports the field numbers of packet a new payload cannot have: those of its
+// fields, reserved ones, and those socketgen.lock records, so removed payloads are not reused
+func takenNumbers(packet protoreflect.MessageDescriptor) (func(int32) bool, error) {
+	history, err := lock.Load(lock.FileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	recorded := map[int32]bool{}
+	if history != nil {
+		for _, p := range history.Payloads {
+			recorded[p.Number] = true
+		}
+	}
+
+	return func(n int32) bool {
+		number := protowire.Number(n)
+		return recorded[n] ||
+			packet.Fields().ByNumber(number) != nil ||
+			packet.ReservedRanges().Has(number) ||
+			(number >= protowire.FirstReservedNumber && number <= protowire.LastReservedNumber)
+	}, nil
+}
+
+// allocateNumber returns the lowest free number of --range, or the number after the highest
+// payload if there are no ranges, and a description of where it comes from
+func allocateNumber(result *parser.ParseResult, ranges config.Ranges, taken func(int32) bool) (int32, string, error) {
+	if len(ranges) == 0 {
+		if addRange != "" {
+			return 0, "", fmt.Errorf("--range is set, but %s declares no ranges", configFile)
+		}
+		var highest int32
+		for _, p := range result.Payloads {
+			highest = max(highest, p.Number)
+		}
+		for n := highest + 1; n <= int32(protowire.MaxValidNumber); n++ {
+			if !taken(n) {
+				return n, "", nil
+			}
+		}
+		return 0, "", fmt.Errorf("no field number is left after %d", highest)
+	}
+
+	if addRange == "" {
+		return 0, "", fmt.Errorf("%s declares ranges; choose one with --range (%s)", configFile, strings.Join(ranges.Names(), ", "))
+	}
+	r, ok := ranges.Find(addRange)
+	if !ok {
+		return 0, "", fmt.Errorf("%s declares no range %q; declared ranges: %s", configFile, addRange, strings.Join(ranges.Names(), ", "))
+	}
+	for n := r.Min; n <= r.Max; n++ {
+		if !taken(n) {
+			return n, ", in range " + r.String(), nil
+		}
+	}
+	return 0, "", fmt.Errorf("range %s is full; extend it in %s", r, configFile)
+}
+
+// memberLine matches a oneof member declared on a single line, capturing its indentation and number
+var memberLine = regexp.MustCompile(`(?m)^([ \t]*)[\w.]+[ \t]+\w+[ \t]*=[ \t]*(\d+)[^;\n]*;[^\n]*\n`)
+
+// insertPayload declares the message name before the wrapper message of src and adds it to the
+// payload oneof as field = number, after the members with lower numbers
+func insertPayload(src, wrapper, name, field string, number int32) (string, error) {
+	wrapperStart := regexp.MustCompile(`(?m)^[ \t]*message[ \t]+` + wrapper + `[ \t]*\{`).FindStringIndex(src)
+	if wrapperStart == nil {
+		return "", fmt.Errorf("message %s is not in %s", wrapper, schemaFile)
+	}
+	oneofStart := regexp.MustCompile(`oneof[ \t]+payload[ \t]*\{[^\n]*\n`).FindStringIndex(src[wrapperStart[0]:])
+	if oneofStart == nil {
+		return "", fmt.Errorf("the payload oneof of %s was not found", wrapper)
+	}
+	bodyStart := wrapperStart[0] + oneofStart[1]
+	bodyEnd := closingBrace(src, bodyStart)
+	if bodyEnd < 0 {
+		return "", fmt.Errorf("the payload oneof of %s is not closed", wrapper)
+	}
+
+	// After the last member numbered lower, else first, indented like the members
+	at, indent := bodyStart, "    "
+	for i, m := range memberLine.FindAllStringSubmatchIndex(src[bodyStart:bodyEnd], -1) {
+		line := src[bodyStart+m[0] : bodyStart+m[1]]
+		if i == 0 {
+			indent = src[bodyStart+m[2] : bodyStart+m[3]]
+		}
+		var n int32
+		fmt.Sscan(src[bodyStart+m[4]:bodyStart+m[5]], &n)
+		if n < number {
+			at = bodyStart + m[0] + len(line)
+		}
+	}
+	member := fmt.Sprintf("%s%s %s = %d;\n", indent, name, field, number)
+	src = src[:at] + member + src[at:]
+
+	// Before the wrapper and the comment on it
+	declAt := wrapperStart[0]
+	for declAt > 0 {
+		prev := strings.LastIndex(src[:declAt-1], "\n") + 1
+		if !strings.HasPrefix(strings.TrimSpace(src[prev:declAt]), "//") {
+			break
+		}
+		declAt = prev
+	}
+	return src[:declAt] + "message " + name + " {}\n\n" + src[declAt:], nil
+}
+
+// closingBrace returns the index of the brace closing the block starting at start, skipping
+// comments and strings, or -1
+func closingBrace(src string, start int) int {
+	depth := 1
+	for i := start; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '/' && strings.HasPrefix(src[i:], "//"):
+			if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				return -1
+			}
+		case c == '/' && strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i:], "*/")
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case c == '"' || c == '\'':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// snakeCase converts a message name to its field name (e.g., ChatReact to chat_react, HTTPReq to http_req)
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+
+	addCmd.Flags().StringVar(&addRange, "range", "", "Range of socketgen.yaml to number the payload in (e.g. chat)")
+	addCmd.Flags().StringVar(&addField, "field", "", "Field name of the payload in the oneof (default: the message name in snake_case)")
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/generator"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditSecurity bool
+	auditDir      string
+	auditFix      bool
+)
+
+// Statuses of an audit check
+const (
+	auditOK      = "ok"
+	auditPartial = "partial"
+	auditMissing = "missing"
+)
+
+// auditCheck is the state of one protection
+type auditCheck struct {
+	Name   string
+	Status string
+	Detail string
+	Fix    string // What to do about it, if it is not ok
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit --security",
+	Short: "Report which protections the schema and configuration enable",
+	Long: `With --security, reports which protections of the generated Go server are enabled for
+packet.proto, socketgen.yaml and the Go transports generated in --dir: packet size limits, rate
+limits, authentication gating and origin checks. Exits with status 1 if any is missing or
+partial, for CI.
+
+With --fix, adds a security section with hardened defaults to socketgen.yaml when it has none;
+'socketgen gen' then generates a Guard that every transport runs before dispatch.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !auditSecurity {
+			fmt.Println("Error: choose what to audit: --security")
+			os.Exit(1)
+		}
+
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+		transports, guarded, err := generatedSecurity(auditDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		checks := securityChecks(result, cfg.Security, transports, guarded)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROTECTION\tSTATUS\tDETAIL")
+		failed := 0
+		for _, c := range checks {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, c.Status, c.Detail)
+			if c.Status != auditOK {
+				failed++
+			}
+		}
+		w.Flush()
+
+		if failed == 0 {
+			return
+		}
+		fmt.Println()
+		for _, c := range checks {
+			if c.Status != auditOK {
+				fmt.Printf("%s: %s\n", c.Name, c.Fix)
+			}
+		}
+
+		if !auditFix {
+			os.Exit(1)
+		}
+		if cfg.Security != nil {
+			fmt.Printf("\n%s already has a security section, so it was left unchanged; apply the fixes above to it.\n", configFile)
+			os.Exit(1)
+		}
+		if err := appendHardenedSecurity(configFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nAdded a security section with hardened defaults to %s. Run 'socketgen gen' to generate the guard.\n", configFile)
+		if !slices.ContainsFunc(result.Payloads, func(p parser.PayloadMessage) bool { return p.AuthExempt }) {
+			fmt.Println("Every payload now needs an authenticated session: mark the payloads clients send before logging in with option (socketgen.requires_auth) = false.")
+		}
+	},
+}
+
+// generatedSecurity returns the Go transports generated in dir, and whether the guard is generated there
+func generatedSecurity(dir string) ([]string, bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "packet_transport_*.go"))
+	if err != nil {
+		return nil, false, err
+	}
+	var transports []string
+	for _, f := range files {
+		transports = append(transports, strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), "packet_transport_"), ".go"))
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "packet_security.go"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+	return transports, err == nil, nil
+}
+
+// securityChecks evaluates every protection for the schema, the security section (nil if
+// absent), the generated transports, and whether the guard is generated
+func securityChecks(result *parser.ParseResult, security *config.Security, transports []string, guarded bool) []auditCheck {
+	stale := security != nil && !guarded
+	var clientPayloads []parser.PayloadMessage
+	for _, p := range result.Payloads {
+		if p.Direction != parser.DirectionServerToClient {
+			clientPayloads = append(clientPayloads, p)
+		}
+	}
+
+	return []auditCheck{
+		sizeCheck(security, transports).stale(stale),
+		rateCheck(security, clientPayloads).stale(stale),
+		authCheck(security, clientPayloads).stale(stale),
+		originCheck(security, transports).stale(stale),
+	}
+}
+
+// stale marks an ok check partial when the guard it relies on has not been generated yet
+func (c auditCheck) stale(stale bool) auditCheck {
+	if stale && c.Status == auditOK {
+		c.Status, c.Fix = auditPartial, "run 'socketgen gen' to generate the guard enforcing it"
+	}
+	return c
+}
+
+func sizeCheck(security *config.Security, transports []string) auditCheck {
+	c := auditCheck{Name: "size limits"}
+	if security != nil {
+		c.Status, c.Detail = auditOK, fmt.Sprintf("packets are limited to %d bytes on every transport", security.MaxPacketSize)
+		return c
+	}
+
+	var unbounded []string
+	for _, t := range transports {
+		if t == "ws" || t == "socketio" {
+			unbounded = append(unbounded, t)
+		}
+	}
+	if len(unbounded) > 0 {
+		c.Status, c.Detail = auditMissing, transportsThat(unbounded, "accepts", "accept")+" messages of any size"
+	} else {
+		c.Status, c.Detail = auditPartial, fmt.Sprintf("packets are limited to %d bytes (MaxFrameSize) only", config.MaxFrameSize)
+	}
+	c.Fix = "set security.max_packet_size to the largest packet clients send"
+	return c
+}
+
+func rateCheck(security *config.Security, payloads []parser.PayloadMessage) auditCheck {
+	c := auditCheck{Name: "rate limits"}
+	var limited, unlimited []string
+	for _, p := range payloads {
+		if p.RateLimit > 0 || (security != nil && security.RateLimit > 0) {
+			limited = append(limited, p.FieldName)
+		} else {
+			unlimited = append(unlimited, p.FieldName)
+		}
+	}
+
+	switch {
+	case security == nil && len(limited) > 0:
+		c.Status, c.Detail = auditMissing, fmt.Sprintf("(socketgen.rate_limit) is set on %d payload(s) but not enforced without a security section", len(limited))
+		c.Fix = "add a security section to generate the guard enforcing it, with security.rate_limit for the other payloads"
+	case len(limited) == 0:
+		c.Status, c.Detail = auditMissing, "no client payload has a rate limit"
+		c.Fix = "set security.rate_limit, and option (socketgen.rate_limit) on payloads that need another rate"
+	case len(unlimited) > 0:
+		c.Status, c.Detail = auditPartial, fmt.Sprintf("%d of %d client payloads have no rate limit: %s", len(unlimited), len(payloads), strings.Join(unlimited, ", "))
+		c.Fix = "set security.rate_limit, or option (socketgen.rate_limit) on these payloads"
+	default:
+		c.Status, c.Detail = auditOK, fmt.Sprintf("all %d client payloads are rate limited", len(payloads))
+	}
+	return c
+}
+
+func authCheck(security *config.Security, payloads []parser.PayloadMessage) auditCheck {
+	c := auditCheck{Name: "auth gating"}
+	var gated, open []string
+	for _, p := range payloads {
+		auth := p.RequiresAuth
+		if security != nil {
+			auth, _ = generator.GuardRule(p, security)
+		}
+		if auth {
+			gated = append(gated, p.FieldName)
+		} else {
+			open = append(open, p.FieldName)
+		}
+	}
+
+	switch {
+	case security == nil && len(gated) > 0:
+		c.Status, c.Detail = auditMissing, fmt.Sprintf("(socketgen.requires_auth) is set on %d payload(s) but not enforced without a security section", len(gated))
+		c.Fix = "add a security section to generate the guard enforcing it"
+	case len(gated) == 0:
+		c.Status, c.Detail = auditMissing, "every client payload is accepted before login"
+		c.Fix = "set security.require_auth, and option (socketgen.requires_auth) = false on the login request"
+	case len(open) == 0:
+		c.Status, c.Detail = auditPartial, "every client payload needs an authenticated session, so clients cannot log in"
+		c.Fix = "set option (socketgen.requires_auth) = false on the login request"
+	case !security.RequireAuth:
+		c.Status, c.Detail = auditPartial, fmt.Sprintf("%d of %d client payloads are accepted before login: %s", len(open), len(payloads), strings.Join(open, ", "))
+		c.Fix = "set security.require_auth, keeping option (socketgen.requires_auth) = false on the login request"
+	default:
+		c.Status, c.Detail = auditOK, fmt.Sprintf("%d client payload(s) accepted before login: %s", len(open), strings.Join(open, ", "))
+	}
+	return c
+}
+
+func originCheck(security *config.Security, transports []string) auditCheck {
+	c := auditCheck{Name: "origin checks"}
+	if security != nil {
+		c.Status, c.Detail = auditOK, fmt.Sprintf("browsers may connect from the server's own origin and %d allowed origin(s)", len(security.AllowedOrigins))
+		return c
+	}
+
+	var open []string
+	for _, t := range transports {
+		if t == "socketio" || t == "sse" || t == "grpcweb" {
+			open = append(open, t)
+		}
+	}
+	if len(open) == 0 {
+		c.Status, c.Detail = auditOK, "no generated transport accepts browsers from other origins"
+		return c
+	}
+	c.Status, c.Detail = auditMissing, transportsThat(open, "accepts", "accept")+" browsers from any origin"
+	c.Fix = "add a security section, with security.allowed_origins for clients hosted elsewhere"
+	return c
+}
+
+// transportsThat starts a sentence about the transports names with the verb agreeing with them
+func transportsThat(names []string, singular, plural string) string {
+	if len(names) == 1 {
+		return fmt.Sprintf("the %s transport %s", names[0], singular)
+	}
+	return fmt.Sprintf("the %s and %s transports %s", strings.Join(names[:len(names)-1], ", "), names[len(names)-1], plural)
+}
+
+const hardenedSecurityYAML = `
+# Hardened defaults from socketgen audit --security --fix
+security:
+  max_packet_size: %d  # Largest packet a client may send, in bytes
+  rate_limit: %g  # Packets per second per session, for payloads without option (socketgen.rate_limit)
+  burst: %d
+  require_auth: %t  # Payloads with option (socketgen.requires_auth) = false are open before login
+  allowed_origins: []  # Origins of browser clients hosted elsewhere, e.g. https://play.example.com
+`
+
+// appendHardenedSecurity adds a security section with the defaults of config.HardenedSecurity to
+// the configuration file at path, creating it if needed
+func appendHardenedSecurity(path string) error {
+	s := config.HardenedSecurity()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	section := fmt.Sprintf(hardenedSecurityYAML, s.MaxPacketSize, s.RateLimit, s.Burst, s.RequireAuth)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		section = strings.TrimPrefix(section, "\n")
+	}
+	if _, err := f.WriteString(section); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().BoolVar(&auditSecurity, "security", false, "Report the protections of the generated Go server")
+	auditCmd.Flags().StringVar(&auditDir, "dir", "./gen", "Directory of the generated Go package")
+	auditCmd.Flags().BoolVar(&auditFix, "fix", false, "Add a security section with hardened defaults to socketgen.yaml")
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/scenario"
+	"github.com/spf13/cobra"
+)
+
+var (
+	botURL     string
+	botClients int
+	botRampUp  time.Duration
+)
+
+var botCmd = &cobra.Command{
+	Use:   "bot <scenario.yaml>",
+	Short: "Run a protocol scenario against a server with simulated clients",
+	Long: `Runs a YAML (or JSON) scenario of send, expect, sleep, and loop steps against a server, using
+packet.proto to encode and decode packets (no generated code needed). With --clients 1 it acts as a
+conformance check; with more clients it is a load test. Exits with status 1 if any client fails.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, err := parser.LoadSchema(schemaFile)
+		if err != nil {
+			fmt.Printf("Error loading %s: %v\n", schemaFile, err)
+			return
+		}
+
+		s, err := scenario.Load(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if err := s.Validate(schema); err != nil {
+			fmt.Printf("Error: invalid scenario %s: %v\n", s.Name, err)
+			return
+		}
+
+		fmt.Printf("Running %q with %d client(s) against %s\n", s.Name, botClients, botURL)
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			failed    int
+			sent      int
+			received  int
+			latencies = map[string][]time.Duration{}
+		)
+		start := time.Now()
+		for i := 0; i < botClients; i++ {
+			if i > 0 && botRampUp > 0 {
+				time.Sleep(botRampUp / time.Duration(botClients))
+			}
+
+			wg.Add(1)
+			go func(client int) {
+				defer wg.Done()
+				result, err := runBot(schema, s, client)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed++
+					fmt.Printf("client %d: FAIL %v\n", client, err)
+				}
+				if result != nil {
+					sent += result.Sent
+					received += result.Received
+					for _, l := range result.Latencies {
+						latencies[l.Payload] = append(latencies[l.Payload], l.Duration)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		fmt.Printf("\n%d/%d client(s) passed in %s (%d sent, %d received)\n",
+			botClients-failed, botClients, time.Since(start).Round(time.Millisecond), sent, received)
+		printLatencies(latencies)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func runBot(schema *parser.Schema, s *scenario.Scenario, client int) (*scenario.Result, error) {
+	conn, err := scenario.Dial(botURL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	runner := &scenario.Runner{Schema: schema, Conn: conn, Client: client}
+	return runner.Run(context.Background(), s)
+}
+
+// printLatencies prints the response time distribution of every expected payload
+func printLatencies(latencies map[string][]time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	payloads := make([]string, 0, len(latencies))
+	for payload := range latencies {
+		payloads = append(payloads, payload)
+	}
+	slices.Sort(payloads)
+
+	fmt.Printf("\n%-24s %8s %10s %10s %10s\n", "EXPECT", "COUNT", "P50", "P95", "MAX")
+	for _, payload := range payloads {
+		d := latencies[payload]
+		slices.Sort(d)
+		fmt.Printf("%-24s %8d %10s %10s %10s\n", payload, len(d),
+			percentile(d, 0.50), percentile(d, 0.95), d[len(d)-1].Round(time.Microsecond))
+	}
+}
+
+// percentile returns the p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(float64(len(sorted)-1)*p)].Round(time.Microsecond)
+}
+
+func init() {
+	rootCmd.AddCommand(botCmd)
+
+	botCmd.Flags().StringVar(&botURL, "url", "ws://localhost:8080/ws", "WebSocket URL of the server")
+	botCmd.Flags().IntVar(&botClients, "clients", 1, "Number of simulated clients running the scenario concurrently")
+	botCmd.Flags().DurationVar(&botRampUp, "ramp-up", 0, "Spread client connections over this duration")
+}
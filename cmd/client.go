@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/snowmerak/socketgen/devclient"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var clientURL string
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Connect to a server and send packets interactively",
+	Long: `Opens a WebSocket connection and starts a REPL where payloads are composed as JSON and converted
+to GamePackets using packet.proto. Every packet received from the server is pretty-printed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, err := parser.LoadSchema(schemaFile)
+		if err != nil {
+			fmt.Printf("Error loading %s: %v\n", schemaFile, err)
+			return
+		}
+
+		client, err := devclient.Dial(clientURL, schema)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		defer client.Close()
+
+		fmt.Printf("Connected to %s\n", clientURL)
+		go func() {
+			if err := client.Receive(); err != nil {
+				fmt.Printf("\nConnection closed: %v\n", err)
+				os.Exit(0)
+			}
+		}()
+
+		if err := client.RunREPL(os.Stdin); err != nil {
+			fmt.Printf("Error reading input: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(clientCmd)
+
+	clientCmd.Flags().StringVar(&clientURL, "url", "ws://localhost:8080/ws", "WebSocket URL of the server")
+}
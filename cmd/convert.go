@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/recording"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	convertFixtures string
+	convertScenario string
+	convertPeer     string
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert <recording.jsonl|recording.sgr>",
+	Short: "Convert a recorded session into fixtures and bot scenarios",
+	Long: `Converts a recording made with 'socketgen serve --record' into a fixtures file for the dev server
+and/or a scenario for 'socketgen bot', so real sessions become regression tests.
+
+Fixtures map every payload a client sent to the server's answer the first time it was seen.
+The scenario replays one peer's session (the first recorded peer unless --peer is given),
+expecting the recorded responses. Replay files (.sgr) are converted the same way.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if convertFixtures == "" && convertScenario == "" {
+			fmt.Println("Error: nothing to do, pass --fixtures and/or --scenario")
+			return
+		}
+
+		schema, err := parser.LoadSchema(schemaFile)
+		if err != nil {
+			fmt.Printf("Error loading %s: %v\n", schemaFile, err)
+			return
+		}
+
+		entries, err := recording.Load(args[0], schema)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if convertFixtures != "" {
+			fixtures, err := recording.Fixtures(schema, entries)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			data, err := json.MarshalIndent(fixtures, "", "  ")
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := os.WriteFile(convertFixtures, append(data, '\n'), 0644); err != nil {
+				fmt.Printf("Error writing fixtures: %v\n", err)
+				return
+			}
+			fmt.Printf("Wrote fixtures for %d payloads to %s\n", len(fixtures), convertFixtures)
+		}
+
+		if convertScenario != "" {
+			peer := convertPeer
+			if peer == "" {
+				peers := recording.Peers(entries)
+				if len(peers) == 0 {
+					fmt.Println("Error: the recording is empty")
+					return
+				}
+				peer = peers[0]
+			}
+
+			s, err := recording.Scenario(schema, entries, peer)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			var data bytes.Buffer
+			enc := yaml.NewEncoder(&data)
+			enc.SetIndent(2)
+			if err := enc.Encode(s); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := os.WriteFile(convertScenario, data.Bytes(), 0644); err != nil {
+				fmt.Printf("Error writing scenario: %v\n", err)
+				return
+			}
+			fmt.Printf("Wrote scenario with %d steps for %s to %s\n", len(s.Steps), peer, convertScenario)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringVar(&convertFixtures, "fixtures", "", "Write a dev server fixtures file")
+	convertCmd.Flags().StringVar(&convertScenario, "scenario", "", "Write a bot scenario file")
+	convertCmd.Flags().StringVar(&convertPeer, "peer", "", "Peer address whose session becomes the scenario (default: first recorded peer)")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/snowmerak/socketgen/coverage"
+	"github.com/snowmerak/socketgen/lock"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var coverageMin float64
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage <report.json>...",
+	Short: "Report which payload handlers tests never exercised",
+	Long: `Merges handler coverage reports written by code generated with 'socketgen gen --coverage'
+(e.g. from the Go and TypeScript test suites) and prints how often each payload handler in
+packet.proto ran. Exits with status 1 if coverage is below --min.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			return
+		}
+
+		// Reports recorded before a payload was renamed or superseded count for its successor
+		history, err := lock.Load(lock.FileName)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		var reports []*coverage.Report
+		for _, path := range args {
+			r, err := coverage.Load(path)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if r.SchemaVersion != result.SchemaVersion {
+				fmt.Printf("Warning: %s was recorded with schema %s, %s is %s\n", path, r.SchemaVersion, schemaFile, result.SchemaVersion)
+			}
+			if history != nil {
+				r.Payloads = coverage.Rename(r.Payloads, history.Current)
+			}
+			reports = append(reports, r)
+		}
+
+		lines, stale := coverage.Merge(result, reports)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PAYLOAD\tRUNS\tSTATUS")
+		covered := 0
+		for _, l := range lines {
+			status := "ok"
+			if l.Runs == 0 {
+				status = "NOT COVERED"
+			} else {
+				covered++
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", l.Payload, l.Runs, status)
+		}
+		w.Flush()
+
+		slices.Sort(stale)
+		for _, payload := range stale {
+			fmt.Printf("Warning: %s is in a report but not in %s\n", payload, schemaFile)
+		}
+
+		percent := 100.0
+		if len(lines) > 0 {
+			percent = 100 * float64(covered) / float64(len(lines))
+		}
+		fmt.Printf("\nPayload coverage: %d/%d (%.1f%%)\n", covered, len(lines), percent)
+
+		if percent < coverageMin {
+			fmt.Printf("Coverage is below the minimum of %.1f%%\n", coverageMin)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(coverageCmd)
+
+	coverageCmd.Flags().Float64Var(&coverageMin, "min", 0, "Minimum payload coverage in percent")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/snowmerak/socketgen/coverage"
+	"github.com/snowmerak/socketgen/lock"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/recording"
+	"github.com/spf13/cobra"
+)
+
+var (
+	deadRecordings []string
+	deadCoverage   []string
+)
+
+var deadCmd = &cobra.Command{
+	Use:   "dead --recording <file>... --coverage <report.json>...",
+	Short: "Report payloads never sent or handled",
+	Long: `Cross-references packet.proto with recorded traffic (JSON Lines or replay recordings, e.g.
+of 'socketgen serve --record') and handler coverage reports ('socketgen gen --coverage'), and
+flags the payloads no recording carries and no handler ran for. Such payloads are candidates for
+removal from the schema. Exits with status 1 if any payload is dead.
+
+Evidence only counts for the period it covers: record production traffic long enough for rare
+payloads (season events, admin commands) to show up.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(deadRecordings) == 0 && len(deadCoverage) == 0 {
+			fmt.Println("Error: give the evidence to check: --recording, --coverage or both")
+			os.Exit(1)
+		}
+
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+
+		var entries []recording.Entry
+		for _, path := range deadRecordings {
+			e, err := recording.Load(path, result.Schema)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			entries = append(entries, e...)
+		}
+
+		// Reports recorded before a payload was renamed or superseded count for its successor
+		history, err := lock.Load(lock.FileName)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		var reports []*coverage.Report
+		for _, path := range deadCoverage {
+			r, err := coverage.Load(path)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if r.SchemaVersion != result.SchemaVersion {
+				fmt.Printf("Warning: %s was recorded with schema %s, %s is %s\n", path, r.SchemaVersion, schemaFile, result.SchemaVersion)
+			}
+			if history != nil {
+				r.Payloads = coverage.Rename(r.Payloads, history.Current)
+			}
+			reports = append(reports, r)
+		}
+
+		usages, stale := coverage.Usages(result, entries, reports)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PAYLOAD\tRECEIVED\tSENT\tHANDLED\tSTATUS")
+		dead := 0
+		for i, u := range usages {
+			unsent := len(deadRecordings) > 0 && u.Received+u.Sent == 0
+			unhandled := len(deadCoverage) > 0 && u.Handled == 0
+			status := "ok"
+			switch {
+			case (unsent || len(deadRecordings) == 0) && (unhandled || len(deadCoverage) == 0):
+				status = "DEAD"
+				if next := result.Payloads[i].SupersededBy; next != "" {
+					status += " (superseded by " + next + ")"
+				}
+				dead++
+			case unsent:
+				status = "never sent"
+			case unhandled:
+				status = "never handled"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", u.Payload, deadCount(u.Received, deadRecordings), deadCount(u.Sent, deadRecordings), deadCount(u.Handled, deadCoverage), status)
+		}
+		w.Flush()
+
+		slices.Sort(stale)
+		for _, payload := range stale {
+			fmt.Printf("Warning: %s is in a report but not in %s\n", payload, schemaFile)
+		}
+
+		fmt.Printf("\n%d of %d payloads dead, from %d recorded packets and %d coverage reports.\n", dead, len(usages), len(entries), len(reports))
+		if dead > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// deadCount prints n, or "-" when there is no evidence (no files) to count it from
+func deadCount(n int, files []string) string {
+	if len(files) == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+func init() {
+	rootCmd.AddCommand(deadCmd)
+
+	deadCmd.Flags().StringSliceVar(&deadRecordings, "recording", nil, "Recordings of traffic, JSON Lines or replay (.sgr)")
+	deadCmd.Flags().StringSliceVar(&deadCoverage, "coverage", nil, "Handler coverage reports written by code generated with --coverage")
+}
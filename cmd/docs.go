@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/snowmerak/socketgen/generator"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var docsOut string
+
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Print a Markdown reference of the protocol",
+	Long: `Renders the payloads of packet.proto, their fields, socketgen options and proto comments
+as Markdown, so protocol documentation lives in the proto file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			return
+		}
+
+		var w io.Writer = os.Stdout
+		if docsOut != "" {
+			f, err := os.Create(docsOut)
+			if err != nil {
+				fmt.Printf("Error creating %s: %v\n", docsOut, err)
+				return
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if err := generator.GenerateDocs(result, w); err != nil {
+			fmt.Printf("Error generating docs: %v\n", err)
+			return
+		}
+		if docsOut != "" {
+			fmt.Printf("Wrote %s\n", docsOut)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+
+	docsCmd.Flags().StringVarP(&docsOut, "out", "o", "", "File to write the reference to (default stdout)")
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/socketgen/fuzz"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fuzzDir     string
+	fuzzTime    time.Duration
+	fuzzTargets []string
+	fuzzVerbose bool
+)
+
+var fuzzCmd = &cobra.Command{
+	Use:   "fuzz",
+	Short: "Run the generated fuzz tests within a time budget",
+	Long: `Runs the fuzz tests generated with 'socketgen gen --lang go --fuzz' one after another, sharing
+--time between them. They feed random and mutated packets to Dispatch, and frames to the frame
+decoder of the stream transports, and fail on a panic or on an input allocating far more memory
+than its size. Exits with status 1 if any target found a failing input; 'go test' saves it
+under testdata/fuzz, where plain 'go test' keeps running it as a regression test.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		targets, err := fuzz.Targets(fuzzDir)
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("No fuzz tests in %s. Generate them with 'socketgen gen --lang go --fuzz'.\n", fuzzDir)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(fuzzTargets) > 0 {
+			for _, name := range fuzzTargets {
+				if !slices.Contains(targets, name) {
+					fmt.Printf("Error: no fuzz test %s in %s (available: %s)\n", name, fuzzDir, strings.Join(targets, ", "))
+					os.Exit(1)
+				}
+			}
+			targets = fuzzTargets
+		}
+
+		budget := (fuzzTime / time.Duration(len(targets))).Truncate(time.Second)
+		if budget < time.Second {
+			fmt.Printf("Error: --time %s leaves less than a second for each of %d fuzz tests\n", fuzzTime, len(targets))
+			os.Exit(1)
+		}
+
+		var log io.Writer = io.Discard
+		if fuzzVerbose {
+			log = os.Stdout
+		}
+
+		var failed []fuzz.Result
+		for _, target := range targets {
+			fmt.Printf("Fuzzing %s for %s...\n", target, budget)
+			res, err := fuzz.Run(fuzzDir, target, budget, log)
+			if err != nil {
+				fmt.Printf("Error: %s: %v\n", target, err)
+				os.Exit(1)
+			}
+			if !res.OK {
+				failed = append(failed, res)
+				if !fuzzVerbose {
+					fmt.Println(res.Output)
+				}
+			}
+		}
+
+		if len(failed) > 0 {
+			fmt.Printf("\n%d of %d fuzz test(s) found a failing input:\n", len(failed), len(targets))
+			for _, res := range failed {
+				fmt.Printf("  %s: %s (re-run with 'go test -run %s/%s')\n", res.Name, res.Input, res.Name, fileBase(res.Input))
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("No failing input found in %d fuzz test(s).\n", len(targets))
+	},
+}
+
+// fileBase returns the last element of a slash-separated path
+func fileBase(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func init() {
+	rootCmd.AddCommand(fuzzCmd)
+
+	fuzzCmd.Flags().StringVar(&fuzzDir, "dir", "./gen", "Directory of the generated Go package")
+	fuzzCmd.Flags().DurationVar(&fuzzTime, "time", time.Minute, "Total time to fuzz, shared by the fuzz tests")
+	fuzzCmd.Flags().StringSliceVar(&fuzzTargets, "target", nil, "Fuzz only these tests (e.g. FuzzFrames)")
+	fuzzCmd.Flags().BoolVarP(&fuzzVerbose, "verbose", "v", false, "Show the output of go test")
+}
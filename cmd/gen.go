@@ -1,93 +1,748 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/snowmerak/socketgen/config"
 	"github.com/snowmerak/socketgen/generator"
+	"github.com/snowmerak/socketgen/lock"
+	"github.com/snowmerak/socketgen/options"
 	"github.com/snowmerak/socketgen/parser"
 	"github.com/spf13/cobra"
 )
 
 var (
-	languages  []string
-	outDir     string
-	withProtoc bool
+	languages    []string
+	outDir       string
+	withProtoc   bool
+	withVectors  bool
+	withSamples  bool
+	withFuzz     bool
+	withChaos    bool
+	withSim      bool
+	withCanary   bool
+	withDedup    bool
+	concurrency  bool
+	replayWindow bool
+	withCoverage bool
+	withPooled   bool
+	withSignalR  bool
+	withSplit    bool
+	withMetrics  bool
+	previous     string
+	withLock     bool
+	zeroAlloc    bool
+	transports   []string
+	frameCRC     bool
+	gateway      []string
+	internal     string
+	configFile   string
+	jobs         int
+	genJSON      bool
+	failFast     bool
+	keepGoing    bool
+	genPlan      bool
+	genClean     bool
+	genForce     bool
+	genOnly      []string
+	genSkip      []string
+	genProfile   string
+	genSizes     bool
+	genGrowth    int
+	genIndexJSON bool
+	genWatch     bool
+	genDebounce  time.Duration
+	genEnv       string
+	genOutputs   map[string]string // Output directories of languages, from the configuration
 )
 
 var genCmd = &cobra.Command{
 	Use:   "gen",
 	Short: "Generate code for selected languages",
-	Long:  `Generates Dispatcher and Handler code based on packet.proto for the specified languages.`,
+	Long:  `Generates Dispatcher and Handler code based on packet.proto, or the proto file given with --proto, for the specified languages.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// A profile or the gen section of the configuration may set the languages instead
+		if genProfile == "" && !cmd.Flags().Changed("lang") && !configSetsLanguages() {
+			return fmt.Errorf(`required flag(s) "lang" not set`)
+		}
+		return checkArtifacts()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Generating code for languages: %v\n", languages)
-		fmt.Printf("Output directory: %s\n", outDir)
-
-		// Run protoc if requested
-		if withProtoc {
-			fmt.Println("Running protoc...")
-			if err := generator.GenerateProtoc("packet.proto", languages, outDir); err != nil {
-				fmt.Printf("Warning: Failed to run protoc: %v\n", err)
-				fmt.Println("Make sure you have 'protoc' and necessary plugins installed.")
-			} else {
-				fmt.Println("Successfully generated protobuf bindings.")
-			}
+		if genJSON {
+			// Progress goes to stderr, so stdout holds nothing but the report
+			genLog = os.Stderr
 		}
-
-		// Parse packet.proto
-		result, err := parser.Parse("packet.proto")
-		if err != nil {
-			fmt.Printf("Error parsing packet.proto: %v\n", err)
+		if genWatch {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			watchGen(ctx, cmd)
 			return
 		}
+		report, _ := generate(cmd)
+		report.finish()
+	},
+}
+
+// generate runs gen once with the flags of cmd, completed from the configuration, and returns its
+// report and the parsed schema (nil if it failed to parse)
+func generate(cmd *cobra.Command) (*genReport, *parser.ParseResult) {
+	report := &genReport{OutDir: outDir, Payloads: []genPayload{}, Targets: []genTarget{}, Files: []string{}, Warnings: []string{}, Errors: []string{}}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		report.fail(exitConfig, "%v", err)
+		return report, nil
+	}
+	// --out on the command line puts every language there
+	if !cmd.Flags().Changed("out") {
+		genOutputs = cfg.Outputs
+	}
+	if genProfile != "" {
+		if err := applyProfile(cmd, cfg, genProfile); err != nil {
+			report.fail(exitConfig, "%v", err)
+			return report, nil
+		}
+		fmt.Fprintf(genLog, "Using profile: %s\n", genProfile)
+	}
+	// The gen section sets what neither the command line nor the profile does
+	if err := applyFlags(cmd, cfg.Gen, "gen"); err != nil {
+		report.fail(exitConfig, "%v", err)
+		return report, nil
+	}
+	if !cmd.Flags().Changed("lang") {
+		report.fail(exitConfig, "profile %q sets no languages; set lang in it or in the gen section of %s, or pass --lang", genProfile, configFile)
+		return report, nil
+	}
+	if err := checkArtifacts(); err != nil {
+		report.fail(exitConfig, "%s: %v", configFile, err)
+		return report, nil
+	}
+	report.OutDir = outDir
 
-		fmt.Printf("Found package: %s\n", result.PackageName)
-		fmt.Println("Detected payloads:")
-		for _, p := range result.Payloads {
-			fmt.Printf(" - %s (Field: %s, Type: %s)\n", p.Name, p.FieldName, p.FullName)
-		}
-
-		for _, lang := range languages {
-			var err error
-			switch lang {
-			case "go":
-				fmt.Println("Generating Go code...")
-				err = generator.GenerateGo(result, outDir)
-			case "ts":
-				fmt.Println("Generating TypeScript code...")
-				err = generator.GenerateTS(result, outDir)
-			case "python":
-				fmt.Println("Generating Python code...")
-				err = generator.GeneratePython(result, outDir)
-			case "csharp":
-				fmt.Println("Generating C# code...")
-				err = generator.GenerateCSharp(result, outDir)
-			case "dart":
-				fmt.Println("Generating Dart code...")
-				err = generator.GenerateDart(result, outDir)
-			case "php":
-				fmt.Println("Generating PHP code...")
-				err = generator.GeneratePHP(result, outDir)
-			case "ruby":
-				fmt.Println("Generating Ruby code...")
-				err = generator.GenerateRuby(result, outDir)
-			case "kotlin":
-				fmt.Println("Generating Kotlin code...")
-				err = generator.GenerateKotlin(result, outDir)
-			case "java":
-				fmt.Println("Generating Java code...")
-				err = generator.GenerateJava(result, outDir)
-			default:
-				fmt.Printf("Warning: Language '%s' is not supported yet.\n", lang)
-				continue
+	fmt.Fprintf(genLog, "Generating code for languages: %v\n", languages)
+	fmt.Fprintf(genLog, "Output directory: %s\n", outDir)
+	for _, lang := range slices.Sorted(maps.Keys(genOutputs)) {
+		if languageGenerators[lang] == nil {
+			report.warn("outputs.%s in the configuration is not a language socketgen generates", lang)
+		} else if slices.Contains(languages, lang) {
+			fmt.Fprintf(genLog, "Output directory of %s: %s\n", lang, genOutputs[lang])
+		}
+	}
+
+	// Schemas importing socketgen/options.proto compile against the options of this socketgen version
+	vendorOptions(schemaFile, report)
+	if cfg.Framing != nil && len(transports) == 0 {
+		report.warn("framing in the configuration only applies to --transports")
+	}
+	if cfg.Metrics != nil && !withMetrics {
+		report.warn("metrics in the configuration only applies to --metrics")
+	}
+	if genEnv != "" {
+		if cfg.Endpoints == nil {
+			report.warn("--env only applies to the endpoints of the configuration, which defines none")
+		} else if cfg.Endpoints.Environment(genEnv) == nil {
+			report.fail(exitConfig, "--env: environment %q is not defined in %s; defined environments: %s", genEnv, configFile, strings.Join(cfg.Endpoints.Names(), ", "))
+			return report, nil
+		}
+	}
+
+	for lang := range cfg.Plugins {
+		if !generator.NeedsPlugin(lang) {
+			report.warn("plugins.%s in the configuration is ignored; protoc generates the %s bindings itself", lang, lang)
+		}
+	}
+
+	// Everything is generated into a staging directory first, one subdirectory per target, and
+	// copied to the output directory once planned
+	stage, err := os.MkdirTemp("", "socketgen-gen-")
+	if err != nil {
+		report.fail(exitGenerate, "Failed to create staging directory: %v", err)
+		return report, nil
+	}
+	defer os.RemoveAll(stage)
+	var staged []string // Targets to copy to the output directory
+
+	// Run protoc if requested
+	if withProtoc && generates("bindings") {
+		fmt.Fprintln(genLog, "Running protoc...")
+		protoFiles := []string{schemaFile}
+		for _, schema := range cfg.Schemas {
+			protoFiles = append(protoFiles, schema.File)
+		}
+		var protocErr error
+		targets, langs := protocTargets(languages)
+		for _, target := range targets {
+			staged = append(staged, target)
+			for _, protoFile := range protoFiles {
+				protocErr = errors.Join(protocErr, generator.GenerateProtoc(protoFile, parser.ImportPaths, langs[target], filepath.Join(stage, target), cfg.Plugins, genLog))
 			}
+		}
+		if err := protocErr; err != nil {
+			report.fail(exitProtoc, "Failed to run protoc: %v", err)
+			// Code generated anyway would be built against stale bindings
+			if failFast {
+				return report, nil
+			}
+		} else {
+			fmt.Fprintln(genLog, "Successfully generated protobuf bindings.")
+		}
+	}
+
+	// Parse packet.proto
+	result, err := parser.Parse(schemaFile)
+	if err != nil {
+		report.fail(exitParse, "Failed to parse %s: %v", schemaFile, err)
+		return report, nil
+	}
+	report.Package, report.SchemaVersion = result.PackageName, result.SchemaVersion
+
+	if result.HasValidation() && slices.ContainsFunc(languages, func(lang string) bool { return lang == "go" || lang == "ts" || lang == "java" }) {
+		for _, warning := range generator.ValidationWarnings(result) {
+			report.warn("%s", warning)
+		}
+	}
+
+	for _, warning := range lintSchema(result, cfg) {
+		report.warn("%s", warning)
+	}
+
+	if written, err := updateLock(result, filepath.Join(stage, "lock")); err != nil {
+		report.warn("Failed to update %s: %v", lock.FileName, err)
+	} else if written {
+		staged = append(staged, "lock")
+	}
+
+	fmt.Fprintf(genLog, "Found package: %s\n", result.PackageName)
+	fmt.Fprintln(genLog, "Detected payloads:")
+	for _, p := range result.Payloads {
+		fmt.Fprintf(genLog, " - %s (Field: %s, Type: %s)\n", p.Name, p.FieldName, p.FullName)
+		report.Payloads = append(report.Payloads, genPayload{p.Name, p.FieldName, p.FullName, p.Number})
+	}
+
+	if withMetrics && slices.Contains(languages, "go") {
+		for _, warning := range generator.MetricLabelWarnings(result, cfg.Metrics) {
+			report.warn("%s", warning)
+		}
+	}
+
+	tasks := slices.DeleteFunc(slices.Clone(languages), func(lang string) bool {
+		if languageGenerators[lang] == nil {
+			report.warn("Language '%s' is not supported yet.", lang)
+			return true
+		}
+		return false
+	})
+	if withVectors && generates("tests") {
+		tasks = append(tasks, "vectors")
+	}
+
+	report.Targets = runTasks(tasks, func(task string) ([]string, error) {
+		dir := filepath.Join(stage, task)
+		if task == "vectors" {
+			return nil, generator.GenerateVectors(result, dir)
+		}
+		return generateLanguage(result, cfg, task, dir)
+	})
+
+	// Failed targets may be incomplete, so they are not copied
+	var failed, skipped []string
+	for _, t := range report.Targets {
+		switch {
+		case t.Skipped:
+			skipped = append(skipped, t.Name)
+		case !t.OK:
+			failed = append(failed, t.Name)
+		default:
+			staged = append(staged, t.Name)
+		}
+	}
+
+	if genIndexJSON {
+		if err := writeIndex(result, stage, report, filepath.Join(stage, "index")); err != nil {
+			report.warn("Failed to write %s: %v", indexFileName, err)
+		} else {
+			staged = append(staged, "index")
+		}
+	}
 
-			if err != nil {
-				fmt.Printf("Error generating %s code: %v\n", lang, err)
-			} else {
-				fmt.Printf("Successfully generated %s code.\n", lang)
+	if err := measureTargets(stage, report); err != nil {
+		report.warn("Failed to measure the generated code: %v", err)
+	}
+	if err := applyStage(stage, staged, report); err != nil {
+		report.fail(exitGenerate, "Failed to write %s: %v", outDir, err)
+	}
+	if genSizes {
+		if !genPlan {
+			timeCompiles(cfg, report)
+		}
+		printSizes(report)
+	}
+	if len(failed) > 0 {
+		report.fail(exitGenerate, "Failed to generate code for %s", strings.Join(failed, ", "))
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(genLog, "Skipped %s after the first failure (--fail-fast).\n", strings.Join(skipped, ", "))
+	}
+	return report, result
+}
+
+// genArtifacts are the kinds of files gen generates, which --only and --skip select from
+var genArtifacts = []string{
+	"bindings",   // protoc's, with --protoc
+	"dispatcher", // Dispatcher and handlers, and what extends them for every side
+	"server",     // Server transports, gateway, security guard and middleware
+	"client",     // Client transports and endpoint configuration
+	"tests",      // Golden vectors, vector tests, fuzz tests, chaos stream, sample builders and coverage instrumentation
+}
+
+func checkArtifacts() error {
+	for _, artifact := range slices.Concat(genOnly, genSkip) {
+		if !slices.Contains(genArtifacts, artifact) {
+			return fmt.Errorf("unknown artifact %q; expected one of %s", artifact, strings.Join(genArtifacts, ", "))
+		}
+	}
+	return nil
+}
+
+// generates reports whether --only and --skip select artifact
+func generates(artifact string) bool {
+	if len(genOnly) > 0 && !slices.Contains(genOnly, artifact) {
+		return false
+	}
+	return !slices.Contains(genSkip, artifact)
+}
+
+// partial reports whether --only or --skip leave out some artifacts, so the files of earlier runs
+// that were not generated again are not orphans
+func partial() bool {
+	return slices.ContainsFunc(genArtifacts, func(artifact string) bool { return !generates(artifact) })
+}
+
+// languageGenerators generate the dispatcher and handlers of each language
+var languageGenerators = map[string]func(*parser.ParseResult, string) error{
+	"go":     generator.GenerateGo,
+	"ts":     generator.GenerateTS,
+	"python": generator.GeneratePython,
+	"csharp": generator.GenerateCSharp,
+	"dart":   generator.GenerateDart,
+	"php":    generator.GeneratePHP,
+	"ruby":   generator.GenerateRuby,
+	"kotlin": generator.GenerateKotlin,
+	"java":   generator.GenerateJava,
+}
+
+// runTasks runs task for each of tasks, --jobs at a time, printing the progress and the time each
+// took as they finish, and the errors of failed tasks at the end. With --fail-fast, tasks not yet
+// started when one fails are skipped.
+func runTasks(tasks []string, task func(string) ([]string, error)) []genTarget {
+	start := time.Now()
+	queue := make(chan string, len(tasks))
+	for _, name := range tasks {
+		queue <- name
+	}
+	close(queue)
+
+	// Workers take the tasks in order, so --jobs 1 generates the languages in the order given
+	targets := make(chan genTarget)
+	var stop atomic.Bool
+	for range min(max(jobs, 1), len(tasks)) {
+		go func() {
+			for name := range queue {
+				if stop.Load() {
+					targets <- genTarget{Name: name, Skipped: true}
+					continue
+				}
+				taskStart := time.Now()
+				notes, err := task(name)
+				t := genTarget{Name: name, OK: err == nil, Elapsed: time.Since(taskStart), Notes: notes}
+				if err != nil {
+					t.Errors = strings.Split(err.Error(), "\n")
+					stop.Store(failFast)
+				}
+				targets <- t
 			}
+		}()
+	}
+
+	results := make([]genTarget, 0, len(tasks))
+	for i := range tasks {
+		t := <-targets
+		status := "done"
+		switch {
+		case t.Skipped:
+			status = "SKIPPED"
+		case !t.OK:
+			status = "FAILED"
 		}
-	},
+		progress := fmt.Sprintf("[%d/%d]", i+1, len(tasks))
+		fmt.Fprintf(genLog, "%-7s %-8s %-7s %s\n", progress, t.Name, status, t.Elapsed.Round(time.Millisecond))
+		for _, note := range t.Notes {
+			fmt.Fprintf(genLog, "%-7s %s\n", "", note)
+		}
+		results = append(results, t)
+	}
+
+	failed := false
+	for _, t := range results {
+		if t.OK || t.Skipped {
+			continue
+		}
+		failed = true
+		fmt.Fprintf(genLog, "\n%s failed:\n", t.Name)
+		for _, line := range t.Errors {
+			fmt.Fprintf(genLog, "  %s\n", line)
+		}
+	}
+	if !failed {
+		fmt.Fprintf(genLog, "Generated code for %d targets in %s.\n", len(tasks), time.Since(start).Round(time.Millisecond))
+	}
+	return results
+}
+
+// generateLanguage generates the code of lang along with the extras the flags and the
+// configuration select for it into dir, leaving out the artifacts --only and --skip do not select.
+// Every step runs even if an earlier one fails; the errors are joined.
+func generateLanguage(result *parser.ParseResult, cfg *config.Config, lang, dir string) ([]string, error) {
+	var notes []string
+	var errs []error
+	step := func(what string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", what, err))
+		}
+	}
+
+	if generates("dispatcher") {
+		step("dispatcher", languageGenerators[lang](result, dir))
+	}
+
+	if len(cfg.Session) > 0 && generates("dispatcher") && (lang == "go" || lang == "csharp" || lang == "ts") {
+		step("session accessors", generator.GenerateSession(result, cfg.Session, lang, dir))
+	}
+
+	if withPooled && generates("dispatcher") && (lang == "csharp" || lang == "java") {
+		step("pooled decoding", generator.GeneratePooled(result, lang, dir))
+	}
+
+	if withSplit && generates("dispatcher") && lang == "ts" {
+		step("split modules", generator.GenerateTSSplit(result, dir))
+	}
+
+	if withSignalR && generates("server") && lang == "csharp" {
+		step("SignalR adapter", generator.GenerateSignalR(result, dir))
+	}
+
+	// The C# and Java server scaffolds are the pooled readers and the SignalR hub
+	if (withPooled || (withSignalR && lang == "csharp")) && generates("server") && (lang == "csharp" || lang == "java") {
+		step("health endpoints", generator.GenerateHealth(result, lang, dir))
+	}
+
+	if cfg.Tenant != "" && generates("server") && lang == "go" {
+		step("tenant router", generator.GenerateTenant(result, cfg.Tenant, dir))
+	}
+
+	if zeroAlloc && generates("dispatcher") && lang == "go" {
+		step("zero-alloc dispatch", generator.GenerateZeroAlloc(result, dir))
+	}
+
+	if withMetrics && generates("server") && lang == "go" {
+		step("metrics", generator.GenerateMetrics(result, cfg.Metrics, dir))
+	}
+
+	// Handlers take time from the Clock of the harness, so it is part of the dispatcher
+	if withSim && generates("dispatcher") && lang == "go" {
+		step("simulation harness", generator.GenerateSim(result, dir))
+	}
+
+	if withCanary && generates("dispatcher") && lang == "go" {
+		step("canary handler", generator.GenerateCanary(result, dir))
+	}
+
+	if previous != "" && generates("dispatcher") && lang == "go" {
+		step("previous schema support", generator.GeneratePrevious(result, previous, dir))
+	}
+
+	if cfg.Security != nil && generates("server") && lang == "go" {
+		step("security guard", generator.GenerateSecurity(result, cfg.Security, dir))
+	}
+
+	if concurrency && generates("server") && lang == "go" {
+		step("concurrency limits", generator.GenerateConcurrency(result, dir))
+	}
+
+	if withDedup && generates("server") && lang == "go" {
+		step("dedup cache", generator.GenerateDedup(result, dir))
+	}
+
+	if replayWindow && generates("server") && lang == "go" {
+		step("replay window", generator.GenerateReplayWindow(result, dir))
+	}
+
+	if cfg.Middleware != nil && generates("server") && lang == "go" {
+		step("middleware presets", generator.GenerateMiddleware(result, cfg.Middleware, cfg.Security != nil, dir))
+	}
+
+	if (cfg.Security != nil || len(transports) > 0) && generates("server") && lang == "go" {
+		step("runtime config", generator.GenerateRuntimeConfig(result, dir))
+	}
+
+	if len(transports) > 0 && generates("server") && lang == "go" {
+		opts := generator.TransportOptions{Checksum: frameCRC, Framing: cfg.Framing, Previous: previous != "", Security: cfg.Security != nil, Middleware: cfg.Middleware != nil}
+		step("transports", generator.GenerateTransports(result, transports, opts, dir))
+	}
+
+	if slices.Contains(transports, "socketio") && generates("client") && lang == "ts" {
+		step("Socket.IO client", generator.GenerateSocketIOClient(result, dir))
+	}
+
+	if slices.Contains(transports, "mqtt") && generates("client") && lang == "ts" {
+		step("MQTT client", generator.GenerateMQTTClient(result, dir))
+	}
+
+	if slices.Contains(transports, "grpcweb") && generates("client") && lang == "ts" {
+		step("gRPC-Web client", generator.GenerateGRPCWebClient(result, dir))
+	}
+
+	if slices.Contains(transports, "sse") && generates("client") && lang == "ts" {
+		step("SSE client", generator.GenerateSSEClient(result, dir))
+	}
+
+	if cfg.Endpoints != nil && generates("client") {
+		step("endpoint configuration", generator.GenerateEndpoints(result, cfg.Endpoints, genEnv, lang, dir))
+	}
+
+	if len(gateway) > 0 && generates("server") && lang == "go" {
+		step("gateway", generator.GenerateGateway(result, gateway, dir))
+	}
+
+	if lang == "go" && generates("dispatcher") {
+		note, err := generateInternal(result, dir)
+		step("internal dispatcher", err)
+		if note != "" {
+			notes = append(notes, note)
+		}
+	}
+
+	if lang == "go" && generates("dispatcher") && len(cfg.Schemas) > 0 {
+		note, err := generateSchemas(result, cfg.Schemas, dir)
+		step("schema router", err)
+		if note != "" {
+			notes = append(notes, note)
+		}
+	}
+
+	if withCoverage && generates("tests") && (lang == "go" || lang == "ts") {
+		step("handler coverage", generator.GenerateCoverage(result, lang, dir))
+	}
+
+	if withSamples && generates("tests") && lang == "go" {
+		step("sample builders", generator.GenerateSamples(result, dir))
+	}
+
+	if withChaos && generates("tests") && lang == "go" {
+		step("chaos stream", generator.GenerateChaos(result, dir))
+	}
+
+	if withFuzz && generates("tests") && lang == "go" {
+		step("fuzz tests", generator.GenerateFuzz(result, len(transports) > 0, dir))
+	}
+
+	if withVectors && generates("tests") {
+		step("vector tests", generator.GenerateVectorTests(result, lang, dir))
+	}
+
+	return notes, errors.Join(errs...)
+}
+
+// generateInternal generates the server-to-server dispatcher from the InternalPacket envelope,
+// read from --internal or, if that is not set, from the --proto file when it defines one, into dir.
+// It returns a note on what it generated, if anything.
+func generateInternal(client *parser.ParseResult, dir string) (string, error) {
+	protoFile := internal
+	if protoFile == "" {
+		if client.Schema.Packet.ParentFile().Messages().ByName(parser.InternalWrapper) == nil {
+			return "", nil
+		}
+		protoFile = schemaFile
+	}
+
+	result, err := parser.ParseWrapper(protoFile, parser.InternalWrapper)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", protoFile, err)
+	}
+
+	// An envelope from another proto package gets its own Go package directory
+	var pkgDir string
+	if result.PackageName != client.PackageName {
+		pkgDir = result.PackageName
+	}
+
+	if err := generator.GenerateInternal(result, filepath.Join(dir, pkgDir)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Generated internal dispatcher for %d %s payloads in %s.", len(result.Payloads), parser.InternalWrapper, filepath.Join(outDir, pkgDir)), nil
+}
+
+// generateSchemas generates the dispatchers of the schemas of the project besides packet.proto,
+// and the router of all schemas, into dir. It returns a note on what it generated.
+func generateSchemas(client *parser.ParseResult, schemas config.Schemas, dir string) (string, error) {
+	var parsed []generator.ProjectSchema
+	payloads := len(client.Payloads)
+	for _, schema := range schemas {
+		result, err := parser.ParseWrapper(schema.File, schema.Wrapper())
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", schema.File, err)
+		}
+		parsed = append(parsed, generator.ProjectSchema{Name: schema.Name, Prefix: schema.Prefix(), File: schema.File, ParseResult: result})
+		payloads += len(result.Payloads)
+	}
+
+	if err := generator.GenerateSchemas(client, parsed, dir); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Generated router for %d payloads of %d schemas.", payloads, len(schemas)+1), nil
+}
+
+// updateLock records the payloads of result in socketgen.lock, when it exists or --lock is set,
+// and writes the mapping of old payload names to dir. It reports whether it wrote the mapping.
+// With --plan, the lockfile is left as it is.
+func updateLock(result *parser.ParseResult, dir string) (bool, error) {
+	f, err := lock.Load(lock.FileName)
+	created := errors.Is(err, os.ErrNotExist) && withLock
+	switch {
+	case created:
+		f = &lock.File{}
+	case errors.Is(err, os.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	changes := f.Update(result)
+	if !genPlan {
+		if err := f.Save(lock.FileName); err != nil {
+			return false, err
+		}
+	}
+	if created {
+		fmt.Fprintf(genLog, "Created '%s' with %d payloads.\n", lock.FileName, len(f.Payloads))
+	} else {
+		for _, change := range changes {
+			fmt.Fprintf(genLog, "%s: %s\n", lock.FileName, change)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+	return true, f.WriteMap(filepath.Join(dir, lock.MapFileName), result.SchemaVersion)
+}
+
+// unprofiledFlags are the gen flags a profile cannot set, as they select the profile or the way gen
+// runs rather than what it generates
+var unprofiledFlags = []string{"profile", "config", "help", "json", "plan", "clean", "force", "fail-fast", "keep-going", "sizes", "growth-warn", "watch", "debounce"}
+
+// applyProfile sets the flags of gen from the profile name of cfg, except the flags given on the
+// command line
+func applyProfile(cmd *cobra.Command, cfg *config.Config, name string) error {
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		if len(cfg.Profiles) == 0 {
+			return fmt.Errorf("profile %q is not defined; %s defines no profiles", name, configFile)
+		}
+		return fmt.Errorf("profile %q is not defined in %s; defined profiles: %s", name, configFile, strings.Join(slices.Sorted(maps.Keys(cfg.Profiles)), ", "))
+	}
+	return applyFlags(cmd, profile, "profiles."+name)
+}
+
+// applyFlags sets the flags of gen from flags, found at section of the configuration, except the
+// flags already set
+func applyFlags(cmd *cobra.Command, flags config.Profile, section string) error {
+	for _, key := range slices.Sorted(maps.Keys(flags)) {
+		// Keys may follow the snake_case of the rest of the configuration, except for flags named
+		// so (proto_path)
+		flag := key
+		if cmd.Flags().Lookup(flag) == nil {
+			flag = strings.ReplaceAll(key, "_", "-")
+		}
+		f := cmd.Flags().Lookup(flag)
+		if f == nil || slices.Contains(unprofiledFlags, flag) {
+			return fmt.Errorf("%s: %s.%s is not a gen flag the configuration can set", configFile, section, key)
+		}
+		if f.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(flag, flags[key]); err != nil {
+			return fmt.Errorf("%s: %s.%s: %w", configFile, section, key, err)
+		}
+	}
+	return nil
+}
+
+// configSetsLanguages reports whether the gen section of the configuration sets the languages. A
+// configuration that does not load is reported by gen itself.
+func configSetsLanguages() bool {
+	cfg, err := config.Load(configFile)
+	return err == nil && cfg.Gen["lang"] != ""
+}
+
+// targetDir returns the directory the files of target are written to: the output directory of its
+// language in the configuration, or --out
+func targetDir(target string) string {
+	if dir, ok := genOutputs[strings.TrimPrefix(target, "protoc-")]; ok {
+		return dir
+	}
+	return outDir
+}
+
+// protocTargets groups languages by the target staging their protoc bindings: protoc for the
+// languages written to --out, and protoc-<lang> for each language with an output directory of its
+// own, so its bindings are written next to its dispatcher
+func protocTargets(languages []string) ([]string, map[string][]string) {
+	var targets []string
+	langs := map[string][]string{}
+	for _, lang := range languages {
+		target := "protoc"
+		if _, ok := genOutputs[lang]; ok {
+			target = "protoc-" + lang
+		}
+		if langs[target] == nil {
+			targets = append(targets, target)
+		}
+		langs[target] = append(langs[target], lang)
+	}
+	return targets, langs
+}
+
+// vendorOptions writes socketgen/options.proto into the import path having it, or else the first
+// (the working directory without --proto_path), when protoFile imports it and the file is missing or
+// comes from another socketgen version
+func vendorOptions(protoFile string, report *genReport) {
+	schema, err := os.ReadFile(protoFile)
+	if err != nil || !options.Imported(schema) {
+		return
+	}
+	dir := parser.ImportDir(options.ImportPath)
+	_, statErr := os.Stat(filepath.Join(dir, options.ImportPath))
+	written, err := options.Vendor(dir)
+	switch {
+	case err != nil:
+		report.warn("Failed to write %s: %v", options.ImportPath, err)
+	case written && statErr != nil:
+		fmt.Fprintf(genLog, "Created '%s' with socketgen's custom options.\n", options.ImportPath)
+	case written:
+		fmt.Fprintf(genLog, "Updated '%s' to this version of socketgen's custom options.\n", options.ImportPath)
+	}
 }
 
 func init() {
@@ -96,6 +751,57 @@ func init() {
 	genCmd.Flags().StringSliceVar(&languages, "lang", []string{}, "Target languages (go, ts, python, csharp, dart, php, ruby, kotlin, java)")
 	genCmd.Flags().StringVar(&outDir, "out", "./gen", "Output directory")
 	genCmd.Flags().BoolVar(&withProtoc, "protoc", false, "Generate protobuf bindings using protoc")
+	genCmd.Flags().StringVar(&genProfile, "profile", "", "Profile of the configuration setting the flags not given on the command line (e.g. dev, prod)")
+	genCmd.Flags().BoolVar(&genJSON, "json", false, "Print a JSON report of the payloads, files written, warnings and errors to stdout; progress goes to stderr")
+	genCmd.Flags().BoolVar(&genPlan, "plan", false, "Print which files would be created, overwritten or deleted, without writing anything")
+	genCmd.Flags().BoolVar(&genClean, "clean", false, "Delete files generated by an earlier run that are no longer generated (e.g. after a payload was removed)")
+	genCmd.Flags().BoolVar(&genForce, "force", false, "Overwrite (and with --clean, delete) generated files edited since they were generated")
+	genCmd.Flags().StringSliceVar(&genOnly, "only", []string{}, "Generate only these artifacts ("+strings.Join(genArtifacts, ", ")+")")
+	genCmd.Flags().StringSliceVar(&genSkip, "skip", []string{}, "Do not generate these artifacts ("+strings.Join(genArtifacts, ", ")+")")
+	genCmd.Flags().BoolVar(&genSizes, "sizes", false, "Print the files, lines and bytes generated per target, and the time the compile commands of the configuration take")
+	genCmd.Flags().IntVar(&genGrowth, "growth-warn", 25, "Warn when the code of a target grows by more than this percentage, and faster than the payloads (0 disables)")
+	genCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop at the first failure: skip generation if protoc fails, and languages not yet started if one fails")
+	genCmd.Flags().BoolVar(&keepGoing, "keep-going", false, "Generate every language despite failures, and report them all at the end (default)")
+	genCmd.MarkFlagsMutuallyExclusive("fail-fast", "keep-going")
+	genCmd.Flags().BoolVar(&genIndexJSON, "index", false, "Write "+indexFileName+", mapping every payload to the handlers and send functions generated for it per language, for editor plugins")
+	genCmd.Flags().BoolVar(&genWatch, "watch", false, "Generate again whenever the proto file or its imports change, until interrupted")
+	genCmd.Flags().DurationVar(&genDebounce, "debounce", 300*time.Millisecond, "How long the files must stay unchanged before --watch generates again, so saving several files runs gen once")
+	genCmd.MarkFlagsMutuallyExclusive("watch", "plan")
+	genCmd.Flags().IntVar(&jobs, "jobs", runtime.NumCPU(), "Number of languages to generate at once")
+	genCmd.Flags().BoolVar(&withVectors, "vectors", false, "Generate golden test vectors (vectors.json) and a test per language that checks them")
+	genCmd.Flags().BoolVar(&withFuzz, "fuzz", false, "Generate fuzz tests of the dispatcher and the stream transport frames, run by socketgen fuzz (go)")
+	genCmd.Flags().BoolVar(&withChaos, "chaos", false, "Generate a ChaosStream injecting latency, drops, duplicates and reordering into a PacketStream, for tests (go)")
+	genCmd.Flags().BoolVar(&withSim, "sim", false, "Generate a deterministic simulation harness with a virtual Clock, replaying recordings into handlers (go)")
+	genCmd.Flags().BoolVar(&withSamples, "samples", false, "Generate Sample<Payload> builders returning realistic sample payloads for tests (go)")
+
+	genCmd.Flags().BoolVar(&withCoverage, "coverage", false, "Generate handler coverage instrumentation (go, ts); merge reports with 'socketgen coverage'")
+
+	genCmd.Flags().BoolVar(&withPooled, "pooled", false, "Generate decode paths into pooled buffers for high-throughput servers (csharp, java)")
+	genCmd.Flags().BoolVar(&withSplit, "split", false, "Generate the TypeScript client as a module per payload that bundlers can tree-shake, loading payloads marked lazy on first use (ts)")
+	genCmd.Flags().BoolVar(&withSignalR, "signalr", false, "Generate a SignalR hub and HubConnection stream that carry packets (csharp)")
+
+	genCmd.Flags().BoolVar(&withCanary, "canary", false, "Generate a CanaryHandler rolling new handler logic out to a share of packets or sessions (go)")
+
+	genCmd.Flags().BoolVar(&concurrency, "concurrency", false, "Generate ServeConcurrent and per-session limits on the handlers running at once (go)")
+	genCmd.Flags().BoolVar(&withDedup, "dedup", false, "Generate a DedupStream answering retried requests from a cache of responses by Header.request_id (go)")
+
+	genCmd.Flags().BoolVar(&replayWindow, "replay-window", false, "Generate a ReplayWindowStream dropping packets whose Header.timestamp is too old or too far ahead (go)")
+
+	genCmd.Flags().BoolVar(&zeroAlloc, "zero-alloc", false, "Generate a Go decoder that reuses messages, with dispatch benchmarks for 'socketgen bench'")
+
+	genCmd.Flags().BoolVar(&withMetrics, "metrics", false, "Generate OpenMetrics packet counters fed by the sampling tap stream (go)")
+
+	genCmd.Flags().StringSliceVar(&transports, "transports", []string{}, "Go server transports to generate (tcp, ws, kcp, quic, socketio, mqtt, grpcweb, sse)")
+	genCmd.Flags().BoolVar(&frameCRC, "frame-crc", false, "Append a CRC32C checksum to every frame of the stream transports (tcp, kcp, quic)")
+
+	genCmd.Flags().StringVar(&genEnv, "env", "", "Environment of the endpoints of the configuration the generated clients connect to by default (default: endpoints.default)")
+
+	genCmd.Flags().StringSliceVar(&gateway, "gateway", []string{}, "Generate a Go gateway forwarding grouped payloads over these backends (nats, grpc)")
+
+	genCmd.Flags().StringVar(&previous, "previous", "", "Descriptor set of the previous schema (e.g. the packet_descriptor.pb of the last release) to keep serving during rolling deployments (go)")
+
+	genCmd.Flags().BoolVar(&withLock, "lock", false, "Create socketgen.lock, the payload history from which "+lock.MapFileName+" maps old payload names (kept up to date once it exists)")
+
+	genCmd.Flags().StringVar(&internal, "internal", "", "Proto file defining the InternalPacket envelope for server-to-server traffic (default: the --proto file, if it defines one)")
 
-	genCmd.MarkFlagRequired("lang")
 }
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// indexFileName is the index gen --index writes into the output directory
+const indexFileName = "socketgen.index.json"
+
+// genIndex maps every payload to the symbols generated for it, for editor plugins and scripts to
+// go to the handler of a payload or find its senders
+type genIndex struct {
+	SchemaVersion string         `json:"schemaVersion"`
+	Proto         string         `json:"proto"`
+	Payloads      []indexPayload `json:"payloads"`
+}
+
+type indexPayload struct {
+	Name     string        `json:"name"`
+	Field    string        `json:"field"`
+	Number   int32         `json:"number"`
+	Type     string        `json:"type"`
+	Message  string        `json:"message,omitempty"`  // Where the message is declared, as file:line
+	Declared string        `json:"declared,omitempty"` // Where the field of the envelope is declared, as file:line
+	Symbols  []indexSymbol `json:"symbols"`
+}
+
+// indexSymbol is a symbol generated for a payload. File is relative to the output directory, and
+// Line starts at 1.
+type indexSymbol struct {
+	Language string `json:"language"`
+	Kind     string `json:"kind"` // "handler": the method handling the payload; "sender": a function sending it
+	Symbol   string `json:"symbol"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// senderDeclaration matches the declaration of a function in any generated language, capturing its name
+var senderDeclaration = regexp.MustCompile(`(?:\b(?:func|function|fun|void|def)\s+|Future<void>\s+|\bdef self\.)(\w+)\(`)
+
+// symbolNames returns the names of the handler method and the send function generated for p in lang
+func symbolNames(lang string, p *parser.PayloadMessage) (handler, sender string) {
+	switch lang {
+	case "go", "csharp":
+		return "On" + p.Name, "Send" + p.Name
+	case "python", "ruby":
+		return "on_" + p.FieldName, "send_" + p.FieldName
+	}
+	return "on" + p.Name, "send" + p.Name
+}
+
+// declarations returns where the message of p and its field of the envelope are declared, as
+// file:line
+func declarations(result *parser.ParseResult, p *parser.PayloadMessage) (message, field string) {
+	if desc, err := result.Schema.Files.FindDescriptorByName(protoreflect.FullName(p.FullName)); err == nil {
+		message = parser.Position(desc)
+	}
+	if fd := result.Schema.Packet.Fields().ByNumber(protoreflect.FieldNumber(p.Number)); fd != nil {
+		field = parser.Position(fd)
+	}
+	return message, field
+}
+
+// writeIndex indexes the symbols the languages staged in stage/<target> generated for the payloads
+// of result, into dir
+func writeIndex(result *parser.ParseResult, stage string, report *genReport, dir string) error {
+	index := genIndex{SchemaVersion: result.SchemaVersion, Proto: filepath.ToSlash(parser.SourcePath(result.Schema.Packet.ParentFile().Path())), Payloads: []indexPayload{}}
+	byName := map[string]int{}
+	for i := range result.Payloads {
+		p := &result.Payloads[i]
+		message, field := declarations(result, p)
+		index.Payloads = append(index.Payloads, indexPayload{
+			Name: p.Name, Field: p.FieldName, Number: p.Number, Type: p.FullName,
+			Message: message, Declared: field, Symbols: []indexSymbol{},
+		})
+		byName[p.FullName] = i
+	}
+
+	for _, t := range report.Targets {
+		if !t.OK || languageGenerators[t.Name] == nil {
+			continue
+		}
+		lang := t.Name
+		senders := map[string]int{}
+		for i := range result.Payloads {
+			_, sender := symbolNames(lang, &result.Payloads[i])
+			senders[sender] = i
+		}
+
+		root := filepath.Join(stage, lang)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == root {
+				return nil
+			}
+			if err != nil || d.IsDir() || generatedLanguages[filepath.Ext(path)] == "" {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			// The index is in the output directory, while a language may be written elsewhere
+			if rel, err = filepath.Rel(outDir, filepath.Join(targetDir(lang), rel)); err != nil {
+				return err
+			}
+			file := filepath.ToSlash(rel)
+
+			lines := strings.Split(string(data), "\n")
+			for i, line := range lines {
+				if m := senderDeclaration.FindStringSubmatch(line); m != nil {
+					if p, ok := senders[m[1]]; ok {
+						index.Payloads[p].Symbols = append(index.Payloads[p].Symbols, indexSymbol{lang, "sender", m[1], file, i + 1})
+					}
+				}
+
+				// The handlers carry the Source: doc comment of their payload
+				_, source, ok := strings.Cut(line, parser.SourcePrefix)
+				if !ok {
+					continue
+				}
+				fullName, _, _ := strings.Cut(source, " ")
+				p, ok := byName[fullName]
+				if !ok {
+					continue
+				}
+				handler, _ := symbolNames(lang, &result.Payloads[p])
+				if at := handlerLine(lines, i, handler); at >= 0 {
+					index.Payloads[p].Symbols = append(index.Payloads[p].Symbols, indexSymbol{lang, "handler", handler, file, at + 1})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range index.Payloads {
+		slices.SortStableFunc(index.Payloads[i].Symbols, func(a, b indexSymbol) int {
+			return strings.Compare(a.Language, b.Language)
+		})
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, indexFileName), append(data, '\n'), 0644)
+}
+
+// handlerLine returns the index of the line declaring handler next to the doc comment at line
+// comment: after it, or before it for Python docstrings. It returns -1 if there is none.
+func handlerLine(lines []string, comment int, handler string) int {
+	call := handler + "("
+	for i := comment + 1; i < len(lines) && i <= comment+20; i++ {
+		if strings.Contains(lines[i], call) {
+			return i
+		}
+		if strings.Contains(lines[i], parser.SourcePrefix) {
+			break
+		}
+	}
+	for i := comment - 1; i >= 0 && i >= comment-20; i-- {
+		if strings.Contains(lines[i], call) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// manifestFile lists the files gen wrote to the output directory by target with the hash of their
+// content, so files that are no longer generated (e.g. after a payload was removed) can be told from
+// files of the user, and generated files the user edited since are not overwritten
+const manifestFile = ".socketgen-manifest.json"
+
+// manifest is the content of manifestFile
+type manifest struct {
+	Targets  map[string][]manifestEntry `json:"targets"`
+	Payloads int                        `json:"payloads,omitempty"` // Of the schema, when the sizes were recorded
+	Sizes    map[string]targetSize      `json:"sizes,omitempty"`    // Of the code of each target, from the last generation of every artifact
+}
+
+type manifestEntry struct {
+	Path   string `json:"path"`             // Relative to the output directory, with forward slashes
+	SHA256 string `json:"sha256,omitempty"` // Of the content generated; empty in manifests of older versions
+}
+
+func loadManifest(dir string) (*manifest, error) {
+	m := &manifest{Targets: map[string][]manifestEntry{}}
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+	if m.Targets == nil {
+		m.Targets = map[string][]manifestEntry{}
+	}
+	return m, nil
+}
+
+func (m *manifest) save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0644)
+}
+
+// What applying the plan does to a file of the output directory
+const (
+	actionCreate    = "create"
+	actionOverwrite = "overwrite"
+	actionUnchanged = "unchanged"
+	actionOrphaned  = "orphaned" // Generated by an earlier run only; deleted with --clean
+	actionDelete    = "delete"
+	actionModified  = "modified" // Edited since it was generated; kept unless --force
+)
+
+// planEntry is a file of the output directory a target generates or generated before
+type planEntry struct {
+	Target string `json:"target"`
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	rel    string // Path relative to the output directory, with forward slashes
+	src    string // Staged file, "" for orphans
+	sum    string // Hash to record in the manifest
+}
+
+// makePlan compares the files staged for targets, in stage/<target>, with the output directory.
+// Files the manifest lists for these targets that were not staged again are orphans, unless --only
+// or --skip left out some artifacts.
+func makePlan(stage string, targets []string, m *manifest) ([]planEntry, error) {
+	// A file can move between targets, e.g. when --protoc is dropped
+	recorded := map[string]string{}
+	for _, entries := range m.Targets {
+		for _, e := range entries {
+			recorded[e.Path] = e.SHA256
+		}
+	}
+
+	var plan []planEntry
+	produced := map[string]bool{}
+	for _, target := range targets {
+		root := filepath.Join(stage, target)
+		dir := targetDir(target)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return nil // The target wrote nothing
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			// The manifest lists the files of languages written elsewhere relative to the output directory too
+			dst := filepath.Join(dir, rel)
+			if rel, err = filepath.Rel(outDir, dst); err != nil {
+				return err
+			}
+			e := planEntry{Target: target, Path: dst, rel: filepath.ToSlash(rel), src: path}
+			if e.Action, e.sum, err = compareFiles(path, e.Path, recorded[e.rel]); err != nil {
+				return err
+			}
+			produced[e.rel] = true
+			plan = append(plan, e)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, target := range targets {
+		for _, old := range m.Targets[target] {
+			path := filepath.Join(outDir, filepath.FromSlash(old.Path))
+			if produced[old.Path] || partial() {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				continue // Removed already
+			}
+			action := actionOrphaned
+			if genClean {
+				action = actionDelete
+				if edited, err := editedSince(path, old.SHA256); err != nil {
+					return nil, err
+				} else if edited {
+					action = actionModified
+				}
+			}
+			plan = append(plan, planEntry{Target: target, Path: path, Action: action, rel: old.Path, sum: old.SHA256})
+		}
+	}
+
+	// The orphans of a target follow its files
+	slices.SortStableFunc(plan, func(a, b planEntry) int {
+		return cmp.Compare(slices.Index(targets, a.Target), slices.Index(targets, b.Target))
+	})
+	return plan, nil
+}
+
+// compareFiles returns what copying src over dst does, given the hash recorded for dst when it was
+// generated, and the hash to record for it
+func compareFiles(src, dst, recorded string) (string, string, error) {
+	want, err := os.ReadFile(src)
+	if err != nil {
+		return "", "", err
+	}
+	sum := hashContent(want)
+	have, err := os.ReadFile(dst)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return actionCreate, sum, nil
+	case err != nil:
+		return "", "", err
+	case bytes.Equal(want, have):
+		return actionUnchanged, sum, nil
+	case recorded != "" && hashContent(have) != recorded && !genForce:
+		// The manifest keeps the hash of what was generated, so the edit is caught again next time
+		return actionModified, recorded, nil
+	}
+	return actionOverwrite, sum, nil
+}
+
+// editedSince reports whether the file at path no longer has the content recorded in the manifest.
+// Files recorded without a hash, by older versions, count as unedited.
+func editedSince(path, recorded string) (bool, error) {
+	if recorded == "" || genForce {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return hashContent(data) != recorded, nil
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// applyStage plans copying the staged targets to the output directory and prints the plan. Unless
+// --plan is set, it then copies the files, deletes orphans with --clean, and updates the manifest.
+// Unchanged files are not written, so their modification times stay, and files edited since they
+// were generated are not written unless --force is set.
+func applyStage(stage string, targets []string, report *genReport) error {
+	m, err := loadManifest(outDir)
+	if err != nil {
+		return err
+	}
+	plan, err := makePlan(stage, targets, m)
+	if err != nil {
+		return err
+	}
+	printPlan(plan, report)
+	compareSizes(m, report)
+	if genPlan {
+		return nil
+	}
+
+	for _, e := range plan {
+		switch e.Action {
+		case actionCreate, actionOverwrite:
+			data, err := os.ReadFile(e.src)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(e.Path, data, 0644); err != nil {
+				return err
+			}
+			report.Files = append(report.Files, e.Path)
+		case actionDelete:
+			if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+	for _, target := range targets {
+		entries := []manifestEntry{}
+		for _, e := range plan {
+			// Orphans stay listed until deleted, so a later --clean finds them
+			if e.Target == target && e.Action != actionDelete {
+				entries = append(entries, manifestEntry{Path: e.rel, SHA256: e.sum})
+			}
+		}
+		// The files of the artifacts left out stay listed as they were
+		for _, old := range m.Targets[target] {
+			if partial() && !slices.ContainsFunc(entries, func(e manifestEntry) bool { return e.Path == old.Path }) {
+				entries = append(entries, old)
+			}
+		}
+		slices.SortFunc(entries, func(a, b manifestEntry) int { return cmp.Compare(a.Path, b.Path) })
+		m.Targets[target] = entries
+	}
+	recordSizes(m, report)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	return m.save(outDir)
+}
+
+// printPlan prints every file of the plan with --plan, and otherwise a summary, the orphans and
+// the edited files it keeps
+func printPlan(plan []planEntry, report *genReport) {
+	counts := map[string]int{}
+	target := ""
+	for _, e := range plan {
+		counts[e.Action]++
+		if !genPlan {
+			continue
+		}
+		if e.Target != target {
+			target = e.Target
+			fmt.Fprintf(genLog, "%s:\n", target)
+		}
+		fmt.Fprintf(genLog, "  %-9s %s\n", e.Action, e.Path)
+	}
+	if genPlan {
+		report.Plan = plan
+	}
+
+	for _, e := range plan {
+		switch {
+		case e.Action == actionOrphaned:
+			report.warn("%s is no longer generated; delete it or run gen with --clean", e.Path)
+		case e.Action == actionModified && e.src == "":
+			report.fail(exitGenerate, "%s is no longer generated but was edited since; delete it or run gen with --clean --force", e.Path)
+		case e.Action == actionModified:
+			report.fail(exitGenerate, "%s was edited since it was generated; move the changes out of it, or run gen with --force to overwrite it", e.Path)
+		}
+	}
+
+	summary := "Files in"
+	if genPlan {
+		summary = "Plan for"
+	}
+	fmt.Fprintf(genLog, "%s %s: %d created, %d overwritten, %d unchanged, %d deleted", summary, outDir,
+		counts[actionCreate], counts[actionOverwrite], counts[actionUnchanged], counts[actionDelete])
+	if counts[actionModified] > 0 {
+		fmt.Fprintf(genLog, ", %d edited kept", counts[actionModified])
+	}
+	fmt.Fprintln(genLog, ".")
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// planProject sets the output directory to a new directory, with the given files, and resets the
+// gen flags the plan depends on; all of them are restored afterwards. It returns the directory
+// the targets are staged in.
+func planProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	out, outputs, clean, force, plan, only, skip, log := outDir, genOutputs, genClean, genForce, genPlan, genOnly, genSkip, genLog
+	t.Cleanup(func() {
+		outDir, genOutputs, genClean, genForce, genPlan, genOnly, genSkip, genLog = out, outputs, clean, force, plan, only, skip, log
+	})
+	outDir = writeProject(t, files)
+	genOutputs, genClean, genForce, genPlan, genOnly, genSkip, genLog = nil, false, false, false, nil, nil, io.Discard
+	return t.TempDir()
+}
+
+// stageFiles writes files, by path relative to the staging directory, e.g. go/packet_dispatcher.go
+func stageFiles(t *testing.T, stage string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(stage, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// recordedManifest returns a manifest listing files, by target, with the hash of their content
+func recordedManifest(files map[string]map[string]string) *manifest {
+	m := &manifest{Targets: map[string][]manifestEntry{}}
+	for target, contents := range files {
+		for path, content := range contents {
+			m.Targets[target] = append(m.Targets[target], manifestEntry{Path: path, SHA256: hashContent([]byte(content))})
+		}
+	}
+	return m
+}
+
+// actions returns the action of every entry of plan, keyed by its target and its path relative to
+// the output directory (e.g. go:packet.go)
+func actions(plan []planEntry) map[string]string {
+	got := map[string]string{}
+	for _, e := range plan {
+		got[e.Target+":"+e.rel] = e.Action
+	}
+	return got
+}
+
+func TestCompareFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst      string // "" for no file
+		recorded string
+		force    bool
+		want     string
+	}{
+		{"new file", "", "", false, actionCreate},
+		{"same content", "generated v2", hashContent([]byte("generated v1")), false, actionUnchanged},
+		{"regenerated", "generated v1", hashContent([]byte("generated v1")), false, actionOverwrite},
+		{"recorded by an older version", "edited", "", false, actionOverwrite},
+		{"edited", "edited", hashContent([]byte("generated v1")), false, actionModified},
+		{"edited with --force", "edited", hashContent([]byte("generated v1")), true, actionOverwrite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage := planProject(t, nil)
+			genForce = tt.force
+			src := filepath.Join(stage, "packet.go")
+			stageFiles(t, stage, map[string]string{"packet.go": "generated v2"})
+			dst := filepath.Join(outDir, "packet.go")
+			if tt.dst != "" {
+				if err := os.WriteFile(dst, []byte(tt.dst), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			action, sum, err := compareFiles(src, dst, tt.recorded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != tt.want {
+				t.Errorf("got %s, want %s", action, tt.want)
+			}
+			// An edited file keeps the hash of what was generated, so the edit is caught next time
+			wantSum := hashContent([]byte("generated v2"))
+			if action == actionModified {
+				wantSum = tt.recorded
+			}
+			if sum != wantSum {
+				t.Errorf("got hash %s to record, want %s", sum, wantSum)
+			}
+		})
+	}
+}
+
+func TestMakePlanOrphans(t *testing.T) {
+	tests := []struct {
+		name   string
+		edited bool
+		clean  bool
+		force  bool
+		only   []string
+		want   string // Action of the orphan, "" if it is not in the plan
+	}{
+		{"kept", false, false, false, nil, actionOrphaned},
+		{"deleted with --clean", false, true, false, nil, actionDelete},
+		{"edited, kept with --clean", true, true, false, nil, actionModified},
+		{"edited, deleted with --clean --force", true, true, true, nil, actionDelete},
+		{"left out by --only", false, true, false, []string{"dispatcher"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orphan := "packet_chat_msg.go"
+			if tt.edited {
+				orphan = "edited"
+			}
+			stage := planProject(t, map[string]string{"packet.go": "generated", "packet_chat_msg.go": orphan})
+			genClean, genForce, genOnly = tt.clean, tt.force, tt.only
+			stageFiles(t, stage, map[string]string{"go/packet.go": "generated"})
+			m := recordedManifest(map[string]map[string]string{"go": {"packet.go": "generated", "packet_chat_msg.go": "packet_chat_msg.go"}})
+
+			plan, err := makePlan(stage, []string{"go"}, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := actions(plan)
+			if got["go:packet.go"] != actionUnchanged {
+				t.Errorf("got %s for packet.go, want %s", got["go:packet.go"], actionUnchanged)
+			}
+			if got["go:packet_chat_msg.go"] != tt.want {
+				t.Errorf("got %q for the orphan, want %q", got["go:packet_chat_msg.go"], tt.want)
+			}
+		})
+	}
+}
+
+func TestMakePlanIgnoresRemovedOrphans(t *testing.T) {
+	stage := planProject(t, nil)
+	genClean = true
+	m := recordedManifest(map[string]map[string]string{"go": {"packet_chat_msg.go": "generated"}})
+
+	plan, err := makePlan(stage, []string{"go"}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 0 {
+		t.Errorf("got plan %v for an orphan deleted by hand, want none", actions(plan))
+	}
+}
+
+func TestMakePlanFileMovedBetweenTargets(t *testing.T) {
+	// packet.pb.go was staged by protoc; without --protoc, the Go target writes it instead
+	tests := []struct {
+		name    string
+		current string
+		want    string
+	}{
+		{"regenerated", "protoc bindings", actionOverwrite},
+		{"edited", "edited bindings", actionModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage := planProject(t, map[string]string{"packet.pb.go": tt.current})
+			stageFiles(t, stage, map[string]string{"go/packet.pb.go": "builtin bindings"})
+			m := recordedManifest(map[string]map[string]string{"protoc": {"packet.pb.go": "protoc bindings"}})
+
+			plan, err := makePlan(stage, []string{"go"}, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := actions(plan); len(got) != 1 || got["go:packet.pb.go"] != tt.want {
+				t.Errorf("got plan %v, want packet.pb.go of go to %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakePlanLanguageOutputs(t *testing.T) {
+	stage := planProject(t, nil)
+	genOutputs = map[string]string{"ts": filepath.Join(outDir, "web")}
+	stageFiles(t, stage, map[string]string{"go/packet.go": "go", "ts/PacketDispatcher.ts": "ts"})
+
+	plan, err := makePlan(stage, []string{"go", "ts"}, &manifest{Targets: map[string][]manifestEntry{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The manifest lists the files of languages written elsewhere relative to the output directory too
+	want := map[string]string{"go:packet.go": actionCreate, "ts:web/PacketDispatcher.ts": actionCreate}
+	if got := actions(plan); !maps.Equal(got, want) {
+		t.Errorf("got plan %v, want %v", got, want)
+	}
+}
+
+func TestApplyStage(t *testing.T) {
+	stage := planProject(t, nil)
+	stageFiles(t, stage, map[string]string{"go/packet.go": "v1", "go/packet_chat_msg.go": "v1", "ts/PacketDispatcher.ts": "v1"})
+	apply := func() *genReport {
+		t.Helper()
+		report := &genReport{}
+		if err := applyStage(stage, []string{"go", "ts"}, report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if report := apply(); len(report.Files) != 3 || read("packet.go") != "v1" {
+		t.Fatalf("got files %v, want the 3 staged files written", report.Files)
+	}
+
+	// --plan writes nothing
+	genPlan = true
+	stageFiles(t, stage, map[string]string{"go/packet.go": "v2"})
+	if report := apply(); len(report.Plan) != 3 || read("packet.go") != "v1" {
+		t.Errorf("--plan planned %d files and left packet.go %q, want 3 planned and packet.go unchanged", len(report.Plan), read("packet.go"))
+	}
+	genPlan = false
+
+	// An edited file is kept, and reported
+	if err := os.WriteFile(filepath.Join(outDir, "packet.go"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := apply()
+	if read("packet.go") != "edited" || len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "was edited") {
+		t.Errorf("got packet.go %q and errors %v, want the edit kept and reported", read("packet.go"), report.Errors)
+	}
+	// The manifest keeps the hash generated, so the edit is still caught on the next run
+	if report := apply(); len(report.Errors) != 1 {
+		t.Errorf("got errors %v on the next run, want the edit reported again", report.Errors)
+	}
+	genForce = true
+	if apply(); read("packet.go") != "v2" {
+		t.Errorf("got packet.go %q with --force, want it overwritten", read("packet.go"))
+	}
+	genForce = false
+
+	// Only the dispatcher is generated: the files of earlier runs that were not staged again stay listed
+	genOnly = []string{"dispatcher"}
+	if err := os.RemoveAll(filepath.Join(stage, "go", "packet_chat_msg.go")); err != nil {
+		t.Fatal(err)
+	}
+	if report := apply(); len(report.Warnings) != 0 {
+		t.Errorf("got warnings %v with --only, want no orphans", report.Warnings)
+	}
+	m, err := loadManifest(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, e := range m.Targets["go"] {
+		listed = append(listed, e.Path)
+	}
+	if !slices.Equal(listed, []string{"packet.go", "packet_chat_msg.go"}) {
+		t.Errorf("the manifest lists %v for go, want both files", listed)
+	}
+
+	// Generating everything again, packet_chat_msg.go is an orphan, deleted with --clean
+	genOnly = nil
+	if report := apply(); len(report.Warnings) != 1 || read("packet_chat_msg.go") != "v1" {
+		t.Errorf("got warnings %v, want packet_chat_msg.go kept as an orphan", report.Warnings)
+	}
+	genClean = true
+	if apply(); read("packet_chat_msg.go") != "" {
+		t.Errorf("packet_chat_msg.go was not deleted with --clean")
+	}
+	if m, err = loadManifest(outDir); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Targets["go"]) != 1 {
+		t.Errorf("the manifest lists %v for go after --clean, want packet.go only", m.Targets["go"])
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Exit statuses of gen, one per stage that can fail; invalid flags exit with 1. When several stages
+// fail (with --keep-going), gen exits with the status of the first.
+const (
+	exitConfig   = 2 // The project configuration cannot be loaded
+	exitParse    = 3 // packet.proto (or --proto) does not compile or is not a valid socketgen schema
+	exitProtoc   = 4 // protoc failed for at least one language
+	exitGenerate = 5 // The code of at least one language failed to generate
+)
+
+// genLog receives the progress of gen; stderr with --json
+var genLog io.Writer = os.Stdout
+
+// genReport is the outcome of gen, or of lint, printed as JSON with --json for build systems and editors
+type genReport struct {
+	OK            bool         `json:"ok"`
+	ExitCode      int          `json:"exitCode"`
+	Package       string       `json:"package,omitempty"`
+	SchemaVersion string       `json:"schemaVersion,omitempty"`
+	OutDir        string       `json:"outDir,omitempty"` // Not set by lint
+	Payloads      []genPayload `json:"payloads"`
+	Targets       []genTarget  `json:"targets"`
+	Files         []string     `json:"files"`          // Files created or overwritten in outDir, including protoc's
+	Plan          []planEntry  `json:"plan,omitempty"` // Every file generated or orphaned, with --plan
+	Warnings      []string     `json:"warnings"`
+	Errors        []string     `json:"errors"`
+}
+
+type genPayload struct {
+	Name   string `json:"name"`
+	Field  string `json:"field"`
+	Type   string `json:"type"`
+	Number int32  `json:"number"`
+}
+
+// genTarget is the outcome of one language, or of the golden vectors
+type genTarget struct {
+	Name      string        `json:"name"`
+	OK        bool          `json:"ok"`
+	Skipped   bool          `json:"skipped,omitempty"` // Not started after an earlier failure, with --fail-fast
+	Elapsed   time.Duration `json:"-"`
+	ElapsedMs int64         `json:"elapsedMs"`
+	Notes     []string      `json:"notes,omitempty"`
+	Errors    []string      `json:"errors,omitempty"`
+	Size      *targetSize   `json:"size,omitempty"`         // Of the code generated
+	Previous  *targetSize   `json:"previousSize,omitempty"` // Of the code the last generation of every artifact
+	Compile   time.Duration `json:"-"`                      // Run time of the compile command, with --sizes
+	CompileMs int64         `json:"compileMs,omitempty"`
+}
+
+func (r *genReport) warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintf(genLog, "Warning: %s\n", msg)
+	r.Warnings = append(r.Warnings, msg)
+}
+
+// fail records an error of the stage exiting with code
+func (r *genReport) fail(code int, format string, args ...any) {
+	if r.ExitCode == 0 {
+		r.ExitCode = code
+	}
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintf(genLog, "Error: %s\n", msg)
+	r.Errors = append(r.Errors, msg)
+}
+
+// finish prints the report with --json, and exits with the status of the first failure
+func (r *genReport) finish() {
+	r.print()
+	if !r.OK {
+		os.Exit(r.ExitCode)
+	}
+}
+
+// print completes the report and prints it with --json
+func (r *genReport) print() {
+	r.OK = len(r.Errors) == 0
+	if genJSON {
+		for i := range r.Targets {
+			r.Targets[i].ElapsedMs = r.Targets[i].Elapsed.Milliseconds()
+			r.Targets[i].CompileMs = r.Targets[i].Compile.Milliseconds()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	}
+}
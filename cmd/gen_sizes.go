@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snowmerak/socketgen/config"
+)
+
+// targetSize is the size of the code a target generated
+type targetSize struct {
+	Files int   `json:"files"`
+	Lines int   `json:"lines"`
+	Bytes int64 `json:"bytes"`
+}
+
+// measureTargets measures what every target that succeeded staged in stage/<target>
+func measureTargets(stage string, report *genReport) error {
+	for i := range report.Targets {
+		t := &report.Targets[i]
+		if !t.OK {
+			continue
+		}
+		size, err := measure(filepath.Join(stage, t.Name))
+		if err != nil {
+			return err
+		}
+		t.Size = &size
+	}
+	return nil
+}
+
+func measure(root string) (targetSize, error) {
+	var size targetSize
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if path == root && errors.Is(err, fs.ErrNotExist) {
+			return nil // The target wrote nothing
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		size.Files++
+		size.Bytes += int64(len(data))
+		// Binary files, such as the embedded descriptor set, have no lines
+		if len(data) > 0 && bytes.IndexByte(data, 0) < 0 {
+			size.Lines += bytes.Count(data, []byte{'\n'})
+			if data[len(data)-1] != '\n' {
+				size.Lines++
+			}
+		}
+		return nil
+	})
+	return size, err
+}
+
+// compareSizes sets the sizes the manifest recorded for the targets the last time they were all
+// generated, and warns about targets whose code grew by more than --growth-warn percent, and faster
+// than the payloads did. Sizes are not compared when --only or --skip leave out some artifacts.
+func compareSizes(m *manifest, report *genReport) {
+	if partial() || m.Payloads == 0 {
+		return
+	}
+	payloads := float64(len(report.Payloads)) / float64(m.Payloads)
+	for i := range report.Targets {
+		t := &report.Targets[i]
+		prev, ok := m.Sizes[t.Name]
+		if t.Size == nil || !ok || prev.Lines == 0 {
+			continue
+		}
+		t.Previous = &prev
+		growth := float64(t.Size.Lines) / float64(prev.Lines)
+		if genGrowth > 0 && growth > 1+float64(genGrowth)/100 && growth > payloads {
+			report.warn("the %s code grew %s (%d to %d lines, %s) while payloads went from %d to %d; check the options and payloads added for what costs that much",
+				t.Name, percent(growth), prev.Lines, t.Size.Lines, byteSize(t.Size.Bytes), m.Payloads, len(report.Payloads))
+		}
+	}
+}
+
+// recordSizes records the sizes of the targets in the manifest, for compareSizes to compare the
+// next generation with
+func recordSizes(m *manifest, report *genReport) {
+	if partial() {
+		return
+	}
+	if m.Sizes == nil {
+		m.Sizes = map[string]targetSize{}
+	}
+	m.Payloads = len(report.Payloads)
+	for _, t := range report.Targets {
+		if t.Size != nil {
+			m.Sizes[t.Name] = *t.Size
+		}
+	}
+}
+
+// timeCompiles runs the compile command of the configuration for each language generated, and
+// records how long it took. A command failing is a warning: gen wrote the code either way.
+func timeCompiles(cfg *config.Config, report *genReport) {
+	for i := range report.Targets {
+		t := &report.Targets[i]
+		args := strings.Fields(cfg.Compile[t.Name])
+		if !t.OK || len(args) == 0 {
+			continue
+		}
+		fmt.Fprintf(genLog, "Compiling %s: %s\n", t.Name, strings.Join(args, " "))
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout, cmd.Stderr = genLog, genLog
+		start := time.Now()
+		if err := cmd.Run(); err != nil {
+			report.warn("compile command of %s failed: %v", t.Name, err)
+			continue
+		}
+		t.Compile = time.Since(start)
+	}
+}
+
+// printSizes prints the size of the code of every target, with its change since the last
+// generation and its compile time, when known
+func printSizes(report *genReport) {
+	w := tabwriter.NewWriter(genLog, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tFILES\tLINES\tSIZE\tCHANGE\tCOMPILE")
+	for _, t := range report.Targets {
+		if t.Size == nil {
+			continue
+		}
+		change, compile := "-", "-"
+		if t.Previous != nil {
+			change = fmt.Sprintf("%+d lines", t.Size.Lines-t.Previous.Lines)
+		}
+		if t.Compile > 0 {
+			compile = t.Compile.Round(10 * time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", t.Name, t.Size.Files, t.Size.Lines, byteSize(t.Size.Bytes), change, compile)
+	}
+	w.Flush()
+}
+
+// percent prints the growth of ratio, e.g. "38%"
+func percent(ratio float64) string {
+	return fmt.Sprintf("%.0f%%", (ratio-1)*100)
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
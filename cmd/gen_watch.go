@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/watch"
+	"github.com/spf13/cobra"
+)
+
+// watchGen runs gen, then again whenever the schema, one of its imports or another proto file gen
+// reads changes, until ctx is done (gen stops it when interrupted). Every run ends with a summary
+// line, and a failed run keeps the watch going so the next save can fix it.
+func watchGen(ctx context.Context, cmd *cobra.Command) {
+	run := 0
+	var watched []string
+	regenerate := func() {
+		run++
+		start := time.Now()
+		report, result := generate(cmd)
+		report.print()
+		// A configuration gen cannot load is not fixed by editing the schema
+		if run == 1 && report.ExitCode == exitConfig {
+			os.Exit(exitConfig)
+		}
+		printRunSummary(run, report, time.Since(start))
+
+		// A schema that does not parse keeps the files of the last one watched, and its own
+		if result != nil {
+			watched = watchedFiles(result)
+		} else if !slices.Contains(watched, schemaFile) {
+			watched = append(watched, schemaFile)
+		}
+	}
+
+	regenerate()
+	fmt.Fprintf(genLog, "Watching %s and its imports for changes (Ctrl+C to stop)...\n", schemaFile)
+	// Poll calls watched and regenerate from the same goroutine, so watched needs no locking
+	watch.Poll(ctx, func() []string { return watched }, genDebounce, func() {
+		fmt.Fprintln(genLog)
+		fmt.Fprintln(genLog, "Change detected; regenerating...")
+		regenerate()
+	})
+}
+
+// watchedFiles returns the proto files a run of gen reads: the schema of result and its imports,
+// the schemas of the configuration and the --internal schema
+func watchedFiles(result *parser.ParseResult) []string {
+	paths := append([]string{schemaFile}, result.Schema.FilePaths()...)
+	if cfg, err := config.Load(configFile); err == nil {
+		for _, schema := range cfg.Schemas {
+			paths = append(paths, schema.File)
+		}
+	}
+	if internal != "" {
+		paths = append(paths, internal)
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// printRunSummary prints the outcome of a run of gen --watch on one line
+func printRunSummary(run int, report *genReport, elapsed time.Duration) {
+	var generated []string
+	for _, t := range report.Targets {
+		if t.OK {
+			generated = append(generated, t.Name)
+		}
+	}
+
+	summary := fmt.Sprintf("[%s] Run %d ", time.Now().Format("15:04:05"), run)
+	if report.OK {
+		summary += fmt.Sprintf("generated %s in %s: %d file(s) written", strings.Join(generated, ", "), elapsed.Round(time.Millisecond), len(report.Files))
+	} else {
+		// Compiler errors span lines
+		summary += fmt.Sprintf("failed in %s: %s", elapsed.Round(time.Millisecond), strings.Join(strings.Fields(report.Errors[0]), " "))
+		if len(report.Errors) > 1 {
+			summary += fmt.Sprintf(" (and %d more error(s))", len(report.Errors)-1)
+		}
+		if len(generated) > 0 {
+			summary += "; generated " + strings.Join(generated, ", ")
+		}
+	}
+	if len(report.Warnings) > 0 {
+		summary += fmt.Sprintf(", %d warning(s)", len(report.Warnings))
+	}
+	fmt.Fprintln(genLog, summary)
+}
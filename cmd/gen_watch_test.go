@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// importingSchema is envelopeSchema with its Header imported from common/header.proto
+var importingSchema = strings.Replace(envelopeSchema, "message Header {\n  uint32 seq = 1;\n}\n", `import "common/header.proto";`+"\n", 1)
+
+const headerSchema = "syntax = \"proto3\";\npackage packet;\n\nmessage Header {\n  uint32 seq = 1;\n}\n"
+
+// syncBuffer is a bytes.Buffer watchGen can write while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// watchProject makes dir the working directory and resolves the schema from its configuration
+// like socketgen does; the settings and the gen flags are restored afterwards
+func watchProject(t *testing.T, dir string) {
+	t.Helper()
+	t.Chdir(dir)
+	file, paths, wrapper, cacheDir := schemaFile, parser.ImportPaths, parser.Wrapper, parser.CacheDir
+	langs, out, in, debounce, log := languages, outDir, internal, genDebounce, genLog
+	t.Cleanup(func() {
+		schemaFile, parser.ImportPaths, parser.Wrapper, parser.CacheDir = file, paths, wrapper, cacheDir
+		languages, outDir, internal, genDebounce, genLog = langs, out, in, debounce, log
+		resetFlags(rootCmd)
+	})
+	parser.CacheDir = ""
+	// Parsing no arguments merges the flags of socketgen into those of gen, as running it does
+	if err := genCmd.ParseFlags(nil); err != nil {
+		t.Fatal(err)
+	}
+	resolveSchema(genCmd)
+}
+
+func TestWatchedFiles(t *testing.T) {
+	watchProject(t, writeProject(t, map[string]string{
+		"proto/packet.proto":        importingSchema,
+		"proto/common/header.proto": headerSchema,
+		"socketgen.yaml":            "gen:\n  proto: proto/packet.proto\n  proto_path: proto\n  wrapper: Envelope\nschemas:\n  social: proto/social.proto\n",
+	}))
+	internal = "proto/internal.proto"
+
+	result, err := parser.Parse(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := watchedFiles(result)
+	for _, want := range []string{"proto/packet.proto", "proto/common/header.proto", "proto/social.proto", "proto/internal.proto"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("watched %v, want %s among them", got, want)
+		}
+	}
+	if !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != len(got) {
+		t.Errorf("watched %v, want every file once, sorted", got)
+	}
+}
+
+func TestWatchGen(t *testing.T) {
+	watchProject(t, writeProject(t, map[string]string{
+		"packet.proto":        importingSchema,
+		"common/header.proto": headerSchema,
+		"socketgen.yaml":      "gen:\n  wrapper: Envelope\n  lang: [go]\n  out: ./gen\n",
+	}))
+	genDebounce = 20 * time.Millisecond
+	log := &syncBuffer{}
+	genLog = log
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchGen(ctx, genCmd)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	waitFor := func(what string) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !strings.Contains(log.String(), what) {
+			if time.Now().After(deadline) {
+				t.Fatalf("gen --watch did not print %q:\n%s", what, log.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor("Run 1 generated go")
+	waitFor("Watching packet.proto")
+	// A change to an import regenerates, once for several saves
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	header := strings.Replace(headerSchema, "uint32 seq = 1;", "uint32 seq = 1;\n  string request_id = 2;", 1)
+	write("common/header.proto", header)
+	write("common/header.proto", header+"\n")
+	waitFor("Run 2 generated go")
+	data, err := os.ReadFile("gen/packet_descriptor.pb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("request_id")) {
+		t.Error("Run 2 did not generate the Header imported from common/header.proto again")
+	}
+
+	// A schema that does not compile fails the run, and the watch goes on
+	write("packet.proto", importingSchema+"message {\n")
+	waitFor("Run 3 failed")
+	write("packet.proto", importingSchema)
+	waitFor("Run 4 generated go")
+	time.Sleep(10 * genDebounce)
+	if strings.Contains(log.String(), "Run 5") {
+		t.Errorf("gen --watch ran again with nothing changed:\n%s", log.String())
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("gen --watch did not stop once its context was done")
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/socketgen/generator"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphFormat  string
+	graphOut     string
+	graphPayload []string
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print a graph of the payloads and the messages they share",
+	Long: `Renders the payloads of packet.proto as a DOT or mermaid graph: the sub-messages each
+payload is made of, with those several payloads share highlighted, and the responds_with and
+superseded_by edges between payloads. Render DOT with Graphviz (dot -Tsvg); mermaid renders in
+GitHub Markdown.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(generator.GraphFormats, graphFormat) {
+			fmt.Printf("Error: unknown graph format %q; expected one of %s\n", graphFormat, strings.Join(generator.GraphFormats, ", "))
+			os.Exit(1)
+		}
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+
+		var w io.Writer = os.Stdout
+		if graphOut != "" {
+			f, err := os.Create(graphOut)
+			if err != nil {
+				fmt.Printf("Error creating %s: %v\n", graphOut, err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if err := generator.GenerateGraph(result, graphFormat, graphPayload, w); err != nil {
+			fmt.Printf("Error generating graph: %v\n", err)
+			os.Exit(1)
+		}
+		if graphOut != "" {
+			fmt.Printf("Wrote %s\n", graphOut)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().StringVar(&graphFormat, "format", "dot", "Graph format ("+strings.Join(generator.GraphFormats, ", ")+")")
+	graphCmd.Flags().StringVarP(&graphOut, "out", "o", "", "File to write the graph to (default stdout)")
+	graphCmd.Flags().StringSliceVar(&graphPayload, "payload", nil, "Limit the graph to these payloads, by type or field name, and those they respond with or are superseded by")
+}
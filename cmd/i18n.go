@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	i18nLocales []string
+	i18nDir     string
+)
+
+var i18nCmd = &cobra.Command{
+	Use:   "i18n",
+	Short: "Scaffold the message catalogs of the text codes in packet.proto",
+	Long: `Writes a message catalog per locale, <dir>/<locale>.json, with the text codes of the enums of
+fields annotated with option (socketgen.text_code) in packet.proto. The catalogs map codes to
+texts; the code generated for TypeScript, C# and Dart looks them up.
+
+Catalogs that exist are completed, never overwritten: codes new to the schema are added, and
+texts already written are kept. In the first locale, a new code gets the comment of its enum
+value as text; in the others, it is left empty for translators.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+
+		codes := result.TextCodes()
+		if len(codes.Fields) == 0 {
+			fmt.Printf("No field of %s is annotated with option (socketgen.text_code).\n", schemaFile)
+			return
+		}
+		if err := os.MkdirAll(i18nDir, 0755); err != nil {
+			fmt.Printf("Error creating %s: %v\n", i18nDir, err)
+			os.Exit(1)
+		}
+
+		for i, locale := range i18nLocales {
+			path := filepath.Join(i18nDir, locale+".json")
+			added, err := scaffoldCatalog(path, codes, i == 0)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s: %d code(s) added\n", path, added)
+		}
+	},
+}
+
+// scaffoldCatalog adds the codes missing from the catalog at path, creating it if needed, and
+// returns how many it added. With source, a new code gets the comment of its enum value as text.
+func scaffoldCatalog(path string, codes parser.TextCodes, source bool) (int, error) {
+	texts := map[string]string{}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &texts); err != nil {
+			return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return 0, err
+	}
+
+	added := 0
+	for _, enum := range codes.Enums {
+		for _, code := range enum.Codes {
+			if _, ok := texts[code.Code]; ok {
+				continue
+			}
+			text := ""
+			if source {
+				text, _, _ = strings.Cut(code.Comment, "\n\n")
+				text = strings.ReplaceAll(text, "\n", " ")
+			}
+			texts[code.Code] = text
+			added++
+		}
+	}
+	if added == 0 && data != nil {
+		return 0, nil
+	}
+
+	// Keys are written sorted, so catalogs diff well, and texts as translators wrote them
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(texts); err != nil {
+		return 0, err
+	}
+	return added, os.WriteFile(path, b.Bytes(), 0644)
+}
+
+func init() {
+	rootCmd.AddCommand(i18nCmd)
+
+	i18nCmd.Flags().StringSliceVar(&i18nLocales, "locales", []string{"en"}, "Locales to scaffold catalogs for; the first is the source locale")
+	i18nCmd.Flags().StringVar(&i18nDir, "dir", "i18n", "Directory of the catalogs")
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/generator"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ideLanguages []string
+	ideDir       string
+)
+
+// ideTaskPrefix starts the labels of the tasks socketgen ide vscode writes, which it replaces when
+// run again
+const ideTaskPrefix = "socketgen: "
+
+// protoErrorMatcher turns the errors of the proto compiler (packet.proto:12:3: message) into
+// problems of the editor
+var protoErrorMatcher = map[string]any{
+	"owner":        "socketgen",
+	"fileLocation": []string{"relative", "${workspaceFolder}"},
+	"pattern": map[string]any{
+		"regexp":   `^(.+\.proto):(\d+):(\d+): (.*)$`,
+		"file":     1,
+		"line":     2,
+		"column":   3,
+		"message":  4,
+		"severity": "error",
+	},
+}
+
+var ideCmd = &cobra.Command{
+	Use:   "ide",
+	Short: "Set up editors for the project",
+}
+
+var ideVSCodeCmd = &cobra.Command{
+	Use:   "vscode",
+	Short: "Write VS Code snippets of the handlers of every payload, and tasks running socketgen",
+	Long: `Writes .vscode/socketgen.code-snippets, with a snippet per payload and language stubbing its
+handler method (type the handler name, e.g. onChatMsg, to insert it), and adds tasks to
+.vscode/tasks.json running socketgen gen, socketgen lint and socketgen gen --watch, with the
+schema, include directories and envelope used here. Errors of the proto compiler show up as
+problems of the files they are in.
+
+The schema, its include directories, its envelope and the languages come from the gen section of
+socketgen.yaml, if any. Run it again after adding payloads; tasks of other tools in tasks.json are
+kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		languages := ideLanguages
+		if !cmd.Flags().Changed("lang") && cfg.Gen["lang"] != "" {
+			languages = strings.Split(cfg.Gen["lang"], ",")
+		}
+		languages = slices.DeleteFunc(slices.Clone(languages), func(lang string) bool {
+			if !generator.HasVSCodeSnippets(lang) {
+				fmt.Printf("Warning: Language '%s' is not supported; no snippets for it.\n", lang)
+				return true
+			}
+			return false
+		})
+
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+
+		if err := generator.GenerateVSCodeSnippets(result, languages, ideDir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d handler snippets for %s to %s.\n", len(result.Payloads)*len(languages), strings.Join(languages, ", "), filepath.Join(ideDir, generator.SnippetsFile))
+
+		// gen takes the languages from the configuration when it sets them
+		gen := "socketgen gen" + schemaArgs()
+		if cfg.Gen["lang"] == "" {
+			gen += " --lang " + strings.Join(languages, ",")
+		}
+		tasks := []map[string]any{
+			{"label": ideTaskPrefix + "gen", "type": "shell", "command": gen, "group": "build", "problemMatcher": protoErrorMatcher},
+			{"label": ideTaskPrefix + "lint", "type": "shell", "command": "socketgen lint" + schemaArgs(), "problemMatcher": protoErrorMatcher},
+			{"label": ideTaskPrefix + "watch", "type": "shell", "command": strings.Replace(gen, "socketgen gen", "socketgen gen --watch", 1), "isBackground": true, "problemMatcher": []any{}},
+		}
+		path := filepath.Join(ideDir, "tasks.json")
+		if err := mergeTasks(path, tasks); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added tasks %s, %s and %s to %s.\n", tasks[0]["label"], tasks[1]["label"], tasks[2]["label"], path)
+	},
+}
+
+// schemaArgs returns the flags naming the schema, its include directories and its envelope, when
+// they are not the defaults, so the tasks read the schema this command read
+func schemaArgs() string {
+	var args string
+	if schemaFile != "packet.proto" {
+		args += " --proto " + schemaFile
+	}
+	for _, dir := range parser.ImportPaths {
+		args += " -I " + dir
+	}
+	if parser.Wrapper != parser.DefaultWrapper {
+		args += " --wrapper " + parser.Wrapper
+	}
+	return args
+}
+
+// mergeTasks writes tasks to the tasks.json at path, replacing the tasks written by an earlier run
+// and keeping the others
+func mergeTasks(path string, tasks []map[string]any) error {
+	file := map[string]any{"version": "2.0.0"}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		// tasks.json may have comments, which encoding/json cannot read; they would be lost anyway
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to read %s (remove its comments, or add the tasks by hand): %w", path, err)
+		}
+	}
+
+	existing, _ := file["tasks"].([]any)
+	merged := make([]any, 0, len(existing)+len(tasks))
+	for _, task := range existing {
+		if t, ok := task.(map[string]any); ok {
+			if label, _ := t["label"].(string); strings.HasPrefix(label, ideTaskPrefix) {
+				continue
+			}
+		}
+		merged = append(merged, task)
+	}
+	for _, task := range tasks {
+		merged = append(merged, task)
+	}
+	file["tasks"] = merged
+
+	out, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0644)
+}
+
+func init() {
+	rootCmd.AddCommand(ideCmd)
+	ideCmd.AddCommand(ideVSCodeCmd)
+
+	ideVSCodeCmd.Flags().StringSliceVar(&ideLanguages, "lang", []string{"go", "ts", "python", "csharp", "dart", "php", "ruby", "kotlin", "java"}, "Languages to write handler snippets for (default: the languages of the gen section of socketgen.yaml, or all)")
+	ideVSCodeCmd.Flags().StringVar(&ideDir, "dir", ".vscode", "Directory of the VS Code workspace settings")
+}
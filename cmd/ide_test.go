@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIDEVSCodeTasksUseConfiguredSchema(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"proto/network/packet.proto": envelopeSchema,
+		"socketgen.yaml":             "gen:\n  proto: proto/network/packet.proto\n  wrapper: Envelope\n  lang: go\n",
+	})
+	runCommand(t, dir, "ide", "vscode")
+
+	data, err := os.ReadFile(filepath.Join(dir, ".vscode", "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Tasks []struct {
+			Label   string `json:"label"`
+			Command string `json:"command"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"socketgen: gen":   "socketgen gen --proto proto/network/packet.proto --wrapper Envelope",
+		"socketgen: lint":  "socketgen lint --proto proto/network/packet.proto --wrapper Envelope",
+		"socketgen: watch": "socketgen gen --watch --proto proto/network/packet.proto --wrapper Envelope",
+	}
+	for _, task := range file.Tasks {
+		if task.Command != want[task.Label] {
+			t.Errorf("task %s runs %q, want %q", task.Label, task.Command, want[task.Label])
+		}
+		delete(want, task.Label)
+	}
+	for label := range want {
+		t.Errorf("task %s is missing", label)
+	}
+}
@@ -3,20 +3,26 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/options"
 	"github.com/spf13/cobra"
 )
 
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize the project with a basic packet.proto",
-	Long:  `Creates a 'packet.proto' file with the standard structure required by SocketGen.`,
+	Long: `Creates a 'packet.proto' file (or the --proto file) with the standard structure required by SocketGen, and a
+'socketgen.yaml' setting the flags of socketgen gen for the team.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		content := `syntax = "proto3";
 package packet;
 
 option go_package = "./;packet";
 
+import "google/protobuf/any.proto";
+
 // [헤더]: 모든 패킷에 포함될 메타데이터
 message Header {
   int64 timestamp = 1;
@@ -28,31 +34,70 @@ message LoginReq { string id = 1; string pw = 2; }
 message LoginRes { bool success = 1; }
 message ChatMsg  { string text = 1; }
 
+// [에러]: 요청이 실패했을 때 서버가 보내는 표준 응답 (request_id로 요청과 연결됩니다)
+enum ErrorCode {
+  ERROR_CODE_UNSPECIFIED = 0;
+  ERROR_CODE_BAD_REQUEST = 1;
+  ERROR_CODE_UNAUTHENTICATED = 2;
+  ERROR_CODE_FORBIDDEN = 3;
+  ERROR_CODE_NOT_FOUND = 4;
+  ERROR_CODE_RATE_LIMITED = 5;
+  ERROR_CODE_INTERNAL = 6;
+}
+message ErrorRes {
+  ErrorCode code = 1;
+  string message = 2;
+  google.protobuf.Any details = 3;
+}
+
 // [패킷 래퍼]: 네트워크 전송 단위
 message GamePacket {
   Header header = 1;
 
   // 도구는 이 'oneof'를 파싱하여 분기문을 작성합니다.
   oneof payload {
+    ErrorRes error_res = 9;
     LoginReq login_req = 10;
     LoginRes login_res = 11;
     ChatMsg chat_msg = 12;
   }
 }
 `
-		filename := "packet.proto"
+		filename := schemaFile
 		if _, err := os.Stat(filename); err == nil {
 			fmt.Printf("Error: '%s' already exists.\n", filename)
 			return
 		}
 
-		err := os.WriteFile(filename, []byte(content), 0644)
+		err := os.MkdirAll(filepath.Dir(filename), 0755)
+		if err == nil {
+			err = os.WriteFile(filename, []byte(content), 0644)
+		}
 		if err != nil {
 			fmt.Printf("Error creating file: %v\n", err)
 			return
 		}
 
 		fmt.Printf("Created '%s' with basic structure.\n", filename)
+
+		// The flags of gen, shared by the team
+		if _, err := os.Stat(config.DefaultFile); err != nil {
+			if err := os.WriteFile(config.DefaultFile, []byte(config.Template), 0644); err != nil {
+				fmt.Printf("Error creating configuration: %v\n", err)
+				return
+			}
+			fmt.Printf("Created '%s' with the flags of socketgen gen.\n", config.DefaultFile)
+		}
+
+		// Custom options (e.g., socketgen.feature) become available with `import "socketgen/options.proto";`
+		if _, err := os.Stat(options.ImportPath); err == nil {
+			return
+		}
+		if _, err := options.Vendor("."); err != nil {
+			fmt.Printf("Error creating options file: %v\n", err)
+			return
+		}
+		fmt.Printf("Created '%s' with socketgen's custom options.\n", options.ImportPath)
 	},
 }
 
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/generator"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+// exitProblems is the exit status of lint when the schema has problems; a configuration or schema
+// that cannot be loaded exits with the status gen exits with
+const exitProblems = 1
+
+var lintCmd = &cobra.Command{
+	Use:     "lint",
+	Aliases: []string{"check"},
+	Short:   "Check packet.proto against socketgen's rules and the project configuration",
+	Long: `Reports payloads the generated code cannot refer to, and payloads numbered outside the
+ranges declared in socketgen.yaml. Exits with status 1 if there are problems, 2 if the
+configuration cannot be loaded and 3 if the schema does not compile. With --json, prints the
+report of gen --json, listing the problems as errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if genJSON {
+			// Progress goes to stderr, so stdout holds nothing but the report
+			genLog = os.Stderr
+		}
+		lint().finish()
+	},
+}
+
+// lint checks the schema and returns the report of the check, in the shape of the report of gen
+func lint() *genReport {
+	report := &genReport{Payloads: []genPayload{}, Targets: []genTarget{}, Files: []string{}, Warnings: []string{}, Errors: []string{}}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		report.fail(exitConfig, "%v", err)
+		return report
+	}
+	result, err := parser.Parse(schemaFile)
+	if err != nil {
+		report.fail(exitParse, "Failed to parse %s: %v", schemaFile, err)
+		return report
+	}
+	report.Package, report.SchemaVersion = result.PackageName, result.SchemaVersion
+	for _, p := range result.Payloads {
+		report.Payloads = append(report.Payloads, genPayload{p.Name, p.FieldName, p.FullName, p.Number})
+	}
+
+	problems := lintSchema(result, cfg)
+	for _, problem := range problems {
+		msg := fmt.Sprintf("%s: %s", schemaFile, problem)
+		fmt.Fprintln(genLog, msg)
+		report.Errors = append(report.Errors, msg)
+	}
+	if len(problems) > 0 {
+		report.ExitCode = exitProblems
+		fmt.Fprintf(genLog, "%d problem(s) found.\n", len(problems))
+		return report
+	}
+	fmt.Fprintln(genLog, "No problems found.")
+	return report
+}
+
+// lintSchema lists the problems of result that gen warns about as well
+func lintSchema(result *parser.ParseResult, cfg *config.Config) []string {
+	problems := generator.IdentifierWarnings(result)
+	return append(problems, rangeWarnings(result, cfg.Ranges)...)
+}
+
+// rangeWarnings lists the payloads numbered outside every range, when ranges are declared
+func rangeWarnings(result *parser.ParseResult, ranges config.Ranges) []string {
+	if len(ranges) == 0 {
+		return nil
+	}
+	var warnings []string
+	for _, p := range result.Payloads {
+		if _, ok := ranges.Of(p.Number); !ok {
+			warnings = append(warnings, fmt.Sprintf("payload %s is numbered %d, outside the ranges of %s (%s); extend a range to cover it", p.FieldName, p.Number, configFile, strings.Join(rangeNames(ranges), ", ")))
+		}
+	}
+	return warnings
+}
+
+func rangeNames(ranges config.Ranges) []string {
+	names := make([]string, len(ranges))
+	for i, r := range ranges {
+		names[i] = r.String()
+	}
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().BoolVar(&genJSON, "json", false, "Print the report of gen --json, with the problems as errors, to stdout; progress goes to stderr")
+}
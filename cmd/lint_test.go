@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLintJSONReport(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"packet.proto":   envelopeSchema,
+		"socketgen.yaml": "gen:\n  wrapper: Envelope\nranges:\n  auth: 10-19\n",
+	})
+	for _, name := range []string{"lint", "check"} {
+		t.Run(name, func(t *testing.T) {
+			out := runCommand(t, dir, name, "--json")
+			var report genReport
+			if err := json.Unmarshal([]byte(out), &report); err != nil {
+				t.Fatalf("%s --json printed more than the report: %v\n%s", name, err, out)
+			}
+			if !report.OK || report.ExitCode != 0 || len(report.Errors) != 0 {
+				t.Errorf("got ok %v, exit code %d and errors %v, want a passing check", report.OK, report.ExitCode, report.Errors)
+			}
+			if report.Package != "packet" || len(report.Payloads) != 1 || report.Payloads[0].Field != "login_req" {
+				t.Errorf("got package %q and payloads %+v, want login_req of packet", report.Package, report.Payloads)
+			}
+		})
+	}
+}
+
+func TestLintReportsPayloadsOutsideRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges string
+		errors []string
+	}{
+		{"no ranges", "", nil},
+		{"inside", "ranges:\n  auth: 10-19\n  chat: 20-39\n", nil},
+		{"outside", "ranges:\n  auth: 11-19\n  chat: 20-39\n", []string{"packet.proto: payload login_req is numbered 10, outside the ranges of socketgen.yaml (auth (11-19), chat (20-39)); extend a range to cover it"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(writeProject(t, map[string]string{
+				"packet.proto":   strings.ReplaceAll(envelopeSchema, "Envelope", "GamePacket"),
+				"socketgen.yaml": tt.ranges,
+			}))
+			log := genLog
+			genLog = io.Discard
+			t.Cleanup(func() { genLog = log })
+
+			// The command would exit with the status of the report
+			report := lint()
+			if !slices.Equal(report.Errors, tt.errors) {
+				t.Errorf("got errors %q, want %q", report.Errors, tt.errors)
+			}
+			if wantCode := min(len(tt.errors), exitProblems); report.ExitCode != wantCode {
+				t.Errorf("got exit code %d, want %d", report.ExitCode, wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/snowmerak/socketgen/generator"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	packageLangs   []string
+	packageFrom    string
+	packageOut     string
+	packageName    string
+	packageVersion string
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Wrap generated client code into a publishable package",
+	Long: `Copies the code gen generated for each language given, with its protoc bindings, into a package
+scaffold in <out>/<lang>: package.json and tsconfig.json for ts, a .csproj for csharp, and
+pubspec.yaml for dart. The package records the schema version of packet.proto and depends on the
+packages the code imports.
+
+Without --version, a new package starts at 0.1.0, and an existing one keeps its version while the
+schema is unchanged and gets the next minor version when it changed, so every schema has its own
+package version.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+		m, err := loadManifest(packageFrom)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", filepath.Join(packageFrom, manifestFile), err)
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, lang := range packageLangs {
+			if err := packageLanguage(result, m, lang); err != nil {
+				fmt.Printf("Error packaging %s: %v\n", lang, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func packageLanguage(result *parser.ParseResult, m *manifest, lang string) error {
+	if !slices.Contains(generator.PackageLanguages(), lang) {
+		return fmt.Errorf("unsupported language; expected one of %s", strings.Join(generator.PackageLanguages(), ", "))
+	}
+
+	// The code of the language and the bindings protoc generated for it
+	ext := generator.PackageSourceExt(lang)
+	var sources []string
+	for _, target := range []string{"protoc", lang} {
+		for _, e := range m.Targets[target] {
+			if path.Ext(e.Path) == ext {
+				sources = append(sources, e.Path)
+			}
+		}
+	}
+	if !slices.ContainsFunc(m.Targets[lang], func(e manifestEntry) bool { return path.Ext(e.Path) == ext }) {
+		return fmt.Errorf("no %s code in %s; run 'socketgen gen --lang %s --out %s' first", lang, packageFrom, lang, packageFrom)
+	}
+	if !slices.ContainsFunc(m.Targets["protoc"], func(e manifestEntry) bool { return path.Ext(e.Path) == ext }) {
+		fmt.Printf("Warning: %s has no protoc bindings for %s, which the package needs; run gen with --protoc\n", packageFrom, lang)
+	}
+	if err := checkPackageSchema(result, lang, sources); err != nil {
+		return err
+	}
+
+	info := generator.PackageInfo{Name: packageName, Version: packageVersion}
+	if info.Name == "" {
+		info.Name = generator.DefaultPackageName(result, lang)
+	}
+	dir := filepath.Join(packageOut, lang)
+	previous, schema, err := generator.ReadPackageVersion(lang, dir, info)
+	if err != nil {
+		return err
+	}
+	if info.Version, err = nextPackageVersion(previous, schema, result.SchemaVersion); err != nil {
+		return err
+	}
+
+	if err := generator.GeneratePackage(result, lang, dir, packageFrom, sources, info); err != nil {
+		return err
+	}
+	fmt.Printf("Packaged %s %s (schema version %s, %d files) in %s\n", info.Name, info.Version, result.SchemaVersion, len(sources), dir)
+	return nil
+}
+
+// checkPackageSchema makes sure the dispatcher was generated from the current schema, so the
+// package does not record a schema version its code does not speak. The Dart dispatcher does not
+// embed the version, so Dart code is not checked.
+func checkPackageSchema(result *parser.ParseResult, lang string, sources []string) error {
+	dispatcher := map[string]string{"ts": "PacketDispatcher.ts", "csharp": "PacketDispatcher.cs"}[lang]
+	if dispatcher == "" || !slices.Contains(sources, dispatcher) {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(packageFrom, dispatcher))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), `"`+result.SchemaVersion+`"`) {
+		return fmt.Errorf("%s was generated from another schema than %s (%s); run 'socketgen gen' first", filepath.Join(packageFrom, dispatcher), schemaFile, result.SchemaVersion)
+	}
+	return nil
+}
+
+var semver = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)(?:[-+][0-9A-Za-z.+-]*)?$`)
+
+// nextPackageVersion returns the version of a package of schemaVersion, given the version and
+// schema version of the existing package (empty if there is none) and --version
+func nextPackageVersion(previous, previousSchema, schemaVersion string) (string, error) {
+	if packageVersion != "" {
+		if !semver.MatchString(packageVersion) {
+			return "", fmt.Errorf("--version %q is not a semantic version (e.g. 1.2.0)", packageVersion)
+		}
+		if packageVersion == previous && previousSchema != "" && previousSchema != schemaVersion {
+			return "", fmt.Errorf("the schema changed since version %s was packaged; give it a new --version", previous)
+		}
+		return packageVersion, nil
+	}
+
+	if previous == "" {
+		return "0.1.0", nil
+	}
+	if previousSchema == schemaVersion {
+		return previous, nil
+	}
+	parts := semver.FindStringSubmatch(previous)
+	if parts == nil {
+		return "", fmt.Errorf("the schema changed since version %s was packaged, which is not a semantic version; give a new --version", previous)
+	}
+	major, _ := strconv.Atoi(parts[1])
+	minor, _ := strconv.Atoi(parts[2])
+	return fmt.Sprintf("%d.%d.0", major, minor+1), nil
+}
+
+func init() {
+	rootCmd.AddCommand(packageCmd)
+
+	packageCmd.Flags().StringSliceVar(&packageLangs, "lang", nil, "Languages to package (ts, csharp, dart)")
+	packageCmd.Flags().StringVar(&packageFrom, "from", "./gen", "Output directory of gen holding the generated code")
+	packageCmd.Flags().StringVar(&packageOut, "out", "./sdk", "Directory the packages are written to, one per language")
+	packageCmd.Flags().StringVar(&packageName, "name", "", "Package name (default: <package>-client, <Package>.Client or <package>_client)")
+	packageCmd.Flags().StringVar(&packageVersion, "version", "", "Package version (default: the existing version, with the next minor version if the schema changed)")
+	packageCmd.MarkFlagRequired("lang")
+}
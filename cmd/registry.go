@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/registry"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	registryURL   string
+	registryToken string
+	registryName  string
+	registryTag   string
+	registryOut   string
+	registryAddr  string
+	registryDir   string
+)
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Push and pull schemas to a schema registry",
+	Long: `Stores the compiled descriptor set of packet.proto in a simple HTTP schema registry under a
+version tag, and fetches it back for tools that need the schema without the proto sources.`,
+}
+
+var registryPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Upload the descriptor set of packet.proto under a version tag",
+	Run: func(cmd *cobra.Command, args []string) {
+		fds, err := parser.LoadDescriptorSet(schemaFile)
+		if err != nil {
+			fmt.Printf("Error compiling %s: %v\n", schemaFile, err)
+			return
+		}
+
+		name := registryName
+		if name == "" {
+			result, err := parser.Parse(schemaFile)
+			if err != nil {
+				fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+				return
+			}
+			name = result.PackageName
+		}
+
+		version := parser.Fingerprint(fds)
+		client := registry.NewClient(registryURL, registryToken)
+		if err := client.Push(name, registryTag, version, fds); err != nil {
+			fmt.Printf("Error pushing schema: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Pushed %s:%s (schema version %s)\n", name, registryTag, version)
+	},
+}
+
+var registryPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Download a descriptor set by version tag",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := registry.NewClient(registryURL, registryToken)
+		fds, version, err := client.Pull(registryName, registryTag)
+		if err != nil {
+			fmt.Printf("Error pulling schema: %v\n", err)
+			return
+		}
+
+		data, err := proto.Marshal(fds)
+		if err != nil {
+			fmt.Printf("Error encoding descriptor set: %v\n", err)
+			return
+		}
+		if err := os.WriteFile(registryOut, data, 0644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", registryOut, err)
+			return
+		}
+
+		fmt.Printf("Pulled %s:%s (schema version %s) into %s\n", registryName, registryTag, version, registryOut)
+	},
+}
+
+var registryTagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "List the version tags stored for a schema",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := registry.NewClient(registryURL, registryToken)
+		tags, err := client.Tags(registryName)
+		if err != nil {
+			fmt.Printf("Error listing tags: %v\n", err)
+			return
+		}
+
+		for _, tag := range tags {
+			fmt.Println(tag)
+		}
+	},
+}
+
+var registryServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a simple file-backed schema registry",
+	Run: func(cmd *cobra.Command, args []string) {
+		srv := &registry.Server{Dir: registryDir, Token: registryToken}
+
+		fmt.Printf("Schema registry listening on http://%s (storage: %s)\n", registryAddr, registryDir)
+		if err := http.ListenAndServe(registryAddr, srv.Handler()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(registryCmd)
+	registryCmd.AddCommand(registryPushCmd, registryPullCmd, registryTagsCmd, registryServeCmd)
+
+	registryCmd.PersistentFlags().StringVar(&registryURL, "url", "http://localhost:8090", "Registry base URL")
+	registryCmd.PersistentFlags().StringVar(&registryToken, "token", os.Getenv("SOCKETGEN_REGISTRY_TOKEN"), "Registry bearer token (default $SOCKETGEN_REGISTRY_TOKEN)")
+
+	registryPushCmd.Flags().StringVar(&registryName, "name", "", "Schema name (default: proto package name)")
+	registryPushCmd.Flags().StringVar(&registryTag, "tag", "", "Version tag (e.g. v1.2.0)")
+	registryPushCmd.MarkFlagRequired("tag")
+
+	registryPullCmd.Flags().StringVar(&registryName, "name", "", "Schema name")
+	registryPullCmd.Flags().StringVar(&registryTag, "tag", "", "Version tag")
+	registryPullCmd.Flags().StringVar(&registryOut, "out", "schema.pb", "Output file for the descriptor set")
+	registryPullCmd.MarkFlagRequired("name")
+	registryPullCmd.MarkFlagRequired("tag")
+
+	registryTagsCmd.Flags().StringVar(&registryName, "name", "", "Schema name")
+	registryTagsCmd.MarkFlagRequired("name")
+
+	registryServeCmd.Flags().StringVar(&registryAddr, "addr", "localhost:8090", "Address to listen on")
+	registryServeCmd.Flags().StringVar(&registryDir, "dir", "./registry", "Directory to store schemas in")
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
 
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
 	"github.com/spf13/cobra"
 )
 
@@ -11,6 +14,9 @@ var rootCmd = &cobra.Command{
 	Short: "SocketGen is a CLI tool for generating WebSocket packet dispatchers",
 	Long: `SocketGen automates the creation of message routing (Dispatcher) and handler interfaces 
 based on Protobuf definitions for Go, TypeScript, Python, and C#.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		resolveSchema(cmd)
+	},
 }
 
 func Execute() {
@@ -22,4 +28,32 @@ func Execute() {
 
 func init() {
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", config.DefaultFile, "Project configuration file (optional)")
+	rootCmd.PersistentFlags().StringVar(&schemaFile, "proto", "packet.proto", "Proto file defining GamePacket, or the --wrapper envelope (e.g. proto/network/packet.proto); its imports are resolved from --proto_path, or the working directory (default: gen.proto of socketgen.yaml, or packet.proto)")
+	rootCmd.PersistentFlags().StringVar(&parser.CacheDir, "cache-dir", defaultCacheDir(), "Directory caching the compiled descriptor sets, keyed by proto file content ($SOCKETGEN_CACHE_DIR; '' disables the cache)")
+	rootCmd.PersistentFlags().StringSliceVarP(&parser.ImportPaths, "proto_path", "I", nil, "Directory imports are resolved from, like protoc's -I; repeatable, searched in order (default: gen.proto_path of socketgen.yaml, or the working directory)")
+	rootCmd.PersistentFlags().StringVar(&parser.Wrapper, "wrapper", parser.DefaultWrapper, "Name of the envelope message carrying the header and the payload oneof, for schemas naming it otherwise (e.g. Envelope; default: gen.wrapper of socketgen.yaml, or GamePacket)")
+	rootCmd.PersistentFlags().StringVar(&parser.Compiler, "compiler", defaultCompiler(), "Compiler of the proto files: builtin (in process) or protoc ($SOCKETGEN_COMPILER)")
+}
+
+// defaultCompiler returns $SOCKETGEN_COMPILER, or the built-in compiler if that is not set
+func defaultCompiler() string {
+	if compiler := os.Getenv("SOCKETGEN_COMPILER"); compiler != "" {
+		return compiler
+	}
+	return parser.CompilerBuiltin
+}
+
+// defaultCacheDir returns $SOCKETGEN_CACHE_DIR, or socketgen's directory in the user cache
+// directory if that is not set
+func defaultCacheDir() string {
+	if dir, ok := os.LookupEnv("SOCKETGEN_CACHE_DIR"); ok {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "socketgen", "descriptors")
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/sample"
+	"github.com/spf13/cobra"
+)
+
+var sampleFixtures bool
+
+var sampleCmd = &cobra.Command{
+	Use:   "sample [payload...]",
+	Short: "Print realistic sample payloads built from the schema",
+	Long: `Prints a sample of every payload of packet.proto, or of the payload fields given, as a line the
+client REPL sends (e.g. login_req {"id":"id-1","pw":"s3cret!"}). Values are chosen by field name
+to look like real data, and are the same as in the golden test vectors and the Sample<Payload>
+builders of gen --samples.
+
+With --fixtures, prints a fixtures file for 'socketgen serve --fixtures' instead, answering every
+payload with option (socketgen.responds_with) with a sample of its response.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+
+		payloads := result.Payloads
+		if len(args) > 0 {
+			payloads = nil
+			for _, name := range args {
+				i := slices.IndexFunc(result.Payloads, func(p parser.PayloadMessage) bool { return p.FieldName == name })
+				if i < 0 {
+					fmt.Printf("Error: unknown payload %q\n", name)
+					os.Exit(1)
+				}
+				payloads = append(payloads, result.Payloads[i])
+			}
+		}
+
+		if !sampleFixtures {
+			for _, p := range payloads {
+				data, err := sample.JSON(result.Schema.PayloadByName(p.FieldName).Message())
+				if err != nil {
+					fmt.Printf("Error: %s: %v\n", p.FieldName, err)
+					os.Exit(1)
+				}
+				fmt.Printf("%s %s\n", p.FieldName, data)
+			}
+			return
+		}
+
+		fixtures := map[string][]map[string]json.RawMessage{}
+		for _, p := range payloads {
+			if p.RespondsWith == "" {
+				continue
+			}
+			res := result.Payload(p.RespondsWith)
+			field := result.Schema.PayloadByName(res.FieldName)
+			data, err := sample.JSON(field.Message())
+			if err != nil {
+				fmt.Printf("Error: %s: %v\n", res.FieldName, err)
+				os.Exit(1)
+			}
+			// A GamePacket in protobuf JSON, without a header so serve reuses the request's
+			fixtures[p.FieldName] = []map[string]json.RawMessage{{field.JSONName(): data}}
+		}
+		out, err := json.MarshalIndent(fixtures, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sampleCmd)
+
+	sampleCmd.Flags().BoolVar(&sampleFixtures, "fixtures", false, "Print a fixtures file for serve with a sample response to every request payload")
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+// schemaFile is the proto file defining the envelope, from --proto or gen.proto of the
+// configuration (default packet.proto)
+var schemaFile string
+
+// resolveSchema sets the schema, its import paths and its envelope from the proto, proto_path and
+// wrapper keys of the gen section of the configuration, unless --proto, -I or --wrapper is given.
+// The root command runs it before every command, so every command reads the schema gen reads. A
+// configuration that does not load is left to the command to report.
+func resolveSchema(cmd *cobra.Command) {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return
+	}
+	if proto := cfg.Gen["proto"]; proto != "" && !cmd.Flags().Changed("proto") {
+		schemaFile = proto
+	}
+	if paths := cfg.Gen["proto_path"]; paths != "" && !cmd.Flags().Changed("proto_path") {
+		parser.ImportPaths = strings.Split(paths, ",")
+	}
+	if wrapper := cfg.Gen["wrapper"]; wrapper != "" && !cmd.Flags().Changed("wrapper") {
+		parser.Wrapper = wrapper
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envelopeSchema names its envelope Envelope, so it only parses with the wrapper configured
+const envelopeSchema = `syntax = "proto3";
+package packet;
+
+message Header {
+  uint32 seq = 1;
+}
+
+message LoginReq {
+  string token = 1;
+}
+
+message Envelope {
+  Header header = 1;
+  oneof payload {
+    LoginReq login_req = 10;
+  }
+}
+`
+
+// writeProject writes files, by path relative to a new project directory, and returns the directory
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runCommand runs socketgen with args in dir and returns what it printed to stdout, progress
+// included. The schema settings the command resolves, and the flags, are restored afterwards.
+func runCommand(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	t.Chdir(dir)
+	file, paths, wrapper := schemaFile, parser.ImportPaths, parser.Wrapper
+	t.Cleanup(func() { schemaFile, parser.ImportPaths, parser.Wrapper = file, paths, wrapper })
+	cacheDir := parser.CacheDir
+	parser.CacheDir = ""
+	t.Cleanup(func() { parser.CacheDir = cacheDir })
+	t.Cleanup(func() { resetFlags(rootCmd) })
+	t.Cleanup(func() { genJSON = false })
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, log := os.Stdout, genLog
+	os.Stdout, genLog = w, w
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	os.Stdout, genLog = stdout, log
+	w.Close()
+	printed := <-out
+	if err != nil {
+		t.Fatalf("socketgen %s: %v\n%s", strings.Join(args, " "), err, printed)
+	}
+	return printed
+}
+
+// resetFlags marks the flags of cmd and its subcommands as not given, for the next command run
+func resetFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+	cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+func TestCommandsReadSchemaFromConfig(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"proto/network/packet.proto": envelopeSchema,
+		"socketgen.yaml":             "gen:\n  proto: proto/network/packet.proto\n  wrapper: Envelope\n",
+	})
+
+	if out := runCommand(t, dir, "lint"); !strings.Contains(out, "No problems found.") {
+		t.Errorf("lint with the wrapper set only in socketgen.yaml printed:\n%s", out)
+	}
+	if out := runCommand(t, dir, "docs"); !strings.Contains(out, "### LoginReq") {
+		t.Errorf("docs with the schema set only in socketgen.yaml printed:\n%s", out)
+	}
+}
+
+func TestCommandsReadImportPathsFromConfig(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"proto/packet.proto":        strings.Replace(envelopeSchema, "message Header {\n  uint32 seq = 1;\n}\n", `import "common/header.proto";`+"\n", 1),
+		"proto/common/header.proto": "syntax = \"proto3\";\npackage packet;\n\nmessage Header {\n  uint32 seq = 1;\n}\n",
+		"socketgen.yaml":            "gen:\n  proto: proto/packet.proto\n  proto_path: proto\n  wrapper: Envelope\n",
+	})
+
+	if out := runCommand(t, dir, "lint"); !strings.Contains(out, "No problems found.") {
+		t.Errorf("lint with the import paths set only in socketgen.yaml printed:\n%s", out)
+	}
+}
+
+func TestWrapperFlagOverridesConfig(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"packet.proto":   envelopeSchema,
+		"socketgen.yaml": "gen:\n  wrapper: GamePacket\n",
+	})
+
+	if out := runCommand(t, dir, "stats", "--wrapper", "Envelope"); !strings.Contains(out, "login_req") {
+		t.Errorf("stats --wrapper Envelope printed:\n%s", out)
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/snowmerak/socketgen/devserver"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/recording"
+	"github.com/snowmerak/socketgen/watch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr     string
+	servePath     string
+	serveEcho     bool
+	serveFixtures string
+	serveWatch    bool
+	serveRecord   string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a development WebSocket server that inspects packets",
+	Long: `Starts a WebSocket server that decodes every GamePacket using packet.proto (no generated code needed)
+and pretty-prints it. The server can echo packets back or respond from canned fixtures, so client
+developers can work before the real server exists.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, fixtures, err := loadServeState()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if fixtures != nil {
+			fmt.Printf("Loaded fixtures for %d payloads from %s\n", len(fixtures), serveFixtures)
+		}
+
+		srv := devserver.New(schema, fixtures)
+		srv.Echo = serveEcho
+
+		if serveRecord != "" {
+			recorder, err := recording.Create(serveRecord, schema)
+			if err != nil {
+				fmt.Printf("Error opening recording: %v\n", err)
+				return
+			}
+			defer recorder.Close()
+			srv.Recorder = recorder
+			fmt.Printf("Recording packets to %s\n", serveRecord)
+		}
+
+		if serveWatch {
+			go watchServeState(srv, schema)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle(servePath, srv)
+
+		fmt.Printf("Dev server listening on ws://%s%s\n", serveAddr, servePath)
+		if err := http.ListenAndServe(serveAddr, mux); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+// loadServeState loads packet.proto and, if configured, the fixtures file
+func loadServeState() (*parser.Schema, devserver.Fixtures, error) {
+	schema, err := parser.LoadSchema(schemaFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load %s: %w", schemaFile, err)
+	}
+
+	if serveFixtures == "" {
+		return schema, nil, nil
+	}
+
+	fixtures, err := devserver.LoadFixtures(serveFixtures, schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load fixtures: %w", err)
+	}
+	return schema, fixtures, nil
+}
+
+// watchServeState reloads the schema and fixtures into srv whenever packet.proto, its imports,
+// or the fixtures file change. A failed reload keeps the previous state.
+func watchServeState(srv *devserver.Server, schema *parser.Schema) {
+	// Poll calls watched and the reload callback from the same goroutine, so schema needs no locking
+	watched := func() []string {
+		paths := append([]string{schemaFile}, schema.FilePaths()...)
+		if serveFixtures != "" {
+			paths = append(paths, serveFixtures)
+		}
+		return paths
+	}
+
+	fmt.Printf("Watching %s and fixtures for changes...\n", schemaFile)
+	watch.Poll(context.Background(), watched, 500*time.Millisecond, func() {
+		next, fixtures, err := loadServeState()
+		if err != nil {
+			fmt.Printf("Reload failed, keeping previous schema: %v\n", err)
+			return
+		}
+
+		srv.Reload(next, fixtures)
+		schema = next
+
+		fmt.Printf("Reloaded schema (version %s)\n", next.Version)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "localhost:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&servePath, "path", "/ws", "WebSocket endpoint path")
+	serveCmd.Flags().BoolVar(&serveEcho, "echo", false, "Echo every packet back to the sender")
+	serveCmd.Flags().StringVar(&serveFixtures, "fixtures", "", "JSON file of canned responses keyed by payload field name")
+	serveCmd.Flags().BoolVar(&serveWatch, "watch", false, "Reload packet.proto and fixtures when they change, without dropping connections")
+	serveCmd.Flags().StringVar(&serveRecord, "record", "", "Append every packet to this recording, JSON Lines or a replay file ending in .sgr (see socketgen convert)")
+}
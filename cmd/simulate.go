@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/simulate"
+	"github.com/snowmerak/socketgen/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	simulateRTT     time.Duration
+	simulateLoss    float64
+	simulateClients int
+	simulateSamples string
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate <flows.yaml>",
+	Short: "Estimate the latency and bandwidth of protocol flows",
+	Long: `Estimates the end-to-end latency of every flow in a YAML (or JSON) flow definition over an
+assumed network, with its mean and percentiles under packet loss, and the bandwidth the flows
+take at the server (rate x clients x packet sizes). Packet sizes are estimated from packet.proto
+like 'socketgen stats' does, so designs can be compared before they are implemented.
+
+--rtt, --loss and --clients override the values of the definition, e.g. to compare networks.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, err := parser.LoadSchema(schemaFile)
+		if err != nil {
+			fmt.Printf("Error loading %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+
+		d, err := simulate.Load(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if cmd.Flags().Changed("rtt") {
+			d.Network.RTT = simulateRTT
+		}
+		if cmd.Flags().Changed("loss") {
+			d.Network.Loss = simulateLoss
+		}
+		if cmd.Flags().Changed("clients") {
+			d.Clients = simulateClients
+		}
+		if err := d.Validate(schema); err != nil {
+			fmt.Printf("Error: invalid flow definition %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+
+		opts := simulate.Options{Assumptions: stats.DefaultAssumptions}
+		if simulateSamples != "" {
+			opts.Samples, err = stats.LoadSamples(simulateSamples, schema)
+			if err != nil {
+				fmt.Printf("Error loading samples: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		reports := simulate.Estimate(schema, d, opts)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FLOW\tRATE\tLATENCY\tMEAN\tP95\tP99\tPACKETS\tBYTES\tUP\tDOWN")
+		var rate, up, down float64
+		for _, r := range reports {
+			fmt.Fprintf(w, "%s\t%.2f/s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", r.Flow, r.Rate,
+				r.Latency.Round(time.Millisecond), r.Mean.Round(time.Millisecond), r.P95.Round(time.Millisecond),
+				r.P99.Round(time.Millisecond), r.Packets, r.Bytes, byteRate(r.Up), byteRate(r.Down))
+			rate += r.Rate
+			up += r.Up
+			down += r.Down
+		}
+		fmt.Fprintf(w, "TOTAL\t%.2f/s\t\t\t\t\t\t\t%s\t%s\n", rate, byteRate(up), byteRate(down))
+		w.Flush()
+	},
+}
+
+// byteRate formats a rate in bytes per second with a decimal unit
+func byteRate(bytes float64) string {
+	for _, unit := range []string{"B/s", "kB/s", "MB/s"} {
+		if bytes < 1000 {
+			return fmt.Sprintf("%.1f %s", bytes, unit)
+		}
+		bytes /= 1000
+	}
+	return fmt.Sprintf("%.1f GB/s", bytes)
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+
+	simulateCmd.Flags().DurationVar(&simulateRTT, "rtt", simulate.DefaultRTT, "Round-trip time, overriding network.rtt of the definition")
+	simulateCmd.Flags().Float64Var(&simulateLoss, "loss", 0, "Packet loss probability from 0 to 1, overriding network.loss of the definition")
+	simulateCmd.Flags().IntVar(&simulateClients, "clients", 1, "Connected clients, overriding clients of the definition")
+	simulateCmd.Flags().StringVar(&simulateSamples, "samples", "", "JSON file of sample payload values keyed by payload field name, measured exactly")
+}
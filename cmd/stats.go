@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsBudget      int
+	statsBudgets     map[string]int
+	statsSamples     string
+	statsAssumptions = stats.DefaultAssumptions
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Estimate encoded packet sizes per payload",
+	Long: `Estimates the min/typical/max encoded size of a GamePacket for every payload in packet.proto,
+compares them against size budgets, and shows how much of each packet is header overhead.
+
+Min is the size with every payload field left at its default. Typical and max sizes use the
+length assumptions given by the --typical-*/--max-* flags. Payloads can also be measured exactly
+from sample values given with --samples.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, err := parser.LoadSchema(schemaFile)
+		if err != nil {
+			fmt.Printf("Error loading %s: %v\n", schemaFile, err)
+			return
+		}
+
+		opts := stats.Options{
+			Assumptions: statsAssumptions,
+			Budget:      statsBudget,
+			Budgets:     statsBudgets,
+		}
+		if statsSamples != "" {
+			opts.Samples, err = stats.LoadSamples(statsSamples, schema)
+			if err != nil {
+				fmt.Printf("Error loading samples: %v\n", err)
+				return
+			}
+		}
+
+		reports := stats.Report(schema, opts)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PAYLOAD\tMIN\tTYPICAL\tMAX\tSAMPLE\tHEADER\tBUDGET\tSTATUS")
+		over := 0
+		for _, r := range reports {
+			maxSize := strconv.Itoa(r.Size.Max)
+			if r.Size.Unbounded {
+				maxSize += "+"
+			}
+			sample := "-"
+			if r.Sample >= 0 {
+				sample = strconv.Itoa(r.Sample)
+			}
+			budget := "-"
+			if r.Budget > 0 {
+				budget = strconv.Itoa(r.Budget)
+			}
+			if r.Status == stats.StatusOver {
+				over++
+			}
+
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%d (%.0f%%)\t%s\t%s\n",
+				r.Payload, r.Size.Min, r.Size.Typical, maxSize, sample, r.Header, r.HeaderShare(), budget, r.Status)
+		}
+		w.Flush()
+
+		if over > 0 {
+			fmt.Printf("\nWarning: %d payload(s) exceed their size budget.\n", over)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().IntVar(&statsBudget, "budget", 0, "Default size budget in bytes for every payload (0 for none)")
+	statsCmd.Flags().StringToIntVar(&statsBudgets, "budget-for", nil, "Per-payload size budgets (e.g. chat_msg=256,login_req=128)")
+	statsCmd.Flags().StringVar(&statsSamples, "samples", "", "JSON file of sample payload values keyed by payload field name")
+	statsCmd.Flags().IntVar(&statsAssumptions.TypicalString, "typical-string", statsAssumptions.TypicalString, "Assumed typical string length")
+	statsCmd.Flags().IntVar(&statsAssumptions.MaxString, "max-string", statsAssumptions.MaxString, "Assumed maximum string length")
+	statsCmd.Flags().IntVar(&statsAssumptions.TypicalBytes, "typical-bytes", statsAssumptions.TypicalBytes, "Assumed typical bytes field length")
+	statsCmd.Flags().IntVar(&statsAssumptions.MaxBytes, "max-bytes", statsAssumptions.MaxBytes, "Assumed maximum bytes field length")
+	statsCmd.Flags().IntVar(&statsAssumptions.TypicalRepeated, "typical-repeated", statsAssumptions.TypicalRepeated, "Assumed typical element count of repeated fields")
+	statsCmd.Flags().IntVar(&statsAssumptions.MaxRepeated, "max-repeated", statsAssumptions.MaxRepeated, "Assumed maximum element count of repeated fields")
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/snowmerak/socketgen/toolchain"
+	"github.com/spf13/cobra"
+)
+
+var toolchainPlugins bool
+
+var toolchainCmd = &cobra.Command{
+	Use:   "toolchain",
+	Short: "Manage the protoc release socketgen is pinned to",
+}
+
+var toolchainInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Download the pinned protoc (and protoc-gen-go) for this OS and architecture",
+	Long: `Downloads protoc ` + toolchain.ProtocVersion + ` into the user cache directory, and with --plugins protoc-gen-go
+` + toolchain.ProtocGenGoVersion + `, the version of the protobuf runtime socketgen uses. Once installed, every socketgen
+command runs them instead of the protoc and protoc-gen-go in PATH, so the same socketgen version
+produces the same output on every machine. Archives whose SHA-256 differs from the one pinned for
+them are refused.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := toolchain.Install(toolchainPlugins, os.Stdout); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(toolchainCmd)
+	toolchainCmd.AddCommand(toolchainInstallCmd)
+
+	toolchainInstallCmd.Flags().BoolVar(&toolchainPlugins, "plugins", false, "Install protoc-gen-go "+toolchain.ProtocGenGoVersion+" as well")
+}
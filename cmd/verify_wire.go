@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/replay"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	verifyRecordings []string
+	verifyPrevious   string
+	verifyStrict     bool
+)
+
+var verifyWireCmd = &cobra.Command{
+	Use:   "verify-wire --recording <session.sgr>...",
+	Short: "Check that packets recorded with an earlier release still decode",
+	Long: `Replays the packets of replay recordings (e.g. made with 'socketgen serve --record session.sgr')
+from a previous release through packet.proto, as the dispatcher would see them, and fails on:
+
+  - packets that do not decode
+  - payload field numbers packet.proto no longer has
+  - packets without a payload, which the dispatcher rejects
+  - payloads sent in a direction option (socketgen.direction) no longer allows
+  - with --previous, the descriptor set the recording was made with, payload field numbers
+    that now carry another payload
+
+Fields of payloads that packet.proto no longer has are dropped silently by decoders; they are
+reported as warnings, and fail the check with --strict. Exits with status 1 on failures, as an
+end-to-end compatibility gate for CI.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(verifyRecordings) == 0 {
+			fmt.Println("Error: give the recordings to verify with --recording")
+			os.Exit(1)
+		}
+
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+		var old *parser.Schema
+		if verifyPrevious != "" {
+			if old, err = parser.LoadSchemaFromDescriptorSet(verifyPrevious); err != nil {
+				fmt.Printf("Error loading %s: %v\n", verifyPrevious, err)
+				os.Exit(1)
+			}
+		}
+
+		failed := false
+		for _, path := range verifyRecordings {
+			v, err := verifyWire(path, result, old)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			failed = v.report(path) || failed
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// wireIssue is a problem found in some of the frames of a recording
+type wireIssue struct {
+	Problem string
+	Frames  int // Frames with the problem
+	First   int // Number of the first one, counted from 1
+	Failure bool
+}
+
+// wireVerification is the outcome of replaying a recording
+type wireVerification struct {
+	Schema string // Fingerprint the recording was made with
+	Frames int
+	Issues []*wireIssue
+}
+
+func (v *wireVerification) add(frame int, failure bool, format string, args ...any) {
+	problem := fmt.Sprintf(format, args...)
+	i := slices.IndexFunc(v.Issues, func(issue *wireIssue) bool { return issue.Problem == problem })
+	if i < 0 {
+		v.Issues = append(v.Issues, &wireIssue{Problem: problem, First: frame, Failure: failure})
+		i = len(v.Issues) - 1
+	}
+	v.Issues[i].Frames++
+}
+
+// report prints the outcome of the recording at path and reports whether it failed
+func (v *wireVerification) report(path string) bool {
+	failures, warnings := 0, 0
+	for _, issue := range v.Issues {
+		kind := "WARN"
+		if issue.Failure || verifyStrict {
+			kind = "FAIL"
+			failures += issue.Frames
+		} else {
+			warnings += issue.Frames
+		}
+		fmt.Printf("%s: %s: %s (%d frames, first at frame %d)\n", path, kind, issue.Problem, issue.Frames, issue.First)
+	}
+	fmt.Printf("%s: %d frames recorded with schema %s, %d failed, %d with warnings\n", path, v.Frames, v.Schema, failures, warnings)
+	return failures > 0
+}
+
+// verifyWire decodes every frame of the replay at path with the schema of result, as the
+// dispatcher would. With old, the schema the replay was recorded with, payloads are also checked
+// to keep their numbers.
+func verifyWire(path string, result *parser.ParseResult, old *parser.Schema) (*wireVerification, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	defer f.Close()
+
+	r, err := replay.NewReader(f)
+	if errors.Is(err, replay.ErrNotReplay) {
+		return nil, fmt.Errorf("%s is not a replay recording; JSON Lines recordings do not keep the encoded packets, so record with a path ending in %s", path, ".sgr")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	v := &wireVerification{Schema: r.Header().Schema}
+	if old != nil && old.Version != v.Schema {
+		fmt.Printf("Warning: %s was recorded with schema %s, but %s is %s\n", path, v.Schema, verifyPrevious, old.Version)
+	}
+
+	schema := result.Schema
+	for n := 1; ; n++ {
+		frame, err := r.Next()
+		if err == io.EOF {
+			return v, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: frame %d: %w", path, n, err)
+		}
+		v.Frames++
+
+		pkt, err := schema.Decode(frame.Packet)
+		if err != nil {
+			v.add(n, true, "does not decode: %v", err)
+			continue
+		}
+
+		field := schema.PayloadField(pkt)
+		if field == nil {
+			if num, ok := unknownField(pkt.GetUnknown()); ok {
+				v.add(n, true, "payload field %d is not in %s", num, result.Wrapper)
+			} else {
+				v.add(n, true, "has no payload")
+			}
+			continue
+		}
+
+		if old != nil {
+			if prev, err := old.Decode(frame.Packet); err == nil {
+				if was := old.PayloadField(prev); was != nil && (was.Name() != field.Name() || was.Message().FullName() != field.Message().FullName()) {
+					v.add(n, true, "payload %d was %s (%s), and is now %s (%s)", field.Number(), was.Name(), was.Message().FullName(), field.Name(), field.Message().FullName())
+					continue
+				}
+			}
+		}
+
+		if p := result.Payload(string(field.Message().Name())); p != nil {
+			switch {
+			case frame.Direction == replay.Inbound && p.Direction == parser.DirectionServerToClient:
+				v.add(n, true, "%s was sent by a client, but is now sent by the server only", field.Name())
+			case frame.Direction == replay.Outbound && p.Direction == parser.DirectionClientToServer:
+				v.add(n, true, "%s was sent by the server, but is now sent by clients only", field.Name())
+			}
+		}
+
+		for _, path := range droppedFields(pkt.Get(field).Message(), string(field.Name())) {
+			v.add(n, false, "%s has fields %s no longer declares; they are dropped", path, field.Message().FullName())
+		}
+	}
+}
+
+// unknownField returns the number of the first field in raw, the unknown fields of a message
+func unknownField(raw protoreflect.RawFields) (protowire.Number, bool) {
+	num, _, n := protowire.ConsumeTag(raw)
+	return num, n > 0
+}
+
+// droppedFields returns the paths, from path, of the messages within m holding unknown fields
+func droppedFields(m protoreflect.Message, path string) []string {
+	var paths []string
+	if len(m.GetUnknown()) > 0 {
+		paths = append(paths, path)
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if fd.Message() == nil || fd.IsMap() && fd.MapValue().Message() == nil {
+			return true
+		}
+		sub := path + "." + string(fd.Name())
+		switch {
+		case fd.IsList():
+			list := value.List()
+			for i := range list.Len() {
+				paths = append(paths, droppedFields(list.Get(i).Message(), fmt.Sprintf("%s[%d]", sub, i))...)
+			}
+		case fd.IsMap():
+			value.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+				paths = append(paths, droppedFields(value.Message(), fmt.Sprintf("%s[%v]", sub, key.Interface()))...)
+				return true
+			})
+		default:
+			paths = append(paths, droppedFields(value.Message(), sub)...)
+		}
+		return true
+	})
+	return paths
+}
+
+func init() {
+	rootCmd.AddCommand(verifyWireCmd)
+
+	verifyWireCmd.Flags().StringSliceVar(&verifyRecordings, "recording", nil, "Replay recordings (.sgr) made with an earlier release")
+	verifyWireCmd.Flags().StringVar(&verifyPrevious, "previous", "", "Descriptor set the recordings were made with (e.g. the packet_descriptor.pb of that release), to check that payloads kept their numbers")
+	verifyWireCmd.Flags().BoolVar(&verifyStrict, "strict", false, "Fail on fields of payloads packet.proto no longer declares, too")
+}
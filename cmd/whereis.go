@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	whereisDir string
+)
+
+// generatedLanguages names the languages of generated files by extension
+var generatedLanguages = map[string]string{
+	".go": "go", ".ts": "ts", ".py": "python", ".cs": "csharp", ".dart": "dart",
+	".php": "php", ".rb": "ruby", ".kt": "kotlin", ".java": "java",
+}
+
+// generatedLocation matches a location in a file, e.g. gen/packet_handlers.go:25
+var generatedLocation = regexp.MustCompile(`^(.+):(\d+)$`)
+
+var whereisCmd = &cobra.Command{
+	Use:   "whereis <payload | file:line>",
+	Short: "Locate a payload in packet.proto and in the generated code",
+	Long: `Prints where a payload is declared in packet.proto (its message and its field of GamePacket)
+and where the code generated for it is, in every language generated in --dir, as file:line
+locations editors jump to. The payload is given by type name (LoginReq) or field name
+(login_req).
+
+Given a location in generated code instead, e.g. gen/packet_handlers.go:25, whereis locates the
+payload that line is about. The generated handlers carry a "Source:" doc comment telling where
+their payload is declared, which whereis searches for.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", schemaFile, err)
+			os.Exit(1)
+		}
+
+		var p *parser.PayloadMessage
+		if m := generatedLocation.FindStringSubmatch(args[0]); m != nil {
+			line, _ := strconv.Atoi(m[2])
+			if p, err = payloadAt(result, m[1], line); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		} else if p = payloadNamed(result, args[0]); p == nil {
+			fmt.Printf("Error: %s declares no payload %s\n", schemaFile, args[0])
+			os.Exit(1)
+		}
+
+		generated, err := generatedSymbols(whereisDir, p)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		message, field := declarations(result, p)
+		if message != "" {
+			fmt.Fprintf(w, "%s\tmessage %s\n", message, p.FullName)
+		}
+		if field != "" {
+			fmt.Fprintf(w, "%s\tfield %s = %d of %s\n", field, p.FieldName, p.Number, result.Wrapper)
+		}
+		for _, location := range generated {
+			fmt.Fprintf(w, "%s\t%s\n", location, generatedLanguages[filepath.Ext(location[:strings.LastIndex(location, ":")])])
+		}
+		w.Flush()
+		if len(generated) == 0 {
+			fmt.Printf("No code generated for %s in %s; run socketgen gen first\n", p.Name, whereisDir)
+		}
+	},
+}
+
+// payloadNamed returns the payload with the type or field name name, or nil
+func payloadNamed(result *parser.ParseResult, name string) *parser.PayloadMessage {
+	for i := range result.Payloads {
+		if result.Payloads[i].Name == name || result.Payloads[i].FieldName == name {
+			return &result.Payloads[i]
+		}
+	}
+	return nil
+}
+
+// payloadAt returns the payload line of the generated file path is about: the payload whose type or
+// field name the line mentions (the longest, so OnLoginReqV2 is not taken for LoginReq), or else the
+// payload of the "Source:" doc comment the line is part of
+func payloadAt(result *parser.ParseResult, path string, line int) (*parser.PayloadMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return nil, fmt.Errorf("%s has %d lines", path, len(lines))
+	}
+	text := lines[line-1]
+
+	var found *parser.PayloadMessage
+	longest := 0
+	for i := range result.Payloads {
+		p := &result.Payloads[i]
+		for _, name := range []string{p.Name, p.FieldName} {
+			if len(name) > longest && strings.Contains(text, name) {
+				found, longest = p, len(name)
+			}
+		}
+	}
+	if found != nil {
+		return found, nil
+	}
+
+	if _, source, ok := strings.Cut(text, parser.SourcePrefix); ok {
+		fullName, _, _ := strings.Cut(source, " ")
+		for i := range result.Payloads {
+			if result.Payloads[i].FullName == fullName {
+				return &result.Payloads[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s:%d is not about a payload of %s", path, line, schemaFile)
+}
+
+// generatedSymbols returns the locations of the "Source:" doc comments of the symbols generated for
+// p in dir, as file:line
+func generatedSymbols(dir string, p *parser.PayloadMessage) ([]string, error) {
+	marker := parser.SourcePrefix + p.FullName + " at "
+	var locations []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || generatedLanguages[filepath.Ext(path)] == "" {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.Contains(scanner.Text(), marker) {
+				locations = append(locations, fmt.Sprintf("%s:%d", path, line))
+			}
+		}
+		return scanner.Err()
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return locations, err
+}
+
+func init() {
+	rootCmd.AddCommand(whereisCmd)
+	whereisCmd.Flags().StringVar(&whereisDir, "dir", "./gen", "Directory of the generated code")
+}
@@ -0,0 +1,273 @@
+// Package config loads socketgen.yaml, the optional project configuration read by gen.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the configuration file gen reads when present
+const DefaultFile = "socketgen.yaml"
+
+// Config is the content of socketgen.yaml
+type Config struct {
+	// Session declares typed per-connection values, e.g.
+	//
+	//	session:
+	//	  user_id: string
+	//	  room_id: int64
+	Session SessionFields `yaml:"session"`
+
+	// Tenant names the string field of Header that identifies the tenant (realm, game title)
+	// of a packet. When set, the Go output includes a per-tenant router.
+	Tenant string `yaml:"tenant"`
+
+	// Framing replaces the default frame layout of the Go stream transports (a 4-byte big-endian
+	// length, then the GamePacket), see Framing
+	Framing *Framing `yaml:"framing"`
+
+	// Metrics tunes the generated metrics (gen --metrics), see Metrics
+	Metrics *Metrics `yaml:"metrics"`
+
+	// Plugins sets the paths of the protoc plugins gen --protoc runs, by language, e.g.
+	//
+	//	plugins:
+	//	  ts: ./node_modules/.bin/protoc-gen-ts_proto.cmd
+	//	  go: C:/tools/protoc-gen-go.exe
+	//
+	// Plugins not set are looked up in PATH and where their package managers install them.
+	Plugins map[string]string `yaml:"plugins"`
+
+	// Gen sets the gen flags of every run, so a team shares one way to generate, e.g.
+	//
+	//	gen:
+	//	  proto: proto/network/packet.proto
+	//	  lang: [go, ts]
+	//	  out: ./gen
+	//	  protoc: true
+	//
+	// Flags given on the command line, then those of the profile selected with gen --profile,
+	// take precedence.
+	Gen Profile `yaml:"gen"`
+
+	// Outputs sets the output directory of languages, instead of the gen --out directory, e.g.
+	//
+	//	outputs:
+	//	  go: ./server/gen
+	//	  ts: ./web/src/gen
+	//
+	// The protoc bindings of a language are written with its code. --out on the command line puts
+	// every language there.
+	Outputs map[string]string `yaml:"outputs"`
+
+	// Profiles are named sets of gen flags, selected with gen --profile, e.g.
+	//
+	//	profiles:
+	//	  dev:
+	//	    coverage: true
+	//	    vectors: true
+	//	  prod:
+	//	    pooled: true
+	//	    metrics: true
+	//	    transports: [tcp, ws]
+	Profiles map[string]Profile `yaml:"profiles"`
+
+	// Ranges reserve blocks of payload field numbers for features, keeping large schemas
+	// organized, e.g.
+	//
+	//	ranges:
+	//	  auth: 10-19
+	//	  chat: 20-39
+	//
+	// socketgen add numbers a new payload in the range of its feature, and socketgen lint reports
+	// payloads numbered outside every range.
+	Ranges Ranges `yaml:"ranges"`
+
+	// Security hardens the generated Go server, see Security. socketgen audit --security
+	// reports what is missing and proposes hardened defaults.
+	Security *Security `yaml:"security"`
+
+	// Middleware enables presets of middleware (logging, metrics, panic recovery, an auth gate and
+	// rate limits) running around the dispatch of every packet of the generated Go server, see
+	// Middleware
+	Middleware *Middleware `yaml:"middleware"`
+
+	// Endpoints are the servers the generated clients connect to in every environment (dev,
+	// stage, prod), with their TLS settings and transport preference order, see Endpoints
+	Endpoints *Endpoints `yaml:"endpoints"`
+
+	// Schemas are the proto schemas of the project besides packet.proto, so teams can evolve
+	// their payloads apart, e.g.
+	//
+	//	schemas:
+	//	  social: social.proto
+	//
+	// Each defines an envelope named after it (SocialPacket) with the header of GamePacket, and
+	// payloads numbered apart from those of every other schema. The Go output gets a dispatcher
+	// per schema and a Router handing every packet to the dispatcher of its schema.
+	Schemas Schemas `yaml:"schemas"`
+
+	// Compile sets the commands gen --sizes runs, by language, to time how long the generated
+	// code takes to compile, e.g.
+	//
+	//	compile:
+	//	  go: go build ./gen
+	//	  ts: npx tsc --noEmit -p web
+	//
+	// The commands run in the project directory, split on spaces, without a shell.
+	Compile map[string]string `yaml:"compile"`
+}
+
+// Template is the socketgen.yaml socketgen init creates
+const Template = `# Project configuration of socketgen. Flags given on the command line take precedence.
+
+# The flags of every socketgen gen run
+gen:
+  proto: packet.proto
+  lang: [go, ts]
+  out: ./gen
+
+# Output directories of languages, instead of out
+# outputs:
+#   go: ./server/gen
+#   ts: ./web/src/gen
+
+# Named sets of flags, selected with socketgen gen --profile
+# profiles:
+#   dev:
+#     coverage: true
+#     vectors: true
+`
+
+// Profile maps gen flag names to values in the syntax of the command line; lists become
+// comma-separated values
+type Profile map[string]string
+
+func (p *Profile) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: a profile must be a mapping of flag name to value", node.Line)
+	}
+
+	profile := make(Profile, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i].Value, node.Content[i+1]
+		switch value.Kind {
+		case yaml.ScalarNode:
+			profile[name] = value.Value
+		case yaml.SequenceNode:
+			values := make([]string, 0, len(value.Content))
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("line %d: the values of %s must be scalars", item.Line, name)
+				}
+				values = append(values, item.Value)
+			}
+			profile[name] = strings.Join(values, ",")
+		default:
+			return fmt.Errorf("line %d: %s must be a value or a list of values", value.Line, name)
+		}
+	}
+
+	*p = profile
+	return nil
+}
+
+// DefaultMaxLabelValues caps the distinct values of each metric label unless configured
+const DefaultMaxLabelValues = 100
+
+// Metrics controls the cardinality of the generated metrics, e.g.
+//
+//	metrics:
+//	  max_label_values: 20
+//	  drop_labels: [user_id]
+//
+// Metrics are never labeled by session. Fields annotated with option (socketgen.metric_label)
+// become labels, each counting at most MaxLabelValues distinct values; later values are counted
+// together as "other".
+type Metrics struct {
+	MaxLabelValues int      `yaml:"max_label_values"` // Distinct values counted per label (default 100)
+	DropLabels     []string `yaml:"drop_labels"`      // Annotated fields not to use as labels
+}
+
+// SessionField is a typed value attached to a session
+type SessionField struct {
+	Name string // snake_case name (e.g., "user_id")
+	Type string // Proto scalar type name (e.g., "string", "int64")
+}
+
+// SessionFields keeps the declaration order of the session mapping
+type SessionFields []SessionField
+
+// sessionTypes lists the scalar types a session field may have
+var sessionTypes = map[string]bool{
+	"string": true, "bytes": true, "bool": true,
+	"int32": true, "int64": true, "uint32": true, "uint64": true,
+	"float": true, "double": true,
+}
+
+func (s *SessionFields) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: session must be a mapping of name to type", node.Line)
+	}
+
+	fields := make(SessionFields, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, typ := node.Content[i].Value, node.Content[i+1].Value
+		if !sessionTypes[typ] {
+			return fmt.Errorf("line %d: session field %s has unsupported type %q", node.Content[i+1].Line, name, typ)
+		}
+		fields = append(fields, SessionField{Name: name, Type: typ})
+	}
+
+	*s = fields
+	return nil
+}
+
+// Load reads the configuration at path. A missing file yields an empty configuration.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if cfg.Framing != nil {
+		if err := cfg.Framing.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if cfg.Metrics != nil {
+		if cfg.Metrics.MaxLabelValues < 0 {
+			return nil, fmt.Errorf("%s: metrics: max_label_values must not be negative", path)
+		}
+		if cfg.Metrics.MaxLabelValues == 0 {
+			cfg.Metrics.MaxLabelValues = DefaultMaxLabelValues
+		}
+	}
+	if cfg.Security != nil {
+		if err := cfg.Security.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if cfg.Middleware != nil {
+		if err := cfg.Middleware.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if cfg.Endpoints != nil {
+		if err := cfg.Endpoints.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return &cfg, nil
+}
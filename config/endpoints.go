@@ -0,0 +1,279 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EndpointTransports are the transports an endpoint may be reached over, those of gen --transports
+var EndpointTransports = []string{"tcp", "ws", "kcp", "quic", "socketio", "mqtt", "grpcweb", "sse"}
+
+// endpointSchemes are the URL schemes of the transports not addressed by host:port
+var endpointSchemes = map[string][]string{
+	"ws":       {"ws", "wss"},
+	"socketio": {"http", "https"},
+	"mqtt":     {"mqtt", "ws", "mqtts", "wss"},
+	"grpcweb":  {"http", "https"},
+	"sse":      {"http", "https"},
+}
+
+// secureSchemes maps the schemes not carried over TLS to those that are
+var secureSchemes = map[string]string{"ws": "wss", "http": "https", "mqtt": "mqtts"}
+
+// environmentName is the form of an environment name
+var environmentName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// Endpoints are the servers the generated clients connect to, by environment, e.g.
+//
+//	endpoints:
+//	  default: dev
+//	  environments:
+//	    dev:
+//	      urls:
+//	        ws: ws://localhost:8080/ws
+//	        tcp: localhost:9000
+//	    prod:
+//	      urls:
+//	        ws: wss://play.example.com/ws
+//	        quic: play.example.com:4433
+//	      prefer: [quic, ws]
+//	      tls:
+//	        server_name: play.example.com
+//	        min_version: "1.3"
+//
+// Every client language gets the same table, so builds of every platform select the same
+// backends. gen --env changes the environment built in, and the SOCKETGEN_ENV and
+// SOCKETGEN_ENDPOINT_<TRANSPORT> variables override it at run time.
+type Endpoints struct {
+	// Default is the environment clients connect to unless told otherwise (default the first)
+	Default string `yaml:"default"`
+
+	// Environments are the endpoints of every environment, in the order declared
+	Environments Environments `yaml:"environments"`
+}
+
+// Environment holds the endpoints of one environment
+type Environment struct {
+	Name string `yaml:"-"`
+
+	// URLs are the addresses of the server by transport: host:port for tcp, kcp and quic, a URL
+	// for the others
+	URLs EndpointURLs `yaml:"urls"`
+
+	// Prefer is the order clients try the transports in; transports not listed come after, in
+	// the order of URLs
+	Prefer []string `yaml:"prefer"`
+
+	// TLS sets how clients verify the server. Environments without it still use TLS for quic and
+	// for wss://, https:// and mqtts:// URLs, with the defaults.
+	TLS *EndpointTLS `yaml:"tls"`
+}
+
+// EndpointTLS are the TLS settings of the endpoints of an environment
+type EndpointTLS struct {
+	// ServerName is the name verified in the certificate of the server (default the host of the
+	// URL)
+	ServerName string `yaml:"server_name"`
+
+	// MinVersion is the oldest TLS version accepted: 1.2 or 1.3 (default 1.2)
+	MinVersion string `yaml:"min_version"`
+
+	// InsecureSkipVerify accepts any certificate, for development servers with self-signed ones
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// EndpointURL is the address of the server over one transport
+type EndpointURL struct {
+	Transport string
+	URL       string
+}
+
+// Preferred returns the URLs in the order clients try them
+func (e Environment) Preferred() []EndpointURL {
+	urls := make([]EndpointURL, 0, len(e.URLs))
+	for _, transport := range e.Prefer {
+		i := slices.IndexFunc(e.URLs, func(u EndpointURL) bool { return u.Transport == transport })
+		urls = append(urls, e.URLs[i])
+	}
+	for _, u := range e.URLs {
+		if !slices.Contains(e.Prefer, u.Transport) {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// UsesTLS reports whether clients connect to the environment over TLS
+func (e Environment) UsesTLS() bool {
+	if e.TLS != nil {
+		return true
+	}
+	return slices.ContainsFunc(e.URLs, func(u EndpointURL) bool {
+		_, insecure := secureSchemes[scheme(u.URL)]
+		return u.Transport == "quic" || (scheme(u.URL) != "" && !insecure)
+	})
+}
+
+// Environment returns the environment called name, or nil
+func (e *Endpoints) Environment(name string) *Environment {
+	i := slices.IndexFunc(e.Environments, func(env Environment) bool { return env.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return &e.Environments[i]
+}
+
+// Names returns the names of the environments
+func (e *Endpoints) Names() []string {
+	names := make([]string, len(e.Environments))
+	for i, env := range e.Environments {
+		names[i] = env.Name
+	}
+	return names
+}
+
+// Environments keeps the declaration order of the environments mapping
+type Environments []Environment
+
+func (es *Environments) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: endpoints.environments must be a mapping of environment name to its endpoints", node.Line)
+	}
+
+	envs := make(Environments, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name := node.Content[i].Value
+		if !environmentName.MatchString(name) {
+			return fmt.Errorf("line %d: environment name %q must be lowercase (e.g., dev, stage, prod)", node.Content[i].Line, name)
+		}
+		var env Environment
+		if err := node.Content[i+1].Decode(&env); err != nil {
+			return err
+		}
+		env.Name = name
+		envs = append(envs, env)
+	}
+
+	*es = envs
+	return nil
+}
+
+// EndpointURLs keeps the declaration order of the urls mapping
+type EndpointURLs []EndpointURL
+
+func (us *EndpointURLs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: urls must be a mapping of transport to URL", node.Line)
+	}
+
+	urls := make(EndpointURLs, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		transport, u := node.Content[i].Value, node.Content[i+1]
+		if u.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: the URL of %s must be a string", u.Line, transport)
+		}
+		urls = append(urls, EndpointURL{Transport: transport, URL: u.Value})
+	}
+
+	*us = urls
+	return nil
+}
+
+// validate checks the settings and fills in defaults
+func (e *Endpoints) validate() error {
+	if len(e.Environments) == 0 {
+		return fmt.Errorf("endpoints: no environments are defined")
+	}
+	if e.Default == "" {
+		e.Default = e.Environments[0].Name
+	}
+	if e.Environment(e.Default) == nil {
+		return fmt.Errorf("endpoints: default environment %q is not defined; defined environments: %s", e.Default, strings.Join(e.Names(), ", "))
+	}
+
+	for i := range e.Environments {
+		if err := e.Environments[i].validate(); err != nil {
+			return fmt.Errorf("endpoints.environments.%s: %w", e.Environments[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func (e *Environment) validate() error {
+	if len(e.URLs) == 0 {
+		return fmt.Errorf("no urls are defined")
+	}
+	for i, u := range e.URLs {
+		if !slices.Contains(EndpointTransports, u.Transport) {
+			return fmt.Errorf("unknown transport %q; expected one of %s", u.Transport, strings.Join(EndpointTransports, ", "))
+		}
+		if slices.ContainsFunc(e.URLs[:i], func(other EndpointURL) bool { return other.Transport == u.Transport }) {
+			return fmt.Errorf("transport %s has two URLs", u.Transport)
+		}
+		if err := checkEndpointURL(u); err != nil {
+			return err
+		}
+		if secure, ok := secureSchemes[scheme(u.URL)]; ok && e.TLS != nil {
+			return fmt.Errorf("tls is set, but the %s URL %s is not secure; use %s://", u.Transport, u.URL, secure)
+		}
+	}
+
+	for i, transport := range e.Prefer {
+		if !slices.ContainsFunc(e.URLs, func(u EndpointURL) bool { return u.Transport == transport }) {
+			return fmt.Errorf("prefer lists %s, which has no URL", transport)
+		}
+		if slices.Contains(e.Prefer[:i], transport) {
+			return fmt.Errorf("prefer lists %s twice", transport)
+		}
+	}
+
+	if e.TLS != nil {
+		if strings.ContainsAny(e.TLS.ServerName, "\"'\\$ ") {
+			return fmt.Errorf("tls: server_name %q must be a host name", e.TLS.ServerName)
+		}
+		switch e.TLS.MinVersion {
+		case "":
+			e.TLS.MinVersion = "1.2"
+		case "1.2", "1.3":
+		default:
+			return fmt.Errorf("tls: min_version must be 1.2 or 1.3, not %q", e.TLS.MinVersion)
+		}
+	}
+	return nil
+}
+
+// checkEndpointURL checks that u is a host:port, or a URL of a scheme of its transport
+func checkEndpointURL(u EndpointURL) error {
+	// URLs are written into string literals of every language
+	if strings.ContainsAny(u.URL, "\"'\\$ ") {
+		return fmt.Errorf("the %s URL %q must not contain quotes, backslashes, $ or spaces", u.Transport, u.URL)
+	}
+	schemes, ok := endpointSchemes[u.Transport]
+	if !ok {
+		if _, port, err := net.SplitHostPort(u.URL); err != nil || port == "" {
+			return fmt.Errorf("the %s URL %q must be a host:port, e.g. play.example.com:9000", u.Transport, u.URL)
+		}
+		return nil
+	}
+
+	parsed, err := url.Parse(u.URL)
+	if err != nil || parsed.Host == "" || !slices.Contains(schemes, parsed.Scheme) {
+		return fmt.Errorf("the %s URL %q must be a %s:// URL", u.Transport, u.URL, strings.Join(schemes, ":// or "))
+	}
+	return nil
+}
+
+// scheme returns the scheme of a URL, or "" for a host:port
+func scheme(u string) string {
+	scheme, _, ok := strings.Cut(u, "://")
+	if !ok {
+		return ""
+	}
+	return scheme
+}
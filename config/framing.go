@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Framing describes the frame layout of the stream transports (TCP, KCP, QUIC), so they can
+// speak a pre-existing proprietary protocol, e.g.
+//
+//	framing:
+//	  endian: little
+//	  magic: "53 47"
+//	  order: [magic, type, length]
+//	  length: 2
+//	  type: 2
+//	  types:
+//	    login_req: 0x0101
+//
+// A frame is the header fields in the given order, then the body, then an optional checksum.
+type Framing struct {
+	Endian string   `yaml:"endian"` // Byte order of the length and type fields: "big" (default) or "little"
+	Magic  string   `yaml:"magic"`  // Hex bytes every frame starts with (e.g., "53 47"), optional
+	Order  []string `yaml:"order"`  // Order of the header fields (magic, length, type); defaults to magic, length, type
+	Length int      `yaml:"length"` // Width of the length field in bytes: 1, 2, or 4 (default 4)
+
+	// Type is the width of a message type field in bytes: 1, 2, or 4. The body is then the
+	// payload message alone, identified by its type code. With 0 (the default), the body is a
+	// whole GamePacket.
+	Type int `yaml:"type"`
+
+	// Types maps payload field names to their type codes; payloads not listed use their field number
+	Types map[string]uint32 `yaml:"types"`
+
+	LengthIncludesHeader bool `yaml:"length_includes_header"` // The length counts the header as well as the body
+	Checksum             bool `yaml:"checksum"`               // A CRC32C of the header and body follows the body
+}
+
+// MagicBytes returns the decoded magic bytes
+func (f *Framing) MagicBytes() ([]byte, error) {
+	magic, err := hex.DecodeString(strings.ReplaceAll(strings.TrimPrefix(f.Magic, "0x"), " ", ""))
+	if err != nil {
+		return nil, fmt.Errorf("framing: magic %q is not hex: %w", f.Magic, err)
+	}
+	return magic, nil
+}
+
+// validate checks the layout and fills in defaults
+func (f *Framing) validate() error {
+	switch f.Endian {
+	case "":
+		f.Endian = "big"
+	case "big", "little":
+	default:
+		return fmt.Errorf("framing: endian must be big or little, not %q", f.Endian)
+	}
+
+	if f.Length == 0 {
+		f.Length = 4
+	}
+	if !validWidth(f.Length) {
+		return fmt.Errorf("framing: length must be 1, 2, or 4 bytes, not %d", f.Length)
+	}
+	if f.Type != 0 && !validWidth(f.Type) {
+		return fmt.Errorf("framing: type must be 0, 1, 2, or 4 bytes, not %d", f.Type)
+	}
+	if f.Type == 0 && len(f.Types) > 0 {
+		return fmt.Errorf("framing: types are set, but there is no type field")
+	}
+
+	magic, err := f.MagicBytes()
+	if err != nil {
+		return err
+	}
+
+	present := []string{"length"}
+	if len(magic) > 0 {
+		present = append(present, "magic")
+	}
+	if f.Type > 0 {
+		present = append(present, "type")
+	}
+	if len(f.Order) == 0 {
+		for _, name := range []string{"magic", "length", "type"} {
+			if slices.Contains(present, name) {
+				f.Order = append(f.Order, name)
+			}
+		}
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for _, name := range f.Order {
+		if !slices.Contains(present, name) {
+			return fmt.Errorf("framing: order lists %q, which is unknown or not configured", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("framing: order lists %q twice", name)
+		}
+		seen[name] = true
+	}
+	for _, name := range present {
+		if !seen[name] {
+			return fmt.Errorf("framing: order is missing %q", name)
+		}
+	}
+	return nil
+}
+
+func validWidth(n int) bool {
+	return n == 1 || n == 2 || n == 4
+}
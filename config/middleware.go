@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// MiddlewarePresets are the middleware presets of the generated Go server
+var MiddlewarePresets = []string{"logging", "metrics", "recover", "auth", "rate_limit"}
+
+// Middleware enables middleware presets of the generated Go server, which run around the dispatch
+// of every packet in the order listed, the first outermost, e.g.
+//
+//	middleware:
+//	  presets: [logging, metrics, recover, auth, rate_limit]
+//	  log_level: debug
+//	  require_auth: true
+//	  rate_limit: 20
+//	  burst: 40
+//
+// The generated transports serve every connection with them, so enabling a preset takes no code.
+type Middleware struct {
+	// Presets are the middleware to run, from MiddlewarePresets:
+	//   - logging logs every packet with its payload, duration and error, with log/slog
+	//   - metrics counts the packets, errors and handling time of every payload
+	//   - recover turns a panicking handler into an error, instead of crashing the server
+	//   - auth rejects payloads requiring an authenticated session from others
+	//   - rate_limit rejects the packets of a session above the rate of their payload
+	Presets []string `yaml:"presets"`
+
+	// LogLevel is the slog level of the packets logged by the logging preset: debug or info
+	// (default info). Packets whose dispatch fails are logged as warnings.
+	LogLevel string `yaml:"log_level"`
+
+	// RequireAuth makes the auth preset require an authenticated session for every client
+	// payload, except those with option (socketgen.requires_auth) = false; without it, only
+	// payloads with option (socketgen.requires_auth) do
+	RequireAuth bool `yaml:"require_auth"`
+
+	// RateLimit is the packets per second the rate_limit preset lets a session send of every
+	// payload without option (socketgen.rate_limit); 0 leaves them unlimited
+	RateLimit float64 `yaml:"rate_limit"`
+
+	// Burst is the packets a session may send at once above its rates (default twice the rate)
+	Burst int `yaml:"burst"`
+}
+
+// Uses reports whether preset is enabled
+func (m *Middleware) Uses(preset string) bool {
+	return slices.Contains(m.Presets, preset)
+}
+
+// validate checks the settings and fills in defaults
+func (m *Middleware) validate() error {
+	for i, preset := range m.Presets {
+		if !slices.Contains(MiddlewarePresets, preset) {
+			return fmt.Errorf("middleware: unknown preset %q; expected one of %s", preset, strings.Join(MiddlewarePresets, ", "))
+		}
+		if slices.Contains(m.Presets[:i], preset) {
+			return fmt.Errorf("middleware: preset %s is listed twice", preset)
+		}
+	}
+
+	switch m.LogLevel {
+	case "":
+		m.LogLevel = "info"
+	case "debug", "info":
+	default:
+		return fmt.Errorf("middleware: log_level must be debug or info, not %q", m.LogLevel)
+	}
+
+	if m.RateLimit < 0 || math.IsNaN(m.RateLimit) || math.IsInf(m.RateLimit, 0) {
+		return fmt.Errorf("middleware: rate_limit must be a positive number of packets per second, not %g", m.RateLimit)
+	}
+	if m.Burst < 0 {
+		return fmt.Errorf("middleware: burst must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxFieldNumber is the largest field number protobuf allows
+const maxFieldNumber = 536870911
+
+// Range is the block of payload field numbers of a feature
+type Range struct {
+	Name     string // Feature name (e.g., "chat")
+	Min, Max int32  // Inclusive bounds
+}
+
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprintf("%s (%d)", r.Name, r.Min)
+	}
+	return fmt.Sprintf("%s (%d-%d)", r.Name, r.Min, r.Max)
+}
+
+// Contains reports whether number is in the range
+func (r Range) Contains(number int32) bool {
+	return r.Min <= number && number <= r.Max
+}
+
+// Ranges keeps the declaration order of the ranges mapping
+type Ranges []Range
+
+// Find returns the range named name
+func (rs Ranges) Find(name string) (Range, bool) {
+	for _, r := range rs {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Range{}, false
+}
+
+// Of returns the range containing number
+func (rs Ranges) Of(number int32) (Range, bool) {
+	for _, r := range rs {
+		if r.Contains(number) {
+			return r, true
+		}
+	}
+	return Range{}, false
+}
+
+// Names lists the names of the ranges in declaration order
+func (rs Ranges) Names() []string {
+	names := make([]string, len(rs))
+	for i, r := range rs {
+		names[i] = r.Name
+	}
+	return names
+}
+
+func (rs *Ranges) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: ranges must be a mapping of feature name to field numbers (e.g., 10-19)", node.Line)
+	}
+
+	ranges := make(Ranges, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i].Value, node.Content[i+1]
+		r, err := parseRange(name, value.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: ranges.%s: %w", value.Line, name, err)
+		}
+		for _, other := range ranges {
+			if r.Min <= other.Max && other.Min <= r.Max {
+				return fmt.Errorf("line %d: ranges.%s overlaps %s", value.Line, name, other)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+
+	*rs = ranges
+	return nil
+}
+
+// parseRange parses the bounds of a range, "min-max" or a single number
+func parseRange(name, s string) (Range, error) {
+	lo, hi, found := strings.Cut(s, "-")
+	if !found {
+		hi = lo
+	}
+	first, err := strconv.ParseInt(strings.TrimSpace(lo), 10, 32)
+	if err != nil {
+		return Range{}, fmt.Errorf("%q is not a range of field numbers (e.g., 10-19)", s)
+	}
+	last, err := strconv.ParseInt(strings.TrimSpace(hi), 10, 32)
+	if err != nil {
+		return Range{}, fmt.Errorf("%q is not a range of field numbers (e.g., 10-19)", s)
+	}
+	if first < 1 || last > maxFieldNumber || first > last {
+		return Range{}, fmt.Errorf("%q is not a range of field numbers between 1 and %d", s, maxFieldNumber)
+	}
+	return Range{Name: name, Min: int32(first), Max: int32(last)}, nil
+}
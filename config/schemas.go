@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaName is the form of a schema name: it prefixes Go identifiers once in PascalCase
+var schemaName = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// Schema is a proto schema of the project besides packet.proto
+type Schema struct {
+	Name string // snake_case name (e.g., "social")
+	File string // Proto file (e.g., "social.proto")
+}
+
+// Prefix returns the name in PascalCase (e.g., "Social"), which prefixes the generated identifiers
+// of the schema
+func (s Schema) Prefix() string {
+	var b strings.Builder
+	for part := range strings.SplitSeq(s.Name, "_") {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// Wrapper returns the name of the envelope the schema defines (e.g., "SocialPacket")
+func (s Schema) Wrapper() string {
+	return s.Prefix() + "Packet"
+}
+
+// Schemas keeps the declaration order of the schemas mapping
+type Schemas []Schema
+
+func (ss *Schemas) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: schemas must be a mapping of schema name to proto file", node.Line)
+	}
+
+	schemas := make(Schemas, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, file := node.Content[i].Value, node.Content[i+1]
+		if !schemaName.MatchString(name) {
+			return fmt.Errorf("line %d: schema name %q must be snake_case (e.g., social, match_making)", node.Content[i].Line, name)
+		}
+		if name == "game" {
+			return fmt.Errorf("line %d: schema name game is taken by packet.proto, whose envelope is GamePacket", node.Content[i].Line)
+		}
+		if file.Kind != yaml.ScalarNode || !strings.HasSuffix(file.Value, ".proto") {
+			return fmt.Errorf("line %d: schemas.%s must name a proto file (e.g., %s.proto)", file.Line, name, name)
+		}
+		if file.Value == "packet.proto" {
+			return fmt.Errorf("line %d: schemas.%s: packet.proto is always a schema of the project", file.Line, name)
+		}
+		for _, other := range schemas {
+			if other.File == file.Value {
+				return fmt.Errorf("line %d: schemas.%s and schemas.%s name the same file", file.Line, name, other.Name)
+			}
+		}
+		schemas = append(schemas, Schema{Name: name, File: file.Value})
+	}
+
+	*ss = schemas
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+)
+
+// MaxFrameSize is the largest packet the generated transports accept (MaxFrameSize in Go)
+const MaxFrameSize = 1 << 20
+
+// Security hardens the generated Go server, e.g.
+//
+//	security:
+//	  max_packet_size: 65536
+//	  rate_limit: 20
+//	  burst: 40
+//	  require_auth: true
+//	  allowed_origins: [https://play.example.com]
+//
+// When set, gen generates a Guard that every transport runs before dispatch, enforcing the
+// options (socketgen.requires_auth) and (socketgen.rate_limit) as well as these defaults.
+type Security struct {
+	// MaxPacketSize is the largest packet a client may send, in bytes (default MaxFrameSize)
+	MaxPacketSize int `yaml:"max_packet_size"`
+
+	// RateLimit is the packets per second a session may send of every client payload without
+	// option (socketgen.rate_limit); 0 leaves them unlimited
+	RateLimit float64 `yaml:"rate_limit"`
+
+	// Burst is the packets a session may send at once above its rates (default twice the rate)
+	Burst int `yaml:"burst"`
+
+	// RequireAuth requires an authenticated session for every client payload, except those with
+	// option (socketgen.requires_auth) = false, such as the login request
+	RequireAuth bool `yaml:"require_auth"`
+
+	// AllowedOrigins are the origins browsers may connect from over WebSocket and Socket.IO,
+	// besides the server's own; clients that send no Origin header, which are not browsers, are
+	// always accepted
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// HardenedSecurity returns the defaults socketgen audit --security --fix proposes
+func HardenedSecurity() Security {
+	return Security{MaxPacketSize: 64 << 10, RateLimit: 20, Burst: 40, RequireAuth: true}
+}
+
+// validate checks the settings and fills in defaults
+func (s *Security) validate() error {
+	switch {
+	case s.MaxPacketSize == 0:
+		s.MaxPacketSize = MaxFrameSize
+	case s.MaxPacketSize < 0 || s.MaxPacketSize > MaxFrameSize:
+		return fmt.Errorf("security: max_packet_size must be between 1 and %d bytes, not %d", MaxFrameSize, s.MaxPacketSize)
+	}
+
+	if s.RateLimit < 0 || math.IsNaN(s.RateLimit) || math.IsInf(s.RateLimit, 0) {
+		return fmt.Errorf("security: rate_limit must be a positive number of packets per second, not %g", s.RateLimit)
+	}
+	if s.Burst < 0 {
+		return fmt.Errorf("security: burst must not be negative")
+	}
+
+	for _, origin := range s.AllowedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("security: allowed origin %q must be a scheme and host, e.g. https://play.example.com", origin)
+		}
+	}
+	return nil
+}
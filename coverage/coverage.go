@@ -0,0 +1,70 @@
+// Package coverage merges the handler coverage reports written by generated code
+// ('socketgen gen --coverage') and compares them, and recorded traffic, against the schema.
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Report is the JSON coverage report written by the generated Coverage types
+type Report struct {
+	SchemaVersion string         `json:"schemaVersion"`
+	Payloads      map[string]int `json:"payloads"` // Payload field name -> handler runs
+}
+
+// Load reads a coverage report
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coverage report: %w", err)
+	}
+
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse coverage report %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Rename returns payloads with the runs of each payload counted under the name current returns
+// for it, such as the successor of a renamed payload; payloads it does not know keep their name
+func Rename(payloads map[string]int, current func(name string) (string, bool)) map[string]int {
+	renamed := make(map[string]int, len(payloads))
+	for payload, n := range payloads {
+		if name, ok := current(payload); ok {
+			payload = name
+		}
+		renamed[payload] += n
+	}
+	return renamed
+}
+
+// Line is the merged coverage of one payload
+type Line struct {
+	Payload string
+	Runs    int
+}
+
+// Merge sums reports per payload, listing every payload of result in schema order.
+// Payloads a report knows but the schema does not are returned as stale.
+func Merge(result *parser.ParseResult, reports []*Report) (lines []Line, stale []string) {
+	runs := map[string]int{}
+	for _, r := range reports {
+		for payload, n := range r.Payloads {
+			runs[payload] += n
+		}
+	}
+
+	for _, p := range result.Payloads {
+		lines = append(lines, Line{Payload: p.FieldName, Runs: runs[p.FieldName]})
+		delete(runs, p.FieldName)
+	}
+	for payload := range runs {
+		stale = append(stale, payload)
+	}
+	return lines, stale
+}
@@ -0,0 +1,36 @@
+package coverage
+
+import (
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/recording"
+)
+
+// Usage is the evidence that a payload is in use: its packets in recorded traffic, and its handler
+// runs in coverage reports
+type Usage struct {
+	Payload  string
+	Received int // Recorded packets sent by clients
+	Sent     int // Recorded packets sent by the server
+	Handled  int // Handler runs
+}
+
+// Usages counts the packets of every payload of result in entries, and its handler runs in
+// reports, in schema order. Payloads a report knows but the schema does not are returned as stale;
+// recordings only hold payloads of the schema, since they are loaded against it.
+func Usages(result *parser.ParseResult, entries []recording.Entry, reports []*Report) (usages []Usage, stale []string) {
+	lines, stale := Merge(result, reports)
+
+	received, sent := map[string]int{}, map[string]int{}
+	for _, e := range entries {
+		if e.Direction == recording.Outbound {
+			sent[e.Payload]++
+		} else {
+			received[e.Payload]++
+		}
+	}
+
+	for _, l := range lines {
+		usages = append(usages, Usage{Payload: l.Payload, Received: received[l.Payload], Sent: sent[l.Payload], Handled: l.Runs})
+	}
+	return usages, stale
+}
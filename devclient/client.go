@@ -0,0 +1,244 @@
+package devclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/registry"
+	"github.com/snowmerak/socketgen/sample"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Client is an interactive WebSocket client that composes GamePackets from JSON
+// using the schema alone and pretty-prints everything the server sends back
+type Client struct {
+	Schema *parser.Schema
+	Out    io.Writer
+
+	conn   *websocket.Conn
+	header *dynamicpb.Message // Header attached to every outgoing packet, nil if unset
+	mu     sync.Mutex         // Serializes writes to Out
+}
+
+// Dial connects to a WebSocket server speaking the GamePacket protocol.
+// A warning is printed if the server advertises a different schema version.
+func Dial(url string, schema *parser.Schema) (*Client, error) {
+	conn, res, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", url, err)
+	}
+
+	c := &Client{
+		Schema: schema,
+		Out:    os.Stdout,
+		conn:   conn,
+	}
+	if advertised := res.Header.Get(registry.VersionHeader); advertised != "" && advertised != schema.Version {
+		c.logf("Warning: server schema version %s differs from local %s\n", advertised, schema.Version)
+	}
+	return c, nil
+}
+
+// Close closes the underlying connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// SetHeader sets the header attached to every outgoing packet from protobuf JSON
+func (c *Client) SetHeader(body []byte) error {
+	if c.Schema.Header == nil {
+		return fmt.Errorf("GamePacket has no header field")
+	}
+
+	header := dynamicpb.NewMessage(c.Schema.Header.Message())
+	if err := c.Schema.ParseJSON(body, header); err != nil {
+		return err
+	}
+	c.header = header
+	return nil
+}
+
+// Send wraps the JSON payload body into a GamePacket under the given payload field and sends it
+func (c *Client) Send(payload string, body []byte) error {
+	field := c.Schema.PayloadByName(payload)
+	if field == nil {
+		return fmt.Errorf("unknown payload %q", payload)
+	}
+
+	msg := dynamicpb.NewMessage(field.Message())
+	if err := c.Schema.ParseJSON(body, msg); err != nil {
+		return fmt.Errorf("invalid %s: %w", payload, err)
+	}
+
+	pkt := c.Schema.NewPacket()
+	if c.header != nil {
+		pkt.Set(c.Schema.Header, protoreflect.ValueOfMessage(c.header))
+	}
+	pkt.Set(field, protoreflect.ValueOfMessage(msg))
+
+	data, err := proto.Marshal(pkt)
+	if err != nil {
+		return err
+	}
+	if err := c.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return err
+	}
+
+	c.printPacket("->", pkt)
+	return nil
+}
+
+// Receive reads packets until the connection closes, printing each one
+func (c *Client) Receive() error {
+	for {
+		msgType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if msgType != websocket.BinaryMessage {
+			c.logf("ignoring non-binary message\n")
+			continue
+		}
+
+		pkt, err := c.Schema.Decode(data)
+		if err != nil {
+			c.logf("decode error: %v\n", err)
+			continue
+		}
+		c.printPacket("<-", pkt)
+	}
+}
+
+// RunREPL reads commands from in until EOF or .quit.
+//
+// A line of the form `<payload_field> <json>` sends a packet (e.g. `login_req {"id": "alice"}`);
+// lines starting with '.' are client commands (see .help).
+func (c *Client) RunREPL(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	c.logf("Type .help for commands.\n")
+
+	for {
+		c.logf("> ")
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		name, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		body := arg
+		if body == "" {
+			body = "{}"
+		}
+
+		var err error
+		switch name {
+		case ".quit", ".exit":
+			return nil
+		case ".help":
+			c.printHelp()
+		case ".payloads":
+			c.printPayloads()
+		case ".describe":
+			err = c.describe(arg)
+		case ".sample":
+			err = c.printSample(arg)
+		case ".header":
+			err = c.SetHeader([]byte(body))
+		default:
+			err = c.Send(name, []byte(body))
+		}
+
+		if err != nil {
+			c.logf("error: %v\n", err)
+		}
+	}
+}
+
+func (c *Client) printHelp() {
+	c.logf(`Commands:
+  <payload> [json]     Send a packet, e.g. login_req {"id": "alice"}
+  .payloads            List payload fields
+  .describe <payload>  Show the fields of a payload message
+  .sample <payload>    Show a packet with sample values, to copy and edit
+  .header <json>       Set the header attached to outgoing packets
+  .quit                Exit
+`)
+}
+
+func (c *Client) printPayloads() {
+	fields := c.Schema.Payload.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		c.logf("  %s (%s)\n", field.Name(), field.Message().FullName())
+	}
+}
+
+func (c *Client) describe(payload string) error {
+	field := c.Schema.PayloadByName(payload)
+	if field == nil {
+		return fmt.Errorf("unknown payload %q", payload)
+	}
+
+	fields := field.Message().Fields()
+	c.logf("%s {\n", field.Message().FullName())
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.Get(i)
+		typeName := f.Kind().String()
+		if f.Message() != nil {
+			typeName = string(f.Message().FullName())
+		} else if f.Enum() != nil {
+			typeName = string(f.Enum().FullName())
+		}
+		if f.IsList() {
+			typeName = "repeated " + typeName
+		} else if f.IsMap() {
+			typeName = fmt.Sprintf("map<%s, %s>", f.MapKey().Kind(), f.MapValue().Kind())
+		}
+		c.logf("  %q: %s\n", f.JSONName(), typeName)
+	}
+	c.logf("}\n")
+	return nil
+}
+
+// printSample prints a line sending a sample of payload
+func (c *Client) printSample(payload string) error {
+	field := c.Schema.PayloadByName(payload)
+	if field == nil {
+		return fmt.Errorf("unknown payload %q", payload)
+	}
+
+	data, err := sample.JSON(field.Message())
+	if err != nil {
+		return err
+	}
+	c.logf("%s %s\n", payload, data)
+	return nil
+}
+
+func (c *Client) printPacket(direction string, pkt protoreflect.Message) {
+	name := "<empty>"
+	if field := c.Schema.PayloadField(pkt); field != nil {
+		name = string(field.Name())
+	}
+
+	c.logf("%s %s\n%s\n", direction, name, c.Schema.Format(pkt))
+}
+
+func (c *Client) logf(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.Out, format, args...)
+}
@@ -0,0 +1,53 @@
+package devserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Fixtures maps a payload field name (e.g. "login_req") to the packets sent back
+// when a packet carrying that payload is received
+type Fixtures map[string][]*dynamicpb.Message
+
+// LoadFixtures reads a JSON fixture file and validates it against the schema.
+//
+// The file is an object keyed by payload field name, where each value is a list of
+// GamePackets in protobuf JSON form:
+//
+//	{
+//	  "login_req": [ { "loginRes": { "success": true } } ]
+//	}
+//
+// Responses without a header inherit the header of the request they answer.
+func LoadFixtures(path string, schema *parser.Schema) (Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures file: %w", err)
+	}
+
+	var raw map[string][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures file: %w", err)
+	}
+
+	fixtures := make(Fixtures, len(raw))
+	for name, packets := range raw {
+		if schema.PayloadByName(name) == nil {
+			return nil, fmt.Errorf("fixture %q does not match any payload field", name)
+		}
+
+		for i, rawPkt := range packets {
+			pkt := schema.NewPacket()
+			if err := schema.ParseJSON(rawPkt, pkt); err != nil {
+				return nil, fmt.Errorf("fixture %s[%d]: %w", name, i, err)
+			}
+			fixtures[name] = append(fixtures[name], pkt)
+		}
+	}
+
+	return fixtures, nil
+}
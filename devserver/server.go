@@ -0,0 +1,163 @@
+package devserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/recording"
+	"github.com/snowmerak/socketgen/registry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Server is a development WebSocket server that decodes every incoming GamePacket
+// using the schema alone and prints it. It can optionally echo packets back or
+// answer them from canned fixtures. The schema and fixtures can be swapped with Reload
+// while connections are open.
+type Server struct {
+	Echo     bool
+	Out      io.Writer
+	Recorder *recording.Recorder // Records every packet in both directions when set
+
+	mu sync.Mutex // Serializes writes to Out
+
+	stateMu  sync.RWMutex
+	schema   *parser.Schema
+	fixtures Fixtures
+}
+
+// New creates a dev server for the given schema and fixtures (which may be nil) that logs to stdout
+func New(schema *parser.Schema, fixtures Fixtures) *Server {
+	return &Server{
+		Out:      os.Stdout,
+		schema:   schema,
+		fixtures: fixtures,
+	}
+}
+
+// Reload replaces the schema and fixtures. Open connections decode their next packet with the new schema.
+func (s *Server) Reload(schema *parser.Schema, fixtures Fixtures) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.schema = schema
+	s.fixtures = fixtures
+}
+
+func (s *Server) current() (*parser.Schema, Fixtures) {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.schema, s.fixtures
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	schema, _ := s.current()
+
+	// Advertise the schema version so clients can detect that they are stale
+	header := http.Header{}
+	header.Set(registry.VersionHeader, schema.Version)
+
+	upgrader := websocket.Upgrader{
+		// Dev server: accept connections from any origin
+		CheckOrigin:  func(r *http.Request) bool { return true },
+		Subprotocols: []string{"socketgen." + schema.Version},
+	}
+
+	conn, err := upgrader.Upgrade(w, r, header)
+	if err != nil {
+		s.logf("upgrade error: %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	peer := conn.RemoteAddr().String()
+	s.logf("[%s] connected\n", peer)
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			s.logf("[%s] disconnected: %v\n", peer, err)
+			return
+		}
+		if msgType != websocket.BinaryMessage {
+			s.logf("[%s] ignoring non-binary message\n", peer)
+			continue
+		}
+
+		schema, fixtures := s.current()
+
+		pkt, err := schema.Decode(data)
+		if err != nil {
+			s.logf("[%s] decode error: %v\n", peer, err)
+			continue
+		}
+		s.printPacket(schema, peer, "<-", pkt)
+
+		if err := s.respond(conn, schema, fixtures, peer, pkt, data); err != nil {
+			s.logf("[%s] write error: %v\n", peer, err)
+			return
+		}
+	}
+}
+
+// respond answers a decoded packet from fixtures if any match, otherwise echoes it when enabled
+func (s *Server) respond(conn *websocket.Conn, schema *parser.Schema, fixtures Fixtures, peer string, req protoreflect.Message, raw []byte) error {
+	field := schema.PayloadField(req)
+	if field != nil {
+		if responses, ok := fixtures[string(field.Name())]; ok {
+			for _, fixture := range responses {
+				res := proto.Clone(fixture).ProtoReflect()
+				if schema.Header != nil && !res.Has(schema.Header) && req.Has(schema.Header) {
+					res.Set(schema.Header, req.Get(schema.Header))
+				}
+
+				data, err := proto.Marshal(res.Interface())
+				if err != nil {
+					return fmt.Errorf("failed to encode fixture: %w", err)
+				}
+				if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+					return err
+				}
+				s.printPacket(schema, peer, "->", res)
+			}
+			return nil
+		}
+	}
+
+	if s.Echo {
+		if err := conn.WriteMessage(websocket.BinaryMessage, raw); err != nil {
+			return err
+		}
+		s.printPacket(schema, peer, "->", req)
+	}
+	return nil
+}
+
+func (s *Server) printPacket(schema *parser.Schema, peer, direction string, pkt protoreflect.Message) {
+	name := "<empty>"
+	if field := schema.PayloadField(pkt); field != nil {
+		name = string(field.Name())
+	}
+
+	s.logf("[%s] %s %s\n%s\n", peer, direction, name, schema.Format(pkt))
+
+	if s.Recorder != nil {
+		dir := recording.Inbound
+		if direction == "->" {
+			dir = recording.Outbound
+		}
+		if err := s.Recorder.Record(schema, peer, dir, pkt); err != nil {
+			s.logf("[%s] record error: %v\n", peer, err)
+		}
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.Out, format, args...)
+}
@@ -0,0 +1,180 @@
+// Package dynamic routes GamePackets at runtime using only the schema, without generated code.
+//
+// It is meant for prototyping, scripting gateways, and iterating on a schema before committing to
+// generated dispatchers:
+//
+//	router, err := dynamic.Load("packet.proto")
+//	router.Handle("login_req", func(header, msg *dynamicpb.Message) error {
+//		id := msg.Get(msg.Descriptor().Fields().ByName("id")).String()
+//		...
+//	})
+//	err = router.Serve(stream, func(err error) { log.Println(err) })
+package dynamic
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// HandlerFunc handles one payload. header is nil if the packet carries no header.
+type HandlerFunc func(header, msg *dynamicpb.Message) error
+
+// PacketStream abstracts the transport, matching the PacketStream interface of generated code
+type PacketStream interface {
+	ReadPacket() ([]byte, error)
+	WritePacket([]byte) error
+}
+
+// Router dispatches packets to handlers registered by payload field name (e.g. "login_req")
+type Router struct {
+	mu       sync.RWMutex
+	schema   *parser.Schema
+	handlers map[string]HandlerFunc
+	fallback HandlerFunc
+}
+
+// NewRouter creates a router for the given schema
+func NewRouter(schema *parser.Schema) *Router {
+	return &Router{
+		schema:   schema,
+		handlers: map[string]HandlerFunc{},
+	}
+}
+
+// Load compiles protoFile with protoc and creates a router for it
+func Load(protoFile string) (*Router, error) {
+	schema, err := parser.LoadSchema(protoFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewRouter(schema), nil
+}
+
+// LoadDescriptorSet creates a router from a serialized FileDescriptorSet file
+func LoadDescriptorSet(path string) (*Router, error) {
+	schema, err := parser.LoadSchemaFromDescriptorSet(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRouter(schema), nil
+}
+
+// Schema returns the schema packets are currently decoded with
+func (r *Router) Schema() *parser.Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schema
+}
+
+// Handle registers the handler for a payload field name
+func (r *Router) Handle(payload string, h HandlerFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.schema.PayloadByName(payload) == nil {
+		return fmt.Errorf("unknown payload %q", payload)
+	}
+	r.handlers[payload] = h
+	return nil
+}
+
+// HandleDefault registers the handler for payloads without a registered handler
+func (r *Router) HandleDefault(h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = h
+}
+
+// Dispatch decodes a packet and calls the handler registered for its payload
+func (r *Router) Dispatch(data []byte) error {
+	r.mu.RLock()
+	schema := r.schema
+	r.mu.RUnlock()
+
+	pkt, err := schema.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	field := schema.PayloadField(pkt)
+	if field == nil {
+		return fmt.Errorf("unknown packet type")
+	}
+
+	r.mu.RLock()
+	h, ok := r.handlers[string(field.Name())]
+	if !ok {
+		h = r.fallback
+	}
+	r.mu.RUnlock()
+
+	if h == nil {
+		return fmt.Errorf("no handler registered for %s", field.Name())
+	}
+
+	var header *dynamicpb.Message
+	if schema.Header != nil && pkt.Has(schema.Header) {
+		header = pkt.Get(schema.Header).Message().Interface().(*dynamicpb.Message)
+	}
+	msg := pkt.Get(field).Message().Interface().(*dynamicpb.Message)
+
+	return h(header, msg)
+}
+
+// NewPayload returns an empty message for the payload field name
+func (r *Router) NewPayload(payload string) (*dynamicpb.Message, error) {
+	field := r.Schema().PayloadByName(payload)
+	if field == nil {
+		return nil, fmt.Errorf("unknown payload %q", payload)
+	}
+	return dynamicpb.NewMessage(field.Message()), nil
+}
+
+// Encode wraps msg into a GamePacket under the payload field name. header may be nil.
+func (r *Router) Encode(payload string, header, msg proto.Message) ([]byte, error) {
+	schema := r.Schema()
+
+	field := schema.PayloadByName(payload)
+	if field == nil {
+		return nil, fmt.Errorf("unknown payload %q", payload)
+	}
+	if msg.ProtoReflect().Descriptor().FullName() != field.Message().FullName() {
+		return nil, fmt.Errorf("payload %s expects %s, got %s", payload, field.Message().FullName(), msg.ProtoReflect().Descriptor().FullName())
+	}
+
+	pkt := schema.NewPacket()
+	if header != nil && schema.Header != nil {
+		pkt.Set(schema.Header, protoreflect.ValueOfMessage(header.ProtoReflect()))
+	}
+	pkt.Set(field, protoreflect.ValueOfMessage(msg.ProtoReflect()))
+
+	return proto.Marshal(pkt)
+}
+
+// Send encodes msg and writes it to the stream
+func (r *Router) Send(stream PacketStream, payload string, header, msg proto.Message) error {
+	data, err := r.Encode(payload, header, msg)
+	if err != nil {
+		return err
+	}
+	return stream.WritePacket(data)
+}
+
+// Serve reads packets from the stream and dispatches them until reading fails.
+// Dispatch errors are reported through onError (if non-nil) and do not stop the loop.
+func (r *Router) Serve(stream PacketStream, onError func(error)) error {
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if err := r.Dispatch(data); err != nil && onError != nil {
+			onError(fmt.Errorf("dispatch error: %w", err))
+		}
+	}
+}
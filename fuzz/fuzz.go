@@ -0,0 +1,81 @@
+// Package fuzz runs the fuzz tests generated with 'socketgen gen --fuzz' and parses their results.
+package fuzz
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TestFile is the file 'socketgen gen --fuzz' writes the fuzz tests to
+const TestFile = "packet_fuzz_test.go"
+
+// Result is the outcome of fuzzing one target
+type Result struct {
+	Name   string        // Fuzz test name (e.g., "FuzzDispatch")
+	OK     bool          // No failing input was found
+	Input  string        // File the failing input was written to, relative to the package; empty if OK
+	Output string        // Output of 'go test' describing the failure; empty if OK
+	Took   time.Duration // Time spent, including building
+}
+
+var fuzzFunc = regexp.MustCompile(`(?m)^func (Fuzz\w+)\(\w+ \*testing\.F\)`)
+
+// Targets returns the fuzz tests of the generated package in dir, in file order
+func Targets(dir string) ([]string, error) {
+	src, err := os.ReadFile(filepath.Join(dir, TestFile))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range fuzzFunc.FindAllSubmatch(src, -1) {
+		names = append(names, string(m[1]))
+	}
+	return names, nil
+}
+
+// Run fuzzes target in the Go package in dir for budget. The output of 'go test' is copied to
+// log. An error is returned only if the target could not be run; a failing input is reported in
+// the result.
+func Run(dir, target string, budget time.Duration, log io.Writer) (Result, error) {
+	res := Result{Name: target}
+	start := time.Now()
+
+	var out bytes.Buffer
+	cmd := exec.Command("go", "test", "-run", "^$", "-fuzz", "^"+target+"$", "-fuzztime", budget.String(), ".")
+	cmd.Dir = dir
+	cmd.Stdout = io.MultiWriter(&out, log)
+	cmd.Stderr = cmd.Stdout
+	err := cmd.Run()
+	res.Took = time.Since(start)
+	if err == nil {
+		res.OK = true
+		return res, nil
+	}
+
+	res.Input = failingInput(out.String())
+	if res.Input == "" {
+		// Not a finding, e.g. the package does not compile
+		return res, fmt.Errorf("go test failed: %w\n%s", err, strings.TrimSpace(out.String()))
+	}
+	res.Output = strings.TrimSpace(out.String())
+	return res, nil
+}
+
+// failingInput returns the file go test wrote the failing input to, from its output
+func failingInput(output string) string {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "Failing input written to "); ok {
+			return path
+		}
+	}
+	return ""
+}
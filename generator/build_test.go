@@ -0,0 +1,193 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// testSchema has a header routing tenants, with the request_id and timestamp of retries and
+// replays, and a payload of every kind the server extras treat
+// apart: one forwarded to a backend group with a dispatch priority, one left open to
+// unauthenticated sessions, a broadcast, and the ErrorRes of the fallible handlers
+const testSchema = `syntax = "proto3";
+package packet;
+
+message Header {
+  uint32 seq = 1;
+  string tenant = 2;
+  string request_id = 3;
+  int64 timestamp = 4;
+}
+
+// @socketgen requires_auth=false
+message LoginReq {
+  string token = 1;
+}
+
+// @socketgen group=chat priority=1
+message ChatMsg {
+  string text = 1;
+}
+
+// @socketgen broadcast
+message ChatEvent {
+  string text = 1;
+}
+
+enum ErrorCode {
+  ERROR_CODE_INTERNAL = 0;
+  ERROR_CODE_FORBIDDEN = 1;
+}
+
+message ErrorRes {
+  ErrorCode code = 1;
+  string message = 2;
+}
+
+message GamePacket {
+  Header header = 1;
+  oneof payload {
+    LoginReq login_req = 10;
+    ChatMsg chat_msg = 11;
+    ChatEvent chat_event = 12;
+    ErrorRes error_res = 13;
+  }
+}
+`
+
+// generateGoPackage writes the Go output of testSchema, with the extras generate adds, into a
+// new package under testdata, where the protobuf runtime resolves from this module. Each generated
+// package also gets its packet.pb.go, so it builds as a server would.
+func generateGoPackage(t *testing.T, generate func(result *parser.ParseResult, dir string) error) string {
+	t.Helper()
+	return generateGoSchemaPackage(t, testSchema, generate)
+}
+
+// generateGoSchemaPackage is generateGoPackage for another schema, e.g. one without the options
+// an extra is generated for
+func generateGoSchemaPackage(t *testing.T, schema string, generate func(result *parser.ParseResult, dir string) error) string {
+	t.Helper()
+	return "./" + filepath.ToSlash(generateGoPackageIn(t, "testdata", schema, generate))
+}
+
+// transportModule is a module requiring the libraries of the transports and gateway backends,
+// which socketgen itself does not depend on
+const transportModule = "testdata/transports"
+
+// generateTransportPackage is generateGoPackage in transportModule, for the transports and
+// gateway backends; it returns the package relative to the module, for goModuleCommand
+func generateTransportPackage(t *testing.T, generate func(result *parser.ParseResult, dir string) error) string {
+	t.Helper()
+	return "./" + filepath.Base(generateGoPackageIn(t, transportModule, testSchema, generate))
+}
+
+// generateGoPackageIn writes the package of generateGoSchemaPackage into a new directory of
+// parent, which it returns
+func generateGoPackageIn(t *testing.T, parent, schema string, generate func(result *parser.ParseResult, dir string) error) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds generated code")
+	}
+
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := os.MkdirTemp(parent, "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	protoFile := filepath.Join(dir, "packet.proto")
+	if err := os.WriteFile(protoFile, []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := parser.Parse(protoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateGo(result, dir); err != nil {
+		t.Fatal(err)
+	}
+	if generate != nil {
+		if err := generate(result, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeGoProto(t, result, dir)
+	return dir
+}
+
+// parseSchema parses schema from a new directory, which it returns with the result
+func parseSchema(t *testing.T, schema string) (*parser.ParseResult, string) {
+	t.Helper()
+	dir := t.TempDir()
+	protoFile := filepath.Join(dir, "packet.proto")
+	if err := os.WriteFile(protoFile, []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := parser.Parse(protoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, dir
+}
+
+// writeGoProto writes packet.pb.go as protoc-gen-go would, without needing protoc
+func writeGoProto(t *testing.T, result *parser.ParseResult, dir string) {
+	t.Helper()
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(result.DescriptorSet, &fds); err != nil {
+		t.Fatal(err)
+	}
+	target := fds.File[len(fds.File)-1].GetName()
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{target},
+		Parameter:      proto.String("paths=source_relative,M" + target + "=example.com/packet;" + result.PackageName),
+		ProtoFile:      fds.File,
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range plugin.Files {
+		if f.Generate {
+			internal_gengo.GenerateFile(plugin, f)
+		}
+	}
+	res := plugin.Response()
+	if res.Error != nil {
+		t.Fatal(res.GetError())
+	}
+	for _, f := range res.File {
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(f.GetName())), []byte(f.GetContent()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// goCommand runs the go command on generated code, failing the test with its output
+func goCommand(t *testing.T, args ...string) {
+	t.Helper()
+	goModuleCommand(t, "", args...)
+}
+
+// goModuleCommand is goCommand in the module at dir, e.g. transportModule
+func goModuleCommand(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
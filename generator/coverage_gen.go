@@ -0,0 +1,212 @@
+package generator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Coverage reports share one JSON format across languages, so runs of the Go and TypeScript
+// test suites can be merged with 'socketgen coverage':
+//
+//	{"schemaVersion": "...", "payloads": {"login_req": 3, "chat_msg": 0}}
+
+const goCoverageTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// coveragePayloads lists the field name of every payload with a handler, in schema order
+var coveragePayloads = []string{
+{{- range .HandledPayloads }}
+	"{{.FieldName}}",
+{{- end }}
+}
+
+// Coverage counts how often each payload handler ran, so tests can report which protocol
+// paths they never exercise
+type Coverage struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewCoverage returns an empty coverage counter
+func NewCoverage() *Coverage {
+	return &Coverage{counts: make(map[string]int, len(coveragePayloads))}
+}
+
+// Hit records one run of the handler for payload
+func (c *Coverage) Hit(payload string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[payload]++
+}
+
+// Counts returns the number of handler runs for every payload, including those that never ran
+func (c *Coverage) Counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(coveragePayloads))
+	for _, p := range coveragePayloads {
+		counts[p] = c.counts[p]
+	}
+	return counts
+}
+
+// Uncovered returns the payloads whose handler never ran, in schema order
+func (c *Coverage) Uncovered() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var uncovered []string
+	for _, p := range coveragePayloads {
+		if c.counts[p] == 0 {
+			uncovered = append(uncovered, p)
+		}
+	}
+	return uncovered
+}
+
+// WriteReport writes a human-readable per-payload report
+func (c *Coverage) WriteReport(w io.Writer) error {
+	counts := c.Counts()
+	covered := 0
+	for _, p := range coveragePayloads {
+		mark := "  "
+		if counts[p] > 0 {
+			covered++
+		} else {
+			mark = "!!"
+		}
+		if _, err := fmt.Fprintf(w, "%s %-32s %d\n", mark, p, counts[p]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "payload coverage: %d/%d (%.1f%%)\n", covered, len(coveragePayloads),
+		100*float64(covered)/float64(max(len(coveragePayloads), 1)))
+	return err
+}
+
+// WriteJSON writes the report in the format read by 'socketgen coverage'
+func (c *Coverage) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		SchemaVersion string         ` + "`json:\"schemaVersion\"`" + `
+		Payloads      map[string]int ` + "`json:\"payloads\"`" + `
+	}{SchemaVersion, c.Counts()})
+}
+
+// WriteFile writes the JSON report to path, e.g. from TestMain after the tests ran
+func (c *Coverage) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.WriteJSON(f)
+}
+
+// Handler wraps handler so every dispatched payload is counted before handler runs
+func (c *Coverage) Handler(handler PacketHandler) PacketHandler {
+	return &coverageHandler{coverage: c, handler: handler}
+}
+
+type coverageHandler struct {
+	coverage *Coverage
+	handler  PacketHandler
+}
+{{- if .Features }}
+
+// FeatureEnabled forwards feature checks to the wrapped handler
+func (h *coverageHandler) FeatureEnabled(feature string) bool {
+	if flags, ok := h.handler.(FeatureFlags); ok {
+		return flags.FeatureEnabled(feature)
+	}
+	return true
+}
+{{- end }}
+{{- range .HandledPayloads }}
+
+func (h *coverageHandler) On{{.Name}}(header *Header, msg *{{.Name}}) {
+	h.coverage.Hit("{{.FieldName}}")
+	h.handler.On{{.Name}}(header, msg)
+}
+{{- end }}
+`
+
+const tsCoverageTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { IPacketHandler, SCHEMA_VERSION } from "./PacketDispatcher";
+
+const COVERAGE_PAYLOADS = [
+{{- range .Payloads }}
+  "{{.FieldName}}",
+{{- end }}
+] as const;
+
+export type CoveragePayload = typeof COVERAGE_PAYLOADS[number];
+
+// Coverage counts how often each payload handler ran, so tests can report which protocol
+// paths they never exercise
+export class Coverage {
+  private counts = new Map<CoveragePayload, number>();
+
+  hit(payload: CoveragePayload): void {
+    this.counts.set(payload, (this.counts.get(payload) ?? 0) + 1);
+  }
+
+  // Returns the number of handler runs for every payload, including those that never ran
+  snapshot(): Record<CoveragePayload, number> {
+    const out = {} as Record<CoveragePayload, number>;
+    for (const p of COVERAGE_PAYLOADS) {
+      out[p] = this.counts.get(p) ?? 0;
+    }
+    return out;
+  }
+
+  uncovered(): CoveragePayload[] {
+    return COVERAGE_PAYLOADS.filter((p) => !this.counts.get(p));
+  }
+
+  // Renders the report in the format read by 'socketgen coverage'
+  toJSON(): { schemaVersion: string; payloads: Record<CoveragePayload, number> } {
+    return { schemaVersion: SCHEMA_VERSION, payloads: this.snapshot() };
+  }
+
+  // Wraps handler so every dispatched payload is counted before handler runs
+  handler(handler: IPacketHandler): IPacketHandler {
+    return {
+{{- range .Payloads }}
+      on{{.Name}}: (header, msg) => {
+        this.hit("{{.FieldName}}");
+        handler.on{{.Name}}(header, msg);
+      },
+{{- end }}
+    };
+  }
+}
+`
+
+// coverageFiles maps each language to its coverage file name and template
+var coverageFiles = map[string]struct {
+	fileName string
+	text     string
+}{
+	"go": {"packet_coverage.go", goCoverageTemplate},
+	"ts": {"PacketCoverage.ts", tsCoverageTemplate},
+}
+
+// GenerateCoverage writes the handler coverage instrumentation for lang
+func GenerateCoverage(result *parser.ParseResult, lang string, outDir string) error {
+	file, ok := coverageFiles[lang]
+	if !ok {
+		return fmt.Errorf("handler coverage is not supported for %s", lang)
+	}
+	return writeTemplate(outDir, file.fileName, lang+"_coverage", file.text, template.FuncMap{}, result)
+}
@@ -15,17 +15,31 @@ using {{.PackageName | toPascalCase}};
 
 public interface IPacketHandler {
 {{- range .Payloads }}
+{{- xmlDocComment "    " .Doc }}
     void On{{.Name}}(Header header, {{.Name}} msg);
 {{- end }}
 }
 
 public static class PacketDispatcher {
+    public const string SchemaVersion = "{{.SchemaVersion}}";
+    public const string SchemaVersionHeader = "X-Socketgen-Schema";
+    public const string SchemaSubprotocol = "socketgen." + SchemaVersion;
+
+    public static void CheckSchemaVersion(string advertised) {
+        if (!string.IsNullOrEmpty(advertised) && advertised != SchemaVersion) {
+            throw new System.InvalidOperationException($"schema version mismatch: peer has {advertised}, this build has {SchemaVersion}");
+        }
+    }
+
     public static void Dispatch(byte[] data, IPacketHandler handler) {
-        var pkt = GamePacket.Parser.ParseFrom(data);
-        
+        DispatchPacket({{.Wrapper}}.Parser.ParseFrom(data), handler);
+    }
+
+    // Routes an already decoded packet to handler
+    public static void DispatchPacket({{.Wrapper}} pkt, IPacketHandler handler) {
         switch (pkt.PayloadCase) {
 {{- range .Payloads }}
-            case GamePacket.PayloadOneofCase.{{.Name}}:
+            case {{$.Wrapper}}.PayloadOneofCase.{{.Name}}:
                 handler.On{{.Name}}(pkt.Header, pkt.{{.Name}});
                 break;
 {{- end }}
@@ -46,7 +60,7 @@ public static class PacketDispatcher {
 {{- range .Payloads }}
 
     public static void Send{{.Name}}(IPacketStream stream, Header header, {{.Name}} msg) {
-        var pkt = new GamePacket {
+        var pkt = new {{$.Wrapper}} {
             Header = header,
             {{.Name}} = msg
         };
@@ -63,7 +77,8 @@ public interface IPacketStream {
 
 func GenerateCSharp(result *parser.ParseResult, outDir string) error {
 	funcMap := template.FuncMap{
-		"toPascalCase": toPascalCase,
+		"toPascalCase":  toPascalCase,
+		"xmlDocComment": xmlDocComment,
 	}
 
 	tmpl, err := template.New("csharp").Funcs(funcMap).Parse(csharpTemplate)
@@ -82,5 +97,17 @@ func GenerateCSharp(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	if err := generateLifecycle(result, "csharp", outDir); err != nil {
+		return err
+	}
+	if err := generateSnapshots(result, "csharp", outDir); err != nil {
+		return err
+	}
+	if err := generateInputs(result, "csharp", outDir); err != nil {
+		return err
+	}
+	return generateTextCodes(result, "csharp", outDir)
 }
@@ -12,22 +12,33 @@ import (
 const dartTemplate = `// Code generated by socketgen. DO NOT EDIT.
 import 'packet.pb.dart';
 
+const schemaVersion = '{{.SchemaVersion}}';
+const schemaVersionHeader = 'X-Socketgen-Schema';
+const schemaSubprotocol = 'socketgen.$schemaVersion';
+
+void checkSchemaVersion(String? advertised) {
+  if (advertised != null && advertised.isNotEmpty && advertised != schemaVersion) {
+    throw StateError('schema version mismatch: peer has $advertised, this build has $schemaVersion');
+  }
+}
+
 abstract class PacketHandler {
 {{- range .Payloads }}
+{{- lineComment "  /// " .Doc }}
   void on{{.Name}}(Header header, {{.Name}} msg);
 {{- end }}
 }
 
 void dispatch(List<int> data, PacketHandler handler) {
-  final pkt = GamePacket.fromBuffer(data);
+  final pkt = {{.Wrapper}}.fromBuffer(data);
   
   switch (pkt.whichPayload()) {
 {{- range .Payloads }}
-    case GamePacket_Payload.{{.FieldName | toCamelCase}}:
-      handler.on{{.Name}}(pkt.header, pkt.{{.FieldName | toCamelCase}});
+    case {{$.Wrapper}}_Payload.{{dartName .FieldName .Number}}:
+      handler.on{{.Name}}(pkt.header, pkt.{{dartName .FieldName .Number}});
       break;
 {{- end }}
-    case GamePacket_Payload.notSet:
+    case {{.Wrapper}}_Payload.notSet:
       break;
   }
 }
@@ -51,9 +62,9 @@ Future<void> serve(PacketStream stream, PacketHandler handler) async {
 {{- range .Payloads }}
 
 Future<void> send{{.Name}}(PacketStream stream, Header header, {{.Name}} msg) async {
-  final pkt = GamePacket()
+  final pkt = {{$.Wrapper}}()
     ..header = header
-    ..{{.FieldName | toCamelCase}} = msg;
+    ..{{dartName .FieldName .Number}} = msg;
   await stream.writePacket(pkt.writeToBuffer());
 }
 {{- end }}
@@ -61,7 +72,8 @@ Future<void> send{{.Name}}(PacketStream stream, Header header, {{.Name}} msg) as
 
 func GenerateDart(result *parser.ParseResult, outDir string) error {
 	funcMap := template.FuncMap{
-		"toCamelCase": toCamelCase,
+		"lineComment": lineComment,
+		"dartName":    dartName,
 	}
 
 	tmpl, err := template.New("dart").Funcs(funcMap).Parse(dartTemplate)
@@ -80,5 +92,17 @@ func GenerateDart(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	if err := generateLifecycle(result, "dart", outDir); err != nil {
+		return err
+	}
+	if err := generateSnapshots(result, "dart", outDir); err != nil {
+		return err
+	}
+	if err := generateInputs(result, "dart", outDir); err != nil {
+		return err
+	}
+	return generateTextCodes(result, "dart", outDir)
 }
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const docsTemplate = `# {{.PackageName}} Protocol
+
+Schema version ` + "`{{.SchemaVersion}}`" + `. Generated by socketgen from the comments and options of the proto file.
+{{- if .Header }}
+
+## Header
+
+Every {{.Wrapper}} carries a {{.HeaderType}}.
+
+| Field | Number | Type | Description |
+|-------|--------|------|-------------|
+{{- range .Header }}
+| ` + "`{{.Name}}`" + ` | {{.Number}} | {{fieldType .}} | {{cell .Comment}} |
+{{- end }}
+{{- end }}
+
+## Payloads
+
+| Payload | Number | Direction | Description |
+|---------|--------|-----------|-------------|
+{{- range .Payloads }}
+| [{{.Name}}](#{{anchor .Name}}) | {{.Number}} | {{.Direction}} | {{cell (summary .Comment)}} |
+{{- end }}
+{{- range .Payloads }}
+
+### {{.Name}}
+{{- if .Comment }}
+
+{{.Comment}}
+{{- end }}
+{{- with facts . }}
+{{ range . }}
+- {{.}}
+{{- end }}
+{{- end }}
+{{- if .Fields }}
+
+| Field | Number | Type | Description |
+|-------|--------|------|-------------|
+{{- range .Fields }}
+| ` + "`{{.Name}}`" + ` | {{.Number}} | {{fieldType .}} | {{cell .Comment}} |
+{{- end }}
+{{- end }}
+{{- end }}
+`
+
+// GenerateDocs writes a Markdown reference of the protocol to w, from the comments and options
+// of the proto file
+func GenerateDocs(result *parser.ParseResult, w io.Writer) error {
+	funcMap := template.FuncMap{
+		"anchor":    func(name string) string { return strings.ToLower(name) },
+		"cell":      docsCell,
+		"summary":   func(comment string) string { first, _, _ := strings.Cut(comment, "\n\n"); return first },
+		"fieldType": docsFieldType,
+		"facts":     docsFacts,
+	}
+
+	tmpl, err := template.New("docs").Funcs(funcMap).Parse(docsTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse docs template: %w", err)
+	}
+	return tmpl.Execute(w, result)
+}
+
+// docsCell fits text into a Markdown table cell
+func docsCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
+
+func docsFieldType(f parser.MessageField) string {
+	kind := f.Kind
+	if f.TypeName != "" {
+		kind = f.TypeName
+	}
+	switch {
+	case f.Map:
+		return "map (" + kind + ")"
+	case f.Repeated:
+		return "repeated " + kind
+	case f.Optional:
+		return "optional " + kind
+	}
+	return kind
+}
+
+// docsFacts lists what the socketgen options of p mean for its senders and handlers
+func docsFacts(p parser.PayloadMessage) []string {
+	var facts []string
+	if p.RespondsWith != "" {
+		facts = append(facts, fmt.Sprintf("Responds with [%s](#%s)", p.RespondsWith, strings.ToLower(p.RespondsWith)))
+	}
+	if p.MaxPageSize > 0 {
+		facts = append(facts, fmt.Sprintf("Paginated, up to %d items per page", p.MaxPageSize))
+	}
+	if p.RequiresAuth {
+		facts = append(facts, "Requires an authenticated session")
+	}
+	if p.RateLimit > 0 {
+		facts = append(facts, "Rate limited to "+strconv.FormatFloat(p.RateLimit, 'g', -1, 64)+" packets per second")
+	}
+	if p.Feature != "" {
+		facts = append(facts, "Gated by feature `"+p.Feature+"`")
+	}
+	if p.Group != "" {
+		facts = append(facts, "Handled by service group `"+p.Group+"`")
+	}
+	if p.Broadcast {
+		facts = append(facts, "Broadcast, received by read-only sessions")
+	}
+	if p.Priority != 0 {
+		facts = append(facts, fmt.Sprintf("Dispatch priority %d", p.Priority))
+	}
+	if p.Compress {
+		facts = append(facts, "Worth compressing on the wire")
+	}
+	if p.Throttle {
+		facts = append(facts, "Throttled state update, sent less often to slow sessions")
+	}
+	if p.Snapshot {
+		facts = append(facts, "State snapshot, interpolated by clients")
+	}
+	if p.Input {
+		facts = append(facts, "Input command, queued by clients for prediction")
+	}
+	for _, f := range p.Fields {
+		if f.TextCode {
+			facts = append(facts, "`"+f.Name+"` carries a text code, localized by clients")
+		}
+	}
+	if p.SupersededBy != "" {
+		facts = append(facts, fmt.Sprintf("Superseded by [%s](#%s)", p.SupersededBy, strings.ToLower(p.SupersededBy)))
+	}
+	return facts
+}
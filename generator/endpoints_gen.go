@@ -0,0 +1,896 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// endpointEnvironment is an environment of the endpoints section as the templates use it
+type endpointEnvironment struct {
+	Name               string
+	Endpoints          []config.EndpointURL // In the order clients try them
+	TLS                bool
+	ServerName         string
+	MinVersion         string // 1.2 or 1.3
+	InsecureSkipVerify bool
+}
+
+const goEndpointsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DefaultEnvironment is the environment clients connect to unless SOCKETGEN_ENV names another
+const DefaultEnvironment = "{{.Default}}"
+
+// EnvironmentVariable names the environment to connect to instead of DefaultEnvironment
+const EnvironmentVariable = "SOCKETGEN_ENV"
+
+// EndpointVariablePrefix starts the variables replacing the URL of a transport, e.g.
+// SOCKETGEN_ENDPOINT_WS
+const EndpointVariablePrefix = "SOCKETGEN_ENDPOINT_"
+
+// Endpoint is the address of the server over one transport
+type Endpoint struct {
+	Transport string // {{join .AllTransports ", "}}
+	URL       string // host:port for tcp, kcp and quic
+}
+
+// EndpointTLS are the TLS settings of the endpoints of an environment
+type EndpointTLS struct {
+	Enabled            bool
+	ServerName         string // Name verified in the certificate of the server; empty for the host of the URL
+	MinVersion         uint16 // tls.VersionTLS12 or tls.VersionTLS13
+	InsecureSkipVerify bool   // Any certificate is accepted
+}
+
+// EndpointConfig holds the endpoints of an environment, in the order clients should try them
+type EndpointConfig struct {
+	Environment string
+	Endpoints   []Endpoint
+	TLS         EndpointTLS
+}
+
+// Environments are the endpoints of every environment of socketgen.yaml
+var Environments = map[string]EndpointConfig{
+{{- range .Environments }}
+	"{{.Name}}": {
+		Environment: "{{.Name}}",
+		Endpoints: []Endpoint{
+{{- range .Endpoints }}
+			{Transport: "{{.Transport}}", URL: "{{.URL}}"},
+{{- end }}
+		},
+{{- if .TLS }}
+		TLS: EndpointTLS{Enabled: true{{if .ServerName}}, ServerName: "{{.ServerName}}"{{end}}, MinVersion: tls.VersionTLS{{nodot .MinVersion}}{{if .InsecureSkipVerify}}, InsecureSkipVerify: true{{end}}},
+{{- end }}
+	},
+{{- end }}
+}
+
+// endpointTransports are the transports a SOCKETGEN_ENDPOINT_<TRANSPORT> variable may set
+var endpointTransports = []string{ {{- range $i, $t := .AllTransports }}{{if $i}}, {{end}}"{{$t}}"{{end -}} }
+
+// URL returns the URL of transport, and whether the environment has one
+func (c EndpointConfig) URL(transport string) (string, bool) {
+	for _, e := range c.Endpoints {
+		if e.Transport == transport {
+			return e.URL, true
+		}
+	}
+	return "", false
+}
+
+// TLSConfig returns the TLS configuration of the endpoints, e.g. for DialQUIC, or nil if they
+// do not use TLS
+func (c EndpointConfig) TLSConfig() *tls.Config {
+	if !c.TLS.Enabled {
+		return nil
+	}
+	return &tls.Config{ServerName: c.TLS.ServerName, MinVersion: c.TLS.MinVersion, InsecureSkipVerify: c.TLS.InsecureSkipVerify}
+}
+
+// SelectEndpoints returns the endpoints of the environment name or, if name is empty, of
+// SOCKETGEN_ENV or DefaultEnvironment. A SOCKETGEN_ENDPOINT_<TRANSPORT> variable replaces the
+// URL of its transport, or adds the transport last.
+func SelectEndpoints(name string) (EndpointConfig, error) {
+	if name == "" {
+		name = os.Getenv(EnvironmentVariable)
+	}
+	if name == "" {
+		name = DefaultEnvironment
+	}
+	c, ok := Environments[name]
+	if !ok {
+		return EndpointConfig{}, fmt.Errorf("unknown environment %q (defined: {{join .Names ", "}})", name)
+	}
+
+	c.Endpoints = slices.Clone(c.Endpoints)
+	for _, transport := range endpointTransports {
+		url := os.Getenv(EndpointVariablePrefix + strings.ToUpper(transport))
+		if url == "" {
+			continue
+		}
+		i := slices.IndexFunc(c.Endpoints, func(e Endpoint) bool { return e.Transport == transport })
+		if i < 0 {
+			c.Endpoints = append(c.Endpoints, Endpoint{Transport: transport, URL: url})
+		} else {
+			c.Endpoints[i].URL = url
+		}
+	}
+	return c, nil
+}
+`
+
+const tsEndpointsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+
+/** The environment clients connect to unless SOCKETGEN_ENV names another */
+export const DEFAULT_ENVIRONMENT = "{{.Default}}";
+
+export const ENVIRONMENT_VARIABLE = "SOCKETGEN_ENV";
+
+/** Starts the variables replacing the URL of a transport, e.g. SOCKETGEN_ENDPOINT_WS */
+export const ENDPOINT_VARIABLE_PREFIX = "SOCKETGEN_ENDPOINT_";
+
+const TRANSPORTS = [{{range $i, $t := .AllTransports}}{{if $i}}, {{end}}"{{$t}}"{{end}}] as const;
+
+export type Transport = (typeof TRANSPORTS)[number];
+
+/** The address of the server over one transport; host:port for tcp, kcp and quic */
+export interface Endpoint {
+  transport: Transport;
+  url: string;
+}
+
+/** The TLS settings of the endpoints of an environment, named as Node's tls.connect takes them */
+export interface EndpointTLS {
+  enabled: boolean;
+  /** The name verified in the certificate of the server; undefined for the host of the URL */
+  servername?: string;
+  minVersion: "TLSv1.2" | "TLSv1.3";
+  rejectUnauthorized: boolean;
+}
+
+/** The endpoints of an environment, in the order clients should try them */
+export interface EndpointConfig {
+  environment: string;
+  endpoints: readonly Endpoint[];
+  tls: EndpointTLS;
+}
+
+/** The endpoints of every environment of socketgen.yaml */
+export const ENVIRONMENTS: Readonly<Record<string, EndpointConfig>> = {
+{{- range .Environments }}
+  "{{.Name}}": {
+    environment: "{{.Name}}",
+    endpoints: [
+{{- range .Endpoints }}
+      { transport: "{{.Transport}}", url: "{{.URL}}" },
+{{- end }}
+    ],
+    tls: { enabled: {{.TLS}}, {{if .ServerName}}servername: "{{.ServerName}}", {{end}}minVersion: "TLSv{{.MinVersion}}", rejectUnauthorized: {{not .InsecureSkipVerify}} },
+  },
+{{- end }}
+};
+
+/** Returns the URL of transport in config, if it has one */
+export function endpointURL(config: EndpointConfig, transport: Transport): string | undefined {
+  return config.endpoints.find((e) => e.transport === transport)?.url;
+}
+
+/**
+ * Returns the endpoints of the environment name or, if it is not given, of SOCKETGEN_ENV or
+ * DEFAULT_ENVIRONMENT. A SOCKETGEN_ENDPOINT_<TRANSPORT> variable replaces the URL of its
+ * transport, or adds the transport last. The variables are read from env, process.env where there
+ * is one; browser builds pass those of their bundler (e.g. import.meta.env).
+ */
+export function selectEndpoints(
+  name?: string,
+  env: Record<string, string | undefined> = (globalThis as { process?: { env: Record<string, string | undefined> } }).process?.env ?? {},
+): EndpointConfig {
+  const environment = name || env[ENVIRONMENT_VARIABLE] || DEFAULT_ENVIRONMENT;
+  const config = ENVIRONMENTS[environment];
+  if (!config) {
+    throw new Error("unknown environment '" + environment + "' (defined: {{join .Names ", "}})");
+  }
+
+  const endpoints = [...config.endpoints];
+  for (const transport of TRANSPORTS) {
+    const url = env[ENDPOINT_VARIABLE_PREFIX + transport.toUpperCase()];
+    if (!url) {
+      continue;
+    }
+    const i = endpoints.findIndex((e) => e.transport === transport);
+    if (i < 0) {
+      endpoints.push({ transport, url });
+    } else {
+      endpoints[i] = { transport, url };
+    }
+  }
+  return { ...config, endpoints };
+}
+`
+
+const pyEndpointsTemplate = `# Code generated by socketgen. DO NOT EDIT.
+import os
+import ssl
+from collections.abc import Mapping
+from dataclasses import dataclass, replace
+
+DEFAULT_ENVIRONMENT = "{{.Default}}"
+"""The environment clients connect to unless SOCKETGEN_ENV names another."""
+
+ENVIRONMENT_VARIABLE = "SOCKETGEN_ENV"
+ENDPOINT_VARIABLE_PREFIX = "SOCKETGEN_ENDPOINT_"
+_TRANSPORTS = ({{range .AllTransports}}"{{.}}", {{end}})
+
+
+@dataclass(frozen=True)
+class Endpoint:
+    """The address of the server over one transport; host:port for tcp, kcp and quic."""
+
+    transport: str
+    url: str
+
+
+@dataclass(frozen=True)
+class EndpointTLS:
+    """The TLS settings of the endpoints of an environment."""
+
+    enabled: bool = False
+    server_name: str | None = None  # Name verified in the certificate of the server; None for the host of the URL
+    min_version: ssl.TLSVersion = ssl.TLSVersion.TLSv1_2
+    insecure_skip_verify: bool = False
+
+    def context(self) -> ssl.SSLContext | None:
+        """Returns an SSL context for the settings, or None without TLS. Pass server_name, if any,
+        as the server_hostname of the connection."""
+        if not self.enabled:
+            return None
+        context = ssl.create_default_context()
+        context.minimum_version = self.min_version
+        if self.insecure_skip_verify:
+            context.check_hostname = False
+            context.verify_mode = ssl.CERT_NONE
+        return context
+
+
+@dataclass(frozen=True)
+class EndpointConfig:
+    """The endpoints of an environment, in the order clients should try them."""
+
+    environment: str
+    endpoints: tuple[Endpoint, ...]
+    tls: EndpointTLS
+
+    def url(self, transport: str) -> str | None:
+        return next((e.url for e in self.endpoints if e.transport == transport), None)
+
+
+ENVIRONMENTS: Mapping[str, EndpointConfig] = {
+{{- range .Environments }}
+    "{{.Name}}": EndpointConfig(
+        environment="{{.Name}}",
+        endpoints=(
+{{- range .Endpoints }}
+            Endpoint("{{.Transport}}", "{{.URL}}"),
+{{- end }}
+        ),
+{{- if .TLS }}
+        tls=EndpointTLS(enabled=True, {{if .ServerName}}server_name="{{.ServerName}}", {{end}}min_version=ssl.TLSVersion.TLSv{{underscore .MinVersion}}{{if .InsecureSkipVerify}}, insecure_skip_verify=True{{end}}),
+{{- else }}
+        tls=EndpointTLS(),
+{{- end }}
+    ),
+{{- end }}
+}
+
+
+def select_endpoints(name: str | None = None, env: Mapping[str, str] | None = None) -> EndpointConfig:
+    """Returns the endpoints of the environment name or, if it is not given, of SOCKETGEN_ENV or
+    DEFAULT_ENVIRONMENT. A SOCKETGEN_ENDPOINT_<TRANSPORT> variable replaces the URL of its
+    transport, or adds the transport last. The variables are read from env, os.environ by default."""
+    if env is None:
+        env = os.environ
+    environment = name or env.get(ENVIRONMENT_VARIABLE) or DEFAULT_ENVIRONMENT
+    if environment not in ENVIRONMENTS:
+        raise ValueError(f"unknown environment {environment!r} (defined: {{join .Names ", "}})")
+    config = ENVIRONMENTS[environment]
+
+    endpoints = list(config.endpoints)
+    for transport in _TRANSPORTS:
+        url = env.get(ENDPOINT_VARIABLE_PREFIX + transport.upper())
+        if not url:
+            continue
+        i = next((i for i, e in enumerate(endpoints) if e.transport == transport), None)
+        if i is None:
+            endpoints.append(Endpoint(transport, url))
+        else:
+            endpoints[i] = Endpoint(transport, url)
+    return replace(config, endpoints=tuple(endpoints))
+`
+
+const csharpEndpointsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+#nullable enable
+using System;
+using System.Collections.Generic;
+using System.Linq;
+using System.Security.Authentication;
+
+namespace {{.PackageName | toPascalCase}} {
+    /// <summary>The address of the server over one transport; host:port for tcp, kcp and quic.</summary>
+    public sealed record Endpoint(string Transport, string Url);
+
+    /// <summary>The TLS settings of the endpoints of an environment. ServerName is the name verified in the certificate of the server, or null for the host of the URL.</summary>
+    public sealed record EndpointTls(bool Enabled, string? ServerName = null, SslProtocols MinVersion = SslProtocols.Tls12, bool InsecureSkipVerify = false);
+
+    /// <summary>The endpoints of an environment, in the order clients should try them.</summary>
+    public sealed record EndpointConfig(string Environment, IReadOnlyList<Endpoint> Endpoints, EndpointTls Tls) {
+        public string? Url(string transport) => Endpoints.FirstOrDefault(e => e.Transport == transport)?.Url;
+    }
+
+    public static class Endpoints {
+        /// <summary>The environment clients connect to unless SOCKETGEN_ENV names another.</summary>
+        public const string DefaultEnvironment = "{{.Default}}";
+        public const string EnvironmentVariable = "SOCKETGEN_ENV";
+        public const string EndpointVariablePrefix = "SOCKETGEN_ENDPOINT_";
+        private static readonly string[] Transports = { {{- range $i, $t := .AllTransports }}{{if $i}}, {{end}}"{{$t}}"{{end -}} };
+
+        /// <summary>The endpoints of every environment of socketgen.yaml.</summary>
+        public static readonly IReadOnlyDictionary<string, EndpointConfig> Environments = new Dictionary<string, EndpointConfig> {
+{{- range .Environments }}
+            ["{{.Name}}"] = new EndpointConfig("{{.Name}}", new[] {
+{{- range .Endpoints }}
+                new Endpoint("{{.Transport}}", "{{.URL}}"),
+{{- end }}
+            }, new EndpointTls({{.TLS}}{{if .TLS}}, {{if .ServerName}}"{{.ServerName}}"{{else}}null{{end}}, SslProtocols.Tls{{nodot .MinVersion}}, {{.InsecureSkipVerify}}{{end}})),
+{{- end }}
+        };
+
+        /// <summary>
+        /// Returns the endpoints of the environment name or, if it is null, of SOCKETGEN_ENV or
+        /// DefaultEnvironment. A SOCKETGEN_ENDPOINT_&lt;TRANSPORT&gt; variable replaces the URL of its
+        /// transport, or adds the transport last. The variables are read with env, the environment
+        /// variables of the process by default.
+        /// </summary>
+        public static EndpointConfig Select(string? name = null, Func<string, string?>? env = null) {
+            env ??= System.Environment.GetEnvironmentVariable;
+            var environment = !string.IsNullOrEmpty(name) ? name : env(EnvironmentVariable);
+            if (string.IsNullOrEmpty(environment)) {
+                environment = DefaultEnvironment;
+            }
+            if (!Environments.TryGetValue(environment, out var config)) {
+                throw new ArgumentException($"unknown environment '{environment}' (defined: {{join .Names ", "}})", nameof(name));
+            }
+
+            var endpoints = config.Endpoints.ToList();
+            foreach (var transport in Transports) {
+                var url = env(EndpointVariablePrefix + transport.ToUpperInvariant());
+                if (string.IsNullOrEmpty(url)) {
+                    continue;
+                }
+                var i = endpoints.FindIndex(e => e.Transport == transport);
+                if (i < 0) {
+                    endpoints.Add(new Endpoint(transport, url));
+                } else {
+                    endpoints[i] = new Endpoint(transport, url);
+                }
+            }
+            return config with { Endpoints = endpoints };
+        }
+    }
+}
+`
+
+const javaEndpointsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+import java.util.ArrayList;
+import java.util.Collections;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.function.Function;
+
+/** The servers clients connect to in every environment of socketgen.yaml. */
+public final class PacketEndpoints {
+    /** The environment clients connect to unless SOCKETGEN_ENV names another. */
+    public static final String DEFAULT_ENVIRONMENT = "{{.Default}}";
+    public static final String ENVIRONMENT_VARIABLE = "SOCKETGEN_ENV";
+    public static final String ENDPOINT_VARIABLE_PREFIX = "SOCKETGEN_ENDPOINT_";
+    private static final String[] TRANSPORTS = { {{- range $i, $t := .AllTransports }}{{if $i}}, {{end}}"{{$t}}"{{end -}} };
+
+    /** The address of the server over one transport; host:port for tcp, kcp and quic. */
+    public static final class Endpoint {
+        public final String transport;
+        public final String url;
+
+        public Endpoint(String transport, String url) {
+            this.transport = transport;
+            this.url = url;
+        }
+    }
+
+    /** The TLS settings of the endpoints of an environment. */
+    public static final class Tls {
+        public final boolean enabled;
+        /** The name verified in the certificate of the server, or null for the host of the URL. */
+        public final String serverName;
+        /** The oldest protocol accepted, as SSLParameters takes it: TLSv1.2 or TLSv1.3. */
+        public final String minVersion;
+        public final boolean insecureSkipVerify;
+
+        public Tls(boolean enabled, String serverName, String minVersion, boolean insecureSkipVerify) {
+            this.enabled = enabled;
+            this.serverName = serverName;
+            this.minVersion = minVersion;
+            this.insecureSkipVerify = insecureSkipVerify;
+        }
+    }
+
+    /** The endpoints of an environment, in the order clients should try them. */
+    public static final class Config {
+        public final String environment;
+        public final List<Endpoint> endpoints;
+        public final Tls tls;
+
+        public Config(String environment, List<Endpoint> endpoints, Tls tls) {
+            this.environment = environment;
+            this.endpoints = Collections.unmodifiableList(endpoints);
+            this.tls = tls;
+        }
+
+        /** The URL of transport, or null if the environment has none. */
+        public String url(String transport) {
+            for (Endpoint e : endpoints) {
+                if (e.transport.equals(transport)) {
+                    return e.url;
+                }
+            }
+            return null;
+        }
+    }
+
+    /** The endpoints of every environment of socketgen.yaml. */
+    public static final Map<String, Config> ENVIRONMENTS;
+
+    static {
+        Map<String, Config> environments = new LinkedHashMap<>();
+{{- range .Environments }}
+        environments.put("{{.Name}}", new Config("{{.Name}}", List.of(new Endpoint[] {
+{{- range .Endpoints }}
+            new Endpoint("{{.Transport}}", "{{.URL}}"),
+{{- end }}
+        }), new Tls({{.TLS}}, {{if .ServerName}}"{{.ServerName}}"{{else}}null{{end}}, "TLSv{{.MinVersion}}", {{.InsecureSkipVerify}})));
+{{- end }}
+        ENVIRONMENTS = Collections.unmodifiableMap(environments);
+    }
+
+    private PacketEndpoints() {}
+
+    /** Returns the endpoints of the environment name, or of SOCKETGEN_ENV or DEFAULT_ENVIRONMENT if it is null. */
+    public static Config select(String name) {
+        return select(name, System::getenv);
+    }
+
+    /**
+     * Returns the endpoints of the environment name or, if it is null, of SOCKETGEN_ENV or
+     * DEFAULT_ENVIRONMENT. A SOCKETGEN_ENDPOINT_&lt;TRANSPORT&gt; variable replaces the URL of its
+     * transport, or adds the transport last. The variables are read with env.
+     */
+    public static Config select(String name, Function<String, String> env) {
+        String environment = name != null && !name.isEmpty() ? name : env.apply(ENVIRONMENT_VARIABLE);
+        if (environment == null || environment.isEmpty()) {
+            environment = DEFAULT_ENVIRONMENT;
+        }
+        Config config = ENVIRONMENTS.get(environment);
+        if (config == null) {
+            throw new IllegalArgumentException("unknown environment '" + environment + "' (defined: {{join .Names ", "}})");
+        }
+
+        List<Endpoint> endpoints = new ArrayList<>(config.endpoints);
+        for (String transport : TRANSPORTS) {
+            String url = env.apply(ENDPOINT_VARIABLE_PREFIX + transport.toUpperCase());
+            if (url == null || url.isEmpty()) {
+                continue;
+            }
+            int i = 0;
+            while (i < endpoints.size() && !endpoints.get(i).transport.equals(transport)) {
+                i++;
+            }
+            if (i == endpoints.size()) {
+                endpoints.add(new Endpoint(transport, url));
+            } else {
+                endpoints.set(i, new Endpoint(transport, url));
+            }
+        }
+        return new Config(config.environment, endpoints, config.tls);
+    }
+}
+`
+
+const kotlinEndpointsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+/** The address of the server over one transport; host:port for tcp, kcp and quic. */
+data class Endpoint(val transport: String, val url: String)
+
+/**
+ * The TLS settings of the endpoints of an environment. serverName is the name verified in the
+ * certificate of the server, or null for the host of the URL; minVersion is TLSv1.2 or TLSv1.3.
+ */
+data class EndpointTls(
+    val enabled: Boolean,
+    val serverName: String? = null,
+    val minVersion: String = "TLSv1.2",
+    val insecureSkipVerify: Boolean = false,
+)
+
+/** The endpoints of an environment, in the order clients should try them. */
+data class EndpointConfig(val environment: String, val endpoints: List<Endpoint>, val tls: EndpointTls) {
+    fun url(transport: String): String? = endpoints.firstOrNull { it.transport == transport }?.url
+}
+
+/** The servers clients connect to in every environment of socketgen.yaml. */
+object Endpoints {
+    /** The environment clients connect to unless SOCKETGEN_ENV names another. */
+    const val DEFAULT_ENVIRONMENT = "{{.Default}}"
+    const val ENVIRONMENT_VARIABLE = "SOCKETGEN_ENV"
+    const val ENDPOINT_VARIABLE_PREFIX = "SOCKETGEN_ENDPOINT_"
+    private val transports = listOf({{range $i, $t := .AllTransports}}{{if $i}}, {{end}}"{{$t}}"{{end}})
+
+    val environments: Map<String, EndpointConfig> = mapOf(
+{{- range .Environments }}
+        "{{.Name}}" to EndpointConfig(
+            "{{.Name}}",
+            listOf(
+{{- range .Endpoints }}
+                Endpoint("{{.Transport}}", "{{.URL}}"),
+{{- end }}
+            ),
+            EndpointTls({{.TLS}}, {{if .ServerName}}"{{.ServerName}}"{{else}}null{{end}}, "TLSv{{.MinVersion}}", {{.InsecureSkipVerify}}),
+        ),
+{{- end }}
+    )
+
+    /**
+     * Returns the endpoints of the environment name or, if it is null, of SOCKETGEN_ENV or
+     * DEFAULT_ENVIRONMENT. A SOCKETGEN_ENDPOINT_<TRANSPORT> variable replaces the URL of its
+     * transport, or adds the transport last. The variables are read with env, the environment
+     * variables of the process by default; Android apps pass their own lookup (e.g. of BuildConfig).
+     */
+    fun select(name: String? = null, env: (String) -> String? = System::getenv): EndpointConfig {
+        val environment = name?.takeIf { it.isNotEmpty() } ?: env(ENVIRONMENT_VARIABLE)?.takeIf { it.isNotEmpty() } ?: DEFAULT_ENVIRONMENT
+        val config = environments[environment]
+            ?: throw IllegalArgumentException("unknown environment '$environment' (defined: {{join .Names ", "}})")
+
+        val endpoints = config.endpoints.toMutableList()
+        for (transport in transports) {
+            val url = env(ENDPOINT_VARIABLE_PREFIX + transport.uppercase())?.takeIf { it.isNotEmpty() } ?: continue
+            val i = endpoints.indexOfFirst { it.transport == transport }
+            if (i < 0) endpoints.add(Endpoint(transport, url)) else endpoints[i] = Endpoint(transport, url)
+        }
+        return config.copy(endpoints = endpoints)
+    }
+}
+`
+
+const dartEndpointsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+
+/// The address of the server over one transport; host:port for tcp, kcp and quic.
+class Endpoint {
+  const Endpoint(this.transport, this.url);
+
+  final String transport;
+  final String url;
+}
+
+/// The TLS settings of the endpoints of an environment.
+class EndpointTls {
+  const EndpointTls({this.enabled = false, this.serverName, this.minVersion = 'TLSv1.2', this.insecureSkipVerify = false});
+
+  final bool enabled;
+
+  /// The name verified in the certificate of the server, or null for the host of the URL.
+  final String? serverName;
+
+  /// The oldest protocol accepted, TLSv1.2 or TLSv1.3.
+  final String minVersion;
+  final bool insecureSkipVerify;
+}
+
+/// The endpoints of an environment, in the order clients should try them.
+class EndpointConfig {
+  const EndpointConfig(this.environment, this.endpoints, this.tls);
+
+  final String environment;
+  final List<Endpoint> endpoints;
+  final EndpointTls tls;
+
+  /// The URL of [transport], or null if the environment has none.
+  String? url(String transport) {
+    for (final e in endpoints) {
+      if (e.transport == transport) {
+        return e.url;
+      }
+    }
+    return null;
+  }
+}
+
+/// The environment clients connect to unless SOCKETGEN_ENV names another.
+const String defaultEnvironment = '{{.Default}}';
+
+const List<String> _transports = [{{range $i, $t := .AllTransports}}{{if $i}}, {{end}}'{{$t}}'{{end}}];
+
+/// The endpoints of every environment of socketgen.yaml.
+const Map<String, EndpointConfig> environments = {
+{{- range .Environments }}
+  '{{.Name}}': EndpointConfig('{{.Name}}', [
+{{- range .Endpoints }}
+    Endpoint('{{.Transport}}', '{{.URL}}'),
+{{- end }}
+  ], EndpointTls({{if .TLS}}enabled: true, {{if .ServerName}}serverName: '{{.ServerName}}', {{end}}minVersion: 'TLSv{{.MinVersion}}'{{if .InsecureSkipVerify}}, insecureSkipVerify: true{{end}}{{end}})),
+{{- end }}
+};
+
+/// The SOCKETGEN_ENV and SOCKETGEN_ENDPOINT_<TRANSPORT> values the app was built with, e.g.
+/// flutter build --dart-define=SOCKETGEN_ENV=stage
+const Map<String, String> _defines = {
+  'SOCKETGEN_ENV': String.fromEnvironment('SOCKETGEN_ENV'),
+{{- range .AllTransports }}
+  'SOCKETGEN_ENDPOINT_{{upper .}}': String.fromEnvironment('SOCKETGEN_ENDPOINT_{{upper .}}'),
+{{- end }}
+};
+
+/// Returns the endpoints of the environment [name] or, if it is not given, of SOCKETGEN_ENV or
+/// [defaultEnvironment]. A SOCKETGEN_ENDPOINT_<TRANSPORT> value replaces the URL of its transport,
+/// or adds the transport last. The values are read from [env], those the app was built with by
+/// default.
+EndpointConfig selectEndpoints([String? name, Map<String, String> env = _defines]) {
+  var environment = name ?? '';
+  if (environment.isEmpty) {
+    environment = env['SOCKETGEN_ENV'] ?? '';
+  }
+  if (environment.isEmpty) {
+    environment = defaultEnvironment;
+  }
+  final config = environments[environment];
+  if (config == null) {
+    throw ArgumentError.value(environment, 'name', 'unknown environment (defined: {{join .Names ", "}})');
+  }
+
+  final endpoints = List.of(config.endpoints);
+  for (final transport in _transports) {
+    final url = env['SOCKETGEN_ENDPOINT_${transport.toUpperCase()}'] ?? '';
+    if (url.isEmpty) {
+      continue;
+    }
+    final i = endpoints.indexWhere((e) => e.transport == transport);
+    if (i < 0) {
+      endpoints.add(Endpoint(transport, url));
+    } else {
+      endpoints[i] = Endpoint(transport, url);
+    }
+  }
+  return EndpointConfig(config.environment, endpoints, config.tls);
+}
+`
+
+const phpEndpointsTemplate = `<?php
+// Code generated by socketgen. DO NOT EDIT.
+namespace {{.PackageName | toPascalCase}};
+
+/** The address of the server over one transport; host:port for tcp, kcp and quic. */
+final class Endpoint {
+    public function __construct(public readonly string $transport, public readonly string $url) {}
+}
+
+/**
+ * The TLS settings of the endpoints of an environment. serverName is the name verified in the
+ * certificate of the server, or null for the host of the URL; minVersion is TLSv1.2 or TLSv1.3.
+ */
+final class EndpointTls {
+    public function __construct(
+        public readonly bool $enabled = false,
+        public readonly ?string $serverName = null,
+        public readonly string $minVersion = 'TLSv1.2',
+        public readonly bool $insecureSkipVerify = false,
+    ) {}
+}
+
+/** The endpoints of an environment, in the order clients should try them. */
+final class EndpointConfig {
+    /** @param Endpoint[] $endpoints */
+    public function __construct(public readonly string $environment, public readonly array $endpoints, public readonly EndpointTls $tls) {}
+
+    public function url(string $transport): ?string {
+        foreach ($this->endpoints as $endpoint) {
+            if ($endpoint->transport === $transport) {
+                return $endpoint->url;
+            }
+        }
+        return null;
+    }
+}
+
+/** The servers clients connect to in every environment of socketgen.yaml. */
+final class Endpoints {
+    /** The environment clients connect to unless SOCKETGEN_ENV names another. */
+    public const DEFAULT_ENVIRONMENT = '{{.Default}}';
+    public const ENVIRONMENT_VARIABLE = 'SOCKETGEN_ENV';
+    public const ENDPOINT_VARIABLE_PREFIX = 'SOCKETGEN_ENDPOINT_';
+    private const TRANSPORTS = [{{range $i, $t := .AllTransports}}{{if $i}}, {{end}}'{{$t}}'{{end}}];
+
+    /** @return array<string, EndpointConfig> The endpoints of every environment of socketgen.yaml */
+    public static function environments(): array {
+        return [
+{{- range .Environments }}
+            '{{.Name}}' => new EndpointConfig('{{.Name}}', [
+{{- range .Endpoints }}
+                new Endpoint('{{.Transport}}', '{{.URL}}'),
+{{- end }}
+            ], new EndpointTls({{.TLS}}, {{if .ServerName}}'{{.ServerName}}'{{else}}null{{end}}, 'TLSv{{.MinVersion}}', {{.InsecureSkipVerify}})),
+{{- end }}
+        ];
+    }
+
+    /**
+     * Returns the endpoints of the environment $name or, if it is null, of SOCKETGEN_ENV or
+     * DEFAULT_ENVIRONMENT. A SOCKETGEN_ENDPOINT_<TRANSPORT> variable replaces the URL of its
+     * transport, or adds the transport last. The variables are read from $env, or with getenv.
+     *
+     * @param array<string, string>|null $env
+     */
+    public static function select(?string $name = null, ?array $env = null): EndpointConfig {
+        $lookup = fn (string $variable): string => (string) ($env === null ? getenv($variable) : ($env[$variable] ?? ''));
+        $environment = $name ?: ($lookup(self::ENVIRONMENT_VARIABLE) ?: self::DEFAULT_ENVIRONMENT);
+        $config = self::environments()[$environment]
+            ?? throw new \InvalidArgumentException("unknown environment '$environment' (defined: {{join .Names ", "}})");
+
+        $endpoints = $config->endpoints;
+        foreach (self::TRANSPORTS as $transport) {
+            $url = $lookup(self::ENDPOINT_VARIABLE_PREFIX . strtoupper($transport));
+            if ($url === '') {
+                continue;
+            }
+            $i = array_search($transport, array_map(fn (Endpoint $e): string => $e->transport, $endpoints), true);
+            if ($i === false) {
+                $endpoints[] = new Endpoint($transport, $url);
+            } else {
+                $endpoints[$i] = new Endpoint($transport, $url);
+            }
+        }
+        return new EndpointConfig($config->environment, $endpoints, $config->tls);
+    }
+}
+`
+
+const rubyEndpointsTemplate = `# Code generated by socketgen. DO NOT EDIT.
+
+# The address of the server over one transport; host:port for tcp, kcp and quic.
+Endpoint = Struct.new(:transport, :url)
+
+# The TLS settings of the endpoints of an environment. server_name is the name verified in the
+# certificate of the server, or nil for the host of the URL; min_version is :TLS1_2 or :TLS1_3, as
+# OpenSSL::SSL::SSLContext#min_version takes it.
+EndpointTLS = Struct.new(:enabled, :server_name, :min_version, :insecure_skip_verify)
+
+# The endpoints of an environment, in the order clients should try them.
+EndpointConfig = Struct.new(:environment, :endpoints, :tls) do
+  def url(transport)
+    endpoints.find { |e| e.transport == transport }&.url
+  end
+end
+
+# The servers clients connect to in every environment of socketgen.yaml.
+module Endpoints
+  # The environment clients connect to unless SOCKETGEN_ENV names another.
+  DEFAULT_ENVIRONMENT = '{{.Default}}'
+  ENVIRONMENT_VARIABLE = 'SOCKETGEN_ENV'
+  ENDPOINT_VARIABLE_PREFIX = 'SOCKETGEN_ENDPOINT_'
+  TRANSPORTS = %w[{{join .AllTransports " "}}].freeze
+
+  ENVIRONMENTS = {
+{{- range .Environments }}
+    '{{.Name}}' => EndpointConfig.new('{{.Name}}', [
+{{- range .Endpoints }}
+      Endpoint.new('{{.Transport}}', '{{.URL}}'),
+{{- end }}
+    ].freeze, EndpointTLS.new({{.TLS}}, {{if .ServerName}}'{{.ServerName}}'{{else}}nil{{end}}, :TLS{{underscore .MinVersion}}, {{.InsecureSkipVerify}})),
+{{- end }}
+  }.freeze
+
+  # Returns the endpoints of the environment name or, if it is nil, of SOCKETGEN_ENV or
+  # DEFAULT_ENVIRONMENT. A SOCKETGEN_ENDPOINT_<TRANSPORT> variable replaces the URL of its
+  # transport, or adds the transport last. The variables are read from env, ENV by default.
+  def self.select(name = nil, env = ENV)
+    name = env[ENVIRONMENT_VARIABLE] if name.nil? || name.empty?
+    name = DEFAULT_ENVIRONMENT if name.nil? || name.empty?
+    config = ENVIRONMENTS.fetch(name) do
+      raise ArgumentError, "unknown environment '#{name}' (defined: {{join .Names ", "}})"
+    end
+
+    endpoints = config.endpoints.dup
+    TRANSPORTS.each do |transport|
+      url = env[ENDPOINT_VARIABLE_PREFIX + transport.upcase]
+      next if url.nil? || url.empty?
+
+      i = endpoints.index { |e| e.transport == transport }
+      if i
+        endpoints[i] = Endpoint.new(transport, url)
+      else
+        endpoints << Endpoint.new(transport, url)
+      end
+    end
+    EndpointConfig.new(config.environment, endpoints.freeze, config.tls)
+  end
+end
+`
+
+// endpointsFiles maps each language to its endpoints file name and template
+var endpointsFiles = map[string]struct {
+	fileName string
+	text     string
+}{
+	"go":     {"packet_endpoints.go", goEndpointsTemplate},
+	"ts":     {"PacketEndpoints.ts", tsEndpointsTemplate},
+	"python": {"packet_endpoints.py", pyEndpointsTemplate},
+	"csharp": {"PacketEndpoints.cs", csharpEndpointsTemplate},
+	"java":   {"PacketEndpoints.java", javaEndpointsTemplate},
+	"kotlin": {"PacketEndpoints.kt", kotlinEndpointsTemplate},
+	"dart":   {"packet_endpoints.dart", dartEndpointsTemplate},
+	"php":    {"PacketEndpoints.php", phpEndpointsTemplate},
+	"ruby":   {"packet_endpoints.rb", rubyEndpointsTemplate},
+}
+
+// GenerateEndpoints writes the endpoints of every environment of cfg for lang, with env, or the
+// default environment of cfg if empty, as the environment clients connect to unless overridden
+func GenerateEndpoints(result *parser.ParseResult, cfg *config.Endpoints, env, lang, outDir string) error {
+	file, ok := endpointsFiles[lang]
+	if !ok {
+		return fmt.Errorf("endpoint configuration is not supported for %s", lang)
+	}
+	if env == "" {
+		env = cfg.Default
+	}
+	if cfg.Environment(env) == nil {
+		return fmt.Errorf("environment %q is not defined in endpoints; defined environments: %s", env, strings.Join(cfg.Names(), ", "))
+	}
+
+	var envs []endpointEnvironment
+	for _, e := range cfg.Environments {
+		env := endpointEnvironment{Name: e.Name, Endpoints: e.Preferred(), TLS: e.UsesTLS(), MinVersion: "1.2"}
+		if e.TLS != nil {
+			env.ServerName, env.MinVersion, env.InsecureSkipVerify = e.TLS.ServerName, e.TLS.MinVersion, e.TLS.InsecureSkipVerify
+		}
+		envs = append(envs, env)
+	}
+
+	funcMap := template.FuncMap{
+		"toPascalCase": toPascalCase,
+		"join":         strings.Join,
+		"upper":        strings.ToUpper,
+		"nodot":        func(v string) string { return strings.ReplaceAll(v, ".", "") },
+		"underscore":   func(v string) string { return strings.ReplaceAll(v, ".", "_") },
+	}
+	data := struct {
+		*parser.ParseResult
+		Default       string
+		Names         []string
+		Environments  []endpointEnvironment
+		AllTransports []string // Transports a variable may set a URL for
+	}{result, env, cfg.Names(), envs, config.EndpointTransports}
+
+	return writeTemplate(outDir, file.fileName, lang+"_endpoints", file.text, funcMap, data)
+}
@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const goErrorsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+{{- if .Details }}
+	"google.golang.org/protobuf/types/known/anypb"
+{{- end }}
+)
+
+// ProtocolError is an error reported to the peer as ErrorRes
+type ProtocolError struct {
+	Code    {{.CodeType}}
+	Message string
+{{- if .Details }}
+	Details *anypb.Any
+{{- end }}
+}
+
+// NewProtocolError returns a ProtocolError with a formatted message
+func NewProtocolError(code {{.CodeType}}, format string, args ...any) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// ErrorResFor converts err into an ErrorRes. Errors that are not (and do not wrap) a
+// ProtocolError are reported as {{.Internal}} without exposing their text.
+func ErrorResFor(err error) *ErrorRes {
+	var perr *ProtocolError
+	if !errors.As(err, &perr) {
+		return &ErrorRes{Code: {{.Internal}}, Message: "internal error"}
+	}
+	return &ErrorRes{Code: perr.Code, Message: perr.Message{{if .Details}}, Details: perr.Details{{end}}}
+}
+
+// ProtocolErrorFrom converts a received ErrorRes into a ProtocolError
+func ProtocolErrorFrom(res *ErrorRes) *ProtocolError {
+	return &ProtocolError{Code: res.GetCode(), Message: res.GetMessage(){{if .Details}}, Details: res.GetDetails(){{end}}}
+}
+
+// SendError sends err as ErrorRes with the request's header, so the peer can correlate it by request_id
+func SendError(stream PacketStream, header *Header, err error) error {
+	return SendErrorRes(stream, header, ErrorResFor(err))
+}
+
+// FalliblePacketHandler is a PacketHandler whose methods return errors. DispatchFallible sends
+// a returned error back to the peer as ErrorRes correlated to the request.
+type FalliblePacketHandler interface {
+{{- range .HandledPayloads }}
+{{- lineComment "\t// " .Doc }}
+	On{{.Name}}(header *Header, msg *{{.Name}}) error
+{{- end }}
+}
+
+// DispatchFallible decodes data, calls handler, and answers a handler error with ErrorRes on stream
+func DispatchFallible(stream PacketStream, data []byte, handler FalliblePacketHandler) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+{{- if .HasBroadcast }}
+	if isReadOnly(handler) {
+		return fmt.Errorf("%w: %s", ErrReadOnlySession, PayloadName(pkt))
+	}
+{{- end }}
+
+{{- if .HasSuperseded }}
+	upgradePacket(pkt)
+{{- end }}
+
+	var err error
+	switch payload := pkt.Payload.(type) {
+{{- range .HandledPayloads }}
+	case *{{$.Wrapper}}_{{.Name}}:
+{{- if .Feature }}
+		if ferr := checkFeature(handler, "{{.Feature}}"); ferr != nil {
+			return SendError(stream, pkt.Header, NewProtocolError({{$.Forbidden}}, "%v", ferr))
+		}
+{{- end }}
+{{- if .Normalized }}
+		Normalize{{.Name}}(payload.{{.Name}})
+{{- end }}
+{{- if .Validated }}
+		if verr := Validate{{.Name}}(payload.{{.Name}}); verr != nil {
+			handleValidationError(handler, pkt.Header, verr)
+			return SendError(stream, pkt.Header, NewProtocolError({{$.BadRequest}}, "%v", verr))
+		}
+{{- end }}
+{{- if .MaxPageSize }}
+		if payload.{{.Name}} != nil {
+			payload.{{.Name}}.PageSize = clampPageSize(payload.{{.Name}}.PageSize, {{.MaxPageSize}})
+		}
+{{- end }}
+		err = handler.On{{.Name}}(pkt.Header, payload.{{.Name}})
+{{- end }}
+	default:
+		return fmt.Errorf("unknown packet type")
+	}
+
+	if err != nil {
+		return SendError(stream, pkt.Header, err)
+	}
+	return nil
+}
+
+// ServeFallible reads packets from stream and dispatches them to handler until the stream fails
+func ServeFallible(stream PacketStream, handler FalliblePacketHandler) error {
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		if err := DispatchFallible(stream, data, handler); err != nil {
+			logDispatchError(err)
+			continue
+		}
+	}
+}
+`
+
+const tsErrorsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+
+type {{.Wrapper}} = {{.PackageName}}.{{.Wrapper}};
+type ErrorRes = {{.PackageName}}.ErrorRes;
+type ErrorCode = {{.PackageName}}.{{.CodeName}};
+
+/** A typed protocol error received as ErrorRes */
+export class ProtocolError extends Error {
+  constructor(
+    readonly code: ErrorCode,
+    message: string,
+    /** request_id of the request that failed */
+    readonly requestId: string = "",
+{{- if .Details }}
+    readonly details?: ErrorRes["details"],
+{{- end }}
+  ) {
+    super(message);
+    this.name = "ProtocolError";
+  }
+}
+
+/** Returns the ProtocolError carried by pkt, or undefined if pkt is not an ErrorRes */
+export function protocolErrorOf(pkt: {{.Wrapper}}): ProtocolError | undefined {
+  const res = pkt.errorRes;
+  if (!res) {
+    return undefined;
+  }
+  return new ProtocolError(res.code, res.message, pkt.header?.requestId ?? ""{{if .Details}}, res.details{{end}});
+}
+`
+
+// errorsData describes the ErrorRes payload for the error templates
+type errorsData struct {
+	*parser.ParseResult
+	CodeName   string // Proto name of the error code enum, relative to the package (e.g., "ErrorCode")
+	CodeType   string // Go type of the error code enum
+	Internal   string // Go constant reported for non-protocol errors
+	Forbidden  string // Go constant reported for disabled features
+	BadRequest string // Go constant reported for packets breaking validation constraints
+	Details    bool   // ErrorRes has a google.protobuf.Any details field
+}
+
+// errorsFor inspects the ErrorRes payload. It returns nil if the schema has none.
+func errorsFor(result *parser.ParseResult) (*errorsData, error) {
+	res := result.Payload("ErrorRes")
+	if res == nil {
+		return nil, nil
+	}
+
+	data := &errorsData{ParseResult: result}
+	hasMessage := false
+	for _, f := range res.Fields {
+		switch {
+		case f.Name == "code" && f.Kind == "enum" && !f.Repeated:
+			data.CodeName = strings.TrimPrefix(f.TypeName, result.PackageName+".")
+		case f.Name == "message" && f.Kind == "string" && !f.Repeated:
+			hasMessage = true
+		case f.Name == "details" && f.TypeName == "google.protobuf.Any" && !f.Repeated:
+			data.Details = true
+		}
+	}
+	if data.CodeName == "" || !hasMessage {
+		return nil, fmt.Errorf("ErrorRes must have fields `<enum> code` and `string message`")
+	}
+
+	desc, err := result.Schema.Files.FindDescriptorByName(protoreflect.FullName(fullName(result, data.CodeName)))
+	if err != nil {
+		return nil, fmt.Errorf("error code enum: %w", err)
+	}
+	enum := desc.(protoreflect.EnumDescriptor)
+
+	// protoc-gen-go names enum values <Enum>_<VALUE>, with nested names joined by "_"
+	data.CodeType = strings.ReplaceAll(data.CodeName, ".", "_")
+	data.Internal = data.CodeType + "_" + string(enum.Values().Get(0).Name())
+	data.Forbidden = data.Internal
+	data.BadRequest = data.Internal
+	for i := 0; i < enum.Values().Len(); i++ {
+		name := string(enum.Values().Get(i).Name())
+		if strings.HasSuffix(name, "INTERNAL") {
+			data.Internal = data.CodeType + "_" + name
+		}
+		if strings.HasSuffix(name, "FORBIDDEN") {
+			data.Forbidden = data.CodeType + "_" + name
+		}
+		if strings.HasSuffix(name, "BAD_REQUEST") || strings.HasSuffix(name, "INVALID_ARGUMENT") {
+			data.BadRequest = data.CodeType + "_" + name
+		}
+	}
+	return data, nil
+}
+
+// generateGoErrors writes packet_errors.go when the schema declares ErrorRes
+func generateGoErrors(result *parser.ParseResult, outDir string) error {
+	data, err := errorsFor(result)
+	if data == nil {
+		return err
+	}
+	return writeTemplate(outDir, "packet_errors.go", "go_errors", goErrorsTemplate, template.FuncMap{"lineComment": lineComment}, data)
+}
+
+// generateTSErrors writes PacketErrors.ts when the schema declares ErrorRes
+func generateTSErrors(result *parser.ParseResult, outDir string) error {
+	data, err := errorsFor(result)
+	if data == nil {
+		return err
+	}
+	return writeTemplate(outDir, "PacketErrors.ts", "ts_errors", tsErrorsTemplate, nil, data)
+}
@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goAdminTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// AdminDialer opens an authenticated admin connection to addr.
+// token is the operator credential given with -token or $SOCKETGEN_ADMIN_TOKEN.
+type AdminDialer func(addr string, token string) (PacketStream, error)
+
+// RunAdminCLI runs the operator CLI with one subcommand per server-control payload.
+// It is meant to be called from a main package:
+//
+//	func main() {
+//		if err := {{.PackageName}}.RunAdminCLI(os.Args[1:], dialAdmin); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			os.Exit(1)
+//		}
+//	}
+func RunAdminCLI(args []string, dial AdminDialer) error {
+	global := flag.NewFlagSet("admin", flag.ContinueOnError)
+	addr := global.String("addr", "localhost:8080", "admin address of the server")
+	token := global.String("token", os.Getenv("SOCKETGEN_ADMIN_TOKEN"), "admin token (default $SOCKETGEN_ADMIN_TOKEN)")
+	wait := global.Bool("wait", false, "wait for one response packet and print it")
+	global.Usage = func() {
+		fmt.Fprintln(global.Output(), "Usage: admin [flags] <command> [command flags]")
+		fmt.Fprintln(global.Output(), "\nCommands:")
+{{- range .Payloads }}{{ if .Admin }}
+		fmt.Fprintln(global.Output(), "  {{ adminCommand .Name }}")
+{{- end }}{{ end }}
+		fmt.Fprintln(global.Output(), "\nFlags:")
+		global.PrintDefaults()
+	}
+
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return errors.New("missing command")
+	}
+
+	var send func(stream PacketStream) error
+	switch command := global.Arg(0); command {
+{{- range .Payloads }}{{ if .Admin }}
+	case "{{ adminCommand .Name }}":
+		msg := &{{.Name}}{}
+		fs := flag.NewFlagSet("{{ adminCommand .Name }}", flag.ContinueOnError)
+		body := fs.String("json", "", "payload as protobuf JSON; individual flags override its fields")
+{{- range .Fields }}{{ if goFlagFunc . }}
+		f{{.Name | toGoName}} := fs.{{ goFlagFunc . }}("{{.Name}}", {{ goFlagZero . }}, "{{.Name}} ({{.Kind}})")
+{{- end }}{{ end }}
+		if err := fs.Parse(global.Args()[1:]); err != nil {
+			return err
+		}
+		if *body != "" {
+			if err := protojson.Unmarshal([]byte(*body), msg); err != nil {
+				return fmt.Errorf("invalid -json: %w", err)
+			}
+		}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+{{- range .Fields }}{{ if goFlagFunc . }}
+			case "{{.Name}}":
+				msg.{{.Name | goFieldName}} = {{ goFlagCast . }}(*f{{.Name | toGoName}})
+{{- end }}{{ end }}
+			}
+		})
+		send = func(stream PacketStream) error {
+			return Send{{.Name}}(stream, &Header{}, msg)
+		}
+{{- end }}{{ end }}
+	default:
+		global.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	stream, err := dial(*addr, *token)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	if closer, ok := stream.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if err := send(stream); err != nil {
+		return err
+	}
+	if !*wait {
+		return nil
+	}
+
+	data, err := stream.ReadPacket()
+	if err != nil {
+		return err
+	}
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	fmt.Println(protojson.Format(pkt))
+	return nil
+}
+`
+
+func generateGoAdmin(result *parser.ParseResult, outDir string) error {
+	funcMap := template.FuncMap{
+		"toGoName":     toGoName,
+		"goFieldName":  goFieldName,
+		"adminCommand": adminCommand,
+		"goFlagFunc":   goFlagFunc,
+		"goFlagZero":   goFlagZero,
+		"goFlagCast":   goFlagCast,
+	}
+
+	return writeTemplate(outDir, "packet_admin.go", "go_admin", goAdminTemplate, funcMap, result)
+}
+
+// adminCommand derives the CLI subcommand name from an admin payload name
+// e.g. AdminKick -> kick, AdminMaintenanceMode -> maintenance-mode
+func adminCommand(name string) string {
+	return toKebabCase(strings.TrimPrefix(name, "Admin"))
+}
+
+// goFlagFunc returns the flag.FlagSet method used for a field, or "" if the field
+// can only be set through -json
+func goFlagFunc(f parser.MessageField) string {
+	if f.Repeated || f.Map {
+		return ""
+	}
+	switch f.Kind {
+	case "string":
+		return "String"
+	case "bool":
+		return "Bool"
+	case "int32", "sint32", "sfixed32", "int64", "sint64", "sfixed64":
+		return "Int64"
+	case "uint32", "fixed32", "uint64", "fixed64":
+		return "Uint64"
+	case "float", "double":
+		return "Float64"
+	}
+	return ""
+}
+
+func goFlagZero(f parser.MessageField) string {
+	switch goFlagFunc(f) {
+	case "String":
+		return `""`
+	case "Bool":
+		return "false"
+	}
+	return "0"
+}
+
+// goFlagCast returns the conversion from the flag value to the Go field type
+func goFlagCast(f parser.MessageField) string {
+	switch f.Kind {
+	case "int32", "sint32", "sfixed32":
+		return "int32"
+	case "uint32", "fixed32":
+		return "uint32"
+	case "float":
+		return "float32"
+	}
+	return ""
+}
@@ -0,0 +1,217 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Traffic is counted on every packet, so the counters are atomics in a fixed array indexed by
+// payload: counting takes no lock and allocates nothing, whatever the number of sessions.
+
+const goBandwidthTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+{{- if .Query }}
+	"errors"
+{{- end }}
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// bandwidthPayloads are the payloads traffic is counted by; "" counts packets without a payload
+var bandwidthPayloads = [...]string{
+	"",
+{{- range .Payloads }}
+	"{{.FieldName}}",
+{{- end }}
+}
+
+var bandwidthSlots = func() map[string]int {
+	slots := make(map[string]int, len(bandwidthPayloads))
+	for i, payload := range bandwidthPayloads {
+		slots[payload] = i
+	}
+	return slots
+}()
+
+// PayloadTraffic is the traffic of one payload. Bytes are those of the encoded {{.Wrapper}}s,
+// without the framing of the transports.
+type PayloadTraffic struct {
+	Payload    string ` + "`json:\"payload\"`" + ` // Payload field name, "" for packets without a payload
+	PacketsIn  uint64 ` + "`json:\"packetsIn\"`" + `
+	BytesIn    uint64 ` + "`json:\"bytesIn\"`" + `
+	PacketsOut uint64 ` + "`json:\"packetsOut\"`" + `
+	BytesOut   uint64 ` + "`json:\"bytesOut\"`" + `
+}
+
+// BandwidthMeter counts the packets and bytes received and sent by payload. The Server has one
+// for all clients, and every Client one of its own.
+type BandwidthMeter struct {
+	counters [len(bandwidthPayloads)][2]bandwidthCounter // By payload, then in and out
+}
+
+type bandwidthCounter struct {
+	packets atomic.Uint64
+	bytes   atomic.Uint64
+}
+
+func (m *BandwidthMeter) add(slot int, size int, outbound bool) {
+	direction := 0
+	if outbound {
+		direction = 1
+	}
+	c := &m.counters[slot][direction]
+	c.packets.Add(1)
+	c.bytes.Add(uint64(size))
+}
+
+// Traffic returns the traffic of every payload that has any, the most bytes first
+func (m *BandwidthMeter) Traffic() []PayloadTraffic {
+	var traffic []PayloadTraffic
+	for i, payload := range bandwidthPayloads {
+		t := PayloadTraffic{
+			Payload:    payload,
+			PacketsIn:  m.counters[i][0].packets.Load(),
+			BytesIn:    m.counters[i][0].bytes.Load(),
+			PacketsOut: m.counters[i][1].packets.Load(),
+			BytesOut:   m.counters[i][1].bytes.Load(),
+		}
+		if t.PacketsIn > 0 || t.PacketsOut > 0 {
+			traffic = append(traffic, t)
+		}
+	}
+	sort.SliceStable(traffic, func(i, j int) bool {
+		return traffic[i].BytesIn+traffic[i].BytesOut > traffic[j].BytesIn+traffic[j].BytesOut
+	})
+	return traffic
+}
+
+// WriteTo writes the counters in the OpenMetrics text format
+func (m *BandwidthMeter) WriteTo(w io.Writer) (int64, error) {
+	traffic := m.Traffic()
+	sort.Slice(traffic, func(i, j int) bool { return traffic[i].Payload < traffic[j].Payload })
+
+	var b strings.Builder
+	b.WriteString("# TYPE socketgen_bandwidth_packets counter\n# HELP socketgen_bandwidth_packets Packets by payload and direction, counted on every packet.\n")
+	for _, t := range traffic {
+		b.WriteString("socketgen_bandwidth_packets_total{payload=\"" + t.Payload + "\",direction=\"in\"} " + strconv.FormatUint(t.PacketsIn, 10) + "\n")
+		b.WriteString("socketgen_bandwidth_packets_total{payload=\"" + t.Payload + "\",direction=\"out\"} " + strconv.FormatUint(t.PacketsOut, 10) + "\n")
+	}
+	b.WriteString("# TYPE socketgen_bandwidth_bytes counter\n# HELP socketgen_bandwidth_bytes Encoded packet bytes by payload and direction, counted on every packet.\n")
+	for _, t := range traffic {
+		b.WriteString("socketgen_bandwidth_bytes_total{payload=\"" + t.Payload + "\",direction=\"in\"} " + strconv.FormatUint(t.BytesIn, 10) + "\n")
+		b.WriteString("socketgen_bandwidth_bytes_total{payload=\"" + t.Payload + "\",direction=\"out\"} " + strconv.FormatUint(t.BytesOut, 10) + "\n")
+	}
+	b.WriteString("# EOF\n")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the counters to scrapers
+func (m *BandwidthMeter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// Bandwidth returns the traffic counters of the client
+func (c *Client) Bandwidth() *BandwidthMeter {
+	return &c.bandwidth
+}
+
+// count adds a packet the client received or was sent to its counters and the server's
+func (c *Client) count(data []byte, outbound bool) {
+	slot := bandwidthSlots[peekPayload(data)]
+	c.bandwidth.add(slot, len(data), outbound)
+	if c.global != nil {
+		c.global.add(slot, len(data), outbound)
+	}
+}
+{{- if .Query }}
+
+// ErrUnknownSession is returned for a session ID no connected client has
+var ErrUnknownSession = errors.New("unknown session")
+
+// BandwidthReport answers an {{.Req.Name}} query with the bytes of every payload, of the session
+// it names or, without one, of all clients. Send it back from the admin handler:
+//
+//	res, err := srv.BandwidthReport(msg)
+//	if err != nil { ... }
+//	Send{{.Res.Name}}(stream, header, res)
+func (s *Server) BandwidthReport(req *{{.Req.Name}}) (*{{.Res.Name}}, error) {
+	meter := &s.Bandwidth
+	if req.GetSession() != "" {
+		c := s.Sessions.Get(req.GetSession())
+		if c == nil {
+			return nil, ErrUnknownSession
+		}
+		meter = c.Bandwidth()
+	}
+
+	res := &{{.Res.Name}}{Session: req.GetSession(), Sent: map[string]uint64{}, Received: map[string]uint64{}}
+	for _, t := range meter.Traffic() {
+		if t.BytesOut > 0 {
+			res.Sent[t.Payload] = t.BytesOut
+		}
+		if t.BytesIn > 0 {
+			res.Received[t.Payload] = t.BytesIn
+		}
+	}
+	return res, nil
+}
+{{- end }}
+`
+
+// bandwidthQuery returns the admin payloads querying the bandwidth counters, AdminBandwidth and
+// AdminBandwidthRes, if the schema declares them, and checks that their fields match what the
+// generated code expects
+func bandwidthQuery(result *parser.ParseResult) (req, res *parser.PayloadMessage, err error) {
+	req, res = result.Payload("AdminBandwidth"), result.Payload("AdminBandwidthRes")
+	if req == nil && res == nil {
+		return nil, nil, nil
+	}
+	if req == nil || res == nil {
+		return nil, nil, fmt.Errorf("bandwidth queries need both AdminBandwidth and AdminBandwidthRes payloads")
+	}
+
+	fields := func(p *parser.PayloadMessage) protoreflect.FieldDescriptors {
+		return result.Schema.PayloadByName(p.FieldName).Message().Fields()
+	}
+	if f := fields(req).ByName("session"); f == nil || f.Kind() != protoreflect.StringKind || f.Cardinality() == protoreflect.Repeated {
+		return nil, nil, fmt.Errorf("AdminBandwidth must have a field `string session`")
+	}
+	if f := fields(res).ByName("session"); f == nil || f.Kind() != protoreflect.StringKind || f.Cardinality() == protoreflect.Repeated {
+		return nil, nil, fmt.Errorf("AdminBandwidthRes must have a field `string session`")
+	}
+	for _, name := range []protoreflect.Name{"sent", "received"} {
+		f := fields(res).ByName(name)
+		if f == nil || !f.IsMap() || f.MapKey().Kind() != protoreflect.StringKind || f.MapValue().Kind() != protoreflect.Uint64Kind {
+			return nil, nil, fmt.Errorf("AdminBandwidthRes must have a field `map<string, uint64> %s`", name)
+		}
+	}
+	return req, res, nil
+}
+
+// generateGoBandwidth writes packet_bandwidth.go, the traffic counters of the Go server
+func generateGoBandwidth(result *parser.ParseResult, outDir string) error {
+	req, res, err := bandwidthQuery(result)
+	if err != nil {
+		return err
+	}
+	if err := generateGoPeek(result, outDir); err != nil {
+		return err
+	}
+	data := struct {
+		*parser.ParseResult
+		Query    bool
+		Req, Res *parser.PayloadMessage
+	}{result, req != nil, req, res}
+	return writeTemplate(outDir, "packet_bandwidth.go", "go_bandwidth", goBandwidthTemplate, nil, data)
+}
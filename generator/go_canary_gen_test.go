@@ -0,0 +1,22 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateCanaryBuilds(t *testing.T) {
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		// Servers only get the canary handler with --canary
+		if _, err := os.Stat(filepath.Join(dir, "packet_canary.go")); !errors.Is(err, fs.ErrNotExist) {
+			return errors.New("GenerateGo wrote packet_canary.go")
+		}
+		return GenerateCanary(result, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
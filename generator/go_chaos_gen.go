@@ -0,0 +1,179 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goChaosTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ChaosConfig describes the network conditions a ChaosStream simulates.
+// Rates are probabilities between 0 and 1, applied independently to every packet.
+type ChaosConfig struct {
+	Latency       time.Duration // Fixed delay added to every packet
+	Jitter        time.Duration // Random extra delay in [0, Jitter)
+	DropRate      float64       // Packet is silently lost
+	DuplicateRate float64       // Packet is delivered twice
+	ReorderRate   float64       // Packet is held back and delivered after the next one
+	Seed          int64         // Seed for the fault sequence; 0 picks a random seed
+	ReadOnly      bool          // Only disturb incoming packets
+	WriteOnly     bool          // Only disturb outgoing packets
+}
+
+// ChaosStream wraps a PacketStream and injects latency, jitter, drops, duplicates, and
+// reordering, so handlers can be validated under bad network conditions in load tests
+// and integration tests.
+type ChaosStream struct {
+	stream PacketStream
+	config ChaosConfig
+
+	mu        sync.Mutex
+	rng       *rand.Rand
+	readHeld  [][]byte // Packets queued for delivery to ReadPacket (duplicates and reordered packets)
+	readLate  []byte   // Packet held back for reordering on the read side
+	writeLate []byte   // Packet held back for reordering on the write side
+}
+
+// NewChaosStream wraps stream with the given network conditions
+func NewChaosStream(stream PacketStream, config ChaosConfig) *ChaosStream {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &ChaosStream{
+		stream: stream,
+		config: config,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// chance reports whether an event with the given probability happens
+func (c *ChaosStream) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64() < rate
+}
+
+// delay sleeps for the configured latency plus jitter
+func (c *ChaosStream) delay() {
+	d := c.config.Latency
+	if c.config.Jitter > 0 {
+		c.mu.Lock()
+		d += time.Duration(c.rng.Int63n(int64(c.config.Jitter)))
+		c.mu.Unlock()
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+func (c *ChaosStream) ReadPacket() ([]byte, error) {
+	if c.config.WriteOnly {
+		return c.stream.ReadPacket()
+	}
+
+	for {
+		c.mu.Lock()
+		if len(c.readHeld) > 0 {
+			data := c.readHeld[0]
+			c.readHeld = c.readHeld[1:]
+			c.mu.Unlock()
+			return data, nil
+		}
+		c.mu.Unlock()
+
+		data, err := c.stream.ReadPacket()
+		if err != nil {
+			// Deliver a held-back packet before surfacing the error
+			c.mu.Lock()
+			late := c.readLate
+			c.readLate = nil
+			c.mu.Unlock()
+			if late != nil {
+				return late, nil
+			}
+			return nil, err
+		}
+		if c.chance(c.config.DropRate) {
+			continue
+		}
+		c.delay()
+
+		c.mu.Lock()
+		if c.readLate == nil && c.config.ReorderRate > 0 && c.rng.Float64() < c.config.ReorderRate {
+			c.readLate = data
+			c.mu.Unlock()
+			continue
+		}
+		if c.readLate != nil {
+			c.readHeld = append(c.readHeld, c.readLate)
+			c.readLate = nil
+		}
+		if c.config.DuplicateRate > 0 && c.rng.Float64() < c.config.DuplicateRate {
+			c.readHeld = append(c.readHeld, data)
+		}
+		c.mu.Unlock()
+		return data, nil
+	}
+}
+
+func (c *ChaosStream) WritePacket(data []byte) error {
+	if c.config.ReadOnly {
+		return c.stream.WritePacket(data)
+	}
+
+	if c.chance(c.config.DropRate) {
+		return nil
+	}
+	c.delay()
+
+	c.mu.Lock()
+	if c.writeLate == nil && c.config.ReorderRate > 0 && c.rng.Float64() < c.config.ReorderRate {
+		c.writeLate = data
+		c.mu.Unlock()
+		return nil
+	}
+	late := c.writeLate
+	c.writeLate = nil
+	duplicate := c.config.DuplicateRate > 0 && c.rng.Float64() < c.config.DuplicateRate
+	c.mu.Unlock()
+
+	if err := c.stream.WritePacket(data); err != nil {
+		return err
+	}
+	if late != nil {
+		if err := c.stream.WritePacket(late); err != nil {
+			return err
+		}
+	}
+	if duplicate {
+		return c.stream.WritePacket(data)
+	}
+	return nil
+}
+
+// Flush delivers a packet still held back for reordering on the write side
+func (c *ChaosStream) Flush() error {
+	c.mu.Lock()
+	late := c.writeLate
+	c.writeLate = nil
+	c.mu.Unlock()
+
+	if late == nil {
+		return nil
+	}
+	return c.stream.WritePacket(late)
+}
+`
+
+// GenerateChaos writes packet_chaos.go, a fault-injecting PacketStream wrapper for tests
+func GenerateChaos(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_chaos.go", "go_chaos", goChaosTemplate, nil, result)
+}
@@ -0,0 +1,22 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateChaosBuilds(t *testing.T) {
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		// Servers only get the chaos stream with --chaos
+		if _, err := os.Stat(filepath.Join(dir, "packet_chaos.go")); !errors.Is(err, fs.ErrNotExist) {
+			return errors.New("GenerateGo wrote packet_chaos.go")
+		}
+		return GenerateChaos(result, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
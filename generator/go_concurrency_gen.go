@@ -0,0 +1,160 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goConcurrencyTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrTooManyInFlight is returned when a session exceeds its limit of concurrent handlers
+var ErrTooManyInFlight = errors.New("too many in-flight handlers")
+
+// OverflowPolicy decides what happens to a packet that arrives while its session is at the limit
+type OverflowPolicy int
+
+const (
+	OverflowWait   OverflowPolicy = iota // Stop reading from the session until a handler finishes (backpressure)
+	OverflowReject                       // Drop the packet and report it to OnReject
+	OverflowClose                        // Report the packet to OnReject and end the session with ErrTooManyInFlight
+)
+
+// ConcurrencyConfig limits the handlers one session may have running at once, so a client
+// sending slow requests cannot monopolize the worker pool
+type ConcurrencyConfig struct {
+	MaxInFlight int // Handlers running at once for the session (default 1)
+	Policy      OverflowPolicy
+	OnReject    func(pkt *{{.Wrapper}}) // Called for packets refused by OverflowReject or OverflowClose, e.g. to send an error (optional)
+}
+
+// SessionLimiter counts the in-flight handlers of one session
+type SessionLimiter struct {
+	config ConcurrencyConfig
+	slots  chan struct{}
+}
+
+// NewSessionLimiter returns a limiter for one session
+func NewSessionLimiter(config ConcurrencyConfig) *SessionLimiter {
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = 1
+	}
+	return &SessionLimiter{config: config, slots: make(chan struct{}, config.MaxInFlight)}
+}
+
+// Acquire takes a handler slot. With OverflowWait it blocks until one is free; otherwise it
+// reports pkt to OnReject and returns ErrTooManyInFlight when the session is at its limit.
+func (l *SessionLimiter) Acquire(pkt *{{.Wrapper}}) error {
+	if l.config.Policy == OverflowWait {
+		l.slots <- struct{}{}
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+		if l.config.OnReject != nil {
+			l.config.OnReject(pkt)
+		}
+		return ErrTooManyInFlight
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (l *SessionLimiter) Release() {
+	<-l.slots
+}
+
+// InFlight returns the number of handlers currently running for the session
+func (l *SessionLimiter) InFlight() int {
+	return len(l.slots)
+}
+
+// overflow reports whether a failed Acquire ends the session
+func (l *SessionLimiter) overflow(err error) bool {
+	return l.config.Policy == OverflowClose && errors.Is(err, ErrTooManyInFlight)
+}
+
+// ServeConcurrent reads packets from stream and dispatches each in its own goroutine, with at
+// most config.MaxInFlight running at once. It waits for running handlers before returning.
+func ServeConcurrent(stream PacketStream, handler PacketHandler, config ConcurrencyConfig) error {
+	limiter := NewSessionLimiter(config)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		pkt := &{{.Wrapper}}{}
+		if err := proto.Unmarshal(data, pkt); err != nil {
+			logDispatchError(err)
+			continue
+		}
+
+		if err := limiter.Acquire(pkt); err != nil {
+			if limiter.overflow(err) {
+				return err
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer limiter.Release()
+			if err := DispatchPacket(pkt, handler); err != nil {
+				logDispatchError(err)
+			}
+		}()
+	}
+}
+{{- if .HasPriority }}
+
+// ServeQueuedLimited is like ServeQueued for a DispatchQueue shared by many sessions: packets of
+// this session only enter q while the session has fewer than limiter's maximum in flight
+func ServeQueuedLimited(stream PacketStream, q *DispatchQueue, limiter *SessionLimiter) error {
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		pkt := &{{.Wrapper}}{}
+		if err := proto.Unmarshal(data, pkt); err != nil {
+			logDispatchError(err)
+			continue
+		}
+
+		if err := limiter.Acquire(pkt); err != nil {
+			if limiter.overflow(err) {
+				return err
+			}
+			continue
+		}
+		if err := q.push(pkt, limiter.Release); err != nil {
+			limiter.Release()
+			if !errors.Is(err, ErrQueueFull) {
+				return err
+			}
+		}
+	}
+}
+{{- end }}
+`
+
+// GenerateConcurrency writes packet_concurrency.go, per-session limits on in-flight handlers
+func GenerateConcurrency(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_concurrency.go", "go_concurrency", goConcurrencyTemplate, nil, result)
+}
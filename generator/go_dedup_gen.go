@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"errors"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goDedupTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DedupConfig configures the request cache of a DedupStream
+type DedupConfig struct {
+	Size int           // Request IDs remembered per session (default 256)
+	TTL  time.Duration // How long a request ID is remembered (default 30s)
+}
+
+// DedupStream wraps the PacketStream of one session and makes handlers idempotent: a packet
+// whose Header.request_id was seen recently is not dispatched again. Instead, the responses
+// written for the original request (packets carrying the same request_id) are sent again.
+// A duplicate of a request that is still being handled is dropped; the original's response
+// answers both. Handlers must write their responses through the DedupStream.
+type DedupStream struct {
+	stream PacketStream
+	config DedupConfig
+
+	mu      sync.Mutex
+	order   *list.List // Most recently seen request first
+	entries map[string]*list.Element
+}
+
+type dedupEntry struct {
+	requestID string
+	seen      time.Time
+	responses [][]byte
+}
+
+// NewDedupStream wraps stream with a per-session cache of recent request IDs
+func NewDedupStream(stream PacketStream, config DedupConfig) *DedupStream {
+	if config.Size <= 0 {
+		config.Size = 256
+	}
+	if config.TTL <= 0 {
+		config.TTL = 30 * time.Second
+	}
+	return &DedupStream{
+		stream:  stream,
+		config:  config,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (d *DedupStream) ReadPacket() ([]byte, error) {
+	for {
+		data, err := d.stream.ReadPacket()
+		if err != nil {
+			return nil, err
+		}
+
+		id := requestIDOf(data)
+		if id == "" {
+			return data, nil
+		}
+
+		replay, duplicate := d.remember(id)
+		if !duplicate {
+			return data, nil
+		}
+		for _, res := range replay {
+			if err := d.stream.WritePacket(res); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *DedupStream) WritePacket(data []byte) error {
+	if id := requestIDOf(data); id != "" {
+		d.mu.Lock()
+		if el, ok := d.entries[id]; ok {
+			entry := el.Value.(*dedupEntry)
+			entry.responses = append(entry.responses, data)
+		}
+		d.mu.Unlock()
+	}
+	return d.stream.WritePacket(data)
+}
+
+// remember records id as seen. For a duplicate, it returns the responses cached for the original request.
+func (d *DedupStream) remember(id string) (responses [][]byte, duplicate bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.evict(now)
+
+	if el, ok := d.entries[id]; ok {
+		return el.Value.(*dedupEntry).responses, true
+	}
+
+	d.entries[id] = d.order.PushFront(&dedupEntry{requestID: id, seen: now})
+	if d.order.Len() > d.config.Size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*dedupEntry).requestID)
+	}
+	return nil, false
+}
+
+// evict drops request IDs older than the TTL
+func (d *DedupStream) evict(now time.Time) {
+	for el := d.order.Back(); el != nil; el = d.order.Back() {
+		entry := el.Value.(*dedupEntry)
+		if now.Sub(entry.seen) < d.config.TTL {
+			return
+		}
+		d.order.Remove(el)
+		delete(d.entries, entry.requestID)
+	}
+}
+
+// requestIDOf returns the request_id of an encoded packet, or "" if it has none or cannot be decoded
+func requestIDOf(data []byte) string {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return ""
+	}
+	return pkt.GetHeader().GetRequestId()
+}
+`
+
+// GenerateDedup writes packet_dedup.go, a stream deduplicating requests by the request_id of the header
+func GenerateDedup(result *parser.ParseResult, outDir string) error {
+	if !hasHeaderField(result, "request_id", "string") {
+		return errors.New("the dedup cache needs a string request_id field in Header")
+	}
+	return writeTemplate(outDir, "packet_dedup.go", "go_dedup", goDedupTemplate, nil, result)
+}
+
+// hasHeaderField reports whether the header has a singular field of the given name and kind
+func hasHeaderField(result *parser.ParseResult, name, kind string) bool {
+	for _, f := range result.Header {
+		if f.Name == name && f.Kind == kind && !f.Repeated && !f.Map {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateDedupBuilds(t *testing.T) {
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		// Servers only get the dedup cache with --dedup
+		if _, err := os.Stat(filepath.Join(dir, "packet_dedup.go")); !errors.Is(err, fs.ErrNotExist) {
+			return errors.New("GenerateGo wrote packet_dedup.go")
+		}
+		return GenerateDedup(result, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
+
+func TestGenerateDedupNeedsRequestID(t *testing.T) {
+	result, dir := parseSchema(t, strings.Replace(testSchema, "  string request_id = 3;\n", "", 1))
+	if err := GenerateDedup(result, dir); err == nil {
+		t.Error("GenerateDedup succeeded for a header without request_id")
+	}
+}
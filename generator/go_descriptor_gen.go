@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goDescriptorTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	_ "embed"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// SchemaDescriptorSet is the serialized FileDescriptorSet of the schema and its imports.
+// Tools built on this package (proxies, loggers, admin UIs) can use it to decode any payload generically.
+//
+//go:embed packet_descriptor.pb
+var SchemaDescriptorSet string
+
+var (
+	schemaFilesOnce sync.Once
+	schemaFiles     *protoregistry.Files
+	schemaFilesErr  error
+)
+
+// SchemaFiles returns a file registry built from SchemaDescriptorSet
+func SchemaFiles() (*protoregistry.Files, error) {
+	schemaFilesOnce.Do(func() {
+		var fds descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal([]byte(SchemaDescriptorSet), &fds); err != nil {
+			schemaFilesErr = fmt.Errorf("failed to decode embedded descriptor set: %w", err)
+			return
+		}
+		schemaFiles, schemaFilesErr = protodesc.NewFiles(&fds)
+	})
+	return schemaFiles, schemaFilesErr
+}
+
+// NewDynamicMessage returns an empty dynamic message for the full message name (e.g. "{{ fullName $ "LoginReq" }}")
+func NewDynamicMessage(fullName string) (*dynamicpb.Message, error) {
+	files, err := SchemaFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(fullName))
+	if err != nil {
+		return nil, err
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", fullName)
+	}
+	return dynamicpb.NewMessage(md), nil
+}
+
+// DecodeDynamicPacket decodes a {{.Wrapper}} without using the generated types.
+// It returns the packet and the name of the payload field that is set, or "" if none is.
+func DecodeDynamicPacket(data []byte) (*dynamicpb.Message, string, error) {
+	pkt, err := NewDynamicMessage("{{ fullName $ $.Wrapper }}")
+	if err != nil {
+		return nil, "", err
+	}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return nil, "", err
+	}
+
+	field := pkt.WhichOneof(pkt.Descriptor().Oneofs().ByName("payload"))
+	if field == nil {
+		return pkt, "", nil
+	}
+	return pkt, string(field.Name()), nil
+}
+`
+
+// generateGoDescriptor writes packet_descriptor.go and the descriptor set it embeds, packet_descriptor.pb
+func generateGoDescriptor(result *parser.ParseResult, outDir string) error {
+	if err := os.WriteFile(filepath.Join(outDir, "packet_descriptor.pb"), result.DescriptorSet, 0644); err != nil {
+		return fmt.Errorf("failed to write descriptor set: %w", err)
+	}
+
+	funcMap := template.FuncMap{
+		"fullName": fullName,
+	}
+	return writeTemplate(outDir, "packet_descriptor.go", "go_descriptor", goDescriptorTemplate, funcMap, result)
+}
+
+// fullName qualifies a message name with the proto package
+func fullName(result *parser.ParseResult, name string) string {
+	if result.PackageName == "" {
+		return name
+	}
+	return result.PackageName + "." + name
+}
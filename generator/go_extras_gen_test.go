@@ -0,0 +1,115 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// extrasConfig configures every Go extra gen derives from socketgen.yaml
+const extrasConfig = `session:
+  user_id: string
+  room_id: int64
+tenant: tenant
+metrics:
+  max_label_values: 20
+security:
+  max_packet_size: 65536
+  rate_limit: 20
+  require_auth: true
+middleware:
+  presets: [logging, metrics, recover, auth, rate_limit]
+endpoints:
+  environments:
+    dev:
+      urls:
+        tcp: localhost:9000
+        ws: ws://localhost:8080/ws
+`
+
+// internalSchema adds the InternalPacket envelope of the server-to-server dispatcher to testSchema
+const internalSchema = testSchema + `
+message ReloadCmd {
+  string reason = 1;
+}
+
+message InternalPacket {
+  Header header = 1;
+  oneof payload {
+    ReloadCmd reload_cmd = 10;
+  }
+}
+`
+
+// loadConfig loads content as socketgen.yaml
+func loadConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), config.DefaultFile)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// TestGoExtrasBuildTogether generates the Go output with every extra, as gen does with all of
+// its flags, and runs the generated tests
+func TestGoExtrasBuildTogether(t *testing.T) {
+	cfg := loadConfig(t, extrasConfig)
+	pkg := generateGoSchemaPackage(t, internalSchema, func(result *parser.ParseResult, dir string) error {
+		// The previous schema had no ChatEvent yet
+		older, _ := parseSchema(t, strings.Replace(testSchema, "    ChatEvent chat_event = 12;\n", "", 1))
+		previous := filepath.Join(t.TempDir(), "previous.pb")
+		if err := os.WriteFile(previous, older.DescriptorSet, 0644); err != nil {
+			return err
+		}
+		internal, err := parser.ParseWrapper(filepath.Join(dir, "packet.proto"), parser.InternalWrapper)
+		if err != nil {
+			return err
+		}
+
+		// In the order of gen
+		steps := []func() error{
+			func() error { return GenerateSession(result, cfg.Session, "go", dir) },
+			func() error { return GenerateTenant(result, cfg.Tenant, dir) },
+			func() error { return GenerateZeroAlloc(result, dir) },
+			func() error { return GenerateMetrics(result, cfg.Metrics, dir) },
+			func() error { return GenerateSim(result, dir) },
+			func() error { return GenerateCanary(result, dir) },
+			func() error { return GeneratePrevious(result, previous, dir) },
+			func() error { return GenerateSecurity(result, cfg.Security, dir) },
+			func() error { return GenerateConcurrency(result, dir) },
+			func() error { return GenerateDedup(result, dir) },
+			func() error { return GenerateReplayWindow(result, dir) },
+			func() error { return GenerateMiddleware(result, cfg.Middleware, true, dir) },
+			func() error { return GenerateRuntimeConfig(result, dir) },
+			func() error {
+				opts := TransportOptions{Checksum: true, Previous: true, Security: true, Middleware: true}
+				return GenerateTransports(result, []string{"tcp", "ws"}, opts, dir)
+			},
+			func() error { return GenerateEndpoints(result, cfg.Endpoints, "", "go", dir) },
+			func() error { return GenerateGateway(result, nil, dir) },
+			func() error { return GenerateInternal(internal, dir) },
+			func() error { return GenerateCoverage(result, "go", dir) },
+			func() error { return GenerateSamples(result, dir) },
+			func() error { return GenerateChaos(result, dir) },
+			func() error { return GenerateFuzz(result, true, dir) },
+			func() error { return GenerateVectorTests(result, "go", dir) },
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	goCommand(t, "vet", pkg)
+	goCommand(t, "test", pkg)
+}
@@ -0,0 +1,431 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// A custom frame layout replaces frameConn in packet_transport.go with one that reads and writes
+// the layout from socketgen.yaml, so the stream transports can speak an existing protocol. The
+// dispatch layer is unchanged: frames with a type field are turned into GamePacket bytes by
+// wrapping the body in the payload field of its type, without decoding it.
+
+const goFramingTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+{{- if .Magic }}
+	"bytes"
+{{- end }}
+{{- if .NeedsBinary }}
+	"encoding/binary"
+{{- end }}
+{{- if or .Magic .Type }}
+	"errors"
+{{- end }}
+	"fmt"
+{{- if .Checksum }}
+	"hash/crc32"
+{{- end }}
+	"io"
+	"net"
+	"sync"
+{{- if .Type }}
+
+	"google.golang.org/protobuf/encoding/protowire"
+{{- end }}
+)
+
+// FrameHeaderSize is the size of the frame header configured in socketgen.yaml
+const FrameHeaderSize = {{.HeaderSize}}
+
+// maxFrameBody is the largest body the length field can describe
+const maxFrameBody = {{.MaxBody}}
+{{- if .Magic }}
+
+// FrameMagic starts every frame
+var FrameMagic = []byte{ {{- .MagicLiteral -}} }
+
+// ErrBadMagic is returned when a frame does not start with FrameMagic
+var ErrBadMagic = errors.New("bad frame magic")
+{{- end }}
+{{- if .Type }}
+
+// ErrUnknownFrameType is returned for a frame type code that maps to no payload
+var ErrUnknownFrameType = errors.New("unknown frame type")
+
+// FrameTypes maps payload field names to their frame type codes
+var FrameTypes = map[string]uint32{
+{{- range .Types }}
+	"{{.FieldName}}":{{.Pad}} {{.Code}},
+{{- end }}
+}
+
+// OnUnknownFrame is called for every frame whose type maps to no payload, e.g. a keepalive of
+// the existing protocol. The frame is then skipped. If nil, the connection is ended with
+// ErrUnknownFrameType.
+var OnUnknownFrame func(remote net.Addr, code uint32, body []byte)
+{{- end }}
+
+// frameConn carries packets over a byte stream in the layout configured in socketgen.yaml:
+// {{.Describe}}
+type frameConn struct {
+	rw     io.ReadWriteCloser
+	remote net.Addr
+
+	writeMu sync.Mutex
+}
+
+func newFrameConn(rw io.ReadWriteCloser, remote net.Addr) *frameConn {
+	return &frameConn{rw: rw, remote: remote}
+}
+
+func (c *frameConn) ReadPacket() ([]byte, error) {
+	for {
+		pkt, skip, err := c.readFrame()
+		if !skip {
+			return pkt, err
+		}
+	}
+}
+
+// readFrame reads one frame and returns it as an encoded {{.Wrapper}}. skip is true for frames
+// the callbacks chose to drop.
+func (c *frameConn) readFrame() (pkt []byte, skip bool, err error) {
+	var header [FrameHeaderSize]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return nil, false, err
+	}
+{{- if .Magic }}
+	if !bytes.Equal(header[{{.Magic.Offset}}:{{.Magic.End}}], FrameMagic) {
+		return nil, false, NewDisconnectError(DisconnectProtocolError, fmt.Errorf("%w: % x", ErrBadMagic, header[{{.Magic.Offset}}:{{.Magic.End}}]))
+	}
+{{- end }}
+
+	size := int({{.Length.Read}})
+{{- if .LengthIncludesHeader }}
+	if size < FrameHeaderSize {
+		return nil, false, NewDisconnectError(DisconnectProtocolError, fmt.Errorf("frame length %d is shorter than the header", size))
+	}
+	size -= FrameHeaderSize
+{{- end }}
+	if size > MaxFrameSize {
+		return nil, false, NewDisconnectError(DisconnectProtocolError, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size))
+	}
+
+	frame := make([]byte, FrameHeaderSize+size{{if .Checksum}}+4{{end}})
+	copy(frame, header[:])
+	if _, err := io.ReadFull(c.rw, frame[FrameHeaderSize:]); err != nil {
+		return nil, false, err
+	}
+{{- if .Checksum }}
+	n := len(frame) - 4
+	if crc32.Checksum(frame[:n], crc32cTable) != binary.{{.Endian}}.Uint32(frame[n:]) {
+		err := fmt.Errorf("%w: %d-byte frame from %s", ErrFrameCorrupt, size, c.remote)
+		if OnCorruptFrame != nil && OnCorruptFrame(c.remote, err) {
+			return nil, true, nil
+		}
+		return nil, false, NewDisconnectError(DisconnectProtocolError, err)
+	}
+	frame = frame[:n]
+{{- end }}
+	body := frame[FrameHeaderSize:]
+{{- if .Type }}
+
+	var num protowire.Number
+	switch code := {{.TypeField.Read}}; code {
+{{- range .Types }}
+	case {{.Code}}:
+		num = {{.Number}} // {{.FieldName}}
+{{- end }}
+	default:
+		if OnUnknownFrame != nil {
+			OnUnknownFrame(c.remote, code, body)
+			return nil, true, nil
+		}
+		return nil, false, NewDisconnectError(DisconnectProtocolError, fmt.Errorf("%w: %d", ErrUnknownFrameType, code))
+	}
+
+	// Wrap the payload in a {{.Wrapper}} without decoding it
+	pkt = protowire.AppendTag(make([]byte, 0, len(body)+protowire.SizeTag(num)+protowire.SizeVarint(uint64(len(body)))), num, protowire.BytesType)
+	return protowire.AppendBytes(pkt, body), false, nil
+{{- else }}
+	return body, false, nil
+{{- end }}
+}
+
+func (c *frameConn) WritePacket(data []byte) error {
+{{- if .Type }}
+	num, body, err := payloadOf(data)
+	if err != nil {
+		return err
+	}
+	var code uint32
+	switch num {
+{{- range .Types }}
+	case {{.Number}}: // {{.FieldName}}
+		code = {{.Code}}
+{{- end }}
+	default:
+		return fmt.Errorf("%w: payload field %d has no frame type", ErrUnknownFrameType, num)
+	}
+{{- else }}
+	body := data
+{{- end }}
+	if len(body) > MaxFrameSize || len(body) > maxFrameBody {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(body))
+	}
+
+	frame := make([]byte, FrameHeaderSize+len(body){{if .Checksum}}+4{{end}})
+{{- if .Magic }}
+	copy(frame[{{.Magic.Offset}}:], FrameMagic)
+{{- end }}
+	{{.Length.Put}}
+{{- if .Type }}
+	{{.TypeField.Put}}
+{{- end }}
+	copy(frame[FrameHeaderSize:], body)
+{{- if .Checksum }}
+	n := len(frame) - 4
+	binary.{{.Endian}}.PutUint32(frame[n:], crc32.Checksum(frame[:n], crc32cTable))
+{{- end }}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err {{if .Type}}={{else}}:={{end}} c.rw.Write(frame)
+	return err
+}
+
+func (c *frameConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *frameConn) Close() error {
+	return c.rw.Close()
+}
+{{- if .Type }}
+
+// payloadOf returns the field number and the encoded message of the payload set in an encoded
+// {{.Wrapper}}. The header is not sent, since the frame layout has no room for it.
+func payloadOf(data []byte) (protowire.Number, []byte, error) {
+	var num protowire.Number
+	var body []byte
+	for len(data) > 0 {
+		n, typ, l := protowire.ConsumeTag(data)
+		if l < 0 {
+			return 0, nil, protowire.ParseError(l)
+		}
+		data = data[l:]
+		if typ == protowire.BytesType {
+			v, l := protowire.ConsumeBytes(data)
+			if l < 0 {
+				return 0, nil, protowire.ParseError(l)
+			}
+			if isPayloadNumber(n) {
+				num, body = n, v
+			}
+			data = data[l:]
+			continue
+		}
+		l = protowire.ConsumeFieldValue(n, typ, data)
+		if l < 0 {
+			return 0, nil, protowire.ParseError(l)
+		}
+		data = data[l:]
+	}
+	if num == 0 {
+		return 0, nil, fmt.Errorf("packet has no payload")
+	}
+	return num, body, nil
+}
+
+// isPayloadNumber reports whether n is the field number of a {{.Wrapper}} payload
+func isPayloadNumber(n protowire.Number) bool {
+	switch n {
+	case {{range $i, $t := .Types}}{{if $i}}, {{end}}{{.Number}}{{end}}:
+		return true
+	}
+	return false
+}
+{{- end }}
+`
+
+// frameField is a header field of a custom frame layout
+type frameField struct {
+	Offset int
+	End    int
+	Read   string // Go expression reading the field from header as a uint32
+	Put    string // Go statement writing the field into frame
+}
+
+// frameType maps a payload to its frame type code
+type frameType struct {
+	FieldName string
+	Pad       string // Spaces aligning the codes of FrameTypes
+	Number    int32
+	Code      uint32
+}
+
+// generateGoFraming writes packet_framing.go, the frameConn for a custom frame layout
+func generateGoFraming(result *parser.ParseResult, framing *config.Framing, outDir string) error {
+	magic, err := framing.MagicBytes()
+	if err != nil {
+		return err
+	}
+
+	endian := "BigEndian"
+	if framing.Endian == "little" {
+		endian = "LittleEndian"
+	}
+
+	data := struct {
+		*parser.ParseResult
+		HeaderSize           int
+		MaxBody              string
+		Endian               string
+		NeedsBinary          bool
+		Magic                *frameField
+		MagicLiteral         string
+		Length               *frameField
+		Type                 bool
+		TypeField            *frameField
+		Types                []frameType
+		LengthIncludesHeader bool
+		Checksum             bool
+		Describe             string
+	}{
+		ParseResult:          result,
+		Endian:               endian,
+		NeedsBinary:          framing.Length > 1 || framing.Type > 1 || framing.Checksum,
+		Type:                 framing.Type > 0,
+		LengthIncludesHeader: framing.LengthIncludesHeader,
+		Checksum:             framing.Checksum,
+	}
+
+	lengthValue := "len(body)"
+	if framing.LengthIncludesHeader {
+		lengthValue = "FrameHeaderSize+len(body)"
+	}
+
+	var describe []string
+	offset := 0
+	for _, name := range framing.Order {
+		switch name {
+		case "magic":
+			data.Magic = &frameField{Offset: offset, End: offset + len(magic)}
+			describe = append(describe, fmt.Sprintf("magic (%d bytes)", len(magic)))
+			offset += len(magic)
+		case "length":
+			data.Length = newFrameField(offset, framing.Length, endian, lengthValue)
+			describe = append(describe, describeInt("length", framing.Length, framing.Endian))
+			offset += framing.Length
+		case "type":
+			data.TypeField = newFrameField(offset, framing.Type, endian, "code")
+			describe = append(describe, describeInt("type", framing.Type, framing.Endian))
+			offset += framing.Type
+		}
+	}
+	data.HeaderSize = offset
+
+	literals := make([]string, len(magic))
+	for i, b := range magic {
+		literals[i] = fmt.Sprintf("0x%02x", b)
+	}
+	data.MagicLiteral = strings.Join(literals, ", ")
+
+	data.MaxBody = "MaxFrameSize"
+	if framing.Length < 4 {
+		limit := 1<<(8*framing.Length) - 1
+		if framing.LengthIncludesHeader {
+			limit -= data.HeaderSize
+		}
+		data.MaxBody = fmt.Sprint(limit)
+	}
+
+	if data.Type {
+		data.Types, err = frameTypes(result, framing)
+		if err != nil {
+			return err
+		}
+		describe = append(describe, "the payload message")
+	} else {
+		describe = append(describe, "the "+result.Wrapper)
+	}
+	if framing.Checksum {
+		describe = append(describe, "CRC32C of the header and body")
+	}
+	data.Describe = strings.Join(describe, ", ")
+
+	return writeTemplate(outDir, "packet_framing.go", "go_framing", goFramingTemplate, nil, data)
+}
+
+// describeInt describes an integer header field for the frameConn doc comment
+func describeInt(name string, width int, endian string) string {
+	if width == 1 {
+		return name + " (1 byte)"
+	}
+	return fmt.Sprintf("%s (%d bytes, %s-endian)", name, width, endian)
+}
+
+// newFrameField describes a width-byte integer field at offset. value is the Go expression
+// written by Put.
+func newFrameField(offset, width int, endian, value string) *frameField {
+	f := &frameField{Offset: offset, End: offset + width}
+	switch width {
+	case 1:
+		f.Read = fmt.Sprintf("uint32(header[%d])", offset)
+		f.Put = fmt.Sprintf("frame[%d] = byte(%s)", offset, value)
+	case 2:
+		f.Read = fmt.Sprintf("uint32(binary.%s.Uint16(header[%d:%d]))", endian, offset, f.End)
+		f.Put = fmt.Sprintf("binary.%s.PutUint16(frame[%d:%d], uint16(%s))", endian, offset, f.End, value)
+	default:
+		f.Read = fmt.Sprintf("binary.%s.Uint32(header[%d:%d])", endian, offset, f.End)
+		f.Put = fmt.Sprintf("binary.%s.PutUint32(frame[%d:%d], uint32(%s))", endian, offset, f.End, value)
+	}
+	return f
+}
+
+// frameTypes assigns a type code to every payload: the code from the configuration, or the
+// payload's field number
+func frameTypes(result *parser.ParseResult, framing *config.Framing) ([]frameType, error) {
+	known := map[string]bool{}
+	for _, p := range result.Payloads {
+		known[p.FieldName] = true
+	}
+	for name := range framing.Types {
+		if !known[name] {
+			return nil, fmt.Errorf("framing: types lists %q, which is not a payload", name)
+		}
+	}
+
+	limit := uint64(1)<<(8*framing.Type) - 1
+	owner := map[uint32]string{}
+	types := make([]frameType, 0, len(result.Payloads))
+	for _, p := range result.Payloads {
+		number := int32(result.Schema.PayloadByName(p.FieldName).Number())
+		code, ok := framing.Types[p.FieldName]
+		if !ok {
+			code = uint32(number)
+		}
+		if uint64(code) > limit {
+			return nil, fmt.Errorf("framing: type code %d of %s does not fit in %d bytes", code, p.FieldName, framing.Type)
+		}
+		if other, dup := owner[code]; dup {
+			return nil, fmt.Errorf("framing: %s and %s have the same type code %d", other, p.FieldName, code)
+		}
+		owner[code] = p.FieldName
+		types = append(types, frameType{FieldName: p.FieldName, Number: number, Code: code})
+	}
+
+	width := 0
+	for _, t := range types {
+		width = max(width, len(t.FieldName))
+	}
+	for i := range types {
+		types[i].Pad = strings.Repeat(" ", width-len(types[i].FieldName))
+	}
+	return types, nil
+}
@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The fuzz tests are seeded with the golden vectors, so the fuzzer starts from valid packets of
+// every payload and mutates them into the semi-valid ones a hostile peer would send.
+
+const goFuzzTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+{{- if .Framing }}
+	"bytes"
+	"io"
+	"net"
+{{- end }}
+	"runtime"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxFuzzAllocPerByte bounds the memory a packet may allocate when it is decoded and dispatched,
+// per byte of input. Decoding grows an input by a constant factor at most, so an input needing
+// more is an amplification bug, e.g. a length prefix trusted before it is checked.
+const maxFuzzAllocPerByte = 1024
+
+// fuzzSeeds are the encoded sample packets of every payload, with the field number and encoded
+// message of their payload
+var fuzzSeeds = []struct {
+	number protowire.Number
+	packet []byte
+	body   []byte
+}{
+{{- range .Seeds }}
+	{ {{- .Number}}, []byte({{printf "%q" .Packet}}), []byte({{printf "%q" .Body}})}, // {{.FieldName}}
+{{- end }}
+}
+
+// FuzzDispatch feeds arbitrary bytes to Dispatch, which must reject them without panicking
+func FuzzDispatch(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed.packet)
+	}
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkFuzzAlloc(t, len(data), 0, func() {
+			Dispatch(data, fuzzHandler{})
+		})
+	})
+}
+
+// FuzzDispatchPayload wraps arbitrary bytes in a {{.Wrapper}} as the payload picked by index, so
+// every payload's decoding and checks are fuzzed even where random packets rarely reach them
+func FuzzDispatchPayload(f *testing.F) {
+	for i, seed := range fuzzSeeds {
+		f.Add(uint8(i), seed.body)
+	}
+	f.Fuzz(func(t *testing.T, index uint8, body []byte) {
+		seed := fuzzSeeds[int(index)%len(fuzzSeeds)]
+		data := protowire.AppendTag(nil, seed.number, protowire.BytesType)
+		data = protowire.AppendBytes(data, body)
+		checkFuzzAlloc(t, len(data), 0, func() {
+			Dispatch(data, fuzzHandler{})
+		})
+	})
+}
+{{- if .Framing }}
+
+// FuzzFrames feeds arbitrary bytes to the frame decoder of the stream transports and dispatches
+// every packet it reads, until the input runs out or is rejected
+func FuzzFrames(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		var buf bytes.Buffer
+		if err := newFrameConn(fuzzStream{Writer: &buf}, fuzzAddr).WritePacket(seed.packet); err == nil {
+			f.Add(buf.Bytes())
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// A frame header may announce MaxFrameSize bytes before the input turns out shorter
+		checkFuzzAlloc(t, len(data), MaxFrameSize, func() {
+			conn := newFrameConn(fuzzStream{Reader: bytes.NewReader(data)}, fuzzAddr)
+			for {
+				pkt, err := conn.ReadPacket()
+				if err != nil {
+					return
+				}
+				Dispatch(pkt, fuzzHandler{})
+			}
+		})
+	})
+}
+
+var fuzzAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// fuzzStream is a connection reading from Reader and writing to Writer
+type fuzzStream struct {
+	io.Reader
+	io.Writer
+}
+
+func (fuzzStream) Close() error { return nil }
+{{- end }}
+
+// checkFuzzAlloc runs fn and fails t if it allocated more than maxFuzzAllocPerByte per byte of
+// an n-byte input, plus extra bytes
+func checkFuzzAlloc(t *testing.T, n, extra int, fn func()) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+
+	limit := uint64(maxFuzzAllocPerByte*(n+64) + extra)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > limit {
+		t.Fatalf("a %d-byte input allocated %d bytes, more than the limit of %d", n, alloc, limit)
+	}
+}
+
+// fuzzHandler accepts every payload and ignores it
+type fuzzHandler struct{}
+{{ range .HandledPayloads }}
+func (fuzzHandler) On{{.Name}}(*Header, *{{.Name}}) {}
+{{- end }}
+`
+
+type goFuzzSeed struct {
+	FieldName string
+	Number    int32
+	Packet    string
+	Body      string
+}
+
+// GenerateFuzz writes packet_fuzz_test.go, with native Go fuzz tests of Dispatch and, when
+// framing is set, of the frame decoder of the stream transports
+func GenerateFuzz(result *parser.ParseResult, framing bool, outDir string) error {
+	vectors, err := BuildVectors(result)
+	if err != nil {
+		return err
+	}
+	if len(vectors) == 0 {
+		return fmt.Errorf("%s has no payloads to fuzz", result.Wrapper)
+	}
+
+	seeds := make([]goFuzzSeed, 0, len(vectors))
+	for _, v := range vectors {
+		packet, err := hex.DecodeString(v.PacketHex)
+		if err != nil {
+			return err
+		}
+		body, err := hex.DecodeString(v.PayloadHex)
+		if err != nil {
+			return err
+		}
+		seeds = append(seeds, goFuzzSeed{v.Payload, result.Payload(v.Name).Number, string(packet), string(body)})
+	}
+
+	data := struct {
+		*parser.ParseResult
+		Framing bool
+		Seeds   []goFuzzSeed
+	}{result, framing, seeds}
+	return writeTemplate(outDir, "packet_fuzz_test.go", "go_fuzz", goFuzzTemplate, nil, data)
+}
@@ -0,0 +1,353 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The gateway terminates client connections and forwards each packet to the backend service
+// group named by its (socketgen.group) option. The transport to the backends is pluggable;
+// NATS and gRPC implementations are generated on request, each in its own file.
+
+const goGatewayTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// PayloadGroups maps payload field names to the backend group that handles them
+var PayloadGroups = map[string]string{
+{{- range .Payloads }}
+{{- if .Group }}
+	"{{.FieldName}}": "{{.Group}}",
+{{- end }}
+{{- end }}
+}
+
+// ForwardedPacket is a client packet as a backend group receives it
+type ForwardedPacket struct {
+	Session  string // Gateway-assigned ID of the client connection
+	Identity string // Identity returned by Gateway.Authenticate
+	Packet   []byte // The encoded {{.Wrapper}}
+}
+
+// GroupHandler handles packets forwarded to a backend group. A non-empty reply (an encoded
+// {{.Wrapper}}) is sent back to the client.
+type GroupHandler func(ctx context.Context, pkt *ForwardedPacket) (reply []byte, err error)
+
+// Backend carries forwarded packets from the gateway to backend groups
+type Backend interface {
+	Forward(ctx context.Context, group string, pkt *ForwardedPacket) (reply []byte, err error)
+}
+
+// Gateway terminates client connections at the edge: it authenticates each connection, then
+// forwards every packet to the backend group of its payload and relays the replies
+type Gateway struct {
+	Backend Backend
+
+	// Authenticate checks the first packet of a connection and returns the client's identity.
+	// That packet is then routed like any other.
+	Authenticate func(ctx context.Context, session string, pkt *{{.Wrapper}}) (identity string, err error)
+
+	// Local handles payloads without a group (optional; they are dropped otherwise)
+	Local PacketHandler
+
+	// Timeout bounds every forward (default 5s)
+	Timeout time.Duration
+
+	// Lifecycle receives connect, authentication, and disconnect events (optional)
+	Lifecycle Lifecycle
+}
+
+// Serve handles one client connection until it fails. session identifies the connection to backends.
+func (g *Gateway) Serve(ctx context.Context, stream PacketStream, session string) (err error) {
+	lifecycle := g.Lifecycle
+	if lifecycle == nil {
+		lifecycle = NopLifecycle{}
+	}
+	lifecycle.OnConnect(session)
+	defer func() {
+		lifecycle.OnDisconnect(session, DisconnectReasonOf(err), err)
+	}()
+
+	authenticated := false
+	identity := ""
+
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		pkt := &{{.Wrapper}}{}
+		if err := proto.Unmarshal(data, pkt); err != nil {
+			logDispatchError(err)
+			continue
+		}
+
+		if !authenticated {
+			identity, err = g.Authenticate(ctx, session, pkt)
+			if err != nil {
+				return NewDisconnectError(DisconnectProtocolError, fmt.Errorf("%w: %w", ErrUnauthenticated, err))
+			}
+			authenticated = true
+			lifecycle.OnAuthenticated(session, identity)
+		}
+
+		group, ok := PayloadGroups[PayloadName(pkt)]
+		if !ok {
+			if g.Local != nil {
+				if err := DispatchPacket(pkt, g.Local); err != nil {
+					logDispatchError(err)
+				}
+			}
+			continue
+		}
+
+		reply, err := g.forward(ctx, group, &ForwardedPacket{Session: session, Identity: identity, Packet: data})
+		if err != nil {
+			logDispatchError(fmt.Errorf("forward to %s failed: %w", group, err))
+			continue
+		}
+		if len(reply) > 0 {
+			if err := stream.WritePacket(reply); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (g *Gateway) forward(ctx context.Context, group string, pkt *ForwardedPacket) ([]byte, error) {
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return g.Backend.Forward(ctx, group, pkt)
+}
+`
+
+const goGatewayNATSTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Headers carrying ForwardedPacket metadata on NATS messages
+const (
+	natsSessionHeader  = "Socketgen-Session"
+	natsIdentityHeader = "Socketgen-Identity"
+	natsErrorHeader    = "Socketgen-Error"
+)
+
+// NATSBackend forwards packets as NATS requests on the subject <Prefix>.<group>
+type NATSBackend struct {
+	Conn   *nats.Conn
+	Prefix string
+}
+
+// NewNATSBackend returns a backend publishing on nc; prefix defaults to "socketgen"
+func NewNATSBackend(nc *nats.Conn, prefix string) *NATSBackend {
+	if prefix == "" {
+		prefix = "socketgen"
+	}
+	return &NATSBackend{Conn: nc, Prefix: prefix}
+}
+
+func (b *NATSBackend) Forward(ctx context.Context, group string, pkt *ForwardedPacket) ([]byte, error) {
+	msg := nats.NewMsg(b.Prefix + "." + group)
+	msg.Data = pkt.Packet
+	msg.Header.Set(natsSessionHeader, pkt.Session)
+	msg.Header.Set(natsIdentityHeader, pkt.Identity)
+
+	reply, err := b.Conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if reason := reply.Header.Get(natsErrorHeader); reason != "" {
+		return nil, errors.New(reason)
+	}
+	return reply.Data, nil
+}
+
+// ServeNATSGroup handles the packets forwarded to group. Instances of the same group share a
+// queue subscription, so each packet is handled once.
+func ServeNATSGroup(nc *nats.Conn, prefix, group string, handler GroupHandler) (*nats.Subscription, error) {
+	if prefix == "" {
+		prefix = "socketgen"
+	}
+	return nc.QueueSubscribe(prefix+"."+group, group, func(msg *nats.Msg) {
+		reply, err := handler(context.Background(), &ForwardedPacket{
+			Session:  msg.Header.Get(natsSessionHeader),
+			Identity: msg.Header.Get(natsIdentityHeader),
+			Packet:   msg.Data,
+		})
+
+		res := nats.NewMsg(msg.Reply)
+		res.Data = reply
+		if err != nil {
+			res.Header.Set(natsErrorHeader, err.Error())
+		}
+		msg.RespondMsg(res)
+	})
+}
+`
+
+const goGatewayGRPCTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+)
+
+// Forwarded packets travel as raw bytes in a unary call, so backends need no extra proto service
+const (
+	grpcForwardService = "socketgen.Gateway"
+	grpcForwardMethod  = "/" + grpcForwardService + "/Forward"
+	grpcRawCodec       = "socketgen-raw"
+	grpcSessionKey     = "socketgen-session"
+	grpcIdentityKey    = "socketgen-identity"
+)
+
+func init() {
+	encoding.RegisterCodec(rawCodec{})
+}
+
+// rawCodec passes *[]byte messages through unchanged
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("%s codec cannot marshal %T", grpcRawCodec, v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("%s codec cannot unmarshal into %T", grpcRawCodec, v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return grpcRawCodec
+}
+
+// GRPCBackend forwards packets as unary gRPC calls to one connection per group
+type GRPCBackend struct {
+	Conns map[string]grpc.ClientConnInterface // Group -> backend connection
+}
+
+func (b *GRPCBackend) Forward(ctx context.Context, group string, pkt *ForwardedPacket) ([]byte, error) {
+	conn, ok := b.Conns[group]
+	if !ok {
+		return nil, fmt.Errorf("no gRPC connection for group %s", group)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, grpcSessionKey, pkt.Session, grpcIdentityKey, pkt.Identity)
+	req := pkt.Packet
+	var reply []byte
+	if err := conn.Invoke(ctx, grpcForwardMethod, &req, &reply, grpc.CallContentSubtype(grpcRawCodec)); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// RegisterGRPCGroup registers handler on s as the receiver of forwarded packets
+func RegisterGRPCGroup(s *grpc.Server, handler GroupHandler) {
+	call := func(ctx context.Context, data *[]byte) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		pkt := &ForwardedPacket{Packet: *data}
+		if v := md.Get(grpcSessionKey); len(v) > 0 {
+			pkt.Session = v[0]
+		}
+		if v := md.Get(grpcIdentityKey); len(v) > 0 {
+			pkt.Identity = v[0]
+		}
+
+		reply, err := handler(ctx, pkt)
+		if err != nil {
+			return nil, err
+		}
+		return &reply, nil
+	}
+
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: grpcForwardService,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Forward",
+				Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+					var data []byte
+					if err := dec(&data); err != nil {
+						return nil, err
+					}
+					if interceptor == nil {
+						return call(ctx, &data)
+					}
+					info := &grpc.UnaryServerInfo{Server: srv, FullMethod: grpcForwardMethod}
+					return interceptor(ctx, &data, info, func(ctx context.Context, req any) (any, error) {
+						return call(ctx, req.(*[]byte))
+					})
+				},
+			},
+		},
+	}, nil)
+}
+`
+
+// gatewayBackends maps each backend to its file name and template
+var gatewayBackends = map[string]struct {
+	fileName string
+	text     string
+}{
+	"nats": {"packet_gateway_nats.go", goGatewayNATSTemplate},
+	"grpc": {"packet_gateway_grpc.go", goGatewayGRPCTemplate},
+}
+
+// GenerateGateway writes packet_gateway.go, which forwards packets to backend groups, and one
+// file per requested backend (nats, grpc)
+func GenerateGateway(result *parser.ParseResult, backends []string, outDir string) error {
+	if len(result.Groups()) == 0 {
+		return fmt.Errorf("no payload has option (socketgen.group), so there is nothing to forward")
+	}
+	for _, name := range backends {
+		if _, ok := gatewayBackends[name]; !ok {
+			return fmt.Errorf("unknown gateway backend %q (supported: nats, grpc)", name)
+		}
+	}
+
+	if err := writeTemplate(outDir, "packet_gateway.go", "go_gateway", goGatewayTemplate, nil, result); err != nil {
+		return err
+	}
+	for _, name := range backends {
+		b := gatewayBackends[name]
+		if err := writeTemplate(outDir, b.fileName, "go_gateway_"+name, b.text, nil, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
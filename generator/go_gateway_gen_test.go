@@ -0,0 +1,23 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGatewayLogsDispatchErrors(t *testing.T) {
+	test, err := os.ReadFile(filepath.Join("testdata", "gateway_test.go.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		if err := GenerateGateway(result, nil, dir); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, "gateway_test.go"), test, 0644)
+	})
+	goCommand(t, "test", pkg)
+}
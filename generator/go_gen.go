@@ -9,35 +9,43 @@ import (
 	"github.com/snowmerak/socketgen/parser"
 )
 
+// The Go output is split by how often content changes, so adding a payload touches as few
+// lines as possible in review: packet_dispatcher.go is stable, packet_handlers.go holds the
+// per-payload tables, and packet_version.go holds the schema fingerprint.
+
 const goTemplate = `// Code generated by socketgen. DO NOT EDIT.
 package {{.PackageName}}
 
 import (
+	"errors"
 	"fmt"
 	"google.golang.org/protobuf/proto"
 )
 
-type PacketHandler interface {
-{{- range .Payloads }}
-	On{{.Name}}(header *Header, msg *{{.Name}})
-{{- end }}
+// SchemaVersionHeader is the handshake header servers use to advertise SchemaVersion
+const SchemaVersionHeader = "X-Socketgen-Schema"
+
+// SchemaSubprotocol is the WebSocket subprotocol carrying SchemaVersion, for clients that cannot read handshake headers
+const SchemaSubprotocol = "socketgen." + SchemaVersion
+
+// ErrSchemaMismatch is returned by CheckSchemaVersion when the peer runs a different schema
+var ErrSchemaMismatch = errors.New("schema version mismatch")
+
+// CheckSchemaVersion compares the version advertised by the peer with SchemaVersion.
+// An empty version is accepted, since peers built before versioning do not advertise one.
+func CheckSchemaVersion(advertised string) error {
+	if advertised != "" && advertised != SchemaVersion {
+		return fmt.Errorf("%w: peer has %s, this build has %s", ErrSchemaMismatch, advertised, SchemaVersion)
+	}
+	return nil
 }
 
 func Dispatch(data []byte, handler PacketHandler) error {
-	pkt := &GamePacket{}
+	pkt := &{{.Wrapper}}{}
 	if err := proto.Unmarshal(data, pkt); err != nil {
 		return err
 	}
-
-	switch payload := pkt.Payload.(type) {
-{{- range .Payloads }}
-	case *GamePacket_{{.Name}}:
-		handler.On{{.Name}}(pkt.Header, payload.{{.Name}})
-{{- end }}
-	default:
-		return fmt.Errorf("unknown packet type")
-	}
-	return nil
+	return DispatchPacket(pkt, handler)
 }
 
 type PacketStream interface {
@@ -51,19 +59,209 @@ func Serve(stream PacketStream, handler PacketHandler) error {
 		if err != nil {
 			return err
 		}
+		if rejectReadOnly(stream) {
+			continue
+		}
 		if err := Dispatch(data, handler); err != nil {
-			fmt.Println(fmt.Errorf("dispatch error: %w", err))
+			logDispatchError(err)
 			continue
 		}
 	}
 }
 
+// LogDispatchError receives the error of every packet Serve skips over (by default, printed to
+// stdout); replace it to send the errors to your logger
+var LogDispatchError = func(err error) {
+	fmt.Println(fmt.Errorf("dispatch error: %w", err))
+}
+
+// sampleDispatchErrors reports whether to log the next dispatch error; the runtime config of the
+// server sets it to sample the log
+var sampleDispatchErrors func() bool
+
+func logDispatchError(err error) {
+	if sampleDispatchErrors != nil && !sampleDispatchErrors() {
+		return
+	}
+	LogDispatchError(err)
+}
+
+// rejectReadOnly reports whether the packet just read from stream is dropped because the session
+// of stream is read-only, logging the rejection. Every serve loop calls it before dispatching.
+func rejectReadOnly(stream PacketStream) bool {
+{{- if .HasBroadcast }}
+	if isReadOnly(stream) {
+		logDispatchError(ErrReadOnlySession)
+		return true
+	}
+{{- end }}
+	return false
+}
+`
+
+const goHandlersTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+{{- if or .Features .HasBroadcast }}
+	"errors"
+{{- end }}
+	"fmt"
+	"google.golang.org/protobuf/proto"
+)
+
+type PacketHandler interface {
+{{- range .HandledPayloads }}
+{{- lineComment "\t// " .Doc }}
+	On{{.Name}}(header *Header, msg *{{.Name}})
+{{- end }}
+}
+
+// DispatchPacket routes an already decoded packet to handler
+func DispatchPacket(pkt *{{.Wrapper}}, handler PacketHandler) error {
+{{- if .HasBroadcast }}
+	if isReadOnly(handler) {
+		return fmt.Errorf("%w: %s", ErrReadOnlySession, PayloadName(pkt))
+	}
+{{- end }}
+{{- if .HasSuperseded }}
+	upgradePacket(pkt)
+{{- end }}
+	switch payload := pkt.Payload.(type) {
+{{- range .HandledPayloads }}
+	case *{{$.Wrapper}}_{{.Name}}:
+{{- if .Feature }}
+		if err := checkFeature(handler, "{{.Feature}}"); err != nil {
+			return err
+		}
+{{- end }}
+{{- if .Normalized }}
+		Normalize{{.Name}}(payload.{{.Name}})
+{{- end }}
+{{- if .Validated }}
+		if verr := Validate{{.Name}}(payload.{{.Name}}); verr != nil {
+			return handleValidationError(handler, pkt.Header, verr)
+		}
+{{- end }}
+{{- if .MaxPageSize }}
+		if payload.{{.Name}} != nil {
+			payload.{{.Name}}.PageSize = clampPageSize(payload.{{.Name}}.PageSize, {{.MaxPageSize}})
+		}
+{{- end }}
+		handler.On{{.Name}}(pkt.Header, payload.{{.Name}})
+{{- end }}
+	default:
+		return fmt.Errorf("unknown packet type")
+	}
+	return nil
+}
+{{- if .HasPagination }}
+
+// clampPageSize enforces the page size limit of a paginated request; 0 requests the maximum
+func clampPageSize(size, limit int32) int32 {
+	if size <= 0 || size > limit {
+		return limit
+	}
+	return size
+}
+{{- end }}
+
+{{- if .Features }}
+
+// ErrFeatureDisabled is returned by Dispatch for a payload whose feature is turned off
+var ErrFeatureDisabled = errors.New("feature disabled")
+
+// FeatureFlags can be implemented by a PacketHandler to gate payloads marked with the
+// socketgen.feature option. Dispatch rejects those payloads while their feature is disabled.
+type FeatureFlags interface {
+	FeatureEnabled(feature string) bool
+}
+
+// PayloadFeatures maps payload field names to the feature that gates them
+var PayloadFeatures = map[string]string{
+{{- range .Payloads }}
+{{- if .Feature }}
+	"{{.FieldName}}": "{{.Feature}}",
+{{- end }}
+{{- end }}
+}
+
+func checkFeature(handler any, feature string) error {
+	if flags, ok := handler.(FeatureFlags); ok && !flags.FeatureEnabled(feature) {
+		return fmt.Errorf("%w: %s", ErrFeatureDisabled, feature)
+	}
+	return nil
+}
+{{- end }}
+
+{{- if .HasBroadcast }}
+
+// SessionRole tells what a session may do
+type SessionRole int32
+
+const (
+	// RolePlayer sends and receives every payload
+	RolePlayer SessionRole = iota
+	// RoleSpectator is read-only: it only receives broadcast payloads, and every packet it sends is rejected
+	RoleSpectator
+)
+
+// ErrReadOnlySession is returned by Dispatch for any packet from a read-only session
+var ErrReadOnlySession = errors.New("read-only session")
+
+// ErrNotBroadcast is returned when a payload that is not a broadcast is sent to a read-only session
+var ErrNotBroadcast = errors.New("payload is not a broadcast")
+
+// SessionRoles can be implemented by a PacketHandler or a PacketStream to give its session a
+// role. Dispatch rejects every packet of a read-only handler or stream, and the Send functions
+// refuse payloads that are not broadcasts on a read-only stream.
+type SessionRoles interface {
+	SessionRole() SessionRole
+}
+
+// IsBroadcastPayload reports whether a payload field name is marked with socketgen.broadcast
+func IsBroadcastPayload(name string) bool {
+	switch name {
+	case {{range $i, $p := .BroadcastPayloads}}{{if $i}}, {{end}}"{{.FieldName}}"{{end}}:
+		return true
+	}
+	return false
+}
+
+// isBroadcastPacket reports whether an encoded packet carries a broadcast payload
+func isBroadcastPacket(data []byte) bool {
+	pkt := &{{.Wrapper}}{}
+	return proto.Unmarshal(data, pkt) == nil && IsBroadcastPayload(PayloadName(pkt))
+}
+
+func isReadOnly(v any) bool {
+	r, ok := v.(SessionRoles)
+	return ok && r.SessionRole() == RoleSpectator
+}
+{{- end }}
+
+// PayloadName returns the payload field name of pkt (e.g., "login_req"), or "" if no payload is set
+func PayloadName(pkt *{{.Wrapper}}) string {
+	switch pkt.Payload.(type) {
+{{- range .Payloads }}
+	case *{{$.Wrapper}}_{{.Name}}:
+		return "{{.FieldName}}"
+{{- end }}
+	}
+	return ""
+}
+
 {{- range .Payloads }}
 
 func Send{{.Name}}(stream PacketStream, header *Header, msg *{{.Name}}) error {
-	pkt := &GamePacket{
+{{- if and $.HasBroadcast (not .Broadcast) }}
+	if isReadOnly(stream) {
+		return fmt.Errorf("%w: {{.FieldName}}", ErrNotBroadcast)
+	}
+{{- end }}
+	pkt := &{{$.Wrapper}}{
 		Header: header,
-		Payload: &GamePacket_{{.Name}}{
+		Payload: &{{$.Wrapper}}_{{.Name}}{
 			{{.Name}}: msg,
 		},
 	}
@@ -76,29 +274,81 @@ func Send{{.Name}}(stream PacketStream, header *Header, msg *{{.Name}}) error {
 {{- end }}
 `
 
+const goVersionTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+// SchemaVersion is the fingerprint of the schema this code was generated from.
+// Servers advertise it at connect time so stale clients can be detected.
+const SchemaVersion = "{{.SchemaVersion}}"
+`
+
+// goGitAttributes marks the output as generated, so review tools collapse it, and the
+// embedded descriptor set as binary, so it does not produce a textual diff
+const goGitAttributes = `# Code generated by socketgen. DO NOT EDIT.
+* linguist-generated=true
+*.pb binary
+`
+
 func GenerateGo(result *parser.ParseResult, outDir string) error {
-	tmpl, err := template.New("go").Parse(goTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse go template: %w", err)
+	// We assume the dispatcher lives in the same package as the generated proto code (e.g. "packet").
+	// The parser returns TypeName like "LoginReq", which matches the structs protoc-gen-go generates.
+	if err := writeTemplate(outDir, "packet_dispatcher.go", "go", goTemplate, nil, result); err != nil {
+		return err
 	}
-
-	if err := os.MkdirAll(outDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	if err := writeTemplate(outDir, "packet_handlers.go", "go_handlers", goHandlersTemplate, template.FuncMap{"lineComment": lineComment}, result); err != nil {
+		return err
 	}
-
-	outFile := filepath.Join(outDir, "packet_dispatcher.go")
-	f, err := os.Create(outFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+	if err := writeTemplate(outDir, "packet_version.go", "go_version", goVersionTemplate, nil, result); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, ".gitattributes"), []byte(goGitAttributes), 0644); err != nil {
+		return fmt.Errorf("failed to write .gitattributes: %w", err)
+	}
+	if err := generateGoDescriptor(result, outDir); err != nil {
+		return err
+	}
+	if err := generateLifecycle(result, "go", outDir); err != nil {
+		return err
+	}
+	if result.HasSampling() {
+		if err := generateGoTap(result, outDir); err != nil {
+			return err
+		}
+	}
+	if result.HasSuperseded() {
+		if err := generateGoUpgrade(result, outDir); err != nil {
+			return err
+		}
+	}
+	if result.HasValidation() {
+		if err := generateValidation(result, "go", outDir); err != nil {
+			return err
+		}
+	}
+	if result.HasNormalization() {
+		if err := generateGoNormalize(result, outDir); err != nil {
+			return err
+		}
+	}
+	if result.HasPriority() {
+		if err := generateGoQueue(result, outDir); err != nil {
+			return err
+		}
+	}
+	if err := generateGoResume(result, outDir); err != nil {
+		return err
+	}
+	if err := generateGoErrors(result, outDir); err != nil {
+		return err
+	}
+	if err := generateGoRPC(result, outDir); err != nil {
+		return err
 	}
-	defer f.Close()
-
-	// Prepare data for template
-	// We might need to adjust package name or imports depending on where this file is placed relative to the generated proto code.
-	// For now, we assume it's in the same package as the generated proto code (e.g. "packet").
-
-	// Capitalize payload names for Go export rules if needed, but usually proto generates CamelCase structs.
-	// The parser returns TypeName like "LoginReq".
 
-	return tmpl.Execute(f, result)
+	for _, p := range result.Payloads {
+		if p.Admin {
+			return generateGoAdmin(result, outDir)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,35 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestServeRejectsReadOnlySessions(t *testing.T) {
+	test, err := os.ReadFile(filepath.Join("testdata", "read_only_test.go.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		if err := GenerateConcurrency(result, dir); err != nil {
+			return err
+		}
+		if err := GenerateZeroAlloc(result, dir); err != nil {
+			return err
+		}
+		if err := GenerateTenant(result, "tenant", dir); err != nil {
+			return err
+		}
+		if err := GenerateSchemas(result, nil, dir); err != nil {
+			return err
+		}
+		if err := GenerateGateway(result, nil, dir); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, "read_only_test.go"), test, 0644)
+	})
+	goCommand(t, "test", "-race", pkg)
+}
@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Server-to-server traffic uses its own envelope (InternalPacket by convention), so internal
+// payloads never appear in the client protocol. The generated code mirrors the client-facing
+// dispatcher with Internal-prefixed names, so both can live in one package.
+
+const goInternalTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+{{- $header := "" }}
+{{- if .HeaderType }}{{ $header = printf "header *%s, " .HeaderType }}{{ end }}
+
+// InternalHandler handles {{.Wrapper}} payloads sent between services
+type InternalHandler interface {
+{{- range .Payloads }}
+{{- lineComment "\t// " .Doc }}
+	On{{.Name}}({{$header}}msg *{{.Name}})
+{{- end }}
+}
+
+// InternalStream carries encoded {{.Wrapper}}s between services. A PacketStream satisfies it.
+type InternalStream interface {
+	ReadPacket() ([]byte, error)
+	WritePacket([]byte) error
+}
+
+// DispatchInternal decodes a {{.Wrapper}} and routes it to handler
+func DispatchInternal(data []byte, handler InternalHandler) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	return DispatchInternalPacket(pkt, handler)
+}
+
+// DispatchInternalPacket routes an already decoded {{.Wrapper}} to handler
+func DispatchInternalPacket(pkt *{{.Wrapper}}, handler InternalHandler) error {
+	switch payload := pkt.Payload.(type) {
+{{- range .Payloads }}
+	case *{{$.Wrapper}}_{{.Name}}:
+		handler.On{{.Name}}({{if $.HeaderType}}pkt.Header, {{end}}payload.{{.Name}})
+{{- end }}
+	default:
+		return fmt.Errorf("unknown internal packet type")
+	}
+	return nil
+}
+
+// ServeInternal dispatches every packet read from stream to handler until reading fails
+func ServeInternal(stream InternalStream, handler InternalHandler) error {
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if err := DispatchInternal(data, handler); err != nil {
+			fmt.Println(fmt.Errorf("internal dispatch error: %w", err))
+			continue
+		}
+	}
+}
+
+// InternalClient sends {{.Wrapper}} payloads to another service
+type InternalClient struct {
+	stream InternalStream
+}
+
+// NewInternalClient returns a client writing to stream
+func NewInternalClient(stream InternalStream) *InternalClient {
+	return &InternalClient{stream: stream}
+}
+
+func (c *InternalClient) send(pkt *{{.Wrapper}}) error {
+	data, err := proto.Marshal(pkt)
+	if err != nil {
+		return err
+	}
+	return c.stream.WritePacket(data)
+}
+{{- range .Payloads }}
+
+func (c *InternalClient) {{.Name}}({{$header}}msg *{{.Name}}) error {
+	return c.send(&{{$.Wrapper}}{
+{{- if $.HeaderType }}
+		Header: header,
+{{- end }}
+		Payload: &{{$.Wrapper}}_{{.Name}}{
+			{{.Name}}: msg,
+		},
+	})
+}
+{{- end }}
+`
+
+// GenerateInternal writes packet_internal.go, the dispatcher and client for a server-to-server
+// envelope parsed with parser.ParseWrapper
+func GenerateInternal(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_internal.go", "go_internal", goInternalTemplate, template.FuncMap{"lineComment": lineComment}, result)
+}
@@ -0,0 +1,210 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+// The knobs are read on every packet, so they are swapped as a whole behind an atomic pointer: a
+// reload never stalls dispatch, and a packet never sees half of an update.
+
+const goKnobsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// Knobs are the server settings operators may change without a restart, e.g.
+//
+//	{"maxPacketSize": 65536, "rateLimits": {"chat_msg": 2}, "burst": 10, "features": {"guilds": false}, "logEvery": 100}
+//
+// The size and rate limits are enforced by the Guard, which the security section of socketgen.yaml
+// generates, and override its generated limits.
+type Knobs struct {
+	MaxPacketSize int                ` + "`json:\"maxPacketSize\"`" + ` // Largest packet a client may send, in bytes, up to the generated limit (0: the generated limit)
+	RateLimits    map[string]float64 ` + "`json:\"rateLimits\"`" + `    // Packets per second per session by payload field name (0: unlimited)
+	Burst         int                ` + "`json:\"burst\"`" + `         // Packets a session may send at once above the rates (0: the generated burst)
+	Features      map[string]bool    ` + "`json:\"features\"`" + `      // Feature flags; features not listed are enabled
+	LogEvery      int                ` + "`json:\"logEvery\"`" + `      // Log one dispatch error in every LogEvery (0: all)
+}
+
+// knobPayloads are the payload field names RateLimits may name
+var knobPayloads = map[string]bool{
+{{- range .Payloads }}
+	"{{.FieldName}}": true,
+{{- end }}
+}
+
+// validate checks the knobs, so a typo in a reload leaves the running ones in place
+func (k *Knobs) validate() error {
+	if k.MaxPacketSize < 0 {
+		return fmt.Errorf("maxPacketSize must not be negative")
+	}
+	for name, rate := range k.RateLimits {
+		if !knobPayloads[name] {
+			return fmt.Errorf("rateLimits: unknown payload %q", name)
+		}
+		if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return fmt.Errorf("rateLimits: %s must be a positive number of packets per second", name)
+		}
+	}
+	if k.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	if k.LogEvery < 0 {
+		return fmt.Errorf("logEvery must not be negative")
+	}
+	return nil
+}
+
+// RuntimeConfig holds the current Knobs. A reload replaces them as a whole, and is rejected,
+// leaving them unchanged, if the new ones are invalid.
+type RuntimeConfig struct {
+	// OnReload is called after every reload with the knobs in use, and the error if it was
+	// rejected (optional)
+	OnReload func(knobs *Knobs, err error)
+
+	mu     sync.Mutex // Serializes reloads
+	path   string
+	knobs  atomic.Pointer[Knobs]
+	logged atomic.Uint64
+}
+
+// Runtime is the runtime config of the server. Server.Run loads it from the knobs file of the
+// ServerConfig and reloads it on SIGHUP.
+var Runtime = &RuntimeConfig{}
+
+func init() {
+	sampleDispatchErrors = Runtime.sampleLog
+}
+
+// Knobs returns the knobs in use; they must not be modified
+func (r *RuntimeConfig) Knobs() *Knobs {
+	if k := r.knobs.Load(); k != nil {
+		return k
+	}
+	return &Knobs{}
+}
+
+// Set replaces the knobs, e.g. from an admin packet
+func (r *RuntimeConfig) Set(knobs *Knobs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.set(knobs)
+}
+
+// Apply replaces the knobs with the JSON document data, e.g. the body of an admin packet
+func (r *RuntimeConfig) Apply(data []byte) error {
+	var knobs Knobs
+	if err := json.Unmarshal(data, &knobs); err != nil {
+		return r.reloaded(fmt.Errorf("invalid knobs: %w", err))
+	}
+	return r.Set(&knobs)
+}
+
+// Load reads the knobs from the JSON file at path, which Reload reads again
+func (r *RuntimeConfig) Load(path string) error {
+	r.mu.Lock()
+	r.path = path
+	r.mu.Unlock()
+	return r.Reload()
+}
+
+// Reload reads the knobs file given to Load again
+func (r *RuntimeConfig) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.path == "" {
+		return r.reloaded(fmt.Errorf("no knobs file was loaded"))
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return r.reloaded(fmt.Errorf("failed to read knobs: %w", err))
+	}
+	var knobs Knobs
+	if err := json.Unmarshal(data, &knobs); err != nil {
+		return r.reloaded(fmt.Errorf("failed to parse knobs %s: %w", r.path, err))
+	}
+	return r.set(&knobs)
+}
+
+// ReloadOnSIGHUP reloads the knobs file every time the process receives SIGHUP, until stop is called
+func (r *RuntimeConfig) ReloadOnSIGHUP() (stop func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				r.Reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return sync.OnceFunc(func() {
+		signal.Stop(hup)
+		close(done)
+	})
+}
+
+// FeatureEnabled reports whether feature is on; handlers implementing FeatureFlags may return it
+func (r *RuntimeConfig) FeatureEnabled(feature string) bool {
+	enabled, ok := r.Knobs().Features[feature]
+	return !ok || enabled
+}
+
+func (r *RuntimeConfig) set(knobs *Knobs) error {
+	if err := knobs.validate(); err != nil {
+		return r.reloaded(fmt.Errorf("invalid knobs: %w", err))
+	}
+	r.knobs.Store(knobs)
+	return r.reloaded(nil)
+}
+
+func (r *RuntimeConfig) reloaded(err error) error {
+	if r.OnReload != nil {
+		r.OnReload(r.Knobs(), err)
+	}
+	return err
+}
+
+// maxPacketSize returns the packet size limit of the knobs if it is below limit, which the
+// transports enforce, or limit
+func (r *RuntimeConfig) maxPacketSize(limit int) int {
+	if size := r.Knobs().MaxPacketSize; size > 0 && size < limit {
+		return size
+	}
+	return limit
+}
+
+// rateLimit returns the rate limit and burst of payload, or rate and burst if the knobs do not set them
+func (r *RuntimeConfig) rateLimit(payload string, rate, burst float64) (float64, float64) {
+	knobs := r.Knobs()
+	if limit, ok := knobs.RateLimits[payload]; ok && limit != rate {
+		rate, burst = limit, max(1, math.Ceil(2*limit))
+	}
+	if knobs.Burst > 0 {
+		burst = float64(knobs.Burst)
+	}
+	return rate, burst
+}
+
+// sampleLog reports whether to log the next dispatch error under LogEvery
+func (r *RuntimeConfig) sampleLog() bool {
+	every := r.Knobs().LogEvery
+	return every <= 1 || r.logged.Add(1)%uint64(every) == 1
+}
+`
+
+// GenerateRuntimeConfig writes packet_knobs.go, the runtime config of the Go server that Server.Run
+// reloads on SIGHUP and the Guard reads its limits from
+func GenerateRuntimeConfig(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_knobs.go", "go_knobs", goKnobsTemplate, nil, result)
+}
@@ -0,0 +1,233 @@
+package generator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goMetricsTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+{{- if .Labels }}
+	"fmt"
+{{- end }}
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+{{- if .Labels }}
+
+	"google.golang.org/protobuf/proto"
+{{- end }}
+)
+
+// MetricLabelNames lists the labels taken from packet fields, from option (socketgen.metric_label)
+var MetricLabelNames = []string{ {{- range $i, $l := .Labels }}{{if $i}}, {{end}}"{{$l.Name}}"{{end -}} }
+
+// MaxMetricLabelValues caps the distinct values counted per label. Later values are counted
+// together as MetricOverflowValue, so a misbehaving client cannot blow up the series count.
+const MaxMetricLabelValues = {{.MaxLabelValues}}
+
+// MetricOverflowValue replaces label values beyond MaxMetricLabelValues
+const MetricOverflowValue = "other"
+
+// Metrics counts packets and bytes by payload, direction and the labels in MetricLabelNames,
+// and exposes them in the OpenMetrics text format. Feed it from a TapStream, whose sample
+// weights it adds up:
+//
+//	stream = NewTapStream(stream, metrics.Observe)
+//	http.Handle("/metrics", metrics)
+type Metrics struct {
+	mu     sync.Mutex
+	values []map[string]bool // Distinct values counted per label
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labels  string // Rendered label set, e.g. payload="login_req",direction="in"
+	packets float64
+	bytes   float64
+}
+
+// metricEscaper escapes label values as OpenMetrics requires
+var metricEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+// NewMetrics creates empty metrics
+func NewMetrics() *Metrics {
+	m := &Metrics{series: map[string]*metricSeries{}}
+	for range MetricLabelNames {
+		m.values = append(m.values, map[string]bool{})
+	}
+	return m
+}
+
+// Observe counts a packet reported by a TapStream
+func (m *Metrics) Observe(e TapEvent) {
+	direction := "in"
+	if e.Outbound {
+		direction = "out"
+	}
+	var b strings.Builder
+	b.WriteString("payload=\"" + e.Payload + "\",direction=\"" + direction + "\"")
+{{- if .Labels }}
+	values := metricLabelValues(e.Data)
+{{- end }}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+{{- if .Labels }}
+	for i, value := range values {
+		// Unset fields are always counted as ""
+		if value != "" && !m.values[i][value] {
+			if len(m.values[i]) >= MaxMetricLabelValues {
+				value = MetricOverflowValue
+			} else {
+				m.values[i][value] = true
+			}
+		}
+		b.WriteString("," + MetricLabelNames[i] + "=\"" + metricEscaper.Replace(value) + "\"")
+	}
+{{- end }}
+
+	key := b.String()
+	s := m.series[key]
+	if s == nil {
+		s = &metricSeries{labels: key}
+		m.series[key] = s
+	}
+	s.packets += e.Weight
+	s.bytes += e.Weight * float64(len(e.Data))
+}
+{{- if .Labels }}
+
+// metricLabelValues returns the values of MetricLabelNames in an encoded packet; fields of
+// payloads other than the packet's are empty
+func metricLabelValues(data []byte) []string {
+	values := make([]string, len(MetricLabelNames))
+	pkt := &{{.Wrapper}}{}
+	if proto.Unmarshal(data, pkt) != nil {
+		return values
+	}
+{{- range $i, $l := .Labels }}
+{{- if $l.Header }}
+	values[{{$i}}] = fmt.Sprint(pkt.GetHeader().Get{{goFieldName $l.Name}}())
+{{- else }}
+	switch payload := pkt.Payload.(type) {
+{{- range $l.Payloads }}
+	case *{{$.Wrapper}}_{{.}}:
+		values[{{$i}}] = fmt.Sprint(payload.{{.}}.Get{{goFieldName $l.Name}}())
+{{- end }}
+	}
+{{- end }}
+{{- end }}
+	return values
+}
+{{- end }}
+
+// WriteTo writes the metrics in the OpenMetrics text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	series := make([]metricSeries, 0, len(m.series))
+	for _, s := range m.series {
+		series = append(series, *s)
+	}
+	m.mu.Unlock()
+	sort.Slice(series, func(i, j int) bool { return series[i].labels < series[j].labels })
+
+	var b strings.Builder
+	b.WriteString("# TYPE socketgen_packets counter\n# HELP socketgen_packets Packets by payload and direction.\n")
+	for _, s := range series {
+		b.WriteString("socketgen_packets_total{" + s.labels + "} " + strconv.FormatFloat(s.packets, 'g', -1, 64) + "\n")
+	}
+	b.WriteString("# TYPE socketgen_packet_bytes counter\n# HELP socketgen_packet_bytes Encoded packet bytes by payload and direction.\n")
+	for _, s := range series {
+		b.WriteString("socketgen_packet_bytes_total{" + s.labels + "} " + strconv.FormatFloat(s.bytes, 'g', -1, 64) + "\n")
+	}
+	b.WriteString("# EOF\n")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics to scrapers
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	m.WriteTo(w)
+}
+`
+
+// GenerateMetrics writes packet_metrics.go, OpenMetrics counters fed by a TapStream, and the
+// tap itself if needed. Annotated fields listed in cfg.DropLabels are left out.
+func GenerateMetrics(result *parser.ParseResult, cfg *config.Metrics, outDir string) error {
+	if cfg == nil {
+		cfg = &config.Metrics{MaxLabelValues: config.DefaultMaxLabelValues}
+	}
+
+	var labels []parser.MetricLabel
+	for _, label := range result.MetricLabels() {
+		if label.Name == "payload" || label.Name == "direction" {
+			return fmt.Errorf("metric label %s clashes with a built-in label", label.Name)
+		}
+		if !slices.Contains(cfg.DropLabels, label.Name) {
+			labels = append(labels, label)
+		}
+	}
+
+	funcMap := template.FuncMap{
+		"goFieldName": goFieldName,
+	}
+	data := struct {
+		*parser.ParseResult
+		Labels         []parser.MetricLabel
+		MaxLabelValues int
+	}{result, labels, cfg.MaxLabelValues}
+
+	if err := writeTemplate(outDir, "packet_metrics.go", "go_metrics", goMetricsTemplate, funcMap, data); err != nil {
+		return fmt.Errorf("failed to generate metrics: %w", err)
+	}
+	// The metrics are fed by the tap, which GenerateGo only writes for schemas with sample rates
+	if !result.HasSampling() {
+		return generateGoTap(result, outDir)
+	}
+	return nil
+}
+
+// highCardinalityNames are field name parts that suggest a value per user, session, or event
+var highCardinalityNames = []string{"id", "session", "uuid", "token", "ip", "addr", "address", "email", "nonce", "seq", "timestamp", "time", "at"}
+
+// MetricLabelWarnings returns a warning for every metric label that looks like it has many
+// distinct values, unless it is dropped by cfg. Such labels multiply the series of every
+// payload, and most of their values end up counted as "other".
+func MetricLabelWarnings(result *parser.ParseResult, cfg *config.Metrics) []string {
+	var warnings []string
+	for _, label := range result.MetricLabels() {
+		if cfg != nil && slices.Contains(cfg.DropLabels, label.Name) {
+			continue
+		}
+
+		reason := ""
+		switch label.Field.Kind {
+		case "bytes", "float", "double":
+			reason = label.Field.Kind + " values rarely repeat"
+		default:
+			for _, part := range strings.Split(label.Name, "_") {
+				if slices.Contains(highCardinalityNames, part) {
+					reason = "its name suggests a value per user, session, or event"
+					break
+				}
+			}
+		}
+		if reason != "" {
+			warnings = append(warnings, fmt.Sprintf("metric label %s looks high-cardinality (%s); remove its (socketgen.metric_label) option or list it in metrics.drop_labels", label.Name, reason))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,359 @@
+package generator
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Middleware runs around DispatchPacket rather than wrapping the PacketHandler, so handlers keep
+// the optional interfaces (Authenticator, FeatureFlags, SessionRoles) Dispatch looks for.
+
+const goMiddlewareTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Middleware runs around the dispatch of every packet of a session. It calls next to dispatch pkt
+// to handler, or returns an error without calling it to drop the packet; the error is logged like
+// any dispatch error.
+type Middleware func(pkt *{{.Wrapper}}, handler PacketHandler, next func() error) error
+
+// Presets are the middleware presets of middleware.presets of socketgen.yaml, outermost first
+var Presets = []string{ {{- range $i, $p := .Presets}}{{if $i}}, {{end}}"{{$p}}"{{end -}} }
+
+// NewPresets returns the middleware of Presets for a new session, whose rate limits it counts.
+// The transports serve every connection with them; call it once per session when serving streams
+// yourself:
+//
+//	ServeWithMiddleware(stream, handler, NewPresets()...)
+func NewPresets() []Middleware {
+	return []Middleware{
+{{- range .Presets }}
+{{- if eq . "logging" }}
+		LoggingMiddleware(slog.Default()),
+{{- else if eq . "metrics" }}
+		MetricsMiddleware(DefaultHandlerMetrics),
+{{- else if eq . "recover" }}
+		RecoverMiddleware(),
+{{- else if eq . "auth" }}
+		AuthMiddleware(),
+{{- else if eq . "rate_limit" }}
+		RateLimitMiddleware(),
+{{- end }}
+{{- end }}
+	}
+}
+
+// DispatchWithMiddleware is Dispatch running the packet through middlewares, the first outermost
+func DispatchWithMiddleware(data []byte, handler PacketHandler, middlewares ...Middleware) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	return runMiddleware(pkt, handler, middlewares)
+}
+
+func runMiddleware(pkt *{{.Wrapper}}, handler PacketHandler, middlewares []Middleware) error {
+	if len(middlewares) == 0 {
+		return DispatchPacket(pkt, handler)
+	}
+	return middlewares[0](pkt, handler, func() error {
+		return runMiddleware(pkt, handler, middlewares[1:])
+	})
+}
+
+// ServeWithMiddleware is Serve{{if .Security}}Guarded{{end}} dispatching every packet through middlewares, the first outermost
+func ServeWithMiddleware(stream PacketStream, handler PacketHandler, middlewares ...Middleware) error {
+{{- if .Security }}
+	guard := NewGuard()
+{{- end }}
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+{{- if .Security }}
+		if err := guard.Check(data, handler); err != nil {
+			logDispatchError(err)
+			continue
+		}
+{{- end }}
+		if err := DispatchWithMiddleware(data, handler, middlewares...); err != nil {
+			logDispatchError(err)
+			continue
+		}
+	}
+}
+
+// servePresets serves a session with the middleware of Presets, for the transports
+func servePresets(stream PacketStream, handler PacketHandler) error {
+	return ServeWithMiddleware(stream, handler, NewPresets()...)
+}
+
+// LoggingMiddleware logs every packet to logger with its payload and the time it took to
+// dispatch, at the level of middleware.log_level of socketgen.yaml, and packets whose dispatch
+// failed as warnings with their error
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(pkt *{{.Wrapper}}, handler PacketHandler, next func() error) error {
+		start := time.Now()
+		err := next()
+		if err != nil {
+			logger.Warn("packet failed", "payload", PayloadName(pkt), "duration", time.Since(start), "error", err)
+		} else {
+			logger.Log(context.Background(), {{.LogLevel}}, "packet handled", "payload", PayloadName(pkt), "duration", time.Since(start))
+		}
+		return err
+	}
+}
+
+// MetricsMiddleware counts the packets, errors and handling time of every payload in metrics
+func MetricsMiddleware(metrics *HandlerMetrics) Middleware {
+	return func(pkt *{{.Wrapper}}, handler PacketHandler, next func() error) error {
+		start := time.Now()
+		err := next()
+		metrics.observe(PayloadName(pkt), time.Since(start), err)
+		return err
+	}
+}
+
+// ErrHandlerPanic is returned by RecoverMiddleware for a packet whose handler panicked
+var ErrHandlerPanic = errors.New("handler panicked")
+
+// RecoverMiddleware turns a panic of a handler into an ErrHandlerPanic with the stack of the
+// panic, so one bad packet does not crash the server. List logging and metrics before it to log
+// and count panics as errors.
+func RecoverMiddleware() Middleware {
+	return func(pkt *{{.Wrapper}}, handler PacketHandler, next func() error) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %s: %v\n%s", ErrHandlerPanic, PayloadName(pkt), r, debug.Stack())
+			}
+		}()
+		return next()
+	}
+}
+{{- if not .Security }}
+
+// ErrRateLimited is returned by RateLimitMiddleware for a packet above its payload's rate limit
+var ErrRateLimited = errors.New("rate limited")
+
+// Authenticator is implemented by handlers of sessions that log in. Payloads requiring
+// authentication are rejected until Authenticated returns true; sessions whose handler does not
+// implement it are never authenticated.
+type Authenticator interface {
+	Authenticated() bool
+}
+{{- end }}
+
+// authPayloads are the payloads AuthMiddleware requires an authenticated session for
+var authPayloads = map[string]bool{
+{{- range .Rules }}
+{{- if .Auth }}
+	{{printf "%q" .Name}}: true,
+{{- end }}
+{{- end }}
+}
+
+// AuthMiddleware rejects the payloads requiring an authenticated session, from option
+// (socketgen.requires_auth) and middleware.require_auth of socketgen.yaml, while the handler is
+// not an Authenticator reporting the session as authenticated
+func AuthMiddleware() Middleware {
+	return func(pkt *{{.Wrapper}}, handler PacketHandler, next func() error) error {
+		if name := PayloadName(pkt); authPayloads[name] {
+			if a, ok := handler.(Authenticator); !ok || !a.Authenticated() {
+				return fmt.Errorf("%w: %s", ErrUnauthenticated, name)
+			}
+		}
+		return next()
+	}
+}
+
+// payloadRateLimits are the packets per second and burst RateLimitMiddleware lets a session send
+// of every rate limited payload
+var payloadRateLimits = map[string]struct{ rate, burst float64 }{
+{{- range .Rules }}
+{{- if ne .Rate "0" }}
+	{{printf "%q" .Name}}: { {{- .Rate}}, {{.Burst -}} },
+{{- end }}
+{{- end }}
+}
+
+// RateLimitMiddleware rejects the packets of a session above the rate of their payload, from
+// option (socketgen.rate_limit) and middleware.rate_limit of socketgen.yaml. Create one per
+// session, as it counts the packets of the session.
+func RateLimitMiddleware() Middleware {
+	var mu sync.Mutex
+	buckets := map[string]*guardBucket{}
+	return func(pkt *{{.Wrapper}}, handler PacketHandler, next func() error) error {
+		name := PayloadName(pkt)
+		if limit, ok := payloadRateLimits[name]; ok {
+			mu.Lock()
+			bucket, ok := buckets[name]
+			if !ok {
+				bucket = &guardBucket{tokens: limit.burst, last: time.Now()}
+				buckets[name] = bucket
+			}
+			allowed := bucket.take(limit.rate, limit.burst)
+			mu.Unlock()
+			if !allowed {
+				return fmt.Errorf("%w: %s", ErrRateLimited, name)
+			}
+		}
+		return next()
+	}
+}
+{{- if not .Security }}
+
+// guardBucket is a token bucket refilled at a payload's rate limit
+type guardBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *guardBucket) take(rate, burst float64) bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+{{- end }}
+
+// DefaultHandlerMetrics are the metrics the metrics preset counts in; serve them to scrapers, e.g.
+//
+//	http.Handle("/metrics/handlers", DefaultHandlerMetrics)
+var DefaultHandlerMetrics = NewHandlerMetrics()
+
+// HandlerMetrics counts the packets, errors and handling time of every payload, and exposes
+// them in the OpenMetrics text format
+type HandlerMetrics struct {
+	mu     sync.Mutex
+	series map[string]*handlerSeries
+}
+
+type handlerSeries struct {
+	packets float64
+	errors  float64
+	seconds float64
+}
+
+// NewHandlerMetrics creates empty metrics
+func NewHandlerMetrics() *HandlerMetrics {
+	return &HandlerMetrics{series: map[string]*handlerSeries{}}
+}
+
+func (m *HandlerMetrics) observe(payload string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.series[payload]
+	if s == nil {
+		s = &handlerSeries{}
+		m.series[payload] = s
+	}
+	s.packets++
+	if err != nil {
+		s.errors++
+	}
+	s.seconds += d.Seconds()
+}
+
+// WriteTo writes the metrics in the OpenMetrics text format
+func (m *HandlerMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	payloads := make([]string, 0, len(m.series))
+	series := make(map[string]handlerSeries, len(m.series))
+	for payload, s := range m.series {
+		payloads = append(payloads, payload)
+		series[payload] = *s
+	}
+	m.mu.Unlock()
+	sort.Strings(payloads)
+
+	var b strings.Builder
+	for _, metric := range []struct {
+		name, help string
+		value      func(s handlerSeries) float64
+	}{
+		{"socketgen_handler_packets", "Packets dispatched by payload.", func(s handlerSeries) float64 { return s.packets }},
+		{"socketgen_handler_errors", "Packets whose dispatch failed by payload.", func(s handlerSeries) float64 { return s.errors }},
+		{"socketgen_handler_seconds", "Time spent dispatching packets by payload.", func(s handlerSeries) float64 { return s.seconds }},
+	} {
+		b.WriteString("# TYPE " + metric.name + " counter\n# HELP " + metric.name + " " + metric.help + "\n")
+		for _, payload := range payloads {
+			b.WriteString(metric.name + "_total{payload=\"" + payload + "\"} " + strconv.FormatFloat(metric.value(series[payload]), 'g', -1, 64) + "\n")
+		}
+	}
+	b.WriteString("# EOF\n")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics to scrapers
+func (m *HandlerMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	m.WriteTo(w)
+}
+`
+
+// GenerateMiddleware writes packet_middleware.go, with the middleware presets enabled by
+// middleware, which the transports serve every connection with
+func GenerateMiddleware(result *parser.ParseResult, middleware *config.Middleware, security bool, outDir string) error {
+	// The auth gate and the rate limits follow the rules of the security guard
+	rules := make([]goGuardRule, 0, len(result.Payloads))
+	for _, p := range result.Payloads {
+		auth, rate := GuardRule(p, &config.Security{RequireAuth: middleware.RequireAuth, RateLimit: middleware.RateLimit})
+		burst := float64(middleware.Burst)
+		if burst == 0 {
+			burst = max(1, math.Ceil(2*rate))
+		}
+		rules = append(rules, goGuardRule{
+			Name:   p.FieldName,
+			Number: p.Number,
+			Auth:   auth,
+			Rate:   strconv.FormatFloat(rate, 'g', -1, 64),
+			Burst:  strconv.FormatFloat(burst, 'g', -1, 64),
+		})
+	}
+
+	level := "slog.LevelInfo"
+	if middleware.LogLevel == "debug" {
+		level = "slog.LevelDebug"
+	}
+	data := struct {
+		*parser.ParseResult
+		Presets  []string
+		LogLevel string
+		Security bool
+		Rules    []goGuardRule
+	}{result, middleware.Presets, level, security, rules}
+	return writeTemplate(outDir, "packet_middleware.go", "go_middleware", goMiddlewareTemplate, nil, data)
+}
@@ -0,0 +1,30 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateMiddlewareWithGatewayBuilds(t *testing.T) {
+	for _, security := range []bool{false, true} {
+		pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+			if security {
+				hardened := config.HardenedSecurity()
+				if err := GenerateSecurity(result, &hardened, dir); err != nil {
+					return err
+				}
+				if err := GenerateRuntimeConfig(result, dir); err != nil {
+					return err
+				}
+			}
+			middleware := &config.Middleware{Presets: []string{"auth", "rate_limit"}}
+			if err := GenerateMiddleware(result, middleware, security, dir); err != nil {
+				return err
+			}
+			return GenerateGateway(result, nil, dir)
+		})
+		goCommand(t, "vet", pkg)
+	}
+}
@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"strconv"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goNormalizeTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+{{- if .UsesProto }}
+
+import "google.golang.org/protobuf/proto"
+{{- end }}
+{{- range .Payloads }}
+{{- if .Normalized }}
+
+// Normalize{{.Name}} applies the defaults and clamp ranges of {{.Name}}'s fields, from options
+// (socketgen.default_value) and (socketgen.clamp). Dispatch calls it before the handler.
+func Normalize{{.Name}}(msg *{{.Name}}) {
+	if msg == nil {
+		return
+	}
+{{- range .Fields }}
+{{- $field := goFieldName .Name }}
+{{- if .Default }}
+{{- if .Optional }}
+	if msg.{{$field}} == nil {
+		msg.{{$field}} = proto.{{protoHelper .Kind}}({{defaultLiteral .}})
+	}
+{{- else }}
+	if {{if eq .Kind "bool"}}!msg.{{$field}}{{else}}msg.{{$field}} == {{if eq .Kind "string"}}""{{else}}0{{end}}{{end}} {
+		msg.{{$field}} = {{defaultLiteral .}}
+	}
+{{- end }}
+{{- end }}
+{{- if .Clamp }}
+{{- if .Repeated }}
+	for i := range msg.{{$field}} {
+		msg.{{$field}}[i] = {{clampExpr . (printf "msg.%s[i]" $field)}}
+	}
+{{- else if and .Optional (not .Default) }}
+	if msg.{{$field}} != nil {
+		*msg.{{$field}} = {{clampExpr . (printf "*msg.%s" $field)}}
+	}
+{{- else if .Optional }}
+	*msg.{{$field}} = {{clampExpr . (printf "*msg.%s" $field)}}
+{{- else }}
+	msg.{{$field}} = {{clampExpr . (printf "msg.%s" $field)}}
+{{- end }}
+{{- end }}
+{{- end }}
+}
+{{- end }}
+{{- end }}
+{{- if .ClampsFloats }}
+
+// notNaN replaces NaN, which compares outside of every clamp range, with 0
+func notNaN[T float32 | float64](v T) T {
+	if v != v {
+		return 0
+	}
+	return v
+}
+{{- end }}
+`
+
+// generateGoNormalize writes packet_normalize.go, applying the field defaults and clamp ranges
+// of payloads before dispatch
+func generateGoNormalize(result *parser.ParseResult, outDir string) error {
+	usesProto, clampsFloats := false, false
+	for _, p := range result.Payloads {
+		for _, f := range p.Fields {
+			usesProto = usesProto || f.Default != "" && f.Optional
+			clampsFloats = clampsFloats || f.Clamp != "" && (f.Kind == "float" || f.Kind == "double")
+		}
+	}
+
+	funcMap := template.FuncMap{
+		"goFieldName":    goFieldName,
+		"protoHelper":    goProtoHelper,
+		"defaultLiteral": goDefaultLiteral,
+		"clampExpr":      goClampExpr,
+	}
+	data := struct {
+		*parser.ParseResult
+		UsesProto    bool
+		ClampsFloats bool
+	}{result, usesProto, clampsFloats}
+	return writeTemplate(outDir, "packet_normalize.go", "go_normalize", goNormalizeTemplate, funcMap, data)
+}
+
+// goProtoHelper returns the proto package function that allocates a value of a scalar kind
+func goProtoHelper(kind string) string {
+	switch kind {
+	case "int32", "sint32", "sfixed32":
+		return "Int32"
+	case "int64", "sint64", "sfixed64":
+		return "Int64"
+	case "uint32", "fixed32":
+		return "Uint32"
+	case "uint64", "fixed64":
+		return "Uint64"
+	case "float":
+		return "Float32"
+	case "double":
+		return "Float64"
+	case "bool":
+		return "Bool"
+	}
+	return "String"
+}
+
+// goDefaultLiteral returns the Go literal of a field's default value; the parser has checked
+// that numbers and bools are written as Go would
+func goDefaultLiteral(f parser.MessageField) string {
+	if f.Kind == "string" {
+		return strconv.Quote(f.Default)
+	}
+	return f.Default
+}
+
+// goClampExpr returns the expression clamping value to the range of f
+func goClampExpr(f parser.MessageField, value string) string {
+	minimum, maximum, _ := f.ClampBounds()
+	if f.Kind == "float" || f.Kind == "double" {
+		value = "notNaN(" + value + ")"
+	}
+	if minimum != "" {
+		value = "max(" + value + ", " + minimum + ")"
+	}
+	if maximum != "" {
+		value = "min(" + value + ", " + maximum + ")"
+	}
+	return value
+}
@@ -0,0 +1,42 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goPeekTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// peekPayload returns the payload field name of an encoded packet without decoding it, so
+// sampling and counting packets cost next to nothing
+func peekPayload(data []byte) string {
+	payload := ""
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return payload
+		}
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return payload
+		}
+		data = data[n:]
+
+		// The last payload field wins, as in proto.Unmarshal
+		switch num {
+{{- range .Payloads }}
+		case {{.Number}}:
+			payload = "{{.FieldName}}"
+{{- end }}
+		}
+	}
+	return payload
+}
+`
+
+// generateGoPeek writes packet_peek.go, which reads the payload of an encoded packet for the
+// tap stream, the bandwidth counters and the throttle
+func generateGoPeek(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_peek.go", "go_peek", goPeekTemplate, nil, result)
+}
@@ -0,0 +1,201 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// A rolling deployment serves clients of the last release for weeks. Rather than generating a
+// second set of types, the previous schema is embedded as a descriptor set, and packets of
+// sessions on it are converted to and from the current schema by field name.
+
+const goPreviousTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// PreviousSchemaVersion is the fingerprint of the previous schema, which this build keeps
+// serving next to SchemaVersion
+const PreviousSchemaVersion = "{{.PreviousVersion}}"
+
+// PreviousSchemaSubprotocol is the WebSocket subprotocol of clients built with the previous schema
+const PreviousSchemaSubprotocol = "socketgen." + PreviousSchemaVersion
+
+// PreviousSchemaDescriptorSet is the serialized FileDescriptorSet of the previous schema
+//
+//go:embed packet_previous.pb
+var PreviousSchemaDescriptorSet string
+
+// ErrNotInPreviousSchema is returned when a packet is sent to a session on the previous schema
+// whose payload did not exist yet
+var ErrNotInPreviousSchema = errors.New("payload not in the previous schema")
+
+// NegotiateSchemaVersion picks the schema of a session from the version its peer advertised. It
+// reports whether the session is on the previous schema, and fails with ErrSchemaMismatch for
+// any other version. An empty version means the current schema.
+func NegotiateSchemaVersion(advertised string) (previous bool, err error) {
+	switch advertised {
+	case "", SchemaVersion:
+		return false, nil
+	case PreviousSchemaVersion:
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: peer has %s, this build has %s or %s", ErrSchemaMismatch, advertised, SchemaVersion, PreviousSchemaVersion)
+}
+
+var previousPacket = sync.OnceValues(func() (protoreflect.MessageDescriptor, error) {
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal([]byte(PreviousSchemaDescriptorSet), &fds); err != nil {
+		return nil, fmt.Errorf("failed to decode the previous descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&fds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the previous schema: %w", err)
+	}
+	desc, err := files.FindDescriptorByName("{{.PreviousPacket}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the previous schema: %w", err)
+	}
+	return desc.(protoreflect.MessageDescriptor), nil
+})
+
+// PreviousStream carries the packets of a session on the previous schema, converting them to
+// and from the current schema by field name, so handlers only ever see current packets. Fields
+// and payloads that were renumbered keep working; those renamed or retyped since are dropped.
+type PreviousStream struct {
+	stream PacketStream
+}
+
+// NewPreviousStream wraps the stream of a session on the previous schema
+func NewPreviousStream(stream PacketStream) *PreviousStream {
+	return &PreviousStream{stream: stream}
+}
+
+func (s *PreviousStream) ReadPacket() ([]byte, error) {
+	data, err := s.stream.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := previousPacket()
+	if err != nil {
+		return nil, err
+	}
+
+	old := dynamicpb.NewMessage(desc)
+	if proto.Unmarshal(data, old) != nil {
+		return data, nil // Dispatch reports the malformed packet
+	}
+	pkt := &{{.Wrapper}}{}
+	convertFields(old, pkt.ProtoReflect())
+	return proto.Marshal(pkt)
+}
+
+func (s *PreviousStream) WritePacket(data []byte) error {
+	desc, err := previousPacket()
+	if err != nil {
+		return err
+	}
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+
+	old := dynamicpb.NewMessage(desc)
+	convertFields(pkt.ProtoReflect(), old)
+	if pkt.Payload != nil && old.WhichOneof(desc.Oneofs().ByName("payload")) == nil {
+		return fmt.Errorf("%w: %s", ErrNotInPreviousSchema, PayloadName(pkt))
+	}
+	out, err := proto.Marshal(old)
+	if err != nil {
+		return err
+	}
+	return s.stream.WritePacket(out)
+}
+
+// convertFields copies the fields set on src to the fields of dst with the same name and kind,
+// converting nested messages the same way
+func convertFields(src, dst protoreflect.Message) {
+	fields := dst.Descriptor().Fields()
+	src.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		target := fields.ByName(fd.Name())
+		if target == nil || target.Kind() != fd.Kind() || target.IsList() != fd.IsList() || target.IsMap() != fd.IsMap() {
+			return true
+		}
+
+		switch {
+		case fd.IsList():
+			list := dst.Mutable(target).List()
+			for i := 0; i < v.List().Len(); i++ {
+				list.Append(convertValue(v.List().Get(i), fd, list.NewElement))
+			}
+		case fd.IsMap():
+			if target.MapKey().Kind() != fd.MapKey().Kind() || target.MapValue().Kind() != fd.MapValue().Kind() {
+				return true
+			}
+			m := dst.Mutable(target).Map()
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				m.Set(k, convertValue(mv, fd.MapValue(), m.NewValue))
+				return true
+			})
+		case fd.Message() != nil:
+			convertFields(v.Message(), dst.Mutable(target).Message())
+		default:
+			dst.Set(target, v)
+		}
+		return true
+	})
+}
+
+// convertValue converts a list element or map value; newValue creates an empty message of the
+// destination type
+func convertValue(v protoreflect.Value, fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value) protoreflect.Value {
+	if fd.Message() == nil {
+		return v
+	}
+	out := newValue()
+	convertFields(v.Message(), out.Message())
+	return out
+}
+`
+
+// GeneratePrevious writes packet_previous.go and the descriptor set it embeds,
+// packet_previous.pb, so the Go server keeps serving clients of the schema in descriptorSet
+// (e.g. the packet_descriptor.pb of the last release)
+func GeneratePrevious(result *parser.ParseResult, descriptorSet string, outDir string) error {
+	previous, err := parser.LoadSchemaFromDescriptorSet(descriptorSet)
+	if err != nil {
+		return fmt.Errorf("failed to load the previous schema: %w", err)
+	}
+	if previous.Version == result.SchemaVersion {
+		return fmt.Errorf("%s is the current schema", descriptorSet)
+	}
+
+	data, err := os.ReadFile(descriptorSet)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "packet_previous.pb"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write the previous descriptor set: %w", err)
+	}
+
+	tmplData := struct {
+		*parser.ParseResult
+		PreviousVersion string
+		PreviousPacket  string
+	}{result, previous.Version, string(previous.Packet.FullName())}
+	return writeTemplate(outDir, "packet_previous.go", "go_previous", goPreviousTemplate, nil, tmplData)
+}
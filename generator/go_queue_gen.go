@@ -0,0 +1,186 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goQueueTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrQueueFull is returned by DispatchQueue.Push when the queue is at capacity
+var ErrQueueFull = errors.New("dispatch queue full")
+
+// ErrQueueClosed is returned by DispatchQueue.Push after Close
+var ErrQueueClosed = errors.New("dispatch queue closed")
+
+// PayloadPriorities maps payload field names to their dispatch priority (socketgen.priority).
+// Payloads without the option have priority 0.
+var PayloadPriorities = map[string]int32{
+{{- range .Payloads }}
+{{- if .Priority }}
+	"{{.FieldName}}": {{.Priority}},
+{{- end }}
+{{- end }}
+}
+
+// PacketPriority returns the dispatch priority of pkt's payload
+func PacketPriority(pkt *{{.Wrapper}}) int32 {
+	switch pkt.Payload.(type) {
+{{- range .Payloads }}
+{{- if .Priority }}
+	case *{{$.Wrapper}}_{{.Name}}:
+		return {{.Priority}}
+{{- end }}
+{{- end }}
+	}
+	return 0
+}
+
+type queuedPacket struct {
+	pkt      *{{.Wrapper}}
+	priority int32
+	seq      uint64
+	done     func() // Called after dispatch, may be nil
+}
+
+// packetHeap orders packets by priority, then by arrival
+type packetHeap []queuedPacket
+
+func (h packetHeap) Len() int { return len(h) }
+func (h packetHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h packetHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *packetHeap) Push(x any)   { *h = append(*h, x.(queuedPacket)) }
+func (h *packetHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// DispatchQueue buffers incoming packets and dispatches them by priority, so high-priority
+// payloads (e.g., input commands) are handled before low-priority ones (e.g., chat) whenever
+// the handler falls behind. Packets of equal priority keep their arrival order.
+type DispatchQueue struct {
+	handler  PacketHandler
+	capacity int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	packets packetHeap
+	seq     uint64
+	closed  bool
+}
+
+// NewDispatchQueue returns a queue dispatching to handler. capacity bounds the number of
+// waiting packets; 0 means unbounded.
+func NewDispatchQueue(handler PacketHandler, capacity int) *DispatchQueue {
+	q := &DispatchQueue{handler: handler, capacity: capacity}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Push decodes data and queues it for dispatch
+func (q *DispatchQueue) Push(data []byte) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	return q.PushPacket(pkt)
+}
+
+// PushPacket queues an already decoded packet for dispatch
+func (q *DispatchQueue) PushPacket(pkt *{{.Wrapper}}) error {
+	return q.push(pkt, nil)
+}
+
+// push queues pkt and calls done once it is dispatched
+func (q *DispatchQueue) push(pkt *{{.Wrapper}}, done func()) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if q.capacity > 0 && len(q.packets) >= q.capacity {
+		return ErrQueueFull
+	}
+
+	q.seq++
+	heap.Push(&q.packets, queuedPacket{pkt: pkt, priority: PacketPriority(pkt), seq: q.seq, done: done})
+	q.cond.Signal()
+	return nil
+}
+
+// Len returns the number of packets waiting for dispatch
+func (q *DispatchQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.packets)
+}
+
+// Run dispatches queued packets until the queue is closed and drained. Dispatch errors are
+// passed to onError, which may be nil. Run may be called from several goroutines to form a worker pool.
+func (q *DispatchQueue) Run(onError func(error)) {
+	for {
+		q.mu.Lock()
+		for len(q.packets) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.packets) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		item := heap.Pop(&q.packets).(queuedPacket)
+		q.mu.Unlock()
+
+		if err := DispatchPacket(item.pkt, q.handler); err != nil && onError != nil {
+			onError(err)
+		}
+		if item.done != nil {
+			item.done()
+		}
+	}
+}
+
+// Close stops accepting packets. Run returns once the remaining packets are dispatched.
+func (q *DispatchQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// ServeQueued reads packets from stream into q until the stream fails, then closes q.
+// Run q in separate goroutines to dispatch.
+func ServeQueued(stream PacketStream, q *DispatchQueue) error {
+	defer q.Close()
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		if err := q.Push(data); err != nil && !errors.Is(err, ErrQueueFull) {
+			return err
+		}
+	}
+}
+`
+
+// generateGoQueue writes packet_queue.go, a dispatch queue ordered by payload priority
+func generateGoQueue(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_queue.go", "go_queue", goQueueTemplate, nil, result)
+}
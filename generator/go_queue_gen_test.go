@@ -0,0 +1,25 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateGoWithoutPrioritiesBuilds(t *testing.T) {
+	schema := strings.Replace(testSchema, " priority=1", "", 1)
+	pkg := generateGoSchemaPackage(t, schema, func(result *parser.ParseResult, dir string) error {
+		// The dispatch queue orders by option (socketgen.priority), so it is only generated for schemas using it
+		if _, err := os.Stat(filepath.Join(dir, "packet_queue.go")); !errors.Is(err, fs.ErrNotExist) {
+			return errors.New("GenerateGo wrote packet_queue.go for a schema without priorities")
+		}
+		// ServeQueuedLimited feeds the dispatch queue, so the concurrency limits leave it out too
+		return GenerateConcurrency(result, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"errors"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goReplayWindowTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrStalePacket is reported for a packet whose Header.timestamp is older than the replay window
+	ErrStalePacket = errors.New("stale packet")
+	// ErrFuturePacket is reported for a packet whose Header.timestamp is ahead of the server's clock
+	ErrFuturePacket = errors.New("packet from the future")
+	// ErrMissingTimestamp is reported for a packet without a Header.timestamp
+	ErrMissingTimestamp = errors.New("packet has no timestamp")
+)
+
+// ReplayWindowConfig configures a ReplayWindowStream
+type ReplayWindowConfig struct {
+	Window time.Duration // How old a packet may be (default 30s)
+	Ahead  time.Duration // How far ahead of the server's clock a packet may be (default 2s)
+
+	// MaxSkew is the largest clock offset allowed for the session, which widens the window by as
+	// much: the offset is learned from the first packet and capped at MaxSkew (default 0, the
+	// clocks must agree)
+	MaxSkew time.Duration
+
+	// ClockSkew returns the session's clock offset (its clock minus the server's), e.g. measured
+	// by a time sync exchange, instead of learning it from the first packet. It is called for
+	// every packet and capped at MaxSkew.
+	ClockSkew func() time.Duration
+
+	Unit         time.Duration    // Unit of Header.timestamp (default time.Millisecond)
+	AllowMissing bool             // Accept packets without a timestamp
+	Now          func() time.Time // The server's clock (default time.Now)
+
+	// OnReject is called for every packet dropped, with the reason (ErrStalePacket,
+	// ErrFuturePacket or ErrMissingTimestamp). If nil, packets are dropped silently.
+	OnReject func(err error)
+}
+
+// ReplayWindowStream wraps the PacketStream of one session and drops packets whose
+// Header.timestamp falls outside a window around the server's clock, so packets captured on an
+// unauthenticated transport cannot be replayed later. A replay within the window is not
+// detected; wrap the stream in a DedupStream as well to drop repeated request IDs.
+type ReplayWindowStream struct {
+	stream PacketStream
+	config ReplayWindowConfig
+
+	mu      sync.Mutex
+	skew    time.Duration
+	learned bool
+}
+
+// NewReplayWindowStream wraps stream with a replay window
+func NewReplayWindowStream(stream PacketStream, config ReplayWindowConfig) *ReplayWindowStream {
+	if config.Window <= 0 {
+		config.Window = 30 * time.Second
+	}
+	if config.Ahead <= 0 {
+		config.Ahead = 2 * time.Second
+	}
+	if config.Unit <= 0 {
+		config.Unit = time.Millisecond
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &ReplayWindowStream{stream: stream, config: config}
+}
+
+func (s *ReplayWindowStream) ReadPacket() ([]byte, error) {
+	for {
+		data, err := s.stream.ReadPacket()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.check(data); err != nil {
+			if s.config.OnReject != nil {
+				s.config.OnReject(err)
+			}
+			continue
+		}
+		return data, nil
+	}
+}
+
+func (s *ReplayWindowStream) WritePacket(data []byte) error {
+	return s.stream.WritePacket(data)
+}
+
+// Skew returns the clock offset of the session in use, after capping at MaxSkew
+func (s *ReplayWindowStream) Skew() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.ClockSkew != nil {
+		return s.capSkew(s.config.ClockSkew())
+	}
+	return s.skew
+}
+
+// check returns why the packet data must be dropped, or nil
+func (s *ReplayWindowStream) check(data []byte) error {
+	ts := timestampOf(data)
+	if ts == 0 {
+		if s.config.AllowMissing {
+			return nil
+		}
+		return ErrMissingTimestamp
+	}
+
+	now := s.config.Now()
+	sent := time.Unix(0, 0).Add(time.Duration(ts) * s.config.Unit)
+
+	s.mu.Lock()
+	var skew time.Duration
+	switch {
+	case s.config.ClockSkew != nil:
+		skew = s.capSkew(s.config.ClockSkew())
+	case !s.learned:
+		s.skew, s.learned = s.capSkew(sent.Sub(now)), true
+		skew = s.skew
+	default:
+		skew = s.skew
+	}
+	s.mu.Unlock()
+
+	// The packet's time on the server's clock
+	at := sent.Add(-skew)
+	if age := now.Sub(at); age > s.config.Window {
+		return fmt.Errorf("%w: sent %s ago, the window is %s", ErrStalePacket, age.Round(time.Millisecond), s.config.Window)
+	}
+	if ahead := at.Sub(now); ahead > s.config.Ahead {
+		return fmt.Errorf("%w: sent %s ahead", ErrFuturePacket, ahead.Round(time.Millisecond))
+	}
+	return nil
+}
+
+func (s *ReplayWindowStream) capSkew(skew time.Duration) time.Duration {
+	return max(-s.config.MaxSkew, min(skew, s.config.MaxSkew))
+}
+
+// timestampOf returns the Header.timestamp of an encoded packet, or 0 if it has none or cannot be decoded
+func timestampOf(data []byte) int64 {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return 0
+	}
+	return pkt.GetHeader().GetTimestamp()
+}
+`
+
+// GenerateReplayWindow writes packet_replay_window.go, a stream dropping packets whose header
+// timestamp is outside a window around the server's clock
+func GenerateReplayWindow(result *parser.ParseResult, outDir string) error {
+	if !hasHeaderField(result, "timestamp", "int64") {
+		return errors.New("the replay window needs an int64 timestamp field in Header")
+	}
+	return writeTemplate(outDir, "packet_replay_window.go", "go_replay_window", goReplayWindowTemplate, nil, result)
+}
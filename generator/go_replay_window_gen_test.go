@@ -0,0 +1,30 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateReplayWindowBuilds(t *testing.T) {
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		// Servers only get the replay window with --replay-window
+		if _, err := os.Stat(filepath.Join(dir, "packet_replay_window.go")); !errors.Is(err, fs.ErrNotExist) {
+			return errors.New("GenerateGo wrote packet_replay_window.go")
+		}
+		return GenerateReplayWindow(result, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
+
+func TestGenerateReplayWindowNeedsTimestamp(t *testing.T) {
+	result, dir := parseSchema(t, strings.Replace(testSchema, "  int64 timestamp = 4;\n", "", 1))
+	if err := GenerateReplayWindow(result, dir); err == nil {
+		t.Error("GenerateReplayWindow succeeded for a header without timestamp")
+	}
+}
@@ -0,0 +1,402 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goResumeTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrSessionDetached is returned by ResumableSession.ReadPacket while no connection is attached
+var ErrSessionDetached = errors.New("session detached")
+
+var errCannotResume = errors.New("missed packets are no longer buffered")
+
+// ResumeConfig controls how long and how much a ResumeStore keeps for disconnected sessions
+type ResumeConfig struct {
+	Window      time.Duration // How long a disconnected session can be resumed (default 30s)
+	MaxBuffered int           // Sent packets kept per session for replay (default 256)
+}
+
+// ResumeStore keeps sessions alive across brief network drops. On every connection the client
+// first sends ResumeReq with its token and the number of packets it has received; if the
+// session is still within the window, the store re-attaches it and replays what the client
+// missed. Otherwise a new session is started. Either way the server answers with ResumeRes.
+type ResumeStore struct {
+	config ResumeConfig
+
+	mu       sync.Mutex
+	sessions map[string]*ResumableSession
+}
+
+// NewResumeStore returns an empty store
+func NewResumeStore(config ResumeConfig) *ResumeStore {
+	if config.Window <= 0 {
+		config.Window = 30 * time.Second
+	}
+	if config.MaxBuffered <= 0 {
+		config.MaxBuffered = 256
+	}
+	return &ResumeStore{config: config, sessions: map[string]*ResumableSession{}}
+}
+
+// Accept runs the resume handshake on a new connection. It returns the session to serve and
+// whether an existing session was resumed. If the client does not start with ResumeReq, a new
+// session is created and its first packet is returned by the first ReadPacket.
+func (s *ResumeStore) Accept(stream PacketStream) (*ResumableSession, bool, error) {
+	return s.accept(stream, nil)
+}
+
+// accept is Accept, calling leave before a session is resumed, so the connection the client
+// left can be closed before a write still blocked on it holds up the resume
+func (s *ResumeStore) accept(stream PacketStream, leave func(*ResumableSession)) (*ResumableSession, bool, error) {
+	data, err := stream.ReadPacket()
+	if err != nil {
+		return nil, false, err
+	}
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return nil, false, err
+	}
+
+	req := pkt.GetResumeReq()
+	if req == nil {
+		s.sweep()
+		session, err := s.newSession(stream, pkt.Header, data)
+		return session, false, err
+	}
+
+	s.mu.Lock()
+	session := s.sessions[req.Token]
+	s.mu.Unlock()
+	if session != nil && leave != nil {
+		leave(session)
+	}
+
+	s.sweep()
+
+	if session != nil {
+		err := session.resume(stream, pkt.Header, req.LastSeq)
+		if err == nil {
+			return session, true, nil
+		}
+		if !errors.Is(err, errCannotResume) {
+			return nil, false, err
+		}
+		s.Remove(session)
+	}
+
+	session, err = s.newSession(stream, pkt.Header, nil)
+	return session, false, err
+}
+
+// Remove forgets a session, e.g. after logout or a kick, so it can no longer be resumed
+func (s *ResumeStore) Remove(session *ResumableSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, session.token)
+}
+
+func (s *ResumeStore) newSession(stream PacketStream, header *Header, pending []byte) (*ResumableSession, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	session := &ResumableSession{
+		store:    s,
+		token:    hex.EncodeToString(token),
+		stream:   stream,
+		pending:  pending,
+		firstSeq: 1,
+	}
+	if err := SendResumeRes(stream, header, &ResumeRes{Resumed: false, Token: session.token}); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.sessions[session.token] = session
+	s.mu.Unlock()
+	return session, nil
+}
+
+// sweep drops sessions that have been detached for longer than the window
+func (s *ResumeStore) sweep() {
+	deadline := time.Now().Add(-s.config.Window)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, session := range s.sessions {
+		if session.expired(deadline) {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// ResumableSession is a PacketStream that survives reconnects. Packets written while the client
+// is away are buffered and replayed when it resumes.
+type ResumableSession struct {
+	store *ResumeStore
+	token string
+
+	mu         sync.Mutex
+	stream     PacketStream // nil while detached
+	generation uint64       // Incremented on every resume, so reads on a replaced stream do not detach the new one
+	pending    []byte       // First packet of a session started without ResumeReq
+	buffer     [][]byte     // Recently sent packets; buffer[i] has sequence number firstSeq+i
+	firstSeq   uint64
+	detachedAt time.Time
+}
+
+// Token returns the resume token issued to the client
+func (s *ResumableSession) Token() string {
+	return s.token
+}
+
+func (s *ResumableSession) ReadPacket() ([]byte, error) {
+	s.mu.Lock()
+	if data := s.pending; data != nil {
+		s.pending = nil
+		s.mu.Unlock()
+		return data, nil
+	}
+	stream, generation := s.stream, s.generation
+	s.mu.Unlock()
+
+	if stream == nil {
+		return nil, ErrSessionDetached
+	}
+	data, err := stream.ReadPacket()
+	if err != nil {
+		s.mu.Lock()
+		if s.generation == generation {
+			s.detachLocked()
+		}
+		s.mu.Unlock()
+	}
+	return data, err
+}
+
+// WritePacket sends data and keeps it for replay. While the client is away, packets are only buffered.
+func (s *ResumableSession) WritePacket(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buffer = append(s.buffer, data)
+	if drop := len(s.buffer) - s.store.config.MaxBuffered; drop > 0 {
+		s.buffer = s.buffer[drop:]
+		s.firstSeq += uint64(drop)
+	}
+
+	if s.stream == nil {
+		return nil
+	}
+	if err := s.stream.WritePacket(data); err != nil {
+		// The packet stays buffered and is replayed if the client resumes in time
+		s.detachLocked()
+	}
+	return nil
+}
+
+func (s *ResumableSession) detachLocked() {
+	if s.stream != nil {
+		s.stream = nil
+		s.detachedAt = time.Now()
+	}
+}
+
+// attached reports whether a connection is attached
+func (s *ResumableSession) attached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream != nil
+}
+
+func (s *ResumableSession) expired(deadline time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream == nil && s.detachedAt.Before(deadline)
+}
+
+// resume attaches stream and replays the packets after lastSeq
+func (s *ResumableSession) resume(stream PacketStream, header *Header, lastSeq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := s.firstSeq + uint64(len(s.buffer)) - 1
+	if lastSeq > sent || lastSeq+1 < s.firstSeq {
+		return errCannotResume
+	}
+
+	s.stream = stream
+	s.generation++
+	s.pending = nil
+
+	if err := SendResumeRes(stream, header, &ResumeRes{Resumed: true, Token: s.token}); err != nil {
+		s.detachLocked()
+		return err
+	}
+	for _, data := range s.buffer[lastSeq+1-s.firstSeq:] {
+		if err := stream.WritePacket(data); err != nil {
+			s.detachLocked()
+			return err
+		}
+	}
+	return nil
+}
+
+// ResumeTracker is the client side of the resume protocol. Pass every received packet to
+// Track, and send ResumePacket first on every (re)connection.
+type ResumeTracker struct {
+	// OnNewSession is called when the server could not resume and started a new session,
+	// meaning server-side session state was lost and the client should log in again
+	OnNewSession func()
+
+	mu       sync.Mutex
+	token    string
+	received uint64
+}
+
+// Track records a received packet. It returns false for ResumeRes, which the tracker handles
+// and which does not need to be dispatched.
+func (t *ResumeTracker) Track(pkt *{{.Wrapper}}) bool {
+	res := pkt.GetResumeRes()
+
+	t.mu.Lock()
+	if res == nil {
+		t.received++
+		t.mu.Unlock()
+		return true
+	}
+	lost := !res.Resumed && t.token != ""
+	if !res.Resumed {
+		t.received = 0
+	}
+	t.token = res.Token
+	t.mu.Unlock()
+
+	if lost && t.OnNewSession != nil {
+		t.OnNewSession()
+	}
+	return false
+}
+
+// ResumePacket returns the ResumeReq to send first on a new connection
+func (t *ResumeTracker) ResumePacket(header *Header) ([]byte, error) {
+	t.mu.Lock()
+	req := &ResumeReq{Token: t.token, LastSeq: t.received}
+	t.mu.Unlock()
+
+	return proto.Marshal(&{{.Wrapper}}{
+		Header:  header,
+		Payload: &{{.Wrapper}}_ResumeReq{ResumeReq: req},
+	})
+}
+`
+
+const tsResumeTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+
+const { {{.Wrapper}} } = {{.PackageName}};
+type {{.Wrapper}} = {{.PackageName}}.{{.Wrapper}};
+type Header = {{.PackageName}}.Header;
+
+/**
+ * Client side of the resume protocol. Pass every received packet to track(), and send
+ * resumePacket() first on every (re)connection so brief network drops keep the session.
+ */
+export class ResumeTracker {
+  token = "";
+  received = 0;
+  /** Called when the server started a new session, meaning server-side state was lost */
+  onNewSession?: () => void;
+
+  /** Records a received packet. Returns false for ResumeRes, which needs no dispatch. */
+  track(pkt: {{.Wrapper}}): boolean {
+    const res = pkt.resumeRes;
+    if (!res) {
+      this.received++;
+      return true;
+    }
+    const lost = !res.resumed && this.token !== "";
+    if (!res.resumed) {
+      this.received = 0;
+    }
+    this.token = res.token;
+    if (lost) {
+      this.onNewSession?.();
+    }
+    return false;
+  }
+
+  /** The ResumeReq to send first on a new connection */
+  resumePacket(header: Header): Uint8Array {
+    const pkt = {{.Wrapper}}.fromPartial({
+      header: header,
+      resumeReq: { token: this.token, lastSeq: this.received },
+    });
+    return {{.Wrapper}}.encode(pkt).finish();
+  }
+}
+`
+
+// resumeFields lists the fields the resume protocol expects on its payloads
+var resumeFields = map[string]map[string]string{
+	"ResumeReq": {"token": "string", "last_seq": "uint64"},
+	"ResumeRes": {"resumed": "bool", "token": "string"},
+}
+
+// hasResume reports whether the schema declares the ResumeReq and ResumeRes payloads,
+// and checks that their fields match what the generated code expects
+func hasResume(result *parser.ParseResult) (bool, error) {
+	req, res := result.Payload("ResumeReq"), result.Payload("ResumeRes")
+	if req == nil && res == nil {
+		return false, nil
+	}
+	if req == nil || res == nil {
+		return false, fmt.Errorf("resume protocol needs both ResumeReq and ResumeRes payloads")
+	}
+
+	for _, p := range []*parser.PayloadMessage{req, res} {
+		for name, kind := range resumeFields[p.Name] {
+			found := false
+			for _, f := range p.Fields {
+				if f.Name == name && f.Kind == kind && !f.Repeated && !f.Map {
+					found = true
+				}
+			}
+			if !found {
+				return false, fmt.Errorf("%s must have a field `%s %s`", p.Name, kind, name)
+			}
+		}
+	}
+	return true, nil
+}
+
+// generateGoResume writes packet_resume.go when the schema declares the resume payloads
+func generateGoResume(result *parser.ParseResult, outDir string) error {
+	if ok, err := hasResume(result); !ok {
+		return err
+	}
+	return writeTemplate(outDir, "packet_resume.go", "go_resume", goResumeTemplate, nil, result)
+}
+
+// generateTSResume writes PacketResume.ts when the schema declares the resume payloads
+func generateTSResume(result *parser.ParseResult, outDir string) error {
+	if ok, err := hasResume(result); !ok {
+		return err
+	}
+	return writeTemplate(outDir, "PacketResume.ts", "ts_resume", tsResumeTemplate, nil, result)
+}
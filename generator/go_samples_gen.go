@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/sample"
+)
+
+const goSamplesTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+{{range .Samples}}
+// Sample{{.Name}} returns a new {{.Name}} holding realistic sample values, the same as the
+// golden test vectors, for tests and fixtures
+func Sample{{.Name}}() *{{.Name}} {
+	return sampleOf(&{{.Name}}{}, {{printf "%q" .JSON}})
+}
+{{end}}
+func sampleOf[T proto.Message](msg T, data string) T {
+	if err := protojson.Unmarshal([]byte(data), msg); err != nil {
+		panic("socketgen: invalid sample: " + err.Error())
+	}
+	return msg
+}
+`
+
+type goSample struct {
+	Name string
+	JSON string
+}
+
+// GenerateSamples writes packet_samples.go, with a Sample<Payload> function per payload building
+// the payload sample.Fill fills
+func GenerateSamples(result *parser.ParseResult, outDir string) error {
+	samples := make([]goSample, 0, len(result.Payloads))
+	for _, p := range result.Payloads {
+		field := result.Schema.PayloadByName(p.FieldName)
+		if field == nil {
+			return fmt.Errorf("payload %s is not in the schema", p.FieldName)
+		}
+		data, err := sample.JSON(field.Message())
+		if err != nil {
+			return fmt.Errorf("failed to build a sample of %s: %w", p.Name, err)
+		}
+		samples = append(samples, goSample{Name: p.Name, JSON: string(data)})
+	}
+
+	data := struct {
+		PackageName string
+		Samples     []goSample
+	}{result.PackageName, samples}
+	return writeTemplate(outDir, "packet_samples.go", "go_samples", goSamplesTemplate, nil, data)
+}
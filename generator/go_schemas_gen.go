@@ -0,0 +1,249 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The schemas of a project share the envelope of GamePacket: the same header type at the same
+// field, and payload field numbers no two schemas use. A packet of any schema is therefore routed
+// by the number of its payload field alone, without decoding it, and the schemas generate into one
+// package with the names of all but packet.proto prefixed, like the internal dispatcher.
+
+const goSchemaTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// {{.Prefix}}SchemaVersion is the fingerprint of {{.File}}, which changes apart from SchemaVersion
+const {{.Prefix}}SchemaVersion = "{{.SchemaVersion}}"
+
+// {{.Prefix}}Handler handles the payloads of the {{.Name}} schema, carried in {{.Wrapper}}s
+type {{.Prefix}}Handler interface {
+{{- range .Payloads }}
+{{- lineComment "\t// " .Doc }}
+	On{{.Name}}(header *{{$.HeaderType}}, msg *{{.Name}})
+{{- end }}
+}
+
+// Dispatch{{.Prefix}} decodes a {{.Wrapper}} and routes it to handler
+func Dispatch{{.Prefix}}(data []byte, handler {{.Prefix}}Handler) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	return Dispatch{{.Prefix}}Packet(pkt, handler)
+}
+
+// Dispatch{{.Prefix}}Packet routes an already decoded {{.Wrapper}} to handler
+func Dispatch{{.Prefix}}Packet(pkt *{{.Wrapper}}, handler {{.Prefix}}Handler) error {
+	switch payload := pkt.Payload.(type) {
+{{- range .Payloads }}
+	case *{{$.Wrapper}}_{{.Name}}:
+		handler.On{{.Name}}(pkt.Header, payload.{{.Name}})
+{{- end }}
+	default:
+		return fmt.Errorf("unknown {{.Name}} packet type")
+	}
+	return nil
+}
+{{- range .Payloads }}
+
+func Send{{.Name}}(stream PacketStream, header *{{$.HeaderType}}, msg *{{.Name}}) error {
+	pkt := &{{$.Wrapper}}{
+		Header: header,
+		Payload: &{{$.Wrapper}}_{{.Name}}{
+			{{.Name}}: msg,
+		},
+	}
+	data, err := proto.Marshal(pkt)
+	if err != nil {
+		return err
+	}
+	return stream.WritePacket(data)
+}
+{{- end }}
+`
+
+const goRouterTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrNoSchemaHandler is returned by Router.Route for a packet of a schema the router has no
+// handler for
+var ErrNoSchemaHandler = errors.New("no handler for the schema of the packet")
+
+// SchemaOf returns the schema of an encoded packet ({{range $i, $s := .Schemas}}{{if $i}}, {{end}}"{{$s.Name}}"{{end}}) from the field
+// number of its payload, or "" if no schema has its payload
+func SchemaOf(data []byte) string {
+	schema := ""
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return schema
+		}
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return schema
+		}
+		data = data[n:]
+
+		// The last payload field wins, as in proto.Unmarshal
+		switch num {
+{{- range .Schemas }}
+{{- if .Numbers }}
+		case {{.Numbers}}:
+			schema = "{{.Name}}"
+{{- end }}
+{{- end }}
+		}
+	}
+	return schema
+}
+
+// Router hands every packet to the dispatcher of its schema, so one connection carries the
+// payloads of every schema of the project. A schema without a handler is refused.
+type Router struct {
+{{- range .Schemas }}
+	{{.Field}} {{.Handler}}
+{{- end }}
+}
+
+// Route dispatches an encoded packet of any schema to the handler of its schema
+func (r *Router) Route(data []byte) error {
+	switch schema := SchemaOf(data); schema {
+{{- range .Schemas }}
+	case "{{.Name}}":
+		if r.{{.Prefix}} == nil {
+			return fmt.Errorf("%w: %s", ErrNoSchemaHandler, schema)
+		}
+		return {{.Dispatch}}(data, r.{{.Prefix}})
+{{- end }}
+	}
+	return fmt.Errorf("unknown packet type")
+}
+
+// Serve routes every packet read from stream until reading fails
+func (r *Router) Serve(stream PacketStream) error {
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		if err := r.Route(data); err != nil {
+			logDispatchError(err)
+		}
+	}
+}
+`
+
+// ProjectSchema is a schema of the project besides packet.proto, declared in socketgen.yaml
+type ProjectSchema struct {
+	Name   string // e.g., "social"
+	Prefix string // Prefix of the generated identifiers (e.g., "Social")
+	File   string // e.g., "social.proto"
+	*parser.ParseResult
+}
+
+// CheckSchemas reports the schemas that do not share the envelope of result: they must be in the
+// same proto package, have the header of GamePacket, and number and name their payloads apart
+// from those of every other schema.
+func CheckSchemas(result *parser.ParseResult, schemas []ProjectSchema) error {
+	header := result.Schema.Packet.Fields().ByName("header")
+	if header == nil || header.Message() == nil {
+		return fmt.Errorf("%s needs a header message for other schemas to share", result.Wrapper)
+	}
+
+	numbers := make(map[int32]string)
+	names := make(map[string]string)
+	claim := func(schema string, p parser.PayloadMessage) error {
+		if other, ok := numbers[p.Number]; ok {
+			return fmt.Errorf("payload %s of the %s schema is numbered %d, like a payload of the %s schema; the router tells the schemas apart by payload field number", p.FieldName, schema, p.Number, other)
+		}
+		if other, ok := names[p.Name]; ok && other != schema {
+			return fmt.Errorf("payload %s of the %s schema is also a payload of the %s schema", p.Name, schema, other)
+		}
+		numbers[p.Number], names[p.Name] = schema, schema
+		return nil
+	}
+	for _, p := range result.Payloads {
+		claim("game", p)
+	}
+
+	for _, s := range schemas {
+		if s.PackageName != result.PackageName {
+			return fmt.Errorf("%s is in proto package %s, but the schemas of a project share package %s", s.File, s.PackageName, result.PackageName)
+		}
+		h := s.Schema.Packet.Fields().ByName("header")
+		if h == nil || h.Message() == nil || h.Message().FullName() != header.Message().FullName() || h.Number() != header.Number() {
+			return fmt.Errorf("%s of %s must have the header of %s, `%s header = %d;`", s.Wrapper, s.File, result.Wrapper, result.HeaderType, header.Number())
+		}
+		for _, p := range s.Payloads {
+			if err := claim(s.Name, p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateSchemas writes a dispatcher per schema of the project besides packet.proto,
+// packet_schema_<name>.go, and packet_router.go, which routes the packets of every schema
+func GenerateSchemas(result *parser.ParseResult, schemas []ProjectSchema, outDir string) error {
+	if err := CheckSchemas(result, schemas); err != nil {
+		return err
+	}
+
+	type routedSchema struct {
+		Name, Prefix, Field, Handler, Dispatch string
+		Numbers                                string
+	}
+	routed := []routedSchema{{Name: "game", Prefix: "Game", Handler: "PacketHandler", Dispatch: "Dispatch", Numbers: payloadNumbers(result.Payloads)}}
+	for _, s := range schemas {
+		if err := writeTemplate(outDir, "packet_schema_"+s.Name+".go", "go_schema", goSchemaTemplate, template.FuncMap{"lineComment": lineComment}, s); err != nil {
+			return err
+		}
+		routed = append(routed, routedSchema{Name: s.Name, Prefix: s.Prefix, Handler: s.Prefix + "Handler", Dispatch: "Dispatch" + s.Prefix, Numbers: payloadNumbers(s.Payloads)})
+	}
+
+	// Align the fields of Router as gofmt would
+	width := 0
+	for _, s := range routed {
+		width = max(width, len(s.Prefix))
+	}
+	for i := range routed {
+		routed[i].Field = fmt.Sprintf("%-*s", width, routed[i].Prefix)
+	}
+
+	data := struct {
+		PackageName string
+		Schemas     []routedSchema
+	}{result.PackageName, routed}
+	return writeTemplate(outDir, "packet_router.go", "go_router", goRouterTemplate, nil, data)
+}
+
+// payloadNumbers lists the field numbers of payloads for a case clause (e.g., "10, 11, 12")
+func payloadNumbers(payloads []parser.PayloadMessage) string {
+	numbers := make([]string, len(payloads))
+	for i, p := range payloads {
+		numbers[i] = fmt.Sprint(p.Number)
+	}
+	return strings.Join(numbers, ", ")
+}
@@ -0,0 +1,253 @@
+package generator
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The guard checks the raw packet before Dispatch decodes it, so an unauthenticated or flooding
+// client costs the server a field scan rather than a decode.
+
+const goSecurityTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MaxPacketSize is the largest packet a client may send, from security.max_packet_size of socketgen.yaml
+const MaxPacketSize = {{.MaxPacketSize}}
+
+// AllowedOrigins are the origins browsers may connect from over WebSocket and Socket.IO,
+// besides the server's own, from security.allowed_origins of socketgen.yaml
+var AllowedOrigins = []string{ {{- range $i, $o := .AllowedOrigins}}{{if $i}}, {{end}}{{printf "%q" $o}}{{end -}} }
+
+var (
+	// ErrPacketTooLarge is returned by Guard.Check for a packet larger than MaxPacketSize
+	ErrPacketTooLarge = errors.New("packet too large")
+	// ErrRateLimited is returned by Guard.Check for a packet above its payload's rate limit
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// Authenticator is implemented by handlers of sessions that log in. Payloads requiring
+// authentication are rejected until Authenticated returns true; sessions whose handler does not
+// implement it are never authenticated.
+type Authenticator interface {
+	Authenticated() bool
+}
+
+// guardRule is what Guard enforces for a payload
+type guardRule struct {
+	name  string
+	auth  bool
+	rate  float64 // Packets per second, 0 if unlimited
+	burst float64
+}
+
+var guardRules = map[protowire.Number]guardRule{
+{{- range .Rules }}
+	{{.Number}}: { {{- printf "%q" .Name}}, {{.Auth}}, {{.Rate}}, {{.Burst}}},
+{{- end }}
+}
+
+// Guard enforces the packet size limit, rate limits and authentication of one session. Create
+// one per connection; ServeTransports does so for every transport. The Knobs of Runtime override
+// the size and rate limits while the server runs.
+type Guard struct {
+	mu      sync.Mutex
+	buckets map[protowire.Number]*guardBucket
+}
+
+func NewGuard() *Guard {
+	return &Guard{buckets: make(map[protowire.Number]*guardBucket)}
+}
+
+// Check returns an error if the session of handler may not send the encoded packet data
+func (g *Guard) Check(data []byte, handler any) error {
+	if len(data) > Runtime.maxPacketSize(MaxPacketSize) {
+		return fmt.Errorf("%w: %d bytes", ErrPacketTooLarge, len(data))
+	}
+
+	num := guardPayload(data)
+	rule, ok := guardRules[num]
+	if !ok {
+		// Dispatch rejects a packet without a known payload
+		return nil
+	}
+	if rule.auth {
+		if a, ok := handler.(Authenticator); !ok || !a.Authenticated() {
+			return fmt.Errorf("%w: %s", ErrUnauthenticated, rule.name)
+		}
+	}
+	if rate, burst := Runtime.rateLimit(rule.name, rule.rate, rule.burst); rate > 0 && !g.take(num, rate, burst) {
+		return fmt.Errorf("%w: %s", ErrRateLimited, rule.name)
+	}
+	return nil
+}
+
+func (g *Guard) take(num protowire.Number, rate, burst float64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	bucket, ok := g.buckets[num]
+	if !ok {
+		bucket = &guardBucket{tokens: burst, last: time.Now()}
+		g.buckets[num] = bucket
+	}
+	return bucket.take(rate, burst)
+}
+
+// guardPayload returns the field number of the payload set in an encoded {{.Wrapper}}, or 0
+func guardPayload(data []byte) protowire.Number {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return 0
+		}
+		data = data[n:]
+		if _, ok := guardRules[num]; ok && typ == protowire.BytesType {
+			return num
+		}
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return 0
+		}
+		data = data[n:]
+	}
+	return 0
+}
+
+// ServeGuarded is Serve with a Guard checking every packet before it is dispatched
+func ServeGuarded(stream PacketStream, handler PacketHandler) error {
+	guard := NewGuard()
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		if err := guard.Check(data, handler); err != nil {
+			logDispatchError(err)
+			continue
+		}
+		if err := Dispatch(data, handler); err != nil {
+			logDispatchError(err)
+			continue
+		}
+	}
+}
+
+// checkOrigin accepts requests and WebSocket handshakes from clients that send no Origin header,
+// which are not browsers, from the server's own host, and from AllowedOrigins
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return slices.ContainsFunc(AllowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), u.Scheme+"://"+u.Host)
+	})
+}
+
+// allowOrigin answers a cross-origin HTTP request from an origin checkOrigin accepts with the
+// CORS header allowing it, and any other with 403 Forbidden. It reports whether the request may proceed.
+func allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	if !checkOrigin(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+	return true
+}
+
+// guardBucket is a token bucket refilled at a payload's rate limit
+type guardBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *guardBucket) take(rate, burst float64) bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+`
+
+type goGuardRule struct {
+	Name   string
+	Number int32
+	Auth   bool
+	Rate   string
+	Burst  string
+}
+
+// GuardRule returns whether a payload requires an authenticated session and its rate limit in
+// packets per second (0 if unlimited) under security
+func GuardRule(p parser.PayloadMessage, security *config.Security) (auth bool, rate float64) {
+	auth = p.RequiresAuth || (security.RequireAuth && !p.AuthExempt)
+	rate = p.RateLimit
+	if rate == 0 {
+		rate = security.RateLimit
+	}
+	return auth, rate
+}
+
+// GenerateSecurity writes packet_security.go, with the Guard enforcing security and the payload
+// options (socketgen.requires_auth) and (socketgen.rate_limit)
+func GenerateSecurity(result *parser.ParseResult, security *config.Security, outDir string) error {
+	rules := make([]goGuardRule, 0, len(result.Payloads))
+	for _, p := range result.Payloads {
+		auth, rate := GuardRule(p, security)
+		burst := float64(security.Burst)
+		if burst == 0 {
+			burst = max(1, math.Ceil(2*rate))
+		}
+		rules = append(rules, goGuardRule{
+			Name:   p.FieldName,
+			Number: p.Number,
+			Auth:   auth,
+			Rate:   strconv.FormatFloat(rate, 'g', -1, 64),
+			Burst:  strconv.FormatFloat(burst, 'g', -1, 64),
+		})
+	}
+
+	data := struct {
+		*parser.ParseResult
+		*config.Security
+		Rules []goGuardRule
+	}{result, security, rules}
+	return writeTemplate(outDir, "packet_security.go", "go_security", goSecurityTemplate, nil, data)
+}
@@ -0,0 +1,22 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateSecurityWithGatewayBuilds(t *testing.T) {
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		security := config.HardenedSecurity()
+		if err := GenerateSecurity(result, &security, dir); err != nil {
+			return err
+		}
+		if err := GenerateRuntimeConfig(result, dir); err != nil {
+			return err
+		}
+		return GenerateGateway(result, nil, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
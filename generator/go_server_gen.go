@@ -0,0 +1,973 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goServerTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+{{- if .Has.quic }}
+	"crypto/tls"
+{{- end }}
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+{{- if .Has.mqtt }}
+	"strings"
+{{- end }}
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServerConfig lists the listeners a Server starts, e.g.
+//
+//	{"listeners": [{{ range $i, $l := .Examples }}{{ if $i }}, {{ end }}"{{ $l }}"{{ end }}]}
+type ServerConfig struct {
+	Listeners []string          ` + "`json:\"listeners\"`" + `
+	Handshake *HandshakeLimits  ` + "`json:\"handshake\"`" + ` // Limits on clients that have not authenticated (optional)
+	Regions   map[string]string ` + "`json:\"regions\"`" + `   // Region of each client IP prefix, e.g. {"203.0.113.0/24": "ap-northeast"} (optional)
+	Health    string            ` + "`json:\"health\"`" + `    // Address serving /healthz and /readyz, e.g. ":8086" (optional)
+	KnobsFile string            ` + "`json:\"knobs\"`" + `     // JSON file of the Knobs, reloaded on SIGHUP (optional)
+	TickRate  float64           ` + "`json:\"tickRate\"`" + `  // Ticks per second packets are dispatched at, e.g. 20 (optional: as they arrive)
+{{- if .Has.quic }}
+	TLSCert   string            ` + "`json:\"tlsCert\"`" + `   // Certificate file, required by quic:// listeners
+	TLSKey    string            ` + "`json:\"tlsKey\"`" + `
+{{- end }}
+}
+
+var (
+	// ErrConnRateExceeded is reported for a connection from an address above HandshakeLimits.ConnRate
+	ErrConnRateExceeded = errors.New("connection rate exceeded")
+	// ErrTooManyUnauthenticated is reported for a connection while HandshakeLimits.MaxUnauthenticated
+	// clients have not authenticated
+	ErrTooManyUnauthenticated = errors.New("too many unauthenticated clients")
+)
+
+// HandshakeLimits throttle clients until they authenticate, so a flood of connections that never
+// log in cannot exhaust the server, e.g.
+//
+//	"handshake": {"connRate": 5, "connBurst": 20, "timeout": "10s", "maxUnauthenticated": 1000}
+//
+// A connection over a limit is closed with DisconnectThrottled as soon as it is accepted. Zero
+// values leave a limit off.
+type HandshakeLimits struct {
+	ConnRate           float64       // New connections per second accepted from one IP address
+	ConnBurst          int           // Connections one IP address may open at once (default twice ConnRate)
+	Timeout            time.Duration // How long a client may stay unauthenticated before DisconnectTimeout
+	MaxUnauthenticated int           // Clients that may be connected without having authenticated
+}
+
+// UnmarshalJSON reads the limits with the timeout as a duration string, e.g. "10s"
+func (l *HandshakeLimits) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ConnRate           float64 ` + "`json:\"connRate\"`" + `
+		ConnBurst          int     ` + "`json:\"connBurst\"`" + `
+		Timeout            string  ` + "`json:\"timeout\"`" + `
+		MaxUnauthenticated int     ` + "`json:\"maxUnauthenticated\"`" + `
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*l = HandshakeLimits{ConnRate: raw.ConnRate, ConnBurst: raw.ConnBurst, MaxUnauthenticated: raw.MaxUnauthenticated}
+	if raw.Timeout != "" {
+		timeout, err := time.ParseDuration(raw.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid handshake timeout: %w", err)
+		}
+		l.Timeout = timeout
+	}
+	return nil
+}
+
+// RegionResolver resolves the region of a connecting client from its address, e.g. with a GeoIP
+// database or a table of the load balancers' prefixes. It returns "" if the region is unknown.
+type RegionResolver interface {
+	Region(addr net.Addr) string
+}
+
+// RegionResolverFunc adapts a function to a RegionResolver
+type RegionResolverFunc func(addr net.Addr) string
+
+func (f RegionResolverFunc) Region(addr net.Addr) string {
+	return f(addr)
+}
+
+// PrefixRegions resolves the region of a client from the longest IP prefix containing its address
+type PrefixRegions map[netip.Prefix]string
+
+// ParsePrefixRegions parses a table of IP prefixes (e.g. "203.0.113.0/24") to regions, as in the
+// regions of a ServerConfig
+func ParsePrefixRegions(table map[string]string) (PrefixRegions, error) {
+	regions := make(PrefixRegions, len(table))
+	for prefix, region := range table {
+		p, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid region prefix: %w", err)
+		}
+		regions[p.Masked()] = region
+	}
+	return regions, nil
+}
+
+func (r PrefixRegions) Region(addr net.Addr) string {
+	ip, err := netip.ParseAddr(connHost(addr))
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	best, region := -1, ""
+	for p, name := range r {
+		if p.Bits() > best && p.Contains(ip) {
+			best, region = p.Bits(), name
+		}
+	}
+	return region
+}
+
+// LoadServerConfig reads a JSON server configuration
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server config: %w", err)
+	}
+	var cfg ServerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse server config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Listen starts every listener in the configuration. Supported schemes: {{ range $i, $s := .Schemes }}{{ if $i }}, {{ end }}{{ $s }}{{ end }}.
+func (cfg *ServerConfig) Listen() ([]Transport, error) {
+	var transports []Transport
+	for _, listener := range cfg.Listeners {
+		t, err := cfg.listen(listener)
+		if err != nil {
+			for _, started := range transports {
+				started.Close()
+			}
+			return nil, fmt.Errorf("listener %s: %w", listener, err)
+		}
+		transports = append(transports, t)
+	}
+	return transports, nil
+}
+
+func (cfg *ServerConfig) listen(listener string) (Transport, error) {
+	u, err := url.Parse(listener)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+{{- if .Has.ws }}
+	case "ws":
+		path := u.Path
+		if path == "" {
+			path = "/ws"
+		}
+		return ListenWebSocket(u.Host, path)
+{{- end }}
+{{- if .Has.tcp }}
+	case "tcp":
+		return ListenTCP(u.Host)
+{{- end }}
+{{- if .Has.kcp }}
+	case "udp", "kcp":
+		return ListenKCP(u.Host)
+{{- end }}
+{{- if .Has.quic }}
+	case "quic":
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		return ListenQUIC(u.Host, &tls.Config{Certificates: []tls.Certificate{cert}})
+{{- end }}
+{{- if .Has.mqtt }}
+	case "mqtt", "mqtts":
+		broker := "tcp://" + u.Host
+		if u.Scheme == "mqtts" {
+			broker = "ssl://" + u.Host
+		}
+		opts := MQTTOptions{Prefix: strings.Trim(u.Path, "/"), Username: u.User.Username()}
+		opts.Password, _ = u.User.Password()
+		if qos := u.Query().Get("qos"); qos != "" {
+			n, err := strconv.ParseUint(qos, 10, 8)
+			if err != nil || n > 2 {
+				return nil, fmt.Errorf("invalid MQTT QoS %q", qos)
+			}
+			opts.QoS = byte(n)
+		}
+		return ListenMQTT(broker, opts)
+{{- end }}
+{{- if .Has.grpcweb }}
+	case "grpcweb":
+		return ListenGRPCWeb(u.Host, u.Path)
+{{- end }}
+{{- if .Has.sse }}
+	case "sse":
+		return ListenSSE(u.Host, u.Path)
+{{- end }}
+{{- if .Has.socketio }}
+	case "socketio":
+		path := u.Path
+		if path == "" {
+			path = "/socket.io/"
+		}
+		return ListenSocketIO(u.Host, path)
+{{- end }}
+	}
+	return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+}
+
+// Client is one connected client, on any transport
+type Client struct {
+	ID string
+
+	mu          sync.Mutex
+	conn        TransportConn
+	closeOnce   sync.Once
+	closeReason DisconnectReason
+	done        chan struct{} // Closed when the client is disconnected
+	region      atomic.Value  // string
+{{- if .HasBroadcast }}
+	role        atomic.Int32
+{{- end }}
+
+	// unauthenticated is set while the client counts against HandshakeLimits.MaxUnauthenticated
+	unauthenticated atomic.Bool
+	handshake       *time.Timer
+
+	bandwidth BandwidthMeter
+	global    *BandwidthMeter // Of the Server
+{{- if .HasThrottle }}
+	throttle  sessionThrottle
+{{- end }}
+{{- if .Resume }}
+
+	// resume carries the packets of the client across connections, if the Server has a ResumeStore
+	resume   *ResumableSession
+	migrated chan struct{}
+{{- end }}
+}
+
+// Conn returns the connection of the client{{if .Resume}}, which changes when the client migrates{{end}}
+func (c *Client) Conn() TransportConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// stream returns the stream the packets of the client go through
+func (c *Client) stream() PacketStream {
+{{- if .Resume }}
+	if c.resume != nil {
+		return c.resume
+	}
+{{- end }}
+	return c.Conn()
+}
+
+// ReadPacket reads the next packet from the client's connection{{if .Resume}}. If the connection
+// is lost, it waits for the client to resume its session on a new connection, over any
+// transport, until the resume window closes.{{end}}
+func (c *Client) ReadPacket() ([]byte, error) {
+{{- if .Resume }}
+	for {
+		data, err := c.stream().ReadPacket()
+		if err == nil {
+			c.count(data, false)
+			return data, nil
+		}
+		if c.resume == nil || DisconnectReasonOf(err) == DisconnectProtocolError || !c.awaitMigration() {
+			return data, err
+		}
+	}
+{{- else }}
+	data, err := c.stream().ReadPacket()
+	if err == nil {
+		c.count(data, false)
+	}
+	return data, err
+{{- end }}
+}
+
+// WritePacket writes an encoded packet to the client's connection{{if .HasBroadcast}}. Read-only clients only
+// receive broadcast payloads; other packets are refused with ErrNotBroadcast.{{end}}{{if .HasThrottle}}
+// Throttled payloads the client is not due for are dropped with ErrThrottled.{{end}}
+func (c *Client) WritePacket(data []byte) error {
+{{- if .HasBroadcast }}
+	if c.SessionRole() == RoleSpectator && !isBroadcastPacket(data) {
+		return ErrNotBroadcast
+	}
+{{- end }}
+	return c.write(data)
+}
+
+// write writes an encoded packet to the client's connection and counts it
+func (c *Client) write(data []byte) error {
+{{- if .HasThrottle }}
+	if c.throttled(data) {
+		return ErrThrottled
+	}
+	c.throttle.backlog.Add(1)
+	defer c.throttle.backlog.Add(-1)
+{{- end }}
+	if err := c.stream().WritePacket(data); err != nil {
+		return err
+	}
+	c.count(data, true)
+	return nil
+}
+{{- if .HasBroadcast }}
+
+// SessionRole returns the role of the client; clients connect as RolePlayer
+func (c *Client) SessionRole() SessionRole {
+	return SessionRole(c.role.Load())
+}
+
+// SetSessionRole changes the role of the client, e.g. when it joins as a spectator. Packets from
+// a RoleSpectator client are rejected from then on.
+func (c *Client) SetSessionRole(role SessionRole) {
+	c.role.Store(int32(role))
+}
+{{- end }}
+
+// Region returns the region of the client, resolved when it connected by the Server's Regions,
+// or "" if it is unknown
+func (c *Client) Region() string {
+	region, _ := c.region.Load().(string)
+	return region
+}
+
+// SetRegion changes the region of the client, e.g. to the one it measured the lowest latency to
+func (c *Client) SetRegion(region string) {
+	c.region.Store(region)
+}
+
+// Disconnect closes the client's connection for reason, telling the client why if its transport
+// supports it. Only the first call has an effect.
+func (c *Client) Disconnect(reason DisconnectReason) {
+	c.close(reason)
+}
+
+// close closes the connection once and returns the reason it was closed for
+func (c *Client) close(reason DisconnectReason) DisconnectReason {
+	c.closeOnce.Do(func() {
+		c.closeReason = reason
+		closeWithReason(c.Conn(), reason)
+		close(c.done)
+	})
+	return c.closeReason
+}
+{{- if .Resume }}
+
+// awaitMigration waits for the client to resume its session on a new connection, and reports
+// whether it did before the resume window closed or the client was disconnected
+func (c *Client) awaitMigration() bool {
+	timer := time.NewTimer(c.resume.store.config.Window)
+	defer timer.Stop()
+	for !c.resume.attached() {
+		select {
+		case <-c.migrated:
+		case <-timer.C:
+			return false
+		case <-c.done:
+			return false
+		}
+	}
+	return true
+}
+
+// migrate moves the client to conn, on which its session was resumed, and closes the connection
+// it leaves
+func (c *Client) migrate(conn TransportConn) {
+	c.mu.Lock()
+	old := c.conn
+	c.conn = conn
+	c.mu.Unlock()
+
+	old.Close()
+	select {
+	case c.migrated <- struct{}{}:
+	default:
+	}
+	select {
+	case <-c.done:
+		// Disconnected meanwhile; the new connection is closed too
+		closeWithReason(conn, c.closeReason)
+	default:
+	}
+}
+{{- end }}
+
+// SessionManager tracks the connected clients of a Server across all transports
+type SessionManager struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+func (m *SessionManager) add(c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.clients == nil {
+		m.clients = map[string]*Client{}
+	}
+	m.clients[c.ID] = c
+}
+
+func (m *SessionManager) remove(c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.clients, c.ID)
+}
+
+// Get returns the client with the given ID, or nil if it is not connected
+func (m *SessionManager) Get(id string) *Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.clients[id]
+}
+
+// Count returns the number of connected clients
+func (m *SessionManager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.clients)
+}
+
+// InRegion returns the connected clients of region, e.g. to match players close to each other
+func (m *SessionManager) InRegion(region string) []*Client {
+	var clients []*Client
+	m.Range(func(c *Client) bool {
+		if c.Region() == region {
+			clients = append(clients, c)
+		}
+		return true
+	})
+	return clients
+}
+
+// Range calls fn for every connected client until fn returns false
+func (m *SessionManager) Range(fn func(c *Client) bool) {
+	m.mu.RLock()
+	clients := make([]*Client, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.RUnlock()
+
+	for _, c := range clients {
+		if !fn(c) {
+			return
+		}
+	}
+}
+
+// RoomManager groups clients into named rooms, regardless of the transport they use
+type RoomManager struct {
+	mu    sync.RWMutex
+	rooms map[string]map[string]*Client
+}
+
+// Join adds c to room
+func (m *RoomManager) Join(room string, c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rooms == nil {
+		m.rooms = map[string]map[string]*Client{}
+	}
+	if m.rooms[room] == nil {
+		m.rooms[room] = map[string]*Client{}
+	}
+	m.rooms[room][c.ID] = c
+}
+
+// Leave removes c from room; empty rooms are deleted
+func (m *RoomManager) Leave(room string, c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rooms[room], c.ID)
+	if len(m.rooms[room]) == 0 {
+		delete(m.rooms, room)
+	}
+}
+
+// LeaveAll removes c from every room
+func (m *RoomManager) LeaveAll(c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for room, members := range m.rooms {
+		delete(members, c.ID)
+		if len(members) == 0 {
+			delete(m.rooms, room)
+		}
+	}
+}
+
+// Members returns the clients in room
+func (m *RoomManager) Members(room string) []*Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	members := make([]*Client, 0, len(m.rooms[room]))
+	for _, c := range m.rooms[room] {
+		members = append(members, c)
+	}
+	return members
+}
+
+// Broadcast writes an encoded packet to every client in room except the given one (which may be nil).
+// Failed writes are skipped; the reader of that connection notices the failure and disconnects it.
+{{- if .HasBroadcast }}
+// Read-only clients are skipped unless the packet carries a broadcast payload.
+{{- end }}
+{{- if .HasThrottle }}
+// Clients are skipped for a throttled payload they are not due for.
+{{- end }}
+func (m *RoomManager) Broadcast(room string, data []byte, except *Client) {
+{{- if .HasBroadcast }}
+	toSpectators := sync.OnceValue(func() bool { return isBroadcastPacket(data) })
+{{- end }}
+	for _, c := range m.Members(room) {
+		if c == except {
+			continue
+		}
+{{- if .HasBroadcast }}
+		if c.SessionRole() == RoleSpectator && !toSpectators() {
+			continue
+		}
+{{- end }}
+		c.write(data)
+	}
+}
+
+// Server serves every configured listener with one dispatcher, and shares sessions and rooms
+// between them, so clients on different transports can play together
+type Server struct {
+	Sessions SessionManager
+	Rooms    RoomManager
+
+	// NewHandler returns the handler for a newly connected client
+	NewHandler func(c *Client) PacketHandler
+	// Lifecycle receives connect, authentication, and disconnect events (optional). OnDisconnect
+	// is called after the client left every room.
+	Lifecycle Lifecycle
+
+	// Handshake throttles clients until they authenticate with Authenticated. Run sets it from
+	// the configuration, if the configuration has limits.
+	Handshake HandshakeLimits
+	// OnRefused is called for every connection refused by the Handshake limits, with
+	// ErrConnRateExceeded or ErrTooManyUnauthenticated (optional)
+	OnRefused func(addr net.Addr, err error)
+	// Regions resolves the region of every client before OnConnect, for Client.Region (optional).
+	// Run sets it from the regions of the configuration, if it is nil.
+	Regions RegionResolver
+
+	// QueueDepth returns the packets waiting for dispatch, e.g. the Len of a DispatchQueue shared
+	// by the clients, for the health endpoints (optional)
+	QueueDepth func() int
+	// MaxQueueDepth is the queue depth above which the server reports it is not ready, so
+	// orchestration routes new clients elsewhere until it catches up (0: no limit)
+	MaxQueueDepth int
+	// Bandwidth counts the packets and bytes of all clients by payload; HealthHandler serves it
+	// at /metrics, and every Client counts its own
+	Bandwidth BandwidthMeter
+	// Tick dispatches the packets of all clients in batches, once per tick, instead of as they
+	// arrive (optional). Run sets it from the tick rate of the configuration, if it is nil.
+	Tick *TickLoop
+{{- if .HasThrottle }}
+	// Throttle decides how often clients get the payloads marked with option (socketgen.throttle);
+	// DefaultThrottle if nil. Clients keep the policy they connected with.
+	Throttle ThrottlePolicy
+{{- end }}
+{{- if .Resume }}
+
+	// Resume lets clients migrate their session to a new connection, over any transport, by
+	// sending ResumeReq first (optional). The client keeps its ID, rooms, role, region and handler,
+	// and packets sent while it was away are replayed.
+	Resume *ResumeStore
+{{- end }}
+
+	nextID          atomic.Uint64
+	mu              sync.Mutex
+	transports      []Transport
+	shutdown        atomic.Bool
+	unauthenticated atomic.Int64
+	health          *http.Server
+{{- if .Resume }}
+	resumable       map[string]*Client // Clients by resume token
+{{- end }}
+
+	connMu      sync.Mutex
+	connBuckets map[string]*connBucket
+	connSwept   time.Time
+}
+
+// NewServer returns a server creating a handler per client with newHandler
+func NewServer(newHandler func(c *Client) PacketHandler) *Server {
+	return &Server{NewHandler: newHandler}
+}
+
+// Run listens on every listener of cfg and serves clients until one listener fails. If cfg has a
+// knobs file, Runtime is loaded from it and reloaded on SIGHUP while Run serves.
+func (s *Server) Run(cfg *ServerConfig) error {
+	if cfg.KnobsFile != "" {
+		if err := Runtime.Load(cfg.KnobsFile); err != nil {
+			return err
+		}
+		stop := Runtime.ReloadOnSIGHUP()
+		defer stop()
+	}
+	if cfg.Handshake != nil {
+		s.Handshake = *cfg.Handshake
+	}
+	if s.Tick == nil && cfg.TickRate > 0 {
+		s.Tick = NewTickLoop(cfg.TickRate)
+	}
+	if s.Regions == nil && len(cfg.Regions) > 0 {
+		regions, err := ParsePrefixRegions(cfg.Regions)
+		if err != nil {
+			return err
+		}
+		s.Regions = regions
+	}
+	if cfg.Health != "" {
+		ln, err := net.Listen("tcp", cfg.Health)
+		if err != nil {
+			return fmt.Errorf("health endpoints: %w", err)
+		}
+		s.mu.Lock()
+		s.health = &http.Server{Handler: s.HealthHandler()}
+		s.mu.Unlock()
+		go s.health.Serve(ln)
+	}
+	transports, err := cfg.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(transports...)
+}
+
+// Serve serves clients on already started transports until one of them fails. It returns nil
+// after Shutdown.
+func (s *Server) Serve(transports ...Transport) error {
+	s.mu.Lock()
+	s.transports = append(s.transports, transports...)
+	s.mu.Unlock()
+	if s.Tick != nil {
+		s.Tick.Start()
+	}
+
+	err := acceptAll(s.serveConn, transports)
+	if s.shutdown.Load() {
+		return nil
+	}
+	return err
+}
+
+// Kick disconnects c with DisconnectKicked
+func (s *Server) Kick(c *Client) {
+	c.Disconnect(DisconnectKicked)
+}
+
+// Authenticated reports that c proved its identity, for the Lifecycle. It lifts the Handshake
+// timeout of c, and c no longer counts against HandshakeLimits.MaxUnauthenticated.
+func (s *Server) Authenticated(c *Client, identity string) {
+	s.settle(c)
+	s.lifecycle().OnAuthenticated(c.ID, identity)
+}
+
+// Shutdown stops accepting connections and disconnects every client with DisconnectShutdown,
+// then stops the health endpoints and the ticks
+func (s *Server) Shutdown() {
+	s.shutdown.Store(true)
+	s.mu.Lock()
+	for _, t := range s.transports {
+		t.Close()
+	}
+	health := s.health
+	s.mu.Unlock()
+
+	s.Sessions.Range(func(c *Client) bool {
+		c.Disconnect(DisconnectShutdown)
+		return true
+	})
+	if health != nil {
+		health.Close()
+	}
+	if s.Tick != nil {
+		s.Tick.Stop()
+	}
+}
+
+func (s *Server) lifecycle() Lifecycle {
+	if s.Lifecycle == nil {
+		return NopLifecycle{}
+	}
+	return s.Lifecycle
+}
+
+func (s *Server) serveConn(conn TransportConn) {
+	if err := s.admit(conn); err != nil {
+		closeWithReason(conn, DisconnectThrottled)
+		if s.OnRefused != nil {
+			s.OnRefused(conn.RemoteAddr(), err)
+		}
+		return
+	}
+
+{{- if .Resume }}
+	var session *ResumableSession
+	if s.Resume != nil {
+		var resumed bool
+		var err error
+		if session, resumed, err = s.acceptResume(conn); err != nil || resumed {
+			s.unauthenticated.Add(-1)
+			return
+		}
+	}
+{{- end }}
+
+	c := &Client{ID: strconv.FormatUint(s.nextID.Add(1), 10), conn: conn, done: make(chan struct{}), global: &s.Bandwidth}
+{{- if .HasThrottle }}
+	c.throttle.policy = s.Throttle
+{{- end }}
+{{- if .Resume }}
+	if session != nil {
+		c.resume, c.migrated = session, make(chan struct{}, 1)
+		s.mu.Lock()
+		if s.resumable == nil {
+			s.resumable = map[string]*Client{}
+		}
+		s.resumable[session.Token()] = c
+		s.mu.Unlock()
+	}
+{{- end }}
+	c.unauthenticated.Store(true)
+	if timeout := s.Handshake.Timeout; timeout > 0 {
+		c.handshake = time.AfterFunc(timeout, func() {
+			if c.unauthenticated.Load() {
+				c.Disconnect(DisconnectTimeout)
+			}
+		})
+	}
+	if s.Regions != nil {
+		c.SetRegion(s.Regions.Region(conn.RemoteAddr()))
+	}
+	s.Sessions.add(c)
+	s.lifecycle().OnConnect(c.ID)
+
+	serve := {{if .Middleware}}servePresets{{else if .Security}}ServeGuarded{{else}}Serve{{end}}
+	if s.Tick != nil {
+		serve = s.Tick.Serve
+	}
+	err := serve(c, s.NewHandler(c))
+
+	// A reason set by Kick or Shutdown wins over the error it caused
+	reason := c.close(DisconnectReasonOf(err))
+{{- if .Resume }}
+	if session != nil {
+		s.Resume.Remove(session)
+		s.mu.Lock()
+		delete(s.resumable, session.Token())
+		s.mu.Unlock()
+	}
+{{- end }}
+	s.settle(c)
+	s.Rooms.LeaveAll(c)
+	s.Sessions.remove(c)
+	s.lifecycle().OnDisconnect(c.ID, reason, err)
+}
+
+{{- if .Resume }}
+
+// acceptResume runs the resume handshake on conn. If it resumed the session of a client, the
+// client migrates to conn and goes on being served by its first connection's goroutine.
+func (s *Server) acceptResume(conn TransportConn) (*ResumableSession, bool, error) {
+	// The first packet must arrive within the handshake timeout
+	var timer *time.Timer
+	if timeout := s.Handshake.Timeout; timeout > 0 {
+		timer = time.AfterFunc(timeout, func() { closeWithReason(conn, DisconnectTimeout) })
+	}
+	session, resumed, err := s.Resume.accept(conn, func(session *ResumableSession) {
+		s.mu.Lock()
+		c := s.resumable[session.Token()]
+		s.mu.Unlock()
+		if c != nil {
+			// Unblocks the reads and writes still waiting on the connection the client left,
+			// e.g. after a switch from Wi-Fi to cellular
+			c.Conn().Close()
+		}
+	})
+	if timer != nil {
+		timer.Stop()
+	}
+	if err != nil {
+		closeWithReason(conn, DisconnectReasonOf(err))
+		return nil, false, err
+	}
+	if !resumed {
+		return session, false, nil
+	}
+
+	s.mu.Lock()
+	c := s.resumable[session.Token()]
+	s.mu.Unlock()
+	if c == nil {
+		// The client was disconnected meanwhile
+		closeWithReason(conn, DisconnectClosed)
+		return nil, true, nil
+	}
+	c.migrate(conn)
+	return session, true, nil
+}
+{{- end }}
+
+// admit applies the Handshake limits to a new connection and counts it as unauthenticated, or
+// returns why it is refused
+func (s *Server) admit(conn TransportConn) error {
+	limits := s.Handshake
+	if limits.ConnRate > 0 && !s.takeConn(connHost(conn.RemoteAddr()), limits) {
+		return ErrConnRateExceeded
+	}
+	n := s.unauthenticated.Add(1)
+	if limits.MaxUnauthenticated > 0 && n > int64(limits.MaxUnauthenticated) {
+		s.unauthenticated.Add(-1)
+		return fmt.Errorf("%w: %d", ErrTooManyUnauthenticated, limits.MaxUnauthenticated)
+	}
+	return nil
+}
+
+// settle stops counting c as unauthenticated, once it authenticated or disconnected
+func (s *Server) settle(c *Client) {
+	if !c.unauthenticated.CompareAndSwap(true, false) {
+		return
+	}
+	s.unauthenticated.Add(-1)
+	if c.handshake != nil {
+		c.handshake.Stop()
+	}
+}
+
+// takeConn takes a connection from the token bucket of host
+func (s *Server) takeConn(host string, limits HandshakeLimits) bool {
+	burst := float64(limits.ConnBurst)
+	if burst <= 0 {
+		burst = max(1, 2*limits.ConnRate)
+	}
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	now := time.Now()
+	if s.connBuckets == nil {
+		s.connBuckets = map[string]*connBucket{}
+	}
+
+	// Forget the addresses whose buckets refilled, so the map does not grow with every address seen
+	if refill := time.Duration(burst / limits.ConnRate * float64(time.Second)); now.Sub(s.connSwept) > refill {
+		for h, b := range s.connBuckets {
+			if now.Sub(b.last) > refill {
+				delete(s.connBuckets, h)
+			}
+		}
+		s.connSwept = now
+	}
+
+	bucket, ok := s.connBuckets[host]
+	if !ok {
+		bucket = &connBucket{tokens: burst, last: now}
+		s.connBuckets[host] = bucket
+	}
+	bucket.tokens = min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limits.ConnRate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// connBucket is a token bucket of new connections from one address
+type connBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// connHost returns the IP address of addr without its port, or the whole address if it has none
+func connHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+`
+
+// generateGoServer writes packet_server.go, a server bootstrap that starts the listeners in a
+// config file using the generated transports
+func generateGoServer(result *parser.ParseResult, transports []string, opts TransportOptions, outDir string) error {
+	has := map[string]bool{}
+	for _, t := range transports {
+		has[t] = true
+	}
+
+	// Schemes and example listeners, in a fixed order
+	var schemes, examples []string
+	for _, t := range []struct{ name, scheme, example string }{
+		{"ws", "ws", "ws://:8080/ws"},
+		{"tcp", "tcp", "tcp://:9000"},
+		{"kcp", "udp (KCP)", "udp://:9001"},
+		{"quic", "quic", "quic://:9002"},
+		{"socketio", "socketio", "socketio://:3000/socket.io/"},
+		{"mqtt", "mqtt, mqtts", "mqtt://localhost:1883/socketgen?qos=1"},
+		{"grpcweb", "grpcweb", "grpcweb://:8081"},
+		{"sse", "sse", "sse://:8082/sse"},
+	} {
+		if has[t.name] {
+			schemes = append(schemes, t.scheme)
+			examples = append(examples, t.example)
+		}
+	}
+
+	resume, err := hasResume(result)
+	if err != nil {
+		return err
+	}
+
+	data := struct {
+		*parser.ParseResult
+		Has        map[string]bool
+		Schemes    []string
+		Examples   []string
+		Security   bool
+		Middleware bool
+		Resume     bool
+	}{result, has, schemes, examples, opts.Security, opts.Middleware, resume}
+
+	if err := writeTemplate(outDir, "packet_server.go", "go_server", goServerTemplate, nil, data); err != nil {
+		return err
+	}
+	if err := generateGoBandwidth(result, outDir); err != nil {
+		return err
+	}
+	if err := generateGoTick(result, opts, outDir); err != nil {
+		return err
+	}
+	if result.HasThrottle() {
+		if err := generateGoThrottle(result, outDir); err != nil {
+			return err
+		}
+	}
+	return writeTemplate(outDir, "packet_health.go", "go_health", goHealthTemplate, nil, result)
+}
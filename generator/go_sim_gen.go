@@ -0,0 +1,360 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goSimTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Clock is the time source of game logic. Handlers that take time from a Clock instead of the
+// time package run deterministically in a Simulation.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f after d. stop cancels the call and reports whether it was still pending.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// SystemClock is the Clock of real servers
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// VirtualClock is a Clock that only moves when it is advanced. Timers run on the goroutine
+// advancing the clock, in the order they are due; timers due at the same time run in the order
+// they were set.
+type VirtualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*virtualTimer // Sorted by due time
+}
+
+type virtualTimer struct {
+	due time.Time
+	f   func()
+}
+
+// NewVirtualClock creates a clock standing at start
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *VirtualClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &virtualTimer{due: c.now.Add(d), f: f}
+	i := sort.Search(len(c.timers), func(i int) bool { return c.timers[i].due.After(t.due) })
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = t
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, pending := range c.timers {
+			if pending == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Advance moves the clock forward by d, see AdvanceTo
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.AdvanceTo(c.Now().Add(d))
+}
+
+// AdvanceTo moves the clock to t, running every timer due by then with the clock at its due
+// time, including timers set by those timers. The clock never moves back.
+func (c *VirtualClock) AdvanceTo(t time.Time) {
+	for {
+		c.mu.Lock()
+		if len(c.timers) == 0 || c.timers[0].due.After(t) {
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.mu.Unlock()
+			return
+		}
+		timer := c.timers[0]
+		c.timers = c.timers[1:]
+		if timer.due.After(c.now) {
+			c.now = timer.due
+		}
+		c.mu.Unlock()
+		timer.f()
+	}
+}
+
+// Pending returns the number of timers that have not run yet
+func (c *VirtualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// SimFrame is one packet of a simulated or recorded session
+type SimFrame struct {
+	Time     time.Time
+	Peer     string
+	Outbound bool // Sent by the server
+	Packet   *{{.Wrapper}}
+}
+
+// maxReplayFrame is the largest replay frame ReadReplay accepts
+const maxReplayFrame = 16 << 20
+
+// ErrNotReplay is returned by ReadReplay for input that is not a socketgen replay file
+var ErrNotReplay = errors.New("not a socketgen replay file")
+
+// ReadReplay decodes a replay file recorded with 'socketgen serve --record <file>.sgr'. The
+// replay must have been recorded with this schema; a recorder killed in the middle of a frame
+// leaves a replay that fails with io.ErrUnexpectedEOF.
+func ReadReplay(r io.Reader) ([]SimFrame, error) {
+	in := bufio.NewReader(r)
+	magic := make([]byte, 5)
+	if _, err := io.ReadFull(in, magic); err != nil || string(magic[:4]) != "SGRP" {
+		return nil, ErrNotReplay
+	}
+	if magic[4] != 1 {
+		return nil, fmt.Errorf("unsupported replay version %d", magic[4])
+	}
+	schema, err := readReplayBytes(in)
+	if err != nil {
+		return nil, fmt.Errorf("malformed replay header: %w", err)
+	}
+	if string(schema) != SchemaVersion {
+		return nil, fmt.Errorf("%w: replay recorded with %s, this build has %s", ErrSchemaMismatch, schema, SchemaVersion)
+	}
+	start, err := binary.ReadUvarint(in)
+	if err != nil {
+		return nil, fmt.Errorf("malformed replay header: %w", err)
+	}
+
+	var frames []SimFrame
+	for {
+		body, err := readReplayBytes(in)
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("replay frame %d: %w", len(frames)+1, err)
+		}
+
+		frame, err := decodeReplayFrame(body, time.Unix(0, int64(start)))
+		if err != nil {
+			return nil, fmt.Errorf("replay frame %d: %w", len(frames)+1, err)
+		}
+		frames = append(frames, frame)
+	}
+}
+
+// readReplayBytes reads a varint length and that many bytes. It returns io.EOF only if the
+// input ends before the length.
+func readReplayBytes(in *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(in)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxReplayFrame {
+		return nil, fmt.Errorf("frame of %d bytes exceeds %d", size, maxReplayFrame)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(in, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeReplayFrame decodes the offset, direction, peer and packet of a frame
+func decodeReplayFrame(body []byte, start time.Time) (SimFrame, error) {
+	malformed := errors.New("malformed frame")
+	offset, n := binary.Uvarint(body)
+	if n <= 0 || n >= len(body) {
+		return SimFrame{}, malformed
+	}
+	outbound := body[n] == 1
+	body = body[n+1:]
+	peerLen, n := binary.Uvarint(body)
+	if n <= 0 || uint64(len(body)-n) < peerLen {
+		return SimFrame{}, malformed
+	}
+
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(body[n+int(peerLen):], pkt); err != nil {
+		return SimFrame{}, err
+	}
+	return SimFrame{
+		Time:     start.Add(time.Duration(offset)),
+		Peer:     string(body[n : n+int(peerLen)]),
+		Outbound: outbound,
+		Packet:   pkt,
+	}, nil
+}
+
+// Simulation feeds packets into handlers without a network, on a VirtualClock, so game logic
+// driven by packets can be regression-tested deterministically. Packets are dispatched on the
+// goroutine calling Deliver or Run, and timers run there as the clock advances, so handlers
+// must not start goroutines of their own.
+type Simulation struct {
+	Clock *VirtualClock
+
+	newHandler func(peer string, stream PacketStream, clock Clock) PacketHandler
+	sessions   map[string]PacketHandler
+	sent       []SimFrame
+}
+
+// NewSimulation creates a simulation whose clock stands at start. newHandler creates the
+// handler of a peer when its first packet arrives; packets written to stream are captured as
+// the peer's outbound frames. Handlers implementing Lifecycle get OnConnect then, and
+// OnDisconnect from Close.
+func NewSimulation(start time.Time, newHandler func(peer string, stream PacketStream, clock Clock) PacketHandler) *Simulation {
+	return &Simulation{
+		Clock:      NewVirtualClock(start),
+		newHandler: newHandler,
+		sessions:   map[string]PacketHandler{},
+	}
+}
+
+// Deliver advances the clock to at, running the timers due by then, and dispatches pkt from peer
+func (s *Simulation) Deliver(at time.Time, peer string, pkt *{{.Wrapper}}) error {
+	s.Clock.AdvanceTo(at)
+
+	handler, ok := s.sessions[peer]
+	if !ok {
+		handler = s.newHandler(peer, &simStream{sim: s, peer: peer}, s.Clock)
+		s.sessions[peer] = handler
+		if l, ok := handler.(Lifecycle); ok {
+			l.OnConnect(peer)
+		}
+	}
+	return DispatchPacket(pkt, handler)
+}
+
+// Run delivers the inbound frames in order, e.g. those of a replay from ReadReplay. Outbound
+// frames are skipped; compare them with Compare. It stops at the first packet a handler rejects.
+func (s *Simulation) Run(frames []SimFrame) error {
+	for i, frame := range frames {
+		if frame.Outbound {
+			continue
+		}
+		if err := s.Deliver(frame.Time, frame.Peer, frame.Packet); err != nil {
+			return fmt.Errorf("frame %d from %s: %w", i+1, frame.Peer, err)
+		}
+	}
+	return nil
+}
+
+// Close ends the session of peer, as if it had disconnected
+func (s *Simulation) Close(peer string) {
+	handler, ok := s.sessions[peer]
+	if !ok {
+		return
+	}
+	delete(s.sessions, peer)
+	if l, ok := handler.(Lifecycle); ok {
+		l.OnDisconnect(peer, DisconnectClosed, nil)
+	}
+}
+
+// Sent returns the packets handlers have sent so far, in order, stamped with the virtual time
+func (s *Simulation) Sent() []SimFrame {
+	return s.sent
+}
+
+// Compare checks that every peer was sent the same packets, in the same order, as the outbound
+// frames of recorded. Send times are not compared.
+func (s *Simulation) Compare(recorded []SimFrame) error {
+	want := outboundByPeer(recorded)
+	got := outboundByPeer(s.sent)
+
+	peers := make([]string, 0, len(want))
+	for peer := range want {
+		peers = append(peers, peer)
+	}
+	for peer := range got {
+		if _, ok := want[peer]; !ok {
+			peers = append(peers, peer)
+		}
+	}
+	sort.Strings(peers)
+
+	for _, peer := range peers {
+		w, g := want[peer], got[peer]
+		for i := 0; i < len(w) || i < len(g); i++ {
+			switch {
+			case i >= len(g):
+				return fmt.Errorf("%s: packet %d: got nothing, want %s", peer, i+1, PayloadName(w[i]))
+			case i >= len(w):
+				return fmt.Errorf("%s: packet %d: got %s, want nothing", peer, i+1, PayloadName(g[i]))
+			case !proto.Equal(g[i], w[i]):
+				return fmt.Errorf("%s: packet %d: got %v, want %v", peer, i+1, g[i], w[i])
+			}
+		}
+	}
+	return nil
+}
+
+func outboundByPeer(frames []SimFrame) map[string][]*{{.Wrapper}} {
+	packets := map[string][]*{{.Wrapper}}{}
+	for _, frame := range frames {
+		if frame.Outbound {
+			packets[frame.Peer] = append(packets[frame.Peer], frame.Packet)
+		}
+	}
+	return packets
+}
+
+// simStream captures the packets a handler sends to its peer
+type simStream struct {
+	sim  *Simulation
+	peer string
+}
+
+func (s *simStream) ReadPacket() ([]byte, error) {
+	return nil, errors.New("simulated sessions receive packets through Simulation.Deliver")
+}
+
+func (s *simStream) WritePacket(data []byte) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	s.sim.sent = append(s.sim.sent, SimFrame{Time: s.sim.Clock.Now(), Peer: s.peer, Outbound: true, Packet: pkt})
+	return nil
+}
+`
+
+// GenerateSim writes packet_sim.go, a deterministic simulation harness with a virtual clock
+func GenerateSim(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_sim.go", "go_sim", goSimTemplate, nil, result)
+}
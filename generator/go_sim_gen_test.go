@@ -0,0 +1,22 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateSimBuilds(t *testing.T) {
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		// Servers only get the simulation harness with --sim
+		if _, err := os.Stat(filepath.Join(dir, "packet_sim.go")); !errors.Is(err, fs.ErrNotExist) {
+			return errors.New("GenerateGo wrote packet_sim.go")
+		}
+		return GenerateSim(result, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
@@ -0,0 +1,81 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goTapTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import "math/rand"
+
+// TapEvent describes one packet reported by a TapStream
+type TapEvent struct {
+	Payload  string  // Payload field name (e.g., "move_cmd"), "" if the packet has none
+	Outbound bool    // Written to the stream rather than read from it
+	Data     []byte  // The encoded {{.Wrapper}}; copy it to keep it after the tap returns
+	Weight   float64 // Packets this event stands for (1 / sample rate); add it to counters instead of 1
+}
+
+// Tap receives the packets a TapStream samples, e.g. to log them or count metrics
+type Tap func(TapEvent)
+
+// PayloadSampleRate returns the fraction of packets of payload (e.g., "move_cmd") that taps
+// report, from option (socketgen.sample_rate); 1 for payloads without it
+func PayloadSampleRate(payload string) float64 {
+	switch payload {
+{{- range .Payloads }}
+{{- if ne .SampleRate 1.0 }}
+	case "{{.FieldName}}":
+		return {{.SampleRate}}
+{{- end }}
+{{- end }}
+	}
+	return 1
+}
+
+// TapStream wraps a PacketStream and reports the packets it carries to a tap, sampled by
+// payload, so high-frequency payloads do not drown logs and metrics. Packets are reported
+// after they were read or written successfully.
+type TapStream struct {
+	stream PacketStream
+	tap    Tap
+}
+
+// NewTapStream wraps stream, reporting sampled packets to tap
+func NewTapStream(stream PacketStream, tap Tap) *TapStream {
+	return &TapStream{stream: stream, tap: tap}
+}
+
+func (t *TapStream) ReadPacket() ([]byte, error) {
+	data, err := t.stream.ReadPacket()
+	if err == nil {
+		t.observe(data, false)
+	}
+	return data, err
+}
+
+func (t *TapStream) WritePacket(data []byte) error {
+	err := t.stream.WritePacket(data)
+	if err == nil {
+		t.observe(data, true)
+	}
+	return err
+}
+
+func (t *TapStream) observe(data []byte, outbound bool) {
+	payload := peekPayload(data)
+	rate := PayloadSampleRate(payload)
+	if rate < 1 && rand.Float64() >= rate {
+		return
+	}
+	t.tap(TapEvent{Payload: payload, Outbound: outbound, Data: data, Weight: 1 / rate})
+}
+`
+
+// generateGoTap writes packet_tap.go, a PacketStream wrapper reporting sampled packets to
+// logging and metrics
+func generateGoTap(result *parser.ParseResult, outDir string) error {
+	if err := generateGoPeek(result, outDir); err != nil {
+		return err
+	}
+	return writeTemplate(outDir, "packet_tap.go", "go_tap", goTapTemplate, nil, result)
+}
@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestGenerateTapForSampleRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		metrics bool
+		want    bool
+	}{
+		{"no sample rates", testSchema, false, false},
+		{"sample rate", strings.Replace(testSchema, "// @socketgen broadcast", "// @socketgen broadcast sample_rate=0.1", 1), false, true},
+		{"metrics", testSchema, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := generateGoSchemaPackage(t, tt.schema, func(result *parser.ParseResult, dir string) error {
+				if tt.metrics {
+					if err := GenerateMetrics(result, nil, dir); err != nil {
+						return err
+					}
+				}
+				_, err := os.Stat(filepath.Join(dir, "packet_tap.go"))
+				if got := !errors.Is(err, fs.ErrNotExist); got != tt.want {
+					t.Errorf("packet_tap.go written: %v, want %v", got, tt.want)
+				}
+				return nil
+			})
+			goCommand(t, "vet", pkg)
+		})
+	}
+}
+
+func TestServerBuildsWithoutTap(t *testing.T) {
+	// The bandwidth counters read the payload of packets like the tap does
+	pkg := generateGoPackage(t, func(result *parser.ParseResult, dir string) error {
+		if err := GenerateRuntimeConfig(result, dir); err != nil {
+			return err
+		}
+		return GenerateTransports(result, []string{"tcp"}, TransportOptions{}, dir)
+	})
+	goCommand(t, "vet", pkg)
+}
@@ -0,0 +1,183 @@
+package generator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goTenantTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrUnknownTenant is returned by TenantRouter.Dispatch for packets without a known tenant
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// ErrTenantRateLimited is returned by TenantRouter.Dispatch when a tenant exceeds its rate limit
+var ErrTenantRateLimited = errors.New("tenant rate limited")
+
+// TenantOf returns the tenant of pkt (Header.{{.Field}})
+func TenantOf(pkt *{{.Wrapper}}) string {
+	return pkt.GetHeader().Get{{.Field | goFieldName}}()
+}
+
+// TenantConfig configures a TenantRouter
+type TenantConfig struct {
+	// Handler returns the handler for a tenant. It is called once per tenant; return an error
+	// (e.g., ErrUnknownTenant) to reject the tenant's packets.
+	Handler func(tenant string) (PacketHandler, error)
+
+	// RateLimit is the sustained packets per second allowed per tenant (0 means unlimited),
+	// with bursts of up to Burst packets (default 1)
+	RateLimit float64
+	Burst     int
+
+	// OnPacket is called for every dispatched packet, e.g. to count metrics labeled by tenant and payload
+	OnPacket func(tenant, payload string)
+}
+
+// TenantRouter scopes dispatch by tenant: every tenant gets its own handler and rate limit,
+// so several game titles can share the same socket infrastructure
+type TenantRouter struct {
+	config TenantConfig
+
+	mu       sync.Mutex
+	handlers map[string]PacketHandler
+	limits   map[string]*tenantBucket
+}
+
+// NewTenantRouter returns a router using config
+func NewTenantRouter(config TenantConfig) *TenantRouter {
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	return &TenantRouter{
+		config:   config,
+		handlers: map[string]PacketHandler{},
+		limits:   map[string]*tenantBucket{},
+	}
+}
+
+// Dispatch decodes data and routes it to the handler of its tenant
+func (r *TenantRouter) Dispatch(data []byte) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	return r.DispatchPacket(pkt)
+}
+
+// DispatchPacket routes an already decoded packet to the handler of its tenant
+func (r *TenantRouter) DispatchPacket(pkt *{{.Wrapper}}) error {
+	tenant := TenantOf(pkt)
+	if tenant == "" {
+		return ErrUnknownTenant
+	}
+
+	handler, allowed, err := r.acquire(tenant)
+	if err != nil {
+		return fmt.Errorf("tenant %s: %w", tenant, err)
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s", ErrTenantRateLimited, tenant)
+	}
+
+	if r.config.OnPacket != nil {
+		r.config.OnPacket(tenant, PayloadName(pkt))
+	}
+	return DispatchPacket(pkt, handler)
+}
+
+// acquire returns the tenant's handler and takes a token from its rate limit
+func (r *TenantRouter) acquire(tenant string) (PacketHandler, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	handler, ok := r.handlers[tenant]
+	if !ok {
+		if r.config.Handler == nil {
+			return nil, false, ErrUnknownTenant
+		}
+		h, err := r.config.Handler(tenant)
+		if err != nil {
+			return nil, false, err
+		}
+		handler = h
+		r.handlers[tenant] = handler
+	}
+
+	if r.config.RateLimit <= 0 {
+		return handler, true, nil
+	}
+	bucket, ok := r.limits[tenant]
+	if !ok {
+		bucket = &tenantBucket{tokens: float64(r.config.Burst), last: time.Now()}
+		r.limits[tenant] = bucket
+	}
+	return handler, bucket.take(r.config.RateLimit, float64(r.config.Burst)), nil
+}
+
+// ServeTenants reads packets from stream and routes them by tenant until the stream fails
+func ServeTenants(stream PacketStream, router *TenantRouter) error {
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		if err := router.Dispatch(data); err != nil {
+			logDispatchError(err)
+			continue
+		}
+	}
+}
+
+// tenantBucket is a token bucket refilled at the tenant's rate limit
+type tenantBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tenantBucket) take(rate, burst float64) bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+`
+
+// GenerateTenant writes packet_tenant.go, routing packets by the tenant in Header.field
+func GenerateTenant(result *parser.ParseResult, field string, outDir string) error {
+	if !hasHeaderField(result, field, "string") {
+		return fmt.Errorf("tenant field %q must be a string field of Header", field)
+	}
+
+	funcMap := template.FuncMap{
+		"goFieldName": goFieldName,
+	}
+	data := struct {
+		*parser.ParseResult
+		Field string
+	}{result, field}
+
+	return writeTemplate(outDir, "packet_tenant.go", "go_tenant", goTenantTemplate, funcMap, data)
+}
@@ -0,0 +1,165 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+// Throttling drops packets rather than delaying them: a throttled payload is a state update whose
+// next packet replaces it, so a late packet is worth less than the one after it.
+
+const goThrottleTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrThrottled is returned by Client.WritePacket for a packet of a throttled payload the client is
+// not due for yet. The packet is dropped; the next one replaces it, so callers can ignore the error.
+var ErrThrottled = errors.New("payload throttled")
+
+// throttledPayloads are the payloads marked with option (socketgen.throttle)
+var throttledPayloads = [...]string{
+{{- range .ThrottledPayloads }}
+	"{{.FieldName}}",
+{{- end }}
+}
+
+// throttleSlot returns the index of payload in throttledPayloads, or -1 if it is not throttled
+func throttleSlot(payload string) int {
+	switch payload {
+{{- range $i, $p := .ThrottledPayloads }}
+	case "{{$p.FieldName}}":
+		return {{$i}}
+{{- end }}
+	}
+	return -1
+}
+
+// SessionLoad is what a ThrottlePolicy decides on
+type SessionLoad struct {
+	RTT     time.Duration // Last round trip reported with Client.ObserveRTT, 0 until one is
+	Backlog int           // Writes to the client waiting on its connection
+}
+
+// ThrottlePolicy decides how often a client gets a throttled payload. Interval returns the least
+// time between two packets of payload to the client, given the interval in use (0 at first) and
+// the load of the client; 0 sends every packet. It is called for every packet that is sent.
+type ThrottlePolicy interface {
+	Interval(payload string, current time.Duration, load SessionLoad) time.Duration
+}
+
+// ThrottlePolicyFunc adapts a function to a ThrottlePolicy
+type ThrottlePolicyFunc func(payload string, current time.Duration, load SessionLoad) time.Duration
+
+func (f ThrottlePolicyFunc) Interval(payload string, current time.Duration, load SessionLoad) time.Duration {
+	return f(payload, current, load)
+}
+
+// AdaptiveThrottle backs off clients that cannot keep up. While the RTT is above MaxRTT or more
+// than MaxBacklog writes wait, it doubles the interval, starting from Step, up to MaxInterval;
+// once the client recovers, it shortens the interval by Step down to MinInterval.
+type AdaptiveThrottle struct {
+	MinInterval time.Duration // Interval of a client that keeps up; 0 sends it every packet
+	MaxInterval time.Duration // 0: no limit
+	Step        time.Duration
+	MaxRTT      time.Duration // 0: the RTT is not considered
+	MaxBacklog  int
+}
+
+// DefaultThrottle is the policy of a Server without one
+var DefaultThrottle = AdaptiveThrottle{
+	MaxInterval: time.Second,
+	Step:        20 * time.Millisecond,
+	MaxRTT:      250 * time.Millisecond,
+	MaxBacklog:  2,
+}
+
+func (a AdaptiveThrottle) Interval(payload string, current time.Duration, load SessionLoad) time.Duration {
+	next := current - a.Step
+	if (a.MaxRTT > 0 && load.RTT > a.MaxRTT) || load.Backlog > a.MaxBacklog {
+		next = max(current*2, a.Step)
+		if a.MaxInterval > 0 {
+			next = min(next, a.MaxInterval)
+		}
+	}
+	return max(next, a.MinInterval)
+}
+
+// PayloadThrottle is the throttling of one payload to a client
+type PayloadThrottle struct {
+	Payload  string        ` + "`json:\"payload\"`" + `
+	Interval time.Duration ` + "`json:\"interval\"`" + ` // Least time between two packets, 0 if not throttled
+	Dropped  uint64        ` + "`json:\"dropped\"`" + `  // Packets dropped since the client connected
+}
+
+// sessionThrottle is the throttling state of a client
+type sessionThrottle struct {
+	policy  ThrottlePolicy // Of the Server when the client connected
+	rtt     atomic.Int64
+	backlog atomic.Int32
+
+	mu       sync.Mutex
+	payloads [len(throttledPayloads)]struct {
+		interval time.Duration
+		next     time.Time
+		dropped  uint64
+	}
+}
+
+// ObserveRTT reports a round trip to the client measured by the application, e.g. with a ping
+// payload, to the throttle policy
+func (c *Client) ObserveRTT(rtt time.Duration) {
+	c.throttle.rtt.Store(int64(rtt))
+}
+
+// Load returns the load of the client the throttle policy decides on
+func (c *Client) Load() SessionLoad {
+	return SessionLoad{RTT: time.Duration(c.throttle.rtt.Load()), Backlog: int(c.throttle.backlog.Load())}
+}
+
+// Throttling returns the throttling of every throttled payload to the client
+func (c *Client) Throttling() []PayloadThrottle {
+	t := &c.throttle
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	throttling := make([]PayloadThrottle, len(throttledPayloads))
+	for i, payload := range throttledPayloads {
+		throttling[i] = PayloadThrottle{Payload: payload, Interval: t.payloads[i].interval, Dropped: t.payloads[i].dropped}
+	}
+	return throttling
+}
+
+// throttled reports whether data carries a throttled payload the client is not due for. If it is
+// due, the policy sets when the next one is.
+func (c *Client) throttled(data []byte) bool {
+	slot := throttleSlot(peekPayload(data))
+	if slot < 0 {
+		return false
+	}
+	load, now := c.Load(), time.Now()
+
+	t := &c.throttle
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := &t.payloads[slot]
+	if now.Before(p.next) {
+		p.dropped++
+		return true
+	}
+	policy := t.policy
+	if policy == nil {
+		policy = DefaultThrottle
+	}
+	p.interval = max(policy.Interval(throttledPayloads[slot], p.interval, load), 0)
+	p.next = now.Add(p.interval)
+	return false
+}
+`
+
+// generateGoThrottle writes packet_throttle.go, the adaptive throttling of the payloads marked
+// with option (socketgen.throttle) in the Go server
+func generateGoThrottle(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_throttle.go", "go_throttle", goThrottleTemplate, nil, result)
+}
@@ -0,0 +1,197 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+// Packets are checked when they arrive, by the read-only check and the security guard, and only
+// dispatched on the tick, so a flood is refused at once rather than a tick later, and handlers
+// only ever run on the tick goroutine.
+
+const goTickTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTickBacklog is reported for a packet dropped because TickLoop.MaxPending packets already
+// wait for the next tick
+var ErrTickBacklog = errors.New("tick backlog full")
+
+// TickHooks are called by a TickLoop around the packets of every tick, e.g. to step the
+// simulation after the inputs of the tick and broadcast its state
+type TickHooks interface {
+	OnTickBegin(tick uint64, dt time.Duration)
+	OnTickEnd(tick uint64, dt time.Duration)
+}
+
+// TickLoop buffers the packets of every client and dispatches them to their handlers once per
+// tick, in the order they arrived, on a single goroutine, like an authoritative simulation
+// consumes its inputs: handlers and hooks share the game state without locks.
+type TickLoop struct {
+	Interval   time.Duration // Time between two ticks, e.g. 50ms for 20 Hz (0: stepped by hand)
+	Hooks      TickHooks     // (optional)
+	MaxBatch   int           // Packets dispatched per tick; the others wait for the next tick (0: all)
+	MaxPending int           // Packets waiting for a tick, beyond which new ones are dropped (0: no limit)
+
+	mu      sync.Mutex
+	pending []tickPacket
+	batch   []tickPacket
+	tick    uint64
+	start   sync.Once
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+type tickPacket struct {
+	data    []byte
+	handler PacketHandler
+{{- if .Middleware }}
+	chain   []Middleware
+{{- end }}
+}
+
+// NewTickLoop returns a loop ticking rate times per second
+func NewTickLoop(rate float64) *TickLoop {
+	return &TickLoop{Interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Tick returns the number of the last tick, counted from 1
+func (l *TickLoop) Tick() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tick
+}
+
+// Pending returns the packets waiting for the next tick
+func (l *TickLoop) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.pending)
+}
+
+// Start runs a tick every Interval on a goroutine of its own until Stop. The Server starts its
+// TickLoop when it serves; starting a loop again, or one without an Interval, does nothing.
+func (l *TickLoop) Start() {
+	if l.Interval <= 0 {
+		return
+	}
+	l.start.Do(func() {
+		l.mu.Lock()
+		l.stop = make(chan struct{})
+		l.mu.Unlock()
+		go l.run()
+	})
+}
+
+// Stop stops the ticks started by Start, after the tick in progress
+func (l *TickLoop) Stop() {
+	l.start.Do(func() {}) // A loop that never started stays stopped
+	l.mu.Lock()
+	stop := l.stop
+	l.mu.Unlock()
+	if stop != nil {
+		l.stopped.Do(func() { close(stop) })
+	}
+}
+
+func (l *TickLoop) run() {
+	ticker := time.NewTicker(l.Interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			l.Step(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// Step runs one tick: OnTickBegin, the packets received since the previous tick, then OnTickEnd.
+// Start calls it every Interval; without an Interval, call it from your own game loop, never from
+// two goroutines at once.
+func (l *TickLoop) Step(dt time.Duration) {
+	l.mu.Lock()
+	n := len(l.pending)
+	if l.MaxBatch > 0 {
+		n = min(n, l.MaxBatch)
+	}
+	batch := append(l.batch[:0], l.pending[:n]...)
+	rest := copy(l.pending, l.pending[n:])
+	clear(l.pending[rest:])
+	l.pending = l.pending[:rest]
+	l.tick++
+	tick := l.tick
+	l.mu.Unlock()
+
+	if l.Hooks != nil {
+		l.Hooks.OnTickBegin(tick, dt)
+	}
+	for _, p := range batch {
+		if err := {{if .Middleware}}DispatchWithMiddleware(p.data, p.handler, p.chain...){{else}}Dispatch(p.data, p.handler){{end}}; err != nil {
+			logDispatchError(err)
+		}
+	}
+	if l.Hooks != nil {
+		l.Hooks.OnTickEnd(tick, dt)
+	}
+
+	clear(batch)
+	l.batch = batch[:0]
+}
+
+// push buffers a packet for the next tick
+func (l *TickLoop) push(data []byte, handler PacketHandler{{if .Middleware}}, chain []Middleware{{end}}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.MaxPending > 0 && len(l.pending) >= l.MaxPending {
+		return ErrTickBacklog
+	}
+	l.pending = append(l.pending, tickPacket{data: data, handler: handler{{if .Middleware}}, chain: chain{{end}}})
+	return nil
+}
+
+// Serve reads the packets of stream like Serve{{if .Security}}Guarded{{end}}, but buffers them for the
+// next tick instead of dispatching them as they arrive{{if .Middleware}}; they are dispatched through
+// the middleware presets{{end}}
+func (l *TickLoop) Serve(stream PacketStream, handler PacketHandler) error {
+{{- if .Security }}
+	guard := NewGuard()
+{{- end }}
+{{- if .Middleware }}
+	chain := NewPresets()
+{{- end }}
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+{{- if .Security }}
+		if err := guard.Check(data, handler); err != nil {
+			logDispatchError(err)
+			continue
+		}
+{{- end }}
+		if err := l.push(data, handler{{if .Middleware}}, chain{{end}}); err != nil {
+			logDispatchError(err)
+		}
+	}
+}
+`
+
+// generateGoTick writes packet_tick.go, the fixed-timestep dispatch of the Go server
+func generateGoTick(result *parser.ParseResult, opts TransportOptions, outDir string) error {
+	data := struct {
+		*parser.ParseResult
+		Security   bool
+		Middleware bool
+	}{result, opts.Security, opts.Middleware}
+	return writeTemplate(outDir, "packet_tick.go", "go_tick", goTickTemplate, nil, data)
+}
@@ -0,0 +1,624 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Every transport satisfies the same Transport interface, so one server can listen on several
+// of them at once and feed a single handler. Only the requested transports are generated, since
+// each pulls in its own dependency (gorilla/websocket, kcp-go, quic-go, paho.mqtt.golang).
+
+const goTransportTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+{{- if not .Framing }}
+	"encoding/binary"
+{{- end }}
+	"errors"
+	"fmt"
+{{- if .Checksum }}
+	"hash/crc32"
+{{- end }}
+{{- if not .Framing }}
+	"io"
+{{- end }}
+	"net"
+{{- if not .Framing }}
+	"sync"
+{{- end }}
+)
+
+// MaxFrameSize is the largest packet accepted by stream transports (TCP, KCP, QUIC)
+const MaxFrameSize = 1 << 20
+
+// ErrFrameTooLarge is returned when a peer announces a frame larger than MaxFrameSize
+var ErrFrameTooLarge = errors.New("frame too large")
+{{- if .Checksum }}
+
+// ErrFrameCorrupt is returned when a frame fails its CRC32C checksum
+var ErrFrameCorrupt = errors.New("frame checksum mismatch")
+
+// OnCorruptFrame is called for every frame that fails its checksum, e.g. to count frames
+// corrupted by a misbehaving middlebox. It returns true to drop the frame and keep reading, or
+// false to end the connection with ErrFrameCorrupt. If nil, the connection is ended.
+var OnCorruptFrame func(remote net.Addr, err error) (skip bool)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+{{- end }}
+
+// Transport accepts connections that carry one {{.Wrapper}} per frame
+type Transport interface {
+	Accept() (TransportConn, error)
+	Addr() net.Addr
+	Close() error
+}
+
+// TransportConn is one client connection on a Transport
+type TransportConn interface {
+	PacketStream
+	RemoteAddr() net.Addr
+	Close() error
+}
+
+// ReasonCloser is implemented by connections that can tell the peer why they are closed
+// (e.g., with a WebSocket close code)
+type ReasonCloser interface {
+	CloseWithReason(reason DisconnectReason) error
+}
+
+// closeWithReason closes conn, telling the peer the reason if the transport supports it
+func closeWithReason(conn TransportConn, reason DisconnectReason) error {
+	if rc, ok := conn.(ReasonCloser); ok {
+		return rc.CloseWithReason(reason)
+	}
+	return conn.Close()
+}
+{{- if .Previous }}
+
+// previousConn is a connection whose peer negotiated the previous schema, see PreviousStream
+type previousConn struct {
+	TransportConn
+	stream *PreviousStream
+}
+
+func newPreviousConn(conn TransportConn) *previousConn {
+	return &previousConn{TransportConn: conn, stream: NewPreviousStream(conn)}
+}
+
+func (c *previousConn) ReadPacket() ([]byte, error) {
+	return c.stream.ReadPacket()
+}
+
+func (c *previousConn) WritePacket(data []byte) error {
+	return c.stream.WritePacket(data)
+}
+
+func (c *previousConn) CloseWithReason(reason DisconnectReason) error {
+	return closeWithReason(c.TransportConn, reason)
+}
+{{- end }}
+
+// ServeTransports accepts connections on every transport and serves each one with the handler
+// returned by newHandler. It returns the first accept error, after closing all transports.
+func ServeTransports(newHandler func(conn TransportConn) PacketHandler, transports ...Transport) error {
+	return acceptAll(func(conn TransportConn) {
+		defer conn.Close()
+		{{if .Middleware}}servePresets{{else if .Security}}ServeGuarded{{else}}Serve{{end}}(conn, newHandler(conn))
+	}, transports)
+}
+
+// acceptAll runs serve in its own goroutine for every connection accepted on transports, until
+// one of them fails to accept
+func acceptAll(serve func(conn TransportConn), transports []Transport) error {
+	errs := make(chan error, len(transports))
+	for _, t := range transports {
+		go func(t Transport) {
+			for {
+				conn, err := t.Accept()
+				if err != nil {
+					errs <- fmt.Errorf("%s: %w", t.Addr(), err)
+					return
+				}
+				go serve(conn)
+			}
+		}(t)
+	}
+
+	err := <-errs
+	for _, t := range transports {
+		t.Close()
+	}
+	return err
+}
+
+{{- if not .Framing }}
+
+// frameConn carries packets over a byte stream, each prefixed with its length as a
+// 4-byte big-endian integer{{if .Checksum}} and followed by the CRC32C of the length and the packet{{end}}
+type frameConn struct {
+	rw     io.ReadWriteCloser
+	remote net.Addr
+
+	writeMu sync.Mutex
+}
+
+func newFrameConn(rw io.ReadWriteCloser, remote net.Addr) *frameConn {
+	return &frameConn{rw: rw, remote: remote}
+}
+
+func (c *frameConn) ReadPacket() ([]byte, error) {
+{{- if .Checksum }}
+	for {
+		frame, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		n := len(frame) - 4
+		if crc32.Checksum(frame[:n], crc32cTable) != binary.BigEndian.Uint32(frame[n:]) {
+			err := fmt.Errorf("%w: %d-byte frame from %s", ErrFrameCorrupt, n-4, c.remote)
+			if OnCorruptFrame != nil && OnCorruptFrame(c.remote, err) {
+				continue
+			}
+			return nil, NewDisconnectError(DisconnectProtocolError, err)
+		}
+		return frame[4:n], nil
+	}
+}
+
+// readFrame reads one frame: the length prefix, the packet, and the checksum
+func (c *frameConn) readFrame() ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c.rw, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > MaxFrameSize {
+		return nil, NewDisconnectError(DisconnectProtocolError, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, n))
+	}
+	frame := make([]byte, 4+n+4)
+	copy(frame, size[:])
+	if _, err := io.ReadFull(c.rw, frame[4:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+{{- else }}
+	var size [4]byte
+	if _, err := io.ReadFull(c.rw, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > MaxFrameSize {
+		return nil, NewDisconnectError(DisconnectProtocolError, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, n))
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+{{- end }}
+
+func (c *frameConn) WritePacket(data []byte) error {
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(data))
+	}
+{{- if .Checksum }}
+	frame := make([]byte, 4+len(data)+4)
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	binary.BigEndian.PutUint32(frame[4+len(data):], crc32.Checksum(frame[:4+len(data)], crc32cTable))
+{{- else }}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+{{- end }}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.rw.Write(frame)
+	return err
+}
+
+func (c *frameConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *frameConn) Close() error {
+	return c.rw.Close()
+}
+{{- end }}
+`
+
+const goTransportTCPTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import "net"
+
+// TCPTransport serves length-prefixed packets over TCP
+type TCPTransport struct {
+	listener net.Listener
+}
+
+// ListenTCP listens for TCP connections on addr (e.g., ":9000")
+func ListenTCP(addr string) (*TCPTransport, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &TCPTransport{listener: l}, nil
+}
+
+func (t *TCPTransport) Accept() (TransportConn, error) {
+	conn, err := t.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newFrameConn(conn, conn.RemoteAddr()), nil
+}
+
+func (t *TCPTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *TCPTransport) Close() error {
+	return t.listener.Close()
+}
+
+// DialTCP connects to a TCPTransport, e.g. for tests and bots
+func DialTCP(addr string) (TransportConn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return newFrameConn(conn, conn.RemoteAddr()), nil
+}
+`
+
+const goTransportWebSocketTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"net"
+	"net/http"
+{{- if .Previous }}
+	"slices"
+{{- end }}
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketTransport serves packets as binary WebSocket messages. It advertises SchemaVersion
+// in the handshake, like the dev server.
+{{- if .Previous }} Clients offering only PreviousSchemaSubprotocol are served
+// on the previous schema.
+{{- end }}
+type WebSocketTransport struct {
+	listener net.Listener
+	server   *http.Server
+	conns    chan TransportConn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// ListenWebSocket listens for WebSocket connections on addr at path (e.g., ":8080", "/ws")
+func ListenWebSocket(addr, path string) (*WebSocketTransport, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &WebSocketTransport{
+		listener: l,
+		conns:    make(chan TransportConn),
+		closed:   make(chan struct{}),
+	}
+	upgrader := websocket.Upgrader{Subprotocols: []string{SchemaSubprotocol{{if .Previous}}, PreviousSchemaSubprotocol{{end}}}{{if .Security}}, CheckOrigin: checkOrigin{{end}}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		header := http.Header{}
+		header.Set(SchemaVersionHeader, SchemaVersion)
+{{- if .Previous }}
+		offered := websocket.Subprotocols(r)
+		previous := slices.Contains(offered, PreviousSchemaSubprotocol) && !slices.Contains(offered, SchemaSubprotocol)
+		if previous {
+			header.Set(SchemaVersionHeader, PreviousSchemaVersion)
+		}
+{{- end }}
+		conn, err := upgrader.Upgrade(w, r, header)
+		if err != nil {
+			return
+		}
+{{- if .Security }}
+		conn.SetReadLimit(MaxPacketSize)
+{{- end }}
+		var tc TransportConn = &webSocketConn{conn: conn}
+{{- if .Previous }}
+		if previous {
+			tc = newPreviousConn(tc)
+		}
+{{- end }}
+		select {
+		case t.conns <- tc:
+		case <-t.closed:
+			conn.Close()
+		}
+	})
+	t.server = &http.Server{Handler: mux}
+	go t.server.Serve(l)
+
+	return t, nil
+}
+
+func (t *WebSocketTransport) Accept() (TransportConn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-t.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (t *WebSocketTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *WebSocketTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return t.server.Close()
+}
+
+type webSocketConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (c *webSocketConn) ReadPacket() ([]byte, error) {
+	for {
+		kind, data, err := c.conn.ReadMessage()
+		if err != nil {
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				return nil, NewDisconnectError(DisconnectReasonFromCloseCode(ce.Code), err)
+			}
+			return nil, err
+		}
+		if kind == websocket.BinaryMessage {
+			return data, nil
+		}
+	}
+}
+
+func (c *webSocketConn) WritePacket(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (c *webSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *webSocketConn) Close() error {
+	return c.conn.Close()
+}
+
+// CloseWithReason sends a close frame carrying the close code of reason, then closes the connection
+func (c *webSocketConn) CloseWithReason(reason DisconnectReason) error {
+	msg := websocket.FormatCloseMessage(reason.CloseCode(), reason.String())
+	c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	return c.conn.Close()
+}
+`
+
+const goTransportKCPTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"net"
+
+	"github.com/xtaci/kcp-go/v5"
+)
+
+// KCPTransport serves length-prefixed packets over KCP, a reliable protocol on top of UDP
+// with lower latency than TCP on lossy networks
+type KCPTransport struct {
+	listener *kcp.Listener
+}
+
+// ListenKCP listens for KCP sessions on addr (e.g., ":9001"), without encryption or FEC
+func ListenKCP(addr string) (*KCPTransport, error) {
+	l, err := kcp.ListenWithOptions(addr, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &KCPTransport{listener: l}, nil
+}
+
+func (t *KCPTransport) Accept() (TransportConn, error) {
+	sess, err := t.listener.AcceptKCP()
+	if err != nil {
+		return nil, err
+	}
+	sess.SetNoDelay(1, 10, 2, 1) // Fast mode
+	return newFrameConn(sess, sess.RemoteAddr()), nil
+}
+
+func (t *KCPTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *KCPTransport) Close() error {
+	return t.listener.Close()
+}
+
+// DialKCP connects to a KCPTransport, e.g. for tests and bots
+func DialKCP(addr string) (TransportConn, error) {
+	sess, err := kcp.DialWithOptions(addr, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	sess.SetNoDelay(1, 10, 2, 1)
+	return newFrameConn(sess, sess.RemoteAddr()), nil
+}
+`
+
+const goTransportQUICTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/quic-go/quic-go"
+)
+
+// QUICALPN is the ALPN protocol negotiated by QUIC clients and servers
+const QUICALPN = SchemaSubprotocol
+
+// QUICTransport serves length-prefixed packets over QUIC. Each connection carries its
+// packets on the first bidirectional stream opened by the client.
+type QUICTransport struct {
+	listener *quic.Listener
+}
+
+// ListenQUIC listens for QUIC connections on addr (e.g., ":9002"). QUIC requires TLS;
+// QUICALPN is added to tlsConfig.NextProtos if it is not there.
+func ListenQUIC(addr string, tlsConfig *tls.Config) (*QUICTransport, error) {
+	l, err := quic.ListenAddr(addr, withQUICALPN(tlsConfig), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &QUICTransport{listener: l}, nil
+}
+
+func (t *QUICTransport) Accept() (TransportConn, error) {
+	for {
+		conn, err := t.listener.Accept(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			conn.CloseWithError(0, "no stream")
+			continue
+		}
+		return newFrameConn(quicStream{stream, conn}, conn.RemoteAddr()), nil
+	}
+}
+
+func (t *QUICTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *QUICTransport) Close() error {
+	return t.listener.Close()
+}
+
+// DialQUIC connects to a QUICTransport, e.g. for tests and bots
+func DialQUIC(ctx context.Context, addr string, tlsConfig *tls.Config) (TransportConn, error) {
+	conn, err := quic.DialAddr(ctx, addr, withQUICALPN(tlsConfig), nil)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		conn.CloseWithError(0, "no stream")
+		return nil, err
+	}
+	return newFrameConn(quicStream{stream, conn}, conn.RemoteAddr()), nil
+}
+
+// quicStream closes the whole connection along with its packet stream
+type quicStream struct {
+	*quic.Stream
+	conn *quic.Conn
+}
+
+func (s quicStream) Close() error {
+	s.Stream.Close()
+	return s.conn.CloseWithError(0, "")
+}
+
+func withQUICALPN(tlsConfig *tls.Config) *tls.Config {
+	cfg := tlsConfig.Clone()
+	for _, proto := range cfg.NextProtos {
+		if proto == QUICALPN {
+			return cfg
+		}
+	}
+	cfg.NextProtos = append(cfg.NextProtos, QUICALPN)
+	return cfg
+}
+`
+
+// transportTemplates maps each transport to its file name and template
+var transportTemplates = map[string]struct {
+	fileName string
+	text     string
+}{
+	"tcp":      {"packet_transport_tcp.go", goTransportTCPTemplate},
+	"ws":       {"packet_transport_ws.go", goTransportWebSocketTemplate},
+	"kcp":      {"packet_transport_kcp.go", goTransportKCPTemplate},
+	"quic":     {"packet_transport_quic.go", goTransportQUICTemplate},
+	"socketio": {"packet_transport_socketio.go", goTransportSocketIOTemplate},
+	"mqtt":     {"packet_transport_mqtt.go", goTransportMQTTTemplate},
+	"grpcweb":  {"packet_transport_grpcweb.go", goTransportGRPCWebTemplate},
+	"sse":      {"packet_transport_sse.go", goTransportSSETemplate},
+}
+
+// TransportOptions configures the frames of the stream transports and schema negotiation
+type TransportOptions struct {
+	Checksum   bool            // Append a CRC32C to every frame of the default layout
+	Framing    *config.Framing // Custom frame layout (optional)
+	Previous   bool            // The previous schema is served too, see GeneratePrevious
+	Security   bool            // Connections are served with a Guard and origin checks, see GenerateSecurity
+	Middleware bool            // Connections are served with the middleware presets, see GenerateMiddleware
+}
+
+// GenerateTransports writes packet_transport.go, with the Transport interface, one file per
+// requested transport (tcp, ws, kcp, quic, socketio, mqtt, grpcweb, sse), and packet_server.go, which starts them from a config
+func GenerateTransports(result *parser.ParseResult, transports []string, opts TransportOptions, outDir string) error {
+	for _, name := range transports {
+		if _, ok := transportTemplates[name]; !ok {
+			return fmt.Errorf("unknown transport %q (supported: tcp, ws, kcp, quic, socketio, mqtt, grpcweb, sse)", name)
+		}
+	}
+	if opts.Checksum && opts.Framing != nil {
+		return fmt.Errorf("a custom frame layout sets its checksum with framing.checksum in socketgen.yaml")
+	}
+
+	data := struct {
+		*parser.ParseResult
+		Checksum   bool
+		Framing    bool
+		Previous   bool
+		Security   bool
+		Middleware bool
+	}{result, opts.Checksum || (opts.Framing != nil && opts.Framing.Checksum), opts.Framing != nil, opts.Previous, opts.Security, opts.Middleware}
+	if opts.Framing != nil {
+		if err := generateGoFraming(result, opts.Framing, outDir); err != nil {
+			return err
+		}
+	}
+	if err := writeTemplate(outDir, "packet_transport.go", "go_transport", goTransportTemplate, nil, data); err != nil {
+		return err
+	}
+	for _, name := range transports {
+		t := transportTemplates[name]
+		if err := writeTemplate(outDir, t.fileName, "go_transport_"+name, t.text, nil, data); err != nil {
+			return err
+		}
+	}
+	return generateGoServer(result, transports, opts, outDir)
+}
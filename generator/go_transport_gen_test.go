@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+func TestTransports(t *testing.T) {
+	test, err := os.ReadFile(filepath.Join("testdata", "transports_test.go.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts TransportOptions
+	}{
+		{"default frames", TransportOptions{}},
+		{"frame checksums", TransportOptions{Checksum: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := generateTransportPackage(t, func(result *parser.ParseResult, dir string) error {
+				// gen writes the runtime configuration of the server with the transports
+				if err := GenerateRuntimeConfig(result, dir); err != nil {
+					return err
+				}
+				if err := GenerateTransports(result, []string{"tcp", "ws", "kcp", "quic", "socketio", "mqtt", "grpcweb", "sse"}, tt.opts, dir); err != nil {
+					return err
+				}
+				if err := GenerateGateway(result, []string{"nats", "grpc"}, dir); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(dir, "transports_test.go"), test, 0644)
+			})
+
+			list := exec.Command("go", "list", "-deps", "-test", pkg)
+			list.Dir = transportModule
+			if out, err := list.CombinedOutput(); err != nil {
+				t.Skipf("the libraries of the transports cannot be downloaded: %v\n%s", err, out)
+			}
+			// The MQTT transport and the NATS backend need a broker, so they are only vetted
+			goModuleCommand(t, transportModule, "vet", pkg)
+			goModuleCommand(t, transportModule, "test", "-race", pkg)
+		})
+	}
+}
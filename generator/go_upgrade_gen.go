@@ -0,0 +1,71 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+const goUpgradeTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+{{- range .SupersededPayloads }}
+
+// Upgrade{{.Name}} converts a {{.Name}} from an old client into the {{.SupersededBy}} superseding
+// it, before dispatch. The default copies the fields whose name and type are unchanged; replace
+// it to map renamed fields or fill in new ones.
+var Upgrade{{.Name}} = func(old *{{.Name}}) *{{.SupersededBy}} {
+	next := &{{.SupersededBy}}{}
+	copyMatchingFields(old, next)
+	return next
+}
+{{- end }}
+
+// upgradePacket replaces a superseded payload of pkt with the payload superseding it
+func upgradePacket(pkt *{{.Wrapper}}) {
+	switch payload := pkt.Payload.(type) {
+{{- range .SupersededPayloads }}
+	case *{{$.Wrapper}}_{{.Name}}:
+		pkt.Payload = &{{$.Wrapper}}_{{.SupersededBy}}{ {{- .SupersededBy}}: Upgrade{{.Name}}(payload.{{.Name}})}
+{{- end }}
+	}
+}
+
+// copyMatchingFields copies every field set on src to the field of dst with the same name, if
+// it has the same kind, cardinality, and message or enum type
+func copyMatchingFields(src, dst proto.Message) {
+	from, to := src.ProtoReflect(), dst.ProtoReflect()
+	if !from.IsValid() {
+		return
+	}
+	fields := to.Descriptor().Fields()
+	from.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		target := fields.ByName(fd.Name())
+		if target != nil && sameFieldType(fd, target) {
+			to.Set(target, v)
+		}
+		return true
+	})
+}
+
+func sameFieldType(a, b protoreflect.FieldDescriptor) bool {
+	if a.Kind() != b.Kind() || a.Cardinality() != b.Cardinality() || a.IsMap() != b.IsMap() {
+		return false
+	}
+	switch {
+	case a.IsMap():
+		return sameFieldType(a.MapKey(), b.MapKey()) && sameFieldType(a.MapValue(), b.MapValue())
+	case a.Message() != nil:
+		return a.Message().FullName() == b.Message().FullName()
+	case a.Enum() != nil:
+		return a.Enum().FullName() == b.Enum().FullName()
+	}
+	return true
+}
+`
+
+// generateGoUpgrade writes packet_upgrade.go, converting superseded payloads into their
+// successors before dispatch
+func generateGoUpgrade(result *parser.ParseResult, outDir string) error {
+	return writeTemplate(outDir, "packet_upgrade.go", "go_upgrade", goUpgradeTemplate, nil, result)
+}
@@ -0,0 +1,245 @@
+package generator
+
+import "github.com/snowmerak/socketgen/parser"
+
+// Dispatching a packet normally allocates the GamePacket, its oneof wrapper, the payload, and
+// the header. The zero-alloc mode decodes into messages owned by a PacketDecoder instead, so a
+// payload made only of fixed-size fields (numbers, bools, enums) dispatches without touching
+// the heap. Strings, bytes, repeated fields, and nested messages still allocate when set.
+
+const goZeroAllocTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// FixedSizePayloads lists the payloads made only of fixed-size fields. PacketDecoder dispatches
+// them without heap allocations.
+var FixedSizePayloads = []string{
+{{- range .Fixed }}
+	"{{.FieldName}}",
+{{- end }}
+}
+
+var errMalformedPacket = errors.New("malformed packet")
+
+// PacketDecoder decodes packets into messages it reuses. The packet, header, and payload passed
+// to a handler are only valid until the next Decode; use proto.Clone to keep them.
+// A PacketDecoder must not be used concurrently, so give every session its own.
+type PacketDecoder struct {
+	pkt    {{.Wrapper}}
+{{- if .HeaderType }}
+	header {{.HeaderType}}
+{{- end }}
+{{- range .Payloads }}
+
+	wrap{{.Name}} {{$.Wrapper}}_{{.Name}}
+	msg{{.Name}}  {{.Name}}
+{{- end }}
+}
+
+// Decode decodes data into the decoder's reused messages
+func (d *PacketDecoder) Decode(data []byte) (*{{.Wrapper}}, error) {
+	hasHeader, payload, err := scanPacket(data)
+	if err != nil {
+		return nil, err
+	}
+
+	proto.Reset(&d.pkt)
+{{- if .HeaderType }}
+	if hasHeader {
+		proto.Reset(&d.header)
+		d.pkt.Header = &d.header
+	}
+{{- else }}
+	_ = hasHeader
+{{- end }}
+	switch payload {
+{{- range .Payloads }}
+	case {{index $.Numbers .FieldName}}:
+		proto.Reset(&d.msg{{.Name}})
+		d.wrap{{.Name}}.{{.Name}} = &d.msg{{.Name}}
+		d.pkt.Payload = &d.wrap{{.Name}}
+{{- end }}
+	}
+
+	if err := (proto.UnmarshalOptions{Merge: true}).Unmarshal(data, &d.pkt); err != nil {
+		return nil, err
+	}
+	return &d.pkt, nil
+}
+
+// Dispatch decodes data into the decoder's reused messages and routes it to handler
+func (d *PacketDecoder) Dispatch(data []byte, handler PacketHandler) error {
+	pkt, err := d.Decode(data)
+	if err != nil {
+		return err
+	}
+	return DispatchPacket(pkt, handler)
+}
+
+// ServeZeroAlloc is like Serve, but decodes every packet of the stream into the same messages
+func ServeZeroAlloc(stream PacketStream, handler PacketHandler) error {
+	var d PacketDecoder
+	for {
+		data, err := stream.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if rejectReadOnly(stream) {
+			continue
+		}
+		if err := d.Dispatch(data, handler); err != nil {
+			logDispatchError(err)
+			continue
+		}
+	}
+}
+
+// scanPacket reports whether data sets the header, and the field number of the payload it
+// sets last (the one that wins when decoding), without decoding anything
+func scanPacket(data []byte) (hasHeader bool, payload protowire.Number, err error) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return false, 0, errMalformedPacket
+		}
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return false, 0, errMalformedPacket
+		}
+		data = data[n:]
+
+		switch num {
+{{- if .HeaderType }}
+		case {{.HeaderNumber}}:
+			hasHeader = true
+{{- end }}
+		case {{range $i, $p := .Payloads}}{{if $i}}, {{end}}{{index $.Numbers .FieldName}}{{end}}:
+			payload = num
+		}
+	}
+	return hasHeader, payload, nil
+}
+`
+
+const goZeroAllocBenchTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// benchHandler does nothing, so the benchmarks measure decoding and dispatch only
+type benchHandler struct{}
+{{- range .HandledPayloads }}
+
+func (benchHandler) On{{.Name}}(*Header, *{{.Name}}) {}
+{{- end }}
+
+// fixedPacket encodes a packet whose payload has every field set to a non-zero value
+func fixedPacket(tb testing.TB, payload proto.Message, set func(*{{.Wrapper}}, proto.Message)) []byte {
+	m := payload.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch fd.Kind() {
+		case protoreflect.BoolKind:
+			m.Set(fd, protoreflect.ValueOfBool(true))
+		case protoreflect.EnumKind:
+			m.Set(fd, protoreflect.ValueOfEnum(1))
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+			m.Set(fd, protoreflect.ValueOfInt32(-42))
+		case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+			m.Set(fd, protoreflect.ValueOfInt64(-42))
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+			m.Set(fd, protoreflect.ValueOfUint32(42))
+		case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+			m.Set(fd, protoreflect.ValueOfUint64(42))
+		case protoreflect.FloatKind:
+			m.Set(fd, protoreflect.ValueOfFloat32(4.2))
+		case protoreflect.DoubleKind:
+			m.Set(fd, protoreflect.ValueOfFloat64(4.2))
+		}
+	}
+
+	pkt := &{{.Wrapper}}{}
+	set(pkt, payload)
+	data, err := proto.Marshal(pkt)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+{{ range .Fixed }}
+func BenchmarkDispatch{{.Name}}(b *testing.B) {
+	data := fixedPacket(b, &{{.Name}}{}, func(pkt *{{$.Wrapper}}, msg proto.Message) {
+		pkt.Payload = &{{$.Wrapper}}_{{.Name}}{ {{- .Name}}: msg.(*{{.Name}})}
+	})
+	var d PacketDecoder
+	var handler PacketHandler = benchHandler{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := d.Dispatch(data, handler); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+{{- end }}
+`
+
+// GenerateZeroAlloc writes packet_zeroalloc.go, a decoder that reuses its messages, and
+// packet_zeroalloc_test.go, a dispatch benchmark per fixed-size payload for 'socketgen bench'
+func GenerateZeroAlloc(result *parser.ParseResult, outDir string) error {
+	numbers := map[string]int32{}
+	for _, p := range result.Payloads {
+		numbers[p.FieldName] = int32(result.Schema.PayloadByName(p.FieldName).Number())
+	}
+	headerNumber := int32(0)
+	if result.Schema.Header != nil {
+		headerNumber = int32(result.Schema.Header.Number())
+	}
+
+	var fixed []parser.PayloadMessage
+	for _, p := range result.Payloads {
+		if isFixedSize(p.Fields) {
+			fixed = append(fixed, p)
+		}
+	}
+
+	data := struct {
+		*parser.ParseResult
+		Numbers      map[string]int32
+		HeaderNumber int32
+		Fixed        []parser.PayloadMessage
+	}{result, numbers, headerNumber, fixed}
+
+	if err := writeTemplate(outDir, "packet_zeroalloc.go", "go_zeroalloc", goZeroAllocTemplate, nil, data); err != nil {
+		return err
+	}
+	return writeTemplate(outDir, "packet_zeroalloc_test.go", "go_zeroalloc_bench", goZeroAllocBenchTemplate, nil, data)
+}
+
+// isFixedSize reports whether fields are all singular numbers, bools, or enums, which decode
+// without allocating
+func isFixedSize(fields []parser.MessageField) bool {
+	for _, f := range fields {
+		if f.Repeated || f.Map {
+			return false
+		}
+		switch f.Kind {
+		case "string", "bytes", "message", "group":
+			return false
+		}
+	}
+	return true
+}
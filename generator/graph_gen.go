@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// GraphFormats are the formats GenerateGraph renders
+var GraphFormats = []string{"dot", "mermaid"}
+
+// payloadGraph is the dependency graph of the payloads of a schema
+type payloadGraph struct {
+	pkg      string
+	payloads []parser.PayloadMessage
+	messages []protoreflect.FullName                           // Sub-messages the payloads use, in the order found
+	uses     map[protoreflect.FullName][]protoreflect.FullName // Message types each message has fields of
+	users    map[protoreflect.FullName]int                     // Payloads using each sub-message, directly or not
+}
+
+// newPayloadGraph walks the fields of the payloads of result, down to the sub-messages they are
+// made of. With focus, only the payloads named there (by type or field name), and those they
+// respond with or are superseded by, are in the graph.
+func newPayloadGraph(result *parser.ParseResult, focus []string) (*payloadGraph, error) {
+	g := &payloadGraph{
+		pkg:   result.PackageName,
+		uses:  make(map[protoreflect.FullName][]protoreflect.FullName),
+		users: make(map[protoreflect.FullName]int),
+	}
+
+	payloads := result.Payloads
+	if len(focus) > 0 {
+		selected := make(map[string]bool)
+		for _, name := range focus {
+			i := slices.IndexFunc(result.Payloads, func(p parser.PayloadMessage) bool { return p.Name == name || p.FieldName == name })
+			if i < 0 {
+				return nil, fmt.Errorf("no payload %s", name)
+			}
+			p := result.Payloads[i]
+			selected[p.Name] = true
+			if p.RespondsWith != "" {
+				selected[p.RespondsWith] = true
+			}
+			if p.SupersededBy != "" {
+				selected[p.SupersededBy] = true
+			}
+		}
+		payloads = slices.DeleteFunc(slices.Clone(payloads), func(p parser.PayloadMessage) bool { return !selected[p.Name] })
+	}
+	g.payloads = payloads
+
+	isPayload := make(map[protoreflect.FullName]bool)
+	for _, p := range payloads {
+		isPayload[protoreflect.FullName(p.FullName)] = true
+	}
+	seen := make(map[protoreflect.FullName]bool)
+	var walk func(md protoreflect.MessageDescriptor, reached map[protoreflect.FullName]bool)
+	walk = func(md protoreflect.MessageDescriptor, reached map[protoreflect.FullName]bool) {
+		first := !seen[md.FullName()]
+		seen[md.FullName()] = true
+		fields := md.Fields()
+		for i := range fields.Len() {
+			f := fields.Get(i)
+			if f.IsMap() {
+				f = f.MapValue()
+			}
+			sub := f.Message()
+			if sub == nil {
+				continue
+			}
+			if first && !slices.Contains(g.uses[md.FullName()], sub.FullName()) {
+				g.uses[md.FullName()] = append(g.uses[md.FullName()], sub.FullName())
+			}
+			if reached[sub.FullName()] {
+				continue // Recursive types, or a type used by several fields
+			}
+			reached[sub.FullName()] = true
+			if !isPayload[sub.FullName()] {
+				if g.users[sub.FullName()] == 0 {
+					g.messages = append(g.messages, sub.FullName())
+				}
+				g.users[sub.FullName()]++
+			}
+			walk(sub, reached)
+		}
+	}
+	for _, p := range payloads {
+		field := result.Schema.PayloadByName(p.FieldName)
+		if field == nil || field.Message() == nil {
+			continue
+		}
+		walk(field.Message(), map[protoreflect.FullName]bool{field.Message().FullName(): true})
+	}
+	return g, nil
+}
+
+// label returns the name of a message type without its package (e.g., "Inventory.Item")
+func (g *payloadGraph) label(name protoreflect.FullName) string {
+	return strings.TrimPrefix(string(name), g.pkg+".")
+}
+
+// GenerateGraph writes the dependency graph of the payloads of result to w, in format (dot or
+// mermaid): the sub-messages each payload is made of, highlighting those several payloads share,
+// and the responds_with and superseded_by edges between payloads. With focus, the graph is limited
+// to the payloads named there.
+func GenerateGraph(result *parser.ParseResult, format string, focus []string, w io.Writer) error {
+	g, err := newPayloadGraph(result, focus)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	switch format {
+	case "dot":
+		g.writeDOT(&b, result)
+	case "mermaid":
+		g.writeMermaid(&b, result)
+	default:
+		return fmt.Errorf("unknown graph format %q; expected one of %s", format, strings.Join(GraphFormats, ", "))
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (g *payloadGraph) writeDOT(b *strings.Builder, result *parser.ParseResult) {
+	fmt.Fprintf(b, "digraph %q {\n", g.pkg)
+	b.WriteString("  rankdir=LR;\n  node [fontname=\"Helvetica\"];\n")
+
+	b.WriteString("\n  // Payloads\n")
+	for _, p := range g.payloads {
+		fmt.Fprintf(b, "  %q [label=\"%s\\n%s = %d\", shape=box, style=bold];\n", p.FullName, p.Name, p.FieldName, p.Number)
+	}
+	if len(g.messages) > 0 {
+		b.WriteString("\n  // Sub-messages; those shared by several payloads are filled\n")
+		for _, m := range g.messages {
+			style := ""
+			if g.users[m] > 1 {
+				style = fmt.Sprintf(", style=filled, fillcolor=\"#fde68a\", xlabel=\"%d payloads\"", g.users[m])
+			}
+			fmt.Fprintf(b, "  %q [label=%q, shape=ellipse%s];\n", m, g.label(m), style)
+		}
+	}
+
+	b.WriteString("\n  // Fields\n")
+	g.rangeUses(func(from, to protoreflect.FullName) {
+		fmt.Fprintf(b, "  %q -> %q;\n", from, to)
+	})
+	g.rangePayloadEdges(result, func(from, to, label string) {
+		fmt.Fprintf(b, "  %q -> %q [style=dashed, label=%q];\n", from, to, label)
+	})
+	b.WriteString("}\n")
+}
+
+func (g *payloadGraph) writeMermaid(b *strings.Builder, result *parser.ParseResult) {
+	id := func(name string) string { return strings.ReplaceAll(name, ".", "_") }
+
+	b.WriteString("flowchart LR\n")
+	for _, p := range g.payloads {
+		fmt.Fprintf(b, "  %s[\"%s<br/>%s = %d\"]\n", id(p.FullName), p.Name, p.FieldName, p.Number)
+	}
+	var shared []string
+	for _, m := range g.messages {
+		fmt.Fprintf(b, "  %s([\"%s\"])\n", id(string(m)), g.label(m))
+		if g.users[m] > 1 {
+			shared = append(shared, id(string(m)))
+		}
+	}
+
+	g.rangeUses(func(from, to protoreflect.FullName) {
+		fmt.Fprintf(b, "  %s --> %s\n", id(string(from)), id(string(to)))
+	})
+	g.rangePayloadEdges(result, func(from, to, label string) {
+		fmt.Fprintf(b, "  %s -. %s .-> %s\n", id(from), label, id(to))
+	})
+
+	if len(shared) > 0 {
+		b.WriteString("  classDef shared fill:#fde68a\n")
+		fmt.Fprintf(b, "  class %s shared\n", strings.Join(shared, ","))
+	}
+}
+
+// rangeUses calls f for every message type, payload or sub-message, a message in the graph has
+// fields of
+func (g *payloadGraph) rangeUses(f func(from, to protoreflect.FullName)) {
+	inGraph := make(map[protoreflect.FullName]bool)
+	for _, p := range g.payloads {
+		inGraph[protoreflect.FullName(p.FullName)] = true
+	}
+	for _, m := range g.messages {
+		inGraph[m] = true
+	}
+
+	from := make([]protoreflect.FullName, 0, len(inGraph))
+	for _, p := range g.payloads {
+		from = append(from, protoreflect.FullName(p.FullName))
+	}
+	from = append(from, g.messages...)
+	for _, m := range from {
+		for _, sub := range g.uses[m] {
+			if inGraph[sub] {
+				f(m, sub)
+			}
+		}
+	}
+}
+
+// rangePayloadEdges calls f for every responds_with and superseded_by edge between payloads in
+// the graph, with their full names
+func (g *payloadGraph) rangePayloadEdges(result *parser.ParseResult, f func(from, to, label string)) {
+	inGraph := make(map[string]bool)
+	for _, p := range g.payloads {
+		inGraph[p.Name] = true
+	}
+	for _, p := range g.payloads {
+		if res := result.Payload(p.RespondsWith); res != nil && inGraph[res.Name] {
+			f(p.FullName, res.FullName, "responds with")
+		}
+		if next := result.Payload(p.SupersededBy); next != nil && inGraph[next.Name] {
+			f(p.FullName, next.FullName, "superseded by")
+		}
+	}
+}
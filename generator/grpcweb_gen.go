@@ -0,0 +1,507 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The gRPC-Web bridge carries packets where raw WebSockets are blocked but gRPC-Web passes.
+// Browsers cannot stream requests over gRPC-Web, so a session is a server-streaming call,
+// socketgen.PacketBridge/Open, that delivers packets to the client, and every packet from the
+// client is a unary socketgen.PacketBridge/Send call naming the session in its metadata. The
+// server speaks the gRPC-Web wire format itself, so no proxy is needed in front of it, and
+// proxies that understand gRPC-Web pass it unchanged.
+
+const goTransportGRPCWebTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Methods of the bridge, as gRPC paths
+const (
+	GRPCWebOpenMethod = "/socketgen.PacketBridge/Open"
+	GRPCWebSendMethod = "/socketgen.PacketBridge/Send"
+)
+
+// GRPCWebSessionHeader names the session of a Send call. Open returns it as response metadata.
+const GRPCWebSessionHeader = "X-Socketgen-Session"
+
+// GRPCWebKeepalive is how long an Open stream may stay idle before the server sends an empty
+// message, which keeps proxies from closing it. Clients skip empty messages.
+const GRPCWebKeepalive = 30 * time.Second
+
+// gRPC status codes used by the bridge
+const (
+	grpcOK            = 0
+	grpcNotFound      = 5
+	grpcInvalid       = 3
+	grpcUnimplemented = 12
+	grpcUnavailable   = 14
+)
+
+// GRPCWebTransport serves sessions to gRPC-Web clients. Each session lasts as long as its Open
+// call; the server ends it by finishing the call with the disconnect reason in the
+// x-socketgen-disconnect trailer.
+type GRPCWebTransport struct {
+	listener net.Listener
+	server   *http.Server
+	conns    chan TransportConn
+
+	mu       sync.Mutex
+	sessions map[string]*grpcWebConn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// ListenGRPCWeb listens for gRPC-Web calls on addr (e.g., ":8081"), with the methods below
+// prefix (e.g., "" or "/api")
+func ListenGRPCWeb(addr, prefix string) (*GRPCWebTransport, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &GRPCWebTransport{
+		listener: l,
+		conns:    make(chan TransportConn),
+		sessions: map[string]*grpcWebConn{},
+		closed:   make(chan struct{}),
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+GRPCWebOpenMethod, t.cors(t.open))
+	mux.HandleFunc(prefix+GRPCWebSendMethod, t.cors(t.send))
+	t.server = &http.Server{Handler: mux}
+	go t.server.Serve(l)
+
+	return t, nil
+}
+
+func (t *GRPCWebTransport) Accept() (TransportConn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-t.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (t *GRPCWebTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *GRPCWebTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return t.server.Close()
+}
+
+// cors answers preflight requests and checks that a call uses the binary gRPC-Web format
+func (t *GRPCWebTransport) cors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+{{- if .Security }}
+		if !allowOrigin(w, r) {
+			return
+		}
+{{- else }}
+		h.Set("Access-Control-Allow-Origin", "*")
+{{- end }}
+		h.Set("Access-Control-Allow-Headers", "content-type, x-grpc-web, x-user-agent, grpc-timeout, "+strings.ToLower(GRPCWebSessionHeader))
+		h.Set("Access-Control-Expose-Headers", "grpc-status, grpc-message, "+strings.ToLower(GRPCWebSessionHeader))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/grpc-web" && ct != "application/grpc-web+proto" {
+			// grpc-web-text (base64) is not supported
+			grpcWebStatus(w, grpcUnimplemented, "unsupported content type "+ct)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// open starts a session and streams its packets until either side ends it
+func (t *GRPCWebTransport) open(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		grpcWebStatus(w, grpcUnimplemented, "streaming is not supported")
+		return
+	}
+	io.Copy(io.Discard, r.Body)
+
+	var id [16]byte
+	rand.Read(id[:])
+	c := &grpcWebConn{
+		session: hex.EncodeToString(id[:]),
+		remote:  grpcWebAddr(r.RemoteAddr),
+		inbox:   make(chan []byte),
+		outbox:  make(chan []byte),
+		done:    make(chan struct{}),
+	}
+	c.release = func() {
+		t.mu.Lock()
+		delete(t.sessions, c.session)
+		t.mu.Unlock()
+	}
+	t.mu.Lock()
+	t.sessions[c.session] = c
+	t.mu.Unlock()
+
+	select {
+	case t.conns <- c:
+	case <-t.closed:
+		c.release()
+		grpcWebStatus(w, grpcUnavailable, "server closed")
+		return
+	case <-r.Context().Done():
+		c.release()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc-web+proto")
+	w.Header().Set(GRPCWebSessionHeader, c.session)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(GRPCWebKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case data := <-c.outbox:
+			if _, err := w.Write(grpcWebFrame(0, data)); err != nil {
+				c.finish(err, "")
+				return
+			}
+			flusher.Flush()
+			keepalive.Reset(GRPCWebKeepalive)
+		case <-keepalive.C:
+			w.Write(grpcWebFrame(0, nil))
+			flusher.Flush()
+		case <-c.done:
+			trailer := fmt.Sprintf("grpc-status: %d\r\nx-socketgen-disconnect: %s\r\n", grpcOK, c.reason)
+			w.Write(grpcWebFrame(0x80, []byte(trailer)))
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			c.finish(NewDisconnectError(DisconnectClosed, io.EOF), "")
+			return
+		}
+	}
+}
+
+// send delivers the packet of one Send call to its session
+func (t *GRPCWebTransport) send(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	c := t.sessions[r.Header.Get(GRPCWebSessionHeader)]
+	t.mu.Unlock()
+	if c == nil {
+		grpcWebStatus(w, grpcNotFound, "unknown session")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxFrameSize+5+1))
+	if err != nil {
+		grpcWebStatus(w, grpcUnavailable, err.Error())
+		return
+	}
+	if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		grpcWebStatus(w, grpcInvalid, "malformed gRPC-Web message")
+		c.finish(NewDisconnectError(DisconnectProtocolError, fmt.Errorf("malformed gRPC-Web message")), DisconnectProtocolError.String())
+		return
+	}
+	if len(body)-5 > MaxFrameSize {
+		grpcWebStatus(w, grpcInvalid, ErrFrameTooLarge.Error())
+		c.finish(NewDisconnectError(DisconnectProtocolError, ErrFrameTooLarge), DisconnectProtocolError.String())
+		return
+	}
+
+	select {
+	case c.inbox <- body[5:]:
+	case <-c.done:
+		grpcWebStatus(w, grpcNotFound, "session ended")
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc-web+proto")
+	w.WriteHeader(http.StatusOK)
+	w.Write(grpcWebFrame(0, nil))
+	w.Write(grpcWebFrame(0x80, []byte(fmt.Sprintf("grpc-status: %d\r\n", grpcOK))))
+}
+
+// grpcWebStatus answers a call with a trailers-only response
+func grpcWebStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc-web+proto")
+	w.Header().Set("Grpc-Status", fmt.Sprint(code))
+	w.Header().Set("Grpc-Message", url.PathEscape(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcWebFrame prefixes data with its flags and length
+func grpcWebFrame(flags byte, data []byte) []byte {
+	frame := make([]byte, 5, 5+len(data))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+type grpcWebConn struct {
+	session string
+	remote  net.Addr
+	release func()
+
+	inbox  chan []byte
+	outbox chan []byte
+
+	done   chan struct{}
+	err    error  // Why the session ended, set before done is closed
+	reason string // Disconnect reason sent to the client, set before done is closed
+	once   sync.Once
+}
+
+// finish ends the session once, telling the client reason unless it is empty (the client ended it)
+func (c *grpcWebConn) finish(err error, reason string) {
+	c.once.Do(func() {
+		c.err = err
+		c.reason = reason
+		close(c.done)
+		c.release()
+	})
+}
+
+func (c *grpcWebConn) ReadPacket() ([]byte, error) {
+	select {
+	case data := <-c.inbox:
+		return data, nil
+	case <-c.done:
+		return nil, c.err
+	}
+}
+
+func (c *grpcWebConn) WritePacket(data []byte) error {
+	select {
+	case c.outbox <- data:
+		return nil
+	case <-c.done:
+		return net.ErrClosed
+	}
+}
+
+func (c *grpcWebConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *grpcWebConn) Close() error {
+	c.finish(net.ErrClosed, DisconnectClosed.String())
+	return nil
+}
+
+// CloseWithReason finishes the session's Open call with reason in its trailers
+func (c *grpcWebConn) CloseWithReason(reason DisconnectReason) error {
+	c.finish(net.ErrClosed, reason.String())
+	return nil
+}
+
+// grpcWebAddr is the remote address of the Open call
+type grpcWebAddr string
+
+func (a grpcWebAddr) Network() string { return "tcp" }
+func (a grpcWebAddr) String() string  { return string(a) }
+`
+
+const tsGRPCWebTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { IPacketStream } from "./PacketDispatcher";
+import { DisconnectReason } from "./PacketLifecycle";
+
+const OPEN_METHOD = "/socketgen.PacketBridge/Open";
+const SEND_METHOD = "/socketgen.PacketBridge/Send";
+const SESSION_HEADER = "x-socketgen-session";
+
+export class GrpcWebError extends Error {
+  constructor(public code: number, message: string) {
+    super("grpc-web status " + code + ": " + message);
+  }
+}
+
+function frame(data: Uint8Array): Uint8Array {
+  const out = new Uint8Array(5 + data.length);
+  new DataView(out.buffer).setUint32(1, data.length);
+  out.set(data, 5);
+  return out;
+}
+
+function parseTrailers(data: Uint8Array): Map<string, string> {
+  const trailers = new Map<string, string>();
+  for (const line of new TextDecoder().decode(data).split("\r\n")) {
+    const i = line.indexOf(":");
+    if (i > 0) {
+      trailers.set(line.slice(0, i).trim().toLowerCase(), line.slice(i + 1).trim());
+    }
+  }
+  return trailers;
+}
+
+// Reads gRPC-Web frames from a response body, calling onMessage for every message. Returns the trailers.
+async function readFrames(body: ReadableStream<Uint8Array>, onMessage: (data: Uint8Array) => void): Promise<Map<string, string>> {
+  const reader = body.getReader();
+  let buffer = new Uint8Array(0);
+  for (;;) {
+    while (buffer.length >= 5) {
+      const size = new DataView(buffer.buffer, buffer.byteOffset).getUint32(1);
+      if (buffer.length < 5 + size) {
+        break;
+      }
+      const flags = buffer[0];
+      const data = buffer.slice(5, 5 + size);
+      buffer = buffer.slice(5 + size);
+      if (flags & 0x80) {
+        return parseTrailers(data);
+      }
+      onMessage(data);
+    }
+    const { done, value } = await reader.read();
+    if (done) {
+      return new Map();
+    }
+    const next = new Uint8Array(buffer.length + value.length);
+    next.set(buffer);
+    next.set(value, buffer.length);
+    buffer = next;
+  }
+}
+
+function checkStatus(headers: Headers | Map<string, string>) {
+  const status = headers.get("grpc-status");
+  if (status && status !== "0") {
+    throw new GrpcWebError(Number(status), decodeURIComponent(headers.get("grpc-message") ?? ""));
+  }
+}
+
+// Carries packets over the server's gRPC-Web bridge, for networks that block WebSockets
+export class GrpcWebPacketStream implements IPacketStream {
+  private queue: Uint8Array[] = [];
+  private waiters: { resolve: (data: Uint8Array) => void; reject: (err: Error) => void }[] = [];
+  private closed: Error | null = null;
+  private sending: Promise<void> = Promise.resolve();
+  private abort = new AbortController();
+
+  // Why the server ended the session, once it did
+  disconnectReason: DisconnectReason | null = null;
+
+  private constructor(private baseUrl: string, private session: string, private headers: Record<string, string>) {}
+
+  // Opens a session on the bridge at baseUrl (e.g., "https://game.example.com")
+  static async open(baseUrl: string, headers: Record<string, string> = {}): Promise<GrpcWebPacketStream> {
+    const abort = new AbortController();
+    const res = await fetch(baseUrl + OPEN_METHOD, {
+      method: "POST",
+      headers: { ...headers, "content-type": "application/grpc-web+proto", "x-grpc-web": "1" },
+      body: frame(new Uint8Array(0)),
+      signal: abort.signal,
+    });
+    checkStatus(res.headers);
+    const session = res.headers.get(SESSION_HEADER);
+    if (!res.ok || !res.body || !session) {
+      throw new GrpcWebError(14, "bridge did not open a session (HTTP " + res.status + ")");
+    }
+
+    const stream = new GrpcWebPacketStream(baseUrl, session, headers);
+    stream.abort = abort;
+    readFrames(res.body, (data) => {
+      if (data.length > 0) {
+        stream.deliver(data);
+      }
+    }).then(
+      (trailers) => stream.end((trailers.get("x-socketgen-disconnect") as DisconnectReason) || DisconnectReason.Closed),
+      () => stream.end(DisconnectReason.Closed),
+    );
+    return stream;
+  }
+
+  private deliver(data: Uint8Array) {
+    const waiter = this.waiters.shift();
+    if (waiter) {
+      waiter.resolve(data);
+    } else {
+      this.queue.push(data);
+    }
+  }
+
+  private end(reason: DisconnectReason) {
+    if (this.closed) {
+      return;
+    }
+    this.disconnectReason = reason;
+    this.closed = new Error("grpc-web session ended: " + reason);
+    for (const waiter of this.waiters.splice(0)) {
+      waiter.reject(this.closed);
+    }
+  }
+
+  readPacket(): Promise<Uint8Array> {
+    const data = this.queue.shift();
+    if (data) {
+      return Promise.resolve(data);
+    }
+    if (this.closed) {
+      return Promise.reject(this.closed);
+    }
+    return new Promise((resolve, reject) => this.waiters.push({ resolve, reject }));
+  }
+
+  // Packets are sent one Send call at a time, so they arrive in order
+  writePacket(data: Uint8Array): Promise<void> {
+    const send = this.sending.then(async () => {
+      if (this.closed) {
+        throw this.closed;
+      }
+      const res = await fetch(this.baseUrl + SEND_METHOD, {
+        method: "POST",
+        headers: { ...this.headers, "content-type": "application/grpc-web+proto", "x-grpc-web": "1", [SESSION_HEADER]: this.session },
+        body: frame(data),
+      });
+      checkStatus(res.headers);
+      if (res.body) {
+        checkStatus(await readFrames(res.body, () => {}));
+      }
+    });
+    this.sending = send.catch(() => {});
+    return send;
+  }
+
+  // Ends the session by cancelling its Open call
+  close() {
+    this.abort.abort();
+    this.end(DisconnectReason.Closed);
+  }
+}
+`
+
+// GenerateGRPCWebClient writes GrpcWebPacketStream.ts, the TypeScript client of the gRPC-Web bridge
+func GenerateGRPCWebClient(result *parser.ParseResult, outDir string) error {
+	if err := writeTemplate(outDir, "GrpcWebPacketStream.ts", "ts_grpcweb", tsGRPCWebTemplate, nil, result); err != nil {
+		return fmt.Errorf("failed to generate the gRPC-Web client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package generator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The health endpoints answer the probes of orchestration systems: /healthz (liveness) returns
+// 200 while the process serves HTTP, and /readyz (readiness) returns 503 while the server should
+// get no new clients. Both return the same JSON report in every language, e.g.
+//
+//	{"ready": true, "schemaVersion": "...", "connections": 12, "unauthenticated": 1, "queueDepth": 0}
+
+const goHealthTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// HealthReport is the state of a Server that its health endpoints report
+type HealthReport struct {
+	Ready           bool   ` + "`json:\"ready\"`" + `
+	Reason          string ` + "`json:\"reason,omitempty\"`" + ` // Why the server is not ready
+	SchemaVersion   string ` + "`json:\"schemaVersion\"`" + `
+	Connections     int    ` + "`json:\"connections\"`" + `
+	Unauthenticated int64  ` + "`json:\"unauthenticated\"`" + `
+	QueueDepth      int    ` + "`json:\"queueDepth\"`" + `
+}
+
+// Health returns the current state of the server. It is ready once it serves at least one
+// transport, until Shutdown, as long as the queue depth is at most MaxQueueDepth.
+func (s *Server) Health() HealthReport {
+	report := HealthReport{
+		SchemaVersion:   SchemaVersion,
+		Connections:     s.Sessions.Count(),
+		Unauthenticated: s.unauthenticated.Load(),
+	}
+	if s.QueueDepth != nil {
+		report.QueueDepth = s.QueueDepth()
+	}
+
+	s.mu.Lock()
+	serving := len(s.transports) > 0
+	s.mu.Unlock()
+	switch {
+	case s.shutdown.Load():
+		report.Reason = "shutting down"
+	case !serving:
+		report.Reason = "not serving yet"
+	case s.MaxQueueDepth > 0 && report.QueueDepth > s.MaxQueueDepth:
+		report.Reason = fmt.Sprintf("queue depth %d is above %d", report.QueueDepth, s.MaxQueueDepth)
+	default:
+		report.Ready = true
+	}
+	return report
+}
+
+// HealthHandler serves /healthz, which answers 200 while the process is alive, and /readyz,
+// which answers 503 while the server is not ready. Both return the HealthReport as JSON.
+// /metrics serves the Bandwidth counters.
+func (s *Server) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, s.Health())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := s.Health()
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealth(w, status, report)
+	})
+	mux.Handle("/metrics", &s.Bandwidth)
+	return mux
+}
+
+func writeHealth(w http.ResponseWriter, status int, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+`
+
+const csharpHealthTemplate = `// Code generated by socketgen. DO NOT EDIT.
+#nullable enable
+
+using System;
+using System.Collections.Concurrent;
+using System.Linq;
+using System.Net;
+using System.Text;
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using System.Threading.Tasks;
+using {{.PackageName | toPascalCase}};
+
+// The state of a server that its health endpoints report
+public sealed record HealthReport(
+    [property: JsonPropertyName("ready")] bool Ready,
+    [property: JsonPropertyName("reason"), JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)] string? Reason,
+    [property: JsonPropertyName("schemaVersion")] string SchemaVersion,
+    [property: JsonPropertyName("connections")] int Connections,
+    [property: JsonPropertyName("unauthenticated")] int Unauthenticated,
+    [property: JsonPropertyName("queueDepth")] int QueueDepth);
+
+// Tracks connections through the lifecycle events and serves /healthz, which answers 200 while the
+// process is alive, and /readyz, which answers 503 while the server is not ready. Forward the events
+// of your own IConnectionLifecycle to it, or use it as the lifecycle.
+public sealed class ServerHealth : IConnectionLifecycle {
+    // Session ID to whether it authenticated
+    private readonly ConcurrentDictionary<string, bool> _sessions = new();
+    private volatile string? _notReady = "not serving yet";
+
+    // Returns the packets waiting for dispatch, e.g. the depth of a shared queue (optional)
+    public Func<int>? QueueDepth { get; set; }
+
+    // The queue depth above which the server reports it is not ready (0: no limit)
+    public int MaxQueueDepth { get; set; }
+
+    // Reports the server ready, once it accepts clients
+    public void MarkReady() => _notReady = null;
+
+    // Reports the server not ready for reason, e.g. "shutting down"
+    public void MarkNotReady(string reason) => _notReady = reason;
+
+    public void OnConnect(string session) => _sessions[session] = false;
+
+    public void OnAuthenticated(string session, string identity) => _sessions.TryUpdate(session, true, false);
+
+    public void OnDisconnect(string session, DisconnectReason reason, Exception error) => _sessions.TryRemove(session, out _);
+
+    public HealthReport Report() {
+        int depth = QueueDepth?.Invoke() ?? 0;
+        string? reason = _notReady;
+        if (reason == null && MaxQueueDepth > 0 && depth > MaxQueueDepth) {
+            reason = $"queue depth {depth} is above {MaxQueueDepth}";
+        }
+        return new HealthReport(reason == null, reason, PacketDispatcher.SchemaVersion,
+            _sessions.Count, _sessions.Count(s => !s.Value), depth);
+    }
+
+    // Serves the endpoints at prefix (e.g. "http://+:8086/") until the listener is stopped
+    public HttpListener Start(string prefix) {
+        var listener = new HttpListener();
+        listener.Prefixes.Add(prefix);
+        listener.Start();
+        _ = Task.Run(async () => {
+            while (listener.IsListening) {
+                HttpListenerContext context;
+                try {
+                    context = await listener.GetContextAsync();
+                } catch (Exception) when (!listener.IsListening) {
+                    return;
+                }
+                Respond(context);
+            }
+        });
+        return listener;
+    }
+
+    private void Respond(HttpListenerContext context) {
+        var response = context.Response;
+        var report = Report();
+        switch (context.Request.Url?.AbsolutePath) {
+            case "/healthz":
+                response.StatusCode = 200;
+                break;
+            case "/readyz":
+                response.StatusCode = report.Ready ? 200 : 503;
+                break;
+            default:
+                response.StatusCode = 404;
+                response.Close();
+                return;
+        }
+        byte[] body = Encoding.UTF8.GetBytes(JsonSerializer.Serialize(report));
+        response.ContentType = "application/json";
+        response.Headers["Cache-Control"] = "no-store";
+        response.ContentLength64 = body.Length;
+        response.OutputStream.Write(body, 0, body.Length);
+        response.Close();
+    }
+}
+`
+
+const javaHealthTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+import com.sun.net.httpserver.HttpExchange;
+import com.sun.net.httpserver.HttpServer;
+import java.io.IOException;
+import java.io.OutputStream;
+import java.net.InetSocketAddress;
+import java.nio.charset.StandardCharsets;
+import java.util.Map;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.function.IntSupplier;
+
+/**
+ * Tracks connections through the lifecycle events and serves /healthz, which answers 200 while
+ * the process is alive, and /readyz, which answers 503 while the server is not ready. Forward the
+ * events of your own ConnectionLifecycle to it, or use it as the lifecycle.
+ */
+public final class ServerHealth implements ConnectionLifecycle {
+    /** The state of a server that its health endpoints report. */
+    public static final class Report {
+        public final boolean ready;
+        /** Why the server is not ready, or null. */
+        public final String reason;
+        public final String schemaVersion;
+        public final int connections;
+        public final int unauthenticated;
+        public final int queueDepth;
+
+        Report(boolean ready, String reason, String schemaVersion, int connections, int unauthenticated, int queueDepth) {
+            this.ready = ready;
+            this.reason = reason;
+            this.schemaVersion = schemaVersion;
+            this.connections = connections;
+            this.unauthenticated = unauthenticated;
+            this.queueDepth = queueDepth;
+        }
+
+        public String toJson() {
+            StringBuilder json = new StringBuilder("{\"ready\": ").append(ready);
+            if (reason != null) {
+                json.append(", \"reason\": \"").append(reason.replace("\\", "\\\\").replace("\"", "\\\"")).append('"');
+            }
+            return json.append(", \"schemaVersion\": \"").append(schemaVersion)
+                .append("\", \"connections\": ").append(connections)
+                .append(", \"unauthenticated\": ").append(unauthenticated)
+                .append(", \"queueDepth\": ").append(queueDepth)
+                .append('}').toString();
+        }
+    }
+
+    /** Session ID to whether it authenticated. */
+    private final Map<String, Boolean> sessions = new ConcurrentHashMap<>();
+    private volatile String notReady = "not serving yet";
+
+    /** Returns the packets waiting for dispatch, e.g. the size of a shared queue (optional). */
+    public volatile IntSupplier queueDepth;
+
+    /** The queue depth above which the server reports it is not ready (0: no limit). */
+    public volatile int maxQueueDepth;
+
+    /** Reports the server ready, once it accepts clients. */
+    public void markReady() {
+        notReady = null;
+    }
+
+    /** Reports the server not ready for reason, e.g. "shutting down". */
+    public void markNotReady(String reason) {
+        notReady = reason;
+    }
+
+    @Override
+    public void onConnect(String session) {
+        sessions.put(session, false);
+    }
+
+    @Override
+    public void onAuthenticated(String session, String identity) {
+        sessions.replace(session, true);
+    }
+
+    @Override
+    public void onDisconnect(String session, DisconnectReason reason, Throwable cause) {
+        sessions.remove(session);
+    }
+
+    public Report report() {
+        IntSupplier depthOf = queueDepth;
+        int depth = depthOf != null ? depthOf.getAsInt() : 0;
+        String reason = notReady;
+        if (reason == null && maxQueueDepth > 0 && depth > maxQueueDepth) {
+            reason = "queue depth " + depth + " is above " + maxQueueDepth;
+        }
+        int unauthenticated = (int) sessions.values().stream().filter(authenticated -> !authenticated).count();
+        return new Report(reason == null, reason, PacketDispatcher.SCHEMA_VERSION, sessions.size(), unauthenticated, depth);
+    }
+
+    /** Serves the endpoints on address until the returned server is stopped. */
+    public HttpServer start(InetSocketAddress address) throws IOException {
+        HttpServer server = HttpServer.create(address, 0);
+        server.createContext("/healthz", exchange -> respond(exchange, 200, report()));
+        server.createContext("/readyz", exchange -> {
+            Report report = report();
+            respond(exchange, report.ready ? 200 : 503, report);
+        });
+        server.start();
+        return server;
+    }
+
+    private static void respond(HttpExchange exchange, int status, Report report) throws IOException {
+        byte[] body = report.toJson().getBytes(StandardCharsets.UTF_8);
+        exchange.getResponseHeaders().set("Content-Type", "application/json");
+        exchange.getResponseHeaders().set("Cache-Control", "no-store");
+        exchange.sendResponseHeaders(status, body.length);
+        try (OutputStream out = exchange.getResponseBody()) {
+            out.write(body);
+        }
+    }
+}
+`
+
+// healthTemplates maps each language with a server scaffold besides Go to its health file name and template
+var healthTemplates = map[string]struct {
+	fileName string
+	text     string
+}{
+	"csharp": {"ServerHealth.cs", csharpHealthTemplate},
+	"java":   {"ServerHealth.java", javaHealthTemplate},
+}
+
+// GenerateHealth writes the health and readiness endpoints of the lang server scaffolds. The Go
+// server gets them with its transports.
+func GenerateHealth(result *parser.ParseResult, lang string, outDir string) error {
+	tmpl, ok := healthTemplates[lang]
+	if !ok {
+		return fmt.Errorf("health endpoints are not supported for %s", lang)
+	}
+	funcMap := template.FuncMap{
+		"toPascalCase": toPascalCase,
+	}
+	return writeTemplate(outDir, tmpl.fileName, lang+"_health", tmpl.text, funcMap, result)
+}
@@ -0,0 +1,186 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Proto names are valid identifiers in proto, but not in every target: a field named `class` or
+// `yield` is a keyword in Python, and a message named `List` is reserved in PHP. Each protoc
+// plugin renames such identifiers by its own rule, so the generated code refers to them by the
+// same rule rather than escaping them in one way for all targets. Names socketgen derives itself
+// are always prefixed (e.g., OnLoginReq, send_login_req), so they cannot collide.
+
+// goReservedNames are the methods of messages generated by protoc-gen-go; a field named like one
+// gets a trailing underscore (e.g., `string` -> String_)
+var goReservedNames = nameSet("Reset", "String", "ProtoMessage", "Marshal", "Unmarshal", "ExtensionRangeArray", "ExtensionMap", "Descriptor")
+
+// pythonKeywords cannot be used as attribute names, so fields named like them are read with getattr
+var pythonKeywords = nameSet(
+	"False", "None", "True", "and", "as", "assert", "async", "await", "break", "class", "continue",
+	"def", "del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in",
+	"is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield",
+)
+
+// rubyReservedNames are keywords and methods every Ruby object has, so fields named like them are
+// read with Message#[]
+var rubyReservedNames = nameSet(
+	"BEGIN", "END", "alias", "and", "begin", "break", "case", "class", "def", "defined?", "do", "else",
+	"elsif", "end", "ensure", "false", "for", "if", "in", "module", "next", "nil", "not", "or", "redo",
+	"rescue", "retry", "return", "self", "super", "then", "true", "undef", "unless", "until", "when",
+	"while", "yield", "clone", "display", "dup", "extend", "freeze", "hash", "inspect", "method",
+	"methods", "object_id", "send", "tap", "to_s",
+)
+
+// dartReservedNames are keywords and members of GeneratedMessage; protoc_plugin appends "_" and
+// the field number to a field named like one (e.g., `class` = 3 -> class_3)
+var dartReservedNames = nameSet(
+	"abstract", "as", "assert", "async", "await", "break", "case", "catch", "class", "const",
+	"continue", "covariant", "default", "deferred", "do", "dynamic", "else", "enum", "export",
+	"extends", "extension", "external", "factory", "false", "final", "finally", "for", "get", "if",
+	"implements", "import", "in", "interface", "is", "late", "library", "mixin", "new", "null", "of",
+	"on", "operator", "part", "required", "rethrow", "return", "set", "static", "super", "switch",
+	"sync", "this", "throw", "true", "try", "typedef", "var", "void", "while", "with", "yield",
+	"hashCode", "noSuchMethod", "runtimeType", "toString", "info_", "clear", "freeze", "rebuild",
+	"deepCopy", "createEmptyInstance", "unknownFields", "writeToBuffer", "writeToJson",
+	"writeToJsonMap", "mergeFromBuffer", "mergeFromJson", "mergeFromJsonMap", "mergeFromMessage",
+	"isInitialized", "check", "getField", "setField", "hasField", "clearField",
+)
+
+// kotlinKeywords must be quoted with backticks when used as names
+var kotlinKeywords = nameSet(
+	"as", "break", "class", "continue", "do", "else", "false", "for", "fun", "if", "in", "interface",
+	"is", "null", "object", "package", "return", "super", "this", "throw", "true", "try", "typealias",
+	"typeof", "val", "var", "when", "while",
+)
+
+// javaForbiddenAccessors are names protoc's Java generator cannot use for accessors, since they
+// collide with methods of Object or Message; it appends "_" (e.g., getClass_())
+var javaForbiddenAccessors = nameSet(
+	"Class", "CachedSize", "SerializedSize", "ParserForType", "DefaultInstanceForType",
+	"UnknownFields", "AllFields", "DescriptorForType", "InitializationErrorString",
+)
+
+// phpReservedNames cannot name a PHP class (case-insensitively); protoc's PHP generator prefixes
+// them with "PB" (e.g., List -> PBList)
+var phpReservedNames = nameSet(
+	"abstract", "and", "array", "as", "break", "callable", "case", "catch", "class", "clone", "const",
+	"continue", "declare", "default", "die", "do", "echo", "else", "elseif", "empty", "enddeclare",
+	"endfor", "endforeach", "endif", "endswitch", "endwhile", "eval", "exit", "extends", "final",
+	"finally", "fn", "for", "foreach", "function", "global", "goto", "if", "implements", "include",
+	"include_once", "instanceof", "insteadof", "interface", "isset", "list", "match", "namespace",
+	"new", "or", "print", "private", "protected", "public", "readonly", "require", "require_once",
+	"return", "static", "switch", "throw", "trait", "try", "unset", "use", "var", "while", "xor",
+	"yield", "bool", "false", "float", "int", "iterable", "mixed", "never", "null", "object",
+	"parent", "self", "string", "true", "void",
+)
+
+func nameSet(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return m
+}
+
+// goFieldName returns the Go name protoc-gen-go gives a message field
+func goFieldName(name string) string {
+	goName := toGoName(name)
+	for goReservedNames[goName] {
+		goName += "_"
+	}
+	return goName
+}
+
+// pyAttr returns the Python expression reading field name of obj
+func pyAttr(obj, name string) string {
+	if pythonKeywords[name] {
+		return "getattr(" + obj + ", '" + name + "')"
+	}
+	return obj + "." + name
+}
+
+// rubyAttr returns the Ruby expression reading field name of obj
+func rubyAttr(obj, name string) string {
+	if rubyReservedNames[name] {
+		return obj + "['" + name + "']"
+	}
+	return obj + "." + name
+}
+
+// tsAttr returns the TypeScript expression reading field name of obj. A name losing its leading
+// underscore in camelCase may start with a digit (e.g., _2fa -> 2fa), which only brackets can read.
+func tsAttr(obj, name string) string {
+	camel := toCamelCase(name)
+	if startsWithDigit(camel) {
+		return obj + `["` + camel + `"]`
+	}
+	return obj + "." + camel
+}
+
+// tsKey returns field name as a key of a TypeScript object literal
+func tsKey(name string) string {
+	camel := toCamelCase(name)
+	if startsWithDigit(camel) {
+		return `"` + camel + `"`
+	}
+	return camel
+}
+
+// dartName returns the Dart name protoc_plugin gives field name, numbered number
+func dartName(name string, number int32) string {
+	camel := toCamelCase(name)
+	if dartReservedNames[camel] {
+		camel += "_" + strconv.Itoa(int(number))
+	}
+	return camel
+}
+
+// javaAccessor returns the suffix of the Java accessors of a field whose PascalCase name is name
+// (e.g., getLoginReq(), getClass_())
+func javaAccessor(name string) string {
+	if javaForbiddenAccessors[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// kotlinProperty returns the Kotlin property reading field name of a Java message
+func kotlinProperty(name string) string {
+	camel := toCamelCase(name)
+	if javaForbiddenAccessors[toPascalCase(name)] {
+		camel += "_"
+	}
+	if kotlinKeywords[camel] || startsWithDigit(camel) {
+		return "`" + camel + "`"
+	}
+	return camel
+}
+
+// phpClass returns the PHP class name protoc gives message name
+func phpClass(name string) string {
+	if phpReservedNames[strings.ToLower(name)] {
+		return "PB" + name
+	}
+	return name
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && isASCIIDigit(s[0])
+}
+
+// IdentifierWarnings lists payload message names that no renaming rule can keep valid: generated
+// code refers to message types by their proto name, but protoc-gen-go capitalizes it (e.g.,
+// `class` -> Class), and a lower-case name may be a keyword of other targets
+func IdentifierWarnings(result *parser.ParseResult) []string {
+	var warnings []string
+	for _, p := range result.Payloads {
+		if goName := toGoName(p.Name); goName != p.Name {
+			warnings = append(warnings, fmt.Sprintf("payload message %s is generated as %s in Go, so generated code referring to %s will not compile; name it %s in the schema", p.Name, goName, p.Name, goName))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,254 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The queue only does the bookkeeping of prediction: applying a command to the predicted state,
+// and resetting that state to the server's on a correction, stay with the application. Sequence
+// numbers wrap around, so they are compared by their distance, as TCP does.
+
+const tsInputTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+{{- range .InputPayloads }}
+type {{.Name}} = {{$.PackageName}}.{{.Name}};
+{{- end }}
+
+// Whether sequence number a comes after b, across the wraparound
+function seqAfter(a: number, b: number): boolean {
+  return ((a - b) | 0) > 0;
+}
+
+/**
+ * Numbers the input commands of one kind and keeps them until the server acknowledges them, so
+ * the client can apply them to its predicted state right away and replay the unacknowledged ones
+ * after a server correction.
+ */
+export class InputQueue<T extends { seq: number }> {
+  private nextSeq = 1;
+  private commands: T[] = [];
+
+  /** capacity bounds the unacknowledged commands kept; the oldest are dropped beyond it */
+  constructor(private readonly capacity = 256) {}
+
+  /** Numbers command and keeps it. Send it, and apply it to the predicted state. */
+  push(command: T): T {
+    command.seq = this.nextSeq;
+    this.nextSeq = ((this.nextSeq + 1) >>> 0) || 1;
+    this.commands.push(command);
+    if (this.commands.length > this.capacity) {
+      this.commands.shift();
+    }
+    return command;
+  }
+
+  /** Drops the commands up to seq, the last one the server applied */
+  acknowledge(seq: number): void {
+    this.commands = this.commands.filter((c) => seqAfter(c.seq, seq));
+  }
+
+  /**
+   * Handles a server correction: after resetting the predicted state to the server's, which
+   * includes every command up to seq, replay the commands the server has not applied yet.
+   * Returns how many were replayed.
+   */
+  reconcile(seq: number, replay: (command: T) => void): number {
+    this.acknowledge(seq);
+    for (const command of this.commands) {
+      replay(command);
+    }
+    return this.commands.length;
+  }
+
+  /** The unacknowledged commands, oldest first */
+  get pending(): readonly T[] {
+    return this.commands;
+  }
+
+  /** Drops every unacknowledged command, e.g. after a reconnection; numbering goes on */
+  clear(): void {
+    this.commands = [];
+  }
+}
+{{- range .InputPayloads }}
+
+export class {{.Name}}Queue extends InputQueue<{{.Name}}> {}
+{{- end }}
+`
+
+const csharpInputTemplate = `// Code generated by socketgen. DO NOT EDIT.
+using System;
+using System.Collections.Generic;
+
+namespace {{.PackageName | toPascalCase}} {
+    /// <summary>
+    /// Numbers the input commands of one kind and keeps them until the server acknowledges them, so
+    /// the client can apply them to its predicted state right away and replay the unacknowledged
+    /// ones after a server correction. Commands may be pushed and reconciled from different threads.
+    /// </summary>
+    public class InputQueue<T> where T : class {
+        private readonly Func<T, uint> seqOf;
+        private readonly Action<T, uint> setSeq;
+        private readonly int capacity;
+        private readonly List<T> commands = new List<T>();
+        private uint nextSeq = 1;
+
+        /// <param name="capacity">Bounds the unacknowledged commands kept; the oldest are dropped beyond it.</param>
+        public InputQueue(Func<T, uint> seqOf, Action<T, uint> setSeq, int capacity = 256) {
+            this.seqOf = seqOf;
+            this.setSeq = setSeq;
+            this.capacity = capacity;
+        }
+
+        // Whether sequence number a comes after b, across the wraparound
+        private static bool SeqAfter(uint a, uint b) => unchecked((int)(a - b)) > 0;
+
+        /// <summary>Numbers command and keeps it. Send it, and apply it to the predicted state.</summary>
+        public T Push(T command) {
+            lock (commands) {
+                setSeq(command, nextSeq);
+                nextSeq = unchecked(nextSeq + 1);
+                if (nextSeq == 0) {
+                    nextSeq = 1;
+                }
+                commands.Add(command);
+                if (commands.Count > capacity) {
+                    commands.RemoveAt(0);
+                }
+            }
+            return command;
+        }
+
+        /// <summary>Drops the commands up to seq, the last one the server applied.</summary>
+        public void Acknowledge(uint seq) {
+            lock (commands) {
+                commands.RemoveAll(c => !SeqAfter(seqOf(c), seq));
+            }
+        }
+
+        /// <summary>
+        /// Handles a server correction: after resetting the predicted state to the server's, which
+        /// includes every command up to seq, replay the commands the server has not applied yet.
+        /// Returns how many were replayed.
+        /// </summary>
+        public int Reconcile(uint seq, Action<T> replay) {
+            T[] pending;
+            lock (commands) {
+                commands.RemoveAll(c => !SeqAfter(seqOf(c), seq));
+                pending = commands.ToArray();
+            }
+            foreach (var command in pending) {
+                replay(command);
+            }
+            return pending.Length;
+        }
+
+        /// <summary>The unacknowledged commands, oldest first.</summary>
+        public IReadOnlyList<T> Pending {
+            get {
+                lock (commands) {
+                    return commands.ToArray();
+                }
+            }
+        }
+
+        /// <summary>Drops every unacknowledged command, e.g. after a reconnection; numbering goes on.</summary>
+        public void Clear() {
+            lock (commands) {
+                commands.Clear();
+            }
+        }
+    }
+{{- range .InputPayloads }}
+
+    public class {{.Name}}Queue : InputQueue<{{.Name}}> {
+        public {{.Name}}Queue(int capacity = 256) : base(c => c.Seq, (c, seq) => c.Seq = seq, capacity) {
+        }
+    }
+{{- end }}
+}
+`
+
+const dartInputTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import 'packet.pb.dart';
+
+// Whether sequence number a comes after b, across the wraparound
+bool _seqAfter(int a, int b) => ((a - b) & 0xffffffff).toSigned(32) > 0;
+
+/// Numbers the input commands of one kind and keeps them until the server acknowledges them, so
+/// the client can apply them to its predicted state right away and replay the unacknowledged ones
+/// after a server correction.
+class InputQueue<T> {
+  /// [capacity] bounds the unacknowledged commands kept; the oldest are dropped beyond it.
+  InputQueue(this._seqOf, this._setSeq, {this.capacity = 256});
+
+  final int capacity;
+  final int Function(T command) _seqOf;
+  final void Function(T command, int seq) _setSeq;
+  final List<T> _commands = [];
+  int _nextSeq = 1;
+
+  /// Numbers [command] and keeps it. Send it, and apply it to the predicted state.
+  T push(T command) {
+    _setSeq(command, _nextSeq);
+    _nextSeq = (_nextSeq + 1) & 0xffffffff;
+    if (_nextSeq == 0) {
+      _nextSeq = 1;
+    }
+    _commands.add(command);
+    if (_commands.length > capacity) {
+      _commands.removeAt(0);
+    }
+    return command;
+  }
+
+  /// Drops the commands up to [seq], the last one the server applied.
+  void acknowledge(int seq) {
+    _commands.removeWhere((c) => !_seqAfter(_seqOf(c), seq));
+  }
+
+  /// Handles a server correction: after resetting the predicted state to the server's, which
+  /// includes every command up to [seq], replay the commands the server has not applied yet.
+  /// Returns how many were replayed.
+  int reconcile(int seq, void Function(T command) replay) {
+    acknowledge(seq);
+    for (final command in List.of(_commands)) {
+      replay(command);
+    }
+    return _commands.length;
+  }
+
+  /// The unacknowledged commands, oldest first.
+  List<T> get pending => List.unmodifiable(_commands);
+
+  /// Drops every unacknowledged command, e.g. after a reconnection; numbering goes on.
+  void clear() {
+    _commands.clear();
+  }
+}
+{{- range .InputPayloads }}
+
+class {{.Name}}Queue extends InputQueue<{{.Name}}> {
+  {{.Name}}Queue({int capacity = 256}) : super((c) => c.seq, (c, seq) => c.seq = seq, capacity: capacity);
+}
+{{- end }}
+`
+
+// generateInputs writes the input command queues of a client language, if any payload is an
+// input command
+func generateInputs(result *parser.ParseResult, lang, outDir string) error {
+	if len(result.InputPayloads()) == 0 {
+		return nil
+	}
+	switch lang {
+	case "ts":
+		return writeTemplate(outDir, "PacketInputs.ts", "ts_inputs", tsInputTemplate, nil, result)
+	case "csharp":
+		return writeTemplate(outDir, "PacketInputs.cs", "csharp_inputs", csharpInputTemplate, template.FuncMap{"toPascalCase": toPascalCase}, result)
+	case "dart":
+		return writeTemplate(outDir, "packet_inputs.dart", "dart_inputs", dartInputTemplate, nil, result)
+	}
+	return nil
+}
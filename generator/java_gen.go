@@ -13,7 +13,7 @@ import (
 const javaTemplate = `// Code generated by socketgen. DO NOT EDIT.
 package {{.PackageName}};
 
-import {{.PackageName}}.GamePacket;
+import {{.PackageName}}.{{.Wrapper}};
 import {{.PackageName}}.Header;
 {{- range .Payloads }}
 import {{$.PackageName}}.{{.Name}};
@@ -22,19 +22,51 @@ import com.google.protobuf.InvalidProtocolBufferException;
 
 public interface PacketHandler {
 {{- range .Payloads }}
+{{- blockComment "    " .Doc }}
     void on{{.Name}}(Header header, {{.Name}} msg);
 {{- end }}
+{{- if .HasValidation }}
+
+    /** Called instead of the payload's handler for packets breaking a constraint; by default, dispatch throws the error. */
+    default void onValidationError(Header header, ValidationError error) {
+        throw error;
+    }
+{{- end }}
 }
 
 class PacketDispatcher {
+    public static final String SCHEMA_VERSION = "{{.SchemaVersion}}";
+    public static final String SCHEMA_VERSION_HEADER = "X-Socketgen-Schema";
+    public static final String SCHEMA_SUBPROTOCOL = "socketgen." + SCHEMA_VERSION;
+
+    public static void checkSchemaVersion(String advertised) {
+        if (advertised != null && !advertised.isEmpty() && !advertised.equals(SCHEMA_VERSION)) {
+            throw new IllegalStateException("schema version mismatch: peer has " + advertised + ", this build has " + SCHEMA_VERSION);
+        }
+    }
+
     public static void dispatch(byte[] data, PacketHandler handler) throws InvalidProtocolBufferException {
-        GamePacket pkt = GamePacket.parseFrom(data);
-        
+        dispatchPacket({{.Wrapper}}.parseFrom(data), handler);
+    }
+
+    /** Routes an already decoded packet to handler. */
+    public static void dispatchPacket({{.Wrapper}} pkt, PacketHandler handler) {
         switch (pkt.getPayloadCase()) {
 {{- range .Payloads }}
             case {{.FieldName | toUpper}}:
-                handler.on{{.Name}}(pkt.getHeader(), pkt.get{{.Name}}());
+{{- if .Validated }} {
+                ValidationError error = PacketValidator.validate{{.Name}}(pkt.get{{javaAccessor .Name}}());
+                if (error != null) {
+                    handler.onValidationError(pkt.getHeader(), error);
+                } else {
+                    handler.on{{.Name}}(pkt.getHeader(), pkt.get{{javaAccessor .Name}}());
+                }
+                break;
+            }
+{{- else }}
+                handler.on{{.Name}}(pkt.getHeader(), pkt.get{{javaAccessor .Name}}());
                 break;
+{{- end }}
 {{- end }}
             case PAYLOAD_NOT_SET:
                 break;
@@ -55,9 +87,9 @@ class PacketDispatcher {
 {{- range .Payloads }}
 
     public static void send{{.Name}}(PacketStream stream, Header header, {{.Name}} msg) throws java.io.IOException {
-        GamePacket pkt = GamePacket.newBuilder()
+        {{$.Wrapper}} pkt = {{$.Wrapper}}.newBuilder()
             .setHeader(header)
-            .set{{.Name}}(msg)
+            .set{{javaAccessor .Name}}(msg)
             .build();
         stream.writePacket(pkt.toByteArray());
     }
@@ -72,7 +104,9 @@ interface PacketStream {
 
 func GenerateJava(result *parser.ParseResult, outDir string) error {
 	funcMap := template.FuncMap{
-		"toUpper": strings.ToUpper,
+		"toUpper":      strings.ToUpper,
+		"blockComment": blockComment,
+		"javaAccessor": javaAccessor,
 	}
 
 	tmpl, err := template.New("java").Funcs(funcMap).Parse(javaTemplate)
@@ -91,5 +125,13 @@ func GenerateJava(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	if result.HasValidation() {
+		if err := generateValidation(result, "java", outDir); err != nil {
+			return err
+		}
+	}
+	return generateLifecycle(result, "java", outDir)
 }
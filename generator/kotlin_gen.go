@@ -13,7 +13,7 @@ import (
 const kotlinTemplate = `// Code generated by socketgen. DO NOT EDIT.
 package {{.PackageName}}
 
-import {{.PackageName}}.GamePacket
+import {{.PackageName}}.{{.Wrapper}}
 import {{.PackageName}}.Header
 {{- range .Payloads }}
 import {{$.PackageName}}.{{.Name}}
@@ -21,19 +21,30 @@ import {{$.PackageName}}.{{.Name}}
 
 interface PacketHandler {
 {{- range .Payloads }}
+{{- blockComment "    " .Doc }}
     fun on{{.Name}}(header: Header, msg: {{.Name}})
 {{- end }}
 }
 
 object PacketDispatcher {
+    const val SCHEMA_VERSION = "{{.SchemaVersion}}"
+    const val SCHEMA_VERSION_HEADER = "X-Socketgen-Schema"
+    const val SCHEMA_SUBPROTOCOL = "socketgen.$SCHEMA_VERSION"
+
+    fun checkSchemaVersion(advertised: String?) {
+        if (!advertised.isNullOrEmpty() && advertised != SCHEMA_VERSION) {
+            throw IllegalStateException("schema version mismatch: peer has $advertised, this build has $SCHEMA_VERSION")
+        }
+    }
+
     fun dispatch(data: ByteArray, handler: PacketHandler) {
-        val pkt = GamePacket.parseFrom(data)
+        val pkt = {{.Wrapper}}.parseFrom(data)
         
         when (pkt.payloadCase) {
 {{- range .Payloads }}
-            GamePacket.PayloadCase.{{.FieldName | toUpper}} -> handler.on{{.Name}}(pkt.header, pkt.{{.FieldName | toCamelCase}})
+            {{$.Wrapper}}.PayloadCase.{{.FieldName | toUpper}} -> handler.on{{.Name}}(pkt.header, pkt.{{kotlinProperty .FieldName}})
 {{- end }}
-            GamePacket.PayloadCase.PAYLOAD_NOT_SET -> {} // Handle not set case
+            {{.Wrapper}}.PayloadCase.PAYLOAD_NOT_SET -> {} // Handle not set case
             else -> {} // Handle unknown case
         }
     }
@@ -52,9 +63,9 @@ object PacketDispatcher {
 {{- range .Payloads }}
 
     fun send{{.Name}}(stream: PacketStream, header: Header, msg: {{.Name}}) {
-        val pkt = GamePacket.newBuilder()
+        val pkt = {{$.Wrapper}}.newBuilder()
             .setHeader(header)
-            .set{{.Name}}(msg)
+            .set{{javaAccessor .Name}}(msg)
             .build()
         stream.writePacket(pkt.toByteArray())
     }
@@ -69,8 +80,10 @@ interface PacketStream {
 
 func GenerateKotlin(result *parser.ParseResult, outDir string) error {
 	funcMap := template.FuncMap{
-		"toCamelCase": toCamelCase,
-		"toUpper":     strings.ToUpper,
+		"toUpper":        strings.ToUpper,
+		"blockComment":   blockComment,
+		"kotlinProperty": kotlinProperty,
+		"javaAccessor":   javaAccessor,
 	}
 
 	tmpl, err := template.New("kotlin").Funcs(funcMap).Parse(kotlinTemplate)
@@ -89,5 +102,8 @@ func GenerateKotlin(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	return generateLifecycle(result, "kotlin", outDir)
 }
@@ -0,0 +1,449 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// disconnectReason is a standard reason a connection ends. Every language gets the same reasons
+// and the same WebSocket close codes, so a server in one language can tell a client in another
+// why it was disconnected.
+type disconnectReason struct {
+	Name      string // snake_case name (e.g., "protocol_error")
+	CloseCode int
+	Doc       string
+}
+
+var disconnectReasons = []disconnectReason{
+	{"closed", 1000, "The connection was closed normally, or by the peer without a reason"},
+	{"timeout", 4000, "The peer stopped responding"},
+	{"kicked", 4001, "The server removed the client (e.g., an admin kick or a duplicate login)"},
+	{"protocol_error", 1002, "The peer sent data that violates the protocol"},
+	{"shutdown", 1001, "The server is shutting down"},
+	{"throttled", 1013, "The server refused the connection under load (e.g., too many connections from one address); retry later"},
+}
+
+const goLifecycleTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"net"
+)
+
+// DisconnectReason tells why a connection ended
+type DisconnectReason int
+
+const (
+{{- range $i, $r := .Reasons }}
+	// {{.Doc}}
+	Disconnect{{.Name | toPascalCase}}{{if eq $i 0}} DisconnectReason = iota{{end}}
+{{- end }}
+)
+
+func (r DisconnectReason) String() string {
+	switch r {
+{{- range .Reasons }}
+	case Disconnect{{.Name | toPascalCase}}:
+		return "{{.Name}}"
+{{- end }}
+	}
+	return "unknown"
+}
+
+// CloseCode returns the WebSocket close code that carries r to the peer
+func (r DisconnectReason) CloseCode() int {
+	switch r {
+{{- range .Reasons }}
+	case Disconnect{{.Name | toPascalCase}}:
+		return {{.CloseCode}}
+{{- end }}
+	}
+	return 1000
+}
+
+// DisconnectReasonFromCloseCode maps a WebSocket close code back to its reason. Unknown codes map to DisconnectClosed.
+func DisconnectReasonFromCloseCode(code int) DisconnectReason {
+	switch code {
+{{- range .Reasons }}
+	case {{.CloseCode}}:
+		return Disconnect{{.Name | toPascalCase}}
+{{- end }}
+	}
+	return DisconnectClosed
+}
+
+// ErrUnauthenticated is wrapped by the errors of the security guard, the auth middleware and the
+// gateway for a session that is not authenticated
+var ErrUnauthenticated = errors.New("unauthenticated session")
+
+// DisconnectError ends a connection for a known reason
+type DisconnectError struct {
+	Reason DisconnectReason
+	Err    error // Underlying error, may be nil
+}
+
+// NewDisconnectError returns an error that ends a connection with reason
+func NewDisconnectError(reason DisconnectReason, err error) *DisconnectError {
+	return &DisconnectError{Reason: reason, Err: err}
+}
+
+func (e *DisconnectError) Error() string {
+	if e.Err == nil {
+		return "disconnected: " + e.Reason.String()
+	}
+	return "disconnected: " + e.Reason.String() + ": " + e.Err.Error()
+}
+
+func (e *DisconnectError) Unwrap() error {
+	return e.Err
+}
+
+// DisconnectReasonOf classifies the error that ended a connection: a DisconnectError keeps its
+// reason, network timeouts are DisconnectTimeout, and anything else is DisconnectClosed
+func DisconnectReasonOf(err error) DisconnectReason {
+	var de *DisconnectError
+	if errors.As(err, &de) {
+		return de.Reason
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return DisconnectTimeout
+	}
+	return DisconnectClosed
+}
+
+// Lifecycle receives connection lifecycle events. session identifies the connection.
+// Embed NopLifecycle to implement only some of the events.
+type Lifecycle interface {
+	OnConnect(session string)
+	OnAuthenticated(session, identity string)
+	OnDisconnect(session string, reason DisconnectReason, err error)
+}
+
+// NopLifecycle ignores every lifecycle event
+type NopLifecycle struct{}
+
+func (NopLifecycle) OnConnect(string)                             {}
+func (NopLifecycle) OnAuthenticated(string, string)               {}
+func (NopLifecycle) OnDisconnect(string, DisconnectReason, error) {}
+`
+
+const tsLifecycleTemplate = `// Code generated by socketgen. DO NOT EDIT.
+
+// Why a connection ended. Servers in every language use the same WebSocket close codes.
+export enum DisconnectReason {
+{{- range .Reasons }}
+  /** {{.Doc}} */
+  {{.Name | toPascalCase}} = "{{.Name}}",
+{{- end }}
+}
+
+const CLOSE_CODES: Record<DisconnectReason, number> = {
+{{- range .Reasons }}
+  [DisconnectReason.{{.Name | toPascalCase}}]: {{.CloseCode}},
+{{- end }}
+};
+
+export function closeCodeOf(reason: DisconnectReason): number {
+  return CLOSE_CODES[reason];
+}
+
+// Maps a WebSocket close code (e.g., CloseEvent.code) to its reason; unknown codes are Closed
+export function disconnectReasonOf(code: number): DisconnectReason {
+  for (const [reason, c] of Object.entries(CLOSE_CODES)) {
+    if (c === code) {
+      return reason as DisconnectReason;
+    }
+  }
+  return DisconnectReason.Closed;
+}
+
+export interface IConnectionLifecycle {
+  onConnect?(session: string): void;
+  onAuthenticated?(session: string, identity: string): void;
+  onDisconnect?(session: string, reason: DisconnectReason, error?: unknown): void;
+}
+`
+
+const pyLifecycleTemplate = `# Code generated by socketgen. DO NOT EDIT.
+from enum import Enum
+
+
+class DisconnectReason(Enum):
+    """Why a connection ended. Servers in every language use the same WebSocket close codes."""
+{{- range .Reasons }}
+    {{.Name | toUpper}} = "{{.Name}}"  # {{.Doc}}
+{{- end }}
+
+    @property
+    def close_code(self) -> int:
+        return _CLOSE_CODES[self]
+
+    @classmethod
+    def from_close_code(cls, code: int) -> "DisconnectReason":
+        for reason, c in _CLOSE_CODES.items():
+            if c == code:
+                return reason
+        return cls.CLOSED
+
+
+_CLOSE_CODES = {
+{{- range .Reasons }}
+    DisconnectReason.{{.Name | toUpper}}: {{.CloseCode}},
+{{- end }}
+}
+
+
+class ConnectionLifecycle:
+    """Receives connection lifecycle events; override the ones you need."""
+
+    def on_connect(self, session: str) -> None:
+        pass
+
+    def on_authenticated(self, session: str, identity: str) -> None:
+        pass
+
+    def on_disconnect(self, session: str, reason: DisconnectReason, error: Exception | None = None) -> None:
+        pass
+`
+
+const csharpLifecycleTemplate = `// Code generated by socketgen. DO NOT EDIT.
+namespace {{.PackageName | toPascalCase}} {
+    /// <summary>Why a connection ended. Servers in every language use the same WebSocket close codes.</summary>
+    public enum DisconnectReason {
+{{- range .Reasons }}
+        /// <summary>{{.Doc}}</summary>
+        {{.Name | toPascalCase}},
+{{- end }}
+    }
+
+    public static class DisconnectReasons {
+        public static int CloseCode(this DisconnectReason reason) {
+            switch (reason) {
+{{- range .Reasons }}
+                case DisconnectReason.{{.Name | toPascalCase}}: return {{.CloseCode}};
+{{- end }}
+                default: return 1000;
+            }
+        }
+
+        public static DisconnectReason FromCloseCode(int code) {
+            switch (code) {
+{{- range .Reasons }}
+                case {{.CloseCode}}: return DisconnectReason.{{.Name | toPascalCase}};
+{{- end }}
+                default: return DisconnectReason.Closed;
+            }
+        }
+    }
+
+    public interface IConnectionLifecycle {
+        void OnConnect(string session) {}
+        void OnAuthenticated(string session, string identity) {}
+        void OnDisconnect(string session, DisconnectReason reason, System.Exception error) {}
+    }
+}
+`
+
+const javaDisconnectReasonTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+/** Why a connection ended. Servers in every language use the same WebSocket close codes. */
+public enum DisconnectReason {
+{{- range $i, $r := .Reasons }}
+    /** {{.Doc}} */
+    {{.Name | toUpper}}({{.CloseCode}}){{if eq (inc $i) (len $.Reasons)}};{{else}},{{end}}
+{{- end }}
+
+    public final int closeCode;
+
+    DisconnectReason(int closeCode) {
+        this.closeCode = closeCode;
+    }
+
+    public static DisconnectReason fromCloseCode(int code) {
+        for (DisconnectReason reason : values()) {
+            if (reason.closeCode == code) {
+                return reason;
+            }
+        }
+        return CLOSED;
+    }
+}
+`
+
+const javaLifecycleTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+/** Receives connection lifecycle events; override the ones you need. */
+public interface ConnectionLifecycle {
+    default void onConnect(String session) {}
+
+    default void onAuthenticated(String session, String identity) {}
+
+    default void onDisconnect(String session, DisconnectReason reason, Throwable cause) {}
+}
+`
+
+const kotlinLifecycleTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+/** Why a connection ended. Servers in every language use the same WebSocket close codes. */
+enum class DisconnectReason(val closeCode: Int) {
+{{- range .Reasons }}
+    /** {{.Doc}} */
+    {{.Name | toUpper}}({{.CloseCode}}),
+{{- end }}
+    ;
+
+    companion object {
+        fun fromCloseCode(code: Int): DisconnectReason = entries.firstOrNull { it.closeCode == code } ?: CLOSED
+    }
+}
+
+/** Receives connection lifecycle events; override the ones you need. */
+interface ConnectionLifecycle {
+    fun onConnect(session: String) {}
+    fun onAuthenticated(session: String, identity: String) {}
+    fun onDisconnect(session: String, reason: DisconnectReason, cause: Throwable?) {}
+}
+`
+
+const dartLifecycleTemplate = `// Code generated by socketgen. DO NOT EDIT.
+
+/// Why a connection ended. Servers in every language use the same WebSocket close codes.
+enum DisconnectReason {
+{{- range $i, $r := .Reasons }}
+  /// {{.Doc}}
+  {{.Name | toCamelCase}}({{.CloseCode}}){{if eq (inc $i) (len $.Reasons)}};{{else}},{{end}}
+{{- end }}
+
+  const DisconnectReason(this.closeCode);
+
+  final int closeCode;
+
+  static DisconnectReason fromCloseCode(int code) =>
+      DisconnectReason.values.firstWhere((r) => r.closeCode == code, orElse: () => DisconnectReason.closed);
+}
+
+/// Receives connection lifecycle events; override the ones you need.
+abstract class ConnectionLifecycle {
+  void onConnect(String session) {}
+  void onAuthenticated(String session, String identity) {}
+  void onDisconnect(String session, DisconnectReason reason, Object? error) {}
+}
+`
+
+const phpLifecycleTemplate = `<?php
+// Code generated by socketgen. DO NOT EDIT.
+namespace {{.PackageName | toPascalCase}};
+
+/** Why a connection ended. Servers in every language use the same WebSocket close codes. */
+enum DisconnectReason: string {
+{{- range .Reasons }}
+    case {{.Name | toPascalCase}} = '{{.Name}}'; // {{.Doc}}
+{{- end }}
+
+    public function closeCode(): int {
+        return match ($this) {
+{{- range .Reasons }}
+            self::{{.Name | toPascalCase}} => {{.CloseCode}},
+{{- end }}
+        };
+    }
+
+    public static function fromCloseCode(int $code): self {
+        foreach (self::cases() as $reason) {
+            if ($reason->closeCode() === $code) {
+                return $reason;
+            }
+        }
+        return self::Closed;
+    }
+}
+
+interface ConnectionLifecycle {
+    public function onConnect(string $session): void;
+    public function onAuthenticated(string $session, string $identity): void;
+    public function onDisconnect(string $session, DisconnectReason $reason, ?\Throwable $error): void;
+}
+`
+
+const rubyLifecycleTemplate = `# Code generated by socketgen. DO NOT EDIT.
+
+# Why a connection ended. Servers in every language use the same WebSocket close codes.
+module DisconnectReason
+{{- range .Reasons }}
+  {{.Name | toUpper}} = :{{.Name}} # {{.Doc}}
+{{- end }}
+
+  CLOSE_CODES = {
+{{- range .Reasons }}
+    {{.Name | toUpper}} => {{.CloseCode}},
+{{- end }}
+  }.freeze
+
+  def self.close_code(reason)
+    CLOSE_CODES.fetch(reason, 1000)
+  end
+
+  def self.from_close_code(code)
+    CLOSE_CODES.key(code) || CLOSED
+  end
+end
+
+# Receives connection lifecycle events; include it and override the ones you need.
+module ConnectionLifecycle
+  def on_connect(session); end
+
+  def on_authenticated(session, identity); end
+
+  def on_disconnect(session, reason, error = nil); end
+end
+`
+
+// lifecycleFiles maps each language to its lifecycle files and templates
+var lifecycleFiles = map[string][]struct {
+	fileName string
+	text     string
+}{
+	"go":     {{"packet_lifecycle.go", goLifecycleTemplate}},
+	"ts":     {{"PacketLifecycle.ts", tsLifecycleTemplate}},
+	"python": {{"packet_lifecycle.py", pyLifecycleTemplate}},
+	"csharp": {{"PacketLifecycle.cs", csharpLifecycleTemplate}},
+	"java":   {{"DisconnectReason.java", javaDisconnectReasonTemplate}, {"ConnectionLifecycle.java", javaLifecycleTemplate}},
+	"kotlin": {{"PacketLifecycle.kt", kotlinLifecycleTemplate}},
+	"dart":   {{"packet_lifecycle.dart", dartLifecycleTemplate}},
+	"php":    {{"PacketLifecycle.php", phpLifecycleTemplate}},
+	"ruby":   {{"packet_lifecycle.rb", rubyLifecycleTemplate}},
+}
+
+// generateLifecycle writes the disconnect reasons and lifecycle callbacks for lang
+func generateLifecycle(result *parser.ParseResult, lang, outDir string) error {
+	files, ok := lifecycleFiles[lang]
+	if !ok {
+		return fmt.Errorf("lifecycle events are not supported for %s", lang)
+	}
+
+	funcMap := template.FuncMap{
+		"toPascalCase": toPascalCase,
+		"toCamelCase":  toCamelCase,
+		"toUpper":      strings.ToUpper,
+		"inc":          func(i int) int { return i + 1 },
+	}
+	data := struct {
+		*parser.ParseResult
+		Reasons []disconnectReason
+	}{result, disconnectReasons}
+
+	for _, f := range files {
+		if err := writeTemplate(outDir, f.fileName, lang+"_lifecycle", f.text, funcMap, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
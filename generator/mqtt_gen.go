@@ -0,0 +1,477 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The MQTT transport carries packets through a broker instead of a listening socket, for
+// deployments whose infrastructure is already MQTT-based. Every client session has its own
+// topics, one per direction, and a state topic per direction replaces the connect and close
+// of a socket; the client's last will reports a session that vanished.
+
+const goTransportMQTTTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Topics of a session, below MQTTOptions.Prefix:
+//
+//	<prefix>/<session>/up          packets from the client
+//	<prefix>/<session>/down        packets to the client
+//	<prefix>/<session>/up/state    "open" or "closed" from the client; its last will is "timeout"
+//	<prefix>/<session>/down/state  the DisconnectReason, when the server ends the session
+const (
+	mqttUp        = "up"
+	mqttDown      = "down"
+	mqttUpState   = "up/state"
+	mqttDownState = "down/state"
+)
+
+// MQTTInboxSize is the number of packets buffered per session. When a session's buffer is full,
+// delivery to every session waits, so handlers should not block for long.
+const MQTTInboxSize = 256
+
+// MQTTOptions configures both ends of the MQTT transport
+type MQTTOptions struct {
+	Prefix   string // Topic prefix (default "socketgen")
+	ClientID string // MQTT client ID (default: random for servers, the session for clients)
+	Username string
+	Password string
+	QoS      byte // QoS of every publish and subscription
+}
+
+func (o MQTTOptions) prefix() string {
+	if o.Prefix == "" {
+		return "socketgen"
+	}
+	return o.Prefix
+}
+
+func (o MQTTOptions) topic(session, kind string) string {
+	return o.prefix() + "/" + session + "/" + kind
+}
+
+func (o MQTTOptions) clientOptions(broker, clientID string) *mqtt.ClientOptions {
+	opts := mqtt.NewClientOptions().AddBroker(broker).SetClientID(clientID).SetOrderMatters(true)
+	if o.Username != "" {
+		opts.SetUsername(o.Username).SetPassword(o.Password)
+	}
+	return opts
+}
+
+// MQTTTransport accepts client sessions published to a broker. A session is accepted when its
+// client announces it, or on its first packet.
+type MQTTTransport struct {
+	client mqtt.Client
+	broker string
+	opts   MQTTOptions
+	conns  chan TransportConn
+
+	mu       sync.Mutex
+	sessions map[string]*mqttConn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// ListenMQTT connects to broker (e.g., "tcp://localhost:1883") and accepts the sessions under
+// opts.Prefix. Subscriptions are renewed whenever the client reconnects.
+func ListenMQTT(broker string, opts MQTTOptions) (*MQTTTransport, error) {
+	t := &MQTTTransport{
+		broker:   broker,
+		opts:     opts,
+		conns:    make(chan TransportConn),
+		sessions: map[string]*mqttConn{},
+		closed:   make(chan struct{}),
+	}
+
+	clientID := opts.ClientID
+	if clientID == "" {
+		clientID = "socketgen-server-" + newMQTTID()
+	}
+	subscribed := make(chan error, 1)
+	clientOpts := opts.clientOptions(broker, clientID).SetOnConnectHandler(func(c mqtt.Client) {
+		token := c.SubscribeMultiple(map[string]byte{
+			opts.topic("+", mqttUp):      opts.QoS,
+			opts.topic("+", mqttUpState): opts.QoS,
+		}, t.receive)
+		token.Wait()
+		select {
+		case subscribed <- token.Error():
+		default:
+		}
+	})
+
+	t.client = mqtt.NewClient(clientOpts)
+	if token := t.client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+	if err := <-subscribed; err != nil {
+		t.client.Disconnect(0)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *MQTTTransport) Accept() (TransportConn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-t.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (t *MQTTTransport) Addr() net.Addr {
+	return mqttAddr(t.broker)
+}
+
+func (t *MQTTTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		t.client.Disconnect(250)
+	})
+	return nil
+}
+
+func (t *MQTTTransport) receive(_ mqtt.Client, msg mqtt.Message) {
+	session, kind, ok := strings.Cut(strings.TrimPrefix(msg.Topic(), t.opts.prefix()+"/"), "/")
+	if !ok {
+		return
+	}
+
+	if kind == mqttUpState {
+		state := string(msg.Payload())
+		if state == "open" {
+			t.session(session)
+			return
+		}
+		t.mu.Lock()
+		c := t.sessions[session]
+		t.mu.Unlock()
+		if c != nil {
+			reason := DisconnectClosed
+			if state == DisconnectTimeout.String() {
+				reason = DisconnectTimeout
+			}
+			c.finish(NewDisconnectError(reason, io.EOF), "")
+		}
+		return
+	}
+
+	if c := t.session(session); c != nil {
+		c.deliver(msg.Payload())
+	}
+}
+
+// session returns the connection of a session, accepting it if it is new. It returns nil once
+// the transport is closed.
+func (t *MQTTTransport) session(id string) *mqttConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.sessions[id]; ok {
+		return c
+	}
+	select {
+	case <-t.closed:
+		return nil
+	default:
+	}
+
+	c := newMQTTConn(t.client, t.opts.QoS, mqttAddr(id), t.opts.topic(id, mqttDown), t.opts.topic(id, mqttDownState))
+	c.release = func() {
+		t.mu.Lock()
+		delete(t.sessions, id)
+		t.mu.Unlock()
+	}
+	t.sessions[id] = c
+	go func() {
+		select {
+		case t.conns <- c:
+		case <-t.closed:
+		}
+	}()
+	return c
+}
+
+// DialMQTT joins a broker as the client of session, e.g. for devices and bots. The session must
+// be unique among the clients of the server and must not contain '/', '+', or '#'.
+func DialMQTT(broker, session string, opts MQTTOptions) (TransportConn, error) {
+	if session == "" || strings.ContainsAny(session, "/+#") {
+		return nil, fmt.Errorf("invalid MQTT session %q", session)
+	}
+	clientID := opts.ClientID
+	if clientID == "" {
+		clientID = session
+	}
+
+	c := newMQTTConn(nil, opts.QoS, mqttAddr(broker), opts.topic(session, mqttUp), opts.topic(session, mqttUpState))
+	clientOpts := opts.clientOptions(broker, clientID).
+		SetWill(c.stateTopic, DisconnectTimeout.String(), opts.QoS, false).
+		SetAutoReconnect(false).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			// The broker has published the will, so the server has ended the session too
+			c.finish(NewDisconnectError(DisconnectTimeout, err), "")
+		})
+	client := mqtt.NewClient(clientOpts)
+	c.client = client
+	c.release = func() { client.Disconnect(250) }
+
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+	token := client.SubscribeMultiple(map[string]byte{
+		opts.topic(session, mqttDown):      opts.QoS,
+		opts.topic(session, mqttDownState): opts.QoS,
+	}, func(_ mqtt.Client, msg mqtt.Message) {
+		if strings.HasSuffix(msg.Topic(), "/"+mqttDownState) {
+			c.finish(NewDisconnectError(mqttReason(string(msg.Payload())), io.EOF), "")
+			return
+		}
+		c.deliver(msg.Payload())
+	})
+	if token.Wait() && token.Error() != nil {
+		client.Disconnect(0)
+		return nil, token.Error()
+	}
+	if token := client.Publish(c.stateTopic, opts.QoS, false, "open"); token.Wait() && token.Error() != nil {
+		client.Disconnect(0)
+		return nil, token.Error()
+	}
+	return c, nil
+}
+
+// mqttConn is one end of a session. It publishes packets to topic and its state to stateTopic.
+type mqttConn struct {
+	client     mqtt.Client
+	qos        byte
+	addr       net.Addr
+	topic      string
+	stateTopic string
+	release    func()
+
+	inbox chan []byte
+	done  chan struct{}
+	err   error // Why the connection ended, set before done is closed
+	once  sync.Once
+}
+
+func newMQTTConn(client mqtt.Client, qos byte, addr net.Addr, topic, stateTopic string) *mqttConn {
+	return &mqttConn{
+		client:     client,
+		qos:        qos,
+		addr:       addr,
+		topic:      topic,
+		stateTopic: stateTopic,
+		inbox:      make(chan []byte, MQTTInboxSize),
+		done:       make(chan struct{}),
+	}
+}
+
+func (c *mqttConn) deliver(data []byte) {
+	select {
+	case c.inbox <- data:
+	case <-c.done:
+	}
+}
+
+// finish ends the connection once, publishing state unless it is empty (the peer ended it)
+func (c *mqttConn) finish(err error, state string) {
+	c.once.Do(func() {
+		c.err = err
+		close(c.done)
+		if state != "" {
+			c.client.Publish(c.stateTopic, c.qos, false, state).Wait()
+		}
+		if c.release != nil {
+			c.release()
+		}
+	})
+}
+
+func (c *mqttConn) ReadPacket() ([]byte, error) {
+	// Packets that arrived before the peer ended the session are still delivered
+	select {
+	case data := <-c.inbox:
+		return data, nil
+	default:
+	}
+	select {
+	case data := <-c.inbox:
+		return data, nil
+	case <-c.done:
+		return nil, c.err
+	}
+}
+
+func (c *mqttConn) WritePacket(data []byte) error {
+	select {
+	case <-c.done:
+		return net.ErrClosed
+	default:
+	}
+	token := c.client.Publish(c.topic, c.qos, false, data)
+	token.Wait()
+	return token.Error()
+}
+
+func (c *mqttConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *mqttConn) Close() error {
+	c.finish(net.ErrClosed, DisconnectClosed.String())
+	return nil
+}
+
+// CloseWithReason publishes reason to the session's state topic, then ends the session
+func (c *mqttConn) CloseWithReason(reason DisconnectReason) error {
+	c.finish(net.ErrClosed, reason.String())
+	return nil
+}
+
+// mqttReason parses a DisconnectReason published on a state topic; unknown names are DisconnectClosed
+func mqttReason(name string) DisconnectReason {
+	for r := DisconnectClosed; r.String() != "unknown"; r++ {
+		if r.String() == name {
+			return r
+		}
+	}
+	return DisconnectClosed
+}
+
+// mqttAddr is a broker URL or a session ID
+type mqttAddr string
+
+func (a mqttAddr) Network() string { return "mqtt" }
+func (a mqttAddr) String() string  { return string(a) }
+
+func newMQTTID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+`
+
+const tsMQTTTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import type { IClientOptions, MqttClient } from "mqtt";
+import { IPacketStream } from "./PacketDispatcher";
+import { DisconnectReason } from "./PacketLifecycle";
+
+export interface MQTTStreamOptions {
+  prefix?: string; // Topic prefix (default "socketgen"), as on the server
+  qos?: 0 | 1 | 2; // QoS of every publish and subscription (default 0)
+}
+
+function topic(session: string, kind: string, options: MQTTStreamOptions): string {
+  return (options.prefix ?? "socketgen") + "/" + session + "/" + kind;
+}
+
+// Connect options that make the broker report the session as timed out if the client vanishes.
+// Merge them into the options of mqtt.connect.
+export function mqttConnectOptions(session: string, options: MQTTStreamOptions = {}): IClientOptions {
+  return {
+    will: { topic: topic(session, "up/state", options), payload: DisconnectReason.Timeout, qos: options.qos ?? 0, retain: false },
+  };
+}
+
+// Carries the packets of one session over a connected MQTT.js client
+export class MQTTPacketStream implements IPacketStream {
+  private queue: Uint8Array[] = [];
+  private waiters: { resolve: (data: Uint8Array) => void; reject: (err: Error) => void }[] = [];
+  private closed: Error | null = null;
+  private qos: 0 | 1 | 2;
+
+  // Why the server ended the session, once it did
+  disconnectReason: DisconnectReason | null = null;
+
+  private constructor(private client: MqttClient, private session: string, private options: MQTTStreamOptions) {
+    this.qos = options.qos ?? 0;
+  }
+
+  // Subscribes to the session's topics and announces the session to the server
+  static async open(client: MqttClient, session: string, options: MQTTStreamOptions = {}): Promise<MQTTPacketStream> {
+    const stream = new MQTTPacketStream(client, session, options);
+    const down = topic(session, "down", options);
+    const downState = topic(session, "down/state", options);
+    client.on("message", (t: string, payload: Uint8Array) => {
+      if (t === down) {
+        stream.deliver(new Uint8Array(payload));
+      } else if (t === downState) {
+        stream.end(new TextDecoder().decode(payload) as DisconnectReason);
+      }
+    });
+    client.on("end", () => stream.end(DisconnectReason.Closed));
+    await client.subscribeAsync([down, downState], { qos: stream.qos });
+    await client.publishAsync(topic(session, "up/state", options), "open", { qos: stream.qos });
+    return stream;
+  }
+
+  private deliver(data: Uint8Array) {
+    const waiter = this.waiters.shift();
+    if (waiter) {
+      waiter.resolve(data);
+    } else {
+      this.queue.push(data);
+    }
+  }
+
+  private end(reason: DisconnectReason) {
+    if (this.closed) {
+      return;
+    }
+    this.disconnectReason = reason;
+    this.closed = new Error("mqtt session ended: " + reason);
+    for (const waiter of this.waiters.splice(0)) {
+      waiter.reject(this.closed);
+    }
+  }
+
+  readPacket(): Promise<Uint8Array> {
+    const data = this.queue.shift();
+    if (data) {
+      return Promise.resolve(data);
+    }
+    if (this.closed) {
+      return Promise.reject(this.closed);
+    }
+    return new Promise((resolve, reject) => this.waiters.push({ resolve, reject }));
+  }
+
+  async writePacket(data: Uint8Array): Promise<void> {
+    if (this.closed) {
+      throw this.closed;
+    }
+    await this.client.publishAsync(topic(this.session, "up", this.options), Buffer.from(data), { qos: this.qos });
+  }
+
+  // Tells the server the session is over; the MQTT client stays connected
+  async close(): Promise<void> {
+    if (this.closed) {
+      return;
+    }
+    await this.client.publishAsync(topic(this.session, "up/state", this.options), DisconnectReason.Closed, { qos: this.qos });
+    this.end(DisconnectReason.Closed);
+  }
+}
+`
+
+// GenerateMQTTClient writes MQTTPacketStream.ts, the TypeScript client of the MQTT transport
+func GenerateMQTTClient(result *parser.ParseResult, outDir string) error {
+	if err := writeTemplate(outDir, "MQTTPacketStream.ts", "ts_mqtt", tsMQTTTemplate, nil, result); err != nil {
+		return fmt.Errorf("failed to generate the MQTT client: %w", err)
+	}
+	return nil
+}
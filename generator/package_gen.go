@@ -0,0 +1,311 @@
+package generator
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// A client SDK package is the generated code of one language, copied into the source directory
+// of a package scaffold that records the schema version it was generated from. The source
+// directory is replaced on every run, so it holds generated code only.
+
+const tsPackageTemplate = `{
+  "name": "{{.Name}}",
+  "version": "{{.Version}}",
+  "description": "Client SDK for the {{.PackageName}} protocol, generated by socketgen",
+  "main": "dist/PacketDispatcher.js",
+  "types": "dist/PacketDispatcher.d.ts",
+  "exports": {
+    ".": {
+      "types": "./dist/PacketDispatcher.d.ts",
+      "default": "./dist/PacketDispatcher.js"
+    },
+    "./*": {
+      "types": "./dist/*.d.ts",
+      "default": "./dist/*.js"
+    }
+  },
+  "files": [
+    "dist",
+    "src"
+  ],
+  "scripts": {
+    "build": "tsc",
+    "prepublishOnly": "npm run build"
+  },
+{{- if .Dependencies }}
+  "dependencies": {
+{{- range $i, $dep := .Dependencies }}{{if $i}},{{end}}
+    "{{$dep.Name}}": "{{$dep.Version}}"
+{{- end }}
+  },
+{{- end }}
+  "devDependencies": {
+    "typescript": "^5.4.0"
+  },
+  "socketgen": {
+    "schemaVersion": "{{.SchemaVersion}}"
+  }
+}
+`
+
+const tsConfigTemplate = `{
+  "compilerOptions": {
+    "target": "ES2020",
+    "module": "commonjs",
+    "declaration": true,
+    "esModuleInterop": true,
+    "skipLibCheck": true,
+    "rootDir": "src",
+    "outDir": "dist"
+  },
+  "include": ["src"]
+}
+`
+
+const csharpPackageTemplate = `<Project Sdk="Microsoft.NET.Sdk">
+
+  <PropertyGroup>
+    <TargetFramework>net8.0</TargetFramework>
+    <PackageId>{{.Name}}</PackageId>
+    <Version>{{.Version}}</Version>
+    <Description>Client SDK for the {{.PackageName}} protocol, generated by socketgen</Description>
+    <PackageReadmeFile>README.md</PackageReadmeFile>
+    <SocketgenSchemaVersion>{{.SchemaVersion}}</SocketgenSchemaVersion>
+  </PropertyGroup>
+
+  <ItemGroup>
+    <AssemblyMetadata Include="SocketgenSchemaVersion" Value="$(SocketgenSchemaVersion)" />
+    <None Include="README.md" Pack="true" PackagePath="/" />
+  </ItemGroup>
+{{- if .Dependencies }}
+
+  <ItemGroup>
+{{- range .Dependencies }}
+    <PackageReference Include="{{.Name}}" Version="{{.Version}}" />
+{{- end }}
+  </ItemGroup>
+{{- end }}
+
+</Project>
+`
+
+const dartPackageTemplate = `name: {{.Name}}
+description: Client SDK for the {{.PackageName}} protocol, generated by socketgen.
+version: {{.Version}}
+
+environment:
+  sdk: ">=3.0.0 <4.0.0"
+{{- if .Dependencies }}
+
+dependencies:
+{{- range .Dependencies }}
+  {{.Name}}: {{.Version}}
+{{- end }}
+{{- end }}
+
+socketgen:
+  schema_version: {{.SchemaVersion}}
+`
+
+const packageReadmeTemplate = `# {{.Name}}
+
+Client SDK for the ` + "`{{.PackageName}}`" + ` protocol, schema version ` + "`{{.SchemaVersion}}`" + `.
+
+The code in ` + "`{{.SourceDir}}/`" + ` is generated by socketgen from the protocol's proto file; regenerate it
+instead of editing it. Servers advertise the schema version they run when a client connects, so a
+client built from an older version of this package can tell it is out of date.
+`
+
+// PackageInfo names and versions a client SDK package
+type PackageInfo struct {
+	Name    string
+	Version string
+}
+
+// PackageDependency is a package the generated code imports
+type PackageDependency struct {
+	Name    string
+	Version string
+}
+
+type packageFormat struct {
+	manifest     string                       // File name of the package manifest; * stands for the package name
+	manifestText string                       // Its template
+	extras       map[string]string            // Other scaffold files by name
+	sourceDir    string                       // Where the generated code goes
+	sourceExt    string                       // Extension of the generated code
+	imports      *regexp.Regexp               // Matches the dependencies the code imports in its first group
+	dependencies map[string]PackageDependency // Versions of the known dependencies, by import
+	unknown      string                       // Version required of other imported packages ("": not listed)
+	version      *regexp.Regexp               // Matches the version in the manifest in its first group
+	schema       *regexp.Regexp               // Matches the schema version in the manifest in its first group
+	name         *regexp.Regexp               // Valid package names
+}
+
+var packageFormats = map[string]packageFormat{
+	"ts": {
+		manifest:     "package.json",
+		manifestText: tsPackageTemplate,
+		extras:       map[string]string{"tsconfig.json": tsConfigTemplate},
+		sourceDir:    "src",
+		sourceExt:    ".ts",
+		imports:      regexp.MustCompile(`(?m)^\s*import\s[^;]*?from\s+["']((?:@[^/"']+/)?[^./"'][^/"']*)`),
+		dependencies: map[string]PackageDependency{
+			"protobufjs":         {"protobufjs", "^7.2.5"},
+			"long":               {"long", "^5.2.3"},
+			"@bufbuild/protobuf": {"@bufbuild/protobuf", "^2.2.0"},
+		},
+		unknown: "*",
+		version: regexp.MustCompile(`"version":\s*"([^"]*)"`),
+		schema:  regexp.MustCompile(`"schemaVersion":\s*"([^"]*)"`),
+		name:    regexp.MustCompile(`^(@[a-z0-9~-][a-z0-9._~-]*/)?[a-z0-9~-][a-z0-9._~-]*$`),
+	},
+	"csharp": {
+		manifest:     "*.csproj",
+		manifestText: csharpPackageTemplate,
+		sourceDir:    "src",
+		sourceExt:    ".cs",
+		imports:      regexp.MustCompile(`(?m)^using\s+([A-Za-z0-9_.]+)\s*;`),
+		dependencies: map[string]PackageDependency{
+			"Google.Protobuf":                     {"Google.Protobuf", "3.*"},
+			"Microsoft.AspNetCore.SignalR.Client": {"Microsoft.AspNetCore.SignalR.Client", "8.*"},
+		},
+		version: regexp.MustCompile(`<Version>([^<]*)</Version>`),
+		schema:  regexp.MustCompile(`<SocketgenSchemaVersion>([^<]*)</SocketgenSchemaVersion>`),
+		name:    regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`),
+	},
+	"dart": {
+		manifest:     "pubspec.yaml",
+		manifestText: dartPackageTemplate,
+		sourceDir:    "lib",
+		sourceExt:    ".dart",
+		imports:      regexp.MustCompile(`(?m)^(?:import|export)\s+['"]package:([a-z0-9_]+)/`),
+		dependencies: map[string]PackageDependency{
+			"protobuf": {"protobuf", "^3.1.0"},
+			"fixnum":   {"fixnum", "^1.1.0"},
+		},
+		unknown: "any",
+		version: regexp.MustCompile(`(?m)^version:\s*(\S+)`),
+		schema:  regexp.MustCompile(`(?m)^\s+schema_version:\s*(\S+)`),
+		name:    regexp.MustCompile(`^[a-z][a-z0-9_]*$`),
+	},
+}
+
+// PackageLanguages are the languages GeneratePackage scaffolds client SDK packages for
+func PackageLanguages() []string {
+	return slices.Sorted(maps.Keys(packageFormats))
+}
+
+// PackageSourceExt returns the extension of the generated code of lang that goes into its package
+func PackageSourceExt(lang string) string {
+	return packageFormats[lang].sourceExt
+}
+
+// DefaultPackageName returns the package name of the client SDK of lang, following the naming
+// conventions of its package registry (e.g., packet-client, Packet.Client, packet_client)
+func DefaultPackageName(result *parser.ParseResult, lang string) string {
+	switch lang {
+	case "ts":
+		return strings.ReplaceAll(strings.ToLower(result.PackageName), "_", "-") + "-client"
+	case "csharp":
+		return toPascalCase(result.PackageName) + ".Client"
+	default:
+		return strings.ToLower(result.PackageName) + "_client"
+	}
+}
+
+// ReadPackageVersion returns the version and schema version recorded in the package of lang in
+// dir, or empty strings if there is none yet
+func ReadPackageVersion(lang, dir string, info PackageInfo) (version, schemaVersion string, err error) {
+	format, ok := packageFormats[lang]
+	if !ok {
+		return "", "", fmt.Errorf("packaging is not supported for %s", lang)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, format.manifestFile(info.Name)))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if m := format.version.FindSubmatch(data); m != nil {
+		version = string(m[1])
+	}
+	if m := format.schema.FindSubmatch(data); m != nil {
+		schemaVersion = string(m[1])
+	}
+	return version, schemaVersion, nil
+}
+
+// GeneratePackage writes the client SDK package of lang to dir: the generated code in sources,
+// paths relative to srcDir, goes into the source directory of the package, which is replaced, and
+// the package manifest lists the dependencies the code imports.
+func GeneratePackage(result *parser.ParseResult, lang, dir, srcDir string, sources []string, info PackageInfo) error {
+	format, ok := packageFormats[lang]
+	if !ok {
+		return fmt.Errorf("packaging is not supported for %s", lang)
+	}
+	if !format.name.MatchString(info.Name) {
+		return fmt.Errorf("invalid %s package name %q", lang, info.Name)
+	}
+
+	target := filepath.Join(dir, format.sourceDir)
+	if err := os.RemoveAll(target); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", target, err)
+	}
+	imports := map[string]bool{}
+	for _, rel := range sources {
+		data, err := os.ReadFile(filepath.Join(srcDir, rel))
+		if err != nil {
+			return err
+		}
+		for _, m := range format.imports.FindAllSubmatch(data, -1) {
+			imports[string(m[1])] = true
+		}
+		dst := filepath.Join(target, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dst, err)
+		}
+	}
+
+	var deps []PackageDependency
+	for _, name := range slices.Sorted(maps.Keys(imports)) {
+		if dep, ok := format.dependencies[name]; ok {
+			deps = append(deps, dep)
+		} else if format.unknown != "" && name != info.Name {
+			deps = append(deps, PackageDependency{name, format.unknown})
+		}
+	}
+
+	data := struct {
+		*parser.ParseResult
+		PackageInfo
+		Dependencies []PackageDependency
+		SourceDir    string
+	}{result, info, deps, format.sourceDir}
+
+	if err := writeTemplate(dir, format.manifestFile(info.Name), lang+"_package", format.manifestText, nil, data); err != nil {
+		return err
+	}
+	for name, text := range format.extras {
+		if err := writeTemplate(dir, name, lang+"_package_"+name, text, nil, data); err != nil {
+			return err
+		}
+	}
+	return writeTemplate(dir, "README.md", "package_readme", packageReadmeTemplate, nil, data)
+}
+
+func (f packageFormat) manifestFile(name string) string {
+	return strings.ReplaceAll(f.manifest, "*", name)
+}
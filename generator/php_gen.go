@@ -13,21 +13,32 @@ const phpTemplate = `<?php
 // Code generated by socketgen. DO NOT EDIT.
 namespace {{.PackageName | toPascalCase}};
 
-use {{.PackageName | toPascalCase}}\GamePacket;
+use {{.PackageName | toPascalCase}}\{{.Wrapper}};
 use {{.PackageName | toPascalCase}}\Header;
 {{- range .Payloads }}
-use {{$.PackageName | toPascalCase}}\{{.Name}};
+use {{$.PackageName | toPascalCase}}\{{phpClass .Name}};
 {{- end }}
 
 interface PacketHandler {
 {{- range .Payloads }}
-    public function on{{.Name}}(Header $header, {{.Name}} $msg);
+{{- blockComment "    " .Doc }}
+    public function on{{.Name}}(Header $header, {{phpClass .Name}} $msg);
 {{- end }}
 }
 
 class PacketDispatcher {
+    const SCHEMA_VERSION = '{{.SchemaVersion}}';
+    const SCHEMA_VERSION_HEADER = 'X-Socketgen-Schema';
+    const SCHEMA_SUBPROTOCOL = 'socketgen.{{.SchemaVersion}}';
+
+    public static function checkSchemaVersion(?string $advertised) {
+        if (!empty($advertised) && $advertised !== self::SCHEMA_VERSION) {
+            throw new \RuntimeException("schema version mismatch: peer has $advertised, this build has " . self::SCHEMA_VERSION);
+        }
+    }
+
     public static function dispatch($data, PacketHandler $handler) {
-        $pkt = new GamePacket();
+        $pkt = new {{.Wrapper}}();
         $pkt->mergeFromString($data);
 
         switch ($pkt->getPayload()) {
@@ -52,8 +63,8 @@ class PacketDispatcher {
 
 {{- range .Payloads }}
 
-    public static function send{{.Name}}(PacketStream $stream, Header $header, {{.Name}} $msg) {
-        $pkt = new GamePacket();
+    public static function send{{.Name}}(PacketStream $stream, Header $header, {{phpClass .Name}} $msg) {
+        $pkt = new {{$.Wrapper}}();
         $pkt->setHeader($header);
         $pkt->set{{.Name}}($msg);
         $stream->writePacket($pkt->serializeToString());
@@ -70,6 +81,8 @@ interface PacketStream {
 func GeneratePHP(result *parser.ParseResult, outDir string) error {
 	funcMap := template.FuncMap{
 		"toPascalCase": toPascalCase, // Reusing from csharp_gen.go if in same package, otherwise need to duplicate or move to util
+		"blockComment": blockComment,
+		"phpClass":     phpClass,
 	}
 
 	tmpl, err := template.New("php").Funcs(funcMap).Parse(phpTemplate)
@@ -88,5 +101,8 @@ func GeneratePHP(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	return generateLifecycle(result, "php", outDir)
 }
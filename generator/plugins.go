@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/snowmerak/socketgen/toolchain"
+)
+
+// protocPlugin is a plugin protoc runs to generate the bindings of a language; the bindings of the
+// other languages are built into protoc
+type protocPlugin struct {
+	name    string          // Executable name, e.g. protoc-gen-go
+	install string          // Command installing it
+	dirs    func() []string // Directories it is installed to besides PATH
+}
+
+var protocPlugins = map[string]protocPlugin{
+	"go":   {"protoc-gen-go", "go install google.golang.org/protobuf/cmd/protoc-gen-go@latest", goBinDirs},
+	"ts":   {"protoc-gen-ts_proto", "npm install --save-dev ts-proto", nodeBinDirs},
+	"dart": {"protoc-gen-dart", "dart pub global activate protoc_plugin", pubCacheBinDirs},
+}
+
+// NeedsPlugin reports whether protoc needs a plugin for the bindings of lang, whose path
+// socketgen.yaml can set under plugins
+func NeedsPlugin(lang string) bool {
+	_, ok := protocPlugins[lang]
+	return ok
+}
+
+// findPlugin returns the path of the protoc plugin of lang: override if set (from socketgen.yaml),
+// else the one socketgen toolchain install installed, else the plugin found in PATH or where its
+// package manager installs it. Lookups follow the rules of the OS, so on Windows protoc-gen-go.exe
+// and npm's protoc-gen-ts_proto.cmd shims are found.
+func findPlugin(lang, override string) (string, error) {
+	plugin := protocPlugins[lang]
+	if override != "" {
+		path, err := exec.LookPath(override)
+		if err != nil {
+			return "", fmt.Errorf("%s, set as the %s plugin under plugins.%s in the configuration, is not an executable: %w", override, plugin.name, lang, err)
+		}
+		return filepath.Abs(path)
+	}
+
+	if path := toolchain.Plugin(plugin.name); path != "" {
+		return path, nil
+	}
+	if path, err := exec.LookPath(plugin.name); err == nil {
+		return filepath.Abs(path)
+	}
+	for _, dir := range plugin.dirs() {
+		if path, err := exec.LookPath(filepath.Join(dir, plugin.name)); err == nil {
+			return filepath.Abs(path)
+		}
+	}
+	return "", fmt.Errorf("%s, needed for the %s bindings, is not installed; install it with '%s', or set its path under plugins.%s in the configuration", plugin.name, lang, plugin.install, lang)
+}
+
+// goBinDirs are where go install puts binaries
+func goBinDirs() []string {
+	if gobin := os.Getenv("GOBIN"); gobin != "" {
+		return []string{gobin}
+	}
+	var dirs []string
+	for _, gopath := range filepath.SplitList(os.Getenv("GOPATH")) {
+		dirs = append(dirs, filepath.Join(gopath, "bin"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "go", "bin"))
+	}
+	return dirs
+}
+
+// nodeBinDirs are the node_modules/.bin directories of the working directory and its parents,
+// where npm installs the binaries of a project's dependencies
+func nodeBinDirs() []string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for {
+		dirs = append(dirs, filepath.Join(dir, "node_modules", ".bin"))
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs
+		}
+		dir = parent
+	}
+}
+
+// pubCacheBinDirs are where dart pub global activate puts binaries
+func pubCacheBinDirs() []string {
+	if cache := os.Getenv("PUB_CACHE"); cache != "" {
+		return []string{filepath.Join(cache, "bin")}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{filepath.Join(os.Getenv("LOCALAPPDATA"), "Pub", "Cache", "bin")}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return []string{filepath.Join(home, ".pub-cache", "bin")}
+	}
+	return nil
+}
+
+// pluginFlag passes the plugin at path to protoc explicitly. protoc only looks for plugins in
+// PATH, and on Windows only for .exe files, so this finds the others too.
+func pluginFlag(lang, path string) string {
+	return "--plugin=" + protocPlugins[lang].name + "=" + path
+}
@@ -0,0 +1,251 @@
+package generator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The pooled decode paths read the same length-prefixed frames as the Go stream transports
+// (a 4-byte big-endian length, then the GamePacket), so C# and Java servers can sit behind the
+// same clients. Buffers come from a pool and are returned as soon as the packet is dispatched;
+// decoded messages copy what they keep, so handlers may hold on to them.
+
+const csharpPooledTemplate = `// Code generated by socketgen. DO NOT EDIT.
+using System;
+using System.Buffers;
+using System.Buffers.Binary;
+using System.IO;
+using {{.PackageName | toPascalCase}};
+
+// Knobs of the pooled decode path
+public sealed class ServerConfig {
+    // Larger frames are rejected with an InvalidDataException
+    public int MaxPacketSize { get; set; } = 1 << 20;
+
+    // Rent frame buffers from BufferPool instead of allocating one per packet
+    public bool PooledBuffers { get; set; } = true;
+
+    public ArrayPool<byte> BufferPool { get; set; } = ArrayPool<byte>.Shared;
+}
+
+// Reads length-prefixed packets from a stream into pooled buffers and dispatches them. Packets
+// are parsed straight from the rented span, without a CodedInputStream or an intermediate copy.
+public sealed class PooledPacketReader {
+    private readonly Stream stream;
+    private readonly ServerConfig config;
+    private readonly byte[] sizeBuffer = new byte[4];
+
+    public PooledPacketReader(Stream stream, ServerConfig config = null) {
+        this.stream = stream;
+        this.config = config ?? new ServerConfig();
+    }
+
+    // Reads and dispatches one packet. Returns false when the stream ends between packets.
+    public bool ReadAndDispatch(IPacketHandler handler) {
+        if (!ReadExactly(sizeBuffer, 4)) {
+            return false;
+        }
+        int size = BinaryPrimitives.ReadInt32BigEndian(sizeBuffer);
+        if (size < 0 || size > config.MaxPacketSize) {
+            throw new InvalidDataException($"frame too large: {(uint)size} bytes");
+        }
+
+        byte[] buffer = config.PooledBuffers ? config.BufferPool.Rent(size) : new byte[size];
+        try {
+            if (!ReadExactly(buffer, size)) {
+                throw new EndOfStreamException("stream ended inside a frame");
+            }
+            var pkt = {{.Wrapper}}.Parser.ParseFrom(new ReadOnlySpan<byte>(buffer, 0, size));
+            PacketDispatcher.DispatchPacket(pkt, handler);
+        } finally {
+            if (config.PooledBuffers) {
+                config.BufferPool.Return(buffer);
+            }
+        }
+        return true;
+    }
+
+    // Dispatches packets until the stream ends
+    public void Serve(IPacketHandler handler) {
+        while (ReadAndDispatch(handler)) {
+        }
+    }
+
+    private bool ReadExactly(byte[] buffer, int count) {
+        int read = 0;
+        while (read < count) {
+            int n = stream.Read(buffer, read, count - read);
+            if (n == 0) {
+                if (read == 0) {
+                    return false;
+                }
+                throw new EndOfStreamException("stream ended inside a frame");
+            }
+            read += n;
+        }
+        return true;
+    }
+}
+`
+
+const javaServerConfigTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+import io.netty.buffer.ByteBufAllocator;
+import io.netty.buffer.PooledByteBufAllocator;
+
+/** Knobs of the pooled decode paths. */
+public final class ServerConfig {
+    /** Larger frames are rejected. */
+    public int maxPacketSize = 1 << 20;
+
+    /** Allocator of Netty frame buffers; the pooled allocator avoids a buffer per packet. */
+    public ByteBufAllocator allocator = PooledByteBufAllocator.DEFAULT;
+
+    /** Decode every packet of a connection with one reused {{.Wrapper}}.Builder. */
+    public boolean reuseBuilders = true;
+
+    /** Buffer size of the CodedInputStream that PooledPacketReader keeps per connection. */
+    public int inputBufferSize = 8192;
+}
+`
+
+const javaPooledReaderTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+import com.google.protobuf.CodedInputStream;
+import com.google.protobuf.InvalidProtocolBufferException;
+import java.io.IOException;
+import java.io.InputStream;
+
+/**
+ * Reads length-prefixed packets from a blocking stream and dispatches them. One CodedInputStream
+ * is kept for the whole connection and each frame is decoded in place under a push limit, so no
+ * byte[] is allocated per packet.
+ */
+public final class PooledPacketReader {
+    private final CodedInputStream input;
+    private final ServerConfig config;
+    private final {{.Wrapper}}.Builder builder = {{.Wrapper}}.newBuilder();
+
+    public PooledPacketReader(InputStream stream, ServerConfig config) {
+        this.config = config != null ? config : new ServerConfig();
+        this.input = CodedInputStream.newInstance(stream, this.config.inputBufferSize);
+        this.input.setSizeLimit(Integer.MAX_VALUE);
+    }
+
+    /** Reads and dispatches one packet. Returns false when the stream ends between packets. */
+    public boolean readAndDispatch(PacketHandler handler) throws IOException {
+        if (input.isAtEnd()) {
+            return false;
+        }
+        int size = (input.readRawByte() & 0xff) << 24
+            | (input.readRawByte() & 0xff) << 16
+            | (input.readRawByte() & 0xff) << 8
+            | (input.readRawByte() & 0xff);
+        if (size < 0 || size > config.maxPacketSize) {
+            throw new InvalidProtocolBufferException("frame too large: " + Integer.toUnsignedString(size) + " bytes");
+        }
+
+        int oldLimit = input.pushLimit(size);
+        {{.Wrapper}} pkt;
+        if (config.reuseBuilders) {
+            pkt = builder.clear().mergeFrom(input).build();
+        } else {
+            pkt = {{.Wrapper}}.parseFrom(input);
+        }
+        input.checkLastTagWas(0);
+        input.popLimit(oldLimit);
+        input.resetSizeCounter();
+
+        PacketDispatcher.dispatchPacket(pkt, handler);
+        return true;
+    }
+
+    /** Dispatches packets until the stream ends. */
+    public void serve(PacketHandler handler) throws IOException {
+        while (readAndDispatch(handler)) {
+        }
+    }
+}
+`
+
+const javaNettyHandlerTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+import com.google.protobuf.CodedInputStream;
+import io.netty.buffer.ByteBuf;
+import io.netty.channel.ChannelHandlerContext;
+import io.netty.channel.ChannelPipeline;
+import io.netty.channel.SimpleChannelInboundHandler;
+import io.netty.handler.codec.LengthFieldBasedFrameDecoder;
+
+/**
+ * Netty handler that decodes length-prefixed packets from pooled ByteBufs and dispatches them.
+ * Frames are decoded from the buffer's own memory and released right after dispatch.
+ */
+public final class PacketFrameHandler extends SimpleChannelInboundHandler<ByteBuf> {
+    private final PacketHandler handler;
+    private final ServerConfig config;
+    private final {{.Wrapper}}.Builder builder = {{.Wrapper}}.newBuilder();
+
+    public PacketFrameHandler(PacketHandler handler, ServerConfig config) {
+        super(true); // Release every frame after channelRead0
+        this.handler = handler;
+        this.config = config != null ? config : new ServerConfig();
+    }
+
+    /** Adds the frame decoder and a PacketFrameHandler to pipeline, and makes the channel use the configured allocator. */
+    public static void install(ChannelPipeline pipeline, PacketHandler handler, ServerConfig config) {
+        ServerConfig cfg = config != null ? config : new ServerConfig();
+        pipeline.channel().config().setAllocator(cfg.allocator);
+        pipeline.addLast("socketgen-frames", new LengthFieldBasedFrameDecoder(cfg.maxPacketSize + 4, 0, 4, 0, 4));
+        pipeline.addLast("socketgen-packets", new PacketFrameHandler(handler, cfg));
+    }
+
+    @Override
+    protected void channelRead0(ChannelHandlerContext ctx, ByteBuf frame) throws Exception {
+        CodedInputStream input = CodedInputStream.newInstance(frame.nioBuffer());
+        {{.Wrapper}} pkt;
+        if (config.reuseBuilders) {
+            pkt = builder.clear().mergeFrom(input).build();
+        } else {
+            pkt = {{.Wrapper}}.parseFrom(input);
+        }
+        PacketDispatcher.dispatchPacket(pkt, handler);
+    }
+}
+`
+
+// pooledFiles maps each language to its pooled decode files and templates
+var pooledFiles = map[string][]struct {
+	fileName string
+	text     string
+}{
+	"csharp": {{"PooledPacketReader.cs", csharpPooledTemplate}},
+	"java": {
+		{"ServerConfig.java", javaServerConfigTemplate},
+		{"PooledPacketReader.java", javaPooledReaderTemplate},
+		{"PacketFrameHandler.java", javaNettyHandlerTemplate},
+	},
+}
+
+// GeneratePooled writes decode paths into pooled buffers for high-throughput lang servers
+func GeneratePooled(result *parser.ParseResult, lang string, outDir string) error {
+	files, ok := pooledFiles[lang]
+	if !ok {
+		return fmt.Errorf("pooled decoding is not supported for %s", lang)
+	}
+
+	funcMap := template.FuncMap{
+		"toPascalCase": toPascalCase,
+	}
+	for _, f := range files {
+		if err := writeTemplate(outDir, f.fileName, lang+"_pooled", f.text, funcMap, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,18 +1,58 @@
 package generator
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/socketgen/options"
+	"github.com/snowmerak/socketgen/toolchain"
 )
 
-// GenerateProtoc runs the protoc command for the specified languages
-func GenerateProtoc(protoFile string, languages []string, outDir string) error {
+// GenerateProtoc runs the protoc command for the specified languages. The runs write different
+// files, so they run at once; the errors of all failed runs are joined. Progress is written to log.
+// plugins maps languages to the paths of their protoc plugins, overriding the ones found. Imports are
+// resolved from importPaths, passed as -I, or from the working directory if there are none.
+func GenerateProtoc(protoFile string, importPaths []string, languages []string, outDir string, plugins map[string]string, log io.Writer) error {
+	protoc := toolchain.Protoc()
+	if _, err := exec.LookPath(protoc); err != nil {
+		return fmt.Errorf("protoc is not installed or not in PATH; install it with 'socketgen toolchain install', or see https://grpc.io/docs/protoc-installation/")
+	}
+
 	// Ensure output directory exists
 	if err := os.MkdirAll(outDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// Schemas using socketgen's custom options import socketgen/options.proto, so its bindings are
+	// needed too. Go is the exception: the Go bindings ship in github.com/snowmerak/socketgen/options.
+	dirs := importPaths
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var optionsFile []string
+	for _, dir := range dirs {
+		path := filepath.Join(dir, options.ImportPath)
+		if _, err := os.Stat(path); err == nil {
+			optionsFile = []string{path}
+			break
+		}
+	}
+	var include []string
+	for _, dir := range importPaths {
+		include = append(include, "-I"+dir)
+	}
+
+	var cmds []*exec.Cmd
+	var langs []string
+	var missing []error
 	for _, lang := range languages {
 		var args []string
 
@@ -68,10 +108,13 @@ func GenerateProtoc(protoFile string, languages []string, outDir string) error {
 			// Requires protoc-gen-kotlin and usually java_out as well since Kotlin generated code depends on Java
 			// We will generate both java and kotlin code in the output directory
 			args = []string{
-				"--java_out=" + outDir,
 				"--kotlin_out=" + outDir,
 				protoFile,
 			}
+			// The java run writes the Java code if it is selected too
+			if !slices.Contains(languages, "java") {
+				args = append([]string{"--java_out=" + outDir}, args...)
+			}
 		case "java":
 			// Built-in support
 			args = []string{
@@ -81,16 +124,50 @@ func GenerateProtoc(protoFile string, languages []string, outDir string) error {
 		default:
 			continue
 		}
+		if lang != "go" {
+			args = append(args, optionsFile...)
+		}
+		args = append(slices.Clone(include), args...)
+		if NeedsPlugin(lang) {
+			path, err := findPlugin(lang, plugins[lang])
+			if err != nil {
+				missing = append(missing, err)
+				continue
+			}
+			args = append([]string{pluginFlag(lang, path)}, args...)
+		}
 
-		cmd := exec.Command("protoc", args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmds = append(cmds, exec.Command(protoc, args...))
+		langs = append(langs, lang)
+	}
 
-		fmt.Printf("Running protoc for %s: %s\n", lang, cmd.String())
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to generate protobuf code for %s: %w", lang, err)
-		}
+	// Missing plugins are reported together, before any protoc run
+	if len(missing) > 0 {
+		return errors.Join(missing...)
+	}
+
+	for i, cmd := range cmds {
+		fmt.Fprintf(log, "Running protoc for %s: %s\n", langs[i], cmd.String())
+	}
+
+	errs := make([]error, len(cmds))
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		wg.Go(func() {
+			start := time.Now()
+			// Output is collected so the runs do not interleave it
+			output, err := cmd.CombinedOutput()
+			if output = bytes.TrimSpace(output); len(output) > 0 {
+				output = append([]byte("\n"), output...)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to generate protobuf code for %s: %w%s", langs[i], err, output)
+				return
+			}
+			fmt.Fprintf(log, "protoc for %s finished in %s%s\n", langs[i], time.Since(start).Round(time.Millisecond), output)
+		})
 	}
+	wg.Wait()
 
-	return nil
+	return errors.Join(errs...)
 }
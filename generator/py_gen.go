@@ -11,24 +11,36 @@ import (
 
 const pyTemplate = `# Code generated by socketgen. DO NOT EDIT.
 from abc import ABC, abstractmethod
-from .packet_pb2 import GamePacket
+from .packet_pb2 import {{.Wrapper}}
+
+SCHEMA_VERSION = "{{.SchemaVersion}}"
+SCHEMA_VERSION_HEADER = "X-Socketgen-Schema"
+SCHEMA_SUBPROTOCOL = "socketgen." + SCHEMA_VERSION
+
+class SchemaMismatchError(Exception):
+    pass
+
+def check_schema_version(advertised):
+    if advertised and advertised != SCHEMA_VERSION:
+        raise SchemaMismatchError(f"schema version mismatch: peer has {advertised}, this build has {SCHEMA_VERSION}")
 
 class PacketHandler(ABC):
 {{- range .Payloads }}
     @abstractmethod
     def on_{{.FieldName}}(self, header, msg):
+{{- pyDocstring "        " .Doc }}
         pass
 {{- end }}
 
 def dispatch(data: bytes, handler: PacketHandler):
-    pkt = GamePacket()
+    pkt = {{.Wrapper}}()
     pkt.ParseFromString(data)
     
     type_str = pkt.WhichOneof('payload')
     
 {{- range $i, $p := .Payloads }}
     {{if eq $i 0}}if{{else}}elif{{end}} type_str == '{{.FieldName}}':
-        handler.on_{{.FieldName}}(pkt.header, pkt.{{.FieldName}})
+        handler.on_{{.FieldName}}(pkt.header, {{pyAttr "pkt" .FieldName}})
 {{- end }}
 
 class PacketStream(ABC):
@@ -51,15 +63,15 @@ def serve(stream: PacketStream, handler: PacketHandler):
 {{- range .Payloads }}
 
 def send_{{.FieldName}}(stream: PacketStream, header, msg):
-    pkt = GamePacket()
+    pkt = {{$.Wrapper}}()
     pkt.header.CopyFrom(header)
-    pkt.{{.FieldName}}.CopyFrom(msg)
+    {{pyAttr "pkt" .FieldName}}.CopyFrom(msg)
     stream.write_packet(pkt.SerializeToString())
 {{- end }}
 `
 
 func GeneratePython(result *parser.ParseResult, outDir string) error {
-	tmpl, err := template.New("python").Parse(pyTemplate)
+	tmpl, err := template.New("python").Funcs(template.FuncMap{"pyDocstring": pyDocstring, "pyAttr": pyAttr}).Parse(pyTemplate)
 	if err != nil {
 		return fmt.Errorf("failed to parse python template: %w", err)
 	}
@@ -75,5 +87,8 @@ func GeneratePython(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	return generateLifecycle(result, "python", outDir)
 }
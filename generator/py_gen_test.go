@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPythonCompiles(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+
+	result, _ := parseSchema(t, testSchema)
+	dir := t.TempDir()
+	cfg := loadConfig(t, extrasConfig)
+	for _, generate := range []func() error{
+		func() error { return GeneratePython(result, dir) },
+		func() error { return GenerateEndpoints(result, cfg.Endpoints, "", "python", dir) },
+		func() error { return GenerateVectorTests(result, "python", dir) },
+	} {
+		if err := generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Errorf("got Python files %v, want the dispatcher, lifecycle, endpoints and vector tests", files)
+	}
+	// Syntax only: the protobuf runtime and the protoc bindings are not needed
+	cmd := exec.Command(python, append([]string{"-m", "py_compile"}, files...)...)
+	cmd.Env = append(os.Environ(), "PYTHONPYCACHEPREFIX="+t.TempDir())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("py_compile: %v\n%s", err, out)
+	}
+}
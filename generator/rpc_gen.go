@@ -0,0 +1,390 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goRPCTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"context"
+	"errors"
+	"fmt"
+{{- if .HasPagination }}
+	"iter"
+{{- end }}
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrClientClosed is returned by RPCClient calls after Run has returned
+var ErrClientClosed = errors.New("rpc client closed")
+
+// RPCClient sends requests annotated with socketgen.responds_with and waits for their
+// responses, matched by Header.request_id. Run must be running to receive responses.
+type RPCClient struct {
+	stream PacketStream
+
+	// OnPacket receives packets that do not answer a pending call (e.g., notifications). It may be nil.
+	OnPacket func(pkt *{{.Wrapper}})
+
+	mu      sync.Mutex
+	pending map[string]chan *{{.Wrapper}}
+	seq     uint64
+	err     error
+}
+
+// NewRPCClient returns a client sending on stream
+func NewRPCClient(stream PacketStream) *RPCClient {
+	return &RPCClient{stream: stream, pending: map[string]chan *{{.Wrapper}}{}}
+}
+
+// Run reads packets until the stream fails. Pending and later calls then fail with the stream error.
+func (c *RPCClient) Run() error {
+	for {
+		data, err := c.stream.ReadPacket()
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			return err
+		}
+
+		pkt := &{{.Wrapper}}{}
+		if err := proto.Unmarshal(data, pkt); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[pkt.GetHeader().GetRequestId()]
+		if ok {
+			delete(c.pending, pkt.GetHeader().GetRequestId())
+		}
+		c.mu.Unlock()
+
+		if ok {
+			ch <- pkt
+		} else if c.OnPacket != nil {
+			c.OnPacket(pkt)
+		}
+	}
+}
+
+// call sends pkt with a request_id (generated if the header has none) and waits for the response
+func (c *RPCClient) call(ctx context.Context, pkt *{{.Wrapper}}) (*{{.Wrapper}}, error) {
+	if pkt.Header == nil {
+		pkt.Header = &Header{}
+	} else {
+		pkt.Header = proto.Clone(pkt.Header).(*Header)
+	}
+
+	ch := make(chan *{{.Wrapper}}, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: %v", ErrClientClosed, c.err)
+	}
+	if pkt.Header.RequestId == "" {
+		c.seq++
+		pkt.Header.RequestId = "rpc-" + strconv.FormatUint(c.seq, 10)
+	}
+	id := pkt.Header.RequestId
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	cancel := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	data, err := proto.Marshal(pkt)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := c.stream.WritePacket(data); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			return nil, ErrClientClosed
+		}
+		return res, nil
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+}
+{{- range .Calls }}
+
+// {{.Method}}{{if .Page}}Page{{end}} sends {{.Request.Name}} and waits for {{.Response.Name}}. A protocol error is returned
+// when the server answers with ErrorRes; err reports transport failures and timeouts.
+func (c *RPCClient) {{.Method}}{{if .Page}}Page{{end}}(ctx context.Context, header *Header, msg *{{.Request.Name}}) (*{{.Response.Name}}, *ProtocolError, error) {
+	res, err := c.call(ctx, &{{$.Wrapper}}{Header: header, Payload: &{{$.Wrapper}}_{{.Request.Name}}{ {{- .Request.Name}}: msg}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch payload := res.Payload.(type) {
+	case *{{$.Wrapper}}_{{.Response.Name}}:
+		return payload.{{.Response.Name}}, nil, nil
+	case *{{$.Wrapper}}_ErrorRes:
+		return nil, ProtocolErrorFrom(payload.ErrorRes), nil
+	}
+	return nil, nil, fmt.Errorf("unexpected response %s to {{.Request.FieldName}}", PayloadName(res))
+}
+{{- if .Page }}
+
+// {{.Method}} iterates over the items of every page, following next_cursor from msg's cursor.
+// A protocol error or transport failure ends the iteration with a non-nil error.
+func (c *RPCClient) {{.Method}}(ctx context.Context, header *Header, msg *{{.Request.Name}}) iter.Seq2[{{.Page.GoType}}, error] {
+	return func(yield func({{.Page.GoType}}, error) bool) {
+		req := proto.Clone(msg).(*{{.Request.Name}})
+		for {
+			res, perr, err := c.{{.Method}}Page(ctx, header, req)
+			if perr != nil {
+				err = perr
+			}
+			if err != nil {
+				var zero {{.Page.GoType}}
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range res.{{.Page.Field | goFieldName}} {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if res.NextCursor == "" {
+				return
+			}
+			req.Cursor = res.NextCursor
+		}
+	}
+}
+{{- end }}
+{{- end }}
+`
+
+const tsRPCTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+import { ProtocolError, protocolErrorOf } from "./PacketErrors";
+
+const { {{.Wrapper}} } = {{.PackageName}};
+type {{.Wrapper}} = {{.PackageName}}.{{.Wrapper}};
+type Header = {{.PackageName}}.Header;
+{{- range .Types }}
+type {{.}} = {{$.PackageName}}.{{.}};
+{{- end }}
+
+/** The outcome of a call: the response, or the protocol error the server answered with */
+export type Result<T> = { ok: true; value: T } | { ok: false; error: ProtocolError };
+
+/**
+ * Sends requests annotated with socketgen.responds_with and resolves their responses,
+ * matched by Header.requestId. Pass every received packet to receive().
+ */
+export class RpcClient {
+  /** Receives packets that do not answer a pending call (e.g., notifications) */
+  onPacket?: (pkt: {{.Wrapper}}) => void;
+
+  private readonly pending = new Map<string, (pkt: {{.Wrapper}}) => void>();
+  private seq = 0;
+
+  constructor(
+    private readonly send: (data: Uint8Array) => void | Promise<void>,
+    private readonly timeoutMs = 10000,
+  ) {}
+
+  receive(data: Uint8Array): void {
+    const pkt = {{.Wrapper}}.decode(data);
+    const id = pkt.header?.requestId ?? "";
+    const resolve = this.pending.get(id);
+    if (resolve) {
+      this.pending.delete(id);
+      resolve(pkt);
+    } else {
+      this.onPacket?.(pkt);
+    }
+  }
+
+  private async call(header: Partial<Header> | undefined, payload: Partial<{{.Wrapper}}>): Promise<{{.Wrapper}}> {
+    const requestId = header?.requestId || "rpc-" + ++this.seq;
+    const pkt = {{.Wrapper}}.fromPartial({ ...payload, header: { ...header, requestId } });
+
+    const response = new Promise<{{.Wrapper}}>((resolve, reject) => {
+      const timer = setTimeout(() => {
+        this.pending.delete(requestId);
+        reject(new Error("request " + requestId + " timed out"));
+      }, this.timeoutMs);
+      this.pending.set(requestId, (res) => {
+        clearTimeout(timer);
+        resolve(res);
+      });
+    });
+
+    await this.send({{.Wrapper}}.encode(pkt).finish());
+    return response;
+  }
+{{- range .Calls }}
+
+  async {{.Method | toCamelCase}}{{if .Page}}Page{{end}}(msg: {{.Request.Name}}, header?: Partial<Header>): Promise<Result<{{.Response.Name}}>> {
+    const pkt = await this.call(header, { {{.Request.FieldName | toCamelCase}}: msg });
+    if (pkt.{{.Response.FieldName | toCamelCase}}) {
+      return { ok: true, value: pkt.{{.Response.FieldName | toCamelCase}} };
+    }
+    const error = protocolErrorOf(pkt);
+    if (error) {
+      return { ok: false, error };
+    }
+    throw new Error("unexpected response to {{.Request.FieldName}}");
+  }
+{{- if .Page }}
+
+  /** Iterates over the items of every page, following nextCursor. Throws the ProtocolError if a page fails. */
+  async *{{.Method | toCamelCase}}(msg: {{.Request.Name}}, header?: Partial<Header>): AsyncGenerator<{{.Page.TSType}}> {
+    let cursor = msg.cursor;
+    for (;;) {
+      const result = await this.{{.Method | toCamelCase}}Page({ ...msg, cursor }, header);
+      if (!result.ok) {
+        throw result.error;
+      }
+      yield* result.value.{{.Page.Field | toCamelCase}};
+      if (!result.value.nextCursor) {
+        return;
+      }
+      cursor = result.value.nextCursor;
+    }
+  }
+{{- end }}
+{{- end }}
+}
+`
+
+// rpcCall is a request/response pair annotated with socketgen.responds_with
+type rpcCall struct {
+	Method   string // Client method name (e.g., "Login" for LoginReq)
+	Request  *parser.PayloadMessage
+	Response *parser.PayloadMessage
+	Page     *rpcPage // Set for paginated requests
+}
+
+// rpcPage describes the items field of a paginated response
+type rpcPage struct {
+	Field  string // Field name (e.g., "items")
+	GoType string // Go element type
+	TSType string // TypeScript element type
+}
+
+// pageFor describes the items of a paginated call's response
+func pageFor(result *parser.ParseResult, res *parser.PayloadMessage) *rpcPage {
+	items := res.PageItems()
+	page := &rpcPage{Field: items.Name, GoType: goScalarTypes[items.Kind], TSType: tsScalarTypes[items.Kind]}
+
+	rel := strings.TrimPrefix(items.TypeName, result.PackageName+".")
+	switch items.Kind {
+	case "message":
+		page.GoType = "*" + strings.ReplaceAll(rel, ".", "_")
+		page.TSType = result.PackageName + "." + rel
+	case "enum":
+		page.GoType = strings.ReplaceAll(rel, ".", "_")
+		page.TSType = result.PackageName + "." + rel
+	}
+	return page
+}
+
+// rpcData lists the calls for the RPC client templates
+type rpcData struct {
+	*parser.ParseResult
+	Calls []rpcCall
+	Types []string // Payload types the client uses
+}
+
+// rpcCallsFor collects the annotated request/response pairs. It returns nil if there are none.
+func rpcCallsFor(result *parser.ParseResult) (*rpcData, error) {
+	data := &rpcData{ParseResult: result}
+	seen := map[string]bool{}
+	for i := range result.Payloads {
+		req := &result.Payloads[i]
+		if req.RespondsWith == "" {
+			continue
+		}
+		res := result.Payload(req.RespondsWith)
+		if res == nil {
+			return nil, fmt.Errorf("%s responds with %s, which is not a payload", req.Name, req.RespondsWith)
+		}
+
+		call := rpcCall{Method: rpcMethodName(req.Name), Request: req, Response: res}
+		if req.MaxPageSize > 0 {
+			call.Page = pageFor(result, res)
+		}
+		data.Calls = append(data.Calls, call)
+		for _, name := range []string{req.Name, res.Name} {
+			if !seen[name] {
+				seen[name] = true
+				data.Types = append(data.Types, name)
+			}
+		}
+	}
+	if len(data.Calls) == 0 {
+		return nil, nil
+	}
+
+	errs, err := errorsFor(result)
+	if err != nil {
+		return nil, err
+	}
+	if errs == nil {
+		return nil, fmt.Errorf("RPC clients need the ErrorRes payload")
+	}
+	return data, nil
+}
+
+// rpcMethodName derives a client method name from a request type: LoginReq -> Login
+func rpcMethodName(name string) string {
+	method := strings.TrimSuffix(strings.TrimSuffix(name, "Req"), "Request")
+	if method == "" || method == "Run" || method == "Receive" {
+		return name
+	}
+	return method
+}
+
+// generateGoRPC writes packet_rpc.go when the schema annotates request/response pairs
+func generateGoRPC(result *parser.ParseResult, outDir string) error {
+	data, err := rpcCallsFor(result)
+	if data == nil {
+		return err
+	}
+	funcMap := template.FuncMap{
+		"goFieldName": goFieldName,
+	}
+	return writeTemplate(outDir, "packet_rpc.go", "go_rpc", goRPCTemplate, funcMap, data)
+}
+
+// generateTSRPC writes PacketClient.ts when the schema annotates request/response pairs
+func generateTSRPC(result *parser.ParseResult, outDir string) error {
+	data, err := rpcCallsFor(result)
+	if data == nil {
+		return err
+	}
+	funcMap := template.FuncMap{
+		"toCamelCase": toCamelCase,
+	}
+	return writeTemplate(outDir, "PacketClient.ts", "ts_rpc", tsRPCTemplate, funcMap, data)
+}
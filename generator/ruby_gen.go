@@ -13,13 +13,23 @@ const rubyTemplate = `# Code generated by socketgen. DO NOT EDIT.
 require 'packet_pb'
 
 module PacketDispatcher
+  SCHEMA_VERSION = '{{.SchemaVersion}}'
+  SCHEMA_VERSION_HEADER = 'X-Socketgen-Schema'
+  SCHEMA_SUBPROTOCOL = "socketgen.#{SCHEMA_VERSION}"
+
+  def self.check_schema_version(advertised)
+    if advertised && !advertised.empty? && advertised != SCHEMA_VERSION
+      raise "schema version mismatch: peer has #{advertised}, this build has #{SCHEMA_VERSION}"
+    end
+  end
+
   def self.dispatch(data, handler)
-    pkt = {{.PackageName | toPascalCase}}::GamePacket.decode(data)
+    pkt = {{.PackageName | toPascalCase}}::{{.Wrapper}}.decode(data)
     
     case pkt.payload
 {{- range .Payloads }}
     when :{{.FieldName}}
-      handler.on_{{.FieldName}}(pkt.header, pkt.{{.FieldName}})
+      handler.on_{{.FieldName}}(pkt.header, {{rubyAttr "pkt" .FieldName}})
 {{- end }}
     end
   end
@@ -38,11 +48,11 @@ module PacketDispatcher
 {{- range .Payloads }}
 
   def self.send_{{.FieldName}}(stream, header, msg)
-    pkt = {{$.PackageName | toPascalCase}}::GamePacket.new(
+    pkt = {{$.PackageName | toPascalCase}}::{{$.Wrapper}}.new(
       header: header,
       {{.FieldName}}: msg
     )
-    stream.write_packet({{$.PackageName | toPascalCase}}::GamePacket.encode(pkt))
+    stream.write_packet({{$.PackageName | toPascalCase}}::{{$.Wrapper}}.encode(pkt))
   end
 {{- end }}
 end
@@ -56,6 +66,7 @@ end
 # Interface documentation for PacketHandler
 # class PacketHandler
 {{- range .Payloads }}
+{{- lineComment "#   # " .Doc }}
 #   def on_{{.FieldName}}(header, msg); end
 {{- end }}
 # end
@@ -64,6 +75,8 @@ end
 func GenerateRuby(result *parser.ParseResult, outDir string) error {
 	funcMap := template.FuncMap{
 		"toPascalCase": toPascalCase,
+		"lineComment":  lineComment,
+		"rubyAttr":     rubyAttr,
 	}
 
 	tmpl, err := template.New("ruby").Funcs(funcMap).Parse(rubyTemplate)
@@ -82,5 +95,8 @@ func GenerateRuby(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	return generateLifecycle(result, "ruby", outDir)
 }
@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/config"
+	"github.com/snowmerak/socketgen/parser"
+)
+
+const goSessionTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import "sync"
+
+// Session holds the typed per-connection values declared in socketgen.yaml.
+// It is safe for concurrent use.
+type Session struct {
+	mu sync.RWMutex
+{{- range .Session }}
+
+	v{{.Name | toGoName}}   {{.Type | goType}}
+	has{{.Name | toGoName}} bool
+{{- end }}
+}
+{{- range .Session }}
+
+// {{.Name | toGoName}} returns the session's {{.Name}} and whether it is set
+func (s *Session) {{.Name | toGoName}}() ({{.Type | goType}}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v{{.Name | toGoName}}, s.has{{.Name | toGoName}}
+}
+
+// Set{{.Name | toGoName}} sets the session's {{.Name}}
+func (s *Session) Set{{.Name | toGoName}}(v {{.Type | goType}}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v{{.Name | toGoName}}, s.has{{.Name | toGoName}} = v, true
+}
+
+// Clear{{.Name | toGoName}} unsets the session's {{.Name}}
+func (s *Session) Clear{{.Name | toGoName}}() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var zero {{.Type | goType}}
+	s.v{{.Name | toGoName}}, s.has{{.Name | toGoName}} = zero, false
+}
+{{- end }}
+`
+
+const csharpSessionTemplate = `// Code generated by socketgen. DO NOT EDIT.
+#nullable enable
+
+/// <summary>Typed per-connection values declared in socketgen.yaml. Null means unset.</summary>
+public sealed class Session {
+    private readonly object _lock = new object();
+{{- range .Session }}
+    private {{.Type | csharpType}}? _{{.Name | toCamelCase}};
+{{- end }}
+{{- range .Session }}
+
+    public {{.Type | csharpType}}? {{.Name | toPascalCase}} {
+        get { lock (_lock) { return _{{.Name | toCamelCase}}; } }
+        set { lock (_lock) { _{{.Name | toCamelCase}} = value; } }
+    }
+{{- end }}
+}
+`
+
+const tsSessionTemplate = `// Code generated by socketgen. DO NOT EDIT.
+
+/** Typed per-connection values declared in socketgen.yaml. undefined means unset. */
+export class Session {
+{{- range .Session }}
+  {{.Name | toCamelCase}}?: {{.Type | tsType}};
+{{- end }}
+
+  /** Unsets every value */
+  clear(): void {
+{{- range .Session }}
+    this.{{.Name | toCamelCase}} = undefined;
+{{- end }}
+  }
+}
+`
+
+// sessionTemplates maps each language with a server output to its session file name and template
+var sessionTemplates = map[string]struct {
+	fileName string
+	text     string
+}{
+	"go":     {"packet_session.go", goSessionTemplate},
+	"csharp": {"Session.cs", csharpSessionTemplate},
+	"ts":     {"Session.ts", tsSessionTemplate},
+}
+
+// GenerateSession writes the typed session accessors declared in socketgen.yaml for lang
+func GenerateSession(result *parser.ParseResult, fields config.SessionFields, lang string, outDir string) error {
+	tmpl, ok := sessionTemplates[lang]
+	if !ok {
+		return fmt.Errorf("typed sessions are not supported for %s", lang)
+	}
+
+	funcMap := template.FuncMap{
+		"toGoName":     toGoName,
+		"toCamelCase":  toCamelCase,
+		"toPascalCase": toPascalCase,
+		"goType":       func(t string) string { return goScalarTypes[t] },
+		"csharpType":   func(t string) string { return csharpScalarTypes[t] },
+		"tsType":       func(t string) string { return tsScalarTypes[t] },
+	}
+	data := struct {
+		*parser.ParseResult
+		Session config.SessionFields
+	}{result, fields}
+
+	return writeTemplate(outDir, tmpl.fileName, lang+"_session", tmpl.text, funcMap, data)
+}
+
+var goScalarTypes = map[string]string{
+	"string": "string", "bytes": "[]byte", "bool": "bool",
+	"int32": "int32", "int64": "int64", "uint32": "uint32", "uint64": "uint64",
+	"float": "float32", "double": "float64",
+}
+
+var csharpScalarTypes = map[string]string{
+	"string": "string", "bytes": "byte[]", "bool": "bool",
+	"int32": "int", "int64": "long", "uint32": "uint", "uint64": "ulong",
+	"float": "float", "double": "double",
+}
+
+var tsScalarTypes = map[string]string{
+	"string": "string", "bytes": "Uint8Array", "bool": "boolean",
+	"int32": "number", "int64": "number", "uint32": "number", "uint64": "number",
+	"float": "number", "double": "number",
+}
@@ -0,0 +1,161 @@
+package generator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The SignalR adapter carries the socketgen protocol over an existing ASP.NET SignalR
+// deployment. Every payload is a hub method named after it (e.g. "LoginReq") in both
+// directions, taking the encoded GamePacket as its only argument, so the hub protocol (JSON or
+// MessagePack) never sees the protobuf fields.
+
+const csharpSignalRHubTemplate = `// Code generated by socketgen. DO NOT EDIT.
+using System;
+using System.Threading.Tasks;
+using Microsoft.AspNetCore.SignalR;
+using {{.PackageName | toPascalCase}};
+
+// Creates the packet handler of every SignalR connection; register one with dependency injection.
+// Its lifecycle callbacks run when connections open and close.
+public interface IPacketHubHandlerFactory : IConnectionLifecycle {
+    IPacketHandler Create(string connectionId, IPacketStream stream);
+}
+
+// Hub that dispatches socketgen packets. Clients invoke the method named after a packet's payload
+// with the encoded {{.Wrapper}}, and receive packets the same way. Map it with
+// app.MapHub<PacketHub>("/packets").
+public class PacketHub : Hub {
+    private const string HandlerKey = "socketgen.handler";
+
+    private readonly IPacketHubHandlerFactory factory;
+    private readonly IHubContext<PacketHub> hubContext;
+
+    public PacketHub(IPacketHubHandlerFactory factory, IHubContext<PacketHub> hubContext) {
+        this.factory = factory;
+        this.hubContext = hubContext;
+    }
+
+    // Name of the hub method that carries pkt
+    public static string MethodOf({{.Wrapper}} pkt) {
+        if (pkt.PayloadCase == {{.Wrapper}}.PayloadOneofCase.None) {
+            throw new ArgumentException("packet has no payload");
+        }
+        return pkt.PayloadCase.ToString();
+    }
+
+    public override Task OnConnectedAsync() {
+        var stream = new HubPacketStream(hubContext, Context.ConnectionId);
+        Context.Items[HandlerKey] = factory.Create(Context.ConnectionId, stream);
+        factory.OnConnect(Context.ConnectionId);
+        return base.OnConnectedAsync();
+    }
+
+    public override Task OnDisconnectedAsync(Exception exception) {
+        var reason = exception is TimeoutException ? DisconnectReason.Timeout : DisconnectReason.Closed;
+        factory.OnDisconnect(Context.ConnectionId, reason, exception);
+        return base.OnDisconnectedAsync(exception);
+    }
+{{- range .Payloads }}
+
+    public void {{.Name}}(byte[] data) {
+        Dispatch({{$.Wrapper}}.PayloadOneofCase.{{.Name}}, data);
+    }
+{{- end }}
+
+    private void Dispatch({{.Wrapper}}.PayloadOneofCase method, byte[] data) {
+        var pkt = {{.Wrapper}}.Parser.ParseFrom(data);
+        if (pkt.PayloadCase != method) {
+            throw new HubException($"hub method {method} received a {pkt.PayloadCase} packet");
+        }
+        PacketDispatcher.DispatchPacket(pkt, (IPacketHandler)Context.Items[HandlerKey]);
+    }
+}
+
+// Sends packets to one SignalR connection. Packets from the client arrive through PacketHub, so
+// ReadPacket is not supported.
+public sealed class HubPacketStream : IPacketStream {
+    private readonly IHubContext<PacketHub> hubContext;
+    private readonly string connectionId;
+
+    public HubPacketStream(IHubContext<PacketHub> hubContext, string connectionId) {
+        this.hubContext = hubContext;
+        this.connectionId = connectionId;
+    }
+
+    public byte[] ReadPacket() {
+        throw new NotSupportedException("SignalR clients send packets through PacketHub methods");
+    }
+
+    public void WritePacket(byte[] data) {
+        var method = PacketHub.MethodOf({{.Wrapper}}.Parser.ParseFrom(data));
+        hubContext.Clients.Client(connectionId).SendAsync(method, data).GetAwaiter().GetResult();
+    }
+}
+`
+
+const csharpSignalRClientTemplate = `// Code generated by socketgen. DO NOT EDIT.
+using System;
+using System.Collections.Concurrent;
+using System.Threading.Tasks;
+using Microsoft.AspNetCore.SignalR.Client;
+using {{.PackageName | toPascalCase}};
+
+// Carries packets over a SignalR HubConnection to a PacketHub. Create it before starting the
+// connection so that no packet is missed.
+public sealed class HubConnectionPacketStream : IPacketStream, IDisposable {
+    private readonly HubConnection connection;
+    private readonly BlockingCollection<byte[]> packets = new BlockingCollection<byte[]>();
+    private readonly IDisposable[] subscriptions;
+
+    public HubConnectionPacketStream(HubConnection connection) {
+        this.connection = connection;
+        subscriptions = new IDisposable[] {
+{{- range .Payloads }}
+            connection.On<byte[]>("{{.Name}}", packets.Add),
+{{- end }}
+        };
+        connection.Closed += error => {
+            packets.CompleteAdding();
+            return Task.CompletedTask;
+        };
+    }
+
+    // Blocks until a packet arrives; throws InvalidOperationException once the connection is closed
+    public byte[] ReadPacket() {
+        return packets.Take();
+    }
+
+    public void WritePacket(byte[] data) {
+        var method = {{.Wrapper}}.Parser.ParseFrom(data).PayloadCase;
+        if (method == {{.Wrapper}}.PayloadOneofCase.None) {
+            throw new ArgumentException("packet has no payload");
+        }
+        connection.SendAsync(method.ToString(), data).GetAwaiter().GetResult();
+    }
+
+    public void Dispose() {
+        foreach (var subscription in subscriptions) {
+            subscription.Dispose();
+        }
+        packets.Dispose();
+    }
+}
+`
+
+// GenerateSignalR writes PacketHub.cs, a SignalR hub that dispatches packets, and
+// PacketHubClient.cs, an IPacketStream over a SignalR HubConnection
+func GenerateSignalR(result *parser.ParseResult, outDir string) error {
+	funcMap := template.FuncMap{
+		"toPascalCase": toPascalCase,
+	}
+	if err := writeTemplate(outDir, "PacketHub.cs", "csharp_signalr_hub", csharpSignalRHubTemplate, funcMap, result); err != nil {
+		return fmt.Errorf("failed to generate the SignalR hub: %w", err)
+	}
+	if err := writeTemplate(outDir, "PacketHubClient.cs", "csharp_signalr_client", csharpSignalRClientTemplate, funcMap, result); err != nil {
+		return fmt.Errorf("failed to generate the SignalR client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Snapshots are rendered on the server's clock: the buffer estimates the offset to the local clock
+// from the timestamps it receives, so clients need no clock synchronization. Only float and
+// double fields are blended; other fields, such as lists of entities, keep the value of the older
+// snapshot unless the application passes its own interpolation.
+
+const tsSnapshotTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+
+const { {{range .Snapshots}}{{.Name}}, {{end}}} = {{.PackageName}};
+{{- range .Snapshots }}
+type {{.Name}} = {{$.PackageName}}.{{.Name}};
+{{- end }}
+
+export interface SnapshotBufferOptions {
+  /** How far behind the server clock snapshots are rendered, in milliseconds (default 100); two or three snapshot intervals absorb jitter and a lost snapshot */
+  delay?: number;
+  /** How far past the newest snapshot the state is extrapolated, in milliseconds (default 50); then it holds */
+  maxExtrapolation?: number;
+  /** Snapshots kept (default 32) */
+  capacity?: number;
+}
+
+/**
+ * Buffers the snapshots of one kind as they arrive and returns the state to render at any
+ * moment, interpolated between the two snapshots around the render time.
+ */
+export class SnapshotBuffer<T> {
+  private readonly delay: number;
+  private readonly maxExtrapolation: number;
+  private readonly capacity: number;
+  private snapshots: { time: number; value: T }[] = [];
+  private offset: number | undefined; // Server clock minus local clock, smoothed
+
+  constructor(
+    private readonly timeOf: (snapshot: T) => number,
+    private readonly interpolate: (a: T, b: T, alpha: number) => T,
+    options: SnapshotBufferOptions = {},
+  ) {
+    this.delay = options.delay ?? 100;
+    this.maxExtrapolation = options.maxExtrapolation ?? 50;
+    this.capacity = Math.max(options.capacity ?? 32, 2);
+  }
+
+  /** Adds a received snapshot; now is the local time in milliseconds */
+  push(snapshot: T, now: number = performance.now()): void {
+    const time = this.timeOf(snapshot);
+    const offset = time - now;
+    this.offset = this.offset === undefined ? offset : this.offset + (offset - this.offset) * 0.1;
+
+    let i = this.snapshots.length;
+    while (i > 0 && this.snapshots[i - 1].time > time) {
+      i--;
+    }
+    if (i > 0 && this.snapshots[i - 1].time === time) {
+      return;
+    }
+    this.snapshots.splice(i, 0, { time, value: snapshot });
+    if (this.snapshots.length > this.capacity) {
+      this.snapshots.shift();
+    }
+  }
+
+  /** Returns the state to render at local time now, or undefined before the first snapshot */
+  sample(now: number = performance.now()): T | undefined {
+    const n = this.snapshots.length;
+    if (n === 0) {
+      return undefined;
+    }
+    const render = now + this.offset! - this.delay;
+    if (n === 1 || render <= this.snapshots[0].time) {
+      return this.snapshots[0].value;
+    }
+    let i = 1;
+    while (i < n - 1 && this.snapshots[i].time < render) {
+      i++;
+    }
+    const a = this.snapshots[i - 1];
+    const b = this.snapshots[i];
+    const time = Math.min(render, b.time + this.maxExtrapolation);
+    return this.interpolate(a.value, b.value, (time - a.time) / (b.time - a.time));
+  }
+
+  /** Drops every snapshot, e.g. after a reconnection or a teleport */
+  clear(): void {
+    this.snapshots = [];
+    this.offset = undefined;
+  }
+}
+
+// protobufjs decodes 64-bit fields as Long
+function millis(value: number | { toNumber(): number }): number {
+  return typeof value === "number" ? value : value.toNumber();
+}
+{{- range .Snapshots }}
+
+/** Blends the floating-point fields of two {{.Name}} snapshots; alpha above 1 extrapolates. Other fields are those of a until b is reached. */
+export function interpolate{{.Name}}(a: {{.Name}}, b: {{.Name}}, alpha: number): {{.Name}} {
+  const s = {{.Name}}.create(alpha < 1 ? a : b);
+{{- range .Blend }}
+  {{tsAttr "s" .Name}} = {{tsAttr "a" .Name}} + ({{tsAttr "b" .Name}} - {{tsAttr "a" .Name}}) * alpha;
+{{- end }}
+  return s;
+}
+
+export class {{.Name}}Buffer extends SnapshotBuffer<{{.Name}}> {
+  constructor(options?: SnapshotBufferOptions, interpolate: (a: {{.Name}}, b: {{.Name}}, alpha: number) => {{.Name}} = interpolate{{.Name}}) {
+    super((s) => millis({{tsAttr "s" .Time.Name}}), interpolate, options);
+  }
+}
+{{- end }}
+`
+
+const csharpSnapshotTemplate = `// Code generated by socketgen. DO NOT EDIT.
+using System;
+using System.Collections.Generic;
+using System.Diagnostics;
+
+namespace {{.PackageName | toPascalCase}} {
+    public sealed class SnapshotBufferOptions {
+        /// <summary>How far behind the server clock snapshots are rendered, in milliseconds; two or three snapshot intervals absorb jitter and a lost snapshot.</summary>
+        public double Delay { get; set; } = 100;
+        /// <summary>How far past the newest snapshot the state is extrapolated, in milliseconds; then it holds.</summary>
+        public double MaxExtrapolation { get; set; } = 50;
+        /// <summary>Snapshots kept.</summary>
+        public int Capacity { get; set; } = 32;
+    }
+
+    /// <summary>
+    /// Buffers the snapshots of one kind as they arrive and returns the state to render at any
+    /// moment, interpolated between the two snapshots around the render time. Snapshots may be
+    /// pushed from the network thread while the render thread samples.
+    /// </summary>
+    public class SnapshotBuffer<T> where T : class {
+        private static readonly Stopwatch Clock = Stopwatch.StartNew();
+
+        private readonly Func<T, double> timeOf;
+        private readonly Func<T, T, double, T> interpolate;
+        private readonly SnapshotBufferOptions options;
+        private readonly List<(double Time, T Value)> snapshots = new List<(double Time, T Value)>();
+        private double? offset; // Server clock minus local clock, smoothed
+
+        public SnapshotBuffer(Func<T, double> timeOf, Func<T, T, double, T> interpolate, SnapshotBufferOptions options = null) {
+            this.timeOf = timeOf;
+            this.interpolate = interpolate;
+            this.options = options ?? new SnapshotBufferOptions();
+        }
+
+        /// <summary>Local time in milliseconds, the default of Push and Sample.</summary>
+        public static double Now => Clock.Elapsed.TotalMilliseconds;
+
+        public void Push(T snapshot) => Push(snapshot, Now);
+
+        /// <summary>Adds a received snapshot; now is the local time in milliseconds.</summary>
+        public void Push(T snapshot, double now) {
+            var time = timeOf(snapshot);
+            lock (snapshots) {
+                var sample = time - now;
+                offset = offset.HasValue ? offset + (sample - offset) * 0.1 : sample;
+
+                var i = snapshots.Count;
+                while (i > 0 && snapshots[i - 1].Time > time) {
+                    i--;
+                }
+                if (i > 0 && snapshots[i - 1].Time == time) {
+                    return;
+                }
+                snapshots.Insert(i, (time, snapshot));
+                if (snapshots.Count > Math.Max(options.Capacity, 2)) {
+                    snapshots.RemoveAt(0);
+                }
+            }
+        }
+
+        public T Sample() => Sample(Now);
+
+        /// <summary>Returns the state to render at local time now, or null before the first snapshot.</summary>
+        public T Sample(double now) {
+            lock (snapshots) {
+                var n = snapshots.Count;
+                if (n == 0) {
+                    return null;
+                }
+                var render = now + offset.Value - options.Delay;
+                if (n == 1 || render <= snapshots[0].Time) {
+                    return snapshots[0].Value;
+                }
+                var i = 1;
+                while (i < n - 1 && snapshots[i].Time < render) {
+                    i++;
+                }
+                var a = snapshots[i - 1];
+                var b = snapshots[i];
+                var time = Math.Min(render, b.Time + options.MaxExtrapolation);
+                return interpolate(a.Value, b.Value, (time - a.Time) / (b.Time - a.Time));
+            }
+        }
+
+        /// <summary>Drops every snapshot, e.g. after a reconnection or a teleport.</summary>
+        public void Clear() {
+            lock (snapshots) {
+                snapshots.Clear();
+                offset = null;
+            }
+        }
+    }
+
+    public static class Snapshots {
+{{- range $i, $s := .Snapshots }}
+{{- if $i }}
+{{ end }}
+        /// <summary>Blends the floating-point fields of two {{.Name}} snapshots; alpha above 1 extrapolates. Other fields are those of a until b is reached.</summary>
+        public static {{.Name}} Interpolate{{.Name}}({{.Name}} a, {{.Name}} b, double alpha) {
+            var s = (alpha < 1 ? a : b).Clone();
+{{- range .Blend }}
+            s.{{.Name | toPascalCase}} = {{if eq .Kind "float"}}(float)({{end}}a.{{.Name | toPascalCase}} + (b.{{.Name | toPascalCase}} - a.{{.Name | toPascalCase}}) * alpha{{if eq .Kind "float"}}){{end}};
+{{- end }}
+            return s;
+        }
+{{- end }}
+    }
+{{- range .Snapshots }}
+
+    public class {{.Name}}Buffer : SnapshotBuffer<{{.Name}}> {
+        public {{.Name}}Buffer(SnapshotBufferOptions options = null, Func<{{.Name}}, {{.Name}}, double, {{.Name}}> interpolate = null)
+            : base(s => (double)s.{{.Time.Name | toPascalCase}}, interpolate ?? Snapshots.Interpolate{{.Name}}, options) {
+        }
+    }
+{{- end }}
+}
+`
+
+const dartSnapshotTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import 'packet.pb.dart';
+
+final Stopwatch _clock = Stopwatch()..start();
+
+/// Buffers the snapshots of one kind as they arrive and returns the state to render at any
+/// moment, interpolated between the two snapshots around the render time.
+class SnapshotBuffer<T> {
+  SnapshotBuffer(this._timeOf, this._interpolate, {this.delay = 100, this.maxExtrapolation = 50, int capacity = 32})
+      : capacity = capacity < 2 ? 2 : capacity;
+
+  /// How far behind the server clock snapshots are rendered, in milliseconds; two or three
+  /// snapshot intervals absorb jitter and a lost snapshot.
+  final double delay;
+
+  /// How far past the newest snapshot the state is extrapolated, in milliseconds; then it holds.
+  final double maxExtrapolation;
+
+  /// Snapshots kept.
+  final int capacity;
+
+  final double Function(T snapshot) _timeOf;
+  final T Function(T a, T b, double alpha) _interpolate;
+  final List<(double, T)> _snapshots = [];
+  double? _offset; // Server clock minus local clock, smoothed
+
+  /// Local time in milliseconds, the default of [push] and [sample].
+  static double get now => _clock.elapsedMicroseconds / 1000;
+
+  /// Adds a received snapshot; [at] is the local time in milliseconds.
+  void push(T snapshot, [double? at]) {
+    final time = _timeOf(snapshot);
+    final sample = time - (at ?? now);
+    final offset = _offset;
+    _offset = offset == null ? sample : offset + (sample - offset) * 0.1;
+
+    var i = _snapshots.length;
+    while (i > 0 && _snapshots[i - 1].$1 > time) {
+      i--;
+    }
+    if (i > 0 && _snapshots[i - 1].$1 == time) {
+      return;
+    }
+    _snapshots.insert(i, (time, snapshot));
+    if (_snapshots.length > capacity) {
+      _snapshots.removeAt(0);
+    }
+  }
+
+  /// Returns the state to render at local time [at], or null before the first snapshot.
+  T? sample([double? at]) {
+    final n = _snapshots.length;
+    if (n == 0) {
+      return null;
+    }
+    final render = (at ?? now) + _offset! - delay;
+    if (n == 1 || render <= _snapshots[0].$1) {
+      return _snapshots[0].$2;
+    }
+    var i = 1;
+    while (i < n - 1 && _snapshots[i].$1 < render) {
+      i++;
+    }
+    final (aTime, a) = _snapshots[i - 1];
+    final (bTime, b) = _snapshots[i];
+    final time = render < bTime + maxExtrapolation ? render : bTime + maxExtrapolation;
+    return _interpolate(a, b, (time - aTime) / (bTime - aTime));
+  }
+
+  /// Drops every snapshot, e.g. after a reconnection or a teleport.
+  void clear() {
+    _snapshots.clear();
+    _offset = null;
+  }
+}
+{{- range .Snapshots }}
+
+/// Blends the floating-point fields of two [{{.Name}}] snapshots; [alpha] above 1 extrapolates.
+/// Other fields are those of [a] until [b] is reached.
+{{.Name}} interpolate{{.Name}}({{.Name}} a, {{.Name}} b, double alpha) {
+  final s = (alpha < 1 ? a : b).clone();
+{{- range .Blend }}
+  s.{{dartName .Name .Number}} = a.{{dartName .Name .Number}} + (b.{{dartName .Name .Number}} - a.{{dartName .Name .Number}}) * alpha;
+{{- end }}
+  return s;
+}
+
+class {{.Name}}Buffer extends SnapshotBuffer<{{.Name}}> {
+  {{.Name}}Buffer({double delay = 100, double maxExtrapolation = 50, int capacity = 32, {{.Name}} Function({{.Name}}, {{.Name}}, double) interpolate = interpolate{{.Name}}})
+      : super((s) => s.{{dartName .Time.Name .Time.Number}}.toDouble(), interpolate, delay: delay, maxExtrapolation: maxExtrapolation, capacity: capacity);
+}
+{{- end }}
+`
+
+type snapshotPayload struct {
+	parser.PayloadMessage
+	Time  *parser.MessageField
+	Blend []parser.MessageField // Fields interpolated between snapshots
+}
+
+// snapshotData returns the template data of the snapshot buffers
+func snapshotData(result *parser.ParseResult) any {
+	var snapshots []snapshotPayload
+	for _, p := range result.SnapshotPayloads() {
+		s := snapshotPayload{PayloadMessage: p, Time: p.SnapshotTime()}
+		for _, f := range p.Fields {
+			if (f.Kind == "float" || f.Kind == "double") && f.Name != s.Time.Name && !f.Repeated && !f.Map && !f.Optional && !f.InOneof {
+				s.Blend = append(s.Blend, f)
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+	return struct {
+		*parser.ParseResult
+		Snapshots []snapshotPayload
+	}{result, snapshots}
+}
+
+// generateSnapshots writes the snapshot interpolation buffers of a client language, if any
+// payload is a snapshot
+func generateSnapshots(result *parser.ParseResult, lang, outDir string) error {
+	if len(result.SnapshotPayloads()) == 0 {
+		return nil
+	}
+	data := snapshotData(result)
+	switch lang {
+	case "ts":
+		return writeTemplate(outDir, "PacketSnapshots.ts", "ts_snapshots", tsSnapshotTemplate, template.FuncMap{"tsAttr": tsAttr}, data)
+	case "csharp":
+		return writeTemplate(outDir, "PacketSnapshots.cs", "csharp_snapshots", csharpSnapshotTemplate, template.FuncMap{"toPascalCase": toPascalCase}, data)
+	case "dart":
+		return writeTemplate(outDir, "packet_snapshots.dart", "dart_snapshots", dartSnapshotTemplate, template.FuncMap{"dartName": dartName}, data)
+	}
+	return nil
+}
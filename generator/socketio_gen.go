@@ -0,0 +1,423 @@
+package generator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The Socket.IO adapter lets teams migrating off Socket.IO run both protocols side by side: the
+// Go server accepts Socket.IO clients as one more Transport, and the TypeScript client wraps a
+// socket.io-client Socket as an IPacketStream. Each packet is a Socket.IO event named after its
+// payload (e.g. "login_req") with the encoded GamePacket as its binary attachment. Only the
+// Engine.IO v4 WebSocket transport is implemented, so clients must connect with
+// transports: ["websocket"].
+
+const goTransportSocketIOTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// Engine.IO heartbeat; a client that misses it is disconnected with DisconnectTimeout
+const (
+	SocketIOPingInterval = 25 * time.Second
+	SocketIOPingTimeout  = 20 * time.Second
+)
+
+// SocketIODisconnectEvent is emitted with the DisconnectReason before the server closes a Socket.IO connection
+const SocketIODisconnectEvent = "socketgen:disconnect"
+
+// SocketIOTransport accepts Socket.IO clients (Engine.IO v4, WebSocket transport) on the
+// default namespace. Every packet is an event named after its payload, carrying the encoded
+// {{.Wrapper}} as a binary attachment. Events with other names are ignored.
+type SocketIOTransport struct {
+	listener net.Listener
+	server   *http.Server
+	conns    chan TransportConn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// ListenSocketIO listens for Socket.IO clients on addr at path (e.g., ":3000", "/socket.io/")
+func ListenSocketIO(addr, path string) (*SocketIOTransport, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &SocketIOTransport{
+		listener: l,
+		conns:    make(chan TransportConn),
+		closed:   make(chan struct{}),
+	}
+{{- if .Security }}
+	upgrader := websocket.Upgrader{CheckOrigin: checkOrigin}
+{{- else }}
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+{{- end }}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("EIO") != "4" || query.Get("transport") != "websocket" {
+			// Long polling is not implemented; the client must start on the WebSocket transport
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, ` + "`" + `{"code":0,"message":"Transport unknown"}` + "`" + `)
+			return
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+{{- if .Security }}
+		ws.SetReadLimit(MaxPacketSize)
+{{- end }}
+		conn, err := openSocketIO(ws)
+		if err != nil {
+			ws.Close()
+			return
+		}
+		select {
+		case t.conns <- conn:
+		case <-t.closed:
+			conn.Close()
+		}
+	})
+	t.server = &http.Server{Handler: mux}
+	go t.server.Serve(l)
+
+	return t, nil
+}
+
+func (t *SocketIOTransport) Accept() (TransportConn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-t.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (t *SocketIOTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *SocketIOTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return t.server.Close()
+}
+
+type socketIOConn struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+	done    chan struct{}
+	once    sync.Once
+}
+
+// openSocketIO performs the Engine.IO handshake and the Socket.IO connect to the default namespace
+func openSocketIO(ws *websocket.Conn) (*socketIOConn, error) {
+	c := &socketIOConn{conn: ws, done: make(chan struct{})}
+
+	handshake, err := json.Marshal(map[string]any{
+		"sid":          newSocketIOID(),
+		"upgrades":     []string{},
+		"pingInterval": SocketIOPingInterval.Milliseconds(),
+		"pingTimeout":  SocketIOPingTimeout.Milliseconds(),
+		"maxPayload":   {{if .Security}}MaxPacketSize{{else}}MaxFrameSize{{end}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.writeText("0" + string(handshake)); err != nil {
+		return nil, err
+	}
+
+	ws.SetReadDeadline(time.Now().Add(SocketIOPingInterval + SocketIOPingTimeout))
+	for {
+		kind, msg, err := ws.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if kind != websocket.TextMessage || !strings.HasPrefix(string(msg), "40") {
+			continue
+		}
+		if rest := string(msg[2:]); strings.HasPrefix(rest, "/") && !strings.HasPrefix(rest, "/,") {
+			nsp, _, _ := strings.Cut(rest, ",")
+			c.writeText("44" + nsp + ` + "`" + `,{"message":"Invalid namespace"}` + "`" + `)
+			return nil, fmt.Errorf("socket.io namespace %s is not supported", nsp)
+		}
+		break
+	}
+	if err := c.writeText(` + "`" + `40{"sid":"` + "`" + ` + newSocketIOID() + ` + "`" + `"}` + "`" + `); err != nil {
+		return nil, err
+	}
+
+	go c.ping()
+	return c, nil
+}
+
+func newSocketIOID() string {
+	var id [15]byte
+	rand.Read(id[:])
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+// ping sends Engine.IO pings; ReadPacket extends the read deadline whenever the client answers
+func (c *socketIOConn) ping() {
+	ticker := time.NewTicker(SocketIOPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.writeText("2") != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *socketIOConn) ReadPacket() ([]byte, error) {
+	var event string
+	attachments := 0
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(SocketIOPingInterval + SocketIOPingTimeout))
+		kind, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return nil, NewDisconnectError(DisconnectTimeout, err)
+			}
+			return nil, err
+		}
+
+		if kind == websocket.BinaryMessage {
+			if attachments == 0 {
+				continue
+			}
+			attachments--
+			// Only the first attachment carries the packet
+			if attachments == 0 && event != "" {
+				return msg, nil
+			}
+			continue
+		}
+
+		text := string(msg)
+		switch {
+		case text == "1", strings.HasPrefix(text, "41"):
+			return nil, NewDisconnectError(DisconnectClosed, io.EOF)
+		case strings.HasPrefix(text, "45"):
+			event, attachments, err = c.parseBinaryEvent(text[2:])
+			if err != nil {
+				return nil, NewDisconnectError(DisconnectProtocolError, err)
+			}
+		}
+		// Pongs ("3") and text events only extend the read deadline
+	}
+}
+
+// parseBinaryEvent parses the text part of a BINARY_EVENT,
+// <attachments>-[/namespace,][ack id]["event", {"_placeholder":true,"num":0}],
+// and acknowledges it if the client asked for an ack. It returns "" for events that are not payloads.
+func (c *socketIOConn) parseBinaryEvent(text string) (string, int, error) {
+	count, rest, ok := strings.Cut(text, "-")
+	attachments, err := strconv.Atoi(count)
+	if !ok || err != nil {
+		return "", 0, fmt.Errorf("malformed socket.io binary event")
+	}
+	if strings.HasPrefix(rest, "/") {
+		_, rest, _ = strings.Cut(rest, ",")
+	}
+	ack := rest[:strings.IndexFunc(rest+"[", func(r rune) bool { return r < '0' || r > '9' })]
+	rest = rest[len(ack):]
+
+	var args []json.RawMessage
+	var name string
+	if err := json.Unmarshal([]byte(rest), &args); err != nil || len(args) == 0 {
+		return "", 0, fmt.Errorf("malformed socket.io binary event")
+	}
+	if err := json.Unmarshal(args[0], &name); err != nil {
+		return "", 0, fmt.Errorf("malformed socket.io event name")
+	}
+
+	if ack != "" {
+		c.writeText("43" + ack + "[]")
+	}
+	if !isSocketIOEvent(name) {
+		name = ""
+	}
+	return name, attachments, nil
+}
+
+func (c *socketIOConn) WritePacket(data []byte) error {
+	pkt := &{{.Wrapper}}{}
+	if err := proto.Unmarshal(data, pkt); err != nil {
+		return err
+	}
+	event := PayloadName(pkt)
+	if event == "" {
+		return fmt.Errorf("packet has no payload")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.WriteMessage(websocket.TextMessage, []byte(` + "`" + `451-["` + "`" + `+event+` + "`" + `",{"_placeholder":true,"num":0}]` + "`" + `)); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (c *socketIOConn) writeText(text string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, []byte(text))
+}
+
+func (c *socketIOConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *socketIOConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.conn.Close()
+}
+
+// CloseWithReason emits SocketIODisconnectEvent with the reason, disconnects the socket, then closes the connection
+func (c *socketIOConn) CloseWithReason(reason DisconnectReason) error {
+	c.writeText(` + "`" + `42["` + "`" + ` + SocketIODisconnectEvent + ` + "`" + `","` + "`" + ` + reason.String() + ` + "`" + `"]` + "`" + `)
+	c.writeText("41")
+	return c.Close()
+}
+
+// isSocketIOEvent reports whether name is the event name of a payload
+func isSocketIOEvent(name string) bool {
+	switch name {
+	case {{range $i, $p := .Payloads}}{{if $i}}, {{end}}"{{.FieldName}}"{{end}}:
+		return true
+	}
+	return false
+}
+`
+
+const tsSocketIOTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import type { Socket } from "socket.io-client";
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+import { IPacketStream } from "./PacketDispatcher";
+import { DisconnectReason } from "./PacketLifecycle";
+
+const { {{.Wrapper}} } = {{.PackageName}};
+
+// Socket.IO event name of every payload, with its property name on {{.Wrapper}}
+const PAYLOAD_EVENTS: [string, string][] = [
+{{- range .Payloads }}
+  ["{{.FieldName}}", "{{.FieldName | toCamelCase}}"],
+{{- end }}
+];
+
+export const SOCKET_IO_EVENTS: string[] = PAYLOAD_EVENTS.map(([event]) => event);
+
+// Emitted by the server with the disconnect reason before it closes the connection
+export const SOCKET_IO_DISCONNECT_EVENT = "socketgen:disconnect";
+
+const EVENTS = new Set(SOCKET_IO_EVENTS);
+
+// Returns the Socket.IO event name of an encoded {{.Wrapper}}: the field name of its payload
+export function socketIOEventOf(data: Uint8Array): string {
+  const pkt = {{.Wrapper}}.decode(data) as any;
+  for (const [event, property] of PAYLOAD_EVENTS) {
+    if (pkt[property] !== undefined) {
+      return event;
+    }
+  }
+  throw new Error("packet has no payload");
+}
+
+// Carries packets over a socket.io-client Socket. Connect it with transports: ["websocket"].
+// Each packet is an event named after its payload, with the encoded {{.Wrapper}} as binary
+// attachment; other events on the same socket keep working.
+export class SocketIOStream implements IPacketStream {
+  private queue: Uint8Array[] = [];
+  private waiters: { resolve: (data: Uint8Array) => void; reject: (err: Error) => void }[] = [];
+  private closed: Error | null = null;
+
+  // Why the server closed the connection, once it did
+  disconnectReason: DisconnectReason | null = null;
+
+  constructor(private socket: Socket) {
+    socket.onAny((event: string, data: unknown) => {
+      if (event === SOCKET_IO_DISCONNECT_EVENT) {
+        this.disconnectReason = data as DisconnectReason;
+        return;
+      }
+      if (!EVENTS.has(event)) {
+        return;
+      }
+      const bytes = data instanceof ArrayBuffer ? new Uint8Array(data) : (data as Uint8Array);
+      const waiter = this.waiters.shift();
+      if (waiter) {
+        waiter.resolve(bytes);
+      } else {
+        this.queue.push(bytes);
+      }
+    });
+    socket.on("disconnect", (reason: string) => {
+      if (this.disconnectReason === null) {
+        this.disconnectReason = reason === "ping timeout" ? DisconnectReason.Timeout : DisconnectReason.Closed;
+      }
+      this.closed = new Error("socket.io disconnected: " + reason);
+      for (const waiter of this.waiters.splice(0)) {
+        waiter.reject(this.closed);
+      }
+    });
+  }
+
+  readPacket(): Promise<Uint8Array> {
+    const data = this.queue.shift();
+    if (data) {
+      return Promise.resolve(data);
+    }
+    if (this.closed) {
+      return Promise.reject(this.closed);
+    }
+    return new Promise((resolve, reject) => this.waiters.push({ resolve, reject }));
+  }
+
+  async writePacket(data: Uint8Array): Promise<void> {
+    this.socket.emit(socketIOEventOf(data), data);
+  }
+}
+`
+
+// GenerateSocketIOClient writes SocketIOStream.ts, the TypeScript side of the Socket.IO adapter
+func GenerateSocketIOClient(result *parser.ParseResult, outDir string) error {
+	funcMap := template.FuncMap{
+		"toCamelCase": toCamelCase,
+	}
+	if err := writeTemplate(outDir, "SocketIOStream.ts", "ts_socketio", tsSocketIOTemplate, funcMap, result); err != nil {
+		return fmt.Errorf("failed to generate the Socket.IO client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,408 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The SSE transport suits read-heavy clients such as spectators and dashboards: packets to the
+// client are Server-Sent Events on one long-lived GET, which every proxy and CDN passes, and the
+// rare packets from the client are plain POSTs. Sessions look like any other TransportConn, so
+// the dispatcher API is unchanged.
+
+const goTransportSSETemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSESessionHeader names the session of a packet POST. The event stream announces it in its
+// first event, "session".
+const SSESessionHeader = "X-Socketgen-Session"
+
+// SSEKeepalive is how long an event stream may stay idle before the server sends a comment,
+// which keeps proxies from closing it
+const SSEKeepalive = 15 * time.Second
+
+// SSETransport serves sessions over Server-Sent Events. A client opens GET <path>/events and
+// receives these events:
+//
+//	session     the session ID, first
+//	packet      one {{.Wrapper}}, base64-encoded
+//	disconnect  the DisconnectReason, last, when the server ends the session
+//
+// and sends packets as POST <path>/packets, one {{.Wrapper}} per request body, with the session
+// in SSESessionHeader.
+type SSETransport struct {
+	listener net.Listener
+	server   *http.Server
+	conns    chan TransportConn
+
+	mu       sync.Mutex
+	sessions map[string]*sseConn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// ListenSSE listens for SSE clients on addr below path (e.g., ":8082", "/sse")
+func ListenSSE(addr, path string) (*SSETransport, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &SSETransport{
+		listener: l,
+		conns:    make(chan TransportConn),
+		sessions: map[string]*sseConn{},
+		closed:   make(chan struct{}),
+	}
+	path = strings.TrimSuffix(path, "/")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path+"/events", t.events)
+	mux.HandleFunc(path+"/packets", t.packets)
+	t.server = &http.Server{Handler: mux}
+	go t.server.Serve(l)
+
+	return t, nil
+}
+
+func (t *SSETransport) Accept() (TransportConn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-t.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (t *SSETransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *SSETransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return t.server.Close()
+}
+
+// events starts a session and streams its packets until either side ends it
+func (t *SSETransport) events(w http.ResponseWriter, r *http.Request) {
+{{- if .Security }}
+	if !allowOrigin(w, r) {
+		return
+	}
+{{- else }}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+{{- end }}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var id [16]byte
+	rand.Read(id[:])
+	c := &sseConn{
+		session: hex.EncodeToString(id[:]),
+		remote:  sseAddr(r.RemoteAddr),
+		inbox:   make(chan []byte),
+		outbox:  make(chan []byte),
+		done:    make(chan struct{}),
+	}
+	c.release = func() {
+		t.mu.Lock()
+		delete(t.sessions, c.session)
+		t.mu.Unlock()
+	}
+	t.mu.Lock()
+	t.sessions[c.session] = c
+	t.mu.Unlock()
+
+	select {
+	case t.conns <- c:
+	case <-t.closed:
+		c.release()
+		http.Error(w, "server closed", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		c.release()
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "event: session\ndata: %s\n\n", c.session)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(SSEKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case data := <-c.outbox:
+			if _, err := fmt.Fprintf(w, "event: packet\ndata: %s\n\n", base64.StdEncoding.EncodeToString(data)); err != nil {
+				c.finish(err, "")
+				return
+			}
+			flusher.Flush()
+			keepalive.Reset(SSEKeepalive)
+		case <-keepalive.C:
+			io.WriteString(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-c.done:
+			fmt.Fprintf(w, "event: disconnect\ndata: %s\n\n", c.reason)
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			c.finish(NewDisconnectError(DisconnectClosed, io.EOF), "")
+			return
+		}
+	}
+}
+
+// packets delivers the packet in a POST body to its session
+func (t *SSETransport) packets(w http.ResponseWriter, r *http.Request) {
+{{- if .Security }}
+	if !allowOrigin(w, r) {
+		return
+	}
+{{- else }}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+{{- end }}
+	w.Header().Set("Access-Control-Allow-Headers", "content-type, "+strings.ToLower(SSESessionHeader))
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	t.mu.Lock()
+	c := t.sessions[r.Header.Get(SSESessionHeader)]
+	t.mu.Unlock()
+	if c == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxFrameSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > MaxFrameSize {
+		http.Error(w, ErrFrameTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		c.finish(NewDisconnectError(DisconnectProtocolError, ErrFrameTooLarge), DisconnectProtocolError.String())
+		return
+	}
+
+	select {
+	case c.inbox <- data:
+		w.WriteHeader(http.StatusNoContent)
+	case <-c.done:
+		http.Error(w, "session ended", http.StatusNotFound)
+	case <-r.Context().Done():
+	}
+}
+
+type sseConn struct {
+	session string
+	remote  net.Addr
+	release func()
+
+	inbox  chan []byte
+	outbox chan []byte
+
+	done   chan struct{}
+	err    error  // Why the session ended, set before done is closed
+	reason string // Disconnect reason sent to the client, set before done is closed
+	once   sync.Once
+}
+
+// finish ends the session once, telling the client reason unless it is empty (the client ended it)
+func (c *sseConn) finish(err error, reason string) {
+	c.once.Do(func() {
+		c.err = err
+		c.reason = reason
+		close(c.done)
+		c.release()
+	})
+}
+
+func (c *sseConn) ReadPacket() ([]byte, error) {
+	select {
+	case data := <-c.inbox:
+		return data, nil
+	case <-c.done:
+		return nil, c.err
+	}
+}
+
+func (c *sseConn) WritePacket(data []byte) error {
+	select {
+	case c.outbox <- data:
+		return nil
+	case <-c.done:
+		return net.ErrClosed
+	}
+}
+
+func (c *sseConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *sseConn) Close() error {
+	c.finish(net.ErrClosed, DisconnectClosed.String())
+	return nil
+}
+
+// CloseWithReason sends reason as the last event of the session's stream
+func (c *sseConn) CloseWithReason(reason DisconnectReason) error {
+	c.finish(net.ErrClosed, reason.String())
+	return nil
+}
+
+// sseAddr is the remote address of the event stream
+type sseAddr string
+
+func (a sseAddr) Network() string { return "tcp" }
+func (a sseAddr) String() string  { return string(a) }
+`
+
+const tsSSETemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { IPacketStream } from "./PacketDispatcher";
+import { DisconnectReason } from "./PacketLifecycle";
+
+const SESSION_HEADER = "x-socketgen-session";
+
+function decodeBase64(data: string): Uint8Array {
+  const binary = atob(data);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes;
+}
+
+// Receives packets as Server-Sent Events and sends them as POSTs, for read-heavy clients such as
+// spectators and dashboards
+export class SSEPacketStream implements IPacketStream {
+  private queue: Uint8Array[] = [];
+  private waiters: { resolve: (data: Uint8Array) => void; reject: (err: Error) => void }[] = [];
+  private closed: Error | null = null;
+  private sending: Promise<void> = Promise.resolve();
+
+  // Why the server ended the session, once it did
+  disconnectReason: DisconnectReason | null = null;
+
+  private constructor(private baseUrl: string, private source: EventSource, private session: string) {}
+
+  // Opens a session on the transport at baseUrl (e.g., "https://game.example.com/sse")
+  static open(baseUrl: string): Promise<SSEPacketStream> {
+    return new Promise((resolve, reject) => {
+      const source = new EventSource(baseUrl + "/events");
+      let stream: SSEPacketStream | null = null;
+      source.addEventListener("session", (e) => {
+        stream = new SSEPacketStream(baseUrl, source, (e as MessageEvent).data);
+        resolve(stream);
+      });
+      source.addEventListener("packet", (e) => stream?.deliver(decodeBase64((e as MessageEvent).data)));
+      source.addEventListener("disconnect", (e) => stream?.end((e as MessageEvent).data as DisconnectReason));
+      source.onerror = () => {
+        // EventSource would reconnect into a new session, so the stream ends here
+        if (stream) {
+          stream.end(DisconnectReason.Closed);
+        } else {
+          source.close();
+          reject(new Error("failed to open event stream at " + baseUrl));
+        }
+      };
+    });
+  }
+
+  private deliver(data: Uint8Array) {
+    const waiter = this.waiters.shift();
+    if (waiter) {
+      waiter.resolve(data);
+    } else {
+      this.queue.push(data);
+    }
+  }
+
+  private end(reason: DisconnectReason) {
+    this.source.close();
+    if (this.closed) {
+      return;
+    }
+    this.disconnectReason = reason;
+    this.closed = new Error("sse session ended: " + reason);
+    for (const waiter of this.waiters.splice(0)) {
+      waiter.reject(this.closed);
+    }
+  }
+
+  readPacket(): Promise<Uint8Array> {
+    const data = this.queue.shift();
+    if (data) {
+      return Promise.resolve(data);
+    }
+    if (this.closed) {
+      return Promise.reject(this.closed);
+    }
+    return new Promise((resolve, reject) => this.waiters.push({ resolve, reject }));
+  }
+
+  // Packets are posted one at a time, so they arrive in order
+  writePacket(data: Uint8Array): Promise<void> {
+    const send = this.sending.then(async () => {
+      if (this.closed) {
+        throw this.closed;
+      }
+      const res = await fetch(this.baseUrl + "/packets", {
+        method: "POST",
+        headers: { "content-type": "application/octet-stream", [SESSION_HEADER]: this.session },
+        body: data,
+      });
+      if (!res.ok) {
+        throw new Error("failed to send packet: HTTP " + res.status + " " + (await res.text()));
+      }
+    });
+    this.sending = send.catch(() => {});
+    return send;
+  }
+
+  // Ends the session by closing the event stream
+  close() {
+    this.end(DisconnectReason.Closed);
+  }
+}
+`
+
+// GenerateSSEClient writes SSEPacketStream.ts, the TypeScript client of the SSE transport
+func GenerateSSEClient(result *parser.ParseResult, outDir string) error {
+	if err := writeTemplate(outDir, "SSEPacketStream.ts", "ts_sse", tsSSETemplate, nil, result); err != nil {
+		return fmt.Errorf("failed to generate the SSE client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Packets carry the codes of user-facing texts, never the texts, so one server serves every
+// locale and translations ship with the clients. The catalogs themselves are plain JSON objects of
+// code to text, one per locale, which 'socketgen i18n' scaffolds.
+
+const tsTextCodesTemplate = `// Code generated by socketgen. DO NOT EDIT.
+
+/** The codes of the enums of fields annotated with option (socketgen.text_code) */
+export const TextCodes: readonly string[] = [
+{{- range .Enums }}
+{{- range .Codes }}
+  "{{.Code}}",
+{{- end }}
+{{- end }}
+];
+{{- range .Enums }}
+
+/** Codes of the values of {{.FullName}}, by number */
+export const {{.Name}}Codes: Readonly<Record<number, string>> = {
+{{- range .Codes }}
+  {{.Number}}: "{{.Code}}",
+{{- end }}
+};
+{{- end }}
+
+/** Texts by code, e.g. a locale's file of the catalog scaffolded by socketgen i18n */
+export type Texts = Readonly<Record<string, string>>;
+
+/**
+ * Looks up the texts of the codes the server sends in the catalogs of the locales. A code missing
+ * from a locale falls back to its language ("pt-BR" to "pt"), then to the fallback locale, then to
+ * the code itself. Texts may hold {name} placeholders, filled in from params.
+ */
+export class MessageCatalog {
+  private readonly locales = new Map<string, Texts>();
+
+  constructor(private readonly fallback = "en") {}
+
+  /** Adds the texts of locale, replacing those added before */
+  add(locale: string, texts: Texts): void {
+    this.locales.set(locale, texts);
+  }
+
+  text(code: string, locale: string, params?: Readonly<Record<string, string | number>>): string {
+    const text = this.find(code, locale) ?? code;
+    if (!params) {
+      return text;
+    }
+    return text.replace(/\{(\w+)\}/g, (placeholder, name: string) => (name in params ? String(params[name]) : placeholder));
+  }
+
+  /** The codes of the schema locale has no text for, e.g. to check a translation */
+  missing(locale: string): string[] {
+    return TextCodes.filter((code) => !this.locales.get(locale)?.[code]);
+  }
+
+  private find(code: string, locale: string): string | undefined {
+    for (const candidate of [locale, locale.split("-")[0], this.fallback]) {
+      const text = this.locales.get(candidate)?.[code];
+      if (text) {
+        return text;
+      }
+    }
+    return undefined;
+  }
+}
+`
+
+const csharpTextCodesTemplate = `// Code generated by socketgen. DO NOT EDIT.
+using System.Collections.Generic;
+using System.Linq;
+using System.Text.RegularExpressions;
+
+namespace {{.PackageName | toPascalCase}} {
+    /// <summary>The codes of the enums of fields annotated with option (socketgen.text_code).</summary>
+    public static class TextCodes {
+        public static readonly IReadOnlyList<string> All = new[] {
+{{- range .Enums }}
+{{- range .Codes }}
+            "{{.Code}}",
+{{- end }}
+{{- end }}
+        };
+{{- range .Enums }}
+
+        /// <summary>Codes of the values of {{.FullName}}, by number.</summary>
+        public static readonly IReadOnlyDictionary<int, string> {{.Name}} = new Dictionary<int, string> {
+{{- range .Codes }}
+            [{{.Number}}] = "{{.Code}}",
+{{- end }}
+        };
+{{- end }}
+    }
+
+    /// <summary>
+    /// Looks up the texts of the codes the server sends in the catalogs of the locales. A code missing
+    /// from a locale falls back to its language ("pt-BR" to "pt"), then to the fallback locale, then
+    /// to the code itself. Texts may hold {name} placeholders, filled in from parameters.
+    /// </summary>
+    public class MessageCatalog {
+        private static readonly Regex Placeholder = new Regex(@"\{(\w+)\}");
+
+        private readonly string fallback;
+        private readonly Dictionary<string, IReadOnlyDictionary<string, string>> locales = new Dictionary<string, IReadOnlyDictionary<string, string>>();
+
+        public MessageCatalog(string fallback = "en") {
+            this.fallback = fallback;
+        }
+
+        /// <summary>Adds the texts of locale, replacing those added before.</summary>
+        public void Add(string locale, IReadOnlyDictionary<string, string> texts) {
+            lock (locales) {
+                locales[locale] = texts;
+            }
+        }
+
+        public string Text(string code, string locale, IReadOnlyDictionary<string, object> parameters = null) {
+            var text = Find(code, locale) ?? code;
+            if (parameters == null) {
+                return text;
+            }
+            return Placeholder.Replace(text, m => parameters.TryGetValue(m.Groups[1].Value, out var value) ? value?.ToString() ?? "" : m.Value);
+        }
+
+        /// <summary>The codes of the schema locale has no text for, e.g. to check a translation.</summary>
+        public IReadOnlyList<string> Missing(string locale) {
+            lock (locales) {
+                locales.TryGetValue(locale, out var texts);
+                return TextCodes.All.Where(code => texts == null || !texts.TryGetValue(code, out var text) || string.IsNullOrEmpty(text)).ToList();
+            }
+        }
+
+        private string Find(string code, string locale) {
+            lock (locales) {
+                foreach (var candidate in new[] { locale, locale.Split('-')[0], fallback }) {
+                    if (locales.TryGetValue(candidate, out var texts) && texts.TryGetValue(code, out var text) && !string.IsNullOrEmpty(text)) {
+                        return text;
+                    }
+                }
+            }
+            return null;
+        }
+    }
+}
+`
+
+const dartTextCodesTemplate = `// Code generated by socketgen. DO NOT EDIT.
+
+/// The codes of the enums of fields annotated with option (socketgen.text_code).
+const List<String> textCodes = [
+{{- range .Enums }}
+{{- range .Codes }}
+  '{{.Code}}',
+{{- end }}
+{{- end }}
+];
+{{- range .Enums }}
+
+/// Codes of the values of {{.FullName}}, by number.
+const Map<int, String> {{.Name | toCamelCase}}Codes = {
+{{- range .Codes }}
+  {{.Number}}: '{{.Code}}',
+{{- end }}
+};
+{{- end }}
+
+/// Looks up the texts of the codes the server sends in the catalogs of the locales. A code missing
+/// from a locale falls back to its language ("pt-BR" to "pt"), then to the fallback locale, then to
+/// the code itself. Texts may hold {name} placeholders, filled in from [params].
+class MessageCatalog {
+  MessageCatalog({this.fallback = 'en'});
+
+  final String fallback;
+  final Map<String, Map<String, String>> _locales = {};
+
+  static final RegExp _placeholder = RegExp(r'\{(\w+)\}');
+
+  /// Adds the texts of [locale], replacing those added before.
+  void add(String locale, Map<String, String> texts) {
+    _locales[locale] = texts;
+  }
+
+  String text(String code, String locale, [Map<String, Object>? params]) {
+    final text = _find(code, locale) ?? code;
+    if (params == null) {
+      return text;
+    }
+    return text.replaceAllMapped(_placeholder, (m) => params.containsKey(m[1]) ? '${params[m[1]]}' : m[0]!);
+  }
+
+  /// The codes of the schema [locale] has no text for, e.g. to check a translation.
+  List<String> missing(String locale) =>
+      textCodes.where((code) => (_locales[locale]?[code] ?? '').isEmpty).toList();
+
+  String? _find(String code, String locale) {
+    for (final candidate in [locale, locale.split('-').first, fallback]) {
+      final text = _locales[candidate]?[code];
+      if (text != null && text.isNotEmpty) {
+        return text;
+      }
+    }
+    return null;
+  }
+}
+`
+
+// generateTextCodes writes the text codes and the message catalog of a client language, if any
+// field is annotated with option (socketgen.text_code)
+func generateTextCodes(result *parser.ParseResult, lang, outDir string) error {
+	if !result.HasTextCodes() {
+		return nil
+	}
+	data := struct {
+		PackageName string
+		parser.TextCodes
+	}{result.PackageName, result.TextCodes()}
+	switch lang {
+	case "ts":
+		return writeTemplate(outDir, "PacketTexts.ts", "ts_texts", tsTextCodesTemplate, nil, data)
+	case "csharp":
+		return writeTemplate(outDir, "PacketTexts.cs", "csharp_texts", csharpTextCodesTemplate, template.FuncMap{"toPascalCase": toPascalCase}, data)
+	case "dart":
+		return writeTemplate(outDir, "packet_texts.dart", "dart_texts", dartTextCodesTemplate, template.FuncMap{"toCamelCase": toCamelCase}, data)
+	}
+	return nil
+}
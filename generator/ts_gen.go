@@ -12,24 +12,52 @@ import (
 const tsTemplate = `// Code generated by socketgen. DO NOT EDIT.
 import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
 
-const { GamePacket, Header, {{ range .Payloads }}{{ .Name }}, {{ end }} } = {{.PackageName}};
 type Header = {{.PackageName}}.Header;
 {{- range .Payloads }}
 type {{.Name}} = {{$.PackageName}}.{{.Name}};
 {{- end }}
+{{- if .HasValidation }}
+import { ValidationError{{range .Payloads}}{{if .Validated}}, validate{{.Name}}{{end}}{{end}} } from "./PacketValidation";
+{{- end }}
+
+export const SCHEMA_VERSION = "{{.SchemaVersion}}";
+export const SCHEMA_VERSION_HEADER = "X-Socketgen-Schema";
+// Browsers cannot read handshake headers, so the version is also offered as a WebSocket subprotocol
+export const SCHEMA_SUBPROTOCOL = "socketgen." + SCHEMA_VERSION;
+
+export function checkSchemaVersion(advertised: string | null | undefined): void {
+  if (advertised && advertised !== SCHEMA_VERSION) {
+    throw new Error("schema version mismatch: peer has " + advertised + ", this build has " + SCHEMA_VERSION);
+  }
+}
 
 export interface IPacketHandler {
 {{- range .Payloads }}
+{{- blockComment "  " .Doc }}
   on{{.Name}}(header: Header, msg: {{.Name}}): void;
 {{- end }}
+{{- if .HasValidation }}
+  /** Called instead of the payload's handler for packets breaking a constraint; without it, dispatch throws the error. */
+  onValidationError?(header: Header, error: ValidationError): void;
+{{- end }}
 }
 
 export function dispatch(data: Uint8Array, handler: IPacketHandler) {
-  const pkt = GamePacket.decode(data);
+  const pkt = {{.PackageName}}.{{.Wrapper}}.decode(data);
   
 {{- range $i, $p := .Payloads }}
-  {{if eq $i 0}}if{{else}}else if{{end}} (pkt.{{.FieldName | toCamelCase}}) {
-    handler.on{{.Name}}(pkt.header!, pkt.{{.FieldName | toCamelCase}}!);
+  {{if eq $i 0}}if{{else}}else if{{end}} ({{tsAttr "pkt" .FieldName}}) {
+{{- if .Validated }}
+    const error = validate{{.Name}}({{tsAttr "pkt" .FieldName}}!);
+    if (error) {
+      if (!handler.onValidationError) {
+        throw error;
+      }
+      handler.onValidationError(pkt.header!, error);
+      return;
+    }
+{{- end }}
+    handler.on{{.Name}}(pkt.header!, {{tsAttr "pkt" .FieldName}}!);
   }
 {{- end }}
 }
@@ -49,11 +77,11 @@ export async function serve(stream: IPacketStream, handler: IPacketHandler) {
 {{- range .Payloads }}
 
 export async function send{{.Name}}(stream: IPacketStream, header: Header, msg: {{.Name}}): Promise<void> {
-  const pkt = GamePacket.fromPartial({
+  const pkt = {{$.PackageName}}.{{$.Wrapper}}.fromPartial({
     header: header,
-    {{.FieldName | toCamelCase}}: msg,
+    {{tsKey .FieldName}}: msg,
   });
-  const data = GamePacket.encode(pkt).finish();
+  const data = {{$.PackageName}}.{{$.Wrapper}}.encode(pkt).finish();
   await stream.writePacket(data);
 }
 {{- end }}
@@ -61,7 +89,9 @@ export async function send{{.Name}}(stream: IPacketStream, header: Header, msg:
 
 func GenerateTS(result *parser.ParseResult, outDir string) error {
 	funcMap := template.FuncMap{
-		"toCamelCase": toCamelCase,
+		"blockComment": blockComment,
+		"tsAttr":       tsAttr,
+		"tsKey":        tsKey,
 	}
 
 	tmpl, err := template.New("ts").Funcs(funcMap).Parse(tsTemplate)
@@ -80,5 +110,31 @@ func GenerateTS(result *parser.ParseResult, outDir string) error {
 	}
 	defer f.Close()
 
-	return tmpl.Execute(f, result)
+	if err := tmpl.Execute(f, result); err != nil {
+		return err
+	}
+	if result.HasValidation() {
+		if err := generateValidation(result, "ts", outDir); err != nil {
+			return err
+		}
+	}
+	if err := generateTSResume(result, outDir); err != nil {
+		return err
+	}
+	if err := generateTSErrors(result, outDir); err != nil {
+		return err
+	}
+	if err := generateLifecycle(result, "ts", outDir); err != nil {
+		return err
+	}
+	if err := generateSnapshots(result, "ts", outDir); err != nil {
+		return err
+	}
+	if err := generateInputs(result, "ts", outDir); err != nil {
+		return err
+	}
+	if err := generateTextCodes(result, "ts", outDir); err != nil {
+		return err
+	}
+	return generateTSRPC(result, outDir)
 }
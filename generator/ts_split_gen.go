@@ -0,0 +1,296 @@
+package generator
+
+import (
+	"path/filepath"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// The split client never decodes a GamePacket with its codec, which references the codec of every
+// payload: it reads the header and the payload bytes off the wire itself, and decodes the payload
+// with the codec of the route registered for its number. A bundler then keeps the codecs of the
+// payloads the application registers or sends, and nothing else. Every payload module refers only
+// to its own message, so the modules of lazy payloads become chunks of their own.
+
+const tsRoutesTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+import type { IPacketStream } from "./PacketDispatcher";
+
+type Header = {{.PackageName}}.Header;
+
+/** Encodes and decodes one message type, as the static methods of the message classes do */
+export interface Codec<T> {
+  encode(message: T): { finish(): Uint8Array };
+  decode(input: Uint8Array): T;
+}
+
+/** A payload: its field number in {{.Wrapper}} and its codec. Each module of payloads/ exports one. */
+export interface Route<T> {
+  readonly name: string;
+  readonly number: number;
+  readonly codec: Codec<T>;
+  readonly validate?: (msg: T) => Error | undefined;
+}
+
+export type Handler<T> = (header: Header, msg: T) => void;
+
+const HEADER_FIELD = {{.HeaderNumber}};
+
+function readVarint(data: Uint8Array, pos: number): [number, number] {
+  let value = 0;
+  for (let shift = 1; pos < data.length; shift *= 128) {
+    const b = data[pos++];
+    value += (b & 0x7f) * shift;
+    if (b < 0x80) {
+      return [value, pos];
+    }
+  }
+  throw new Error("truncated packet");
+}
+
+function varint(value: number): number[] {
+  const bytes: number[] = [];
+  while (value >= 0x80) {
+    bytes.push((value % 128) | 0x80);
+    value = Math.floor(value / 128);
+  }
+  bytes.push(value);
+  return bytes;
+}
+
+/** The header of a packet, and the number and the still encoded bytes of its payload (0 and undefined without one) */
+export interface SplitPacket {
+  header: Header;
+  number: number;
+  payload?: Uint8Array;
+}
+
+/** Splits a {{.Wrapper}} into its header and its payload, without decoding the payload */
+export function splitPacket(data: Uint8Array): SplitPacket {
+  let header: Uint8Array | undefined;
+  let number = 0;
+  let payload: Uint8Array | undefined;
+  let pos = 0;
+  while (pos < data.length) {
+    let tag: number;
+    [tag, pos] = readVarint(data, pos);
+    const field = Math.floor(tag / 8);
+    switch (tag % 8) {
+      case 0:
+        [, pos] = readVarint(data, pos);
+        break;
+      case 1:
+        pos += 8;
+        break;
+      case 2: {
+        let length: number;
+        [length, pos] = readVarint(data, pos);
+        const bytes = data.subarray(pos, pos + length);
+        pos += length;
+        if (field === HEADER_FIELD) {
+          header = bytes;
+        } else {
+          // Every other field of {{.Wrapper}} is a payload, possibly of a newer schema
+          number = field;
+          payload = bytes;
+        }
+        break;
+      }
+      case 5:
+        pos += 4;
+        break;
+      default:
+        throw new Error("unsupported wire type in field " + field);
+    }
+  }
+  if (pos > data.length) {
+    throw new Error("truncated packet");
+  }
+  return { header: {{.PackageName}}.Header.decode(header ?? new Uint8Array()), number, payload };
+}
+
+/** Encodes a {{.Wrapper}} of header and msg, the payload of route */
+export function joinPacket<T>(header: Header, route: Route<T>, msg: T): Uint8Array {
+  const headerBytes = {{.PackageName}}.Header.encode(header).finish();
+  const payloadBytes = route.codec.encode(msg).finish();
+  const headerPrefix = [...varint(HEADER_FIELD * 8 + 2), ...varint(headerBytes.length)];
+  const payloadPrefix = [...varint(route.number * 8 + 2), ...varint(payloadBytes.length)];
+  const data = new Uint8Array(headerPrefix.length + headerBytes.length + payloadPrefix.length + payloadBytes.length);
+  let pos = 0;
+  for (const part of [headerPrefix, headerBytes, payloadPrefix, payloadBytes]) {
+    data.set(part, pos);
+    pos += part.length;
+  }
+  return data;
+}
+
+/**
+ * Hands packets to the handlers registered for their payloads. Only the routes registered are
+ * bundled; lazy routes are loaded when their first packet arrives, and the packets after it wait,
+ * so handlers always see packets in order.
+ */
+export class Dispatcher {
+  private readonly routes = new Map<number, { route: Route<any>; handler: Handler<any> }>();
+  private readonly lazy = new Map<number, { load: () => Promise<Route<any>>; handler: Handler<any> }>();
+  private queue: Promise<void> | undefined;
+
+  /** Called for packets whose payload has no handler, e.g. one of a newer schema */
+  onUnhandled?: (header: Header, number: number) => void;
+  /** Called instead of the payload's handler for packets breaking a constraint; without it, dispatch throws the error. */
+  onValidationError?: (header: Header, error: Error) => void;
+
+  on<T>(route: Route<T>, handler: Handler<T>): this {
+    this.routes.set(route.number, { route, handler });
+    return this;
+  }
+
+  /** Registers handler for the payload number, whose route load imports on first use */
+  onLazy<T>(number: number, load: () => Promise<Route<T>>, handler: Handler<T>): this {
+    this.lazy.set(number, { load, handler });
+    return this;
+  }
+
+  /** Dispatches a packet; the promise, if any, settles once a lazy route was loaded and the packet handled */
+  dispatch(data: Uint8Array): Promise<void> | undefined {
+    if (this.queue) {
+      return this.track(this.queue.catch(() => {}).then(() => this.handle(data)));
+    }
+    const pending = this.handle(data);
+    return pending ? this.track(pending) : undefined;
+  }
+
+  private track(pending: Promise<void>): Promise<void> {
+    this.queue = pending;
+    const settled = () => {
+      if (this.queue === pending) {
+        this.queue = undefined;
+      }
+    };
+    pending.then(settled, settled);
+    return pending;
+  }
+
+  private handle(data: Uint8Array): Promise<void> | undefined {
+    const { header, number, payload } = splitPacket(data);
+    const registered = this.routes.get(number);
+    if (registered && payload) {
+      this.call(registered.route, registered.handler, header, payload);
+      return undefined;
+    }
+    const lazy = this.lazy.get(number);
+    if (lazy && payload) {
+      return lazy.load().then((route) => {
+        this.routes.set(number, { route, handler: lazy.handler });
+        this.lazy.delete(number);
+        this.call(route, lazy.handler, header, payload);
+      });
+    }
+    this.onUnhandled?.(header, number);
+    return undefined;
+  }
+
+  private call<T>(route: Route<T>, handler: Handler<T>, header: Header, payload: Uint8Array): void {
+    const msg = route.codec.decode(payload);
+    const error = route.validate?.(msg);
+    if (error) {
+      if (!this.onValidationError) {
+        throw error;
+      }
+      this.onValidationError(header, error);
+      return;
+    }
+    handler(header, msg);
+  }
+}
+
+export async function serve(stream: IPacketStream, dispatcher: Dispatcher): Promise<void> {
+  while (true) {
+    const data = await stream.readPacket();
+    await dispatcher.dispatch(data);
+  }
+}
+
+export async function send<T>(stream: IPacketStream, header: Header, route: Route<T>, msg: T): Promise<void> {
+  await stream.writePacket(joinPacket(header, route, msg));
+}
+`
+
+const tsPayloadRouteTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "../packet"; // Adjust import path as needed
+import type { IPacketStream } from "../PacketDispatcher";
+import { send, type Route } from "../PacketRoutes";
+{{- if .Validated }}
+import { validate{{.Name}} } from "../PacketValidation";
+{{- end }}
+
+type Header = {{.PackageName}}.Header;
+type {{.Name}} = {{.PackageName}}.{{.Name}};
+{{ blockComment "" .Doc }}
+export const {{.Name}}Route: Route<{{.Name}}> = {
+  name: "{{.Name}}",
+  number: {{.Number}},
+  codec: {{.PackageName}}.{{.Name}},
+{{- if .Validated }}
+  validate: validate{{.Name}},
+{{- end }}
+};
+
+export function send{{.Name}}(stream: IPacketStream, header: Header, msg: {{.Name}}): Promise<void> {
+  return send(stream, header, {{.Name}}Route, msg);
+}
+`
+
+const tsLazyTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import type { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+import type { Dispatcher, Handler, Route } from "./PacketRoutes";
+{{- range .Payloads }}
+
+/** Imports the route of {{.Name}}, bundled apart as it is marked with option (socketgen.lazy) */
+export const load{{.Name}} = (): Promise<Route<{{$.PackageName}}.{{.Name}}>> => import("./payloads/{{.Name}}").then((m) => m.{{.Name}}Route);
+
+/** Registers handler for {{.Name}}, loading its code when the first one arrives */
+export function on{{.Name}}(dispatcher: Dispatcher, handler: Handler<{{$.PackageName}}.{{.Name}}>): Dispatcher {
+  return dispatcher.onLazy({{.Number}}, load{{.Name}}, handler);
+}
+{{- end }}
+`
+
+// GenerateTSSplit generates the TypeScript client as modules a bundler can tree-shake: the
+// dispatcher in PacketRoutes.ts, a module per payload in payloads/, and PacketLazy.ts loading the
+// payloads marked with option (socketgen.lazy) on first use
+func GenerateTSSplit(result *parser.ParseResult, outDir string) error {
+	data := struct {
+		*parser.ParseResult
+		HeaderNumber int
+	}{ParseResult: result}
+	if header := result.Schema.Packet.Fields().ByName("header"); header != nil {
+		data.HeaderNumber = int(header.Number())
+	}
+	if err := writeTemplate(outDir, "PacketRoutes.ts", "ts_routes", tsRoutesTemplate, nil, data); err != nil {
+		return err
+	}
+
+	// The modules import the messages from the namespace of the bindings, like the rest of the
+	// TypeScript output
+	funcMap := template.FuncMap{"blockComment": blockComment}
+	for i := range result.Payloads {
+		p := &result.Payloads[i]
+		route := struct {
+			*parser.PayloadMessage
+			PackageName string
+		}{p, result.PackageName}
+		if err := writeTemplate(filepath.Join(outDir, "payloads"), p.Name+".ts", "ts_payload_route", tsPayloadRouteTemplate, funcMap, route); err != nil {
+			return err
+		}
+	}
+
+	if lazy := result.LazyPayloads(); len(lazy) > 0 {
+		data := struct {
+			PackageName string
+			Payloads    []parser.PayloadMessage
+		}{result.PackageName, lazy}
+		return writeTemplate(outDir, "PacketLazy.ts", "ts_lazy", tsLazyTemplate, nil, data)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTSSplitUsesPacketNamespace(t *testing.T) {
+	result, dir := parseSchema(t, strings.Replace(testSchema, "// @socketgen broadcast", "// @socketgen broadcast lazy", 1))
+	out := filepath.Join(dir, "ts")
+	if err := GenerateTSSplit(result, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// A named import of a message binds to ts-proto output, which the rest of the client does not use
+	namedImport := regexp.MustCompile(`import (type )?\{[^}]*\b(Header|LoginReq|ChatMsg|ChatEvent|ErrorRes)\b[^}]*\} from "\.\.?/packet"`)
+	files := map[string][]string{
+		"PacketRoutes.ts":       {`import { packet } from "./packet";`, "packet.Header.decode(", "packet.Header.encode("},
+		"payloads/ChatMsg.ts":   {`import { packet } from "../packet";`, "type ChatMsg = packet.ChatMsg;", "codec: packet.ChatMsg,"},
+		"payloads/LoginReq.ts":  {`import { packet } from "../packet";`, "codec: packet.LoginReq,"},
+		"payloads/ChatEvent.ts": {"codec: packet.ChatEvent,"},
+		"PacketLazy.ts":         {`import type { packet } from "./packet";`, "Route<packet.ChatEvent>", "Handler<packet.ChatEvent>"},
+	}
+	for name, wants := range files {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := string(data)
+		if m := namedImport.FindString(src); m != "" {
+			t.Errorf("%s imports a message by name: %s", name, m)
+		}
+		for _, want := range wants {
+			if !strings.Contains(src, want) {
+				t.Errorf("%s lacks %q:\n%s", name, want, src)
+			}
+		}
+	}
+}
@@ -1,10 +1,34 @@
 package generator
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"text/template"
 	"unicode"
 )
 
+// writeTemplate renders the named template text with data into outDir/fileName, creating outDir if needed
+func writeTemplate(outDir, fileName, name, text string, funcMap template.FuncMap, data any) error {
+	tmpl, err := template.New(name).Funcs(funcMap).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	f, err := os.Create(filepath.Join(outDir, fileName))
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer f.Close()
+
+	return tmpl.Execute(f, data)
+}
+
 func toCamelCase(s string) string {
 	// snake_case to camelCase
 	// e.g. login_req -> loginReq
@@ -48,3 +72,101 @@ func toPascalCase(s string) string {
 	}
 	return result.String()
 }
+
+func toGoName(s string) string {
+	// proto field name to the Go field name chosen by protoc-gen-go
+	// e.g. user_id -> UserId, login_req -> LoginReq
+
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_' && i == 0:
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Skip over '_' in "_{{lowercase}}"
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+
+			// Accept the lower case sequence that follows
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+func toKebabCase(s string) string {
+	// PascalCase to kebab-case
+	// e.g. MaintenanceMode -> maintenance-mode
+
+	var result strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				result.WriteRune('-')
+			}
+			result.WriteRune(unicode.ToLower(r))
+		} else {
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// lineComment returns text as comment lines starting with prefix (e.g., "\t// "), each after a
+// newline, for templates to place before a declaration; "" if text is empty
+func lineComment(prefix, text string) string {
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString("\n" + strings.TrimRight(prefix+line, " \t"))
+	}
+	return b.String()
+}
+
+// blockComment returns text as a /** */ doc comment indented by indent, after a newline; "" if
+// text is empty
+func blockComment(indent, text string) string {
+	if text == "" {
+		return ""
+	}
+	text = strings.ReplaceAll(text, "*/", "*\\/")
+	return "\n" + indent + "/**" + lineComment(indent+" * ", text) + "\n" + indent + " */"
+}
+
+// xmlDocComment returns text as a C# <summary> doc comment indented by indent, after a newline;
+// "" if text is empty
+func xmlDocComment(indent, text string) string {
+	if text == "" {
+		return ""
+	}
+	text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	return "\n" + indent + "/// <summary>" + lineComment(indent+"/// ", text) + "\n" + indent + "/// </summary>"
+}
+
+// pyDocstring returns text as a Python docstring indented by indent, after a newline; "" if text
+// is empty
+func pyDocstring(indent, text string) string {
+	if text == "" {
+		return ""
+	}
+	text = strings.NewReplacer(`\`, `\\`, `"""`, `\"\"\"`).Replace(text)
+	return "\n" + indent + `"""` + lineComment(indent, text) + "\n" + indent + `"""`
+}
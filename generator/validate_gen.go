@@ -0,0 +1,630 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// Validation checks the buf.validate and protoc-gen-validate constraints of the schema before
+// dispatch. Each rule becomes a condition that is true when a packet breaks it, written once per
+// language; rules that have no condition here are reported by ValidationWarnings and skipped.
+
+const goValidateTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}}
+{{- if .Imports }}
+
+import (
+{{- range .Imports }}
+	"{{.}}"
+{{- end }}
+)
+{{- end }}
+
+// ValidationError reports a payload field breaking one of the schema's buf.validate or
+// protoc-gen-validate constraints
+type ValidationError struct {
+	Payload string // Payload type name (e.g., "LoginReq")
+	Field   string // Field name (e.g., "user_id")
+	Rule    string // The broken rule (e.g., "string.min_len")
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Payload + "." + e.Field + ": " + e.Message
+}
+
+// ValidationErrorHandler can be implemented by a PacketHandler or a FalliblePacketHandler.
+// Dispatch calls it instead of the payload's handler for packets breaking a constraint; without
+// it, Dispatch returns the ValidationError. DispatchFallible also answers them with ErrorRes.
+type ValidationErrorHandler interface {
+	OnValidationError(header *Header, err *ValidationError)
+}
+
+// handleValidationError reports err to handler, if it is a ValidationErrorHandler, or returns it
+func handleValidationError(handler any, header *Header, err *ValidationError) error {
+	if h, ok := handler.(ValidationErrorHandler); ok {
+		h.OnValidationError(header, err)
+		return nil
+	}
+	return err
+}
+{{- range .Payloads }}
+{{- range .Patterns }}
+
+var {{.Name}} = regexp.MustCompile({{.Literal}})
+{{- end }}
+
+// Validate{{.Name}} checks msg against the constraints of {{.Name}}'s fields and returns the
+// first one it breaks, or nil
+func Validate{{.Name}}(msg *{{.Name}}) *ValidationError {
+	if msg == nil {
+		msg = &{{.Name}}{}
+	}
+{{- $payload := .Name }}
+{{- range .Checks }}
+	if {{.Cond}} {
+		return &ValidationError{Payload: "{{$payload}}", Field: "{{.Field}}", Rule: "{{.Rule}}", Message: {{.Message}}}
+	}
+{{- end }}
+	return nil
+}
+{{- end }}
+`
+
+const tsValidateTemplate = `// Code generated by socketgen. DO NOT EDIT.
+import { {{.PackageName}} } from "./packet"; // Adjust import path as needed
+{{- range .Payloads }}
+type {{.Name}} = {{$.PackageName}}.{{.Name}};
+{{- end }}
+
+/** A payload field breaking one of the schema's buf.validate or protoc-gen-validate constraints. */
+export class ValidationError extends Error {
+  constructor(
+    readonly payload: string,
+    readonly field: string,
+    readonly rule: string,
+    readonly detail: string,
+  ) {
+    super(payload + "." + field + ": " + detail);
+    this.name = "ValidationError";
+  }
+}
+{{- range .Payloads }}
+{{- range .Patterns }}
+
+const {{.Name}} = new RegExp({{.Literal}}, "u");
+{{- end }}
+
+/** Checks msg against the constraints of {{.Name}}'s fields and returns the first one it breaks. */
+export function validate{{.Name}}(msg: {{.Name}}): ValidationError | undefined {
+{{- $payload := .Name }}
+{{- range .Checks }}
+  if ({{.Cond}}) {
+    return new ValidationError("{{$payload}}", "{{.Field}}", "{{.Rule}}", {{.Message}});
+  }
+{{- end }}
+  return undefined;
+}
+{{- end }}
+`
+
+const javaValidationErrorTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+
+/** A payload field breaking one of the schema's buf.validate or protoc-gen-validate constraints. */
+public class ValidationError extends RuntimeException {
+    private final String payload;
+    private final String field;
+    private final String rule;
+
+    public ValidationError(String payload, String field, String rule, String message) {
+        super(payload + "." + field + ": " + message);
+        this.payload = payload;
+        this.field = field;
+        this.rule = rule;
+    }
+
+    /** Payload type name (e.g., "LoginReq"). */
+    public String getPayload() {
+        return payload;
+    }
+
+    /** Field name (e.g., "user_id"). */
+    public String getField() {
+        return field;
+    }
+
+    /** The broken rule (e.g., "string.min_len"). */
+    public String getRule() {
+        return rule;
+    }
+}
+`
+
+const javaValidatorTemplate = `// Code generated by socketgen. DO NOT EDIT.
+package {{.PackageName}};
+{{- range .Payloads }}
+
+import {{$.PackageName}}.{{.Name}};
+{{- end }}
+{{- if .Imports }}
+{{ range .Imports }}
+import {{.}};
+{{- end }}
+{{- end }}
+
+/** Checks payloads against the schema's buf.validate or protoc-gen-validate constraints. */
+public final class PacketValidator {
+    private PacketValidator() {}
+{{- range .Payloads }}
+{{- range .Patterns }}
+
+    private static final Pattern {{.Name}} = Pattern.compile({{.Literal}});
+{{- end }}
+
+    /** Returns the first constraint of {{.Name}}'s fields that msg breaks, or null. */
+    public static ValidationError validate{{.Name}}({{.Name}} msg) {
+{{- $payload := .Name }}
+{{- range .Checks }}
+        if ({{.Cond}}) {
+            return new ValidationError("{{$payload}}", "{{.Field}}", "{{.Rule}}", {{.Message}});
+        }
+{{- end }}
+        return null;
+    }
+{{- end }}
+}
+`
+
+// validationData is the data of the validation templates of one language
+type validationData struct {
+	*parser.ParseResult
+	Imports  []string
+	Payloads []validatedPayload
+}
+
+type validatedPayload struct {
+	Name     string
+	Checks   []validationCheck
+	Patterns []validationPattern
+}
+
+// validationCheck is a rule of a field, as a condition that holds when a message breaks it
+type validationCheck struct {
+	Field   string // Proto field name
+	Rule    string // e.g., "string.min_len"
+	Cond    string
+	Message string // Quoted for the language
+}
+
+// validationPattern is a compiled regular expression of a pattern rule
+type validationPattern struct {
+	Name    string
+	Literal string
+}
+
+// ValidationWarnings lists the constraints of the schema that generated code does not check
+func ValidationWarnings(result *parser.ParseResult) []string {
+	var warnings []string
+	for _, p := range result.Payloads {
+		for _, f := range p.Fields {
+			for _, rule := range f.Rules {
+				if _, ok := ruleCheck("go", result, p.Name, f, rule); !ok {
+					warnings = append(warnings, fmt.Sprintf("%s.%s: constraint %s is not supported and will not be checked", p.Name, f.Name, ruleName(rule)))
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+// buildValidation turns the constraints of every payload into checks in lang
+func buildValidation(result *parser.ParseResult, lang string) (*validationData, error) {
+	data := &validationData{ParseResult: result}
+	imports := map[string]bool{}
+	for _, p := range result.Payloads {
+		if !p.Validated() {
+			continue
+		}
+		vp := validatedPayload{Name: p.Name}
+		for _, f := range p.Fields {
+			for _, rule := range f.Rules {
+				cond, ok := ruleCheck(lang, result, p.Name, f, rule)
+				if !ok {
+					continue
+				}
+				if rule.Name == "pattern" {
+					if _, err := regexp.Compile(rule.Value); err != nil {
+						return nil, fmt.Errorf("%s.%s: invalid pattern: %w", p.Name, f.Name, err)
+					}
+					vp.Patterns = append(vp.Patterns, validationPattern{Name: patternName(lang, p.Name, f.Name), Literal: quoteFor(lang, rule.Value)})
+				}
+				vp.Checks = append(vp.Checks, validationCheck{
+					Field:   f.Name,
+					Rule:    ruleName(rule),
+					Cond:    cond,
+					Message: quoteFor(lang, ruleMessage(f, rule)),
+				})
+			}
+		}
+		data.Payloads = append(data.Payloads, vp)
+
+		for _, c := range vp.Checks {
+			if lang == "go" && strings.Contains(c.Cond, "strings.") {
+				imports["strings"] = true
+			}
+			if lang == "go" && strings.Contains(c.Cond, "utf8.") {
+				imports["unicode/utf8"] = true
+			}
+		}
+		if len(vp.Patterns) > 0 {
+			imports[map[string]string{"go": "regexp", "java": "java.util.regex.Pattern"}[lang]] = true
+		}
+	}
+	for _, imp := range []string{"regexp", "strings", "unicode/utf8", "java.util.regex.Pattern"} {
+		if imports[imp] {
+			data.Imports = append(data.Imports, imp)
+		}
+	}
+	return data, nil
+}
+
+// generateValidation writes the constraint checks called by the dispatcher of lang (go, ts, java)
+func generateValidation(result *parser.ParseResult, lang, outDir string) error {
+	data, err := buildValidation(result, lang)
+	if err != nil {
+		return err
+	}
+	switch lang {
+	case "go":
+		return writeTemplate(outDir, "packet_validate.go", "go_validate", goValidateTemplate, nil, data)
+	case "ts":
+		return writeTemplate(outDir, "PacketValidation.ts", "ts_validate", tsValidateTemplate, nil, data)
+	case "java":
+		if err := writeTemplate(outDir, "ValidationError.java", "java_validation_error", javaValidationErrorTemplate, nil, data); err != nil {
+			return err
+		}
+		return writeTemplate(outDir, "PacketValidator.java", "java_validate", javaValidatorTemplate, nil, data)
+	}
+	return fmt.Errorf("validation is not supported for %s", lang)
+}
+
+func ruleName(rule parser.ValidationRule) string {
+	if rule.Type == "" {
+		return rule.Name
+	}
+	return rule.Type + "." + rule.Name
+}
+
+func patternName(lang, payload, field string) string {
+	if lang == "java" {
+		return strings.ToUpper(strings.ReplaceAll(toKebabCase(payload), "-", "_")+"_"+field) + "_PATTERN"
+	}
+	return toCamelCase(payload) + toPascalCase(field) + "Pattern"
+}
+
+// numericKinds are the proto scalar kinds compared as numbers
+var numericKinds = map[string]bool{
+	"float": true, "double": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+}
+
+// ruleCheck returns the condition, in lang, that holds when msg breaks rule. ok is false for
+// rules that are not supported, or that do not fit the field.
+func ruleCheck(lang string, result *parser.ParseResult, payload string, f parser.MessageField, rule parser.ValidationRule) (cond string, ok bool) {
+	singular := !f.Repeated && !f.Map
+	if rule.Type == "" {
+		if rule.Name != "required" || rule.Value != "true" || !(singular && (f.Kind == "message" || f.Optional)) {
+			return "", false
+		}
+		return fieldAbsent(lang, f), true
+	}
+
+	v := fieldValue(lang, f)
+	switch {
+	case rule.Type == "string" && f.Kind == "string" && singular:
+		cond = stringCheck(lang, rule, v, patternName(lang, payload, f.Name))
+	case rule.Type == "bytes" && f.Kind == "bytes" && singular:
+		cond = sizeCheck(rule, map[string]string{"go": "len(" + v + ")", "ts": v + ".length", "java": v + ".size()"}[lang], "len", "min_len", "max_len")
+	case rule.Type == f.Kind && numericKinds[f.Kind] && singular:
+		cond = numberCheck(lang, f, rule, v)
+	case rule.Type == "enum" && f.Kind == "enum" && singular:
+		if rule.Name == "defined_only" {
+			if rule.Value == "true" {
+				cond = enumUndefined(lang, result, f, v)
+			}
+		} else {
+			cond = numberCheck(lang, f, rule, v)
+		}
+	case rule.Type == "bool" && f.Kind == "bool" && singular && rule.Name == "const":
+		cond = "!" + v
+		if rule.Value == "false" {
+			cond = v
+		}
+	case rule.Type == "repeated" && f.Repeated:
+		cond = sizeCheck(rule, map[string]string{"go": "len(" + v + ")", "ts": v + ".length", "java": v + ".size()"}[lang], "", "min_items", "max_items")
+	case rule.Type == "map" && f.Map:
+		cond = sizeCheck(rule, map[string]string{"go": "len(" + v + ")", "ts": "Object.keys(" + v + ").length", "java": v + ".size()"}[lang], "", "min_pairs", "max_pairs")
+	}
+	if cond == "" {
+		return "", false
+	}
+	if f.Optional {
+		// Constraints of a field with presence only apply when it is set
+		cond = fieldPresent(lang, f) + " && (" + cond + ")"
+	}
+	return cond, true
+}
+
+// fieldValue returns the expression reading f from msg
+func fieldValue(lang string, f parser.MessageField) string {
+	switch lang {
+	case "go":
+		if f.Optional {
+			return "*msg." + goFieldName(f.Name)
+		}
+		return "msg." + goFieldName(f.Name)
+	case "ts":
+		return tsAttr("msg", f.Name)
+	}
+	name := javaAccessor(toPascalCase(f.Name))
+	switch {
+	case f.Repeated:
+		return "msg.get" + name + "List()"
+	case f.Map:
+		return "msg.get" + name + "Map()"
+	case f.Kind == "enum":
+		return "msg.get" + name + "Value()"
+	}
+	return "msg.get" + name + "()"
+}
+
+func fieldPresent(lang string, f parser.MessageField) string {
+	switch lang {
+	case "go":
+		return "msg." + goFieldName(f.Name) + " != nil"
+	case "ts":
+		return tsAttr("msg", f.Name) + " !== undefined"
+	}
+	return "msg.has" + javaAccessor(toPascalCase(f.Name)) + "()"
+}
+
+func fieldAbsent(lang string, f parser.MessageField) string {
+	switch lang {
+	case "go":
+		return "msg." + goFieldName(f.Name) + " == nil"
+	case "ts":
+		return tsAttr("msg", f.Name) + " === undefined || " + tsAttr("msg", f.Name) + " === null"
+	}
+	return "!msg.has" + javaAccessor(toPascalCase(f.Name)) + "()"
+}
+
+// sizeCheck compares the size of a string, bytes, list, or map; an empty rule name is not supported
+func sizeCheck(rule parser.ValidationRule, size, exact, minimum, maximum string) string {
+	switch rule.Name {
+	case "":
+	case exact:
+		return size + " != " + rule.Value
+	case minimum:
+		return size + " < " + rule.Value
+	case maximum:
+		return size + " > " + rule.Value
+	}
+	return ""
+}
+
+// stringCheck checks a string rule; pattern names the compiled pattern of the field
+func stringCheck(lang string, rule parser.ValidationRule, v, pattern string) string {
+	q := quoteFor(lang, rule.Value)
+	switch rule.Name {
+	case "const":
+		return "!(" + stringEquals(lang, v, q) + ")"
+	case "in", "not_in":
+		if len(rule.Values) == 0 {
+			return ""
+		}
+		var eqs []string
+		for _, value := range rule.Values {
+			eqs = append(eqs, stringEquals(lang, v, quoteFor(lang, value)))
+		}
+		if rule.Name == "in" {
+			return "!(" + strings.Join(eqs, " || ") + ")"
+		}
+		return strings.Join(eqs, " || ")
+	case "pattern":
+		return map[string]string{
+			"go":   "!" + pattern + ".MatchString(" + v + ")",
+			"ts":   "!" + pattern + ".test(" + v + ")",
+			"java": "!" + pattern + ".matcher(" + v + ").find()",
+		}[lang]
+	case "prefix", "suffix", "contains":
+		fn := map[string]map[string]string{
+			"go":   {"prefix": "strings.HasPrefix", "suffix": "strings.HasSuffix", "contains": "strings.Contains"},
+			"ts":   {"prefix": ".startsWith", "suffix": ".endsWith", "contains": ".includes"},
+			"java": {"prefix": ".startsWith", "suffix": ".endsWith", "contains": ".contains"},
+		}[lang][rule.Name]
+		if lang == "go" {
+			return "!" + fn + "(" + v + ", " + q + ")"
+		}
+		return "!" + v + fn + "(" + q + ")"
+	}
+
+	// Lengths count characters (code points), as in protovalidate
+	length := map[string]string{
+		"go":   "utf8.RuneCountInString(" + v + ")",
+		"ts":   "[..." + v + "].length",
+		"java": v + ".codePointCount(0, " + v + ".length())",
+	}[lang]
+	return sizeCheck(rule, length, "len", "min_len", "max_len")
+}
+
+func stringEquals(lang, v, q string) string {
+	switch lang {
+	case "go":
+		return v + " == " + q
+	case "ts":
+		return v + " === " + q
+	}
+	return v + ".equals(" + q + ")"
+}
+
+func numberCheck(lang string, f parser.MessageField, rule parser.ValidationRule, v string) string {
+	switch rule.Name {
+	case "const":
+		return numberCompare(lang, f.Kind, v, "!=", rule.Value)
+	case "gt":
+		return numberCompare(lang, f.Kind, v, "<=", rule.Value)
+	case "gte":
+		return numberCompare(lang, f.Kind, v, "<", rule.Value)
+	case "lt":
+		return numberCompare(lang, f.Kind, v, ">=", rule.Value)
+	case "lte":
+		return numberCompare(lang, f.Kind, v, ">", rule.Value)
+	case "in", "not_in":
+		if len(rule.Values) == 0 {
+			return ""
+		}
+		var eqs []string
+		for _, value := range rule.Values {
+			eqs = append(eqs, numberCompare(lang, f.Kind, v, "==", value))
+		}
+		if rule.Name == "in" {
+			return "!(" + strings.Join(eqs, " || ") + ")"
+		}
+		return strings.Join(eqs, " || ")
+	}
+	return ""
+}
+
+// numberCompare compares a field value with a literal, the way lang needs for the field's kind
+func numberCompare(lang, kind, v, op, literal string) string {
+	switch lang {
+	case "ts":
+		if op == "==" || op == "!=" {
+			op += "="
+		}
+		if strings.HasSuffix(kind, "64") && kind != "double" {
+			return "Number(" + v + ") " + op + " " + literal
+		}
+	case "java":
+		switch kind {
+		case "uint64", "fixed64":
+			return "Long.compareUnsigned(" + v + ", Long.parseUnsignedLong(\"" + literal + "\")) " + op + " 0"
+		case "uint32", "fixed32":
+			return "Integer.toUnsignedLong(" + v + ") " + op + " " + literal + "L"
+		case "int64", "sint64", "sfixed64":
+			return v + " " + op + " " + literal + "L"
+		case "float":
+			return v + " " + op + " " + literal + "f"
+		}
+	}
+	return v + " " + op + " " + literal
+}
+
+// enumUndefined returns the condition that an enum value has no name in the enum's generated code
+func enumUndefined(lang string, result *parser.ParseResult, f parser.MessageField, v string) string {
+	name := strings.TrimPrefix(f.TypeName, result.PackageName+".")
+	switch lang {
+	case "go":
+		return strings.ReplaceAll(name, ".", "_") + "_name[int32(" + v + ")] == \"\""
+	case "ts":
+		return result.PackageName + "." + strings.ReplaceAll(name, ".", "_") + "[" + v + "] === undefined"
+	}
+	return name + ".forNumber(" + v + ") == null"
+}
+
+// ruleMessage describes what a rule requires, e.g. "must be at least 3 characters"
+func ruleMessage(f parser.MessageField, rule parser.ValidationRule) string {
+	unit := "characters"
+	switch rule.Type {
+	case "bytes":
+		unit = "bytes"
+	case "repeated":
+		unit = "items"
+	case "map":
+		unit = "entries"
+	}
+
+	switch rule.Name {
+	case "required":
+		return "is required"
+	case "const":
+		if rule.Type == "string" {
+			return fmt.Sprintf("must equal %q", rule.Value)
+		}
+		return "must equal " + rule.Value
+	case "len":
+		return "must be exactly " + rule.Value + " " + unit
+	case "min_len", "min_items", "min_pairs":
+		return "must have at least " + rule.Value + " " + unit
+	case "max_len", "max_items", "max_pairs":
+		return "must have at most " + rule.Value + " " + unit
+	case "pattern":
+		return "must match the pattern " + rule.Value
+	case "prefix":
+		return fmt.Sprintf("must start with %q", rule.Value)
+	case "suffix":
+		return fmt.Sprintf("must end with %q", rule.Value)
+	case "contains":
+		return fmt.Sprintf("must contain %q", rule.Value)
+	case "gt":
+		return "must be greater than " + rule.Value
+	case "gte":
+		return "must be greater than or equal to " + rule.Value
+	case "lt":
+		return "must be less than " + rule.Value
+	case "lte":
+		return "must be less than or equal to " + rule.Value
+	case "in", "not_in":
+		values := rule.Values
+		if rule.Type == "string" {
+			values = nil
+			for _, v := range rule.Values {
+				values = append(values, strconv.Quote(v))
+			}
+		}
+		if rule.Name == "in" {
+			return "must be one of [" + strings.Join(values, ", ") + "]"
+		}
+		return "must not be one of [" + strings.Join(values, ", ") + "]"
+	case "defined_only":
+		return "must be a defined " + f.TypeName[strings.LastIndex(f.TypeName, ".")+1:] + " value"
+	}
+	return "breaks " + ruleName(rule)
+}
+
+// quoteFor returns s as a string literal of lang
+func quoteFor(lang, s string) string {
+	switch lang {
+	case "go":
+		return strconv.Quote(s)
+	case "ts":
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+
+	// Java string literals have no \x escapes; escape everything outside printable ASCII as UTF-16
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		default:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, "\\u%04x", unit)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
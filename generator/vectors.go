@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/sample"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Vector is a golden test vector: a canonical sample of one payload and its encoded bytes,
+// both on its own and wrapped in a GamePacket with a sample header
+type Vector struct {
+	Payload       string          `json:"payload"` // The payload field name (e.g., "login_req")
+	Name          string          `json:"-"`       // The payload type name (e.g., "LoginReq")
+	Message       string          `json:"message"`
+	HasMap        bool            `json:"hasMap"` // Map entry order is not fixed across implementations, so bytes may legitimately differ
+	PayloadJSON   json.RawMessage `json:"payloadJson"`
+	PayloadHex    string          `json:"payloadHex"`
+	PayloadBase64 string          `json:"payloadBase64"`
+	PacketJSON    json.RawMessage `json:"packetJson"`
+	PacketHex     string          `json:"packetHex"`
+	PacketBase64  string          `json:"packetBase64"`
+}
+
+// VectorFile is the content of vectors.json
+type VectorFile struct {
+	SchemaVersion string   `json:"schemaVersion"`
+	Vectors       []Vector `json:"vectors"`
+}
+
+// BuildVectors creates one golden vector per payload
+func BuildVectors(result *parser.ParseResult) ([]Vector, error) {
+	schema := result.Schema
+	marshal := proto.MarshalOptions{Deterministic: true}
+	toJSON := protojson.MarshalOptions{Resolver: schema.Types}
+
+	vectors := make([]Vector, 0, len(result.Payloads))
+	for _, p := range result.Payloads {
+		field := schema.PayloadByName(p.FieldName)
+		if field == nil || field.Message() == nil {
+			return nil, fmt.Errorf("payload %s is not a message field", p.FieldName)
+		}
+
+		msg := dynamicpb.NewMessage(field.Message())
+		sample.Fill(msg)
+
+		pkt := schema.NewPacket()
+		if schema.Header != nil {
+			sample.Fill(pkt.Mutable(schema.Header).Message())
+		}
+		pkt.Set(field, protoreflect.ValueOfMessage(msg))
+
+		payloadBytes, err := marshal.Marshal(msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", p.FieldName, err)
+		}
+		packetBytes, err := marshal.Marshal(pkt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode packet for %s: %w", p.FieldName, err)
+		}
+		payloadJSON, err := toJSON.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		packetJSON, err := toJSON.Marshal(pkt)
+		if err != nil {
+			return nil, err
+		}
+
+		hasMap := containsMap(field.Message(), map[protoreflect.FullName]bool{}) ||
+			(schema.Header != nil && containsMap(schema.Header.Message(), map[protoreflect.FullName]bool{}))
+
+		vectors = append(vectors, Vector{
+			Payload:       p.FieldName,
+			Name:          p.Name,
+			Message:       p.FullName,
+			HasMap:        hasMap,
+			PayloadJSON:   payloadJSON,
+			PayloadHex:    hex.EncodeToString(payloadBytes),
+			PayloadBase64: base64.StdEncoding.EncodeToString(payloadBytes),
+			PacketJSON:    packetJSON,
+			PacketHex:     hex.EncodeToString(packetBytes),
+			PacketBase64:  base64.StdEncoding.EncodeToString(packetBytes),
+		})
+	}
+
+	return vectors, nil
+}
+
+// containsMap reports whether md or any message reachable from it has a map field
+func containsMap(md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) bool {
+	if seen[md.FullName()] {
+		return false
+	}
+	seen[md.FullName()] = true
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsMap() {
+			return true
+		}
+		if fd.Message() != nil && containsMap(fd.Message(), seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateVectors writes vectors.json into outDir
+func GenerateVectors(result *parser.ParseResult, outDir string) error {
+	vectors, err := BuildVectors(result)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(VectorFile{
+		SchemaVersion: result.SchemaVersion,
+		Vectors:       vectors,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vectors: %w", err)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(outDir, "vectors.json"), append(data, '\n'), 0644)
+}
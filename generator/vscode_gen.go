@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// SnippetsFile is the VS Code snippets file GenerateVSCodeSnippets writes
+const SnippetsFile = "socketgen.code-snippets"
+
+// vscodeSnippet is an entry of a VS Code .code-snippets file
+type vscodeSnippet struct {
+	Scope       string   `json:"scope"`
+	Prefix      string   `json:"prefix"`
+	Body        []string `json:"body"`
+	Description string   `json:"description"`
+}
+
+// handlerStubs are the bodies of the handler method snippets of each language, templates of a
+// payload with its proto package; $0 is where the cursor lands. Dollar signs of the code itself are
+// escaped (\$), as snippets read them as placeholders.
+var handlerStubs = map[string]struct{ scope, prefix, body string }{
+	"go": {"go", "On{{.Name}}", `func (h *${1:Handler}) On{{.Name}}(header *{{.Package}}.Header, msg *{{.Package}}.{{.Name}}) {
+	$0
+}`},
+	"ts": {"typescript", "on{{.Name}}", `on{{.Name}}(header: Header, msg: {{.Name}}): void {
+	$0
+}`},
+	"python": {"python", "on_{{.FieldName}}", `def on_{{.FieldName}}(self, header, msg):
+	${0:pass}`},
+	"csharp": {"csharp", "On{{.Name}}", `public void On{{.Name}}(Header header, {{.Name}} msg)
+{
+	$0
+}`},
+	"dart": {"dart", "on{{.Name}}", `@override
+void on{{.Name}}(Header header, {{.Name}} msg) {
+	$0
+}`},
+	"php": {"php", "on{{.Name}}", `public function on{{.Name}}(Header \$header, {{.Name}} \$msg)
+{
+	$0
+}`},
+	"ruby": {"ruby", "on_{{.FieldName}}", `def on_{{.FieldName}}(header, msg)
+	$0
+end`},
+	"kotlin": {"kotlin", "on{{.Name}}", `override fun on{{.Name}}(header: Header, msg: {{.Name}}) {
+	$0
+}`},
+	"java": {"java", "on{{.Name}}", `@Override
+public void on{{.Name}}(Header header, {{.Name}} msg) {
+	$0
+}`},
+}
+
+// GenerateVSCodeSnippets writes SnippetsFile into dir (a .vscode directory), with a snippet per
+// payload and language stubbing the payload's handler method
+func GenerateVSCodeSnippets(result *parser.ParseResult, languages []string, dir string) error {
+	snippets := map[string]vscodeSnippet{}
+	for _, lang := range languages {
+		stub, ok := handlerStubs[lang]
+		if !ok {
+			return fmt.Errorf("no handler snippets for %s", lang)
+		}
+		prefix, err := template.New("prefix").Parse(stub.prefix)
+		if err != nil {
+			return err
+		}
+		body, err := template.New("body").Parse(stub.body)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range result.Payloads {
+			data := struct {
+				parser.PayloadMessage
+				Package string
+			}{p, result.PackageName}
+			var name, code strings.Builder
+			if err := prefix.Execute(&name, data); err != nil {
+				return err
+			}
+			if err := body.Execute(&code, data); err != nil {
+				return err
+			}
+
+			description := fmt.Sprintf("Handles %s, field %d of %s", p.FullName, p.Number, result.Wrapper)
+			if summary, _, _ := strings.Cut(p.Comment, "\n\n"); summary != "" {
+				description += ": " + strings.ReplaceAll(summary, "\n", " ")
+			}
+			snippets[fmt.Sprintf("socketgen %s %s", lang, name.String())] = vscodeSnippet{
+				Scope:       stub.scope,
+				Prefix:      name.String(),
+				Body:        strings.Split(code.String(), "\n"),
+				Description: description,
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(snippets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, SnippetsFile), append(data, '\n'), 0644)
+}
+
+// HasVSCodeSnippets reports whether GenerateVSCodeSnippets stubs handlers in lang
+func HasVSCodeSnippets(lang string) bool {
+	_, ok := handlerStubs[lang]
+	return ok
+}
@@ -0,0 +1,200 @@
+// Package lock maintains socketgen.lock, the history of the payloads of a schema, so packets
+// recorded under a payload that was renamed, superseded, or removed can still be traced to the
+// payload carrying them today.
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/snowmerak/socketgen/parser"
+)
+
+// FileName is the lockfile's name, next to packet.proto
+const FileName = "socketgen.lock"
+
+// MapFileName is the name of the mapping file gen writes next to the generated code
+const MapFileName = "payload_map.json"
+
+// Reasons a historical payload name maps to another payload
+const (
+	Renamed    = "renamed"
+	Superseded = "superseded"
+	Removed    = "removed"
+)
+
+// Payload is one payload field number of the schema, over its whole history
+type Payload struct {
+	Number       int32    `json:"number"`
+	Name         string   `json:"name"`                    // Current field name, or the last one if removed
+	Type         string   `json:"type"`                    // Message type name
+	FormerNames  []string `json:"former_names,omitempty"`  // Field names it had before, oldest first
+	SupersededBy string   `json:"superseded_by,omitempty"` // Field name of the payload replacing it, from option (socketgen.superseded_by)
+	Removed      bool     `json:"removed,omitempty"`       // No longer in the schema
+}
+
+// File is the content of socketgen.lock
+type File struct {
+	Payloads []Payload `json:"payloads"` // Sorted by number
+}
+
+// Mapping is a historical payload name and the payload now carrying its packets
+type Mapping struct {
+	Name          string `json:"name"`
+	Number        int32  `json:"number"`
+	Current       string `json:"current,omitempty"` // "" if the payload was removed without a successor
+	CurrentNumber int32  `json:"current_number,omitempty"`
+	Reason        string `json:"reason"` // Renamed, Superseded, or Removed
+}
+
+// Load reads a lockfile
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Save writes the lockfile to path
+func (f *File) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Update records the payloads of result, and returns a description of each change to the history.
+// A field number keeps its entry when the payload is renamed, so its former names are kept.
+func (f *File) Update(result *parser.ParseResult) []string {
+	fieldNames := map[string]string{} // Message type name -> field name
+	current := map[int32]bool{}
+	for _, p := range result.Payloads {
+		fieldNames[p.Name] = p.FieldName
+		current[p.Number] = true
+	}
+
+	var changes []string
+	for _, p := range result.Payloads {
+		i := slices.IndexFunc(f.Payloads, func(e Payload) bool { return e.Number == p.Number })
+		if i < 0 {
+			f.Payloads = append(f.Payloads, Payload{Number: p.Number, Name: p.FieldName, Type: p.Name})
+			i = len(f.Payloads) - 1
+			changes = append(changes, fmt.Sprintf("added %s (%d)", p.FieldName, p.Number))
+		}
+
+		e := &f.Payloads[i]
+		if e.Name != p.FieldName {
+			changes = append(changes, fmt.Sprintf("renamed %s (%d) to %s", e.Name, p.Number, p.FieldName))
+			e.FormerNames = slices.DeleteFunc(append(e.FormerNames, e.Name), func(name string) bool { return name == p.FieldName })
+			e.Name = p.FieldName
+		}
+		if e.Removed {
+			changes = append(changes, fmt.Sprintf("restored %s (%d)", p.FieldName, p.Number))
+			e.Removed = false
+		}
+		e.Type = p.Name
+		e.SupersededBy = fieldNames[p.SupersededBy]
+	}
+
+	for i := range f.Payloads {
+		if e := &f.Payloads[i]; !current[e.Number] && !e.Removed {
+			e.Removed = true
+			changes = append(changes, fmt.Sprintf("removed %s (%d)", e.Name, e.Number))
+		}
+	}
+
+	slices.SortFunc(f.Payloads, func(a, b Payload) int { return int(a.Number) - int(b.Number) })
+	return changes
+}
+
+// Mappings lists every payload name that no longer names a current payload, or that was
+// superseded, with the payload now carrying its packets
+func (f *File) Mappings() []Mapping {
+	current := map[string]bool{}
+	for _, e := range f.Payloads {
+		current[e.Name] = current[e.Name] || !e.Removed
+	}
+
+	mappings := []Mapping{}
+	for _, e := range f.Payloads {
+		target, reason := f.successor(e)
+		names := e.FormerNames
+		if reason != Renamed {
+			names = append(slices.Clone(names), e.Name)
+		}
+
+		for _, name := range names {
+			if current[name] && (name != e.Name || e.Removed) {
+				continue // A name reused by another payload
+			}
+			m := Mapping{Name: name, Number: e.Number, Reason: reason}
+			if target != nil {
+				m.Current, m.CurrentNumber = target.Name, target.Number
+			}
+			mappings = append(mappings, m)
+		}
+	}
+	return mappings
+}
+
+// successor returns the current payload carrying the packets of e, following supersessions,
+// and why it differs from e; Renamed if e is current itself
+func (f *File) successor(e Payload) (*Payload, string) {
+	reason := Renamed
+	seen := map[int32]bool{}
+	for e.SupersededBy != "" && !seen[e.Number] {
+		seen[e.Number] = true
+		i := slices.IndexFunc(f.Payloads, func(next Payload) bool { return next.Name == e.SupersededBy })
+		if i < 0 {
+			break
+		}
+		e, reason = f.Payloads[i], Superseded
+	}
+	if e.Removed {
+		return nil, Removed
+	}
+	return &e, reason
+}
+
+// Current returns the current payload field name for a payload name found in old packets or
+// reports, and false if the payload was removed or the name is unknown
+func (f *File) Current(name string) (string, bool) {
+	i := slices.IndexFunc(f.Payloads, func(e Payload) bool { return e.Name == name && !e.Removed })
+	if i >= 0 {
+		if target, _ := f.successor(f.Payloads[i]); target != nil {
+			return target.Name, true
+		}
+	}
+	for _, e := range f.Payloads {
+		if e.Name == name || slices.Contains(e.FormerNames, name) {
+			target, _ := f.successor(e)
+			if target == nil {
+				return "", false
+			}
+			return target.Name, true
+		}
+	}
+	return "", false
+}
+
+// WriteMap writes the mappings of f as JSON to path, for tools reading packets or reports keyed
+// by old payload names
+func (f *File) WriteMap(path, schemaVersion string) error {
+	data, err := json.MarshalIndent(struct {
+		SchemaVersion string    `json:"schemaVersion"`
+		Payloads      []Mapping `json:"payloads"`
+	}{schemaVersion, f.Mappings()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
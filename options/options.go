@@ -0,0 +1,38 @@
+// Package options holds socketgen's custom proto options (socketgen/options.proto) and their Go extension types.
+package options
+
+import (
+	"bytes"
+	_ "embed"
+	"os"
+	"path/filepath"
+)
+
+// ImportPath is the path schemas use to import the options (`import "socketgen/options.proto";`)
+const ImportPath = "socketgen/options.proto"
+
+// Proto is the source of socketgen/options.proto, written next to packet.proto by init and gen
+//
+//go:embed socketgen/options.proto
+var Proto []byte
+
+// Vendor writes the options to ImportPath under dir, unless the file there is already up to date.
+// It reports whether the file was written.
+func Vendor(dir string) (bool, error) {
+	path := filepath.Join(dir, ImportPath)
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, Proto) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, Proto, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Imported reports whether a schema's source imports the options
+func Imported(schema []byte) bool {
+	return bytes.Contains(schema, []byte(`"`+ImportPath+`"`))
+}
@@ -0,0 +1,182 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// annotationPrefix starts a comment line holding socketgen options, for schemas that cannot import
+// socketgen/options.proto (e.g., `// @socketgen direction=c2s responds_with=LoginRes`)
+const annotationPrefix = "@socketgen"
+
+// Field numbers of descriptor.proto used in SourceCodeInfo paths
+const (
+	fileMessageTypeTag   = 4 // FileDescriptorProto.message_type
+	messageFieldTag      = 2 // DescriptorProto.field
+	messageNestedTypeTag = 3 // DescriptorProto.nested_type
+)
+
+// applyAnnotations sets the options written as @socketgen comments on messages and fields, so
+// they read the same as custom options from then on. It needs the descriptor set's source info.
+func applyAnnotations(fds *descriptorpb.FileDescriptorSet) error {
+	for _, file := range fds.File {
+		for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+			pairs, err := parseAnnotation(loc.GetLeadingComments())
+			if err != nil {
+				return annotationError(file, loc, err)
+			}
+			if len(pairs) == 0 {
+				continue
+			}
+
+			var opts proto.Message
+			var target protoreflect.FullName
+			switch d := annotatedDescriptor(file, loc.GetPath()).(type) {
+			case *descriptorpb.DescriptorProto:
+				if d.Options == nil {
+					d.Options = &descriptorpb.MessageOptions{}
+				}
+				opts, target = d.Options, "google.protobuf.MessageOptions"
+			case *descriptorpb.FieldDescriptorProto:
+				if d.Options == nil {
+					d.Options = &descriptorpb.FieldOptions{}
+				}
+				opts, target = d.Options, "google.protobuf.FieldOptions"
+			default:
+				return annotationError(file, loc, fmt.Errorf("%s comments only apply to messages and fields", annotationPrefix))
+			}
+
+			for _, pair := range pairs {
+				if err := setAnnotation(opts, target, pair[0], pair[1]); err != nil {
+					return annotationError(file, loc, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func annotationError(file *descriptorpb.FileDescriptorProto, loc *descriptorpb.SourceCodeInfo_Location, err error) error {
+	line := 0
+	if len(loc.GetSpan()) > 0 {
+		line = int(loc.GetSpan()[0]) + 1
+	}
+	return fmt.Errorf("%s:%d: %w", file.GetName(), line, err)
+}
+
+// annotatedDescriptor returns the message or field at a SourceCodeInfo path, nil for anything else
+func annotatedDescriptor(file *descriptorpb.FileDescriptorProto, path []int32) any {
+	if len(path) < 2 || path[0] != fileMessageTypeTag || int(path[1]) >= len(file.MessageType) {
+		return nil
+	}
+	msg := file.MessageType[path[1]]
+	for path = path[2:]; len(path) >= 2; path = path[2:] {
+		switch index := int(path[1]); {
+		case path[0] == messageNestedTypeTag && index < len(msg.NestedType):
+			msg = msg.NestedType[index]
+		case path[0] == messageFieldTag && index < len(msg.Field) && len(path) == 2:
+			return msg.Field[index]
+		default:
+			return nil
+		}
+	}
+	if len(path) != 0 {
+		return nil
+	}
+	return msg
+}
+
+// parseAnnotation returns the key/value pairs of the @socketgen lines in a comment. A key without
+// a value is true, and values with spaces are double-quoted.
+func parseAnnotation(comment string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, line := range strings.Split(comment, "\n") {
+		rest, ok := annotationLine(line)
+		if !ok {
+			continue
+		}
+
+		for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+			end := strings.IndexFunc(rest, func(r rune) bool { return r == '=' || unicode.IsSpace(r) })
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("%s: missing option name before %q", annotationPrefix, rest)
+			}
+			if !strings.HasPrefix(rest, "=") {
+				pairs = append(pairs, [2]string{key, "true"})
+				continue
+			}
+
+			rest = rest[1:]
+			var value string
+			if strings.HasPrefix(rest, `"`) {
+				quoted, err := strconv.QuotedPrefix(rest)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: unterminated quoted value", annotationPrefix, key)
+				}
+				value, _ = strconv.Unquote(quoted)
+				rest = rest[len(quoted):]
+			} else {
+				end := strings.IndexFunc(rest, unicode.IsSpace)
+				if end < 0 {
+					end = len(rest)
+				}
+				value, rest = rest[:end], rest[end:]
+			}
+			pairs = append(pairs, [2]string{key, value})
+		}
+	}
+	return pairs, nil
+}
+
+// annotationLine reports whether a comment line holds @socketgen options, and returns them
+func annotationLine(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), annotationPrefix)
+	if !ok || rest != "" && !unicode.IsSpace(rune(rest[0])) {
+		return "", false
+	}
+	return rest, true
+}
+
+// setAnnotation sets option socketgen.<key> on opts, which extend target
+func setAnnotation(opts proto.Message, target protoreflect.FullName, key, value string) error {
+	xt, err := protoregistry.GlobalTypes.FindExtensionByName(protoreflect.FullName("socketgen." + key))
+	if err != nil || xt.TypeDescriptor().ContainingMessage().FullName() != target {
+		return fmt.Errorf("%s %s: no such option on %s", annotationPrefix, key, target.Name())
+	}
+	if proto.HasExtension(opts, xt) {
+		return fmt.Errorf("%s %s: option (socketgen.%s) is already set", annotationPrefix, key, key)
+	}
+
+	var v any
+	switch kind := xt.TypeDescriptor().Kind(); kind {
+	case protoreflect.StringKind:
+		v = value
+	case protoreflect.BoolKind:
+		v, err = strconv.ParseBool(value)
+	case protoreflect.Int32Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 0, 32)
+		v = int32(n)
+	case protoreflect.DoubleKind:
+		v, err = strconv.ParseFloat(value, 64)
+	default:
+		return fmt.Errorf("%s %s: options of type %s cannot be written as comments", annotationPrefix, key, kind)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: invalid value %q", annotationPrefix, key, value)
+	}
+	proto.SetExtension(opts, xt, v)
+	return nil
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/snowmerak/socketgen/toolchain"
+)
+
+// CacheDir, if set, is where LoadDescriptorSet keeps the descriptor sets it compiles, so running
+// socketgen again on unchanged proto files skips compiling them. Entries are keyed by the content
+// of the proto files, so the directory can be shared by concurrent runs and several projects.
+var CacheDir string
+
+// memoryCache holds the descriptor sets compiled by this process by cache key, so loading the
+// same files again (e.g. gen parsing both envelopes of packet.proto, or serve --watch reloading
+// after a change to the fixtures only) skips compiling even without CacheDir
+var memoryCache sync.Map
+
+// cacheFormat is part of every cache key; change it when the compilation changes
+const cacheFormat = "descriptor-set-v1"
+
+// importPattern matches the import statements of a proto file
+var importPattern = regexp.MustCompile(`(?m)^\s*import\s+(?:public\s+|weak\s+)?"([^"]+)"\s*;`)
+
+// cachedDescriptorSet returns the cache key of protoFile and the descriptor set cached under it,
+// in memory or in CacheDir. The key is "" if the files cannot be read, and the data is nil on a miss.
+func cachedDescriptorSet(protoFile string) (string, []byte) {
+	key, err := cacheKey(protoFile)
+	if err != nil {
+		return "", nil
+	}
+	if data, ok := memoryCache.Load(key); ok {
+		return key, data.([]byte)
+	}
+	if CacheDir == "" {
+		return key, nil
+	}
+	data, err := os.ReadFile(filepath.Join(CacheDir, key+".pb"))
+	if err != nil {
+		return key, nil
+	}
+	memoryCache.Store(key, data)
+	return key, data
+}
+
+// cacheDescriptorSet stores data, compiled from protoFile, under key. Caching is best effort, so
+// errors are ignored.
+func cacheDescriptorSet(protoFile, key string, data []byte) {
+	// A file saved while compiling may or may not be compiled into data
+	if current, err := cacheKey(protoFile); key == "" || err != nil || current != key {
+		return
+	}
+	memoryCache.Store(key, data)
+	if CacheDir == "" || os.MkdirAll(CacheDir, 0755) != nil {
+		return
+	}
+
+	// Written under a unique name and renamed into place, so concurrent runs never read a partial entry
+	f, err := os.CreateTemp(CacheDir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err != nil || closeErr != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if os.Rename(f.Name(), filepath.Join(CacheDir, key+".pb")) != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// cacheKey hashes the compiler's executable, the import paths and the names and content of protoFile and every
+// file it imports, directly or not. Imports are resolved like protoc does, from ImportPaths; an
+// import missing there is bundled with the compiler (e.g. google/protobuf/any.proto), which the
+// compiler stands for.
+func cacheKey(protoFile string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", cacheFormat, Compiler)
+	for _, dir := range ImportPaths {
+		fmt.Fprintf(h, "-I%s\x00", dir)
+	}
+	// The built-in compiler is part of socketgen, whose version is "(devel)" for every build from
+	// source, so its executable stands for it like protoc's does
+	compiler, err := os.Executable()
+	if Compiler == CompilerProtoc {
+		compiler, err = exec.LookPath(toolchain.Protoc())
+	}
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(compiler)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00", compiler, info.Size(), info.ModTime().UnixNano())
+
+	seen := map[string]bool{}
+	var hashFile func(name, path string) error
+	hashFile = func(name, path string) error {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(h, "%s\x00-\x00", name)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+
+		for _, m := range importPattern.FindAllSubmatch(data, -1) {
+			if err := hashFile(string(m[1]), SourcePath(string(m[1]))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := hashFile(protoName(protoFile), protoFile); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
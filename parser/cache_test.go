@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheKey(t *testing.T) {
+	files := map[string]string{
+		"packet.proto": `syntax = "proto3";
+package packet;
+
+import "common/header.proto";
+
+message GamePacket { Header header = 1; }
+`,
+		"common/header.proto": `syntax = "proto3";
+package packet;
+
+message Header { uint32 seq = 1; }
+`,
+	}
+	tests := []struct {
+		name    string
+		change  func(t *testing.T)
+		changed bool
+	}{
+		{"nothing", func(t *testing.T) {}, false},
+		{"schema", func(t *testing.T) { appendFile(t, "packet.proto", "message ChatMsg {}\n") }, true},
+		{"import", func(t *testing.T) { appendFile(t, "common/header.proto", "message Footer {}\n") }, true},
+		{"unrelated file", func(t *testing.T) { appendFile(t, "other.proto", "message Other {}\n") }, false},
+		{"import paths", func(t *testing.T) {
+			paths := ImportPaths
+			ImportPaths = []string{"."}
+			t.Cleanup(func() { ImportPaths = paths })
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFiles(t, files)
+			before, err := cacheKey("packet.proto")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t)
+			after, err := cacheKey("packet.proto")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := before != after; changed != tt.changed {
+				t.Errorf("key changed: %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestLoadDescriptorSetRecompilesChangedSchema(t *testing.T) {
+	writeFiles(t, map[string]string{"packet.proto": `syntax = "proto3";
+package packet;
+
+message GamePacket {}
+`})
+	dir := CacheDir
+	CacheDir = t.TempDir()
+	t.Cleanup(func() { CacheDir = dir })
+
+	messages := func() []string {
+		t.Helper()
+		fds, err := LoadDescriptorSet("packet.proto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, msg := range fds.File[len(fds.File)-1].MessageType {
+			names = append(names, msg.GetName())
+		}
+		return names
+	}
+
+	if got := messages(); len(got) != 1 {
+		t.Fatalf("got messages %v, want GamePacket", got)
+	}
+	key, _ := cacheKey("packet.proto")
+	if _, err := os.Stat(filepath.Join(CacheDir, key+".pb")); err != nil {
+		t.Errorf("the descriptor set is not cached: %v", err)
+	}
+
+	appendFile(t, "packet.proto", "message ChatMsg {}\n")
+	if got := messages(); len(got) != 2 {
+		t.Errorf("got messages %v after adding ChatMsg, want the schema compiled again", got)
+	}
+}
+
+func appendFile(t *testing.T, name, content string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.FromSlash(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// applyComments copies the comments of payload messages, their fields and the header fields from
+// the schema's source info, and records where the payloads are declared
+func applyComments(r *ParseResult) {
+	if r.Schema.Header != nil && r.Schema.Header.Message() != nil {
+		commentFields(r.Header, r.Schema.Header.Message())
+	}
+
+	for i := range r.Payloads {
+		p := &r.Payloads[i]
+		desc, err := r.Schema.Files.FindDescriptorByName(protoreflect.FullName(p.FullName))
+		if err != nil {
+			continue
+		}
+		md, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok {
+			continue
+		}
+		p.Comment = comment(md)
+		p.Source = fmt.Sprintf("%s at %s, field %d of %s", p.FullName, Position(md), p.Number, r.Wrapper)
+		commentFields(p.Fields, md)
+	}
+}
+
+func commentFields(fields []MessageField, md protoreflect.MessageDescriptor) {
+	for i := range fields {
+		if fd := md.Fields().ByNumber(protoreflect.FieldNumber(fields[i].Number)); fd != nil {
+			fields[i].Comment = comment(fd)
+		}
+	}
+}
+
+// comment returns the leading comment of d, or its trailing comment if it has none, without
+// @socketgen annotations and comment markers
+func comment(d protoreflect.Descriptor) string {
+	loc := d.ParentFile().SourceLocations().ByDescriptor(d)
+	text := loc.LeadingComments
+	if strings.TrimSpace(text) == "" {
+		text = loc.TrailingComments
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(strings.TrimPrefix(line, " "), " \t")
+		if _, ok := annotationLine(line); ok {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// Position returns where d is declared, as file:line (e.g. "packet.proto:42"), with the path of the
+// file resolved from the import paths
+func Position(d protoreflect.Descriptor) string {
+	loc := d.ParentFile().SourceLocations().ByDescriptor(d)
+	return fmt.Sprintf("%s:%d", filepath.ToSlash(SourcePath(d.ParentFile().Path())), loc.StartLine+1)
+}
@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/linker"
+	"github.com/bufbuild/protocompile/reporter"
+	"github.com/snowmerak/socketgen/toolchain"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// The compilers LoadDescriptorSet can compile proto files with
+const (
+	CompilerBuiltin = "builtin" // In process, with github.com/bufbuild/protocompile
+	CompilerProtoc  = "protoc"  // protoc, installed by socketgen toolchain install or in PATH
+)
+
+// Compiler is the compiler LoadDescriptorSet compiles proto files with. The built-in compiler
+// needs nothing installed and keeps the descriptors in memory; protoc is there for schemas relying
+// on protoc's exact behavior.
+var Compiler = CompilerBuiltin
+
+// ImportPaths are the directories imports are resolved from, in order, like the -I flags of protoc.
+// When empty, imports are resolved from the working directory.
+var ImportPaths []string
+
+// importPaths returns ImportPaths, or the working directory if it is empty
+func importPaths() []string {
+	if len(ImportPaths) == 0 {
+		return []string{"."}
+	}
+	return ImportPaths
+}
+
+// ImportDir returns the import path the file imported as name is in: the first one having it, or
+// the first one if none has it (e.g. a file bundled with the compiler)
+func ImportDir(name string) string {
+	for _, dir := range importPaths() {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			return dir
+		}
+	}
+	return importPaths()[0]
+}
+
+// SourcePath returns the path of the file imported as name, in ImportDir(name)
+func SourcePath(name string) string {
+	return filepath.Join(ImportDir(name), filepath.FromSlash(name))
+}
+
+// compile compiles protoFile and the files it imports with Compiler, into a serialized
+// FileDescriptorSet like protoc --include_imports --include_source_info writes
+func compile(protoFile string) ([]byte, error) {
+	switch Compiler {
+	case CompilerBuiltin:
+		return compileBuiltin(protoFile)
+	case CompilerProtoc:
+		return compileProtoc(protoFile)
+	}
+	return nil, fmt.Errorf("unknown compiler %q; expected %s or %s", Compiler, CompilerBuiltin, CompilerProtoc)
+}
+
+// compileBuiltin resolves imports like protoc does: from ImportPaths, or bundled (e.g.
+// google/protobuf/any.proto). Every error in the files is reported, not only the first.
+func compileBuiltin(protoFile string) ([]byte, error) {
+	var errs []error
+	c := protocompile.Compiler{
+		Resolver:       protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: ImportPaths}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+		Reporter: reporter.NewReporter(
+			func(err reporter.ErrorWithPos) error {
+				errs = append(errs, err)
+				return nil
+			},
+			func(err reporter.ErrorWithPos) {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			},
+		),
+	}
+	// The file is named as protoc names it, so findTargetFile finds it either way
+	files, err := c.Compile(context.Background(), protoName(protoFile))
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to compile %s:\n%w", protoFile, errors.Join(errs...))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s: %w", protoFile, err)
+	}
+
+	// Files come after their imports, as with protoc
+	var fds descriptorpb.FileDescriptorSet
+	seen := map[string]bool{}
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := range imports.Len() {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fds.File = append(fds.File, fileDescriptorProto(fd))
+	}
+	for _, f := range files {
+		add(f)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(&fds)
+}
+
+// fileDescriptorProto returns the descriptor proto fd was compiled to, with its source info, or
+// builds one for files bundled with the compiler
+func fileDescriptorProto(fd protoreflect.FileDescriptor) *descriptorpb.FileDescriptorProto {
+	if r, ok := fd.(linker.Result); ok {
+		return r.FileDescriptorProto()
+	}
+	// The imports of a compiled file are wrapped
+	if f, ok := fd.(interface {
+		Unwrap() protoreflect.FileDescriptor
+	}); ok {
+		return fileDescriptorProto(f.Unwrap())
+	}
+	return protodesc.ToFileDescriptorProto(fd)
+}
+
+func compileProtoc(protoFile string) ([]byte, error) {
+	protoc := toolchain.Protoc()
+	if _, err := exec.LookPath(protoc); err != nil {
+		return nil, fmt.Errorf("protoc is not installed or not in PATH; install it with 'socketgen toolchain install', or use the built-in compiler")
+	}
+
+	// Every invocation gets its own directory, so concurrent runs don't clobber each other
+	tmpDir, err := os.MkdirTemp("", "socketgen-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpFile := filepath.Join(tmpDir, "descriptor.pb")
+
+	args := []string{
+		"--descriptor_set_out=" + tmpFile,
+		"--include_imports",
+		"--include_source_info",
+	}
+	for _, dir := range ImportPaths {
+		args = append(args, "-I"+dir)
+	}
+	cmd := exec.Command(protoc, append(args, protoFile)...)
+
+	// Capture stderr to show protoc errors if any
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run protoc: %w", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor file: %w", err)
+	}
+	return data, nil
+}
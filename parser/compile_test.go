@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/socketgen/options"
+	"github.com/snowmerak/socketgen/toolchain"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// writeFiles writes files, by path, into a new working directory, which imports resolve from
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := options.Vendor(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(dir)
+	compiler := Compiler
+	t.Cleanup(func() { Compiler = compiler })
+}
+
+var compileSchemas = map[string]map[string]string{
+	"plain": {"packet.proto": `syntax = "proto3";
+package packet;
+
+message Header { uint32 seq = 1; }
+
+// Sent to log in
+message LoginReq { string token = 1; }
+message ChatMsg { string text = 1; repeated string mentions = 2; map<string, int32> counts = 3; }
+
+message GamePacket {
+  Header header = 1;
+  oneof payload {
+    LoginReq login_req = 10;
+    ChatMsg chat_msg = 11;
+  }
+}
+`},
+	"imports": {
+		"packet.proto": `syntax = "proto3";
+package packet;
+
+import "google/protobuf/any.proto";
+import "common/header.proto";
+
+message ErrorRes { string message = 1; google.protobuf.Any details = 2; }
+
+message GamePacket {
+  Header header = 1;
+  oneof payload {
+    ErrorRes error_res = 9;
+  }
+}
+`,
+		"common/header.proto": `syntax = "proto3";
+package packet;
+
+message Header { int64 timestamp = 1; string request_id = 2; }
+`,
+	},
+	"options": {"packet.proto": `syntax = "proto3";
+package packet;
+
+import "socketgen/options.proto";
+
+message Header { uint32 seq = 1; }
+
+message MoveCmd {
+  option (socketgen.priority) = 2;
+  option (socketgen.sample_rate) = 0.5;
+  float x = 1 [(socketgen.clamp) = "0..100"];
+}
+
+// @socketgen broadcast
+message ChatEvent { string text = 1; }
+
+message GamePacket {
+  Header header = 1;
+  oneof payload {
+    MoveCmd move_cmd = 10;
+    ChatEvent chat_event = 11;
+  }
+}
+`},
+}
+
+func TestCompilersAgree(t *testing.T) {
+	if _, err := exec.LookPath(toolchain.Protoc()); err != nil {
+		t.Skip("protoc is not installed")
+	}
+	for name, files := range compileSchemas {
+		t.Run(name, func(t *testing.T) {
+			writeFiles(t, files)
+
+			results := map[string]*ParseResult{}
+			for _, compiler := range []string{CompilerBuiltin, CompilerProtoc} {
+				Compiler = compiler
+				result, err := Parse("packet.proto")
+				if err != nil {
+					t.Fatalf("%s: %v", compiler, err)
+				}
+				results[compiler] = result
+			}
+
+			builtin, protoc := results[CompilerBuiltin], results[CompilerProtoc]
+			builtinFiles, protocFiles := schemaFiles(t, builtin), schemaFiles(t, protoc)
+			if len(builtinFiles) != len(protocFiles) {
+				t.Errorf("compiled %d files with builtin, %d with protoc", len(builtinFiles), len(protocFiles))
+			}
+			for name, fd := range builtinFiles {
+				if !proto.Equal(fd, protocFiles[name]) {
+					t.Errorf("%s differs:\nwith builtin: %v\nwith protoc: %v", name, fd, protocFiles[name])
+				}
+			}
+			if !reflect.DeepEqual(builtin.Header, protoc.Header) {
+				t.Errorf("header with builtin:\n%+v\nwith protoc:\n%+v", builtin.Header, protoc.Header)
+			}
+			if !reflect.DeepEqual(builtin.Payloads, protoc.Payloads) {
+				t.Errorf("payloads with builtin:\n%+v\nwith protoc:\n%+v", builtin.Payloads, protoc.Payloads)
+			}
+		})
+	}
+}
+
+// schemaFiles returns the files of the descriptor set of result without source info, by name. The
+// files of google/protobuf are left out, as every compiler bundles its own version of them.
+func schemaFiles(t *testing.T, result *ParseResult) map[string]*descriptorpb.FileDescriptorProto {
+	t.Helper()
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(result.DescriptorSet, &fds); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, fd := range withoutSourceInfo(&fds).File {
+		if !strings.HasPrefix(fd.GetName(), "google/protobuf/") {
+			files[fd.GetName()] = fd
+		}
+	}
+	return files
+}
+
+func TestCompileBuiltinReportsEveryError(t *testing.T) {
+	writeFiles(t, map[string]string{"packet.proto": `syntax = "proto3";
+package packet;
+
+message LoginReq { Token token = 1; }
+message ChatMsg { Channel channel = 1; }
+`})
+	Compiler = CompilerBuiltin
+	_, err := compile("packet.proto")
+	if err == nil {
+		t.Fatal("compiled a schema with undefined types")
+	}
+	for _, want := range []string{"packet.proto:4:", "Token", "packet.proto:5:", "Channel"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error lacks %q:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Fingerprint returns a short content hash of the descriptor set. It changes whenever the
+// schema changes, so servers and clients can compare it to detect mismatched builds.
+// Source info (comments, spans) is ignored, so reformatting the proto file keeps the fingerprint.
+func Fingerprint(fds *descriptorpb.FileDescriptorSet) string {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(withoutSourceInfo(fds))
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:12])
+}
+
+// withoutSourceInfo returns a copy of fds without source info (comments and spans)
+func withoutSourceInfo(fds *descriptorpb.FileDescriptorSet) *descriptorpb.FileDescriptorSet {
+	stripped := proto.Clone(fds).(*descriptorpb.FileDescriptorSet)
+	for _, fd := range stripped.File {
+		fd.SourceCodeInfo = nil
+	}
+	return stripped
+}
@@ -0,0 +1,510 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snowmerak/socketgen/options"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// defaultMaxPageSize applies to paginated requests without option (socketgen.max_page_size)
+const defaultMaxPageSize = 100
+
+// Directions of option (socketgen.direction)
+const (
+	DirectionClientToServer = "c2s"
+	DirectionServerToClient = "s2c"
+	DirectionBoth           = "both"
+)
+
+// applyOptions copies the socketgen custom options set on a payload message into p. msg may be nil.
+// The options package registers the extension types, so they are already decoded in the descriptor set.
+func applyOptions(p *PayloadMessage, msg *descriptorpb.DescriptorProto) {
+	opts := msg.GetOptions()
+	if opts == nil {
+		return
+	}
+
+	p.Feature = proto.GetExtension(opts, options.E_Feature).(string)
+	p.Priority = proto.GetExtension(opts, options.E_Priority).(int32)
+	p.RespondsWith = proto.GetExtension(opts, options.E_RespondsWith).(string)
+	p.Group = proto.GetExtension(opts, options.E_Group).(string)
+	p.Broadcast = proto.GetExtension(opts, options.E_Broadcast).(bool)
+	p.SupersededBy = proto.GetExtension(opts, options.E_SupersededBy).(string)
+	if proto.HasExtension(opts, options.E_SampleRate) {
+		p.SampleRate = proto.GetExtension(opts, options.E_SampleRate).(float64)
+	}
+	if direction := proto.GetExtension(opts, options.E_Direction).(string); direction != "" {
+		p.Direction = direction
+	}
+	p.RequiresAuth = proto.GetExtension(opts, options.E_RequiresAuth).(bool)
+	p.AuthExempt = proto.HasExtension(opts, options.E_RequiresAuth) && !p.RequiresAuth
+	p.RateLimit = proto.GetExtension(opts, options.E_RateLimit).(float64)
+	p.Compress = proto.GetExtension(opts, options.E_Compress).(bool)
+	p.Throttle = proto.GetExtension(opts, options.E_Throttle).(bool)
+	p.Snapshot = proto.GetExtension(opts, options.E_Snapshot).(bool)
+	p.Input = proto.GetExtension(opts, options.E_Input).(bool)
+	p.Lazy = proto.GetExtension(opts, options.E_Lazy).(bool)
+
+	if proto.GetExtension(opts, options.E_Paginated).(bool) {
+		p.MaxPageSize = proto.GetExtension(opts, options.E_MaxPageSize).(int32)
+		if p.MaxPageSize <= 0 {
+			p.MaxPageSize = defaultMaxPageSize
+		}
+	}
+}
+
+// applyFieldOptions copies the socketgen custom options set on a field into f
+func applyFieldOptions(f *MessageField, field *descriptorpb.FieldDescriptorProto) {
+	opts := field.GetOptions()
+	if opts == nil {
+		return
+	}
+
+	f.MetricLabel = proto.GetExtension(opts, options.E_MetricLabel).(bool)
+	f.Clamp = strings.TrimSpace(proto.GetExtension(opts, options.E_Clamp).(string))
+	f.Default = proto.GetExtension(opts, options.E_DefaultValue).(string)
+	f.TextCode = proto.GetExtension(opts, options.E_TextCode).(bool)
+}
+
+// Features returns the distinct feature flags gating payloads, sorted by name
+func (r *ParseResult) Features() []string {
+	seen := map[string]bool{}
+	var features []string
+	for _, p := range r.Payloads {
+		if p.Feature != "" && !seen[p.Feature] {
+			seen[p.Feature] = true
+			features = append(features, p.Feature)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// Groups returns the distinct backend groups payloads are forwarded to, sorted by name
+func (r *ParseResult) Groups() []string {
+	seen := map[string]bool{}
+	var groups []string
+	for _, p := range r.Payloads {
+		if p.Group != "" && !seen[p.Group] {
+			seen[p.Group] = true
+			groups = append(groups, p.Group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// validateOptions checks that annotated payloads have the shape their options require
+func validateOptions(r *ParseResult) error {
+	for _, label := range r.MetricLabels() {
+		if label.Field.Kind == "message" || label.Field.Kind == "group" || label.Field.Repeated || label.Field.Map {
+			return fmt.Errorf("metric label %s must be a singular scalar or enum field", label.Name)
+		}
+	}
+
+	for _, f := range r.Header {
+		if f.Clamp != "" || f.Default != "" {
+			return fmt.Errorf("header field %s: options (socketgen.clamp) and (socketgen.default_value) only apply to payload fields", f.Name)
+		}
+	}
+
+	for _, p := range r.Payloads {
+		for _, f := range p.Fields {
+			if err := validateNormalization(f); err != nil {
+				return fmt.Errorf("%s.%s: %w", p.Name, f.Name, err)
+			}
+		}
+		if p.SupersededBy != "" {
+			next := r.Payload(p.SupersededBy)
+			if next == nil {
+				return fmt.Errorf("%s is superseded by %s, which is not a payload", p.Name, p.SupersededBy)
+			}
+			if next.Name == p.Name {
+				return fmt.Errorf("%s cannot supersede itself", p.Name)
+			}
+			if next.SupersededBy != "" {
+				return fmt.Errorf("%s is superseded by %s, which is superseded itself; point it at %s", p.Name, next.Name, next.SupersededBy)
+			}
+		}
+		if p.SampleRate < 0 || p.SampleRate > 1 {
+			return fmt.Errorf("option (socketgen.sample_rate) of %s must be between 0 and 1, not %g", p.Name, p.SampleRate)
+		}
+		switch p.Direction {
+		case DirectionClientToServer, DirectionServerToClient, DirectionBoth:
+		default:
+			return fmt.Errorf("option (socketgen.direction) of %s must be %q, %q or %q, not %q", p.Name, DirectionClientToServer, DirectionServerToClient, DirectionBoth, p.Direction)
+		}
+		if p.RateLimit < 0 || math.IsNaN(p.RateLimit) || math.IsInf(p.RateLimit, 0) {
+			return fmt.Errorf("option (socketgen.rate_limit) of %s must be a positive number of packets per second, not %g", p.Name, p.RateLimit)
+		}
+		if p.Direction == DirectionServerToClient && (p.RequiresAuth || p.RateLimit > 0) {
+			return fmt.Errorf("%s is sent by the server, so options (socketgen.requires_auth) and (socketgen.rate_limit) do not apply", p.Name)
+		}
+		if p.Direction == DirectionClientToServer && p.Throttle {
+			return fmt.Errorf("%s is sent by the client, so option (socketgen.throttle) does not apply", p.Name)
+		}
+		if p.Snapshot {
+			if p.Direction == DirectionClientToServer {
+				return fmt.Errorf("%s is sent by the client, so option (socketgen.snapshot) does not apply", p.Name)
+			}
+			if p.SnapshotTime() == nil {
+				return fmt.Errorf("snapshot %s needs a numeric field `timestamp`, the server time in milliseconds", p.Name)
+			}
+		}
+		if p.Input {
+			if p.Direction == DirectionServerToClient {
+				return fmt.Errorf("%s is sent by the server, so option (socketgen.input) does not apply", p.Name)
+			}
+			if !hasField(p.Fields, "seq", "uint32") {
+				return fmt.Errorf("input %s needs a field `uint32 seq`", p.Name)
+			}
+		}
+		if p.MaxPageSize == 0 {
+			continue
+		}
+		if !hasField(p.Fields, "cursor", "string") || !hasField(p.Fields, "page_size", "int32") {
+			return fmt.Errorf("paginated %s needs fields `string cursor` and `int32 page_size`", p.Name)
+		}
+
+		res := r.Payload(p.RespondsWith)
+		if res == nil {
+			return fmt.Errorf("paginated %s needs option (socketgen.responds_with) naming a payload", p.Name)
+		}
+		if !hasField(res.Fields, "next_cursor", "string") || res.PageItems() == nil {
+			return fmt.Errorf("%s, the response of paginated %s, needs `string next_cursor` and exactly one repeated field", res.Name, p.Name)
+		}
+	}
+	return nil
+}
+
+// validateNormalization checks that the clamp range and default value of f suit its type
+func validateNormalization(f MessageField) error {
+	if f.Clamp == "" && f.Default == "" {
+		return nil
+	}
+	if f.Map || f.InOneof || f.Kind == "message" || f.Kind == "group" || f.Kind == "bytes" || f.Kind == "enum" {
+		return fmt.Errorf("options (socketgen.clamp) and (socketgen.default_value) need a scalar field outside a oneof")
+	}
+
+	if f.Clamp != "" {
+		minimum, maximum, ok := f.ClampBounds()
+		if !ok {
+			return fmt.Errorf("option (socketgen.clamp) must look like \"min..max\", not %q", f.Clamp)
+		}
+		if f.Kind == "string" || f.Kind == "bool" {
+			return fmt.Errorf("option (socketgen.clamp) needs a numeric field, not %s", f.Kind)
+		}
+		lo, hi := math.Inf(-1), math.Inf(1)
+		var err error
+		if minimum != "" {
+			if lo, err = parseNumber(f.Kind, minimum); err != nil {
+				return fmt.Errorf("option (socketgen.clamp): %w", err)
+			}
+		}
+		if maximum != "" {
+			if hi, err = parseNumber(f.Kind, maximum); err != nil {
+				return fmt.Errorf("option (socketgen.clamp): %w", err)
+			}
+		}
+		if lo > hi {
+			return fmt.Errorf("option (socketgen.clamp) has min %s above max %s", minimum, maximum)
+		}
+	}
+
+	if f.Default == "" {
+		return nil
+	}
+	if f.Repeated {
+		return fmt.Errorf("option (socketgen.default_value) needs a singular field")
+	}
+	switch f.Kind {
+	case "string":
+		if !f.Optional && f.Default == "" {
+			return fmt.Errorf("option (socketgen.default_value) is the zero value")
+		}
+	case "bool":
+		if f.Default != "true" && f.Default != "false" {
+			return fmt.Errorf("option (socketgen.default_value) must be true or false, not %q", f.Default)
+		}
+		if !f.Optional && f.Default == "false" {
+			return fmt.Errorf("option (socketgen.default_value) is the zero value; declare the field optional to default it to false")
+		}
+	default:
+		v, err := parseNumber(f.Kind, f.Default)
+		if err != nil {
+			return fmt.Errorf("option (socketgen.default_value): %w", err)
+		}
+		if !f.Optional && v == 0 {
+			return fmt.Errorf("option (socketgen.default_value) is the zero value; declare the field optional to default it to 0")
+		}
+	}
+	return nil
+}
+
+// parseNumber parses a bound or default of a numeric field as a Go literal of its kind would
+func parseNumber(kind, s string) (float64, error) {
+	switch kind {
+	case "int32", "sint32", "sfixed32", "int64", "sint64", "sfixed64":
+		bits := 64
+		if strings.HasSuffix(kind, "32") {
+			bits = 32
+		}
+		v, err := strconv.ParseInt(s, 0, bits)
+		return float64(v), err
+	case "uint32", "fixed32", "uint64", "fixed64":
+		bits := 64
+		if strings.HasSuffix(kind, "32") {
+			bits = 32
+		}
+		v, err := strconv.ParseUint(s, 0, bits)
+		return float64(v), err
+	}
+
+	bits := 64
+	if kind == "float" {
+		bits = 32
+	}
+	v, err := strconv.ParseFloat(s, bits)
+	if err == nil && (math.IsInf(v, 0) || math.IsNaN(v)) {
+		return 0, fmt.Errorf("%s is not a finite number", s)
+	}
+	return v, err
+}
+
+// ClampBounds splits option (socketgen.clamp) into its bounds; a bound left out is "". ok is
+// false if the range is malformed.
+func (f MessageField) ClampBounds() (minimum, maximum string, ok bool) {
+	minimum, maximum, ok = strings.Cut(f.Clamp, "..")
+	minimum, maximum = strings.TrimSpace(minimum), strings.TrimSpace(maximum)
+	return minimum, maximum, ok && (minimum != "" || maximum != "")
+}
+
+// Normalized reports whether any field of p has a clamp range or a default value
+func (p *PayloadMessage) Normalized() bool {
+	for _, f := range p.Fields {
+		if f.Clamp != "" || f.Default != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasNormalization reports whether any payload has fields with a clamp range or a default value
+func (r *ParseResult) HasNormalization() bool {
+	for i := range r.Payloads {
+		if r.Payloads[i].Normalized() {
+			return true
+		}
+	}
+	return false
+}
+
+// PageItems returns the repeated field holding the items of a paginated response, or nil
+// unless there is exactly one
+func (p *PayloadMessage) PageItems() *MessageField {
+	var items *MessageField
+	for i, f := range p.Fields {
+		if f.Repeated {
+			if items != nil {
+				return nil
+			}
+			items = &p.Fields[i]
+		}
+	}
+	return items
+}
+
+func hasField(fields []MessageField, name, kind string) bool {
+	for _, f := range fields {
+		if f.Name == name && f.Kind == kind && !f.Repeated && !f.Map {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPagination reports whether any payload is a paginated request
+func (r *ParseResult) HasPagination() bool {
+	for _, p := range r.Payloads {
+		if p.MaxPageSize > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasBroadcast reports whether any payload is a broadcast, which enables read-only sessions
+func (r *ParseResult) HasBroadcast() bool {
+	for _, p := range r.Payloads {
+		if p.Broadcast {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPriority reports whether any payload has a dispatch priority, which the dispatch queue orders by
+func (r *ParseResult) HasPriority() bool {
+	for _, p := range r.Payloads {
+		if p.Priority != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSampling reports whether any payload has a sample rate below 1, which taps sample by
+func (r *ParseResult) HasSampling() bool {
+	for _, p := range r.Payloads {
+		if p.SampleRate != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasThrottle reports whether any payload is a throttled state update
+func (r *ParseResult) HasThrottle() bool {
+	return len(r.ThrottledPayloads()) > 0
+}
+
+// ThrottledPayloads returns the payloads marked with option (socketgen.throttle)
+func (r *ParseResult) ThrottledPayloads() []PayloadMessage {
+	var payloads []PayloadMessage
+	for _, p := range r.Payloads {
+		if p.Throttle {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+// SnapshotPayloads returns the payloads marked with option (socketgen.snapshot)
+func (r *ParseResult) SnapshotPayloads() []PayloadMessage {
+	var payloads []PayloadMessage
+	for _, p := range r.Payloads {
+		if p.Snapshot {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+// InputPayloads returns the payloads marked with option (socketgen.input)
+func (r *ParseResult) InputPayloads() []PayloadMessage {
+	var payloads []PayloadMessage
+	for _, p := range r.Payloads {
+		if p.Input {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+// LazyPayloads returns the payloads marked with option (socketgen.lazy)
+func (r *ParseResult) LazyPayloads() []PayloadMessage {
+	var payloads []PayloadMessage
+	for _, p := range r.Payloads {
+		if p.Lazy {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+// SnapshotTime returns the `timestamp` field of a snapshot, or nil unless it is a singular
+// integer or double field
+func (p *PayloadMessage) SnapshotTime() *MessageField {
+	for i, f := range p.Fields {
+		if f.Name != "timestamp" || f.Repeated || f.Map || f.InOneof {
+			continue
+		}
+		switch f.Kind {
+		case "int32", "sint32", "sfixed32", "uint32", "fixed32", "int64", "sint64", "sfixed64", "uint64", "fixed64", "double":
+			return &p.Fields[i]
+		}
+	}
+	return nil
+}
+
+// BroadcastPayloads returns the payloads marked with option (socketgen.broadcast)
+func (r *ParseResult) BroadcastPayloads() []PayloadMessage {
+	var payloads []PayloadMessage
+	for _, p := range r.Payloads {
+		if p.Broadcast {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+// MetricLabel labels generated metrics with the value of fields annotated with option
+// (socketgen.metric_label). Payload fields of the same name share a label.
+type MetricLabel struct {
+	Name     string       // The field name, used as the label name (e.g., "region")
+	Field    MessageField // The first annotated field of that name
+	Header   bool         // The field is in the packet header
+	Payloads []string     // Type names of the payloads carrying the field, unless it is in the header
+}
+
+// MetricLabels returns the metric labels, header fields first, in declaration order
+func (r *ParseResult) MetricLabels() []MetricLabel {
+	var labels []MetricLabel
+	index := map[string]int{}
+	for _, f := range r.Header {
+		if f.MetricLabel {
+			index[f.Name] = len(labels)
+			labels = append(labels, MetricLabel{Name: f.Name, Field: f, Header: true})
+		}
+	}
+	for _, p := range r.Payloads {
+		for _, f := range p.Fields {
+			if !f.MetricLabel {
+				continue
+			}
+			i, ok := index[f.Name]
+			if !ok {
+				i = len(labels)
+				index[f.Name] = i
+				labels = append(labels, MetricLabel{Name: f.Name, Field: f})
+			}
+			if !labels[i].Header {
+				labels[i].Payloads = append(labels[i].Payloads, p.Name)
+			}
+		}
+	}
+	return labels
+}
+
+// HasSuperseded reports whether any payload is superseded by another
+func (r *ParseResult) HasSuperseded() bool {
+	return len(r.SupersededPayloads()) > 0
+}
+
+// SupersededPayloads returns the payloads marked with option (socketgen.superseded_by)
+func (r *ParseResult) SupersededPayloads() []PayloadMessage {
+	var payloads []PayloadMessage
+	for _, p := range r.Payloads {
+		if p.SupersededBy != "" {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+// HandledPayloads returns the payloads that have a handler, i.e. all but the superseded ones
+func (r *ParseResult) HandledPayloads() []PayloadMessage {
+	var payloads []PayloadMessage
+	for _, p := range r.Payloads {
+		if p.SupersededBy == "" {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
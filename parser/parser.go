@@ -1,9 +1,8 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -13,100 +12,186 @@ import (
 
 // PayloadMessage represents a message type that can be carried in the GamePacket payload
 type PayloadMessage struct {
-	Name      string // The type name (e.g., "LoginReq")
-	FieldName string // The field name in the oneof (e.g., "login_req")
-	FullName  string // The full proto name (e.g., "packet.LoginReq")
+	Name         string  // The type name (e.g., "LoginReq")
+	FieldName    string  // The field name in the oneof (e.g., "login_req")
+	Number       int32   // The field number in the oneof
+	FullName     string  // The full proto name (e.g., "packet.LoginReq")
+	Admin        bool    // Server-control payload, by convention named with an "Admin" prefix (e.g., "AdminKick")
+	Feature      string  // Feature flag gating the payload, from option (socketgen.feature)
+	Priority     int32   // Dispatch priority, from option (socketgen.priority)
+	RespondsWith string  // Payload type name of the response, from option (socketgen.responds_with)
+	MaxPageSize  int32   // Page size limit of a paginated request, from options (socketgen.paginated) and (socketgen.max_page_size); 0 if not paginated
+	Group        string  // Backend service group, from option (socketgen.group)
+	Broadcast    bool    // Received by read-only sessions, from option (socketgen.broadcast)
+	SampleRate   float64 // Fraction of packets reported by generated taps, from option (socketgen.sample_rate); 1 if not set
+	SupersededBy string  // Payload type name replacing this one, from option (socketgen.superseded_by)
+	Direction    string  // Which side sends the payload ("c2s", "s2c" or "both"), from option (socketgen.direction); "both" if not set
+	RequiresAuth bool    // Needs an authenticated session, from option (socketgen.requires_auth)
+	AuthExempt   bool    // Explicitly open to unauthenticated sessions, option (socketgen.requires_auth) = false
+	RateLimit    float64 // Packets per second a session may send, from option (socketgen.rate_limit); 0 if unlimited
+	Compress     bool    // Worth compressing on the wire, from option (socketgen.compress)
+	Throttle     bool    // High-rate state update sent less often to slow sessions, from option (socketgen.throttle)
+	Snapshot     bool    // State snapshot interpolated by clients, from option (socketgen.snapshot)
+	Input        bool    // Input command queued by clients for prediction, from option (socketgen.input)
+	Lazy         bool    // Loaded on first use by split TS clients, from option (socketgen.lazy)
+	Comment      string  // The message's comment in the proto file, without comment markers
+	Source       string  // Where the payload is declared, e.g. "packet.LoginReq at packet.proto:42, field 10 of GamePacket"
+	Fields       []MessageField
+}
+
+// SourcePrefix starts the line of generated doc comments telling where a payload is declared,
+// which socketgen whereis searches generated code for
+const SourcePrefix = "Source: "
+
+// Doc returns the doc comment of the generated symbols of p: its comment, then where it is declared
+func (p PayloadMessage) Doc() string {
+	if p.Source == "" {
+		return p.Comment
+	}
+	if p.Comment == "" {
+		return SourcePrefix + p.Source
+	}
+	return p.Comment + "\n\n" + SourcePrefix + p.Source
+}
+
+// MessageField describes a single field of a payload message
+type MessageField struct {
+	Name     string // The field name (e.g., "user_id")
+	Number   int32  // The field number
+	Kind     string // The proto type (e.g., "string", "int64", "message", "enum")
+	TypeName string // The full type name for message and enum fields (e.g., "packet.Vec3")
+	Repeated bool
+	Map      bool
+	Optional bool   // Declared `optional` in proto3, so presence is tracked (a pointer in Go)
+	InOneof  bool   // Member of a oneof, other than the implicit one of an optional field
+	Comment  string // The field's comment in the proto file, without comment markers
+
+	MetricLabel bool   // Labels generated metrics, from option (socketgen.metric_label)
+	Clamp       string // Range the value is clamped to (e.g., "0..100"), from option (socketgen.clamp)
+	Default     string // Value of an unset field, from option (socketgen.default_value)
+	TextCode    bool   // Carries the code of a user-facing text, from option (socketgen.text_code)
+
+	Rules []ValidationRule // Constraints from buf.validate or protoc-gen-validate
 }
 
 // ParseResult holds the extracted information from the proto file
 type ParseResult struct {
-	PackageName string
-	Payloads    []PayloadMessage
+	PackageName   string
+	SchemaVersion string  // Content hash of the descriptor set, see Fingerprint
+	DescriptorSet []byte  // Serialized FileDescriptorSet of the proto file and its imports
+	Schema        *Schema // Reflection view of the same descriptors
+	Wrapper       string  // The envelope message (e.g., "GamePacket")
+	HeaderType    string  // Type name of the wrapper's "header" field (e.g., "Header"), "" if it has none
+	Header        []MessageField
+	Payloads      []PayloadMessage
 }
 
-// Parse runs protoc to generate a descriptor set and then parses it to extract GamePacket info
-func Parse(protoFile string) (*ParseResult, error) {
-	// 1. Check if protoc is installed
-	_, err := exec.LookPath("protoc")
-	if err != nil {
-		return nil, fmt.Errorf("protoc is not installed or not in PATH. Please install Protocol Buffers compiler")
-	}
+// DefaultWrapper is the client-facing envelope; InternalWrapper is the conventional name of the
+// envelope for server-to-server traffic
+const (
+	DefaultWrapper  = "GamePacket"
+	InternalWrapper = "InternalPacket"
+)
 
-	// 2. Generate FileDescriptorSet using protoc
-	// We output to a temporary file
-	tmpFile := "temp_descriptor.pb"
-	defer os.Remove(tmpFile)
+// Wrapper is the name of the client-facing envelope Parse and NewSchema look for, for schemas
+// naming it otherwise (Envelope, NetMessage, ClientPacket)
+var Wrapper = DefaultWrapper
 
-	cmd := exec.Command("protoc",
-		"--descriptor_set_out="+tmpFile,
-		"--include_imports",
-		protoFile,
-	)
+// ErrWrapperNotFound is returned when the proto file does not define the requested envelope
+var ErrWrapperNotFound = errors.New("wrapper message not found")
 
-	// Capture stderr to show protoc errors if any
-	cmd.Stderr = os.Stderr
+// Parse compiles protoFile to a descriptor set and then parses it to extract the info of its
+// envelope, named Wrapper
+func Parse(protoFile string) (*ParseResult, error) {
+	return ParseWrapper(protoFile, Wrapper)
+}
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to run protoc: %w", err)
+// ParseWrapper is like Parse, for an envelope with a different name (e.g., InternalPacket).
+// The envelope has the same shape as GamePacket: an optional "header" field and a "payload" oneof.
+func ParseWrapper(protoFile, wrapper string) (*ParseResult, error) {
+	fileDescSet, err := LoadDescriptorSet(protoFile)
+	if err != nil {
+		return nil, err
 	}
 
-	// 3. Read the generated descriptor file
-	data, err := os.ReadFile(tmpFile)
+	// Analyze the descriptor to find the wrapper and its payload
+	result, err := analyzeDescriptor(fileDescSet, protoFile, wrapper)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read descriptor file: %w", err)
+		return nil, err
 	}
 
-	// 4. Unmarshal into FileDescriptorSet
-	var fileDescSet descriptorpb.FileDescriptorSet
-	if err := proto.Unmarshal(data, &fileDescSet); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal descriptor set: %w", err)
+	result.DescriptorSet, err = proto.MarshalOptions{Deterministic: true}.Marshal(withoutSourceInfo(fileDescSet))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode descriptor set: %w", err)
+	}
+
+	targetFileDesc, err := findTargetFile(fileDescSet, protoFile)
+	if err != nil {
+		return nil, err
+	}
+	result.Schema, err = newSchema(fileDescSet, targetFileDesc.GetName(), wrapper)
+	if err != nil {
+		return nil, err
+	}
+	applyValidationRules(result)
+	applyComments(result)
+	if _, err := textCodes(result.Schema); err != nil {
+		return nil, err
 	}
 
-	// 5. Analyze the descriptor to find GamePacket and its payload
-	return analyzeDescriptor(&fileDescSet, protoFile)
+	return result, nil
 }
 
-func analyzeDescriptor(fds *descriptorpb.FileDescriptorSet, targetFile string) (*ParseResult, error) {
-	var targetFileDesc *descriptorpb.FileDescriptorProto
+// LoadDescriptorSet compiles protoFile with Compiler and returns the resulting FileDescriptorSet,
+// including all imported files and their source info. Options written as @socketgen comments are
+// set on the descriptors like custom options.
+func LoadDescriptorSet(protoFile string) (*descriptorpb.FileDescriptorSet, error) {
+	// Reuse the descriptor set compiled from the same files, if this process or CacheDir has it
+	key, data := cachedDescriptorSet(protoFile)
+	if data == nil {
+		var err error
+		if data, err = compile(protoFile); err != nil {
+			return nil, err
+		}
+		cacheDescriptorSet(protoFile, key, data)
+	}
 
-	// Find the descriptor for the target file
-	// Note: protoFile path might need normalization to match what's in the descriptor set
-	// For simplicity, we'll look for the file that matches the input filename (base name)
+	var fileDescSet descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fileDescSet); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal descriptor set: %w", err)
+	}
 
-	// Simple strategy: Look for the file that matches the input filename (base name)
-	targetBase := filepath.Base(targetFile)
-	for _, fd := range fds.File {
-		if strings.HasSuffix(fd.GetName(), targetBase) {
-			targetFileDesc = fd
-			break
-		}
+	if err := applyAnnotations(&fileDescSet); err != nil {
+		return nil, err
 	}
 
-	if targetFileDesc == nil {
-		// Fallback: use the last one (often the main file in simple cases)
-		if len(fds.File) > 0 {
-			targetFileDesc = fds.File[len(fds.File)-1]
-		} else {
-			return nil, fmt.Errorf("no file descriptors found")
-		}
+	return &fileDescSet, nil
+}
+
+func analyzeDescriptor(fds *descriptorpb.FileDescriptorSet, targetFile, wrapper string) (*ParseResult, error) {
+	targetFileDesc, err := findTargetFile(fds, targetFile)
+	if err != nil {
+		return nil, err
 	}
 
 	result := &ParseResult{
-		PackageName: targetFileDesc.GetPackage(),
-		Payloads:    []PayloadMessage{},
+		PackageName:   targetFileDesc.GetPackage(),
+		SchemaVersion: Fingerprint(fds),
+		Wrapper:       wrapper,
+		Payloads:      []PayloadMessage{},
 	}
 
-	// Find "GamePacket" message
+	// Find the wrapper message (e.g., "GamePacket")
 	var gamePacketMsg *descriptorpb.DescriptorProto
 	for _, msg := range targetFileDesc.MessageType {
-		if msg.GetName() == "GamePacket" {
+		if msg.GetName() == wrapper {
 			gamePacketMsg = msg
 			break
 		}
 	}
 
 	if gamePacketMsg == nil {
-		return nil, fmt.Errorf("message 'GamePacket' not found in %s", targetFile)
+		return nil, fmt.Errorf("%w: message '%s' not found in %s", ErrWrapperNotFound, wrapper, targetFile)
 	}
 
 	// Find "payload" oneof field
@@ -122,13 +207,27 @@ func analyzeDescriptor(fds *descriptorpb.FileDescriptorSet, targetFile string) (
 	}
 
 	if oneofIndex == -1 {
-		return nil, fmt.Errorf("'payload' oneof field not found in GamePacket")
+		return nil, fmt.Errorf("'payload' oneof field not found in %s", wrapper)
+	}
+
+	messages := indexMessages(fds)
+
+	for _, field := range gamePacketMsg.Field {
+		if field.GetName() == "header" {
+			result.HeaderType = field.GetTypeName()[strings.LastIndex(field.GetTypeName(), ".")+1:]
+			result.Header = messageFields(messages[strings.TrimPrefix(field.GetTypeName(), ".")], messages)
+		}
 	}
 
 	// Collect fields belonging to this oneof
+	var memberErrs []error
 	for _, field := range gamePacketMsg.Field {
 		if field.OneofIndex != nil && int(*field.OneofIndex) == oneofIndex {
 			// This field is part of the payload oneof
+			if t := field.GetType(); t != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE && t != descriptorpb.FieldDescriptorProto_TYPE_GROUP {
+				memberErrs = append(memberErrs, nonMessagePayload(wrapper, field.GetName(), field.GetNumber(), fieldTypeName(field)))
+				continue
+			}
 
 			// TypeName usually returns ".package.MessageName"
 			fullType := field.GetTypeName()
@@ -137,13 +236,149 @@ func analyzeDescriptor(fds *descriptorpb.FileDescriptorSet, targetFile string) (
 				typeName = fullType[lastDot+1:]
 			}
 
-			result.Payloads = append(result.Payloads, PayloadMessage{
-				Name:      typeName,
-				FieldName: field.GetName(),
-				FullName:  strings.TrimPrefix(fullType, "."),
-			})
+			fullName := strings.TrimPrefix(fullType, ".")
+			payload := PayloadMessage{
+				Name:       typeName,
+				FieldName:  field.GetName(),
+				Number:     field.GetNumber(),
+				FullName:   fullName,
+				Admin:      strings.HasPrefix(typeName, "Admin"),
+				SampleRate: 1,
+				Direction:  DirectionBoth,
+				Fields:     messageFields(messages[fullName], messages),
+			}
+			applyOptions(&payload, messages[fullName])
+			result.Payloads = append(result.Payloads, payload)
 		}
 	}
+	if len(memberErrs) > 0 {
+		return nil, errors.Join(memberErrs...)
+	}
+
+	if err := validateOptions(result); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
+
+// nonMessagePayload reports a member of the payload oneof whose type, typeName, is a scalar or an
+// enum. Payloads are dispatched to a handler per message type, so such a member has no type to
+// dispatch on and must be wrapped in a message.
+func nonMessagePayload(wrapper, field string, number int32, typeName string) error {
+	message := pascalCase(field)
+	return fmt.Errorf("payload %s = %d of %s has type %s, but payloads must be messages, since each is dispatched to the handler of its message type; wrap it in a message instead, e.g. `message %s { %s value = 1; }` and `%s %s = %d;`",
+		field, number, wrapper, typeName, message, typeName, message, field, number)
+}
+
+// fieldTypeName returns the type of field as written in the proto file, e.g. int32 or Color
+func fieldTypeName(field *descriptorpb.FieldDescriptorProto) string {
+	if field.GetTypeName() != "" {
+		return field.GetTypeName()[strings.LastIndex(field.GetTypeName(), ".")+1:]
+	}
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+// pascalCase converts a snake_case field name to a message name (e.g., high_score to HighScore)
+func pascalCase(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// Payload returns the payload with the given type name (e.g., "LoginReq"), or nil
+func (r *ParseResult) Payload(name string) *PayloadMessage {
+	for i := range r.Payloads {
+		if r.Payloads[i].Name == name {
+			return &r.Payloads[i]
+		}
+	}
+	return nil
+}
+
+// findTargetFile returns the descriptor of targetFile within the descriptor set. Files are named
+// by their path relative to the import path they are in, so only the file at that path matches,
+// not another one with the same base name (e.g. an imported common/packet.proto).
+func findTargetFile(fds *descriptorpb.FileDescriptorSet, targetFile string) (*descriptorpb.FileDescriptorProto, error) {
+	name := protoName(targetFile)
+	for _, fd := range fds.File {
+		if fd.GetName() == name {
+			return fd, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not in the descriptor set", name)
+}
+
+// protoName returns the name protoc gives the file at path: the path relative to the first import
+// path containing it, with forward slashes. Paths outside every import path are left as they are.
+func protoName(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	for _, dir := range importPaths() {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, abs); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// indexMessages maps the full name of every message in the descriptor set (including nested ones) to its descriptor
+func indexMessages(fds *descriptorpb.FileDescriptorSet) map[string]*descriptorpb.DescriptorProto {
+	index := map[string]*descriptorpb.DescriptorProto{}
+
+	var walk func(prefix string, msgs []*descriptorpb.DescriptorProto)
+	walk = func(prefix string, msgs []*descriptorpb.DescriptorProto) {
+		for _, msg := range msgs {
+			fullName := prefix + msg.GetName()
+			index[fullName] = msg
+			walk(fullName+".", msg.NestedType)
+		}
+	}
+
+	for _, fd := range fds.File {
+		prefix := ""
+		if fd.GetPackage() != "" {
+			prefix = fd.GetPackage() + "."
+		}
+		walk(prefix, fd.MessageType)
+	}
+
+	return index
+}
+
+// messageFields describes the fields of msg. It returns nil if msg is nil.
+func messageFields(msg *descriptorpb.DescriptorProto, messages map[string]*descriptorpb.DescriptorProto) []MessageField {
+	if msg == nil {
+		return nil
+	}
+
+	fields := make([]MessageField, 0, len(msg.Field))
+	for _, field := range msg.Field {
+		typeName := strings.TrimPrefix(field.GetTypeName(), ".")
+		repeated := field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		isMap := repeated && messages[typeName].GetOptions().GetMapEntry()
+
+		fields = append(fields, MessageField{
+			Name:     field.GetName(),
+			Number:   field.GetNumber(),
+			Kind:     strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_")),
+			TypeName: typeName,
+			Repeated: repeated && !isMap,
+			Map:      isMap,
+			Optional: field.GetProto3Optional(),
+			InOneof:  field.OneofIndex != nil && !field.GetProto3Optional(),
+		})
+		applyFieldOptions(&fields[len(fields)-1], field)
+	}
+	return fields
+}
@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Schema is a reflection view of the proto file, used by tools that need to
+// encode or decode GamePackets without any generated code (e.g. the dev server)
+type Schema struct {
+	Version string // Content hash of the descriptor set, see Fingerprint
+	Files   *protoregistry.Files
+	Types   *dynamicpb.Types
+	Packet  protoreflect.MessageDescriptor // The wrapper message (GamePacket, or another envelope parsed with ParseWrapper)
+	Header  protoreflect.FieldDescriptor   // The "header" field, nil if the wrapper has none
+	Payload protoreflect.OneofDescriptor   // The "payload" oneof
+}
+
+// LoadSchema runs protoc against protoFile and builds a Schema from the resulting descriptors
+func LoadSchema(protoFile string) (*Schema, error) {
+	fds, err := LoadDescriptorSet(protoFile)
+	if err != nil {
+		return nil, err
+	}
+
+	targetFileDesc, err := findTargetFile(fds, protoFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSchema(fds, targetFileDesc.GetName())
+}
+
+// LoadSchemaFromDescriptorSet builds a Schema from a serialized FileDescriptorSet file
+// (e.g. produced by protoc --descriptor_set_out or pulled from a schema registry)
+func LoadSchemaFromDescriptorSet(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor file: %w", err)
+	}
+
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal descriptor set: %w", err)
+	}
+
+	return NewSchema(&fds, "")
+}
+
+// NewSchema builds a Schema from a descriptor set. fileName is the path of the file defining the
+// envelope, named Wrapper, within the set; if empty, the set is searched for a file defining it.
+func NewSchema(fds *descriptorpb.FileDescriptorSet, fileName string) (*Schema, error) {
+	return newSchema(fds, fileName, Wrapper)
+}
+
+func newSchema(fds *descriptorpb.FileDescriptorSet, fileName, wrapper string) (*Schema, error) {
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build file registry: %w", err)
+	}
+
+	var packet protoreflect.MessageDescriptor
+	if fileName != "" {
+		fd, err := files.FindFileByPath(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s in registry: %w", fileName, err)
+		}
+		packet = fd.Messages().ByName(protoreflect.Name(wrapper))
+	} else {
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			packet = fd.Messages().ByName(protoreflect.Name(wrapper))
+			return packet == nil
+		})
+	}
+
+	if packet == nil {
+		return nil, fmt.Errorf("message '%s' not found", wrapper)
+	}
+
+	payload := packet.Oneofs().ByName("payload")
+	if payload == nil {
+		return nil, fmt.Errorf("'payload' oneof field not found in %s", wrapper)
+	}
+	var memberErrs []error
+	for i := range payload.Fields().Len() {
+		if fd := payload.Fields().Get(i); fd.Message() == nil {
+			memberErrs = append(memberErrs, nonMessagePayload(wrapper, string(fd.Name()), int32(fd.Number()), fieldTypeName(protodesc.ToFieldDescriptorProto(fd))))
+		}
+	}
+	if len(memberErrs) > 0 {
+		return nil, errors.Join(memberErrs...)
+	}
+
+	return &Schema{
+		Version: Fingerprint(fds),
+		Files:   files,
+		Types:   dynamicpb.NewTypes(files),
+		Packet:  packet,
+		Header:  packet.Fields().ByName("header"),
+		Payload: payload,
+	}, nil
+}
+
+// NewPacket returns an empty dynamic GamePacket
+func (s *Schema) NewPacket() *dynamicpb.Message {
+	return dynamicpb.NewMessage(s.Packet)
+}
+
+// Decode unmarshals raw bytes into a dynamic GamePacket
+func (s *Schema) Decode(data []byte) (*dynamicpb.Message, error) {
+	pkt := s.NewPacket()
+	if err := (proto.UnmarshalOptions{Resolver: s.Types}).Unmarshal(data, pkt); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+// PayloadField returns the oneof member set on pkt, or nil if no payload is set
+func (s *Schema) PayloadField(pkt protoreflect.Message) protoreflect.FieldDescriptor {
+	return pkt.WhichOneof(s.Payload)
+}
+
+// PayloadByName returns the payload oneof member with the given field name (e.g. "login_req"), or nil
+func (s *Schema) PayloadByName(name string) protoreflect.FieldDescriptor {
+	return s.Payload.Fields().ByName(protoreflect.Name(name))
+}
+
+// Format renders pkt as indented protobuf JSON
+func (s *Schema) Format(pkt protoreflect.Message) string {
+	body, err := protojson.MarshalOptions{
+		Multiline: true,
+		Indent:    "  ",
+		Resolver:  s.Types,
+	}.Marshal(pkt.Interface())
+	if err != nil {
+		return fmt.Sprintf("<unprintable: %v>", err)
+	}
+	return string(body)
+}
+
+// ParseJSON parses protobuf JSON into msg, resolving Any and extension types against the schema
+func (s *Schema) ParseJSON(data []byte, msg proto.Message) error {
+	return protojson.UnmarshalOptions{Resolver: s.Types}.Unmarshal(data, msg)
+}
+
+// FilePaths returns the paths of every file in the schema, including imports, resolved from the
+// import paths
+func (s *Schema) FilePaths() []string {
+	paths := make([]string, 0, s.Files.NumFiles())
+	s.Files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		paths = append(paths, SourcePath(fd.Path()))
+		return true
+	})
+	return paths
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snowmerak/socketgen/options"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TextCode is the code of a user-facing text: servers send the code, and clients look its text up
+// in the message catalog of the player's locale
+type TextCode struct {
+	Code    string // The enum value name, the key of the catalog (e.g., "ERROR_INVENTORY_FULL")
+	Number  int32  // The enum value number
+	Comment string // The value's comment in the proto file, the text of the catalog scaffold
+}
+
+// TextCodeEnum is an enum whose values are text codes, the type of a field annotated with
+// option (socketgen.text_code)
+type TextCodeEnum struct {
+	Name     string     // Identifier of the enum, with the names of its parents (e.g., "ErrorResReason")
+	FullName string     // e.g., "packet.ErrorRes.Reason"
+	Codes    []TextCode // In declaration order, without the zero value, which means no text
+}
+
+// TextCodeField is a field annotated with option (socketgen.text_code)
+type TextCodeField struct {
+	Message string // Full name of the message declaring the field (e.g., "packet.ErrorRes")
+	Name    string // The field name (e.g., "reason")
+	Enum    string // Full name of the field's enum, "" for a string field
+}
+
+// TextCodes are the text codes of the protocol
+type TextCodes struct {
+	Fields []TextCodeField
+	Enums  []TextCodeEnum
+}
+
+// HasTextCodes reports whether any field of the protocol is annotated with option
+// (socketgen.text_code)
+func (r *ParseResult) HasTextCodes() bool {
+	return len(r.TextCodes().Fields) > 0
+}
+
+// TextCodes returns the fields annotated with option (socketgen.text_code) in the header, the
+// payloads and the messages they are made of, and the enums of those fields
+func (r *ParseResult) TextCodes() TextCodes {
+	codes, _ := textCodes(r.Schema)
+	return codes
+}
+
+// textCodes walks the messages of the envelope of schema for fields annotated with option
+// (socketgen.text_code), checking that they are singular strings or enums and that no two codes
+// are the same
+func textCodes(schema *Schema) (TextCodes, error) {
+	var codes TextCodes
+	seen := make(map[protoreflect.FullName]bool)
+	owners := make(map[string]string) // Enum of each code
+
+	var walk func(md protoreflect.MessageDescriptor) error
+	walk = func(md protoreflect.MessageDescriptor) error {
+		if seen[md.FullName()] {
+			return nil
+		}
+		seen[md.FullName()] = true
+
+		fields := md.Fields()
+		for i := range fields.Len() {
+			fd := fields.Get(i)
+			if proto.GetExtension(fd.Options(), options.E_TextCode).(bool) {
+				field := TextCodeField{Message: string(md.FullName()), Name: string(fd.Name())}
+				if fd.Cardinality() == protoreflect.Repeated || (fd.Kind() != protoreflect.StringKind && fd.Kind() != protoreflect.EnumKind) {
+					return fmt.Errorf("%s: option (socketgen.text_code) needs a singular string or enum field", fd.FullName())
+				}
+				if ed := fd.Enum(); ed != nil {
+					field.Enum = string(ed.FullName())
+					if !seen[ed.FullName()] {
+						seen[ed.FullName()] = true
+						enum := textCodeEnum(ed)
+						for _, c := range enum.Codes {
+							if other, ok := owners[c.Code]; ok {
+								return fmt.Errorf("text code %s is a value of both %s and %s; codes key the message catalog, so they must be unique", c.Code, other, enum.FullName)
+							}
+							owners[c.Code] = enum.FullName
+						}
+						codes.Enums = append(codes.Enums, enum)
+					}
+				}
+				codes.Fields = append(codes.Fields, field)
+			}
+
+			if sub := fd.Message(); sub != nil { // Map entries included
+				if err := walk(sub); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return codes, walk(schema.Packet)
+}
+
+func textCodeEnum(ed protoreflect.EnumDescriptor) TextCodeEnum {
+	pkg := string(ed.ParentFile().Package())
+	enum := TextCodeEnum{
+		Name:     strings.ReplaceAll(strings.TrimPrefix(string(ed.FullName()), pkg+"."), ".", ""),
+		FullName: string(ed.FullName()),
+	}
+	values := ed.Values()
+	for i := range values.Len() {
+		v := values.Get(i)
+		if v.Number() == 0 {
+			continue
+		}
+		enum.Codes = append(enum.Codes, TextCode{Code: string(v.Name()), Number: int32(v.Number()), Comment: comment(v)})
+	}
+	return enum
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// validationExtensions are the field options holding constraints: buf.validate (protovalidate)
+// and its predecessor protoc-gen-validate. Both are found by name in the descriptor set, so
+// socketgen does not depend on either.
+var validationExtensions = []protoreflect.FullName{"buf.validate.field", "validate.rules"}
+
+// ValidationRule is one constraint of a field, from buf.validate or protoc-gen-validate
+// (e.g., `[(buf.validate.field).string.min_len = 3]`)
+type ValidationRule struct {
+	Type   string   // The rules group (e.g., "string", "int32", "repeated"); "" for rules on the field itself, such as required
+	Name   string   // The rule (e.g., "min_len", "gte", "pattern")
+	Value  string   // Numbers and bools as literals, strings and bytes as they are; "" for rules holding messages
+	Values []string // The values of list rules, such as "in" and "not_in"
+}
+
+// applyValidationRules reads the constraints of every payload field from the schema
+func applyValidationRules(r *ParseResult) {
+	var extensions []protoreflect.ExtensionType
+	for _, name := range validationExtensions {
+		if xt, err := r.Schema.Types.FindExtensionByName(name); err == nil {
+			extensions = append(extensions, xt)
+		}
+	}
+	if len(extensions) == 0 {
+		return
+	}
+
+	for i := range r.Payloads {
+		p := &r.Payloads[i]
+		desc, err := r.Schema.Files.FindDescriptorByName(protoreflect.FullName(p.FullName))
+		if err != nil {
+			continue
+		}
+		md, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok {
+			continue
+		}
+		for j := range p.Fields {
+			fd := md.Fields().ByNumber(protoreflect.FieldNumber(p.Fields[j].Number))
+			if fd != nil {
+				p.Fields[j].Rules = fieldRules(r, fd, extensions)
+			}
+		}
+	}
+}
+
+// fieldRules decodes the constraint options of fd. They are unknown fields of the options until
+// they are decoded again with the schema's types.
+func fieldRules(r *ParseResult, fd protoreflect.FieldDescriptor, extensions []protoreflect.ExtensionType) []ValidationRule {
+	raw, ok := fd.Options().(*descriptorpb.FieldOptions)
+	if !ok || raw == nil {
+		return nil
+	}
+	data, err := proto.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	opts := &descriptorpb.FieldOptions{}
+	if err := (proto.UnmarshalOptions{Resolver: r.Schema.Types}).Unmarshal(data, opts); err != nil {
+		return nil
+	}
+
+	var rules []ValidationRule
+	for _, xt := range extensions {
+		if !opts.ProtoReflect().Has(xt.TypeDescriptor()) {
+			continue
+		}
+		rangeSet(opts.ProtoReflect().Get(xt.TypeDescriptor()).Message(), func(group protoreflect.FieldDescriptor, v protoreflect.Value) {
+			if group.Message() == nil || group.IsList() {
+				rules = append(rules, newValidationRule("", group, v))
+				return
+			}
+			rangeSet(v.Message(), func(rule protoreflect.FieldDescriptor, v protoreflect.Value) {
+				// protoc-gen-validate nests required under the message rules
+				if rule.Name() == "required" {
+					rules = append(rules, newValidationRule("", rule, v))
+				} else {
+					rules = append(rules, newValidationRule(string(group.Name()), rule, v))
+				}
+			})
+		})
+	}
+	return rules
+}
+
+// rangeSet calls fn for the fields set on m in declaration order, unlike m.Range, so generated
+// checks do not change order between runs
+func rangeSet(m protoreflect.Message, fn func(protoreflect.FieldDescriptor, protoreflect.Value)) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); m.Has(fd) {
+			fn(fd, m.Get(fd))
+		}
+	}
+}
+
+func newValidationRule(group string, fd protoreflect.FieldDescriptor, v protoreflect.Value) ValidationRule {
+	rule := ValidationRule{Type: group, Name: string(fd.Name())}
+	if fd.IsList() {
+		for i := 0; i < v.List().Len(); i++ {
+			rule.Values = append(rule.Values, ruleValue(fd, v.List().Get(i)))
+		}
+		return rule
+	}
+	rule.Value = ruleValue(fd, v)
+	return rule
+}
+
+func ruleValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.EnumKind:
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return string(v.Bytes())
+	}
+	return ""
+}
+
+// HasValidation reports whether any payload field has validation rules
+func (r *ParseResult) HasValidation() bool {
+	for i := range r.Payloads {
+		if r.Payloads[i].Validated() {
+			return true
+		}
+	}
+	return false
+}
+
+// Validated reports whether any field of p has validation rules
+func (p *PayloadMessage) Validated() bool {
+	for _, f := range p.Fields {
+		if len(f.Rules) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/scenario"
+)
+
+// Fixtures converts entries into the dev server fixture format: every payload the clients sent
+// maps to the packets the server answered with, taken from the first time that payload was seen.
+// Headers are dropped from the responses, so replayed responses inherit the request's header.
+func Fixtures(schema *parser.Schema, entries []Entry) (map[string][]json.RawMessage, error) {
+	fixtures := map[string][]json.RawMessage{}
+	pending := map[string]string{} // Peer -> payload whose responses are being collected
+
+	for _, e := range entries {
+		switch e.Direction {
+		case Inbound:
+			delete(pending, e.Peer)
+			if _, ok := fixtures[e.Payload]; e.Payload != "" && !ok {
+				fixtures[e.Payload] = []json.RawMessage{}
+				pending[e.Peer] = e.Payload
+			}
+		case Outbound:
+			request, ok := pending[e.Peer]
+			if !ok {
+				continue
+			}
+			packet, err := withoutHeader(schema, e.Packet)
+			if err != nil {
+				return nil, err
+			}
+			fixtures[request] = append(fixtures[request], packet)
+		}
+	}
+
+	// A request that was never answered gets no fixture, so the dev server falls back to echo
+	for payload, responses := range fixtures {
+		if len(responses) == 0 {
+			delete(fixtures, payload)
+		}
+	}
+	return fixtures, nil
+}
+
+// Scenario converts the session of one peer into a bot scenario: packets the client sent become
+// send steps, and packets the server answered with become expect steps matching the recorded payload.
+// The header of the first sent packet is used for the whole scenario.
+func Scenario(schema *parser.Schema, entries []Entry, peer string) (*scenario.Scenario, error) {
+	s := &scenario.Scenario{Name: "recorded session of " + peer}
+
+	for _, e := range entries {
+		if e.Peer != peer || e.Payload == "" {
+			continue
+		}
+
+		var packet map[string]any
+		if err := json.Unmarshal(e.Packet, &packet); err != nil {
+			return nil, err
+		}
+		payload, _ := packet[schema.PayloadByName(e.Payload).JSONName()].(map[string]any)
+		if payload == nil {
+			payload = map[string]any{}
+		}
+
+		switch e.Direction {
+		case Inbound:
+			if s.Header == nil && schema.Header != nil {
+				s.Header, _ = packet[schema.Header.JSONName()].(map[string]any)
+			}
+			s.Steps = append(s.Steps, scenario.Step{Send: e.Payload, Body: payload})
+		case Outbound:
+			s.Steps = append(s.Steps, scenario.Step{Expect: e.Payload, Match: payload})
+		}
+	}
+
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("no packets recorded for peer %s", peer)
+	}
+	return s, nil
+}
+
+// withoutHeader removes the header from a GamePacket in protobuf JSON form
+func withoutHeader(schema *parser.Schema, packet json.RawMessage) (json.RawMessage, error) {
+	if schema.Header == nil {
+		return packet, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(packet, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, schema.Header.JSONName())
+	return json.Marshal(fields)
+}
@@ -0,0 +1,236 @@
+// Package recording captures packet streams as JSON Lines or replay files (see package replay)
+// and converts them into dev server fixtures and bot scenarios, so real sessions can be replayed
+// as regression tests.
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/replay"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ReplayExt is the file extension of recordings in the replay format; other files are recorded
+// as JSON Lines
+const ReplayExt = ".sgr"
+
+// Direction tells whether a packet was sent by the client or by the server
+type Direction string
+
+const (
+	Inbound  Direction = "in"  // Client to server
+	Outbound Direction = "out" // Server to client
+)
+
+// Entry is one recorded packet, stored as one line of a recording file
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Peer      string          `json:"peer"`
+	Direction Direction       `json:"direction"`
+	Payload   string          `json:"payload"` // Payload field name, or "" if no payload is set
+	Packet    json.RawMessage `json:"packet"`  // The GamePacket as protobuf JSON
+}
+
+// Recorder appends packets to a recording. It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	out    io.Writer
+	replay *replay.Writer // Set for recordings in the replay format
+	closer io.Closer
+}
+
+// NewRecorder creates a recorder writing JSON Lines to out
+func NewRecorder(out io.Writer) *Recorder {
+	return &Recorder{out: out}
+}
+
+// NewReplayRecorder creates a recorder writing replay frames to w
+func NewReplayRecorder(w *replay.Writer) *Recorder {
+	return &Recorder{replay: w}
+}
+
+// Create opens the recording at path for appending, in the replay format if path ends in
+// ReplayExt. A replay can only be continued with the schema it was recorded with; the header
+// keeps that schema even if the schema is reloaded while recording.
+func Create(path string, schema *parser.Schema) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) != ReplayExt {
+		return &Recorder{out: f, closer: f}, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var w *replay.Writer
+	if info.Size() == 0 {
+		w, err = replay.NewWriter(f, replay.Header{Schema: schema.Version})
+	} else {
+		var header replay.Header
+		if header, err = replay.ReadHeader(f); err == nil {
+			if err = header.CheckSchema(schema.Version); err == nil {
+				w = replay.AppendWriter(f, header)
+			}
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to continue replay %s: %w", path, err)
+	}
+	return &Recorder{replay: w, closer: f}, nil
+}
+
+// Close closes the file opened by Create
+func (r *Recorder) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Record appends pkt, sent or received by peer, to the recording
+func (r *Recorder) Record(schema *parser.Schema, peer string, direction Direction, pkt protoreflect.Message) error {
+	if r.replay != nil {
+		return r.recordFrame(peer, direction, pkt)
+	}
+
+	packet, err := protojson.MarshalOptions{Resolver: schema.Types}.Marshal(pkt.Interface())
+	if err != nil {
+		return fmt.Errorf("failed to encode packet: %w", err)
+	}
+
+	entry := Entry{
+		Time:      time.Now(),
+		Peer:      peer,
+		Direction: direction,
+		Packet:    packet,
+	}
+	if field := schema.PayloadField(pkt); field != nil {
+		entry.Payload = string(field.Name())
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.out.Write(append(line, '\n'))
+	return err
+}
+
+func (r *Recorder) recordFrame(peer string, direction Direction, pkt protoreflect.Message) error {
+	data, err := proto.Marshal(pkt.Interface())
+	if err != nil {
+		return fmt.Errorf("failed to encode packet: %w", err)
+	}
+	frame := replay.Frame{Time: time.Now(), Direction: replay.Inbound, Peer: peer, Packet: data}
+	if direction == Outbound {
+		frame.Direction = replay.Outbound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.replay.WriteFrame(frame)
+}
+
+// Load reads a recording file, JSON Lines or replay, and checks every packet against the schema
+func Load(path string, schema *parser.Schema) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	defer f.Close()
+
+	in := bufio.NewReader(f)
+	if magic, _ := in.Peek(len(replay.Magic)); string(magic) == replay.Magic {
+		return loadReplay(path, in, schema)
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if err := schema.ParseJSON(entry.Packet, schema.NewPacket()); err != nil {
+			return nil, fmt.Errorf("%s:%d: packet does not match the schema: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return entries, nil
+}
+
+// loadReplay converts the frames of a replay into entries
+func loadReplay(path string, in io.Reader, schema *parser.Schema) ([]Entry, error) {
+	r, err := replay.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var entries []Entry
+	for i := 1; ; i++ {
+		frame, err := r.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: frame %d: %w", path, i, err)
+		}
+
+		pkt, err := schema.Decode(frame.Packet)
+		if err != nil {
+			return nil, fmt.Errorf("%s: frame %d: packet does not match the schema: %w", path, i, err)
+		}
+		packet, err := protojson.MarshalOptions{Resolver: schema.Types}.Marshal(pkt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: frame %d: %w", path, i, err)
+		}
+
+		entry := Entry{Time: frame.Time, Peer: frame.Peer, Direction: Inbound, Packet: packet}
+		if frame.Direction == replay.Outbound {
+			entry.Direction = Outbound
+		}
+		if field := schema.PayloadField(pkt); field != nil {
+			entry.Payload = string(field.Name())
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// Peers returns the distinct peers in entries, in order of first appearance
+func Peers(entries []Entry) []string {
+	var peers []string
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if !seen[e.Peer] {
+			seen[e.Peer] = true
+			peers = append(peers, e.Peer)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,135 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// VersionHeader carries the schema fingerprint on registry requests and responses
+const VersionHeader = "X-Socketgen-Schema"
+
+// Client talks to a simple HTTP schema registry.
+//
+// The registry stores serialized FileDescriptorSets under a schema name and a version tag:
+//
+//	PUT /schemas/{name}/{tag}   store a descriptor set (application/x-protobuf)
+//	GET /schemas/{name}/{tag}   fetch a descriptor set
+//	GET /schemas/{name}         list tags as JSON
+type Client struct {
+	BaseURL string
+	Token   string // Sent as a bearer token when set
+	HTTP    *http.Client
+}
+
+// NewClient creates a registry client for baseURL
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+		HTTP:    http.DefaultClient,
+	}
+}
+
+// Push uploads the descriptor set under name and tag. version is the schema fingerprint.
+func (c *Client) Push(name, tag, version string, fds *descriptorpb.FileDescriptorSet) error {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(fds)
+	if err != nil {
+		return fmt.Errorf("failed to encode descriptor set: %w", err)
+	}
+
+	req, err := c.newRequest(http.MethodPut, c.schemaURL(name, tag), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set(VersionHeader, version)
+
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Pull downloads the descriptor set stored under name and tag, along with its schema fingerprint
+func (c *Client) Pull(name, tag string) (*descriptorpb.FileDescriptorSet, string, error) {
+	req, err := c.newRequest(http.MethodGet, c.schemaURL(name, tag), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, "", fmt.Errorf("failed to decode descriptor set: %w", err)
+	}
+	return &fds, res.Header.Get(VersionHeader), nil
+}
+
+// Tags lists the version tags stored for name
+func (c *Client) Tags(name string) ([]string, error) {
+	req, err := c.newRequest(http.MethodGet, c.BaseURL+"/schemas/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var tags []string
+	if err := json.NewDecoder(res.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to decode tag list: %w", err)
+	}
+	return tags, nil
+}
+
+func (c *Client) schemaURL(name, tag string) string {
+	return c.BaseURL + "/schemas/" + url.PathEscape(name) + "/" + url.PathEscape(tag)
+}
+
+func (c *Client) newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+// do sends req and turns non-2xx responses into errors
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("registry returned %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return res, nil
+}
@@ -0,0 +1,140 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSchemaSize limits uploaded descriptor sets
+const maxSchemaSize = 16 << 20
+
+// validName matches schema names and tags; a leading dot is rejected so ".." cannot escape Dir
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// Server is a minimal file-backed registry implementing the protocol described on Client.
+// Tags are immutable: pushing different content to an existing tag is rejected.
+type Server struct {
+	Dir   string
+	Token string // Required bearer token for every request when set
+}
+
+// Handler returns the HTTP handler serving the registry API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /schemas/{name}", s.listTags)
+	mux.HandleFunc("GET /schemas/{name}/{tag}", s.get)
+	mux.HandleFunc("PUT /schemas/{name}/{tag}", s.put)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !validName.MatchString(name) {
+		http.Error(w, "invalid schema name", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.Dir, name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tags := []string{}
+	for _, e := range entries {
+		if tag, ok := strings.CutSuffix(e.Name(), ".pb"); ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	path, ok := s.schemaPath(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := os.ReadFile(path + ".pb")
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "schema not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	version, _ := os.ReadFile(path + ".version")
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set(VersionHeader, string(version))
+	w.Write(data)
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request) {
+	path, ok := s.schemaPath(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxSchemaSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxSchemaSize {
+		http.Error(w, "schema too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if existing, err := os.ReadFile(path + ".pb"); err == nil {
+		if !bytes.Equal(existing, data) {
+			http.Error(w, "tag already exists with different content", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(path+".version", []byte(r.Header.Get(VersionHeader)), 0644); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(path+".pb", data, 0644); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// schemaPath validates the name and tag path values and returns the storage path without extension
+func (s *Server) schemaPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name, tag := r.PathValue("name"), r.PathValue("tag")
+	if !validName.MatchString(name) || !validName.MatchString(tag) {
+		http.Error(w, "invalid schema name or tag", http.StatusBadRequest)
+		return "", false
+	}
+	return filepath.Join(s.Dir, name, tag), true
+}
@@ -0,0 +1,267 @@
+// Package replay reads and writes socketgen replay files, the binary recording format for
+// replay viewers and analysis tools. It only depends on the standard library; decode packets
+// with the generated GamePacket type of the schema the replay was recorded with.
+//
+// A replay file is a header followed by frames. All integers are unsigned varints (the
+// protobuf base 128 encoding) unless noted otherwise.
+//
+//	header:
+//	  magic        4 bytes  "SGRP"
+//	  version      1 byte   Version (1)
+//	  schema_len   varint   length of schema
+//	  schema       bytes    schema fingerprint (SchemaVersion of the generated code)
+//	  start        varint   recording start, Unix time in nanoseconds
+//
+//	frame:
+//	  frame_len    varint   length of the rest of the frame
+//	  offset       varint   nanoseconds since start
+//	  direction    1 byte   0 = client to server, 1 = server to client
+//	  peer_len     varint   length of peer
+//	  peer         bytes    peer address (UTF-8)
+//	  packet       bytes    the encoded GamePacket, up to the end of the frame
+//
+// The version is bumped for any change to this layout.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Magic starts every replay file
+const Magic = "SGRP"
+
+// Version is the format version written by this package
+const Version = 1
+
+// MaxFrameSize is the largest frame a Reader accepts
+const MaxFrameSize = 16 << 20
+
+var (
+	// ErrNotReplay is returned when a file does not start with Magic
+	ErrNotReplay = errors.New("not a socketgen replay file")
+	// ErrSchemaMismatch is returned by CheckSchema when a replay was recorded with another schema
+	ErrSchemaMismatch = errors.New("replay schema mismatch")
+)
+
+// Direction tells who sent a packet
+type Direction byte
+
+const (
+	Inbound  Direction = 0 // Client to server
+	Outbound Direction = 1 // Server to client
+)
+
+// Header describes a replay file
+type Header struct {
+	Version int
+	Schema  string // Schema fingerprint the packets were encoded with
+	Start   time.Time
+}
+
+// CheckSchema returns ErrSchemaMismatch unless the replay was recorded with schema
+func (h Header) CheckSchema(schema string) error {
+	if h.Schema != schema {
+		return fmt.Errorf("%w: recorded with %s, this build has %s", ErrSchemaMismatch, h.Schema, schema)
+	}
+	return nil
+}
+
+// Frame is one recorded packet
+type Frame struct {
+	Time      time.Time
+	Direction Direction
+	Peer      string
+	Packet    []byte // The encoded GamePacket
+}
+
+// Writer appends frames to a replay file. It is not safe for concurrent use.
+type Writer struct {
+	w      io.Writer
+	header Header
+	buf    []byte
+}
+
+// NewWriter writes header to w and returns a Writer for its frames. A zero header.Start is
+// replaced by the current time.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	if header.Start.IsZero() {
+		header.Start = time.Now()
+	}
+	header.Version = Version
+
+	buf := append([]byte(Magic), Version)
+	buf = binary.AppendUvarint(buf, uint64(len(header.Schema)))
+	buf = append(buf, header.Schema...)
+	buf = binary.AppendUvarint(buf, uint64(header.Start.UnixNano()))
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, header: header}, nil
+}
+
+// AppendWriter returns a Writer adding frames to a replay whose header was already written,
+// e.g. when a recording is continued
+func AppendWriter(w io.Writer, header Header) *Writer {
+	return &Writer{w: w, header: header}
+}
+
+// Header returns the header of the replay
+func (w *Writer) Header() Header {
+	return w.header
+}
+
+// WriteFrame appends f. Frames recorded before the start of the replay are written at offset 0.
+func (w *Writer) WriteFrame(f Frame) error {
+	offset := f.Time.Sub(w.header.Start)
+	if offset < 0 {
+		offset = 0
+	}
+
+	size := uvarintLen(uint64(offset)) + 1 + uvarintLen(uint64(len(f.Peer))) + len(f.Peer) + len(f.Packet)
+	buf := binary.AppendUvarint(w.buf[:0], uint64(size))
+	buf = binary.AppendUvarint(buf, uint64(offset))
+	buf = append(buf, byte(f.Direction))
+	buf = binary.AppendUvarint(buf, uint64(len(f.Peer)))
+	buf = append(buf, f.Peer...)
+	buf = append(buf, f.Packet...)
+	w.buf = buf
+
+	// One write per frame, so a killed recorder leaves at most one partial frame at the end
+	_, err := w.w.Write(buf)
+	return err
+}
+
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// Reader reads the frames of a replay file
+type Reader struct {
+	r      *bufio.Reader
+	header Header
+}
+
+// NewReader reads the header of a replay from r
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: br, header: header}, nil
+}
+
+// ReadHeader reads only the header of a replay, e.g. to check its schema before appending to it
+func ReadHeader(r io.Reader) (Header, error) {
+	return readHeader(bufio.NewReader(r))
+}
+
+func readHeader(r *bufio.Reader) (Header, error) {
+	magic := make([]byte, len(Magic)+1)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return Header{}, ErrNotReplay
+		}
+		return Header{}, err
+	}
+	if !bytes.Equal(magic[:len(Magic)], []byte(Magic)) {
+		return Header{}, ErrNotReplay
+	}
+	if magic[len(Magic)] != Version {
+		return Header{}, fmt.Errorf("unsupported replay version %d", magic[len(Magic)])
+	}
+
+	schemaLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Header{}, fmt.Errorf("malformed replay header: %w", err)
+	}
+	if schemaLen > 1024 {
+		return Header{}, fmt.Errorf("malformed replay header: schema of %d bytes", schemaLen)
+	}
+	schema := make([]byte, schemaLen)
+	if _, err := io.ReadFull(r, schema); err != nil {
+		return Header{}, fmt.Errorf("malformed replay header: %w", err)
+	}
+	start, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Header{}, fmt.Errorf("malformed replay header: %w", err)
+	}
+
+	return Header{
+		Version: int(magic[len(Magic)]),
+		Schema:  string(schema),
+		Start:   time.Unix(0, int64(start)),
+	}, nil
+}
+
+// Header returns the header of the replay
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the next frame, or io.EOF after the last one. A file that ends inside a frame,
+// e.g. because the recorder was killed, returns io.ErrUnexpectedEOF.
+func (r *Reader) Next() (Frame, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Frame{}, io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+	if size > MaxFrameSize {
+		return Frame{}, fmt.Errorf("replay frame of %d bytes exceeds %d", size, MaxFrameSize)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+
+	offset, n := binary.Uvarint(body)
+	if n <= 0 || n >= len(body) {
+		return Frame{}, errors.New("malformed replay frame")
+	}
+	direction := Direction(body[n])
+	body = body[n+1:]
+	peerLen, n := binary.Uvarint(body)
+	if n <= 0 || uint64(len(body)-n) < peerLen {
+		return Frame{}, errors.New("malformed replay frame")
+	}
+	peer := string(body[n : n+int(peerLen)])
+
+	return Frame{
+		Time:      r.header.Start.Add(time.Duration(offset)),
+		Direction: direction,
+		Peer:      peer,
+		Packet:    body[n+int(peerLen):],
+	}, nil
+}
+
+// ReadAll returns the remaining frames of r
+func (r *Reader) ReadAll() ([]Frame, error) {
+	var frames []Frame
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, f)
+	}
+}
@@ -0,0 +1,253 @@
+// Package sample fills protobuf messages with deterministic, realistic values derived from the schema.
+package sample
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxDepth stops recursion into nested (possibly recursive) messages
+const maxDepth = 3
+
+// Well-known types with a meaning of their own
+const (
+	anyType       = "google.protobuf.Any"
+	timestampType = "google.protobuf.Timestamp"
+	durationType  = "google.protobuf.Duration"
+)
+
+// sampleTime is the time samples are taken at: 2023-11-14T22:13:20Z
+const sampleTime = 1700000000
+
+// Fill sets every field of msg to a sample value. The values depend only on the schema (field
+// names, numbers, and types), so the same schema always produces the same message. They are
+// chosen to look like real data by the field name: user_id becomes "user-1", email an address,
+// created_at a timestamp, page_size a small count, and so on; fields without a telling name hold
+// their name (strings) or number (numbers). Bools are true, enums take their first non-zero value,
+// repeated fields get two distinct elements, maps get one entry, and nested messages are filled up
+// to a depth of 3. Only the first member of each oneof is set, and google.protobuf.Any fields are
+// left empty, since no sample type can be named in them.
+func Fill(msg protoreflect.Message) {
+	fill(msg, 0, 0)
+}
+
+// JSON returns a sample message of type md, see Fill, as compact protobuf JSON
+func JSON(md protoreflect.MessageDescriptor) ([]byte, error) {
+	msg := dynamicpb.NewMessage(md)
+	Fill(msg)
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// protojson varies its whitespace on purpose; compacting keeps the output stable
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fill fills msg, a message at depth, as the element n of its list (0 otherwise)
+func fill(msg protoreflect.Message, depth, n int) {
+	switch msg.Descriptor().FullName() {
+	case timestampType:
+		msg.Set(msg.Descriptor().Fields().ByName("seconds"), protoreflect.ValueOfInt64(sampleTime+int64(n)))
+		return
+	case durationType:
+		msg.Set(msg.Descriptor().Fields().ByName("seconds"), protoreflect.ValueOfInt64(30))
+		return
+	}
+
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() && oneof.Fields().Get(0) != fd {
+			continue
+		}
+		if fd.Message() != nil && fd.Message().FullName() == anyType {
+			continue
+		}
+
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Kind() == protoreflect.MessageKind && depth >= maxDepth {
+				continue
+			}
+			m := msg.Mutable(fd).Map()
+			key := scalarValue(fd.MapKey(), fd.Name(), n).MapKey()
+			if fd.MapValue().Kind() == protoreflect.MessageKind {
+				fill(m.Mutable(key).Message(), depth+1, n)
+			} else {
+				m.Set(key, scalarValue(fd.MapValue(), fd.Name(), n))
+			}
+		case fd.IsList():
+			if fd.Kind() == protoreflect.MessageKind && depth >= maxDepth {
+				continue
+			}
+			list := msg.Mutable(fd).List()
+			for i := 0; i < 2; i++ {
+				if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+					elem := list.NewElement()
+					fill(elem.Message(), depth+1, n+i)
+					list.Append(elem)
+				} else {
+					list.Append(scalarValue(fd, fd.Name(), n+i))
+				}
+			}
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			if depth >= maxDepth {
+				continue
+			}
+			fill(msg.Mutable(fd).Message(), depth+1, n)
+		default:
+			msg.Set(fd, scalarValue(fd, fd.Name(), n))
+		}
+	}
+}
+
+// scalarValue returns the sample value for a non-message field, or a map key or value, named
+// name; n is the index of the list element it is in, so the elements differ
+func scalarValue(fd protoreflect.FieldDescriptor, name protoreflect.Name, n int) protoreflect.Value {
+	words := strings.Split(strings.ToLower(string(name)), "_")
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			if values.Get(i).Number() != 0 {
+				// List elements take successive values
+				if next := i + n; next < values.Len() {
+					return protoreflect.ValueOfEnum(values.Get(next).Number())
+				}
+				return protoreflect.ValueOfEnum(values.Get(i).Number())
+			}
+		}
+		return protoreflect.ValueOfEnum(0)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(intValue(fd, words, n, false)))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(intValue(fd, words, n, true))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(intValue(fd, words, n, false)))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(intValue(fd, words, n, true)))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(floatValue(fd, words, n)))
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(floatValue(fd, words, n))
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(stringValue(words, n))
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(stringValue(words, n)))
+	}
+	return protoreflect.Value{}
+}
+
+// has reports whether the last word of a field name is one of names
+func has(words []string, names ...string) bool {
+	return slices.Contains(names, words[len(words)-1])
+}
+
+func stringValue(words []string, n int) string {
+	suffix := ""
+	if n > 0 {
+		suffix = fmt.Sprint(n + 1)
+	}
+	switch {
+	case has(words, "id", "uuid", "token", "key", "code", "ref") && len(words) > 1:
+		// user_id -> user-1
+		return fmt.Sprintf("%s-%d", strings.Join(words[:len(words)-1], "-"), n+1)
+	case has(words, "id", "uuid", "token", "key", "code", "ref"):
+		return fmt.Sprintf("%s-%d", words[0], n+1)
+	case has(words, "email", "mail"):
+		return fmt.Sprintf("alice%s@example.com", suffix)
+	case has(words, "url", "uri", "link", "endpoint", "avatar", "image", "icon"):
+		return fmt.Sprintf("https://example.com/%s%s", strings.Join(words, "-"), suffix)
+	case has(words, "name", "nickname", "username", "user", "player", "sender", "author", "owner", "from", "to", "target"):
+		return []string{"alice", "bob"}[n%2]
+	case has(words, "pw", "password", "passwd", "secret"):
+		return "s3cret!"
+	case has(words, "text", "message", "msg", "body", "content", "comment", "chat"):
+		return []string{"hello", "good game"}[n%2]
+	case has(words, "reason", "cause"):
+		return []string{"spam", "cheating"}[n%2]
+	case has(words, "title", "description", "desc", "summary", "note"):
+		return fmt.Sprintf("Example %s%s", words[len(words)-1], suffix)
+	case has(words, "tag", "tags", "label", "labels", "category", "categories", "mode", "type", "kind"):
+		return []string{"ranked", "casual"}[n%2]
+	case has(words, "lang", "language", "locale"):
+		return []string{"en", "ko"}[n%2]
+	case has(words, "country", "region"):
+		return []string{"US", "KR"}[n%2]
+	case has(words, "ip", "addr", "address", "host"):
+		return fmt.Sprintf("203.0.113.%d", n+1)
+	case has(words, "version", "ver"):
+		return "1.0." + fmt.Sprint(n)
+	case has(words, "color", "colour"):
+		return []string{"#ff0000", "#00ff00"}[n%2]
+	}
+	return strings.Join(words, "_") + suffix
+}
+
+func intValue(fd protoreflect.FieldDescriptor, words []string, n int, wide bool) int64 {
+	switch {
+	case has(words, "timestamp", "time", "at", "ts", "date", "deadline", "expiry", "expires"):
+		if wide {
+			return sampleTime*1000 + int64(n)*1000 // Milliseconds, the convention of 64-bit timestamps
+		}
+		return sampleTime + int64(n)
+	case has(words, "ms", "millis", "latency", "rtt", "timeout", "delay", "interval", "ttl"):
+		return int64(100 * (n + 1))
+	case has(words, "count", "size", "limit", "num", "total", "capacity", "max", "min", "len", "length", "seconds", "secs", "minutes", "mins", "hours", "days"):
+		return int64(10 * (n + 1))
+	case has(words, "page", "offset", "index", "idx", "rank", "slot", "seq", "sequence"):
+		return int64(n + 1)
+	case has(words, "level", "lvl", "tier", "stage", "round"):
+		return int64(5 + n)
+	case has(words, "hp", "health", "mp", "mana", "energy", "stamina", "percent", "progress"):
+		return 100
+	case has(words, "score", "exp", "xp", "points", "gold", "coins", "price", "amount", "balance", "cost"):
+		return int64(1500 * (n + 1))
+	case has(words, "port"):
+		return 8080
+	case has(words, "version", "ver", "revision"):
+		return 1
+	case has(words, "year"):
+		return 2024
+	case has(words, "id"):
+		return int64(1001 + n)
+	}
+	return int64(fd.Number()) + int64(n)
+}
+
+func floatValue(fd protoreflect.FieldDescriptor, words []string, n int) float64 {
+	switch {
+	case has(words, "x", "y", "z", "w", "pos", "position"):
+		return 12.5 + float64(n)
+	case has(words, "lat", "latitude"):
+		return 37.5665
+	case has(words, "lng", "lon", "longitude"):
+		return 126.978
+	case has(words, "angle", "yaw", "pitch", "roll", "rotation", "heading"):
+		return 90
+	case has(words, "speed", "velocity", "vx", "vy", "vz"):
+		return 3.5
+	case has(words, "ratio", "rate", "scale", "volume", "alpha", "chance", "probability"):
+		return 0.5
+	case has(words, "hp", "health", "percent", "progress"):
+		return 100
+	case has(words, "price", "amount", "balance", "cost"):
+		return 9.99
+	}
+	return float64(fd.Number()) + 0.5 + float64(n)
+}
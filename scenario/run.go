@@ -0,0 +1,188 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Conn is the transport a scenario runs over
+type Conn interface {
+	WritePacket(data []byte) error
+	// ReadPacket returns the next packet, or ctx's error once ctx is done
+	ReadPacket(ctx context.Context) ([]byte, error)
+}
+
+// Latency is the time between the last sent packet and a matched expect step
+type Latency struct {
+	Payload  string
+	Duration time.Duration
+}
+
+// Result summarizes one run of a scenario
+type Result struct {
+	Sent      int
+	Received  int
+	Latencies []Latency
+}
+
+// Runner runs a scenario as one simulated client
+type Runner struct {
+	Schema *parser.Schema
+	Conn   Conn
+	Client int // Index of the simulated client, substituted for ${client}
+
+	header   *dynamicpb.Message
+	lastSent time.Time
+	result   Result
+}
+
+// Run executes the scenario's steps in order and stops at the first failure
+func (r *Runner) Run(ctx context.Context, s *Scenario) (*Result, error) {
+	if s.Header != nil && r.Schema.Header != nil {
+		body, err := expand(s.Header, r.Client)
+		if err != nil {
+			return nil, err
+		}
+		r.header = dynamicpb.NewMessage(r.Schema.Header.Message())
+		if err := r.Schema.ParseJSON(body, r.header); err != nil {
+			return nil, fmt.Errorf("invalid header: %w", err)
+		}
+	}
+
+	err := r.runSteps(ctx, s.Steps, "steps")
+	return &r.result, err
+}
+
+func (r *Runner) runSteps(ctx context.Context, steps []Step, path string) error {
+	for i, step := range steps {
+		where := fmt.Sprintf("%s[%d]", path, i)
+
+		var err error
+		switch {
+		case step.Send != "":
+			err = r.send(step)
+		case step.Expect != "":
+			err = r.expect(ctx, step)
+		case step.Sleep > 0:
+			select {
+			case <-time.After(step.Sleep):
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		case step.Loop > 0:
+			for n := 0; n < step.Loop && err == nil; n++ {
+				err = r.runSteps(ctx, step.Steps, fmt.Sprintf("%s.steps(iteration %d)", where, n+1))
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) send(step Step) error {
+	field := r.Schema.PayloadByName(step.Send)
+	body, err := expand(step.Body, r.Client)
+	if err != nil {
+		return err
+	}
+
+	msg := dynamicpb.NewMessage(field.Message())
+	if err := r.Schema.ParseJSON(body, msg); err != nil {
+		return fmt.Errorf("invalid %s body: %w", step.Send, err)
+	}
+
+	pkt := r.Schema.NewPacket()
+	if r.header != nil {
+		pkt.Set(r.Schema.Header, protoreflect.ValueOfMessage(r.header))
+	}
+	pkt.Set(field, protoreflect.ValueOfMessage(msg))
+
+	data, err := proto.Marshal(pkt)
+	if err != nil {
+		return err
+	}
+	if err := r.Conn.WritePacket(data); err != nil {
+		return fmt.Errorf("send %s: %w", step.Send, err)
+	}
+
+	r.lastSent = time.Now()
+	r.result.Sent++
+	return nil
+}
+
+func (r *Runner) expect(ctx context.Context, step Step) error {
+	within := step.Within
+	if within <= 0 {
+		within = DefaultWithin
+	}
+	ctx, cancel := context.WithTimeout(ctx, within)
+	defer cancel()
+
+	var want map[string]any
+	if step.Match != nil {
+		body, err := expand(step.Match, r.Client)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, &want); err != nil {
+			return err
+		}
+	}
+
+	var last string // Describes the closest miss for the error message
+	for {
+		data, err := r.Conn.ReadPacket(ctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			if last != "" {
+				return fmt.Errorf("no matching %s within %s (last %s: %s)", step.Expect, within, step.Expect, last)
+			}
+			return fmt.Errorf("no %s within %s", step.Expect, within)
+		}
+		if err != nil {
+			return err
+		}
+		r.result.Received++
+
+		pkt, err := r.Schema.Decode(data)
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		field := r.Schema.PayloadField(pkt)
+		if field == nil || string(field.Name()) != step.Expect {
+			continue
+		}
+
+		if want != nil {
+			got, err := protojson.MarshalOptions{Resolver: r.Schema.Types}.Marshal(pkt.Get(field).Message().Interface())
+			if err != nil {
+				return err
+			}
+			var actual any
+			if err := json.Unmarshal(got, &actual); err != nil {
+				return err
+			}
+			if !matches(want, actual) {
+				last = string(got)
+				continue
+			}
+		}
+
+		r.result.Latencies = append(r.result.Latencies, Latency{Payload: step.Expect, Duration: time.Since(r.lastSent)})
+		return nil
+	}
+}
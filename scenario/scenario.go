@@ -0,0 +1,165 @@
+// Package scenario runs protocol scenarios written in YAML (or JSON) against a GamePacket server.
+//
+// A scenario is a list of steps, interpreted using the schema alone:
+//
+//	name: login and chat
+//	header: {requestId: "bot-${client}"}
+//	steps:
+//	  - send: login_req
+//	    body: {id: "bot-${client}", pw: secret}
+//	  - expect: login_res
+//	    within: 500ms
+//	    match: {success: true}
+//	  - loop: 10
+//	    steps:
+//	      - send: chat_msg
+//	        body: {text: hello}
+//	      - sleep: 100ms
+//
+// String values may contain ${client}, which is replaced with the index of the simulated client.
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultWithin is how long an expect step waits when it sets no `within`
+const DefaultWithin = 5 * time.Second
+
+// Scenario is a named list of steps
+type Scenario struct {
+	Name   string         `yaml:"name"`
+	Header map[string]any `yaml:"header,omitempty"` // Header attached to every sent packet, as protobuf JSON
+	Steps  []Step         `yaml:"steps,omitempty"`
+}
+
+// Step is one action. Exactly one of Send, Expect, Sleep, or Loop is set.
+type Step struct {
+	Send string         `yaml:"send,omitempty"` // Payload field name to send
+	Body map[string]any `yaml:"body,omitempty"` // Payload as protobuf JSON
+
+	Expect string         `yaml:"expect,omitempty"` // Payload field name to wait for; other packets are skipped
+	Match  map[string]any `yaml:"match,omitempty"`  // Subset of the expected payload, as protobuf JSON
+	Within time.Duration  `yaml:"within,omitempty"`
+
+	Sleep time.Duration `yaml:"sleep,omitempty"`
+
+	Loop  int    `yaml:"loop,omitempty"` // Repeats Steps this many times
+	Steps []Step `yaml:"steps,omitempty"`
+}
+
+// Load reads a scenario file. JSON files work as well, since JSON is valid YAML.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = path
+	}
+	return &s, nil
+}
+
+// Validate checks that every step is well-formed and names payloads that exist in schema
+func (s *Scenario) Validate(schema *parser.Schema) error {
+	return validateSteps(s.Steps, schema, "steps")
+}
+
+func validateSteps(steps []Step, schema *parser.Schema, path string) error {
+	for i, step := range steps {
+		where := fmt.Sprintf("%s[%d]", path, i)
+
+		actions := 0
+		for _, set := range []bool{step.Send != "", step.Expect != "", step.Sleep > 0, step.Loop > 0} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("%s: a step needs exactly one of send, expect, sleep, or loop", where)
+		}
+
+		for _, payload := range []string{step.Send, step.Expect} {
+			if payload != "" && schema.PayloadByName(payload) == nil {
+				return fmt.Errorf("%s: unknown payload %q", where, payload)
+			}
+		}
+		if step.Loop > 0 {
+			if err := validateSteps(step.Steps, schema, where+".steps"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces ${client} in every string of v and returns it as JSON
+func expand(v map[string]any, client int) ([]byte, error) {
+	if v == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(substitute(v, strconv.Itoa(client)))
+}
+
+func substitute(v any, client string) any {
+	switch v := v.(type) {
+	case string:
+		return strings.ReplaceAll(v, "${client}", client)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = substitute(e, client)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = substitute(e, client)
+		}
+		return out
+	}
+	return v
+}
+
+// matches reports whether every key in want has the same value in got. Scalars are compared by
+// their text, since protobuf JSON renders 64-bit integers as strings.
+func matches(want, got any) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range w {
+			if !matches(v, g[k]) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !matches(w[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return fmt.Sprint(want) == fmt.Sprint(got)
+}
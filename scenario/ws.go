@@ -0,0 +1,62 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketConn is a Conn over a WebSocket connection, one GamePacket per binary frame
+type WebSocketConn struct {
+	conn    *websocket.Conn
+	packets chan []byte
+	err     error // Set before packets is closed
+}
+
+// Dial connects to a GamePacket server at url
+func Dial(url string) (*WebSocketConn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", url, err)
+	}
+
+	c := &WebSocketConn{conn: conn, packets: make(chan []byte, 64)}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *WebSocketConn) readLoop() {
+	defer close(c.packets)
+	for {
+		kind, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		if kind == websocket.BinaryMessage {
+			c.packets <- data
+		}
+	}
+}
+
+func (c *WebSocketConn) WritePacket(data []byte) error {
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (c *WebSocketConn) ReadPacket(ctx context.Context) ([]byte, error) {
+	select {
+	case data, ok := <-c.packets:
+		if !ok {
+			return nil, fmt.Errorf("connection closed: %w", c.err)
+		}
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the connection
+func (c *WebSocketConn) Close() error {
+	return c.conn.Close()
+}
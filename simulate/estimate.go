@@ -0,0 +1,132 @@
+package simulate
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+	"github.com/snowmerak/socketgen/stats"
+	"google.golang.org/protobuf/proto"
+)
+
+// Options configures an estimate
+type Options struct {
+	Assumptions stats.Assumptions        // Lengths of variable-size fields, for payloads without a size
+	Samples     map[string]proto.Message // Sample payload values keyed by payload field name, measured exactly
+}
+
+// FlowReport is the estimate of one flow
+type FlowReport struct {
+	Flow    string
+	Rate    float64       // Flows per second, of all clients
+	Packets int           // IP packets per flow, counting every receiver of a fanout
+	Bytes   int           // Bytes on the wire per flow, counting every receiver of a fanout
+	Latency time.Duration // Time from the first packet sent to the last one received, without loss
+	Mean    time.Duration // Expected latency under loss
+	P95     time.Duration // 95th and 99th percentiles of the latency under loss
+	P99     time.Duration
+	Up      float64 // Bytes per second the clients send to the server
+	Down    float64 // Bytes per second the server sends to the clients
+}
+
+// Estimate estimates the latency of every flow of d and the bandwidth it takes at the server.
+//
+// The latency is half a round trip for every change of direction, the delays of the steps, and
+// the transmission time over the bandwidth. Every lost IP packet delays the flow by one RTO, so
+// the mean adds loss/(1-loss) RTOs per packet, and the 95th percentile adds as many RTOs as
+// losses happen in at most 5% of the flows.
+func Estimate(schema *parser.Schema, d *Definition, opts Options) []FlowReport {
+	net := d.Network.withDefaults()
+	clients := max(d.Clients, 1)
+
+	sizes := map[string]int{}
+	for _, r := range stats.Report(schema, stats.Options{Assumptions: opts.Assumptions, Samples: opts.Samples}) {
+		sizes[r.Payload] = r.Size.Typical
+		if r.Sample >= 0 {
+			sizes[r.Payload] = r.Sample
+		}
+	}
+
+	reports := make([]FlowReport, 0, len(d.Flows))
+	for i, f := range d.Flows {
+		r := FlowReport{Flow: f.Name, Rate: f.Rate * float64(clients)}
+		if r.Flow == "" {
+			r.Flow = fmt.Sprintf("flows[%d]", i)
+		}
+
+		var latency time.Duration
+		var path int // IP packets on the critical path, whose losses delay the flow
+		var up, down int
+		direction := ""
+		for _, step := range f.Steps {
+			size := step.Size
+			if size == 0 {
+				size = sizes[step.payload()]
+			}
+			count, fanout := max(step.Count, 1), max(step.Fanout, 1)
+			packets, bytes := net.wire(size)
+
+			latency += step.Delay
+			if dir := stepDirection(step); dir != direction {
+				latency += net.RTT / 2
+				direction = dir
+			}
+			if net.Bandwidth > 0 {
+				latency += time.Duration(float64(count*bytes*8) / float64(net.Bandwidth) * float64(time.Second))
+			}
+			path += count * packets
+
+			r.Packets += count * packets * fanout
+			r.Bytes += count * bytes * fanout
+			if step.Send != "" {
+				up += count * bytes
+			} else {
+				down += count * bytes * fanout
+			}
+		}
+
+		retransmits := float64(path) * net.Loss / (1 - net.Loss)
+		r.Latency = latency
+		r.Mean = latency + time.Duration(retransmits*float64(net.RTO))
+		r.P95 = latency + time.Duration(lossesAt(path, net.Loss, 0.95))*net.RTO
+		r.P99 = latency + time.Duration(lossesAt(path, net.Loss, 0.99))*net.RTO
+		r.Up = r.Rate * float64(up)
+		r.Down = r.Rate * float64(down)
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+func stepDirection(step Step) string {
+	if step.Send != "" {
+		return "up"
+	}
+	return "down"
+}
+
+// wire returns the IP packets and bytes it takes to send a GamePacket of size bytes
+func (n Network) wire(size int) (packets, bytes int) {
+	framed := size + n.Framing
+	packets = max(1, (framed+n.MTU-1)/n.MTU)
+	return packets, framed + packets*n.Overhead
+}
+
+// lossesAt returns the smallest number of losses among packets that at least quantile of the
+// flows stay within, with every packet lost with probability loss
+func lossesAt(packets int, loss, quantile float64) int {
+	if loss <= 0 {
+		return 0
+	}
+	// Binomial distribution, summed term by term
+	p := math.Pow(1-loss, float64(packets))
+	cumulative := p
+	for k := 0; k < packets; k++ {
+		if cumulative >= quantile {
+			return k
+		}
+		p *= float64(packets-k) / float64(k+1) * loss / (1 - loss)
+		cumulative += p
+	}
+	return packets
+}
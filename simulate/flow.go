@@ -0,0 +1,189 @@
+// Package simulate estimates the latency and bandwidth of protocol flows from the schema alone,
+// before any of them is implemented.
+//
+// A flow definition describes the network and what every client does, in YAML (or JSON):
+//
+//	network: {rtt: 80ms, loss: 0.01, bandwidth: 10Mbps}
+//	clients: 1000
+//	flows:
+//	  - name: login
+//	    rate: 0.001          # per second per client
+//	    steps:
+//	      - send: login_req
+//	      - receive: login_res
+//	        delay: 20ms      # server processing
+//	  - name: chat
+//	    rate: 0.5
+//	    steps:
+//	      - send: chat_msg
+//	      - receive: chat_msg
+//	        fanout: 50       # broadcast to the room
+//
+// Steps in the same direction are pipelined; every change of direction waits for the previous
+// packets to arrive, so it costs half a round trip.
+package simulate
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/socketgen/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the network parameters a definition leaves out
+const (
+	DefaultRTT      = 50 * time.Millisecond
+	DefaultMinRTO   = 200 * time.Millisecond // Added to the RTT when no RTO is given, like Linux's minimum RTO
+	DefaultMTU      = 1460                   // Payload bytes per IP packet (Ethernet MTU minus IPv4 and TCP headers)
+	DefaultOverhead = 40                     // Header bytes per IP packet (IPv4 and TCP)
+	DefaultFraming  = 4                      // Framing bytes per packet (a length prefix or a WebSocket frame header)
+)
+
+// Definition is a set of flows over an assumed network
+type Definition struct {
+	Network Network `yaml:"network"`
+	Clients int     `yaml:"clients,omitempty"` // Connected clients (default 1)
+	Flows   []Flow  `yaml:"flows"`
+}
+
+// Network holds the assumed network parameters
+type Network struct {
+	RTT       time.Duration `yaml:"rtt,omitempty"`
+	Loss      float64       `yaml:"loss,omitempty"`      // Probability that an IP packet is lost, 0 to 1
+	RTO       time.Duration `yaml:"rto,omitempty"`       // Retransmission timeout of a lost packet (default RTT + 200ms)
+	Bandwidth Bandwidth     `yaml:"bandwidth,omitempty"` // Of the client's link, for transmission delay (0: not limited)
+	MTU       int           `yaml:"mtu,omitempty"`
+	Overhead  int           `yaml:"overhead,omitempty"`
+	Framing   int           `yaml:"framing,omitempty"`
+}
+
+// Flow is a sequence of packets a client exchanges with the server, repeated at a rate
+type Flow struct {
+	Name  string  `yaml:"name"`
+	Rate  float64 `yaml:"rate"` // Flows per second per client
+	Steps []Step  `yaml:"steps"`
+}
+
+// Step is one packet of a flow, or a burst of them. Exactly one of Send and Receive is set.
+type Step struct {
+	Send    string        `yaml:"send,omitempty"`    // Payload field name the client sends
+	Receive string        `yaml:"receive,omitempty"` // Payload field name the server sends
+	Count   int           `yaml:"count,omitempty"`   // Packets sent back to back (default 1)
+	Fanout  int           `yaml:"fanout,omitempty"`  // Clients receiving the packet, e.g. of a broadcast (default 1)
+	Size    int           `yaml:"size,omitempty"`    // Encoded GamePacket size in bytes (default: estimated from the schema)
+	Delay   time.Duration `yaml:"delay,omitempty"`   // Time before the step is sent, e.g. server processing
+}
+
+// Bandwidth is a link speed in bits per second, written like 10Mbps, 512kbps or 1Gbps
+type Bandwidth float64
+
+var bandwidthPattern = regexp.MustCompile(`^([0-9.]+)\s*([kKMG]?)(bps|bit/s)$`)
+
+func (b *Bandwidth) UnmarshalYAML(node *yaml.Node) error {
+	if n, err := strconv.ParseFloat(node.Value, 64); err == nil {
+		*b = Bandwidth(n)
+		return nil
+	}
+	m := bandwidthPattern.FindStringSubmatch(strings.TrimSpace(node.Value))
+	if m == nil {
+		return fmt.Errorf("invalid bandwidth %q; expected e.g. 10Mbps", node.Value)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid bandwidth %q: %w", node.Value, err)
+	}
+	*b = Bandwidth(n * map[string]float64{"": 1, "k": 1e3, "K": 1e3, "M": 1e6, "G": 1e9}[m[2]])
+	return nil
+}
+
+// Load reads a flow definition. JSON files work as well, since JSON is valid YAML.
+func Load(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flow definition: %w", err)
+	}
+
+	var d Definition
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse flow definition %s: %w", path, err)
+	}
+	return &d, nil
+}
+
+// Validate checks the network parameters and that every step names a payload of schema
+func (d *Definition) Validate(schema *parser.Schema) error {
+	n := d.Network
+	switch {
+	case n.RTT < 0 || n.RTO < 0:
+		return fmt.Errorf("network: rtt and rto must not be negative")
+	case n.Loss < 0 || n.Loss >= 1:
+		return fmt.Errorf("network: loss must be at least 0 and below 1")
+	case n.Bandwidth < 0 || n.MTU < 0 || n.Overhead < 0 || n.Framing < 0:
+		return fmt.Errorf("network: bandwidth, mtu, overhead and framing must not be negative")
+	case d.Clients < 0:
+		return fmt.Errorf("clients must not be negative")
+	case len(d.Flows) == 0:
+		return fmt.Errorf("no flows defined")
+	}
+
+	for i, f := range d.Flows {
+		where := fmt.Sprintf("flows[%d]", i)
+		if f.Name != "" {
+			where = fmt.Sprintf("flow %q", f.Name)
+		}
+		if f.Rate < 0 {
+			return fmt.Errorf("%s: rate must not be negative", where)
+		}
+		if len(f.Steps) == 0 {
+			return fmt.Errorf("%s: no steps", where)
+		}
+		for j, step := range f.Steps {
+			at := fmt.Sprintf("%s: steps[%d]", where, j)
+			if (step.Send == "") == (step.Receive == "") {
+				return fmt.Errorf("%s: a step needs exactly one of send or receive", at)
+			}
+			if payload := step.payload(); schema.PayloadByName(payload) == nil {
+				return fmt.Errorf("%s: unknown payload %q", at, payload)
+			}
+			if step.Count < 0 || step.Fanout < 0 || step.Size < 0 || step.Delay < 0 {
+				return fmt.Errorf("%s: count, fanout, size and delay must not be negative", at)
+			}
+			if step.Fanout > 0 && step.Send != "" {
+				return fmt.Errorf("%s: fanout only applies to receive steps", at)
+			}
+		}
+	}
+	return nil
+}
+
+func (s Step) payload() string {
+	if s.Send != "" {
+		return s.Send
+	}
+	return s.Receive
+}
+
+// withDefaults returns the network with its defaults filled in
+func (n Network) withDefaults() Network {
+	if n.RTT == 0 {
+		n.RTT = DefaultRTT
+	}
+	if n.RTO == 0 {
+		n.RTO = n.RTT + DefaultMinRTO
+	}
+	if n.MTU == 0 {
+		n.MTU = DefaultMTU
+	}
+	if n.Overhead == 0 {
+		n.Overhead = DefaultOverhead
+	}
+	if n.Framing == 0 {
+		n.Framing = DefaultFraming
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package stats
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Assumptions controls how variable-length fields are sized when estimating
+type Assumptions struct {
+	TypicalString   int // Typical length of string fields in bytes
+	MaxString       int // Maximum length of string fields in bytes
+	TypicalBytes    int // Typical length of bytes fields
+	MaxBytes        int // Maximum length of bytes fields
+	TypicalRepeated int // Typical element count of repeated and map fields
+	MaxRepeated     int // Maximum element count of repeated and map fields
+	MaxDepth        int // Nesting depth after which recursive messages are treated as unbounded
+}
+
+// DefaultAssumptions are used when no assumptions are given
+var DefaultAssumptions = Assumptions{
+	TypicalString:   16,
+	MaxString:       256,
+	TypicalBytes:    64,
+	MaxBytes:        4096,
+	TypicalRepeated: 4,
+	MaxRepeated:     64,
+	MaxDepth:        8,
+}
+
+// Size is an estimated encoded size range in bytes
+type Size struct {
+	Min       int
+	Typical   int
+	Max       int
+	Unbounded bool // The message is recursive, so Max is only a lower bound
+}
+
+func (s Size) add(o Size) Size {
+	return Size{
+		Min:       s.Min + o.Min,
+		Typical:   s.Typical + o.Typical,
+		Max:       s.Max + o.Max,
+		Unbounded: s.Unbounded || o.Unbounded,
+	}
+}
+
+// EstimateMessage estimates the encoded size of a message.
+// Min is the size with every field left at its default, which proto3 omits from the wire.
+func EstimateMessage(md protoreflect.MessageDescriptor, a Assumptions) Size {
+	return estimateMessage(md, a, 0)
+}
+
+// EstimateField estimates the encoded size of a single field including its tag
+func EstimateField(fd protoreflect.FieldDescriptor, a Assumptions) Size {
+	return estimateField(fd, a, 0)
+}
+
+func estimateMessage(md protoreflect.MessageDescriptor, a Assumptions, depth int) Size {
+	if depth > a.MaxDepth {
+		return Size{Unbounded: true}
+	}
+
+	var total Size
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		total = total.add(estimateField(fields.Get(i), a, depth))
+	}
+
+	// Only one member of each oneof can be set, so count the largest member instead of all of them
+	oneofs := md.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		members := oneofs.Get(i).Fields()
+		var sum, largest Size
+		for j := 0; j < members.Len(); j++ {
+			size := estimateField(members.Get(j), a, depth)
+			sum = sum.add(size)
+			largest.Typical = max(largest.Typical, size.Typical)
+			largest.Max = max(largest.Max, size.Max)
+			largest.Unbounded = largest.Unbounded || size.Unbounded
+		}
+		total.Typical -= sum.Typical - largest.Typical
+		total.Max -= sum.Max - largest.Max
+	}
+
+	return total
+}
+
+func estimateField(fd protoreflect.FieldDescriptor, a Assumptions, depth int) Size {
+	tag := protowire.SizeTag(fd.Number())
+
+	if fd.IsMap() {
+		entry := estimateMessage(fd.Message(), a, depth+1)
+		return Size{
+			Typical:   a.TypicalRepeated * (tag + lengthPrefixed(entry.Typical)),
+			Max:       a.MaxRepeated * (tag + lengthPrefixed(entry.Max)),
+			Unbounded: entry.Unbounded,
+		}
+	}
+
+	value := estimateValue(fd, a, depth)
+	if fd.IsList() {
+		if fd.IsPacked() {
+			return Size{
+				Typical: tag + lengthPrefixed(a.TypicalRepeated*value.Typical),
+				Max:     tag + lengthPrefixed(a.MaxRepeated*value.Max),
+			}
+		}
+		return Size{
+			Typical:   a.TypicalRepeated * (tag + value.Typical),
+			Max:       a.MaxRepeated * (tag + value.Max),
+			Unbounded: value.Unbounded,
+		}
+	}
+
+	return Size{
+		Typical:   tag + value.Typical,
+		Max:       tag + value.Max,
+		Unbounded: value.Unbounded,
+	}
+}
+
+// estimateValue sizes a single value of the field's type, without the tag
+func estimateValue(fd protoreflect.FieldDescriptor, a Assumptions, depth int) Size {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return Size{Typical: 1, Max: 1}
+	case protoreflect.EnumKind:
+		return Size{Typical: 1, Max: 10}
+	case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Uint64Kind:
+		// Negative int32 values are sign-extended to 10 bytes
+		return Size{Typical: 2, Max: 10}
+	case protoreflect.Uint32Kind, protoreflect.Sint32Kind:
+		return Size{Typical: 2, Max: 5}
+	case protoreflect.Sint64Kind:
+		return Size{Typical: 2, Max: 10}
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind, protoreflect.FloatKind:
+		return Size{Typical: 4, Max: 4}
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind, protoreflect.DoubleKind:
+		return Size{Typical: 8, Max: 8}
+	case protoreflect.StringKind:
+		return Size{Typical: lengthPrefixed(a.TypicalString), Max: lengthPrefixed(a.MaxString)}
+	case protoreflect.BytesKind:
+		return Size{Typical: lengthPrefixed(a.TypicalBytes), Max: lengthPrefixed(a.MaxBytes)}
+	case protoreflect.MessageKind:
+		inner := estimateMessage(fd.Message(), a, depth+1)
+		return Size{
+			Typical:   lengthPrefixed(inner.Typical),
+			Max:       lengthPrefixed(inner.Max),
+			Unbounded: inner.Unbounded,
+		}
+	case protoreflect.GroupKind:
+		inner := estimateMessage(fd.Message(), a, depth+1)
+		endTag := protowire.SizeTag(fd.Number())
+		return Size{Typical: inner.Typical + endTag, Max: inner.Max + endTag, Unbounded: inner.Unbounded}
+	}
+	return Size{}
+}
+
+// lengthPrefixed returns the size of n bytes preceded by their varint length
+func lengthPrefixed(n int) int {
+	return protowire.SizeVarint(uint64(n)) + n
+}
@@ -0,0 +1,138 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/snowmerak/socketgen/parser"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Budget statuses reported per payload
+const (
+	StatusNoBudget = "-"
+	StatusOK       = "ok"
+	StatusRisk     = "risk" // Typical size fits, but the maximum may exceed the budget
+	StatusOver     = "over" // Typical (or sample) size exceeds the budget
+)
+
+// Options configures a size report
+type Options struct {
+	Assumptions Assumptions
+	Budget      int                      // Default budget in bytes for every payload, 0 for none
+	Budgets     map[string]int           // Per-payload budgets keyed by payload field name
+	Samples     map[string]proto.Message // Sample payload values keyed by payload field name
+}
+
+// PayloadReport summarizes the estimated wire size of a GamePacket carrying one payload
+type PayloadReport struct {
+	Payload string // The payload field name (e.g., "login_req")
+	Message string // The full message name (e.g., "packet.LoginReq")
+	Size    Size   // Estimated size of the whole GamePacket
+	Header  int    // Typical size of the header portion
+	Sample  int    // Size of the GamePacket built from the sample value, -1 if no sample was given
+	Budget  int
+	Status  string
+}
+
+// HeaderShare returns the typical header size as a percentage of the typical packet size
+func (r PayloadReport) HeaderShare() float64 {
+	if r.Size.Typical == 0 {
+		return 0
+	}
+	return float64(r.Header) * 100 / float64(r.Size.Typical)
+}
+
+// Report estimates the size of a GamePacket for every payload in the schema
+func Report(schema *parser.Schema, opts Options) []PayloadReport {
+	var header Size
+	if schema.Header != nil {
+		header = EstimateField(schema.Header, opts.Assumptions)
+	}
+
+	fields := schema.Payload.Fields()
+	reports := make([]PayloadReport, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		name := string(field.Name())
+
+		payload := EstimateField(field, opts.Assumptions)
+		// A set oneof member is always written, even when the message is empty
+		payload.Min = protowire.SizeTag(field.Number()) + lengthPrefixed(0)
+
+		report := PayloadReport{
+			Payload: name,
+			Message: string(field.Message().FullName()),
+			Size:    header.add(payload),
+			Header:  header.Typical,
+			Sample:  -1,
+			Budget:  opts.Budget,
+		}
+		if budget, ok := opts.Budgets[name]; ok {
+			report.Budget = budget
+		}
+		if sample, ok := opts.Samples[name]; ok {
+			report.Sample = header.Typical + protowire.SizeTag(field.Number()) + lengthPrefixed(proto.Size(sample))
+		}
+		report.Status = budgetStatus(report)
+
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+func budgetStatus(r PayloadReport) string {
+	if r.Budget <= 0 {
+		return StatusNoBudget
+	}
+
+	expected := r.Size.Typical
+	if r.Sample >= 0 {
+		expected = r.Sample
+	}
+
+	switch {
+	case expected > r.Budget:
+		return StatusOver
+	case r.Size.Max > r.Budget || r.Size.Unbounded:
+		return StatusRisk
+	}
+	return StatusOK
+}
+
+// LoadSamples reads sample payload values from a JSON file keyed by payload field name:
+//
+//	{
+//	  "login_req": { "id": "alice", "pw": "hunter2" }
+//	}
+func LoadSamples(path string, schema *parser.Schema) (map[string]proto.Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read samples file: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse samples file: %w", err)
+	}
+
+	samples := make(map[string]proto.Message, len(raw))
+	for name, body := range raw {
+		field := schema.PayloadByName(name)
+		if field == nil {
+			return nil, fmt.Errorf("sample %q does not match any payload field", name)
+		}
+
+		msg := dynamicpb.NewMessage(field.Message())
+		if err := schema.ParseJSON(body, msg); err != nil {
+			return nil, fmt.Errorf("sample %s: %w", name, err)
+		}
+		samples[name] = msg
+	}
+
+	return samples, nil
+}
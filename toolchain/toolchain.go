@@ -0,0 +1,314 @@
+// Package toolchain installs the protoc release socketgen is pinned to, and optionally the
+// protoc-gen-go release matching its protobuf runtime, into the user cache directory. Once
+// installed, every command runs them instead of the protoc and plugins in PATH, so the same
+// socketgen version produces the same output on every machine.
+package toolchain
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+//go:generate go run checksums_gen.go
+
+// Versions socketgen is pinned to; checksums.go holds the SHA-256 of their release archives
+const (
+	ProtocVersion      = "29.3"
+	ProtocGenGoVersion = "1.36.10" // The google.golang.org/protobuf version socketgen depends on
+)
+
+// Dir returns the directory the toolchain is installed to, "" if the user has no cache directory
+func Dir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "socketgen", "toolchain")
+}
+
+func protocDir() string {
+	return filepath.Join(Dir(), "protoc-"+ProtocVersion)
+}
+
+func pluginsDir() string {
+	return filepath.Join(Dir(), "protoc-gen-go-"+ProtocGenGoVersion)
+}
+
+func exe(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// Protoc returns the path of the installed protoc, or "protoc" to run the one in PATH if the
+// toolchain is not installed
+func Protoc() string {
+	path := filepath.Join(protocDir(), "bin", exe("protoc"))
+	if Dir() == "" || !isFile(path) {
+		return "protoc"
+	}
+	return path
+}
+
+// Plugin returns the path of the installed protoc plugin name (e.g. protoc-gen-go), "" if it is
+// not installed
+func Plugin(name string) string {
+	path := filepath.Join(pluginsDir(), exe(name))
+	if Dir() == "" || !isFile(path) {
+		return ""
+	}
+	return path
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Install downloads protoc, and protoc-gen-go if withPlugins is set, for the current OS and
+// architecture unless they are installed already. Progress is written to log.
+func Install(withPlugins bool, log io.Writer) error {
+	if Dir() == "" {
+		return fmt.Errorf("no user cache directory to install the toolchain to")
+	}
+
+	if Protoc() == "protoc" {
+		platform, err := protocPlatform()
+		if err != nil {
+			return err
+		}
+		url := fmt.Sprintf("https://github.com/protocolbuffers/protobuf/releases/download/v%s/%s", ProtocVersion, protocArchive(platform))
+		// The release unpacks to bin/protoc and include/, where protoc finds the well-known types
+		if err := install(url, protocDir(), func(name string) bool { return true }, log); err != nil {
+			return fmt.Errorf("failed to install protoc %s: %w", ProtocVersion, err)
+		}
+	}
+	fmt.Fprintf(log, "protoc %s: %s\n", ProtocVersion, Protoc())
+
+	if withPlugins {
+		if Plugin("protoc-gen-go") == "" {
+			url := fmt.Sprintf("https://github.com/protocolbuffers/protobuf-go/releases/download/v%s/%s", ProtocGenGoVersion, protocGenGoArchive(runtime.GOOS, runtime.GOARCH))
+			keep := func(name string) bool { return name == exe("protoc-gen-go") }
+			if err := install(url, pluginsDir(), keep, log); err != nil {
+				return fmt.Errorf("failed to install protoc-gen-go %s: %w", ProtocGenGoVersion, err)
+			}
+		}
+		fmt.Fprintf(log, "protoc-gen-go %s: %s\n", ProtocGenGoVersion, Plugin("protoc-gen-go"))
+	}
+	return nil
+}
+
+// protocPlatform returns the platform suffix of the protoc release archives
+func protocPlatform() (string, error) {
+	platforms := map[string]string{
+		"linux/amd64":   "linux-x86_64",
+		"linux/arm64":   "linux-aarch_64",
+		"linux/386":     "linux-x86_32",
+		"linux/ppc64le": "linux-ppcle_64",
+		"linux/s390x":   "linux-s390_64",
+		"darwin/amd64":  "osx-x86_64",
+		"darwin/arm64":  "osx-aarch_64",
+		"windows/amd64": "win64",
+		"windows/386":   "win32",
+	}
+	platform, ok := platforms[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		return "", fmt.Errorf("protoc is not released for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	return platform, nil
+}
+
+// protocArchive returns the name of the protoc release archive for a platform of protocPlatform
+func protocArchive(platform string) string {
+	return fmt.Sprintf("protoc-%s-%s.zip", ProtocVersion, platform)
+}
+
+// protocGenGoArchive returns the name of the protoc-gen-go release archive for an OS and
+// architecture
+func protocGenGoArchive(goos, goarch string) string {
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("protoc-gen-go.v%s.%s.%s%s", ProtocGenGoVersion, goos, goarch, ext)
+}
+
+// install downloads the archive at url and unpacks the files keep selects into dir. The archive
+// must have the SHA-256 pinned in checksums, since its files are run afterwards. The files are
+// unpacked next to dir and renamed into place, so an interrupted install leaves nothing behind.
+func install(url, dir string, keep func(name string) bool, log io.Writer) error {
+	name := path.Base(url)
+	want, ok := checksums[name]
+	if !ok {
+		return fmt.Errorf("no SHA-256 is pinned for %s, so it cannot be verified", name)
+	}
+
+	fmt.Fprintf(log, "Downloading %s\n", url)
+	archive, err := download(url, filepath.Dir(dir))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+
+	sum, err := fileSHA256(archive)
+	if err != nil {
+		return err
+	}
+	if sum != want {
+		return fmt.Errorf("SHA-256 of %s is %s, but %s is pinned; the download was altered", name, sum, want)
+	}
+	fmt.Fprintf(log, "SHA-256 %s (verified)\n", sum)
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if strings.HasSuffix(url, ".zip") {
+		err = unzip(archive, tmpDir, keep)
+	} else {
+		err = untar(archive, tmpDir, keep)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unpack %s: %w", url, err)
+	}
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		// Another install finished first
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// download writes the body at url to a new file in dir and returns its path
+func download(url, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	f, err := os.CreateTemp(dir, "download-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func unzip(archive, dir string, keep func(name string) bool) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !keep(f.Name) {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(dir, f.Name, f.Mode(), src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func untar(archive, dir string, keep func(name string) bool) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || !keep(hdr.Name) {
+			continue
+		}
+		if err := writeFile(dir, hdr.Name, hdr.FileInfo().Mode(), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile writes the archive entry name to dir, refusing names that escape it
+func writeFile(dir, name string, mode os.FileMode, r io.Reader) error {
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("archive entry %q is outside the archive", name)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,93 @@
+package toolchain
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// releaseArchive returns a zip holding bin/protoc with content
+func releaseArchive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("bin/protoc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(f, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveRelease serves archive under name and pins the SHA-256 of pinned for it until the test ends
+func serveRelease(t *testing.T, name string, archive, pinned []byte) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	t.Cleanup(server.Close)
+
+	sum := sha256.Sum256(pinned)
+	saved := checksums
+	checksums = map[string]string{name: hex.EncodeToString(sum[:])}
+	t.Cleanup(func() { checksums = saved })
+	return server.URL + "/" + name
+}
+
+func TestInstallVerifiesChecksum(t *testing.T) {
+	name := protocArchive("linux-x86_64")
+	genuine := releaseArchive(t, "protoc")
+	dir := filepath.Join(t.TempDir(), "protoc")
+
+	url := serveRelease(t, name, genuine, genuine)
+	if err := install(url, dir, func(string) bool { return true }, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "bin", "protoc")); err != nil || string(data) != "protoc" {
+		t.Errorf("got %q, %v, want the installed protoc", data, err)
+	}
+}
+
+func TestInstallRefusesTamperedArchive(t *testing.T) {
+	name := protocArchive("linux-x86_64")
+	genuine := releaseArchive(t, "protoc")
+	tampered := releaseArchive(t, "protoc with a backdoor")
+	dir := filepath.Join(t.TempDir(), "protoc")
+
+	url := serveRelease(t, name, tampered, genuine)
+	err := install(url, dir, func(string) bool { return true }, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Fatalf("got %v, want a SHA-256 mismatch", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("a tampered archive was unpacked to %s", dir)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(dir))
+	if len(entries) != 0 {
+		t.Errorf("the tampered download was left behind: %v", entries)
+	}
+}
+
+func TestInstallRefusesUnpinnedArchive(t *testing.T) {
+	genuine := releaseArchive(t, "protoc")
+	url := serveRelease(t, protocArchive("linux-x86_64"), genuine, genuine)
+	url = strings.Replace(url, "linux-x86_64", "linux-riscv_64", 1)
+
+	err := install(url, filepath.Join(t.TempDir(), "protoc"), func(string) bool { return true }, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "no SHA-256 is pinned") {
+		t.Fatalf("got %v, want a refusal of an archive without a pinned checksum", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Package watch detects changes to files by polling their modification time and size.
+package watch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func snapshot(paths []string) map[string]fileState {
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			states[path] = fileState{}
+			continue
+		}
+		states[path] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+	}
+	return states
+}
+
+func changed(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || other != state {
+			return true
+		}
+	}
+	return false
+}
+
+// rebase returns the states of last for the paths still watched, and the current state of the
+// paths watched since
+func rebase(last map[string]fileState, paths []string) map[string]fileState {
+	states := make(map[string]fileState, len(paths))
+	var added []string
+	for _, path := range paths {
+		if state, ok := last[path]; ok {
+			states[path] = state
+		} else {
+			added = append(added, path)
+		}
+	}
+	for path, state := range snapshot(added) {
+		states[path] = state
+	}
+	return states
+}
+
+// Poll checks the files returned by paths every interval and calls onChange once they have
+// changed and then stayed unchanged for one more interval, so editors saving in several steps
+// trigger a single call. paths is re-evaluated on every check, so the watched set can change
+// (e.g. when an import is added); the files onChange starts watching are not a change themselves.
+// Poll blocks until ctx is done.
+func Poll(ctx context.Context, paths func() []string, interval time.Duration, onChange func()) {
+	last := snapshot(paths())
+	pending := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := snapshot(paths())
+		if changed(last, current) {
+			last = current
+			pending = true
+			continue
+		}
+
+		if pending {
+			pending = false
+			onChange()
+			last = rebase(last, paths())
+		}
+	}
+}
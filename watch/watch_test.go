@@ -0,0 +1,161 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const interval = 20 * time.Millisecond
+
+// poller runs Poll over the paths set with watch, counting the calls of onChange
+type poller struct {
+	mu      sync.Mutex
+	paths   []string
+	started sync.Once
+	polling chan struct{}
+	calls   chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// startPoll starts Poll over paths, stopped when the test ends, and returns once Poll has taken
+// the first snapshot; onChange, when not nil, runs on every call before it is counted
+func startPoll(t *testing.T, paths []string, onChange func(p *poller)) *poller {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &poller{paths: paths, calls: make(chan struct{}, 16), cancel: cancel, done: make(chan struct{}), polling: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		Poll(ctx, p.watched, interval, func() {
+			if onChange != nil {
+				onChange(p)
+			}
+			p.calls <- struct{}{}
+		})
+	}()
+	t.Cleanup(p.stop)
+	// Poll snapshots the paths right after reading them, well before its first check
+	<-p.polling
+	time.Sleep(interval / 2)
+	return p
+}
+
+func (p *poller) watched() []string {
+	defer p.started.Do(func() { close(p.polling) })
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.paths...)
+}
+
+func (p *poller) watch(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paths = append(p.paths, path)
+}
+
+func (p *poller) stop() {
+	p.cancel()
+	<-p.done
+}
+
+// expectCall fails unless onChange is called within a second
+func (p *poller) expectCall(t *testing.T, what string) {
+	t.Helper()
+	select {
+	case <-p.calls:
+	case <-time.After(time.Second):
+		t.Fatalf("onChange was not called after %s", what)
+	}
+}
+
+// expectNoCall fails if onChange is called within a few intervals
+func (p *poller) expectNoCall(t *testing.T, what string) {
+	t.Helper()
+	select {
+	case <-p.calls:
+		t.Fatalf("onChange was called %s", what)
+	case <-time.After(5 * interval):
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPollDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	schema, header := filepath.Join(dir, "packet.proto"), filepath.Join(dir, "header.proto")
+	writeFile(t, schema, "v1")
+	p := startPoll(t, []string{schema, header}, nil)
+	p.expectNoCall(t, "with nothing changed")
+
+	writeFile(t, schema, "v2 is longer")
+	p.expectCall(t, "modifying a file")
+	writeFile(t, header, "created")
+	p.expectCall(t, "creating a watched file")
+	if err := os.Remove(header); err != nil {
+		t.Fatal(err)
+	}
+	p.expectCall(t, "deleting a watched file")
+	p.expectNoCall(t, "again with nothing changed")
+}
+
+func TestPollDebounces(t *testing.T) {
+	dir := t.TempDir()
+	schema, header := filepath.Join(dir, "packet.proto"), filepath.Join(dir, "header.proto")
+	writeFile(t, schema, "v")
+	writeFile(t, header, "v")
+	p := startPoll(t, []string{schema, header}, nil)
+
+	// An editor saving every file, several times, faster than the interval
+	content := "v"
+	for range 10 {
+		content += "v"
+		writeFile(t, schema, content)
+		writeFile(t, header, content)
+		time.Sleep(interval / 4)
+	}
+	p.expectCall(t, "saving several files")
+	p.expectNoCall(t, "twice for one burst of saves")
+}
+
+func TestPollWatchesPathsAddedLater(t *testing.T) {
+	dir := t.TempDir()
+	schema, header := filepath.Join(dir, "packet.proto"), filepath.Join(dir, "header.proto")
+	writeFile(t, schema, "v1")
+	writeFile(t, header, "v1")
+	// The schema starts importing header.proto, so onChange starts watching it
+	p := startPoll(t, []string{schema}, func(p *poller) {
+		if len(p.watched()) == 1 {
+			p.watch(header)
+		}
+	})
+
+	writeFile(t, schema, "import header.proto")
+	p.expectCall(t, "modifying the schema")
+	p.expectNoCall(t, "for starting to watch a file")
+	writeFile(t, header, "v2 is longer")
+	p.expectCall(t, "modifying a file watched since")
+}
+
+func TestPollStops(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "packet.proto")
+	writeFile(t, schema, "v1")
+	p := startPoll(t, []string{schema}, nil)
+
+	p.cancel()
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		t.Fatal("Poll did not return once its context was done")
+	}
+	writeFile(t, schema, "v2 is longer")
+	p.expectNoCall(t, "after Poll returned")
+}